@@ -9,16 +9,39 @@ package main
 import (
 	"os"
 
+	"github.com/jawher/mow.cli"
 	"github.com/joyent/conch-shell/pkg/cmd/conch1"
 	"github.com/joyent/conch-shell/pkg/commands/admin"
+	"github.com/joyent/conch-shell/pkg/commands/alias"
 	"github.com/joyent/conch-shell/pkg/commands/api"
+	"github.com/joyent/conch-shell/pkg/commands/apply"
+	"github.com/joyent/conch-shell/pkg/commands/batch"
+	"github.com/joyent/conch-shell/pkg/commands/components"
+	"github.com/joyent/conch-shell/pkg/commands/copier"
 	"github.com/joyent/conch-shell/pkg/commands/datacenter"
+	"github.com/joyent/conch-shell/pkg/commands/debug"
 	"github.com/joyent/conch-shell/pkg/commands/devices"
+	"github.com/joyent/conch-shell/pkg/commands/doctor"
+	"github.com/joyent/conch-shell/pkg/commands/events"
+	"github.com/joyent/conch-shell/pkg/commands/export"
 	"github.com/joyent/conch-shell/pkg/commands/global"
 	"github.com/joyent/conch-shell/pkg/commands/hardware"
+	"github.com/joyent/conch-shell/pkg/commands/index"
+	"github.com/joyent/conch-shell/pkg/commands/onboard"
+	"github.com/joyent/conch-shell/pkg/commands/plugins"
 	"github.com/joyent/conch-shell/pkg/commands/profile"
 	"github.com/joyent/conch-shell/pkg/commands/rack"
 	"github.com/joyent/conch-shell/pkg/commands/relay"
+	"github.com/joyent/conch-shell/pkg/commands/release"
+	"github.com/joyent/conch-shell/pkg/commands/report"
+	"github.com/joyent/conch-shell/pkg/commands/rma"
+	"github.com/joyent/conch-shell/pkg/commands/room"
+	"github.com/joyent/conch-shell/pkg/commands/schema"
+	"github.com/joyent/conch-shell/pkg/commands/stats"
+	"github.com/joyent/conch-shell/pkg/commands/status"
+	"github.com/joyent/conch-shell/pkg/commands/switches"
+	"github.com/joyent/conch-shell/pkg/commands/tickets"
+	"github.com/joyent/conch-shell/pkg/commands/undo"
 	"github.com/joyent/conch-shell/pkg/commands/update"
 	"github.com/joyent/conch-shell/pkg/commands/user"
 	"github.com/joyent/conch-shell/pkg/commands/validation"
@@ -26,21 +49,49 @@ import (
 )
 
 func main() {
+	_ = newApp().Run(os.Args)
+}
+
+// newApp builds the app with every command loaded. 'conch batch' builds a
+// fresh one for each command it runs.
+func newApp() *cli.Cli {
 	app := conch1.Init()
 
 	api.Init(app)
+	apply.Init(app)
+	batch.Init(app, newApp)
 	admin.Init(app)
+	alias.Init(app)
+	components.Init(app)
+	copier.Init(app)
 	datacenter.Init(app)
+	debug.Init(app)
 	devices.Init(app)
+	doctor.Init(app)
+	events.Init(app)
+	export.Init(app)
 	global.Init(app)
 	hardware.Init(app)
+	index.Init(app)
+	onboard.Init(app)
+	plugins.Init(app)
 	profile.Init(app)
 	rack.Init(app)
 	relay.Init(app)
+	release.Init(app)
+	report.Init(app)
+	rma.Init(app)
+	room.Init(app)
+	schema.Init(app)
+	stats.Init(app)
+	status.Init(app)
+	switches.Init(app)
+	tickets.Init(app)
 	user.Init(app)
 	workspaces.Init(app)
 	validation.Init(app)
 	update.Init(app)
+	undo.Init(app)
 
-	_ = app.Run(os.Args)
+	return app
 }
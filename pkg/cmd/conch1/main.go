@@ -8,8 +8,10 @@ package conch1
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/jawher/mow.cli"
 	"github.com/joyent/conch-shell/pkg/conch"
@@ -23,10 +25,15 @@ func Init() *cli.Cli {
 	util.UserAgent = fmt.Sprintf("conch shell v%s-%s", util.Version, util.GitRev)
 
 	app := cli.App("conch", "Command line interface for Conch")
+	if util.BatchMode {
+		// A bad command in a batch must not end the whole batch
+		app.ErrorHandling = flag.ContinueOnError
+	}
 
 	app.Version("version", util.Version)
 
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"version",
 		"Get more detailed version info than --version",
 		func(cmd *cli.Cmd) {
@@ -41,7 +48,14 @@ func Init() *cli.Cli {
 					conch.MinimumAPIVersion,
 					conch.BreakingAPIVersion,
 				)
-				if util.DisableApiVersionCheck() {
+				if util.ActiveProfile != nil && util.ActiveProfile.APIVersion != "" {
+					fmt.Printf(
+						"  Profile '%s' requires API version: %s\n",
+						util.ActiveProfile.Name,
+						util.ActiveProfile.APIVersion,
+					)
+				}
+				if util.DisableApiVersionCheck() || util.SkipVersionCheck {
 					fmt.Println("\n** API version checking is disabled. Functionality cannot be guaranteed **")
 				}
 			}
@@ -71,16 +85,85 @@ func Init() *cli.Cli {
 		})
 
 		useJSON         = app.BoolOpt("json j", false, "Output JSON")
-		configFile      = app.StringOpt("config c", "~/.conch.json", "Path to config file")
+		configFile      = app.StringOpt("config c", config.DefaultConfigPath(), "Path to config file")
 		noVersion       = app.BoolOpt("no-version-check", false, "Does nothing. Included for backwards compatibility.") // TODO(sungo): remove back compat
 		profileOverride = app.StringOpt("profile p", "", "Override the active profile")
 		debugMode       = app.BoolOpt("debug", false, "Debug mode")
 		traceMode       = app.BoolOpt("trace", false, "Trace http requests. Warning: this is super loud")
+		noCompression   = app.Bool(cli.BoolOpt{
+			Name:   "no-compression",
+			Value:  false,
+			Desc:   "Ask the API for uncompressed responses",
+			EnvVar: "CONCH_NO_COMPRESSION",
+		})
+		filterOpt       = app.StringOpt("filter", "", util.FilterHelp)
+		countOnly       = app.BoolOpt("count-only", false, "For lists, only print the number of entries")
+		outputPluginOpt = app.String(cli.StringOpt{
+			Name:   "output-plugin",
+			Value:  "",
+			Desc:   "Format the output with this output plugin from the config. See 'conch output-plugins'",
+			EnvVar: "CONCH_OUTPUT_PLUGIN",
+		})
+		summary = app.Bool(cli.BoolOpt{
+			Name:   "summary",
+			Value:  false,
+			Desc:   "Follow tables with the number of rows and, where it makes sense, counts per health, phase, or role",
+			EnvVar: "CONCH_SUMMARY",
+		})
+		skipVersionCheck = app.Bool(cli.BoolOpt{
+			Name:   "skip-version-check",
+			Value:  false,
+			Desc:   "Don't check that the API server's version is one this shell works with. At your own risk",
+			EnvVar: "CONCH_SKIP_VERSION_CHECK",
+		})
+		maxRequests = app.Int(cli.IntOpt{
+			Name:   "max-requests",
+			Value:  0,
+			Desc:   "Stop a command before it makes more than this many API requests, or ask first if it can tell up front. Defaults to the profile's setting. 0 is no limit",
+			EnvVar: "CONCH_MAX_REQUESTS",
+		})
+		rawNumbers = app.Bool(cli.BoolOpt{
+			Name:   "raw-numbers",
+			Value:  false,
+			Desc:   "Print sizes and counts as the API gives them, rather than eg '3.6 TiB' or '1,234'",
+			EnvVar: "CONCH_RAW_NUMBERS",
+		})
+		profileRun = app.Bool(cli.BoolOpt{
+			Name:   "profile-run",
+			Value:  false,
+			Desc:   "When the command is done, print how many API calls it made, the time spent on each endpoint, and the slowest calls, for attaching to performance bug reports",
+			EnvVar: util.ProfileRunEnv,
+		})
+		cacheTTL = app.String(cli.StringOpt{
+			Name:   "cache-ttl",
+			Value:  "",
+			Desc:   "With --json, print the result of the same command run within this long, eg '25s', instead of running it again. Only commands that just read from the API are cached. Defaults to the profile's setting",
+			EnvVar: "CONCH_CACHE_TTL",
+		})
 	)
 
 	app.Before = func() {
+		util.HandleInterrupts()
+
 		util.Debug = *debugMode
 		util.Trace = *traceMode
+		util.NoCompression = *noCompression
+		util.CountOnly = *countOnly
+		util.Summary = *summary
+		util.SkipVersionCheck = *skipVersionCheck
+		util.RawNumbers = *rawNumbers
+
+		if *profileRun {
+			util.StartRunProfile()
+		}
+
+		if *filterOpt != "" {
+			f, err := util.ParseFilter(*filterOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+			util.OutputFilter = f
+		}
 
 		if *useJSON {
 			util.JSON = true
@@ -148,10 +231,49 @@ func Init() *cli.Cli {
 			}
 		}
 
+		util.MaxRequests = *maxRequests
+		if util.MaxRequests == 0 && util.ActiveProfile != nil {
+			util.MaxRequests = util.ActiveProfile.MaxRequests
+		}
+
+		if *outputPluginOpt != "" {
+			if err := util.UseOutputPlugin(*outputPluginOpt); err != nil {
+				util.Bail(err)
+			}
+		}
+
 		// There is no way to avoid the version check, save piping stderr to
 		// /dev/null.  The API is changing too much and introducing too much
 		// breakage on the regular for users to stick using old versions.
-		util.GithubReleaseCheck()
+		// Commands run by 'conch batch' skip it; the batch itself was
+		// checked.
+		if !util.BatchMode {
+			util.GithubReleaseCheck()
+		}
+
+		util.StartStats()
+
+		ttl := *cacheTTL
+		if ttl == "" && util.ActiveProfile != nil {
+			ttl = util.ActiveProfile.CacheTTL
+		}
+		if ttl != "" {
+			d, err := time.ParseDuration(ttl)
+			if err != nil {
+				util.Bail(fmt.Errorf("bad cache TTL '%s': %s", ttl, err))
+			}
+			util.UseResultCache(d, util.Args)
+		}
+	}
+
+	// The result of this run is cached, if --cache-ttl asked for that,
+	// anything that changed data gets written to the change journal, if the
+	// profile has one, and --profile-run gets its report
+	app.After = func() {
+		util.SaveResult()
+		util.FlushJournal()
+		util.FinishStats(nil)
+		util.PrintRunProfile()
 	}
 
 	return app
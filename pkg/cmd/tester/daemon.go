@@ -0,0 +1,191 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package tester
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// If the JWT expires within this window, log in again before starting a pass
+const reauthWindow = 1 * time.Hour
+
+type daemonState struct {
+	sync.Mutex
+
+	Started       time.Time `json:"started"`
+	Every         string    `json:"every"`
+	Destructive   bool      `json:"destructive"`
+	Passes        int       `json:"passes"`
+	Running       bool      `json:"running"`
+	LastStarted   time.Time `json:"last_started,omitempty"`
+	LastFinished  time.Time `json:"last_finished,omitempty"`
+	LastSuccess   time.Time `json:"last_success,omitempty"`
+	LastFailed    int       `json:"last_failed"`
+	LastError     string    `json:"last_error,omitempty"`
+	ConsecErrors  int       `json:"consecutive_errors"`
+	Logins        int       `json:"logins"`
+	JWTExpiration time.Time `json:"jwt_expires,omitempty"`
+}
+
+var state = &daemonState{}
+
+func init() {
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "daemon",
+		Short: "Run the tester continuously, on a schedule",
+		Long:  "Runs a test pass every --every interval, logging in again as needed. By default, the validations-only pass is used. Use --destructive to run the full report submission instead. The current state of the daemon is available as JSON at /health on --health_listen. The endpoint returns a 503 if the last pass errored out or if no pass has succeeded recently.",
+		Run:   daemon,
+	})
+}
+
+func daemon(cmd *cobra.Command, args []string) {
+	if matrixMode() {
+		log.Fatal("--targets is only supported by the run command")
+	}
+
+	every := getDuration("every")
+	if every <= 0 {
+		log.Fatal("--every must be greater than zero")
+	}
+
+	state.Lock()
+	state.Started = time.Now()
+	state.Every = every.String()
+	state.Destructive = viper.GetBool("destructive")
+	state.Logins = 1
+	state.JWTExpiration = API.JWT.Expires
+	state.Unlock()
+
+	if listen := viper.GetString("health_listen"); listen != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/health", healthHandler(every))
+
+		go func() {
+			log.Infof("serving health endpoint on %s", listen)
+			log.Fatal(http.ListenAndServe(listen, mux))
+		}()
+	}
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		daemonPass()
+		<-ticker.C
+	}
+}
+
+func daemonPass() {
+	state.Lock()
+	state.Running = true
+	state.LastStarted = time.Now()
+	state.Passes++
+	state.Unlock()
+
+	err := ensureLogin()
+	if err == nil {
+		err = runPass()
+	}
+
+	state.Lock()
+	defer state.Unlock()
+
+	state.Running = false
+	state.LastFinished = time.Now()
+	state.LastFailed = FailedCount
+	state.JWTExpiration = API.JWT.Expires
+
+	if err != nil {
+		log.Error(err)
+		state.LastError = err.Error()
+		state.ConsecErrors++
+		return
+	}
+
+	state.LastError = ""
+	state.ConsecErrors = 0
+	state.LastSuccess = state.LastFinished
+}
+
+func runPass() error {
+	if viper.GetBool("destructive") {
+		return destructivePass()
+	}
+	return nonbindingPass()
+}
+
+// ensureLogin logs in again if the current JWT is close to expiration or if
+// the API no longer accepts it. API tokens are left alone.
+func ensureLogin() error {
+	if API.Token != "" {
+		// API tokens do not expire and cannot be refreshed. If it was
+		// revoked, the pass will fail and say so.
+		return nil
+	}
+
+	if API.JWT.Expires.IsZero() || time.Until(API.JWT.Expires) < reauthWindow {
+		log.Info("JWT is near expiration. Logging in again")
+		return relogin()
+	}
+
+	// The login may have been revoked out from under us, despite a healthy
+	// looking expiration
+	if _, err := API.GetUserSettings(); err == conch.ErrNotAuthorized {
+		log.Warn("API rejected our credentials. Logging in again")
+		return relogin()
+	}
+
+	return nil
+}
+
+func relogin() error {
+	if err := login(API); err != nil {
+		return err
+	}
+
+	state.Lock()
+	state.Logins++
+	state.Unlock()
+
+	return nil
+}
+
+func healthHandler(every time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state.Lock()
+		defer state.Unlock()
+
+		healthy := state.LastError == ""
+
+		// Allow one full interval of slack before complaining that passes
+		// are not completing
+		if !state.LastSuccess.IsZero() {
+			if time.Since(state.LastSuccess) > 2*every {
+				healthy = false
+			}
+		} else if time.Since(state.Started) > 2*every {
+			healthy = false
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		if err := json.NewEncoder(w).Encode(state); err != nil {
+			log.Warn(err)
+		}
+	}
+}
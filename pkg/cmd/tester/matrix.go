@@ -0,0 +1,209 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package tester
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// target is a single API under test in matrix mode, along with the plan and
+// validation details as that API sees them
+type target struct {
+	URL          string
+	API          *conch.Conch
+	ServerPlanID uuid.UUID
+	SwitchPlanID uuid.UUID
+	Validations  map[uuid.UUID]conch.Validation
+}
+
+// matrixResult is the outcome of validating a single report against a single
+// target
+type matrixResult struct {
+	Status  string
+	Reasons []string
+}
+
+func (r matrixResult) String() string {
+	if len(r.Reasons) == 0 {
+		return r.Status
+	}
+	return fmt.Sprintf("%s (%d)", r.Status, len(r.Reasons))
+}
+
+func (r matrixResult) equal(o matrixResult) bool {
+	if r.Status != o.Status {
+		return false
+	}
+	return strings.Join(r.Reasons, "\n") == strings.Join(o.Reasons, "\n")
+}
+
+func buildTarget(url string) (*target, error) {
+	api, err := newAPI(url)
+	if err != nil {
+		return nil, err
+	}
+	t := &target{URL: url, API: api}
+
+	if err := login(t.API); err != nil {
+		return nil, err
+	}
+
+	t.ServerPlanID, t.SwitchPlanID, t.Validations, err = loadPlans(t.API)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", url, err)
+	}
+
+	return t, nil
+}
+
+// errorResult turns a failed request into a result. The target's URL is taken
+// out of the error so the same failure on two targets isn't seen as a
+// difference. The URL is still logged alongside the result.
+func (t *target) errorResult(err error) matrixResult {
+	msg := strings.Replace(err.Error(), strings.TrimRight(t.URL, "/"), "", -1)
+	return matrixResult{Status: "error", Reasons: []string{msg}}
+}
+
+func (t *target) validate(report Report) matrixResult {
+	if _, err := t.API.GetDevice(report.DeviceSerial); err != nil {
+		return t.errorResult(err)
+	}
+
+	planID := t.ServerPlanID
+	if report.ValidationPlanName == SwitchPlanName {
+		planID = t.SwitchPlanID
+	}
+
+	results, err := t.API.RunDeviceValidationPlan(
+		report.DeviceSerial,
+		planID,
+		report.Raw,
+	)
+	if err != nil {
+		return t.errorResult(err)
+	}
+
+	res := matrixResult{Status: "pass"}
+	for _, result := range results {
+		if result.Status == "pass" {
+			continue
+		}
+		res.Status = "fail"
+
+		validationName := "[unknown]"
+		if val, ok := t.Validations[result.ValidationID]; ok {
+			validationName = val.Name
+		}
+		res.Reasons = append(
+			res.Reasons,
+			fmt.Sprintf(
+				"%s : %s : %s -> %s",
+				validationName,
+				result.Category,
+				result.Status,
+				result.Message,
+			),
+		)
+	}
+	sort.Strings(res.Reasons)
+
+	return res
+}
+
+// matrixPass submits every report to each of the target APIs and reports
+// where their validation results differ
+func matrixPass(urls []string) error {
+	targets := make([]*target, 0)
+	for _, url := range urls {
+		t, err := buildTarget(url)
+		if err != nil {
+			return err
+		}
+
+		version, err := t.API.GetVersion()
+		if err != nil {
+			return fmt.Errorf("error retrieving API's version from %s: %s", url, err)
+		}
+		log.Info(fmt.Sprintf("Testing %s, API %s", url, version))
+
+		targets = append(targets, t)
+	}
+
+	reports, err := extractReports()
+	if err != nil {
+		return err
+	}
+
+	table := util.GetMarkdownTable()
+	header := []string{"Device", "Plan"}
+	header = append(header, urls...)
+	header = append(header, "Differs")
+	table.SetHeader(header)
+
+	differs := 0
+	for i, report := range reports {
+		log.Info(fmt.Sprintf("Processing entry %d of %d", i, len(reports)))
+
+		results := make([]matrixResult, len(targets))
+		for j, t := range targets {
+			results[j] = t.validate(report)
+		}
+
+		row := []string{report.DeviceSerial, report.ValidationPlanName}
+		diff := false
+		for _, res := range results {
+			row = append(row, res.String())
+			if !res.equal(results[0]) {
+				diff = true
+			}
+		}
+
+		if diff {
+			differs++
+			row = append(row, "*")
+
+			fields := log.Fields{
+				"device":               report.DeviceSerial,
+				"report_id":            report.ID,
+				"validation_plan_name": report.ValidationPlanName,
+			}
+			for j, res := range results {
+				fields[targets[j].URL] = strings.Join(
+					append([]string{res.Status}, res.Reasons...),
+					" || ",
+				)
+			}
+			log.WithFields(fields).Error("results differ between targets")
+		} else {
+			row = append(row, "")
+		}
+
+		table.Append(row)
+	}
+
+	table.Render()
+
+	msg := fmt.Sprintf(
+		"Submitted %d reports to %s (validations only). %d differed",
+		len(reports),
+		strings.Join(urls, ", "),
+		differs,
+	)
+
+	log.Info(msg)
+	sendToMM(util.NotifyPayload{
+		Text: msg,
+	})
+	return nil
+}
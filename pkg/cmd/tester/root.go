@@ -8,10 +8,13 @@ package tester
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/joyent/conch-shell/pkg/conch"
 	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/config"
 	"github.com/joyent/conch-shell/pkg/util"
+	homedir "github.com/mitchellh/go-homedir"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
@@ -56,9 +59,21 @@ The option list can be a bit intimidating but the app has a few different modes,
 
 * The API to test: --conch_api, --conch_user, --conch_password
 
+* The API to test, using an API token: --conch_api, --conch_token (or CONCH_TESTER_TOKEN)
+
+* The API to test, using a profile from the conch shell: --conch_profile, --conch_config
+
+* Compare multiple APIs, in run mode: --targets [2]
+
+* Hang detection: --timeout, --deadline
+
+* Daemon mode: --every, --health_listen, --destructive
+
 
 [1] All logs go to STDERR
 
+[2] The comparison table goes to STDOUT. The same credentials are used for each target.
+
 `,
 	}
 )
@@ -77,10 +92,17 @@ func Execute() {
 
 func init() {
 	initFlags()
-	buildAPI()
-	prepEnv()
+	loadProfile()
+
+	// In matrix mode, each target logs in and loads its own plans. The
+	// default API may not even be reachable.
+	if !matrixMode() {
+		buildAPI()
+		prepEnv()
+	}
 
 	UserAgent = fmt.Sprintf("conch %s-%s / API Tester", util.Version, util.GitRev)
+	util.UserAgent = UserAgent
 
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "version",
@@ -96,19 +118,117 @@ func init() {
 	})
 }
 
+// matrixMode is true when the reports are to be compared across --targets
+func matrixMode() bool {
+	return len(viper.GetStringSlice("targets")) > 0
+}
+
 func buildAPI() {
-	API = &conch.Conch{BaseURL: viper.GetString("conch_api")}
-	err := API.Login(
-		viper.GetString("conch_user"),
-		viper.GetString("conch_password"),
+	var err error
+	if API, err = newAPI(viper.GetString("conch_api")); err != nil {
+		log.Fatal(err)
+	}
+	if err := login(API); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newAPI builds an API object for url. Its HTTP client is built here, rather
+// than during the first request, so that every request, the login included,
+// is subject to --timeout.
+func newAPI(url string) (*conch.Conch, error) {
+	api, err := conch.New(
+		conch.WithBaseURL(url),
+		conch.WithUserAgent(UserAgent),
+		conch.WithTimeout(getDuration("timeout")),
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	api.Debug = viper.GetBool("debug")
+	api.Trace = viper.GetBool("trace")
+	api.NoCompression = viper.GetBool("no_compression")
+	api.CompressRequestsOver = viper.GetInt("compress_reports_over")
+
+	return api, nil
+}
+
+// login (re)authenticates an API object using the configured credentials.
+// If an API token is available, it is preferred over the user and password.
+func login(api *conch.Conch) error {
+	if token := viper.GetString("conch_token"); token != "" {
+		api.Token = token
+		if _, err := api.VerifyToken(); err != nil {
+			return fmt.Errorf("error verifying API token with %s : %s", api.BaseURL, err)
+		}
+	} else {
+		err := api.Login(
+			viper.GetString("conch_user"),
+			viper.GetString("conch_password"),
+		)
+
+		if err != nil {
+			return fmt.Errorf("error logging into %s : %s", api.BaseURL, err)
+		}
+	}
+
+	return nil
+}
+
+// loadProfile pulls the API URL and token out of a conch shell profile, if
+// one was requested
+func loadProfile() {
+	name := viper.GetString("conch_profile")
+	if name == "" {
+		return
+	}
+
+	path, err := homedir.Expand(viper.GetString("conch_config"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	cfg, err := config.NewFromJSONFile(path)
 	if err != nil {
-		log.Fatalf("error logging into %s : %s", viper.GetString("conch_api"), err)
+		log.Fatalf("error reading conch shell config '%s': %s", path, err)
 	}
-	API.Debug = viper.GetBool("debug")
-	API.Trace = viper.GetBool("trace")
 
+	var profile *config.ConchProfile
+	for _, prof := range cfg.Profiles {
+		if prof.Name == name {
+			profile = prof
+			break
+		}
+	}
+
+	if profile == nil {
+		log.Fatalf("could not find a profile named '%s' in '%s'", name, path)
+	}
+
+	if profile.BaseURL != "" {
+		viper.Set("conch_api", profile.BaseURL)
+	}
+
+	if viper.GetString("conch_token") == "" {
+		if profile.Token == "" {
+			log.Fatalf(
+				"profile '%s' does not have an API token. Please run 'conch profile upgrade'",
+				name,
+			)
+		}
+		viper.Set("conch_token", profile.Token.String())
+	}
+}
+
+// getDuration parses the named option as a Go duration string, exiting if it
+// is invalid
+func getDuration(name string) time.Duration {
+	d, err := time.ParseDuration(viper.GetString(name))
+	if err != nil {
+		log.Fatalf("could not parse --%s '%s': %s", name, viper.GetString(name), err)
+	}
+	return d
 }
 
 func initFlags() {
@@ -130,6 +250,24 @@ func initFlags() {
 		"Password for Conch API user",
 	)
 
+	flag.String(
+		"conch_token",
+		"",
+		"Conch API token. Used instead of conch_user and conch_password. Also read from CONCH_TESTER_TOKEN",
+	)
+
+	flag.String(
+		"conch_profile",
+		"",
+		"Use the API URL and token from this conch shell profile",
+	)
+
+	flag.String(
+		"conch_config",
+		config.DefaultConfigPath(),
+		"Path to the conch shell config, used with conch_profile",
+	)
+
 	flag.String(
 		"db_host",
 		"localhost",
@@ -166,6 +304,18 @@ func initFlags() {
 		"Trace mode. This is super loud",
 	)
 
+	flag.Bool(
+		"no_compression",
+		false,
+		"Ask the API for uncompressed responses",
+	)
+
+	flag.Int(
+		"compress_reports_over",
+		0,
+		"Gzip report submissions larger than this many bytes. The API must accept gzipped requests. 0 disables",
+	)
+
 	flag.Bool(
 		"verbose",
 		false,
@@ -214,6 +364,42 @@ func initFlags() {
 		"A directory full of device reports",
 	)
 
+	flag.StringSlice(
+		"targets",
+		[]string{},
+		"In run mode, submit the reports to each of these API URLs, instead of --conch_api, and compare the results side-by-side",
+	)
+
+	flag.String(
+		"timeout",
+		"2m",
+		"Give up on any single API request, including a report submission, after this long. Uses Go duration syntax. '0' disables the timeout",
+	)
+
+	flag.String(
+		"deadline",
+		"0",
+		"Stop a test pass after this long, counting any remaining reports as failures. Uses Go duration syntax. '0' disables the deadline",
+	)
+
+	flag.String(
+		"every",
+		"15m",
+		"In daemon mode, run a test pass this often. Uses Go duration syntax (eg '15m', '1h')",
+	)
+
+	flag.String(
+		"health_listen",
+		":8080",
+		"In daemon mode, serve a health endpoint at /health on this address. Empty disables the endpoint",
+	)
+
+	flag.Bool(
+		"destructive",
+		false,
+		"In daemon mode, run the destructive test pass rather than the validations-only pass",
+	)
+
 	viper.SetConfigName("conch_tester")
 	viper.AddConfigPath("/etc")
 	viper.AddConfigPath("/usr/local/etc")
@@ -222,6 +408,9 @@ func initFlags() {
 	viper.SetEnvPrefix("conch_tester")
 	viper.AutomaticEnv()
 
+	// The natural name of CONCH_TESTER_CONCH_TOKEN is a bit much
+	viper.BindEnv("conch_token", "CONCH_TESTER_TOKEN")
+
 	viper.BindPFlags(flag.CommandLine)
 	flag.Parse()
 
@@ -255,33 +444,51 @@ func initFlags() {
 }
 
 func prepEnv() {
-	// Find the IDs for the One True Plans
-	plans, err := API.GetValidationPlans()
+	var err error
+	ServerPlanID, SwitchPlanID, Validations, err = loadPlans(API)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadPlans finds the IDs for the One True Plans and builds a cache of
+// Validation names and details, as seen by the given API
+func loadPlans(api *conch.Conch) (
+	serverPlanID uuid.UUID,
+	switchPlanID uuid.UUID,
+	validations map[uuid.UUID]conch.Validation,
+	err error,
+) {
+	plans, err := api.GetValidationPlans()
 	if err != nil {
-		log.Fatalf("error getting validation plans: %s", err)
+		return serverPlanID, switchPlanID, validations,
+			fmt.Errorf("error getting validation plans: %s", err)
 	}
 	for _, plan := range plans {
 		if plan.Name == ServerPlanName {
-			ServerPlanID = plan.ID
+			serverPlanID = plan.ID
 		} else if plan.Name == SwitchPlanName {
-			SwitchPlanID = plan.ID
+			switchPlanID = plan.ID
 		}
 	}
-	if uuid.Equal(SwitchPlanID, uuid.UUID{}) {
-		log.Fatalf("failed to find validation plan '%s'", SwitchPlanName)
+	if uuid.Equal(switchPlanID, uuid.UUID{}) {
+		return serverPlanID, switchPlanID, validations,
+			fmt.Errorf("failed to find validation plan '%s'", SwitchPlanName)
 	}
 
-	if uuid.Equal(ServerPlanID, uuid.UUID{}) {
-		log.Fatalf("failed to find validation plan '%s'", ServerPlanName)
+	if uuid.Equal(serverPlanID, uuid.UUID{}) {
+		return serverPlanID, switchPlanID, validations,
+			fmt.Errorf("failed to find validation plan '%s'", ServerPlanName)
 	}
 
-	// Build a cache of Validation names and details
-	Validations = make(map[uuid.UUID]conch.Validation)
-	v, err := API.GetValidations()
+	validations = make(map[uuid.UUID]conch.Validation)
+	v, err := api.GetValidations()
 	if err != nil {
-		log.Fatalf("error getting list of validations: '%s'", err)
+		return serverPlanID, switchPlanID, validations,
+			fmt.Errorf("error getting list of validations: '%s'", err)
 	}
 	for _, validation := range v {
-		Validations[validation.ID] = validation
+		validations[validation.ID] = validation
 	}
+	return serverPlanID, switchPlanID, validations, nil
 }
@@ -16,8 +16,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/dghubble/sling"
 	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
 	_ "github.com/lib/pq"
 	homedir "github.com/mitchellh/go-homedir"
 	log "github.com/sirupsen/logrus"
@@ -43,26 +43,6 @@ type Report struct {
 
 type Reports []Report
 
-type mmField struct {
-	Short bool   `json:"short,omitempty"`
-	Title string `json:"title,omitempty"`
-	Value string `json:"value,omitempty"`
-}
-
-type mmAttachment struct {
-	Pretext  string    `json:"pretext,omitempty"`
-	Text     string    `json:"text,omitempty"`
-	Title    string    `json:"title,omitempty"`
-	Color    string    `json:"color,omitempty"`
-	Fallback string    `json:"fallback,omitempty"`
-	Fields   []mmField `json:"fields,omitempty"`
-}
-
-type mmPayload struct {
-	Text        string         `json:"text,omitempty"`
-	Attachments []mmAttachment `json:"attachments,omitempty"`
-}
-
 /************************/
 
 func init() {
@@ -77,7 +57,7 @@ func init() {
 		Use:     "run",
 		Aliases: []string{"test"},
 		Short:   "Run the tester, with supposedly no side effects",
-		Long:    "This submits the reports to the validation endpoints, running the validations in a stateless mode. No data will be written to the database and all the database munging code in the device report processing code will NOT be exercised. This also requires that the device exist already in the database and exist in a workspace that the user can see",
+		Long:    "This submits the reports to the validation endpoints, running the validations in a stateless mode. No data will be written to the database and all the database munging code in the device report processing code will NOT be exercised. This also requires that the device exist already in the database and exist in a workspace that the user can see. If --targets is provided, the reports are submitted to each target and the results are compared.",
 		Run:     nonbindingTest,
 	})
 
@@ -130,12 +110,12 @@ func failMe(r Report, destructive bool) {
 			strings.Join(r.Reasons, " || "),
 		)
 	}
-	payload := mmPayload{
-		Attachments: []mmAttachment{
+	payload := util.NotifyPayload{
+		Attachments: []util.NotifyAttachment{
 			{
 				Color:    "#FF0000",
 				Fallback: msg,
-				Fields: []mmField{
+				Fields: []util.NotifyField{
 					{
 						Title: "API Server",
 						Value: viper.GetString("conch_api"),
@@ -165,7 +145,7 @@ func failMe(r Report, destructive bool) {
 	if r.FileName != "" {
 		payload.Attachments[0].Fields = append(
 			payload.Attachments[0].Fields,
-			mmField{
+			util.NotifyField{
 				Title: "File Name",
 				Value: r.FileName,
 			},
@@ -173,7 +153,7 @@ func failMe(r Report, destructive bool) {
 	} else if !uuid.Equal(r.ID, uuid.UUID{}) {
 		payload.Attachments[0].Fields = append(
 			payload.Attachments[0].Fields,
-			mmField{
+			util.NotifyField{
 				Title: "Report ID",
 				Value: r.ID.String(),
 			},
@@ -184,9 +164,26 @@ func failMe(r Report, destructive bool) {
 }
 
 func nonbindingTest(cmd *cobra.Command, args []string) {
+	if matrixMode() {
+		if err := matrixPass(viper.GetStringSlice("targets")); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := nonbindingPass(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// nonbindingPass runs a single pass of the validations-only test. It can be
+// called repeatedly, as in daemon mode.
+func nonbindingPass() error {
+	FailedCount = 0
+
 	version, err := API.GetVersion()
 	if err != nil {
-		log.Fatalf("error retrieving API's version: %s", err)
+		return fmt.Errorf("error retrieving API's version: %s", err)
 	}
 	log.Info(fmt.Sprintf(
 		"Testing %s, API %s",
@@ -194,9 +191,18 @@ func nonbindingTest(cmd *cobra.Command, args []string) {
 		version,
 	))
 
-	reports := extractReports()
+	reports, err := extractReports()
+	if err != nil {
+		return err
+	}
+	deadline := passDeadline()
+	skipped := 0
 
 	for i, report := range reports {
+		if deadlineExceeded(deadline) {
+			skipped = skipRemaining(reports[i:], false)
+			break
+		}
 		log.Info(fmt.Sprintf("Processing entry %d of %d", i, len(reports)))
 
 		_, err := API.GetDevice(report.DeviceSerial)
@@ -248,23 +254,44 @@ func nonbindingTest(cmd *cobra.Command, args []string) {
 
 	msg := fmt.Sprintf(
 		"Submitted %d reports to %s (validations only). %d failed",
-		len(reports),
+		len(reports)-skipped,
 		viper.GetString("conch_api"),
 		FailedCount,
 	)
+	if skipped > 0 {
+		msg += fmt.Sprintf(
+			". Deadline of %s exceeded, %d reports were not submitted",
+			viper.GetString("deadline"),
+			skipped,
+		)
+	}
 
 	log.Info(msg)
-	sendToMM(mmPayload{
+	sendToMM(util.NotifyPayload{
 		Text: msg,
 	})
+	return nil
 }
 
 /************************/
 
 func destructiveTest(cmd *cobra.Command, args []string) {
+	if matrixMode() {
+		log.Fatal("--targets is only supported by the run command")
+	}
+	if err := destructivePass(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// destructivePass runs a single pass of the full report submission test. It
+// can be called repeatedly, as in daemon mode.
+func destructivePass() error {
+	FailedCount = 0
+
 	version, err := API.GetVersion()
 	if err != nil {
-		log.Fatalf("error retrieving API's version: %s", err)
+		return fmt.Errorf("error retrieving API's version: %s", err)
 	}
 	log.Info(fmt.Sprintf(
 		"Testing %s, API %s",
@@ -272,7 +299,10 @@ func destructiveTest(cmd *cobra.Command, args []string) {
 		version,
 	))
 
-	reports := extractReports()
+	reports, err := extractReports()
+	if err != nil {
+		return err
+	}
 
 	/**
 	*** Submit reports to the API
@@ -280,7 +310,14 @@ func destructiveTest(cmd *cobra.Command, args []string) {
 
 	log.Info("Submitting reports")
 
+	deadline := passDeadline()
+	skipped := 0
+
 	for i, report := range reports {
+		if deadlineExceeded(deadline) {
+			skipped = skipRemaining(reports[i:], true)
+			break
+		}
 		log.Info(fmt.Sprintf("Processing entry %d of %d", i, len(reports)))
 		report.Exists = true
 
@@ -339,40 +376,86 @@ func destructiveTest(cmd *cobra.Command, args []string) {
 
 	msg := fmt.Sprintf(
 		"Submitted %d reports to %s (full report process). %d failed",
-		len(reports),
+		len(reports)-skipped,
 		viper.GetString("conch_api"),
 		FailedCount,
 	)
+	if skipped > 0 {
+		msg += fmt.Sprintf(
+			". Deadline of %s exceeded, %d reports were not submitted",
+			viper.GetString("deadline"),
+			skipped,
+		)
+	}
 
 	log.Info(msg)
-	sendToMM(mmPayload{
+	sendToMM(util.NotifyPayload{
 		Text: msg,
 	})
+	return nil
+}
+
+// passDeadline returns the time by which a pass must finish, or the zero time
+// if there is no deadline
+func passDeadline() time.Time {
+	d := getDuration("deadline")
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+func deadlineExceeded(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
 }
 
-func extractReports() Reports {
+// skipRemaining records a failure for every report that could not be
+// submitted before the deadline and returns the number of reports skipped
+func skipRemaining(reports Reports, destructive bool) int {
+	for _, report := range reports {
+		report.Exists = true
+		report.Reasons = append(
+			report.Reasons,
+			fmt.Sprintf(
+				"not submitted: deadline of %s exceeded",
+				viper.GetString("deadline"),
+			),
+		)
+		failMe(report, destructive)
+	}
+	return len(reports)
+}
+
+// extractReports gathers the reports for a pass. Errors are returned rather
+// than fatal so that the daemon can try again on its next pass.
+func extractReports() (Reports, error) {
 	if viper.GetBool("from_directory") {
 		return extractReportsFromDirectory()
-	} else {
-		return extractReportsFromDB()
 	}
+	return extractReportsFromDB()
 }
 
-func extractReportsFromDirectory() Reports {
+func extractReportsFromDirectory() (Reports, error) {
 	log.Debug("Looking for reports in " + viper.GetString("data_directory"))
 
 	expandedPath, err := homedir.Expand(viper.GetString("data_directory"))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	// Glob only fails on a bad pattern, so an unreadable directory has to
+	// be caught here
+	if _, err := ioutil.ReadDir(expandedPath); err != nil {
+		return nil, fmt.Errorf("error reading reports: %s", err)
 	}
 
 	jsonFiles, err := filepath.Glob(fmt.Sprintf("%s/*.json", expandedPath))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	if len(jsonFiles) == 0 {
-		log.Fatalf("no device reports found in %s", expandedPath)
+		return nil, fmt.Errorf("no device reports found in %s", expandedPath)
 	}
 
 	log.Debug(fmt.Sprintf("Found %d reports", len(jsonFiles)))
@@ -383,6 +466,7 @@ func extractReportsFromDirectory() Reports {
 		jsonBytes, err := ioutil.ReadFile(j)
 		if err != nil {
 			log.Warn(err)
+			continue
 		}
 
 		report := Report{
@@ -402,26 +486,25 @@ func extractReportsFromDirectory() Reports {
 		}
 
 		if val, ok := report.Parsed["device_type"]; ok {
-			deviceType := val.(string)
-			if deviceType == "switch" {
+			if deviceType, _ := val.(string); deviceType == "switch" {
 				report.ValidationPlanID = SwitchPlanID
 				report.ValidationPlanName = SwitchPlanName
 			}
 		}
 
 		if val, ok := report.Parsed["serial_number"]; ok {
-			report.DeviceSerial = val.(string)
+			report.DeviceSerial, _ = val.(string)
 		}
 
 		reports = append(reports, report)
 	}
-	return reports
+	return reports, nil
 }
 
 /**
 *** Grab reports from the database
 **/
-func extractReportsFromDB() Reports {
+func extractReportsFromDB() (Reports, error) {
 	log.Debug("Attempting database connection")
 	connStr := fmt.Sprintf(
 		"user=%s password=%s host=%s dbname=%s sslmode=disable",
@@ -433,13 +516,13 @@ func extractReportsFromDB() Reports {
 
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("error connecting to the database: %s", err)
 	}
+	defer db.Close()
 
 	if err := db.Ping(); err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("error connecting to the database: %s", err)
 	}
-	defer db.Close()
 
 	log.Debug("Database connection was successful")
 
@@ -472,7 +555,7 @@ func extractReportsFromDB() Reports {
 
 	rows, err := db.Query(sql)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("error querying for reports: %s", err)
 	}
 	defer rows.Close()
 
@@ -485,7 +568,7 @@ func extractReportsFromDB() Reports {
 		}
 
 		if err := rows.Scan(&report.DeviceSerial, &report.ID, &report.Completed, &report.Raw); err != nil {
-			log.Fatal(err)
+			return nil, fmt.Errorf("error reading reports: %s", err)
 		}
 
 		if err := json.Unmarshal([]byte(report.Raw), &report.Parsed); err != nil {
@@ -498,8 +581,7 @@ func extractReportsFromDB() Reports {
 		}
 
 		if val, ok := report.Parsed["device_type"]; ok {
-			deviceType := val.(string)
-			if deviceType == "switch" {
+			if deviceType, _ := val.(string); deviceType == "switch" {
 				report.ValidationPlanID = SwitchPlanID
 				report.ValidationPlanName = SwitchPlanName
 			}
@@ -507,6 +589,9 @@ func extractReportsFromDB() Reports {
 
 		reports = append(reports, report)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading reports: %s", err)
+	}
 	rows.Close()
 
 	log.Info(fmt.Sprintf("Found %d device reports to submit", len(reports)))
@@ -514,20 +599,14 @@ func extractReportsFromDB() Reports {
 	log.Debug("Closing database connection")
 	db.Close()
 
-	return reports
+	return reports, nil
 }
 
-func sendToMM(payload mmPayload) {
+func sendToMM(payload util.NotifyPayload) {
 	if !viper.GetBool("mattermost") {
 		return
 	}
-	_, err := sling.New().Set("User-Agent", UserAgent).
-		Post(viper.GetString("mattermost_webhook")).
-		BodyJSON(payload).
-		ReceiveSuccess(nil)
-
-	if err != nil {
+	if err := util.SendNotification(viper.GetString("mattermost_webhook"), payload); err != nil {
 		log.Warn(err)
-		return
 	}
 }
@@ -0,0 +1,225 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package admin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// The kinds of duplicate 'admin audit-duplicates' looks for
+const (
+	duplicateAssetTag = "asset tag"
+	duplicateMAC      = "MAC address"
+	duplicateRackName = "rack name"
+)
+
+// duplicate is a value that more than one object has, when only one should
+type duplicate struct {
+	Kind    string   `json:"kind"`
+	Value   string   `json:"value"`
+	Where   string   `json:"where,omitempty"`
+	Objects []string `json:"objects"`
+}
+
+// duplicateFinder collects the objects that have each value, going by a
+// normalized form of the value so that eg 'ab:cd' and 'AB-CD' count as one
+type duplicateFinder struct {
+	kind      string
+	normalize func(string) string
+	values    map[string]string
+	objects   map[string][]string
+}
+
+func newDuplicateFinder(kind string, normalize func(string) string) *duplicateFinder {
+	return &duplicateFinder{
+		kind:      kind,
+		normalize: normalize,
+		values:    make(map[string]string),
+		objects:   make(map[string][]string),
+	}
+}
+
+// add records that object has value. Empty values are never duplicates.
+func (f *duplicateFinder) add(value string, object string) {
+	key := f.normalize(value)
+	if key == "" {
+		return
+	}
+	if _, ok := f.values[key]; !ok {
+		f.values[key] = strings.TrimSpace(value)
+	}
+	for _, o := range f.objects[key] {
+		if o == object {
+			return
+		}
+	}
+	f.objects[key] = append(f.objects[key], object)
+}
+
+// duplicates lists the values that more than one object has
+func (f *duplicateFinder) duplicates() []duplicate {
+	found := make([]duplicate, 0)
+	for key, objects := range f.objects {
+		if len(objects) < 2 {
+			continue
+		}
+		sort.Strings(objects)
+		found = append(found, duplicate{
+			Kind:    f.kind,
+			Value:   f.values[key],
+			Objects: objects,
+		})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Value < found[j].Value })
+	return found
+}
+
+// normalizeValue compares values without regard to case or surrounding space
+func normalizeValue(v string) string {
+	return strings.ToLower(strings.TrimSpace(v))
+}
+
+// normalizeMAC also ignores the separators between the octets of a MAC
+// address
+func normalizeMAC(mac string) string {
+	return strings.NewReplacer(":", "", "-", "", ".", "").Replace(
+		strings.ToLower(strings.TrimSpace(mac)),
+	)
+}
+
+// findRackNameDuplicates looks for racks with the same name in the same room
+func findRackNameDuplicates(racks []conch.Rack, rooms []conch.Room) []duplicate {
+	aliases := make(map[uuid.UUID]string)
+	for _, r := range rooms {
+		aliases[r.ID] = r.Alias
+	}
+
+	byRoom := make(map[uuid.UUID]*duplicateFinder)
+	for _, r := range racks {
+		f, ok := byRoom[r.DatacenterRoomID]
+		if !ok {
+			f = newDuplicateFinder(duplicateRackName, normalizeValue)
+			byRoom[r.DatacenterRoomID] = f
+		}
+		f.add(r.Name, r.ID.String())
+	}
+
+	found := make([]duplicate, 0)
+	for room, f := range byRoom {
+		where := "room " + room.String()
+		if alias := aliases[room]; alias != "" {
+			where = fmt.Sprintf("room %s (%s)", alias, room)
+		}
+		for _, d := range f.duplicates() {
+			d.Where = where
+			found = append(found, d)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Where != found[j].Where {
+			return found[i].Where < found[j].Where
+		}
+		return found[i].Value < found[j].Value
+	})
+	return found
+}
+
+func auditDuplicates(app *cli.Cmd) {
+	var skipMACsOpt = app.BoolOpt("skip-macs", false, "Don't look for duplicate MAC addresses, which takes a request for every device")
+
+	app.LongDesc = `
+Looks through the whole of Conch for values that should be unique but
+aren't, and which trip up whatever reads the inventory downstream:
+
+  - asset tags shared by more than one device
+  - MAC addresses shared by more than one device, going by the NICs in each
+    device's latest report
+  - racks with the same name in the same room
+
+Values are compared without regard to case or surrounding space, and MAC
+addresses without regard to separators. Nothing is changed. The command exits
+non-zero when it finds any duplicates.`
+
+	app.Action = func() {
+		devices, err := rootWorkspaceDevices([]string{"id", "asset_tag"})
+		if err != nil {
+			util.Bail(err)
+		}
+
+		list := make([]conch.Device, 0, len(devices))
+		for _, d := range devices {
+			list = append(list, d)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+		found := make([]duplicate, 0)
+
+		tags := newDuplicateFinder(duplicateAssetTag, normalizeValue)
+		for _, d := range list {
+			tags.add(d.AssetTag, d.ID)
+		}
+		found = append(found, tags.duplicates()...)
+
+		if !*skipMACsOpt {
+			// The device listings don't include NICs
+			full, err := util.FillDeviceDetails(list)
+			if err != nil {
+				util.Bail(err)
+			}
+			macs := newDuplicateFinder(duplicateMAC, normalizeMAC)
+			for _, d := range full {
+				for _, nic := range d.Nics {
+					macs.add(nic.MAC, d.ID)
+				}
+			}
+			found = append(found, macs.duplicates()...)
+		}
+
+		rooms, err := util.API.GetRooms()
+		if err != nil {
+			util.Bail(err)
+		}
+		racks, err := util.API.GetRacks()
+		if err != nil {
+			util.Bail(err)
+		}
+		found = append(found, findRackNameDuplicates(racks, rooms)...)
+
+		if util.JSON {
+			util.JSONOut(found)
+		} else if len(found) == 0 {
+			fmt.Println("No duplicates found")
+		} else {
+			header := []string{"Kind", "Value", "Where", "Count", "Objects"}
+			row := func(i int) []string {
+				d := found[i]
+				return []string{
+					d.Kind,
+					d.Value,
+					d.Where,
+					strconv.Itoa(len(d.Objects)),
+					strings.Join(d.Objects, ", "),
+				}
+			}
+			if err := util.RenderTable(util.GetMarkdownTable(), header, len(found), row, "Kind"); err != nil {
+				util.Bail(err)
+			}
+		}
+
+		if len(found) > 0 {
+			util.Exit(1)
+		}
+	}
+}
@@ -20,23 +20,61 @@ var UserEmail string
 
 // Init loads up the commands
 func Init(app *cli.Cli) {
-	if util.NoAdmin() {
-		return
-	}
+	registerOutputs()
 
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"admin",
 		"Commands for various server-side administrative tasks",
 		func(cmd *cli.Cmd) {
-			cmd.Before = util.BuildAPIAndVerifyLogin
+			cmd.Before = func() {
+				util.BuildAPIAndVerifyLogin()
+				util.RequireSystemAdmin("The admin commands")
+			}
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"users",
 				"List all users",
-				listAllUsers,
+				func(cmd *cli.Cmd) {
+					cmd.Before = func() { util.RequireFeature("user-admin") }
+					listAllUsers(cmd)
+				},
+			)
+
+			util.Command(
+				cmd,
+				"audit-orphans",
+				"List devices, slots, racks, and rooms that have lost what they belong to, as a cleanup worklist",
+				func(cmd *cli.Cmd) {
+					cmd.Before = func() { util.RequireFeature("rack-layouts") }
+					auditOrphans(cmd)
+				},
+			)
+
+			util.Command(
+				cmd,
+				"audit-duplicates",
+				"List asset tags, MAC addresses, and rack names that more than one device or rack has",
+				auditDuplicates,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
+				"device",
+				"Administrative commands for operating on devices",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"merge",
+						"Fold a device that was registered twice, under two serials, into one record",
+						mergeDevices,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
 				"user",
 				"Administrative commands for operating on a user",
 				func(cmd *cli.Cmd) {
@@ -50,6 +88,8 @@ func Init(app *cli.Cli) {
 					cmd.Spec = "USER"
 
 					cmd.Before = func() {
+						util.RequireFeature("user-admin")
+
 						address, err := mail.ParseAddress(*userIDStr)
 						if err != nil {
 							util.Bail(err)
@@ -57,71 +97,127 @@ func Init(app *cli.Cli) {
 						UserEmail = address.Address
 					}
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get",
 						"Get the basic info about a user",
 						getUser,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"revoke",
 						"Revoke the api tokens and/or logins for a given user",
 						revokeTokens,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"delete rm",
 						"Delete a user from conch. This *cannot* be undone",
 						deleteUser,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"create",
 						"Create a new user. Does *not* assign them to a workspace",
 						createUser,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"reset",
 						"Reset the password for the user",
 						resetUserPassword,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"update",
 						"Update properties of the user",
 						updateUser,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"promote",
 						"Promote the user to system admin",
 						promoteUser,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"demote",
 						"Demote the user to a regular user",
 						demoteUser,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
+						"settings",
+						"Inspect and fix a user's settings, without logging in as them",
+						func(cmd *cli.Cmd) {
+							util.Command(
+								cmd,
+								"get",
+								"Get all of a user's settings, or a single one",
+								getUserSettings,
+							)
+
+							util.Command(
+								cmd,
+								"set",
+								"Set one of a user's settings",
+								setUserSetting,
+							)
+
+							util.Command(
+								cmd,
+								"delete rm",
+								"Delete one of a user's settings",
+								deleteUserSetting,
+							)
+						},
+					)
+
+					util.Command(
+						cmd,
+						"sessions",
+						"List a user's login sessions and API tokens",
+						func(cmd *cli.Cmd) {
+							listSessions(cmd)
+
+							util.Command(
+								cmd,
+								"revoke",
+								"End a single login session or API token of a user",
+								revokeSession,
+							)
+						},
+					)
+
+					util.Command(
+						cmd,
 						"tokens",
 						"List the API tokens for a user",
 						listTokens,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"token",
 						"Operate on a user's API tokens",
 						func(cmd *cli.Cmd) {
-							cmd.Command(
+							util.Command(
+								cmd,
 								"get",
 								"Get a user's API token",
 								getToken,
 							)
 
-							cmd.Command(
+							util.Command(
+								cmd,
 								"delete rm",
 								"Delete a user's API token",
 								removeToken,
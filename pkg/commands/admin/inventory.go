@@ -0,0 +1,38 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package admin
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// rootWorkspaceDevices lists the devices of the workspaces at the top of the
+// tree, which between them hold every device in a rack, keyed by ID. Only
+// the named fields are asked for.
+func rootWorkspaceDevices(fields []string) (map[string]conch.Device, error) {
+	found := make(map[string]conch.Device)
+
+	workspaces, err := util.API.GetWorkspaces()
+	if err != nil {
+		return found, err
+	}
+	for _, ws := range workspaces {
+		if !uuid.Equal(ws.ParentID, uuid.UUID{}) {
+			continue
+		}
+		devices, err := util.API.GetWorkspaceDevicesFields(ws.ID, fields, "", "", "")
+		if err != nil {
+			return found, err
+		}
+		for _, d := range devices {
+			found[d.ID] = d
+		}
+	}
+	return found, nil
+}
@@ -20,28 +20,25 @@ import (
 )
 
 func listAllUsers(app *cli.Cmd) {
+	sorting := util.SortFlags(app, "users")
+
 	app.Action = func() {
 		users, err := util.API.GetAllUsers()
 		if err != nil {
 			util.Bail(err)
 		}
-		if util.JSON {
-			util.JSONOut(users)
-			return
-		}
-
-		sort.Sort(users)
 
-		table := util.GetMarkdownTable()
-		table.SetHeader([]string{
+		header := []string{
 			"ID",
 			"Name",
 			"Email",
 			"Created",
 			"Last Login",
 			"Is Admin",
-		})
-		for _, u := range users {
+		}
+		row := func(i int) []string {
+			u := users[i]
+
 			var last string
 			if u.LastLogin.IsZero() {
 				last = ""
@@ -54,16 +51,32 @@ func listAllUsers(app *cli.Cmd) {
 				isAdmin = "X"
 			}
 
-			table.Append([]string{
+			return []string{
 				u.ID.String(),
 				u.Name,
 				u.Email,
 				util.TimeStr(u.Created),
 				last,
 				isAdmin,
-			})
+			}
+		}
+
+		if util.JSON {
+			if err := sorting.Sort(users, header, row); err != nil {
+				util.Bail(err)
+			}
+			util.JSONOut(users)
+			return
+		}
+
+		sort.Sort(users)
+		if err := sorting.Sort(users, header, row); err != nil {
+			util.Bail(err)
+		}
+
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(users), row); err != nil {
+			util.Bail(err)
 		}
-		table.Render()
 	}
 }
 
@@ -73,8 +86,10 @@ func revokeTokens(app *cli.Cmd) {
 		revokeAuth = app.BoolOpt("auth-only", false, "Revoke auth tokens, not API tokens. This will force a user to log in again on the website (and old versions of the shell)")
 		tokenAuth  = app.BoolOpt("tokens-only", false, "Revoke all API tokens. This will likely break a lot of automation so use this carefully")
 		allAuth    = app.BoolOpt("all", false, "The nuclear option. Revoke all auth *and* API tokens, forcing the user to login again *and* to generate new API tokens for automation processes. Use this very carefully")
+
+		breakGlassOpts = util.NewBreakGlassOpts(app)
 	)
-	app.Spec = "--force (--auth-only | --tokens-only | --all)"
+	app.Spec = "--force (--auth-only | --tokens-only | --all) [--confirm]"
 
 	app.Action = func() {
 		if !*forceOpt {
@@ -82,6 +97,12 @@ func revokeTokens(app *cli.Cmd) {
 		}
 
 		if *allAuth {
+			util.BreakGlass(
+				breakGlassOpts,
+				"revoke every login and API token of "+UserEmail,
+				UserEmail,
+			)
+
 			if err := util.API.RevokeUserTokensAndLogins(UserEmail); err != nil {
 				util.Bail(err)
 			}
@@ -117,36 +138,45 @@ func revokeTokens(app *cli.Cmd) {
 
 func listTokens(app *cli.Cmd) {
 	app.Before = util.BuildAPIAndVerifyLogin
+	sorting := util.SortFlags(app, "tokens")
 
 	app.Action = func() {
 		tokens, err := util.API.GetUserTokens(UserEmail)
 		if err != nil {
 			util.Bail(err)
 		}
-		if util.JSON {
-			util.JSONOut(tokens)
-			return
-		}
-
-		sort.Sort(tokens)
-
-		table := util.GetMarkdownTable()
-		table.SetHeader([]string{"Name", "Created", "Last Used"})
 
-		for _, t := range tokens {
+		header := []string{"Name", "Created", "Last Used"}
+		row := func(i int) []string {
+			t := tokens[i]
 			timeStr := ""
 			if !t.LastUsed.IsZero() {
 				timeStr = util.TimeStr(t.LastUsed)
 			}
 
-			table.Append([]string{
+			return []string{
 				t.Name,
 				util.TimeStr(t.Created),
 				timeStr,
-			})
+			}
 		}
 
-		table.Render()
+		if util.JSON {
+			if err := sorting.Sort(tokens, header, row); err != nil {
+				util.Bail(err)
+			}
+			util.JSONOut(tokens)
+			return
+		}
+
+		sort.Sort(tokens)
+		if err := sorting.Sort(tokens, header, row); err != nil {
+			util.Bail(err)
+		}
+
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(tokens), row); err != nil {
+			util.Bail(err)
+		}
 	}
 }
 
@@ -202,6 +232,7 @@ func deleteUser(app *cli.Cmd) {
 	var (
 		forceOpt       = app.BoolOpt("force", false, "Perform destructive actions")
 		clearTokensOpt = app.BoolOpt("clear-tokens", false, "Purge the user's API tokens")
+		breakGlassOpts = util.NewBreakGlassOpts(app)
 	)
 	app.Spec = "--force [OPTIONS]"
 
@@ -210,6 +241,8 @@ func deleteUser(app *cli.Cmd) {
 			return
 		}
 
+		util.BreakGlass(breakGlassOpts, "delete user "+UserEmail, UserEmail)
+
 		if err := util.API.DeleteUser(UserEmail, *clearTokensOpt); err != nil {
 			util.Bail(err)
 		}
@@ -365,9 +398,11 @@ func updateUser(app *cli.Cmd) {
 			util.Bail(err)
 		}
 
-		if !util.JSON {
-			fmt.Println("User " + UserEmail + " updated")
+		after, err := util.API.GetUser(user.ID)
+		if err != nil {
+			util.Bail(err)
 		}
+		util.DisplayFieldDiff("user "+after.Email, util.FieldDiff(user, after))
 	}
 }
 
@@ -0,0 +1,242 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// mergedDevice is what 'admin device merge' needs to know about each of the
+// two records
+type mergedDevice struct {
+	conch.Device
+	location conch.DeviceLocation
+	settings map[string]string
+	tags     map[string]string
+}
+
+func (d mergedDevice) inRack() bool {
+	return !uuid.Equal(d.location.Rack.ID, uuid.UUID{})
+}
+
+func fetchMergedDevice(serial string) (mergedDevice, error) {
+	var (
+		m   mergedDevice
+		err error
+	)
+
+	m.Device, err = util.API.GetDevice(serial)
+	if err == conch.ErrDataNotFound {
+		return m, fmt.Errorf("device %s does not exist", serial)
+	}
+	if err != nil {
+		return m, err
+	}
+
+	m.location, err = util.API.GetDeviceLocation(serial)
+	if err != nil && err != conch.ErrDataNotFound {
+		return m, err
+	}
+
+	if m.settings, err = util.API.GetDeviceSettings(serial); err != nil {
+		return m, err
+	}
+	if m.tags, err = util.API.GetDeviceTags(serial); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// sortedKeys lists the keys of a map of settings or tags in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// planMerge works out the changes that fold old into keep. Settings and tags
+// that both records have, with different values, are left as keep has them
+// unless overwrite is set; they come back as skipped.
+func planMerge(old mergedDevice, keep mergedDevice, overwrite bool, keepOld bool) (*util.Plan, []string, error) {
+	plan := util.NewPlan()
+	skipped := make([]string, 0)
+
+	if old.inRack() && keep.inRack() {
+		return nil, nil, fmt.Errorf(
+			"both records are in a rack: %s in rack %s, RU %d, and %s in rack %s, RU %d. Take the one that is wrong out of its rack first",
+			old.ID,
+			old.location.Rack.Name,
+			old.location.RackUnitStart,
+			keep.ID,
+			keep.location.Rack.Name,
+			keep.location.RackUnitStart,
+		)
+	}
+
+	assetTag := keep.AssetTag
+	if assetTag == "" {
+		assetTag = old.AssetTag
+	}
+
+	if old.inRack() {
+		rackID := old.location.Rack.ID
+		ru := old.location.RackUnitStart
+		where := fmt.Sprintf("rack %s, RU %d", old.location.Rack.Name, ru)
+
+		plan.Add(util.PlanDelete, "assignment", old.ID, where, func() error {
+			return util.API.DeleteDevicesFromRackSlots(
+				rackID,
+				conch.RequestRackAssignmentDeletes{{DeviceID: old.ID, RackUnitStart: ru}},
+			)
+		})
+		plan.Add(util.PlanCreate, "assignment", keep.ID, fmt.Sprintf("%s, asset tag '%s'", where, assetTag), func() error {
+			return util.API.AssignDevicesToRackSlots(
+				rackID,
+				conch.RequestRackAssignmentUpdates{{
+					DeviceID:       keep.ID,
+					RackUnitStart:  ru,
+					DeviceAssetTag: assetTag,
+				}},
+			)
+		})
+	} else if assetTag != keep.AssetTag {
+		plan.Add(util.PlanUpdate, "asset tag", keep.ID, fmt.Sprintf("'%s', from %s", assetTag, old.ID), func() error {
+			return util.API.SetDeviceAssetTag(keep.ID, assetTag)
+		})
+	}
+
+	copyValues := func(kind string, from map[string]string, to map[string]string, set func(string, string) error) {
+		for _, k := range sortedKeys(from) {
+			k := k
+			v := from[k]
+
+			// The merge's own bookkeeping is written below
+			if k == conch.MergedIntoSetting || k == conch.MergedFromSetting {
+				continue
+			}
+
+			action := util.PlanCreate
+			if was, ok := to[k]; ok {
+				if was == v {
+					continue
+				}
+				if !overwrite {
+					skipped = append(skipped, fmt.Sprintf("%s %s: %s has '%s', %s has '%s'", kind, k, old.ID, v, keep.ID, was))
+					continue
+				}
+				action = util.PlanUpdate
+			}
+			plan.Add(action, kind, k, fmt.Sprintf("'%s' on %s", v, keep.ID), func() error {
+				return set(k, v)
+			})
+		}
+	}
+	copyValues("setting", old.settings, keep.settings, func(k string, v string) error {
+		return util.API.SetDeviceSetting(keep.ID, k, v)
+	})
+	copyValues("tag", old.tags, keep.tags, func(k string, v string) error {
+		return util.API.SetDeviceTag(keep.ID, k, v)
+	})
+
+	mergedFrom := []string{old.ID}
+	if was := keep.settings[conch.MergedFromSetting]; was != "" {
+		mergedFrom = append(strings.Split(was, ","), old.ID)
+	}
+	if was := old.settings[conch.MergedFromSetting]; was != "" {
+		mergedFrom = append(mergedFrom, strings.Split(was, ",")...)
+	}
+	merged := strings.Join(mergedFrom, ",")
+	plan.Add(util.PlanUpdate, "setting", conch.MergedFromSetting, fmt.Sprintf("'%s' on %s", merged, keep.ID), func() error {
+		return util.API.SetDeviceSetting(keep.ID, conch.MergedFromSetting, merged)
+	})
+	plan.Add(util.PlanUpdate, "setting", conch.MergedIntoSetting, fmt.Sprintf("'%s' on %s", keep.ID, old.ID), func() error {
+		return util.API.SetDeviceSetting(old.ID, conch.MergedIntoSetting, keep.ID)
+	})
+
+	if !keepOld && old.Phase != conch.DecommissionedPhase {
+		plan.Add(util.PlanUpdate, "phase", old.ID, conch.DecommissionedPhase, func() error {
+			return util.API.SetDevicePhase(old.ID, conch.DecommissionedPhase)
+		})
+	}
+
+	return plan, skipped, nil
+}
+
+func mergeDevices(cmd *cli.Cmd) {
+	var (
+		oldArg       = cmd.StringArg("OLD", "", "The serial of the record to fold into the other")
+		newArg       = cmd.StringArg("NEW", "", "The serial of the record to keep")
+		overwriteOpt = cmd.BoolOpt("overwrite", false, "Where both records have a setting or tag, take the old record's value")
+		keepOldOpt   = cmd.BoolOpt("keep-old", false, "Don't move the old record to the 'decommissioned' phase")
+		planOpts     = util.NewPlanOpts(cmd)
+	)
+	cmd.Spec = "[OPTIONS] OLD NEW"
+
+	cmd.LongDesc = `
+Folds a device that was registered twice, under two serials, into the record
+that is to be kept. As much as the API allows is moved over to NEW:
+
+  - the rack slot of OLD, if NEW isn't in a rack already, along with OLD's
+    asset tag if NEW has none
+  - the settings and tags of OLD that NEW doesn't have. Where both have a
+    value, NEW's is kept unless --overwrite is given
+
+OLD is then marked with a merged_into setting naming NEW, NEW's merged_from
+setting lists every serial merged into it, and OLD is moved to the
+'decommissioned' phase.
+
+The API has no way to move device reports or validation results, so those
+stay with OLD, where merged_into leads to them. Neither can a record be
+deleted from the shell.
+
+The changes are listed before they are made. Settings and tags that were
+left as NEW had them are listed as well.`
+
+	cmd.Action = func() {
+		if *oldArg == *newArg {
+			util.Bail(errors.New("a device can't be merged into itself"))
+		}
+
+		old, err := fetchMergedDevice(*oldArg)
+		if err != nil {
+			util.Bail(err)
+		}
+		keep, err := fetchMergedDevice(*newArg)
+		if err != nil {
+			util.Bail(err)
+		}
+		if into := old.settings[conch.MergedIntoSetting]; into != "" {
+			util.Bail(fmt.Errorf("%s was already merged into %s", old.ID, into))
+		}
+
+		plan, skipped, err := planMerge(old, keep, *overwriteOpt, *keepOldOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if len(skipped) > 0 && !util.JSON {
+			fmt.Printf("Left as %s has them. Use --overwrite to take %s's values:\n", keep.ID, old.ID)
+			for _, s := range skipped {
+				fmt.Printf("  - %s\n", s)
+			}
+			fmt.Println()
+		}
+
+		plan.Run(planOpts)
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package admin
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// The kinds of orphan 'admin audit-orphans' looks for, in the order they are
+// listed
+const (
+	orphanUnlocatedDevice    = "device without location"
+	orphanMissingDevice      = "assignment to missing device"
+	orphanDeactivatedDevice  = "assignment to deactivated device"
+	orphanDeletedProduct     = "slot with deleted product"
+	orphanDeactivatedProduct = "slot with deactivated product"
+	orphanSlotWithoutRack    = "slot in missing rack"
+	orphanRackWithoutRoom    = "rack in missing room"
+	orphanEmptyRoom          = "room without racks"
+)
+
+var orphanKinds = []string{
+	orphanUnlocatedDevice,
+	orphanMissingDevice,
+	orphanDeactivatedDevice,
+	orphanDeletedProduct,
+	orphanDeactivatedProduct,
+	orphanSlotWithoutRack,
+	orphanRackWithoutRoom,
+	orphanEmptyRoom,
+}
+
+// orphan is one entry in the cleanup worklist
+type orphan struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix"`
+}
+
+// orphanAudit gathers what the audit needs from the API
+type orphanAudit struct {
+	rooms    []conch.Room
+	racks    []conch.Rack
+	slots    conch.RackLayoutSlots
+	products map[uuid.UUID]conch.HardwareProduct
+
+	// assigned maps the device in each occupied slot to the rack it is in
+	assigned map[string]conch.Rack
+
+	// devices are the devices in the root workspaces, keyed by ID
+	devices map[string]conch.Device
+}
+
+func fetchOrphanAudit() (orphanAudit, error) {
+	a := orphanAudit{
+		products: make(map[uuid.UUID]conch.HardwareProduct),
+		assigned: make(map[string]conch.Rack),
+	}
+
+	var err error
+	if a.rooms, err = util.API.GetRooms(); err != nil {
+		return a, err
+	}
+	if a.racks, err = util.API.GetRacks(); err != nil {
+		return a, err
+	}
+	// One request for every slot is far cheaper than one per rack
+	if a.slots, err = util.API.GetRackLayoutSlots(); err != nil {
+		return a, err
+	}
+
+	products, err := util.API.GetAllHardwareProducts()
+	if err != nil {
+		return a, err
+	}
+	for _, p := range products {
+		a.products[p.ID] = p
+	}
+
+	a.devices, err = rootWorkspaceDevices([]string{"id", "rack_id", "deactivated"})
+	if err != nil {
+		return a, err
+	}
+
+	var mu sync.Mutex
+	err = util.Each(
+		len(a.racks),
+		fmt.Sprintf("Fetching the assignments of %d racks", len(a.racks)),
+		func(i int) error {
+			assignments, err := util.API.GetRackAssignments(a.racks[i].ID)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, as := range assignments {
+				if as.DeviceID != "" {
+					a.assigned[as.DeviceID] = a.racks[i]
+				}
+			}
+			return nil
+		},
+	)
+	return a, err
+}
+
+// checkAssignedDevices looks up the assigned devices that the workspaces
+// didn't list, which are the ones that might be gone
+func (a orphanAudit) checkAssignedDevices() ([]orphan, error) {
+	unlisted := make([]string, 0)
+	for id := range a.assigned {
+		if _, ok := a.devices[id]; !ok {
+			unlisted = append(unlisted, id)
+		}
+	}
+
+	var mu sync.Mutex
+	found := make([]orphan, 0)
+	err := util.Each(
+		len(unlisted),
+		fmt.Sprintf("Looking up %d assigned devices", len(unlisted)),
+		func(i int) error {
+			id := unlisted[i]
+			rack := a.assigned[id]
+			fix := fmt.Sprintf("Take the device out of rack %s", rack.Name)
+
+			d, err := util.API.GetDevice(id)
+			if err == conch.ErrDataNotFound {
+				mu.Lock()
+				defer mu.Unlock()
+				found = append(found, orphan{
+					Kind:   orphanMissingDevice,
+					ID:     id,
+					Detail: fmt.Sprintf("assigned to rack %s (%s), but the device does not exist", rack.Name, rack.ID),
+					Fix:    fix,
+				})
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if !d.Deactivated.IsZero() {
+				mu.Lock()
+				defer mu.Unlock()
+				found = append(found, orphan{
+					Kind:   orphanDeactivatedDevice,
+					ID:     id,
+					Detail: fmt.Sprintf("assigned to rack %s (%s), but deactivated %s", rack.Name, rack.ID, util.TimeStr(d.Deactivated)),
+					Fix:    fix,
+				})
+			}
+			return nil
+		},
+	)
+	return found, err
+}
+
+// findOrphans works through what was fetched and lists what is orphaned
+func (a orphanAudit) findOrphans() []orphan {
+	found := make([]orphan, 0)
+
+	for id, d := range a.devices {
+		if uuid.Equal(d.RackID, uuid.UUID{}) {
+			found = append(found, orphan{
+				Kind:   orphanUnlocatedDevice,
+				ID:     id,
+				Detail: "the device is not in any rack",
+				Fix:    fmt.Sprintf("Assign it to a slot with 'conch rack RACK assign', or 'conch device %s decommission'", id),
+			})
+		}
+	}
+
+	racks := make(map[uuid.UUID]conch.Rack)
+	for _, r := range a.racks {
+		racks[r.ID] = r
+	}
+	rooms := make(map[uuid.UUID]bool)
+	for _, r := range a.rooms {
+		rooms[r.ID] = true
+	}
+
+	for _, s := range a.slots {
+		fix := fmt.Sprintf("'conch global layout %s delete', or lay the rack out again", s.ID)
+
+		rack, ok := racks[s.RackID]
+		if !ok {
+			found = append(found, orphan{
+				Kind:   orphanSlotWithoutRack,
+				ID:     s.ID.String(),
+				Detail: fmt.Sprintf("rack %s does not exist", s.RackID),
+				Fix:    fmt.Sprintf("'conch global layout %s delete'", s.ID),
+			})
+			continue
+		}
+
+		p, ok := a.products[s.ProductID]
+		switch {
+		case !ok:
+			found = append(found, orphan{
+				Kind:   orphanDeletedProduct,
+				ID:     s.ID.String(),
+				Detail: fmt.Sprintf("RU %d of rack %s (%s) holds hardware product %s, which does not exist", s.RUStart, rack.Name, rack.ID, s.ProductID),
+				Fix:    fix,
+			})
+		case !p.Deactivated.IsZero():
+			found = append(found, orphan{
+				Kind:   orphanDeactivatedProduct,
+				ID:     s.ID.String(),
+				Detail: fmt.Sprintf("RU %d of rack %s (%s) holds hardware product %s, which was deactivated %s", s.RUStart, rack.Name, rack.ID, p.Name, util.TimeStr(p.Deactivated)),
+				Fix:    fix,
+			})
+		}
+	}
+
+	roomRacks := make(map[uuid.UUID]int)
+	for _, r := range a.racks {
+		roomRacks[r.DatacenterRoomID]++
+		if !rooms[r.DatacenterRoomID] {
+			found = append(found, orphan{
+				Kind:   orphanRackWithoutRoom,
+				ID:     r.ID.String(),
+				Detail: fmt.Sprintf("rack %s is in room %s, which does not exist", r.Name, r.DatacenterRoomID),
+				Fix:    fmt.Sprintf("Move it with 'conch rack %s update --datacenter-room-id ROOM', or delete it", r.ID),
+			})
+		}
+	}
+
+	for _, r := range a.rooms {
+		if roomRacks[r.ID] == 0 {
+			found = append(found, orphan{
+				Kind:   orphanEmptyRoom,
+				ID:     r.ID.String(),
+				Detail: fmt.Sprintf("room %s has no racks", r.Alias),
+				Fix:    fmt.Sprintf("'conch room %s delete', if the room is no longer used", r.ID),
+			})
+		}
+	}
+
+	return found
+}
+
+// sortOrphans puts the worklist in the order of orphanKinds, then by ID
+func sortOrphans(found []orphan) {
+	order := make(map[string]int)
+	for i, k := range orphanKinds {
+		order[k] = i
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Kind != found[j].Kind {
+			return order[found[i].Kind] < order[found[j].Kind]
+		}
+		return found[i].ID < found[j].ID
+	})
+}
+
+func auditOrphans(app *cli.Cmd) {
+	app.LongDesc = `
+Looks through the whole of Conch for objects that have lost what they hang
+off, and lists them as a cleanup worklist, with a suggested fix for each:
+
+  - devices that are in no rack
+  - rack slots assigned to devices that don't exist, or were deactivated
+  - layout slots holding hardware products that were deleted or deactivated
+  - layout slots of racks that don't exist
+  - racks in rooms that don't exist
+  - rooms with no racks
+
+Nothing is changed. This takes a request for every rack, so it can be slow on
+a big install. The command exits non-zero when it finds anything.`
+
+	app.Action = func() {
+		audit, err := fetchOrphanAudit()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		found := audit.findOrphans()
+		gone, err := audit.checkAssignedDevices()
+		if err != nil {
+			util.Bail(err)
+		}
+		found = append(found, gone...)
+		sortOrphans(found)
+
+		if util.JSON {
+			util.JSONOut(found)
+		} else if len(found) == 0 {
+			fmt.Println("No orphans found")
+		} else {
+			header := []string{"Kind", "ID", "Detail", "Fix"}
+			row := func(i int) []string {
+				o := found[i]
+				return []string{o.Kind, o.ID, o.Detail, o.Fix}
+			}
+			if err := util.RenderTable(util.GetMarkdownTable(), header, len(found), row, "Kind"); err != nil {
+				util.Bail(err)
+			}
+		}
+
+		if len(found) > 0 {
+			util.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package admin
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("admin users", conch.UsersDetailed{})
+	util.RegisterOutput("admin user get", conch.UserDetailed{})
+	util.RegisterOutput("admin user settings get", map[string]interface{}{})
+	util.RegisterOutput("admin user sessions", util.Sessions{})
+	util.RegisterOutput("admin user tokens", conch.UserTokens{})
+	util.RegisterOutput("admin user token get", conch.UserToken{})
+	util.RegisterOutput("admin audit-orphans", []orphan{})
+	util.RegisterOutput("admin audit-duplicates", []duplicate{})
+	util.RegisterOutput("admin device merge", []*util.PlanChange{})
+}
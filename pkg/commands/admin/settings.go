@@ -0,0 +1,107 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func getUserSettings(app *cli.Cmd) {
+	var nameArg = app.StringArg("NAME", "", "The name of a single setting to get")
+	app.Spec = "[NAME]"
+
+	app.Action = func() {
+		if *nameArg != "" {
+			setting, err := util.API.GetUserSettingByEmail(UserEmail, *nameArg)
+			if err != nil {
+				util.Bail(err)
+			}
+
+			// The API wraps the value up as { "name": value }
+			value := setting
+			if v, ok := setting.(map[string]interface{}); ok {
+				value = v[*nameArg]
+			}
+
+			if util.JSON {
+				util.JSONOut(value)
+			} else {
+				fmt.Println(value)
+			}
+			return
+		}
+
+		settings, err := util.API.GetUserSettingsByEmail(UserEmail)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(settings)
+			return
+		}
+
+		keys := make([]string, 0, len(settings))
+		for k := range settings {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("%s: %v\n", k, settings[k])
+		}
+	}
+}
+
+func setUserSetting(app *cli.Cmd) {
+	var (
+		nameArg  = app.StringArg("NAME", "", "The name of the setting")
+		valueArg = app.StringArg("VALUE", "", "Setting value as JSON string")
+	)
+	app.Spec = "NAME VALUE"
+
+	app.Action = func() {
+		// As with 'conch user setting NAME set', a value that isn't JSON is
+		// taken as a literal string
+		var value interface{}
+		if err := json.Unmarshal([]byte(*valueArg), &value); err != nil {
+			value = *valueArg
+		}
+
+		err := util.API.SetUserSettingByEmail(
+			UserEmail,
+			*nameArg,
+			map[string]interface{}{*nameArg: value},
+		)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if !util.JSON {
+			fmt.Printf("Setting '%s' set for %s\n", *nameArg, UserEmail)
+		}
+	}
+}
+
+func deleteUserSetting(app *cli.Cmd) {
+	var nameArg = app.StringArg("NAME", "", "The name of the setting")
+	app.Spec = "NAME"
+
+	app.Action = func() {
+		if err := util.API.DeleteUserSettingByEmail(UserEmail, *nameArg); err != nil {
+			util.Bail(err)
+		}
+
+		if !util.JSON {
+			fmt.Printf("Setting '%s' deleted for %s\n", *nameArg, UserEmail)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package alias
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// aliasRow is an alias as 'alias list' shows it
+type aliasRow struct {
+	WorkspaceID uuid.UUID `json:"workspace_id"`
+	Alias       string    `json:"alias"`
+	Serial      string    `json:"serial"`
+}
+
+func workspaceOpt(cmd *cli.Cmd) *string {
+	return cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace the alias belongs to. Defaults to the workspace in the active profile")
+}
+
+func set(cmd *cli.Cmd) {
+	var (
+		nameArg   = cmd.StringArg("NAME", "", "The alias")
+		deviceArg = cmd.StringArg("DEVICE", "", "The serial, hostname, or asset tag of the device")
+		wsOpt     = workspaceOpt(cmd)
+		forceOpt  = cmd.BoolOpt("force", false, "Point an existing alias at a different device")
+	)
+	cmd.Spec = "[OPTIONS] NAME DEVICE"
+
+	cmd.LongDesc = `
+Points an alias at a device, so that the device can be named by the alias
+anywhere a device ID is taken:
+
+    conch alias set web-01 S0M3S3R1AL
+    conch device web-01 get
+
+Aliases belong to a workspace, and are used while it is the active profile's
+workspace. They win over serials, hostnames, and asset tags. They are kept in
+the config file with the rest of the profile, so they are yours alone.`
+
+	cmd.Action = func() {
+		workspace, err := util.MagicWorkspaceOrActiveID(*wsOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		name := *nameArg
+		if name == "" || strings.ContainsAny(name, " \t/") {
+			util.Bail(errors.New("aliases may not be empty, or contain whitespace or slashes"))
+		}
+
+		serial, err := util.MagicDeviceID(*deviceArg)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		// A typo is much easier to spot now than whenever the alias is next
+		// used
+		d, err := util.API.GetDevice(serial)
+		if err != nil {
+			util.Bail(fmt.Errorf("could not find device %s: %s", *deviceArg, err))
+		}
+
+		if old, ok := util.DeviceAliases(workspace)[name]; ok && old != d.ID && !*forceOpt {
+			util.Bail(fmt.Errorf("alias '%s' already points at %s. Use --force to point it at %s", name, old, d.ID))
+		}
+
+		util.SetDeviceAlias(workspace, name, d.ID)
+		util.WriteConfig()
+
+		if util.JSON {
+			util.JSONOut(aliasRow{WorkspaceID: workspace, Alias: name, Serial: d.ID})
+			return
+		}
+		fmt.Printf("%s is now %s\n", name, d.ID)
+	}
+}
+
+func list(cmd *cli.Cmd) {
+	var (
+		wsOpt  = workspaceOpt(cmd)
+		allOpt = cmd.BoolOpt("all a", false, "List the aliases of every workspace")
+	)
+
+	cmd.Action = func() {
+		rows := make([]aliasRow, 0)
+		add := func(workspace uuid.UUID) {
+			for alias, serial := range util.DeviceAliases(workspace) {
+				rows = append(rows, aliasRow{workspace, alias, serial})
+			}
+		}
+
+		if *allOpt {
+			for key := range util.ActiveProfile.DeviceAliases {
+				if workspace, err := uuid.FromString(key); err == nil {
+					add(workspace)
+				}
+			}
+		} else {
+			workspace, err := util.MagicWorkspaceOrActiveID(*wsOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+			add(workspace)
+		}
+
+		sort.Slice(rows, func(i, j int) bool {
+			if !uuid.Equal(rows[i].WorkspaceID, rows[j].WorkspaceID) {
+				return rows[i].WorkspaceID.String() < rows[j].WorkspaceID.String()
+			}
+			return rows[i].Alias < rows[j].Alias
+		})
+
+		if util.JSON {
+			util.JSONOut(rows)
+			return
+		}
+
+		header := []string{"Alias", "Serial"}
+		if *allOpt {
+			header = append([]string{"Workspace"}, header...)
+		}
+		row := func(i int) []string {
+			r := rows[i]
+			if *allOpt {
+				return []string{r.WorkspaceID.String(), r.Alias, r.Serial}
+			}
+			return []string{r.Alias, r.Serial}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(rows), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func remove(cmd *cli.Cmd) {
+	var (
+		nameArg = cmd.StringArg("NAME", "", "The alias")
+		wsOpt   = workspaceOpt(cmd)
+	)
+	cmd.Spec = "[OPTIONS] NAME"
+
+	cmd.Action = func() {
+		workspace, err := util.MagicWorkspaceOrActiveID(*wsOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if _, ok := util.DeviceAliases(workspace)[*nameArg]; !ok {
+			util.Bail(fmt.Errorf("there is no alias named '%s' in workspace %s", *nameArg, workspace))
+		}
+
+		util.SetDeviceAlias(workspace, *nameArg, "")
+		util.WriteConfig()
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package alias contains commands for giving devices memorable names
+package alias
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"alias",
+		"Give devices names of your own, which work anywhere a device ID does",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+
+			util.Command(
+				cmd,
+				"set",
+				"Point an alias at a device",
+				set,
+			)
+
+			util.Command(
+				cmd,
+				"list ls",
+				"List the aliases",
+				list,
+			)
+
+			util.Command(
+				cmd,
+				"delete rm",
+				"Delete an alias",
+				remove,
+			)
+		},
+	)
+}
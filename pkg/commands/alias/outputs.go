@@ -0,0 +1,17 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package alias
+
+import (
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("alias set", aliasRow{})
+	util.RegisterOutput("alias list", []aliasRow{})
+}
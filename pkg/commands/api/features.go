@@ -0,0 +1,61 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"fmt"
+
+	cli "github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+type featureStatus struct {
+	conch.Feature
+	Supported bool `json:"supported"`
+}
+
+func features(cmd *cli.Cmd) {
+	var refresh = cmd.BoolOpt("refresh", false, "Probe the server again rather than trusting what was found before")
+	cmd.LongDesc = `
+Commands that need an optional part of the API check for it first, and explain
+when the server doesn't have it. What each server has is remembered in the
+profile until the server's version changes.`
+
+	cmd.Action = func() {
+		if *refresh {
+			util.ForgetFeatures()
+		}
+
+		statuses := make([]featureStatus, 0, len(conch.Features))
+		for _, f := range conch.Features {
+			has, err := util.HasFeature(f.Name)
+			if err != nil {
+				util.Bail(err)
+			}
+			statuses = append(statuses, featureStatus{f, has})
+		}
+
+		if util.JSON {
+			util.JSONOut(statuses)
+			return
+		}
+
+		fmt.Printf("API server %s, version %s\n\n", util.API.BaseURL, util.APIServerVersion)
+
+		table := util.GetMarkdownTable()
+		table.SetHeader([]string{"Feature", "Description", "Supported"})
+		for _, s := range statuses {
+			supported := "no"
+			if s.Supported {
+				supported = "yes"
+			}
+			table.Append([]string{s.Name, s.Description, supported})
+		}
+		table.Render()
+	}
+}
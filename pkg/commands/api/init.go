@@ -14,26 +14,38 @@ import (
 
 // Init loads up the commands dealing with direct api access
 func Init(app *cli.Cli) {
-	app.Command(
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
 		"api",
 		"Execute raw API commands",
 		func(cmd *cli.Cmd) {
 			cmd.Before = util.BuildAPIAndVerifyLogin
-			cmd.Command(
+			util.Command(
+				cmd,
 				"get",
 				"Perform an HTTP get against a provided URL",
 				get,
 			)
-			cmd.Command(
+			util.Command(
+				cmd,
 				"delete",
 				"Perform an HTTP DELETE against a provided URL",
 				deleteAPI,
 			)
-			cmd.Command(
+			util.Command(
+				cmd,
 				"post",
 				"Perform an HTTP POST against a provided URL",
 				postAPI,
 			)
+			util.Command(
+				cmd,
+				"features",
+				"Show which optional parts of the API the server has",
+				features,
+			)
 
 		},
 	)
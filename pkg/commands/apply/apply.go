@@ -0,0 +1,85 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package apply
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func applyCmd(cmd *cli.Cmd) {
+	var (
+		fileOpt  = cmd.StringOpt("file f", "", "Path to the YAML document. '-' indicates STDIN")
+		pruneOpt = cmd.BoolOpt("prune", false, "Also remove layout slots, workspace racks, and device settings that the document does not list")
+		planOpts = util.NewPlanOpts(cmd)
+	)
+
+	cmd.Spec = "--file [OPTIONS]"
+
+	cmd.LongDesc = `
+Compares a YAML document describing the desired inventory against the API and
+makes the changes needed to match it. For example:
+
+    hardware_products:
+      - name: Joyent-Compute-Platform-3301
+        alias: Hallasan C
+        vendor: Dell
+        sku: 600-0000-001
+        profile:
+          rack_unit: 2
+          purpose: compute
+
+    racks:
+      - name: A01
+        room: us-east-1a
+        role: MANTA
+        phase: integration
+        layout:
+          - ru: 1
+            product: Hallasan C
+
+    workspaces:
+      - name: us-east-1a-build
+        parent: GLOBAL
+        racks: [ A01 ]
+
+    device_settings:
+      SERIAL1:
+        build.owner: dcops
+
+Objects are matched by name. Rooms may be referred to by alias or AZ. Layout
+products may be referred to by name, alias, or SKU. Workspace racks are rack
+names, or 'room/name' when the name alone is ambiguous.
+
+Fields that are missing from the document are left alone. Nothing is ever
+deleted outright; --prune only removes layout slots, workspace racks, and
+device settings beneath objects that the document describes. Settings that
+hold the shell's own records of a device, like rma.* and ticket.*, are never
+pruned.`
+
+	cmd.Before = util.BuildAPIAndVerifyLogin
+
+	util.NotifyOpt(cmd, "apply")
+
+	cmd.Action = func() {
+		doc, err := loadDocument(*fileOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		p, err := newPlanner(*pruneOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if err := p.build(doc); err != nil {
+			util.Bail(err)
+		}
+
+		p.plan.Run(planOpts)
+	}
+}
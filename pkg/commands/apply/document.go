@@ -0,0 +1,169 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	homedir "github.com/mitchellh/go-homedir"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// document is the desired state, as written by a human
+type document struct {
+	HardwareProducts []productDoc                 `yaml:"hardware_products"`
+	Racks            []rackDoc                    `yaml:"racks"`
+	Workspaces       []workspaceDoc               `yaml:"workspaces"`
+	DeviceSettings   map[string]map[string]string `yaml:"device_settings"`
+}
+
+type productDoc struct {
+	Name              string                 `yaml:"name"`
+	Alias             string                 `yaml:"alias"`
+	Vendor            string                 `yaml:"vendor"`
+	SKU               string                 `yaml:"sku"`
+	Prefix            string                 `yaml:"prefix"`
+	GenerationName    string                 `yaml:"generation_name"`
+	LegacyProductName string                 `yaml:"legacy_product_name"`
+	Specification     interface{}            `yaml:"specification"`
+	Profile           map[string]interface{} `yaml:"profile"`
+}
+
+type rackDoc struct {
+	Name         string      `yaml:"name"`
+	Room         string      `yaml:"room"`
+	Role         string      `yaml:"role"`
+	SerialNumber string      `yaml:"serial_number"`
+	AssetTag     string      `yaml:"asset_tag"`
+	Phase        string      `yaml:"phase"`
+	Layout       []layoutDoc `yaml:"layout"`
+}
+
+type layoutDoc struct {
+	RU      int    `yaml:"ru"`
+	Product string `yaml:"product"`
+}
+
+type workspaceDoc struct {
+	Name        string   `yaml:"name"`
+	Parent      string   `yaml:"parent"`
+	Description string   `yaml:"description"`
+	Racks       []string `yaml:"racks"`
+}
+
+func loadDocument(path string) (document, error) {
+	var doc document
+	var raw []byte
+	var err error
+
+	if path == "-" {
+		raw, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		path, err = homedir.Expand(path)
+		if err != nil {
+			return doc, err
+		}
+		raw, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return doc, err
+	}
+
+	if err := yaml.UnmarshalStrict(raw, &doc); err != nil {
+		return doc, err
+	}
+
+	return doc, doc.validate()
+}
+
+func (doc document) validate() error {
+	products := make(map[string]bool)
+	for i, p := range doc.HardwareProducts {
+		if p.Name == "" {
+			return fmt.Errorf("hardware_products[%d] has no name", i)
+		}
+		if products[p.Name] {
+			return fmt.Errorf("hardware product '%s' is listed more than once", p.Name)
+		}
+		products[p.Name] = true
+	}
+
+	racks := make(map[string]bool)
+	for i, r := range doc.Racks {
+		if r.Name == "" || r.Room == "" {
+			return fmt.Errorf("racks[%d] needs both a name and a room", i)
+		}
+		key := r.Room + "/" + r.Name
+		if racks[key] {
+			return fmt.Errorf("rack '%s' is listed more than once", key)
+		}
+		racks[key] = true
+
+		rus := make(map[int]bool)
+		for _, l := range r.Layout {
+			if l.RU < 1 {
+				return fmt.Errorf("rack '%s' has a layout entry with an invalid ru", key)
+			}
+			if l.Product == "" {
+				return fmt.Errorf("rack '%s' ru %d has no product", key, l.RU)
+			}
+			if rus[l.RU] {
+				return fmt.Errorf("rack '%s' ru %d is listed more than once", key, l.RU)
+			}
+			rus[l.RU] = true
+		}
+	}
+
+	workspaces := make(map[string]bool)
+	for i, w := range doc.Workspaces {
+		if w.Name == "" {
+			return fmt.Errorf("workspaces[%d] has no name", i)
+		}
+		if workspaces[w.Name] {
+			return fmt.Errorf("workspace '%s' is listed more than once", w.Name)
+		}
+		workspaces[w.Name] = true
+	}
+
+	if len(doc.HardwareProducts) == 0 &&
+		len(doc.Racks) == 0 &&
+		len(doc.Workspaces) == 0 &&
+		len(doc.DeviceSettings) == 0 {
+		return errors.New("the document does not describe anything")
+	}
+
+	return nil
+}
+
+// jsonable converts the map[interface{}]interface{} values that yaml.v2
+// produces into something encoding/json will accept
+func jsonable(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[fmt.Sprintf("%v", k)] = jsonable(val)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[k] = jsonable(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = jsonable(val)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,24 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package apply contains the command that makes the API match a declarative
+// inventory document
+package apply
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the apply command
+func Init(app *cli.Cli) {
+	util.Command(
+		app.Cmd,
+		"apply",
+		"Make hardware products, racks, layouts, workspaces, and device settings match a YAML document",
+		applyCmd,
+	)
+}
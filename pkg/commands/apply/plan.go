@@ -0,0 +1,572 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// planner holds the actual state of the world, as fetched from the API, and
+// accumulates the plan needed to match a document. Objects that are going
+// to be created are added to the lookup maps as they are planned, so that
+// later changes can refer to them. Their IDs are filled in when the creating
+// change runs.
+type planner struct {
+	prune bool
+	plan  *util.Plan
+
+	vendors    map[string]uuid.UUID
+	products   map[string]*conch.HardwareProduct
+	rooms      map[string]conch.Room
+	roles      map[string]conch.RackRole
+	racks      map[string]*conch.Rack
+	rackList   []*conch.Rack
+	workspaces map[string]*conch.Workspace
+}
+
+func newPlanner(prune bool) (*planner, error) {
+	p := &planner{
+		prune:      prune,
+		plan:       util.NewPlan(),
+		vendors:    make(map[string]uuid.UUID),
+		products:   make(map[string]*conch.HardwareProduct),
+		rooms:      make(map[string]conch.Room),
+		roles:      make(map[string]conch.RackRole),
+		racks:      make(map[string]*conch.Rack),
+		rackList:   make([]*conch.Rack, 0),
+		workspaces: make(map[string]*conch.Workspace),
+	}
+
+	vendors, err := util.API.GetHardwareVendors()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vendors {
+		p.vendors[v.Name] = v.ID
+	}
+
+	products, err := util.API.GetHardwareProducts()
+	if err != nil {
+		return nil, err
+	}
+	for i := range products {
+		prod := &products[i]
+		p.products[prod.Name] = prod
+	}
+
+	rooms, err := util.API.GetRooms()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rooms {
+		p.rooms[r.ID.String()] = r
+		p.rooms[r.AZ] = r
+		if r.Alias != "" {
+			p.rooms[r.Alias] = r
+		}
+	}
+
+	roles, err := util.API.GetRackRoles()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range roles {
+		p.roles[r.Name] = r
+	}
+
+	racks, err := util.API.GetRacks()
+	if err != nil {
+		return nil, err
+	}
+	for i := range racks {
+		rack := &racks[i]
+		p.racks[rack.DatacenterRoomID.String()+"/"+rack.Name] = rack
+		p.rackList = append(p.rackList, rack)
+	}
+
+	workspaces, err := util.API.GetWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range workspaces {
+		ws := &workspaces[i]
+		p.workspaces[ws.Name] = ws
+	}
+
+	return p, nil
+}
+
+// product finds a hardware product by name, alias, or SKU
+func (p *planner) product(ref string) (*conch.HardwareProduct, error) {
+	if prod, ok := p.products[ref]; ok {
+		return prod, nil
+	}
+	for _, prod := range p.products {
+		if prod.Alias == ref || (prod.SKU != "" && prod.SKU == ref) {
+			return prod, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find hardware product '%s'", ref)
+}
+
+// rack finds a rack by name, or by 'room/name' when the name alone is
+// ambiguous
+func (p *planner) rack(ref string) (*conch.Rack, error) {
+	roomRef := ""
+	name := ref
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		roomRef = ref[:i]
+		name = ref[i+1:]
+	}
+
+	if roomRef != "" {
+		room, ok := p.rooms[roomRef]
+		if !ok {
+			return nil, fmt.Errorf("could not find room '%s'", roomRef)
+		}
+		if rack, ok := p.racks[room.ID.String()+"/"+name]; ok {
+			return rack, nil
+		}
+		return nil, fmt.Errorf("could not find rack '%s'", ref)
+	}
+
+	var found *conch.Rack
+	for _, rack := range p.rackList {
+		if rack.Name != name {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("rack name '%s' is ambiguous. Use 'room/name'", ref)
+		}
+		found = rack
+	}
+	if found == nil {
+		return nil, fmt.Errorf("could not find rack '%s'", ref)
+	}
+	return found, nil
+}
+
+func (p *planner) planProducts(docs []productDoc) error {
+	for _, d := range docs {
+		d := d
+		existing := p.products[d.Name]
+
+		desired := &conch.HardwareProduct{}
+		if existing != nil {
+			*desired = *existing
+		}
+		desired.Name = d.Name
+
+		if d.Alias != "" {
+			desired.Alias = d.Alias
+		}
+		if d.Vendor != "" {
+			id, ok := p.vendors[d.Vendor]
+			if !ok {
+				return fmt.Errorf("hardware product '%s': could not find vendor '%s'", d.Name, d.Vendor)
+			}
+			desired.HardwareVendorID = id
+		}
+		if d.SKU != "" {
+			desired.SKU = d.SKU
+		}
+		if d.Prefix != "" {
+			desired.Prefix = d.Prefix
+		}
+		if d.GenerationName != "" {
+			desired.GenerationName = d.GenerationName
+		}
+		if d.LegacyProductName != "" {
+			desired.LegacyProductName = d.LegacyProductName
+		}
+		if d.Specification != nil {
+			desired.Specification = jsonable(d.Specification)
+		}
+		if d.Profile != nil {
+			// Overlay the document's profile fields onto the existing profile
+			current := make(map[string]interface{})
+			j, _ := json.Marshal(desired.Profile)
+			_ = json.Unmarshal(j, &current)
+			for k, v := range jsonable(d.Profile).(map[string]interface{}) {
+				current[k] = v
+			}
+
+			j, err := json.Marshal(current)
+			if err != nil {
+				return err
+			}
+			profile := conch.HardwareProfile{}
+			if err := json.Unmarshal(j, &profile); err != nil {
+				return fmt.Errorf("hardware product '%s': bad profile: %s", d.Name, err)
+			}
+			desired.Profile = profile
+		}
+
+		if existing == nil {
+			if desired.Alias == "" || uuid.Equal(desired.HardwareVendorID, uuid.UUID{}) {
+				return fmt.Errorf("hardware product '%s' does not exist, so it needs an alias and a vendor", d.Name)
+			}
+
+			p.products[d.Name] = desired
+			p.plan.Add(util.PlanCreate, "hardware product", d.Name, "", func() error {
+				return util.API.SaveHardwareProduct(desired)
+			})
+			continue
+		}
+
+		fields := util.FieldNames(util.FieldDiff(existing, desired))
+		if len(fields) == 0 {
+			continue
+		}
+
+		p.plan.Add(util.PlanUpdate, "hardware product", d.Name, strings.Join(fields, ", "), func() error {
+			if err := util.API.SaveHardwareProduct(desired); err != nil {
+				return err
+			}
+			*existing = *desired
+			return nil
+		})
+	}
+
+	return nil
+}
+
+func (p *planner) planRacks(docs []rackDoc) error {
+	for _, d := range docs {
+		d := d
+		name := d.Room + "/" + d.Name
+
+		room, ok := p.rooms[d.Room]
+		if !ok {
+			return fmt.Errorf("rack '%s': could not find room '%s'", name, d.Room)
+		}
+
+		var roleID uuid.UUID
+		if d.Role != "" {
+			role, ok := p.roles[d.Role]
+			if !ok {
+				return fmt.Errorf("rack '%s': could not find rack role '%s'", name, d.Role)
+			}
+			roleID = role.ID
+		}
+
+		key := room.ID.String() + "/" + d.Name
+		rack, exists := p.racks[key]
+
+		if !exists {
+			if uuid.Equal(roleID, uuid.UUID{}) {
+				return fmt.Errorf("rack '%s' does not exist, so it needs a role", name)
+			}
+
+			rack = &conch.Rack{
+				DatacenterRoomID: room.ID,
+				Name:             d.Name,
+				RoleID:           roleID,
+				SerialNumber:     d.SerialNumber,
+				AssetTag:         d.AssetTag,
+			}
+			p.racks[key] = rack
+			p.rackList = append(p.rackList, rack)
+
+			detail := "role " + d.Role
+			if d.Phase != "" {
+				detail = detail + ", phase " + d.Phase
+			}
+
+			p.plan.Add(util.PlanCreate, "rack", name, detail, func() error {
+				if err := util.API.SaveRack(rack); err != nil {
+					return err
+				}
+				if d.Phase != "" {
+					return util.API.SetRackPhase(rack.ID, d.Phase, false)
+				}
+				return nil
+			})
+		} else {
+			desired := *rack
+			if !uuid.Equal(roleID, uuid.UUID{}) {
+				desired.RoleID = roleID
+			}
+			if d.SerialNumber != "" {
+				desired.SerialNumber = d.SerialNumber
+			}
+			if d.AssetTag != "" {
+				desired.AssetTag = d.AssetTag
+			}
+
+			if fields := util.FieldNames(util.FieldDiff(*rack, desired)); len(fields) > 0 {
+				p.plan.Add(util.PlanUpdate, "rack", name, strings.Join(fields, ", "), func() error {
+					if err := util.API.SaveRack(&desired); err != nil {
+						return err
+					}
+					*rack = desired
+					return nil
+				})
+			}
+
+			if d.Phase != "" && d.Phase != rack.Phase {
+				p.plan.Add(util.PlanUpdate, "rack", name, "phase "+rack.Phase+" -> "+d.Phase, func() error {
+					return util.API.SetRackPhase(rack.ID, d.Phase, false)
+				})
+			}
+		}
+
+		if d.Layout == nil {
+			continue
+		}
+		if err := p.planLayout(name, rack, exists, d.Layout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *planner) planLayout(name string, rack *conch.Rack, exists bool, docs []layoutDoc) error {
+	existing := make(map[int]conch.RackLayoutSlot)
+	if exists {
+		slots, err := util.API.GetRackLayout(*rack)
+		if err != nil {
+			return err
+		}
+		for _, s := range slots {
+			existing[s.RUStart] = s
+		}
+	}
+
+	desired := make(map[int]bool)
+	for _, d := range docs {
+		d := d
+		desired[d.RU] = true
+
+		prod, err := p.product(d.Product)
+		if err != nil {
+			return fmt.Errorf("rack '%s' ru %d: %s", name, d.RU, err)
+		}
+
+		slotName := fmt.Sprintf("%s ru %d", name, d.RU)
+
+		slot, ok := existing[d.RU]
+		if !ok {
+			p.plan.Add(util.PlanCreate, "layout slot", slotName, prod.Name, func() error {
+				return util.API.SaveRackLayoutSlot(&conch.RackLayoutSlot{
+					RackID:    rack.ID,
+					ProductID: prod.ID,
+					RUStart:   d.RU,
+				})
+			})
+			continue
+		}
+
+		if uuid.Equal(slot.ProductID, prod.ID) {
+			continue
+		}
+
+		p.plan.Add(util.PlanUpdate, "layout slot", slotName, "product -> "+prod.Name, func() error {
+			slot.ProductID = prod.ID
+			return util.API.SaveRackLayoutSlot(&slot)
+		})
+	}
+
+	if !p.prune {
+		return nil
+	}
+
+	rus := make([]int, 0)
+	for ru := range existing {
+		if !desired[ru] {
+			rus = append(rus, ru)
+		}
+	}
+	sort.Ints(rus)
+
+	for _, ru := range rus {
+		slot := existing[ru]
+		p.plan.Add(util.PlanDelete, "layout slot", fmt.Sprintf("%s ru %d", name, ru), "", func() error {
+			return util.API.DeleteRackLayoutSlot(slot.ID)
+		})
+	}
+
+	return nil
+}
+
+func (p *planner) planWorkspaces(docs []workspaceDoc) error {
+	for _, d := range docs {
+		d := d
+
+		ws, exists := p.workspaces[d.Name]
+		if !exists {
+			parentName := d.Parent
+			if parentName == "" {
+				parentName = "GLOBAL"
+			}
+			parent, ok := p.workspaces[parentName]
+			if !ok {
+				return fmt.Errorf("workspace '%s': could not find parent workspace '%s'", d.Name, parentName)
+			}
+
+			ws = &conch.Workspace{Name: d.Name, Description: d.Description}
+			p.workspaces[d.Name] = ws
+
+			p.plan.Add(util.PlanCreate, "workspace", d.Name, "parent "+parentName, func() error {
+				created, err := util.API.CreateSubWorkspace(*parent, *ws)
+				if err != nil {
+					return err
+				}
+				*ws = created
+				return nil
+			})
+		}
+
+		if d.Racks == nil {
+			continue
+		}
+
+		current := make(map[string]conch.WorkspaceRack)
+		if exists {
+			racks, err := util.API.GetWorkspaceRacks(ws.ID)
+			if err != nil {
+				return err
+			}
+			for _, r := range racks {
+				current[r.ID.String()] = r
+			}
+		}
+
+		wanted := make(map[*conch.Rack]bool)
+		for _, ref := range d.Racks {
+			rack, err := p.rack(ref)
+			if err != nil {
+				return fmt.Errorf("workspace '%s': %s", d.Name, err)
+			}
+			wanted[rack] = true
+
+			if _, ok := current[rack.ID.String()]; ok {
+				continue
+			}
+
+			p.plan.Add(util.PlanCreate, "workspace rack", d.Name+": "+ref, "", func() error {
+				return util.API.AddRackToWorkspace(ws.ID, rack.ID)
+			})
+		}
+
+		if !p.prune {
+			continue
+		}
+
+		keep := make(map[string]bool)
+		for rack := range wanted {
+			keep[rack.ID.String()] = true
+		}
+
+		ids := make([]string, 0)
+		for id := range current {
+			if !keep[id] {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			r := current[id]
+			p.plan.Add(util.PlanDelete, "workspace rack", d.Name+": "+r.Name, "", func() error {
+				return util.API.DeleteRackFromWorkspace(ws.ID, r.ID)
+			})
+		}
+	}
+
+	return nil
+}
+
+func (p *planner) planDeviceSettings(docs map[string]map[string]string) error {
+	serials := make([]string, 0, len(docs))
+	for serial := range docs {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+
+	for _, serial := range serials {
+		serial := serial
+		desired := docs[serial]
+
+		current, err := util.API.GetDeviceSettings(serial)
+		if err != nil {
+			return fmt.Errorf("device '%s': %s", serial, err)
+		}
+
+		keys := make([]string, 0, len(desired))
+		for k := range desired {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			k := k
+			v := desired[k]
+			old, ok := current[k]
+			if ok && old == v {
+				continue
+			}
+
+			action := util.PlanCreate
+			detail := v
+			if ok {
+				action = util.PlanUpdate
+				detail = old + " -> " + v
+			}
+			p.plan.Add(action, "device setting", serial+": "+k, detail, func() error {
+				return util.API.SetDeviceSetting(serial, k, v)
+			})
+		}
+
+		if !p.prune {
+			continue
+		}
+
+		extra := make([]string, 0)
+		for k := range current {
+			if conch.IsReservedSetting(k) {
+				continue
+			}
+			if _, ok := desired[k]; !ok {
+				extra = append(extra, k)
+			}
+		}
+		sort.Strings(extra)
+
+		for _, k := range extra {
+			k := k
+			p.plan.Add(util.PlanDelete, "device setting", serial+": "+k, "", func() error {
+				return util.API.DeleteDeviceSetting(serial, k)
+			})
+		}
+	}
+
+	return nil
+}
+
+// build works out every change needed for the document, in the order they
+// must run. Nothing is changed on the server.
+func (p *planner) build(doc document) error {
+	if err := p.planProducts(doc.HardwareProducts); err != nil {
+		return err
+	}
+	if err := p.planRacks(doc.Racks); err != nil {
+		return err
+	}
+	if err := p.planWorkspaces(doc.Workspaces); err != nil {
+		return err
+	}
+	return p.planDeviceSettings(doc.DeviceSettings)
+}
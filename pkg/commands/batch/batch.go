@@ -0,0 +1,406 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// workerEnv is set in the environment of the worker processes of a
+// --parallel batch
+const workerEnv = "CONCH_BATCH_WORKER"
+
+// Result is the line of JSON written for each command in a batch
+type Result struct {
+	Line     int             `json:"line"`
+	Command  string          `json:"command"`
+	OK       bool            `json:"ok"`
+	ExitCode int             `json:"exit_code"`
+	Output   json.RawMessage `json:"output,omitempty"`
+	Text     string          `json:"text,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Stderr   string          `json:"stderr,omitempty"`
+	Seconds  float64         `json:"seconds"`
+}
+
+func batch(cmd *cli.Cmd, newApp func() *cli.Cli) {
+	var parallelOpt = cmd.IntOpt("parallel P", 1, "Run this many commands at a time, each share in its own worker process")
+
+	cmd.LongDesc = `
+Each line of STDIN is a conch command, without the leading 'conch', such as
+'device ABC123 get'. Arguments can be quoted as in a shell. Blank lines and
+lines starting with # are skipped.
+
+The commands share one API client, so the API version and the login are only
+checked once, however many commands there are. Global options given before
+'batch', like --profile and --json, apply to every command. Use --json to get
+each command's output as JSON rather than text.
+
+For each command, a line of JSON is written to STDOUT with the line number,
+the exit code, and the command's output. With --parallel, results are written
+as they finish, so use the line number to match them up. The batch exits
+non-zero if any command failed.`
+
+	cmd.Action = func() {
+		// The batch is counted by the process that started the workers, and
+		// its commands by the workers
+		if os.Getenv(workerEnv) != "" {
+			util.CancelStats()
+		}
+
+		globals := globalArgs()
+
+		var failed bool
+		var err error
+		if *parallelOpt > 1 {
+			failed, err = runWorkers(os.Stdin, os.Stdout, globals, *parallelOpt)
+		} else {
+			failed, err = runLines(os.Stdin, os.Stdout, globals, newApp)
+		}
+		if err == util.ErrInterrupted {
+			fmt.Fprintln(os.Stderr, "Interrupted. Commands after the last result were not run")
+			util.Exit(util.InterruptExitCode)
+		}
+		if err != nil {
+			util.Bail(err)
+		}
+		if failed {
+			util.Exit(1)
+		}
+	}
+}
+
+// globalArgs are the arguments before 'batch', which are given to every
+// command in the batch
+func globalArgs() []string {
+	for i, arg := range os.Args[1:] {
+		if arg == "batch" {
+			return os.Args[1 : i+1]
+		}
+	}
+	return []string{}
+}
+
+// runLines runs each command in this process. ^C lets the running command
+// stop cleanly, then ends the batch.
+func runLines(in io.Reader, out io.Writer, globals []string, newApp func() *cli.Cli) (bool, error) {
+	util.BatchMode = true
+	defer func() { util.BatchMode = false }()
+
+	done := util.Stoppable()
+	defer done()
+
+	enc := json.NewEncoder(out)
+	failed := false
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		res := runLine(line, globals, newApp)
+		res.Line = n
+		if !res.OK {
+			failed = true
+		}
+		if err := enc.Encode(res); err != nil {
+			return failed, err
+		}
+
+		if util.Interrupted() {
+			return failed, util.ErrInterrupted
+		}
+	}
+	return failed, scanner.Err()
+}
+
+func runLine(line string, globals []string, newApp func() *cli.Cli) Result {
+	res := Result{Command: line}
+
+	args, err := splitLine(line)
+	if err == nil && len(args) > 0 && args[0] == "conch" {
+		args = args[1:]
+	}
+	if err == nil && len(args) > 0 && args[0] == "batch" {
+		err = errors.New("a batch can't run 'batch'")
+	}
+	if err == nil && len(args) == 0 {
+		err = errors.New("no command")
+	}
+	if err != nil {
+		res.ExitCode = 2
+		res.Error = err.Error()
+		return res
+	}
+
+	argv := make([]string, 0, 1+len(globals)+len(args))
+	argv = append(argv, os.Args[0])
+	argv = append(argv, globals...)
+	argv = append(argv, args...)
+
+	start := time.Now()
+	var code int
+	var message string
+	stdout, stderr, err := capture(func() {
+		code, message = util.RunBatchCommand(newApp(), argv)
+	})
+	res.Seconds = time.Since(start).Seconds()
+	if err != nil {
+		code, message = 1, err.Error()
+	}
+
+	res.ExitCode = code
+	res.OK = code == 0
+	res.Error = message
+	res.Stderr = string(stderr)
+
+	trimmed := bytes.TrimSpace(stdout)
+	if len(trimmed) > 0 && json.Valid(trimmed) {
+		res.Output = json.RawMessage(trimmed)
+	} else {
+		res.Text = string(stdout)
+	}
+	return res
+}
+
+// capture runs fn with STDOUT and STDERR redirected, and returns what was
+// written to them
+func capture(fn func()) (stdout []byte, stderr []byte, err error) {
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		outR.Close()
+		outW.Close()
+		return nil, nil, err
+	}
+
+	var outBuf, errBuf bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(&outBuf, outR)
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(&errBuf, errR)
+	}()
+
+	origOut, origErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	defer func() {
+		os.Stdout, os.Stderr = origOut, origErr
+		outW.Close()
+		errW.Close()
+		wg.Wait()
+		outR.Close()
+		errR.Close()
+		stdout, stderr = outBuf.Bytes(), errBuf.Bytes()
+	}()
+
+	fn()
+	return
+}
+
+// runWorkers shares the commands out between worker processes, each of which
+// is a 'conch batch' of its own, and passes their results on
+func runWorkers(in io.Reader, out io.Writer, globals []string, count int) (bool, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return false, err
+	}
+
+	type worker struct {
+		cmd   *exec.Cmd
+		stdin io.WriteCloser
+		// lines maps the worker's own line numbers to ours
+		lines []int
+		sync.Mutex
+	}
+
+	results := make(chan Result)
+	workers := make([]*worker, count)
+	var readers sync.WaitGroup
+
+	for i := range workers {
+		w := &worker{cmd: exec.Command(exe, append(append([]string{}, globals...), "batch")...)}
+		w.cmd.Env = append(os.Environ(), workerEnv+"=1")
+		w.cmd.Stderr = os.Stderr
+
+		if w.stdin, err = w.cmd.StdinPipe(); err != nil {
+			return false, err
+		}
+		stdout, err := w.cmd.StdoutPipe()
+		if err != nil {
+			return false, err
+		}
+		if err := w.cmd.Start(); err != nil {
+			return false, err
+		}
+		workers[i] = w
+
+		readers.Add(1)
+		go func(w *worker, stdout io.Reader) {
+			defer readers.Done()
+			dec := json.NewDecoder(stdout)
+			for {
+				var res Result
+				if err := dec.Decode(&res); err != nil {
+					return
+				}
+				w.Lock()
+				if res.Line > 0 && res.Line <= len(w.lines) {
+					res.Line = w.lines[res.Line-1]
+				}
+				w.Unlock()
+				results <- res
+			}
+		}(w, stdout)
+	}
+
+	done := make(chan struct{})
+	failed := false
+	written := 0
+	var writeErr error
+	go func() {
+		defer close(done)
+		enc := json.NewEncoder(out)
+		for res := range results {
+			written++
+			if !res.OK {
+				failed = true
+			}
+			if writeErr == nil {
+				writeErr = enc.Encode(res)
+			}
+		}
+	}()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	sent := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		w := workers[sent%count]
+		sent++
+		w.Lock()
+		w.lines = append(w.lines, n)
+		w.Unlock()
+		if _, err := fmt.Fprintln(w.stdin, line); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to send line %d to a worker: %s\n", n, err)
+		}
+	}
+	scanErr := scanner.Err()
+
+	for _, w := range workers {
+		w.stdin.Close()
+	}
+	readers.Wait()
+	for _, w := range workers {
+		// Workers exit non-zero when one of their commands failed, which
+		// the results already say
+		_ = w.cmd.Wait()
+	}
+	close(results)
+	<-done
+
+	if writeErr != nil {
+		return failed, writeErr
+	}
+	if scanErr != nil {
+		return failed, scanErr
+	}
+	if written < sent {
+		return true, fmt.Errorf("%d of %d commands were lost when their worker died", sent-written, sent)
+	}
+	return failed, nil
+}
+
+// splitLine breaks a line into arguments the way a shell would, minus the
+// variables and globs. Single quotes keep everything, double quotes keep
+// everything but backslash escapes, and a backslash outside quotes escapes
+// the next character.
+func splitLine(line string) ([]string, error) {
+	args := make([]string, 0)
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("the line ends with a backslash")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
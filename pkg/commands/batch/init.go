@@ -0,0 +1,27 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package batch contains the command that runs many conch commands, read
+// from STDIN, in a single process
+package batch
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the batch command. newApp builds a fresh copy of the whole
+// app, since each command in a batch needs its own.
+func Init(app *cli.Cli, newApp func() *cli.Cli) {
+	util.Command(
+		app.Cmd,
+		"batch",
+		"Run conch commands read from STDIN, one per line, and write a JSON result for each",
+		func(cmd *cli.Cmd) {
+			batch(cmd, newApp)
+		},
+	)
+}
@@ -0,0 +1,181 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package components
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// componentLocation is a component along with the device that holds it
+type componentLocation struct {
+	WorkspaceID   uuid.UUID `json:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name"`
+	DeviceID      string    `json:"device_id"`
+	Hostname      string    `json:"hostname"`
+	Rack          string    `json:"rack"`
+	RackUnit      int       `json:"rack_unit_start"`
+	conch.Component
+}
+
+func find(cmd *cli.Cmd) {
+	var (
+		serialArg    = cmd.StringArg("SERIAL", "", "The serial number of the component, or the MAC address of a NIC")
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace to search. Defaults to the workspace in the active profile")
+		partialOpt   = cmd.BoolOpt("partial", false, "Match any serial that contains SERIAL, rather than the whole serial")
+		targets      = util.WorkspaceFanOutFlags(cmd)
+	)
+	cmd.Spec = "[OPTIONS] SERIAL"
+
+	cmd.LongDesc = `
+Searches the devices of a workspace for a disk, DIMM, power supply, or NIC and
+shows which device currently holds it, and where that device is. Serials are
+matched without regard to case. This requires fetching every device in the
+workspace individually.
+
+The command exits non-zero if the component isn't found.`
+
+	cmd.Action = func() {
+		want := strings.ToLower(strings.TrimSpace(*serialArg))
+		matches := func(serial string) bool {
+			serial = strings.ToLower(serial)
+			if *partialOpt {
+				return strings.Contains(serial, want)
+			}
+			return serial == want
+		}
+
+		var workspaces conch.Workspaces
+		if targets.Set() {
+			var err error
+			workspaces, err = targets.Resolve()
+			if err != nil {
+				util.Bail(err)
+			}
+		} else {
+			id, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+			ws, err := util.API.GetWorkspace(id)
+			if err != nil {
+				util.Bail(err)
+			}
+			workspaces = conch.Workspaces{ws}
+		}
+
+		// Devices are often in more than one workspace, so each one is
+		// fetched only once
+		results := make([]conch.Devices, len(workspaces))
+		err := util.FanOut(workspaces, func(i int, ws conch.Workspace) error {
+			devices, err := util.API.GetWorkspaceDevices(ws.ID, false, "", "", "")
+			if err != nil {
+				return err
+			}
+			results[i] = devices
+			return nil
+		})
+		if err != nil {
+			util.Bail(err)
+		}
+
+		seen := make(map[string]bool)
+		owners := make([]conch.Workspace, 0)
+		devices := make([]conch.Device, 0)
+		for i, ds := range results {
+			for _, d := range ds {
+				if seen[d.ID] {
+					continue
+				}
+				seen[d.ID] = true
+				owners = append(owners, workspaces[i])
+				devices = append(devices, d)
+			}
+		}
+
+		devices, err = util.FillDeviceDetails(devices)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		found := make([]componentLocation, 0)
+		for i, d := range devices {
+			for _, c := range d.Components() {
+				if c.Serial == "" || !matches(c.Serial) {
+					continue
+				}
+				found = append(found, componentLocation{
+					WorkspaceID:   owners[i].ID,
+					WorkspaceName: owners[i].Name,
+					DeviceID:      d.ID,
+					Hostname:      d.Hostname,
+					Component:     c,
+				})
+			}
+		}
+
+		if len(found) > 0 {
+			located := make([]conch.Device, len(found))
+			for i, f := range found {
+				for _, d := range devices {
+					if d.ID == f.DeviceID {
+						located[i] = d
+						break
+					}
+				}
+			}
+			located, err = util.FillDeviceLocations(located)
+			if err != nil {
+				util.Bail(err)
+			}
+			for i := range found {
+				found[i].Rack = located[i].Location.Rack.Name
+				found[i].RackUnit = located[i].Location.RackUnitStart
+			}
+		}
+
+		if util.JSON {
+			util.JSONOut(found)
+		} else if len(found) == 0 {
+			fmt.Printf("No component with serial '%s' was found\n", *serialArg)
+		} else {
+			header := []string{"Workspace", "Device", "Hostname", "Rack", "RU", "Kind", "Slot", "Serial", "Model", "Health"}
+			row := func(i int) []string {
+				f := found[i]
+				ru := ""
+				if f.RackUnit != 0 {
+					ru = strconv.Itoa(f.RackUnit)
+				}
+				return []string{
+					f.WorkspaceName,
+					f.DeviceID,
+					f.Hostname,
+					f.Rack,
+					ru,
+					f.Kind,
+					f.Slot,
+					f.Serial,
+					f.Model,
+					f.Health,
+				}
+			}
+			if err := util.RenderTable(util.GetMarkdownTable(), header, len(found), row); err != nil {
+				util.Bail(err)
+			}
+		}
+
+		if len(found) == 0 {
+			util.Exit(1)
+		}
+	}
+}
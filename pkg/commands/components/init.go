@@ -0,0 +1,35 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package components contains commands for tracking the parts inside
+// devices, like disks and DIMMs, by their serial numbers
+package components
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the component commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"component comp",
+		"Commands for dealing with the components inside devices",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+
+			util.Command(
+				cmd,
+				"find",
+				"Find the device that holds a component, by the component's serial number or MAC address",
+				find,
+			)
+		},
+	)
+}
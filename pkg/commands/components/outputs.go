@@ -0,0 +1,16 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package components
+
+import (
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("component find", []componentLocation{})
+}
@@ -0,0 +1,303 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package copier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+const copyLongDesc = `
+Objects are matched by name on the other profile's API. A missing object is
+created, and one that differs is updated to match. The changes are listed,
+and only made once confirmed.`
+
+// copyOpts are the options every copy command takes
+type copyOpts struct {
+	name      *string
+	toProfile *string
+	plan      util.PlanOpts
+}
+
+func newCopyOpts(cmd *cli.Cmd, what string) copyOpts {
+	opts := copyOpts{
+		name:      cmd.StringArg("NAME", "", "The name or UUID of the "+what+" on the active profile's API"),
+		toProfile: cmd.StringOpt("to-profile", "", "The profile whose API the "+what+" is copied to"),
+		plan:      util.NewPlanOpts(cmd),
+	}
+	cmd.Spec = "[OPTIONS] NAME [OPTIONS]"
+	return opts
+}
+
+// target builds the API client for the profile being copied to
+func (o copyOpts) target() *conch.Conch {
+	if *o.toProfile == "" {
+		util.Bail(errors.New("--to-profile is required"))
+	}
+	if util.ActiveProfile != nil && util.ActiveProfile.Name == *o.toProfile {
+		util.Bail(fmt.Errorf("'%s' is the active profile. Copy to a different one", *o.toProfile))
+	}
+
+	api, err := util.APIForProfile(*o.toProfile)
+	if err != nil {
+		util.Bail(err)
+	}
+	return api
+}
+
+// run shows which API is about to change, then runs the plan
+func (o copyOpts) run(plan *util.Plan, dst *conch.Conch) {
+	if !util.JSON && len(plan.Changes) > 0 {
+		fmt.Printf("Changes to profile '%s' (%s):\n\n", *o.toProfile, dst.BaseURL)
+	}
+	plan.Run(o.plan)
+}
+
+func copyHardwareProduct(cmd *cli.Cmd) {
+	opts := newCopyOpts(cmd, "hardware product")
+	cmd.LongDesc = `
+Copies a hardware product, with its profile and specification, to another
+profile's API. If the product's vendor doesn't exist there, it is created
+too.` + "\n" + copyLongDesc
+
+	cmd.Action = func() {
+		id, err := util.MagicProductID(*opts.name)
+		if err != nil {
+			util.Bail(err)
+		}
+		src, err := util.API.GetHardwareProduct(id)
+		if err != nil {
+			util.Bail(err)
+		}
+		srcVendor, err := util.API.GetHardwareVendorByID(src.HardwareVendorID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		dst := opts.target()
+		plan := util.NewPlan()
+
+		vendors, err := dst.GetHardwareVendors()
+		if err != nil {
+			util.Bail(err)
+		}
+		vendor := &conch.HardwareVendor{Name: srcVendor.Name}
+		for _, v := range vendors {
+			if v.Name == srcVendor.Name {
+				*vendor = v
+				break
+			}
+		}
+		if vendor.ID.IsZero() {
+			plan.Add(util.PlanCreate, "hardware vendor", vendor.Name, "", func() error {
+				return dst.SaveHardwareVendor(vendor)
+			})
+		}
+
+		products, err := dst.GetHardwareProducts()
+		if err != nil {
+			util.Bail(err)
+		}
+		var existing *conch.HardwareProduct
+		for i, p := range products {
+			if p.Name == src.Name {
+				existing = &products[i]
+				break
+			}
+		}
+
+		desired := src
+		desired.ID = uuid.UUID{}
+		desired.Profile.ID = uuid.UUID{}
+		desired.HardwareVendorID = vendor.ID
+
+		if existing == nil {
+			plan.Add(util.PlanCreate, "hardware product", desired.Name, desired.Alias, func() error {
+				// The vendor may have only just been created
+				desired.HardwareVendorID = vendor.ID
+				return dst.SaveHardwareProduct(&desired)
+			})
+		} else {
+			desired.ID = existing.ID
+			desired.Profile.ID = existing.Profile.ID
+
+			fields := util.FieldNames(util.FieldDiff(existing, desired))
+			if len(fields) > 0 {
+				plan.Add(util.PlanUpdate, "hardware product", desired.Name, strings.Join(fields, ", "), func() error {
+					desired.HardwareVendorID = vendor.ID
+					return dst.SaveHardwareProduct(&desired)
+				})
+			}
+		}
+
+		opts.run(plan, dst)
+	}
+}
+
+func copyRackRole(cmd *cli.Cmd) {
+	opts := newCopyOpts(cmd, "rack role")
+	cmd.LongDesc = `
+Copies a rack role to another profile's API.` + "\n" + copyLongDesc
+
+	cmd.Action = func() {
+		id, err := util.MagicRackRoleID(*opts.name)
+		if err != nil {
+			util.Bail(err)
+		}
+		src, err := util.API.GetRackRole(id)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		dst := opts.target()
+		plan := util.NewPlan()
+
+		roles, err := dst.GetRackRoles()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		desired := conch.RackRole{Name: src.Name, RackSize: src.RackSize}
+		found := false
+		for _, r := range roles {
+			if r.Name != src.Name {
+				continue
+			}
+			found = true
+			if r.RackSize != src.RackSize {
+				desired.ID = r.ID
+				detail := fmt.Sprintf("rack_size %d -> %d", r.RackSize, src.RackSize)
+				plan.Add(util.PlanUpdate, "rack role", src.Name, detail, func() error {
+					return dst.SaveRackRole(&desired)
+				})
+			}
+			break
+		}
+		if !found {
+			detail := "rack_size " + strconv.Itoa(src.RackSize)
+			plan.Add(util.PlanCreate, "rack role", src.Name, detail, func() error {
+				return dst.SaveRackRole(&desired)
+			})
+		}
+
+		opts.run(plan, dst)
+	}
+}
+
+func copyValidationPlan(cmd *cli.Cmd) {
+	opts := newCopyOpts(cmd, "validation plan")
+	cmd.LongDesc = `
+Copies a validation plan to another profile's API, and adds to it each of the
+plan's validations that it doesn't already have. Validations are matched by
+name and version, and must already exist on the other API since they are
+part of its code. Validations that the copy has and the original doesn't are
+left alone.` + "\n" + copyLongDesc
+
+	cmd.Action = func() {
+		util.RequireFeature("validation-plans")
+		id, err := util.MagicValidationPlanID(*opts.name)
+		if err != nil {
+			util.Bail(err)
+		}
+		src, err := util.API.GetValidationPlan(id)
+		if err != nil {
+			util.Bail(err)
+		}
+		srcValidations, err := util.API.GetValidationPlanValidations(src.ID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		dst := opts.target()
+		plan := util.NewPlan()
+
+		validationKey := func(v conch.Validation) string {
+			return v.Name + " v" + strconv.Itoa(v.Version)
+		}
+
+		dstValidations, err := dst.GetValidations()
+		if err != nil {
+			util.Bail(err)
+		}
+		known := make(map[string]conch.Validation)
+		for _, v := range dstValidations {
+			known[validationKey(v)] = v
+		}
+
+		missing := make([]string, 0)
+		for _, v := range srcValidations {
+			if _, ok := known[validationKey(v)]; !ok {
+				missing = append(missing, validationKey(v))
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			util.Bail(fmt.Errorf(
+				"profile '%s' doesn't have these validations: %s",
+				*opts.toProfile,
+				strings.Join(missing, ", "),
+			))
+		}
+
+		plans, err := dst.GetValidationPlans()
+		if err != nil {
+			util.Bail(err)
+		}
+		target := &conch.ValidationPlan{Name: src.Name, Description: src.Description}
+		have := make(map[string]bool)
+		for _, p := range plans {
+			if p.Name != src.Name {
+				continue
+			}
+			*target = p
+
+			current, err := dst.GetValidationPlanValidations(p.ID)
+			if err != nil {
+				util.Bail(err)
+			}
+			for _, v := range current {
+				have[validationKey(v)] = true
+			}
+			break
+		}
+
+		if target.ID.IsZero() {
+			plan.Add(util.PlanCreate, "validation plan", target.Name, target.Description, func() error {
+				return dst.CreateValidationPlan(target)
+			})
+		} else if target.Description != src.Description {
+			fmt.Fprintf(
+				os.Stderr,
+				"The description of validation plan '%s' differs on profile '%s' and can't be changed through the API\n",
+				src.Name,
+				*opts.toProfile,
+			)
+		}
+
+		for _, v := range srcValidations {
+			key := validationKey(v)
+			if have[key] {
+				continue
+			}
+			validation := known[key]
+			plan.Add(util.PlanCreate, "plan validation", target.Name, key, func() error {
+				return dst.AddValidationToPlan(target.ID, validation.ID)
+			})
+		}
+
+		opts.run(plan, dst)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package copier contains commands that copy reference data, like hardware
+// products, from the active profile's API to another profile's API
+package copier
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the copy commands
+func Init(app *cli.Cli) {
+	util.Command(
+		app.Cmd,
+		"copy",
+		"Copy reference data from the active profile's API to another profile's API",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+
+			util.Command(
+				cmd,
+				"hardware-product",
+				"Copy a hardware product, and its vendor if need be",
+				copyHardwareProduct,
+			)
+
+			util.Command(
+				cmd,
+				"rack-role",
+				"Copy a rack role",
+				copyRackRole,
+			)
+
+			util.Command(
+				cmd,
+				"validation-plan",
+				"Copy a validation plan and its list of validations",
+				copyValidationPlan,
+			)
+		},
+	)
+}
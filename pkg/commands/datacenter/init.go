@@ -17,19 +17,23 @@ var GdcUUID uuid.UUID
 
 // Init loads up the commands
 func Init(app *cli.Cli) {
+	registerOutputs()
 
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"datacenters dcs",
 		"Operate on all datacenters",
 		func(cmd *cli.Cmd) {
-			cmd.Before = util.BuildAPIAndVerifyLogin
-			cmd.Command(
+			cmd.Before = util.BuildAPIRequiringFeature("datacenters")
+			util.Command(
+				cmd,
 				"get",
 				"Get all datacenters",
 				dcGetAll,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"create",
 				"Create a datacenter",
 				dcCreate,
@@ -37,7 +41,8 @@ func Init(app *cli.Cli) {
 		},
 	)
 
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"datacenter dc",
 		"Operate on individual datacenters",
 		func(cmd *cli.Cmd) {
@@ -46,6 +51,7 @@ func Init(app *cli.Cli) {
 			cmd.Spec = "ID"
 			cmd.Before = func() {
 				util.BuildAPIAndVerifyLogin()
+				util.RequireFeature("datacenters")
 				id, err := util.MagicDatacenterID(*gdcIDStr)
 				if err != nil {
 					util.Bail(err)
@@ -53,35 +59,54 @@ func Init(app *cli.Cli) {
 				GdcUUID = id
 			}
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"get",
 				"Get a datacenter",
 				dcGet,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"delete rm",
 				"Delete a datacenter",
 				dcDelete,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"update",
 				"Update a datacenter",
 				dcUpdate,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"rooms",
 				"Get all rooms assigned to a datacenter",
 				dcGetAllRooms,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"layout-tree",
 				"Get a tree of the datacenter, its rooms, racks, and layouts",
 				dcAllTheThingsTree,
 			)
+
+			util.Command(
+				cmd,
+				"phase",
+				"Change the phase of the datacenter's racks",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"set",
+						"Set the phase of every rack in every room of the datacenter",
+						dcSetPhase,
+					)
+				},
+			)
 		},
 	)
 }
@@ -305,3 +305,40 @@ func dcAllTheThingsTree(app *cli.Cmd) {
 		gotree.PrintTree(tree)
 	}
 }
+
+func dcSetPhase(app *cli.Cmd) {
+	opts := util.NewRackPhaseOpts(app)
+
+	app.LongDesc = `
+Moves every rack in every room of the datacenter to a new phase at once. The
+racks that will change are listed, and the change is only made once
+confirmed. Racks already in the phase are skipped, unless --devices-also is
+given.`
+
+	app.Action = func() {
+		d, err := util.API.GetDatacenter(GdcUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		rooms, err := util.API.GetDatacenterRooms(d)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		racks := make([]conch.Rack, 0)
+		for _, room := range rooms {
+			rs, err := util.API.GetRoomRacks(room)
+			if err != nil {
+				util.Bail(err)
+			}
+			racks = append(racks, rs...)
+		}
+
+		plan := util.NewPlan()
+		if err := util.PlanRackPhases(plan, racks, opts); err != nil {
+			util.Bail(err)
+		}
+		plan.Run(opts.PlanOpts)
+	}
+}
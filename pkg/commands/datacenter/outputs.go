@@ -0,0 +1,21 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package datacenter
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("datacenters get", []conch.Datacenter{})
+	util.RegisterOutput("datacenters create", conch.Datacenter{})
+	util.RegisterOutput("datacenter get", conch.Datacenter{})
+	util.RegisterOutput("datacenter update", conch.Datacenter{})
+	util.RegisterOutput("datacenter rooms", []conch.Room{})
+}
@@ -0,0 +1,275 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// benchResult summarizes every request made to a single endpoint. All
+// durations are in milliseconds.
+type benchResult struct {
+	Endpoint  string  `json:"endpoint"`
+	Path      string  `json:"path"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	LastError string  `json:"last_error,omitempty"`
+	Statuses  []int   `json:"statuses"`
+	Bytes     int     `json:"bytes"`
+	P50       float64 `json:"p50"`
+	P90       float64 `json:"p90"`
+	P99       float64 `json:"p99"`
+	Max       float64 `json:"max"`
+	Network   float64 `json:"mean_network"`
+	Server    float64 `json:"mean_server"`
+	Transfer  float64 `json:"mean_transfer"`
+	Decode    float64 `json:"mean_decode"`
+	NewConns  int     `json:"new_connections"`
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// percentile uses the nearest rank method on already sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// fillPath replaces ':name' segments of an endpoint with values from params
+func fillPath(endpoint string, params map[string]string) (string, error) {
+	segments := strings.Split(endpoint, "/")
+	for i, s := range segments {
+		if !strings.HasPrefix(s, ":") {
+			continue
+		}
+		name := strings.TrimPrefix(s, ":")
+		v, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf(
+				"no value for ':%s' in %s. Use --param %s=VALUE",
+				name,
+				endpoint,
+				name,
+			)
+		}
+		segments[i] = v
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+func benchEndpoint(endpoint string, path string, iterations int) benchResult {
+	r := benchResult{
+		Endpoint: endpoint,
+		Path:     path,
+		Statuses: make([]int, 0),
+	}
+
+	var (
+		totals   = make([]time.Duration, 0, iterations)
+		network  time.Duration
+		server   time.Duration
+		transfer time.Duration
+		decode   time.Duration
+		statuses = make(map[int]bool)
+	)
+
+	for i := 0; i < iterations; i++ {
+		t, err := util.API.TimedGet(path)
+		r.Requests++
+		if err != nil {
+			r.Errors++
+			r.LastError = err.Error()
+			continue
+		}
+		if (t.Status < 200) || (t.Status > 299) {
+			r.Errors++
+			r.LastError = fmt.Sprintf("HTTP %d", t.Status)
+		}
+
+		statuses[t.Status] = true
+		totals = append(totals, t.Total)
+		network += t.DNS + t.Connect + t.TLS
+		server += t.Server
+		transfer += t.Transfer
+		decode += t.Decode
+		r.Bytes = t.Bytes
+		if !t.Reused {
+			r.NewConns++
+		}
+	}
+
+	for s := range statuses {
+		r.Statuses = append(r.Statuses, s)
+	}
+	sort.Ints(r.Statuses)
+
+	if len(totals) == 0 {
+		return r
+	}
+
+	sort.Slice(totals, func(i, j int) bool { return totals[i] < totals[j] })
+	n := time.Duration(len(totals))
+
+	r.P50 = ms(percentile(totals, 50))
+	r.P90 = ms(percentile(totals, 90))
+	r.P99 = ms(percentile(totals, 99))
+	r.Max = ms(totals[len(totals)-1])
+	r.Network = ms(network / n)
+	r.Server = ms(server / n)
+	r.Transfer = ms(transfer / n)
+	r.Decode = ms(decode / n)
+
+	return r
+}
+
+func bench(cmd *cli.Cmd) {
+	var (
+		endpointsOpt  = cmd.StringsOpt("endpoint e", nil, "API path to measure, eg /device/:id. May be given more than once")
+		paramsOpt     = cmd.StringsOpt("param", nil, "Value for a placeholder in the endpoints, as NAME=VALUE, eg id=SERIAL. May be given more than once")
+		iterationsOpt = cmd.IntOpt("iterations n", 50, "Number of requests to make to each endpoint")
+		warmupOpt     = cmd.IntOpt("warmup", 1, "Number of untimed requests to make to each endpoint first")
+	)
+
+	cmd.Spec = "[OPTIONS]"
+
+	cmd.LongDesc = `
+Makes repeated GET requests with the active credentials and reports latency
+percentiles, to help tell whether slowness comes from the API, the network,
+or the shell itself.
+
+Each request is broken down into:
+
+    network   DNS, TCP connect, and TLS handshake. Zero when a connection is reused
+    server    From the request being sent to the first byte of the response.
+              This is the API's own work plus one round trip
+    transfer  Receiving the rest of the response body
+    decode    Parsing the JSON in the shell
+
+Endpoints may contain placeholders like ':id', which are filled in with
+--param. For example:
+
+    conch debug bench --endpoint /device/:id --param id=SERIAL --iterations 50
+
+Only GETs are ever made.`
+
+	cmd.Before = util.BuildAPIAndVerifyLogin
+	cmd.Action = func() {
+		if len(*endpointsOpt) == 0 {
+			util.Bail(errors.New("please provide at least one --endpoint"))
+		}
+		if *iterationsOpt < 1 {
+			util.Bail(errors.New("--iterations must be at least 1"))
+		}
+
+		params := make(map[string]string)
+		for _, p := range *paramsOpt {
+			kv := strings.SplitN(p, "=", 2)
+			if len(kv) != 2 {
+				util.Bail(fmt.Errorf("'%s' is not in the form NAME=VALUE", p))
+			}
+			params[strings.TrimPrefix(kv[0], ":")] = kv[1]
+		}
+
+		paths := make([]string, len(*endpointsOpt))
+		for i, e := range *endpointsOpt {
+			if !strings.HasPrefix(e, "/") {
+				e = "/" + e
+				(*endpointsOpt)[i] = e
+			}
+			path, err := fillPath(e, params)
+			if err != nil {
+				util.Bail(err)
+			}
+			paths[i] = path
+		}
+
+		results := make([]benchResult, 0, len(paths))
+		for i, path := range paths {
+			for w := 0; w < *warmupOpt; w++ {
+				if _, err := util.API.TimedGet(path); err != nil {
+					util.Bail(err)
+				}
+			}
+
+			if !util.JSON {
+				fmt.Fprintf(os.Stderr, "Measuring %s ...\n", path)
+			}
+			results = append(results, benchEndpoint((*endpointsOpt)[i], path, *iterationsOpt))
+		}
+
+		if util.JSON {
+			util.JSONOut(results)
+			return
+		}
+
+		f := func(v float64) string {
+			return strconv.FormatFloat(v, 'f', 1, 64)
+		}
+
+		table := util.GetMarkdownTable()
+		table.SetHeader([]string{
+			"Endpoint",
+			"Requests",
+			"Errors",
+			"p50 ms",
+			"p90 ms",
+			"p99 ms",
+			"Max ms",
+			"Network ms",
+			"Server ms",
+			"Transfer ms",
+			"Decode ms",
+			"Bytes",
+		})
+
+		for _, r := range results {
+			table.Append([]string{
+				r.Endpoint,
+				strconv.Itoa(r.Requests),
+				strconv.Itoa(r.Errors),
+				f(r.P50),
+				f(r.P90),
+				f(r.P99),
+				f(r.Max),
+				f(r.Network),
+				f(r.Server),
+				f(r.Transfer),
+				f(r.Decode),
+				strconv.Itoa(r.Bytes),
+			})
+		}
+		table.Render()
+
+		for _, r := range results {
+			if r.LastError != "" {
+				fmt.Fprintf(os.Stderr, "%s: %d errors, the last being: %s\n", r.Endpoint, r.Errors, r.LastError)
+			}
+		}
+
+		fmt.Println("\nNetwork, server, transfer, and decode times are means. p50 through max cover the whole request.")
+	}
+}
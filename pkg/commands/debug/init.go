@@ -0,0 +1,47 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package debug contains commands for troubleshooting the shell and its
+// connection to the API
+package debug
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the debug commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"debug",
+		"Commands for troubleshooting the shell and the API",
+		func(cmd *cli.Cmd) {
+			util.Command(
+				cmd,
+				"bench",
+				"Measure API latency for one or more endpoints",
+				bench,
+			)
+
+			util.Command(
+				cmd,
+				"record",
+				"Run a conch command and record its API requests and responses in a HAR file",
+				record,
+			)
+
+			util.Command(
+				cmd,
+				"replay",
+				"Run a conch command against the API responses in a HAR file, without touching the API",
+				replay,
+			)
+		},
+	)
+}
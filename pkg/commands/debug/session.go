@@ -0,0 +1,168 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package debug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// runSelf runs this conch with args, plus the options that were given to the
+// record or replay command, and env added to the environment
+func runSelf(args []string, env string) error {
+	full := make([]string, 0)
+	if util.Config != nil && util.Config.Path != "" {
+		full = append(full, "--config", util.Config.Path)
+	}
+	if util.ActiveProfile != nil {
+		full = append(full, "--profile", util.ActiveProfile.Name)
+	}
+	if util.JSON {
+		full = append(full, "--json")
+	}
+	if util.CountOnly {
+		full = append(full, "--count-only")
+	}
+	if util.Summary {
+		full = append(full, "--summary")
+	}
+	full = append(full, args...)
+
+	bin, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	c := exec.Command(bin, full...)
+	c.Env = append(os.Environ(), env)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+func record(cmd *cli.Cmd) {
+	var (
+		outOpt  = cmd.StringOpt("out o", "", "The HAR file to write")
+		argsArg = cmd.StringsArg("ARGS", nil, "The conch command line to record, without the leading 'conch'")
+	)
+	cmd.Spec = "--out -- ARGS..."
+
+	cmd.LongDesc = `
+Runs a conch command and records every API request it makes, and the
+responses, in an HTTP Archive (HAR) file. HAR files can be read by browsers'
+developer tools and by 'conch debug replay', which reruns the command against
+the recording without touching the API.
+
+    conch debug record --out session.har -- devices ls --health fail
+
+--json, --count-only, and --summary given to 'debug record' are passed along to
+the command. Tokens, passwords, and cookies are replaced with REDACTED, so a
+recording can be attached to a bug report. The rest of the data is not: check
+what is in a recording before sharing it.
+
+The file is saved after each request, so a command that fails part way
+through still leaves a recording of what led up to it.`
+
+	cmd.Action = func() {
+		path, err := filepath.Abs(*outOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		// A stale recording must not be appended to
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			util.Bail(err)
+		}
+
+		runErr := runSelf(*argsArg, util.RecordHAREnv+"="+path)
+
+		h, err := conch.LoadHAR(path)
+		if os.IsNotExist(err) {
+			h = conch.NewHAR("conch shell", util.Version)
+		} else if err != nil {
+			util.Bail(err)
+		}
+		h.Log.Args = *argsArg
+
+		b, err := json.MarshalIndent(h, "", "  ")
+		if err != nil {
+			util.Bail(err)
+		}
+		if err := util.WriteFileAtomic(path, append(b, '\n'), 0600); err != nil {
+			util.Bail(err)
+		}
+
+		if !util.JSON {
+			fmt.Fprintf(os.Stderr, "Recorded %d API requests in %s\n", len(h.Log.Entries), path)
+		}
+
+		if runErr != nil {
+			if exit, ok := runErr.(*exec.ExitError); ok {
+				util.Exit(exit.ExitCode())
+			}
+			util.Bail(runErr)
+		}
+	}
+}
+
+func replay(cmd *cli.Cmd) {
+	var (
+		fileArg = cmd.StringArg("FILE", "", "The HAR file written by 'debug record'")
+		argsArg = cmd.StringsArg("ARGS", nil, "The conch command line to run instead of the recorded one, without the leading 'conch'")
+	)
+	cmd.Spec = "FILE [-- ARGS...]"
+
+	cmd.LongDesc = `
+Reruns a command recorded with 'conch debug record', answering its API
+requests from the recording. Nothing is sent to the API, so this works
+offline, and the config file is left alone.
+
+By default the recorded command line is run. Give ARGS to run something else
+against the same responses, eg the same listing with --json:
+
+    conch debug replay session.har -- --json devices ls --health fail
+
+Requests are matched on their method, path, and query; the server is ignored.
+A request that isn't in the recording fails. A profile is still needed, but
+its credentials aren't checked.`
+
+	cmd.Action = func() {
+		path, err := filepath.Abs(*fileArg)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		h, err := conch.LoadHAR(path)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		args := *argsArg
+		if len(args) == 0 {
+			args = h.Log.Args
+		}
+		if len(args) == 0 {
+			util.Bail(errors.New("the recording doesn't say what command was run. Give one after --"))
+		}
+
+		if err := runSelf(args, util.ReplayHAREnv+"="+path); err != nil {
+			if exit, ok := err.(*exec.ExitError); ok {
+				util.Exit(exit.ExitCode())
+			}
+			util.Bail(err)
+		}
+	}
+}
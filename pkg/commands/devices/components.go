@@ -0,0 +1,113 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func getComponents(app *cli.Cmd) {
+	var (
+		kindOpt = app.StringsOpt("kind k", nil, "Only list components of this kind: disk, dimm, psu, or nic. Can be given more than once")
+		sorting = util.SortFlags(app, "components")
+	)
+
+	app.LongDesc = `
+Lists the discrete parts of the device along with their serial numbers and
+firmware. Disks and NICs come from the device itself, and DIMMs and power
+supplies from its latest report, so they are only listed if the report
+carries them. NICs are identified by MAC address.
+
+Use 'conch component find' to go the other way, from a serial number to the
+device that holds it.`
+
+	app.Action = func() {
+		d, err := util.API.GetDevice(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		kinds := make(map[string]bool)
+		for _, k := range *kindOpt {
+			for _, kind := range strings.Split(k, ",") {
+				kinds[strings.ToLower(strings.TrimSpace(kind))] = true
+			}
+		}
+
+		components := make([]conch.Component, 0)
+		for _, c := range d.Components() {
+			if len(kinds) > 0 && !kinds[c.Kind] {
+				continue
+			}
+			components = append(components, c)
+		}
+
+		header := []string{"Kind", "Slot", "Serial", "Vendor", "Model", "Firmware", "Size", "Health"}
+		row := func(i int) []string {
+			c := components[i]
+			return []string{c.Kind, c.Slot, c.Serial, c.Vendor, c.Model, c.Firmware, componentSize(c), c.Health}
+		}
+
+		// Humanized sizes don't sort as text, so the size column is sorted
+		// by its raw value instead
+		sortRow := func(i int) []string {
+			r := row(i)
+			r[6] = componentSortSize(components[i])
+			return r
+		}
+
+		if err := sorting.Sort(components, header, sortRow); err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(components)
+			return
+		}
+
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(components), row, "Kind", "Health"); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+// componentSize makes the size of a disk or DIMM human readable. Power
+// supplies are rated in watts, which are left as they are.
+func componentSize(c conch.Component) string {
+	switch c.Kind {
+	case conch.ComponentDisk:
+		return util.FormatSizeString(c.Size, util.Megabyte)
+	case conch.ComponentDIMM:
+		return util.FormatSizeString(c.Size, util.Gibibyte)
+	}
+	return c.Size
+}
+
+// componentSortSize is the size of a component in bytes, for sorting. Power
+// supplies stay in watts.
+func componentSortSize(c conch.Component) string {
+	var unit int64
+	switch c.Kind {
+	case conch.ComponentDisk:
+		unit = util.Megabyte
+	case conch.ComponentDIMM:
+		unit = util.Gibibyte
+	default:
+		return c.Size
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(c.Size), 10, 64)
+	if err != nil {
+		return c.Size
+	}
+	return strconv.FormatInt(n*unit, 10)
+}
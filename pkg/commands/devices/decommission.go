@@ -0,0 +1,198 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// decommissionLocation is where a device was before it was decommissioned
+type decommissionLocation struct {
+	Datacenter    string    `json:"datacenter,omitempty"`
+	Room          string    `json:"room,omitempty"`
+	AZ            string    `json:"az,omitempty"`
+	RackID        uuid.UUID `json:"rack_id"`
+	Rack          string    `json:"rack,omitempty"`
+	RackUnitStart int       `json:"rack_unit_start,omitempty"`
+}
+
+func (l decommissionLocation) String() string {
+	return fmt.Sprintf("%s / %s / %s / RU %d", l.Datacenter, l.Room, l.Rack, l.RackUnitStart)
+}
+
+// decommissionCertificate is the record handed over for asset disposal
+// audits. Digest is the SHA-256 of the rest of the record, so that a copy can
+// be checked against the original.
+type decommissionCertificate struct {
+	Certificate      string                `json:"certificate"`
+	Serial           string                `json:"serial"`
+	AssetTag         string                `json:"asset_tag,omitempty"`
+	SystemUUID       uuid.UUID             `json:"system_uuid"`
+	HardwareProduct  uuid.UUID             `json:"hardware_product"`
+	PreviousPhase    string                `json:"previous_phase,omitempty"`
+	LastLocation     *decommissionLocation `json:"last_location"`
+	WipedSettings    []string              `json:"wiped_settings"`
+	Note             string                `json:"note,omitempty"`
+	Workspace        string                `json:"workspace,omitempty"`
+	DecommissionedBy string                `json:"decommissioned_by,omitempty"`
+	Decommissioned   time.Time             `json:"decommissioned"`
+	API              string                `json:"api"`
+	Digest           string                `json:"digest,omitempty"`
+}
+
+func (c *decommissionCertificate) sign() error {
+	c.Digest = ""
+	j, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(j)
+	c.Digest = hex.EncodeToString(sum[:])
+	return nil
+}
+
+func decommission(app *cli.Cmd) {
+	var (
+		wipeOpt      = app.BoolOpt("wipe-settings", false, "Delete all of the device's settings. Tags, RMA history, and linked tickets are kept")
+		noteOpt      = app.StringOpt("note", "", "Why the device is being decommissioned")
+		workspaceOpt = app.StringOpt("workspace ws", "", "The workspace whose decommissioned list the device belongs on. Defaults to the workspace in the active profile")
+	)
+
+	app.LongDesc = `
+Takes a device out of service for good:
+
+    1. The device is removed from its rack unit
+    2. With --wipe-settings, all of its settings are deleted, except for
+       its RMA history and linked tickets
+    3. When, by whom, why, and from where it was decommissioned are recorded
+       in its decommission.* settings
+    4. It is moved to the 'decommissioned' phase
+
+A JSON certificate of the decommissioning is then printed, whether or not
+--json is used, for asset disposal audits. 'conch workspace decommissioned'
+lists the devices decommissioned out of a workspace.`
+
+	app.Action = func() {
+		d, err := util.API.GetDevice(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if d.Phase == conch.DecommissionedPhase {
+			util.Bail(fmt.Errorf("device %s is already decommissioned", DeviceSerial))
+		}
+
+		var workspace string
+		if ws, err := util.MagicWorkspaceOrActiveID(*workspaceOpt); err == nil {
+			workspace = ws.String()
+		} else if *workspaceOpt != "" {
+			util.Bail(err)
+		}
+
+		loc, err := util.API.GetDeviceLocation(DeviceSerial)
+		if err != nil && err != conch.ErrDataNotFound {
+			util.Bail(err)
+		}
+
+		cert := decommissionCertificate{
+			Certificate:     "device-decommission",
+			Serial:          DeviceSerial,
+			AssetTag:        d.AssetTag,
+			SystemUUID:      d.SystemUUID,
+			HardwareProduct: d.HardwareProduct,
+			PreviousPhase:   d.Phase,
+			WipedSettings:   make([]string, 0),
+			Note:            *noteOpt,
+			Workspace:       workspace,
+			Decommissioned:  time.Now().UTC().Truncate(time.Second),
+			API:             util.API.BaseURL,
+		}
+		if util.ActiveProfile != nil {
+			cert.DecommissionedBy = util.ActiveProfile.User
+		}
+
+		if !uuid.Equal(loc.Rack.ID, uuid.UUID{}) {
+			cert.LastLocation = &decommissionLocation{
+				Datacenter:    loc.Datacenter.Region,
+				Room:          loc.Room.Alias,
+				AZ:            loc.Room.AZ,
+				RackID:        loc.Rack.ID,
+				Rack:          loc.Rack.Name,
+				RackUnitStart: loc.RackUnitStart,
+			}
+
+			err := util.API.DeleteDevicesFromRackSlots(
+				loc.Rack.ID,
+				conch.RequestRackAssignmentDeletes{{
+					DeviceID:      DeviceSerial,
+					RackUnitStart: loc.RackUnitStart,
+				}},
+			)
+			if err != nil {
+				util.Bail(err)
+			}
+		}
+
+		if *wipeOpt {
+			settings, err := util.API.GetDeviceSettings(DeviceSerial)
+			if err != nil {
+				util.Bail(err)
+			}
+			for k := range settings {
+				if strings.HasPrefix(k, conch.RMASettingPrefix) || strings.HasPrefix(k, conch.TicketSettingPrefix) {
+					continue
+				}
+				cert.WipedSettings = append(cert.WipedSettings, k)
+			}
+			sort.Strings(cert.WipedSettings)
+
+			for _, k := range cert.WipedSettings {
+				if err := util.API.DeleteDeviceSetting(DeviceSerial, k); err != nil {
+					util.Bail(err)
+				}
+			}
+		}
+
+		record := map[string]string{
+			conch.DecommissionDateSetting:      cert.Decommissioned.Format(time.RFC3339),
+			conch.DecommissionUserSetting:      cert.DecommissionedBy,
+			conch.DecommissionNoteSetting:      cert.Note,
+			conch.DecommissionWorkspaceSetting: cert.Workspace,
+		}
+		if cert.LastLocation != nil {
+			record[conch.DecommissionLocationSetting] = cert.LastLocation.String()
+		}
+		for k, v := range record {
+			if v == "" {
+				continue
+			}
+			if err := util.API.SetDeviceSetting(DeviceSerial, k, v); err != nil {
+				util.Bail(err)
+			}
+		}
+
+		if err := util.API.SetDevicePhase(DeviceSerial, conch.DecommissionedPhase); err != nil {
+			util.Bail(err)
+		}
+
+		if err := cert.sign(); err != nil {
+			util.Bail(err)
+		}
+		util.JSONOutIndent(cert)
+	}
+}
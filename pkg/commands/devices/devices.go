@@ -78,7 +78,7 @@ Disks:{{range $name, $slots := .Enclosures}}
         Vendor: {{ .Vendor }}
         Model:  {{ .Model }}
         Transport: {{ .Transport }}
-        Size:   {{ .Size }}
+        Size:   {{ megabytes .Size }}
         Health: {{ .Health }}
         Firmware: {{ .Firmware }}
 {{ end }}{{ end }}{{ end }}
@@ -92,9 +92,21 @@ Validations:{{ range .Validations }}
           Category: {{ .Category }}{{- if len .ComponentID }}
           ComponentID: {{ .ComponentID }}{{ end }}
           Status: {{ .Status }}
-{{ end }}{{ end }}{{ end }}{{ end }}{{ end }}
+{{ end }}{{ end }}{{ end }}{{ end }}{{ end }}{{ if len .Tickets }}
+
+Tickets:{{ range .Tickets }}
+  - {{ .Ticket }}{{ if .Note }}: {{ .Note }}{{ end }}
+    Linked: {{ .Linked.Local }}{{ if .User }} by {{ .User }}{{ end }}
+{{ end }}{{ end }}
 `
 
+// deviceView is what 'device get' shows: the extended device and the tickets
+// linked to it
+type deviceView struct {
+	conch.ExtendedDevice
+	Tickets conch.TicketLinks
+}
+
 func getOne(app *cli.Cmd) {
 	var extended = app.BoolOpt("extended", false, "Only affects JSON output. Alters the device structure to provide better access to disk data and provides access to the most recent validation results")
 	app.Action = func() {
@@ -121,12 +133,17 @@ func getOne(app *cli.Cmd) {
 			util.Bail(err)
 		}
 
-		t, err := template.New("extended_device").Parse(extendedDeviceTemplate)
+		tickets, err := util.API.GetDeviceTickets(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		t, err := template.New("extended_device").Funcs(util.SizeFuncs).Parse(extendedDeviceTemplate)
 		if err != nil {
 			util.Bail(err)
 		}
 
-		if err := t.Execute(os.Stdout, ed); err != nil {
+		if err := t.Execute(os.Stdout, &deviceView{ed, tickets}); err != nil {
 			util.Bail(err)
 		}
 	}
@@ -432,7 +449,13 @@ func deleteTag(app *cli.Cmd) {
 	}
 }
 
-func outputDevices(devices conch.Devices, idsOnly bool, fullOutput bool) {
+func outputDevices(
+	devices conch.Devices,
+	idsOnly bool,
+	fullOutput bool,
+	sorting *util.Sorting,
+	columns *util.FieldColumns,
+) {
 	sort.Sort(devices)
 
 	if idsOnly {
@@ -474,7 +497,7 @@ func outputDevices(devices conch.Devices, idsOnly bool, fullOutput bool) {
 		devices = dLocs
 	}
 
-	if err := util.DisplayDevices(devices, fullOutput); err != nil {
+	if err := util.DisplayDevicesWithFields(devices, fullOutput, sorting, columns); err != nil {
 		util.Bail(err)
 	}
 
@@ -488,6 +511,8 @@ func searchBySetting(app *cli.Cmd) {
 		idsOnly    = app.BoolOpt("ids-only", false, "Only retrieve device IDs")
 		fullOutput = app.BoolOpt("full", false, "When --ids-only is *not* used, provide additional data about the devices rather than normal truncated data. Note: this slows things down immensely")
 	)
+	sorting := util.SortFlags(app, "devices")
+	columns := util.FieldColumnFlags(app)
 
 	app.Spec = "KEY VALUE [OPTIONS]"
 
@@ -499,7 +524,7 @@ func searchBySetting(app *cli.Cmd) {
 		if err != nil {
 			util.Bail(err)
 		}
-		outputDevices(devices, *idsOnly, *fullOutput)
+		outputDevices(devices, *idsOnly, *fullOutput, sorting, columns)
 	}
 }
 
@@ -511,6 +536,8 @@ func searchByTag(app *cli.Cmd) {
 		idsOnly    = app.BoolOpt("ids-only", false, "Only retrieve device IDs")
 		fullOutput = app.BoolOpt("full", false, "When --ids-only is *not* used, provide additional data about the devices rather than normal truncated data. Note: this slows things down immensely")
 	)
+	sorting := util.SortFlags(app, "devices")
+	columns := util.FieldColumnFlags(app)
 
 	app.Spec = "KEY VALUE [OPTIONS]"
 
@@ -522,7 +549,7 @@ func searchByTag(app *cli.Cmd) {
 		if err != nil {
 			util.Bail(err)
 		}
-		outputDevices(devices, *idsOnly, *fullOutput)
+		outputDevices(devices, *idsOnly, *fullOutput, sorting, columns)
 	}
 }
 
@@ -533,6 +560,8 @@ func searchByHostname(app *cli.Cmd) {
 		idsOnly    = app.BoolOpt("ids-only", false, "Only retrieve device IDs")
 		fullOutput = app.BoolOpt("full", false, "When --ids-only is *not* used, provide additional data about the devices rather than normal truncated data. Note: this slows things down immensely")
 	)
+	sorting := util.SortFlags(app, "devices")
+	columns := util.FieldColumnFlags(app)
 
 	app.Spec = "HOSTNAME [OPTIONS]"
 
@@ -544,7 +573,7 @@ func searchByHostname(app *cli.Cmd) {
 		if err != nil {
 			util.Bail(err)
 		}
-		outputDevices(devices, *idsOnly, *fullOutput)
+		outputDevices(devices, *idsOnly, *fullOutput, sorting, columns)
 	}
 }
 
@@ -558,7 +587,9 @@ const validationsTemplate = `
       Status: {{ .Status }}
       Category: {{ .Category }}{{- if len .ComponentID }}
       ComponentID: {{ .ComponentID }}{{ end }}
-      Message: {{ .Message }}
+      Message: {{ .Message }}{{ if ne .Status "pass" }}
+      Checks: {{ .Validation.Description }}{{ if .Runbook }}
+      Runbook: {{ .Runbook }}{{ end }}{{ end }}
 {{ end }}{{ end }}
 `
 
@@ -578,6 +609,7 @@ func getValidationStates(app *cli.Cmd) {
 		type validationResult struct {
 			conch.ValidationResult
 			Validation conch.Validation
+			Runbook    string
 		}
 
 		type resultState struct {
@@ -605,6 +637,7 @@ func getValidationStates(app *cli.Cmd) {
 				betterResults = append(betterResults, validationResult{
 					result,
 					validation,
+					util.RunbookURL(validation.Name, validation.Version),
 				})
 			}
 
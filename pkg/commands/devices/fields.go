@@ -0,0 +1,192 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// deviceField is a custom field of a device, as 'device fields get' lists it
+type deviceField struct {
+	conch.CustomField
+	Value   string `json:"value"`
+	Set     bool   `json:"set"`
+	Problem string `json:"problem,omitempty"`
+}
+
+// fieldsWorkspace is the workspace whose custom fields apply, from
+// --workspace or the active profile
+func fieldsWorkspace(wat string) uuid.UUID {
+	id, err := util.MagicWorkspaceOrActiveID(wat)
+	if err != nil {
+		util.Bail(err)
+	}
+	return id
+}
+
+func definedFieldNames(fields []conch.CustomField) string {
+	if len(fields) == 0 {
+		return "none are defined. See 'conch workspace fields define'"
+	}
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	return "the workspace defines: " + strings.Join(names, ", ")
+}
+
+func getFields(app *cli.Cmd) {
+	var (
+		nameArg      = app.StringArg("NAME", "", "Only print the value of this field")
+		workspaceOpt = app.StringOpt("workspace w", "", "The workspace whose custom fields to use. Defaults to the active profile's")
+	)
+	app.Spec = "[OPTIONS] [NAME] [OPTIONS]"
+
+	app.LongDesc = `
+Lists the custom fields the workspace defines, with this device's value for
+each. Required fields that aren't set, and values that don't fit their field's
+type, are called out. Values set under the field. prefix for fields the
+workspace doesn't define are listed too.`
+
+	app.Action = func() {
+		workspaceID := fieldsWorkspace(*workspaceOpt)
+		defined := util.WorkspaceCustomFields(workspaceID)
+
+		settings, err := util.API.GetDeviceSettings(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+		values := conch.DeviceCustomFields(settings)
+
+		if *nameArg != "" {
+			v, ok := values[*nameArg]
+			if !ok {
+				if _, known := util.WorkspaceCustomField(workspaceID, *nameArg); !known {
+					util.Bail(fmt.Errorf("custom field '%s' is not set, and %s", *nameArg, definedFieldNames(defined)))
+				}
+			}
+			if util.JSON {
+				util.JSONOut(map[string]string{*nameArg: v})
+				return
+			}
+			fmt.Println(v)
+			return
+		}
+
+		fields := make([]deviceField, 0, len(values))
+		seen := make(map[string]bool)
+		for _, f := range defined {
+			seen[f.Name] = true
+			v, set := values[f.Name]
+			df := deviceField{CustomField: f, Value: v, Set: set}
+			if !set {
+				if f.Required {
+					df.Problem = "required, but not set"
+				}
+			} else if _, err := f.Check(v); err != nil {
+				df.Problem = err.Error()
+			}
+			fields = append(fields, df)
+		}
+
+		stray := make([]string, 0)
+		for k := range values {
+			if !seen[k] {
+				stray = append(stray, k)
+			}
+		}
+		sort.Strings(stray)
+		for _, k := range stray {
+			fields = append(fields, deviceField{
+				CustomField: conch.CustomField{Name: k},
+				Value:       values[k],
+				Set:         true,
+				Problem:     "not defined for the workspace",
+			})
+		}
+
+		if util.JSON {
+			util.JSONOut(fields)
+			return
+		}
+
+		if len(fields) == 0 {
+			fmt.Printf("The device has no custom fields, and %s\n", definedFieldNames(defined))
+			return
+		}
+
+		header := []string{"Name", "Type", "Required", "Value", "Problem"}
+		row := func(i int) []string {
+			f := fields[i]
+			required := ""
+			if f.Required {
+				required = "yes"
+			}
+			return []string{f.Name, f.Type, required, f.Value, f.Problem}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(fields), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func setField(app *cli.Cmd) {
+	var (
+		nameArg      = app.StringArg("NAME", "", "The name of the field")
+		valueArg     = app.StringArg("VALUE", "", "The value of the field")
+		clearOpt     = app.BoolOpt("clear", false, "Remove the device's value for the field")
+		workspaceOpt = app.StringOpt("workspace w", "", "The workspace whose custom fields to use. Defaults to the active profile's")
+	)
+	app.Spec = "[OPTIONS] NAME (VALUE | --clear) [OPTIONS]"
+
+	app.LongDesc = `
+Sets the device's value for a custom field that the workspace defines. The
+value has to fit the field's type: numbers, true or false for booleans, and
+dates like 2019-12-31. Required fields can't be cleared.`
+
+	app.Action = func() {
+		workspaceID := fieldsWorkspace(*workspaceOpt)
+
+		f, ok := util.WorkspaceCustomField(workspaceID, *nameArg)
+		if !ok {
+			util.Bail(fmt.Errorf(
+				"custom field '%s' is not defined for the workspace, and %s",
+				*nameArg,
+				definedFieldNames(util.WorkspaceCustomFields(workspaceID)),
+			))
+		}
+
+		value := *valueArg
+		if *clearOpt {
+			value = ""
+		}
+		value, err := f.Check(value)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		setting := conch.CustomFieldSetting(f.Name)
+		if value == "" {
+			err := util.API.DeleteDeviceSetting(DeviceSerial, setting)
+			if err != nil && err != conch.ErrDataNotFound {
+				util.Bail(err)
+			}
+			return
+		}
+
+		if err := util.API.SetDeviceSetting(DeviceSerial, setting, value); err != nil {
+			util.Bail(err)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Outcomes of a report in an archive
+const (
+	importPass      = "pass"
+	importFail      = "fail"
+	importError     = "error"
+	importFailed    = "submit failed"
+	importDuplicate = "duplicate"
+	importInvalid   = "invalid"
+	importDryRun    = "not submitted"
+)
+
+// archivedReport is a device report found in an archive
+type archivedReport struct {
+	file     string
+	serial   string
+	modified time.Time
+	body     []byte
+}
+
+// importResult is what became of one file in an archive
+type importResult struct {
+	File              string    `json:"file"`
+	Serial            string    `json:"serial,omitempty"`
+	Result            string    `json:"result"`
+	ValidationStateID uuid.UUID `json:"validation_state_id"`
+	Detail            string    `json:"detail,omitempty"`
+}
+
+// importSummary is what 'devices report import-archive' prints with --json
+type importSummary struct {
+	Archive    string         `json:"archive"`
+	Files      int            `json:"files"`
+	Submitted  int            `json:"submitted"`
+	Duplicates int            `json:"duplicates"`
+	Invalid    int            `json:"invalid"`
+	Failed     int            `json:"failed"`
+	ByResult   map[string]int `json:"by_result"`
+	Results    []importResult `json:"results"`
+}
+
+// openArchive reads a tar archive, gunzipping it first if it is compressed,
+// whatever it is named
+func openArchive(f *os.File) (*tar.Reader, func(), error) {
+	buffered := bufio.NewReader(f)
+	magic, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(gz), func() { gz.Close() }, nil
+	}
+	return tar.NewReader(buffered), func() {}, nil
+}
+
+// readArchivedReports finds the device reports in an archive. Every regular
+// .json file is expected to be one. Hidden files, like the ._ files macOS
+// leaves on USB sticks, are skipped. Files that aren't reports come back as
+// invalid results.
+func readArchivedReports(archivePath string) ([]archivedReport, []importResult, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	tr, done, err := openArchive(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not a tar archive: %s", archivePath, err)
+	}
+	defer done()
+
+	reports := make([]archivedReport, 0)
+	invalid := make([]importResult, 0)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read archive %s: %s", archivePath, err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		base := path.Base(hdr.Name)
+		if strings.HasPrefix(base, ".") || !strings.HasSuffix(strings.ToLower(base), ".json") {
+			continue
+		}
+
+		body, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s from archive %s: %s", hdr.Name, archivePath, err)
+		}
+
+		report := make(map[string]interface{})
+		if err := json.Unmarshal(body, &report); err != nil {
+			invalid = append(invalid, importResult{
+				File:   hdr.Name,
+				Result: importInvalid,
+				Detail: "not a JSON object: " + err.Error(),
+			})
+			continue
+		}
+		serial, _ := report["serial_number"].(string)
+		if serial == "" {
+			invalid = append(invalid, importResult{
+				File:   hdr.Name,
+				Result: importInvalid,
+				Detail: "the report has no serial_number",
+			})
+			continue
+		}
+
+		reports = append(reports, archivedReport{
+			file:     hdr.Name,
+			serial:   serial,
+			modified: hdr.ModTime,
+			body:     body,
+		})
+	}
+	return reports, invalid, nil
+}
+
+// dedupeReports keeps the newest report of each device, going by when the
+// files were written. Of two written at the same time, the one later in the
+// archive wins. The reports that lose come back as duplicate results.
+func dedupeReports(reports []archivedReport) ([]archivedReport, []importResult) {
+	newest := make(map[string]int)
+	for i, r := range reports {
+		if j, ok := newest[r.serial]; !ok || !r.modified.Before(reports[j].modified) {
+			newest[r.serial] = i
+		}
+	}
+
+	kept := make([]archivedReport, 0, len(newest))
+	dupes := make([]importResult, 0)
+	for i, r := range reports {
+		if newest[r.serial] == i {
+			kept = append(kept, r)
+			continue
+		}
+		dupes = append(dupes, importResult{
+			File:   r.file,
+			Serial: r.serial,
+			Result: importDuplicate,
+			Detail: "a newer report for this device is in " + reports[newest[r.serial]].file,
+		})
+	}
+	return kept, dupes
+}
+
+func importArchive(cmd *cli.Cmd) {
+	var (
+		archiveArg  = cmd.StringArg("ARCHIVE", "", "A tar archive of JSON device reports, gzipped or not")
+		parallelOpt = cmd.IntOpt("parallel P", util.LocationWorkers, "Submit this many reports at a time")
+		dryRunOpt   = cmd.BoolOpt("dry-run", false, "Read the archive and say what would be submitted, without submitting anything")
+	)
+	cmd.Spec = "[OPTIONS] ARCHIVE"
+
+	cmd.LongDesc = `
+Submits every device report in an archive, eg a batch of reports a factory
+sent over on a USB stick, and sums up how they validated.
+
+Every .json file in the archive is taken to be a device report, for the
+device in its serial_number field. When there is more than one report for a
+device, only the newest, going by when the file was written, is submitted.
+Files that can't be read as reports are listed, and skipped.
+
+Reports that fail to submit don't stop the rest. The command exits non-zero if
+any did, so that the archive can be fed through again once the problem is
+sorted out.`
+
+	cmd.Action = func() {
+		if *parallelOpt < 1 {
+			util.Bail(errors.New("--parallel must be at least 1"))
+		}
+
+		reports, invalid, err := readArchivedReports(*archiveArg)
+		if err != nil {
+			util.Bail(err)
+		}
+		files := len(reports) + len(invalid)
+		reports, dupes := dedupeReports(reports)
+
+		results := make([]importResult, len(reports))
+		if *dryRunOpt {
+			for i, r := range reports {
+				results[i] = importResult{File: r.file, Serial: r.serial, Result: importDryRun}
+			}
+		} else {
+			results = submitArchivedReports(reports, *parallelOpt)
+		}
+
+		summary := importSummary{
+			Archive:    *archiveArg,
+			Files:      files,
+			Duplicates: len(dupes),
+			Invalid:    len(invalid),
+			ByResult:   make(map[string]int),
+			Results:    make([]importResult, 0, files),
+		}
+		for _, r := range results {
+			switch r.Result {
+			case importFailed:
+				summary.Failed++
+			case importDryRun:
+			default:
+				summary.Submitted++
+			}
+			summary.ByResult[r.Result]++
+		}
+		summary.Results = append(summary.Results, results...)
+		summary.Results = append(summary.Results, dupes...)
+		summary.Results = append(summary.Results, invalid...)
+		sort.SliceStable(summary.Results, func(i, j int) bool {
+			return summary.Results[i].File < summary.Results[j].File
+		})
+
+		if util.JSON {
+			util.JSONOut(summary)
+		} else {
+			summary.render(*dryRunOpt)
+		}
+
+		if summary.Failed > 0 {
+			util.Exit(1)
+		}
+	}
+}
+
+// submitArchivedReports submits the reports, workers at a time, and says how
+// it is going on STDERR
+func submitArchivedReports(reports []archivedReport, workers int) []importResult {
+	results := make([]importResult, len(reports))
+
+	progress := util.StartProgress("devices report import-archive", len(reports))
+	defer progress.Finish()
+
+	var (
+		mu     sync.Mutex
+		done   int
+		failed int
+	)
+	showProgress := !util.JSON && util.IsTerminal(os.Stderr)
+	tick := func(ok bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if !ok {
+			failed++
+		}
+		if showProgress {
+			fmt.Fprintf(os.Stderr, "\rSubmitted %d of %d reports (%d failed)", done, len(reports), failed)
+		}
+	}
+
+	saved := util.LocationWorkers
+	util.LocationWorkers = workers
+	defer func() { util.LocationWorkers = saved }()
+
+	// Failures are recorded rather than returned, so that one bad report
+	// doesn't stop the rest
+	_ = util.Each(len(reports), fmt.Sprintf("Submitting %d device reports", len(reports)), func(i int) error {
+		r := reports[i]
+		res := importResult{File: r.file, Serial: r.serial}
+
+		state, err := util.API.SubmitDeviceReport(r.serial, string(r.body))
+		if err != nil {
+			res.Result = importFailed
+			res.Detail = err.Error()
+			progress.Fail(r.serial, err)
+		} else {
+			res.Result = state.Status
+			res.ValidationStateID = state.ID
+			progress.Step(r.serial)
+		}
+		results[i] = res
+		tick(err == nil)
+		return nil
+	})
+
+	if showProgress && len(reports) > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	return results
+}
+
+func (s importSummary) render(dryRun bool) {
+	fmt.Printf("Reports in archive: %d\n", s.Files)
+	fmt.Printf("Duplicates skipped: %d\n", s.Duplicates)
+	fmt.Printf("Not device reports: %d\n", s.Invalid)
+	if dryRun {
+		fmt.Printf("Would be submitted: %d\n", s.ByResult[importDryRun])
+		return
+	}
+
+	counts := make([]string, 0)
+	for _, result := range []string{importPass, importFail, importError} {
+		if s.ByResult[result] > 0 {
+			counts = append(counts, fmt.Sprintf("%s: %d", result, s.ByResult[result]))
+		}
+	}
+	if len(counts) > 0 {
+		fmt.Printf("Submitted: %d (%s)\n", s.Submitted, strings.Join(counts, ", "))
+	} else {
+		fmt.Printf("Submitted: %d\n", s.Submitted)
+	}
+	fmt.Printf("Failed to submit: %d\n", s.Failed)
+
+	// Only what needs looking at is listed
+	problems := make([]importResult, 0)
+	for _, r := range s.Results {
+		if r.Result != importPass {
+			problems = append(problems, r)
+		}
+	}
+	if len(problems) == 0 {
+		return
+	}
+
+	fmt.Println()
+	table := util.GetMarkdownTable()
+	table.SetHeader([]string{"File", "Serial", "Result", "Validation State", "Detail"})
+	for _, r := range problems {
+		state := ""
+		if !uuid.Equal(r.ValidationStateID, uuid.UUID{}) {
+			state = r.ValidationStateID.String()
+		}
+		table.Append([]string{r.File, r.Serial, r.Result, state, r.Detail})
+	}
+	table.Render()
+}
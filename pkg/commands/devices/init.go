@@ -8,6 +8,8 @@
 package devices
 
 import (
+	"errors"
+
 	"github.com/jawher/mow.cli"
 	"github.com/joyent/conch-shell/pkg/util"
 )
@@ -24,29 +26,36 @@ var DeviceTagName string
 
 // Init loads up all the device related commands
 func Init(app *cli.Cli) {
-	app.Command(
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
 		"devices ds",
 		"Commands for dealing with multiple devices",
 		func(cmd *cli.Cmd) {
 			cmd.Before = util.BuildAPIAndVerifyLogin
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"search s",
 				"Search for devices",
 				func(cmd *cli.Cmd) {
-					cmd.Command(
+					util.Command(
+						cmd,
 						"setting",
 						"Search for devices by exact setting value",
 						searchBySetting,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"tag",
 						"Search for devices by exact tag value",
 						searchByTag,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"hostname",
 						"Search for devices by exact hostname",
 						searchByHostname,
@@ -54,49 +63,150 @@ func Init(app *cli.Cli) {
 
 				},
 			)
+
+			util.Command(
+				cmd,
+				"report",
+				"Commands for device reports",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"send",
+						"Submit a device report, optionally as a relay would",
+						sendReport,
+					)
+
+					util.Command(
+						cmd,
+						"import-archive",
+						"Submit every device report in a tar archive, newest per device, and sum up how they validated",
+						importArchive,
+					)
+				},
+			)
 		},
 	)
 
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"device d",
 		"Commands for dealing with a single device. The device must be in a workspace to which the user has at least read-only access",
 		func(cmd *cli.Cmd) {
 
-			var deviceSerialStr = cmd.StringArg("ID", "", "The serial of the device")
+			var deviceSerialStr = cmd.StringArg("ID", "", "The serial, hostname, asset tag, or alias of the device")
 
-			cmd.Spec = "ID"
+			cmd.Spec = "[ID]"
 
 			cmd.Before = func() {
 				util.BuildAPIAndVerifyLogin()
 
-				DeviceSerial = *deviceSerialStr
+				if *deviceSerialStr == "" {
+					if !util.CanPick() {
+						util.Bail(errors.New("a device serial, hostname, or asset tag is needed"))
+					}
+					serial, err := util.PickDeviceID()
+					if err != nil {
+						util.Bail(err)
+					}
+					DeviceSerial = serial
+					return
+				}
+
+				serial, err := util.MagicDeviceID(*deviceSerialStr)
+				if err != nil {
+					util.Bail(err)
+				}
+				DeviceSerial = serial
 			}
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"get",
 				"Get the details of a single device",
 				getOne,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"location",
 				"Get the location of a single device",
 				getLocation,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"ipmi",
 				"Get the IPMI address for a single device",
 				getIPMI,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
+				"hostname",
+				"Get/set the host name recorded for a single device",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"get",
+						"Get the device's host name",
+						getHostname,
+					)
+
+					util.Command(
+						cmd,
+						"set",
+						"Record the device's host name",
+						setHostname,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
+				"bmc",
+				"Get/set how to reach a single device's BMC",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"get",
+						"Get the device's BMC address and metadata",
+						getBMC,
+					)
+
+					util.Command(
+						cmd,
+						"set",
+						"Record the device's BMC address and metadata",
+						setBMC,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
 				"settings",
 				"Get the settings for a single device",
-				getSettings,
+				func(cmd *cli.Cmd) {
+					getSettings(cmd)
+
+					util.Command(
+						cmd,
+						"apply-template",
+						"Bring the device's settings in line with its hardware product's settings template",
+						applySettingsTemplate,
+					)
+
+					util.Command(
+						cmd,
+						"diff",
+						"Compare the device's settings with other devices' and list the ones that differ",
+						diffSettings,
+					)
+				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"setting",
 				"Get the value of a single setting for a single device",
 				func(cmd *cli.Cmd) {
@@ -111,19 +221,22 @@ func Init(app *cli.Cli) {
 						DeviceSettingName = *settingNameArg
 					}
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get",
 						"Get a particular device setting",
 						getSetting,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"set",
 						"Set a particular device setting",
 						setSetting,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"delete rm",
 						"Delete a particular device setting",
 						deleteSetting,
@@ -131,24 +244,77 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
+				"fields",
+				"Get/set the custom fields the workspace defines for its devices",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"get",
+						"List the device's custom fields, or get the value of one",
+						getFields,
+					)
+
+					util.Command(
+						cmd,
+						"set",
+						"Set the device's value for a custom field, checked against the field's definition",
+						setField,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
+				"ticket tickets",
+				"Link the device to tickets in an outside tracker",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"list ls",
+						"List the tickets linked to the device",
+						listTickets,
+					)
+
+					util.Command(
+						cmd,
+						"link",
+						"Link a ticket to the device",
+						linkTicket,
+					)
+
+					util.Command(
+						cmd,
+						"unlink",
+						"Remove the link between a ticket and the device",
+						unlinkTicket,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
 				"graduate",
 				"Mark a device as 'graduated'. WARNING: This is a one-way operation that cannot be undone",
 				graduate,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"asset_tag",
 				"Subcommands that deal with asset tags",
 				func(cmd *cli.Cmd) {
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get",
 						"get a device's asset tag",
 						getAssetTag,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"set",
 						"Set a device's asset tag",
 						setAssetTag,
@@ -156,35 +322,155 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"validations",
 				"Show the results of the latest validation runs for this device",
-				getValidationStates,
+				func(cmd *cli.Cmd) {
+					getValidationStates(cmd)
+
+					util.Command(
+						cmd,
+						"history",
+						"List every recorded validation result for this device, newest first",
+						getValidationHistory,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
+				"validation-plan",
+				"Get/set the validation plan that applies to this device",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"get",
+						"Get the device's validation plan",
+						getValidationPlan,
+					)
+
+					util.Command(
+						cmd,
+						"set",
+						"Set the device's validation plan",
+						setValidationPlan,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
+				"replace",
+				"Replace this device with another one in the same rack unit",
+				replaceDevice,
+			)
+
+			util.Command(
+				cmd,
+				"components",
+				"List the disks, DIMMs, power supplies, and NICs in a device, with their serials and firmware",
+				getComponents,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
+				"preflight",
+				"Run the intake checklist against a newly racked device",
+				preflight,
+			)
+
+			util.Command(
+				cmd,
+				"verify",
+				"Compare the hardware the device's BMC reports over Redfish with its latest report and hardware product",
+				verify,
+			)
+
+			util.Command(
+				cmd,
+				"decommission",
+				"Take a device out of service and print a disposal certificate",
+				decommission,
+			)
+
+			util.Command(
+				cmd,
+				"maintenance",
+				"Mark the device as being worked on, so that it doesn't set off alerts",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"start",
+						"Put the device in maintenance for a number of hours",
+						startMaintenance,
+					)
+
+					util.Command(
+						cmd,
+						"stop end",
+						"Take the device out of maintenance before its window ends",
+						stopMaintenance,
+					)
+
+					util.Command(
+						cmd,
+						"status get",
+						"Show the device's maintenance window, if it has one",
+						getMaintenance,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
 				"report",
 				"Get the latest recorded device report as JSON",
 				getReport,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
+				"reports",
+				"List and view the reports the API has stored for this device",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"list ls",
+						"List the stored reports for this device, newest first",
+						listReports,
+					)
+
+					util.Command(
+						cmd,
+						"get",
+						"Show a stored report as a readable summary, or as it was sent",
+						getStoredReport,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
 				"triton",
 				"Subcommands that deal with various Triton related settings",
 				func(cmd *cli.Cmd) {
-					cmd.Command(
+					util.Command(
+						cmd,
 						"reboot",
 						"Mark a device as rebooted into Triton. WARNING: This is a one-way operation that cannot be undone",
 						tritonReboot,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"uuid",
 						"Set the Triton UUID. WARNING: This is a one-way operation that cannot be undone",
 						setTritonUUID,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"setup",
 						"Mark the device as having been setup in Triton. WARNING: This is a one-way operation that cannot be undone",
 						markTritonSetup,
@@ -193,13 +479,15 @@ func Init(app *cli.Cli) {
 			)
 
 			// TAGS
-			cmd.Command(
+			util.Command(
+				cmd,
 				"tags",
 				"Get the tags for a single device",
 				getTags,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"tag",
 				"Get the value of a single tag for a single device",
 				func(cmd *cli.Cmd) {
@@ -214,19 +502,22 @@ func Init(app *cli.Cli) {
 						DeviceTagName = *tagNameArg
 					}
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get",
 						"Get a particular device tag",
 						getTag,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"set",
 						"Set a particular device tag",
 						setTag,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"delete rm",
 						"Delete a particular device tag",
 						deleteTag,
@@ -234,17 +525,20 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"phase",
 				"Get/set the phase for a single device",
 				func(cmd *cli.Cmd) {
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get",
 						"Get the device's phase",
 						getPhase,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"set",
 						"Set the device's phase",
 						setPhase,
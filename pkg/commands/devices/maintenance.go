@@ -0,0 +1,130 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// maintenanceStatus is what 'device maintenance status' prints with --json
+type maintenanceStatus struct {
+	conch.Maintenance
+	Active bool `json:"active"`
+}
+
+func (s maintenanceStatus) render() {
+	state := "ended"
+	if s.Active {
+		state = "in maintenance"
+	} else if time.Now().Before(s.Started) {
+		state = "scheduled"
+	}
+
+	fmt.Printf("Device:  %s\n", s.DeviceID)
+	fmt.Printf("State:   %s\n", state)
+	fmt.Printf("Reason:  %s\n", s.Reason)
+	if s.RackID != "" {
+		fmt.Printf("Rack:    %s\n", s.RackID)
+	}
+	fmt.Printf("Started: %s\n", util.TimeStr(s.Started))
+	fmt.Printf("Until:   %s\n", util.TimeStr(s.Until))
+	if s.User != "" {
+		fmt.Printf("By:      %s\n", s.User)
+	}
+}
+
+func startMaintenance(cmd *cli.Cmd) {
+	var (
+		hoursOpt  = cmd.IntOpt("hours", 4, "How long the device will be in maintenance")
+		reasonOpt = cmd.StringOpt("reason", "", "What is being done to the device, eg 'PSU swap'")
+	)
+	cmd.Spec = "[--hours] --reason"
+
+	cmd.LongDesc = `
+Marks the device as being worked on for the next few hours. While it is,
+'conch status' and 'conch check' leave it out of the failing and stale counts,
+so that it doesn't page anyone, and 'conch workspace devices' notes it.
+Starting maintenance on a device that is already in maintenance replaces the
+window. The window ends by itself, after at most a week; 'device maintenance
+stop' ends it early.`
+
+	cmd.Action = func() {
+		if *hoursOpt < 1 {
+			util.Bail(errors.New("--hours must be at least 1"))
+		}
+		if max := int(conch.MaxMaintenance / time.Hour); *hoursOpt > max {
+			util.Bail(fmt.Errorf("--hours can be at most %d", max))
+		}
+		if strings.TrimSpace(*reasonOpt) == "" {
+			util.Bail(errors.New("please give a --reason"))
+		}
+
+		now := time.Now().UTC()
+		m := conch.Maintenance{
+			DeviceID: DeviceSerial,
+			Started:  now,
+			Until:    now.Add(time.Duration(*hoursOpt) * time.Hour),
+			Reason:   *reasonOpt,
+		}
+		if util.ActiveProfile != nil {
+			m.User = util.ActiveProfile.User
+		}
+
+		if err := util.API.StartDeviceMaintenance(m); err != nil {
+			util.Bail(err)
+		}
+
+		s := maintenanceStatus{m, true}
+		if util.JSON {
+			util.JSONOut(s)
+			return
+		}
+		s.render()
+	}
+}
+
+func stopMaintenance(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		if err := util.API.EndDeviceMaintenance(DeviceSerial); err != nil {
+			util.Bail(err)
+		}
+		if !util.JSON {
+			fmt.Printf("%s is no longer in maintenance\n", DeviceSerial)
+		}
+	}
+}
+
+func getMaintenance(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		m, err := util.API.GetDeviceMaintenance(DeviceSerial)
+		if err == conch.ErrDataNotFound {
+			if util.JSON {
+				util.JSONOut(nil)
+				return
+			}
+			fmt.Printf("%s is not in maintenance\n", DeviceSerial)
+			return
+		}
+		if err != nil {
+			util.Bail(err)
+		}
+
+		s := maintenanceStatus{m, m.Active(time.Now())}
+		if util.JSON {
+			util.JSONOut(s)
+			return
+		}
+		s.render()
+	}
+}
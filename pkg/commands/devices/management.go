@@ -0,0 +1,184 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func getHostname(app *cli.Cmd) {
+	app.LongDesc = `
+Shows the host name the device last reported and the one recorded for it with
+'hostname set', if any. Plain output is the recorded host name when there is
+one, and the reported one otherwise.`
+
+	app.Action = func() {
+		d, err := util.API.GetDevice(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		recorded, err := util.API.GetDeviceSetting(DeviceSerial, conch.SettingHostname)
+		if err != nil && err != conch.ErrDataNotFound {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(map[string]string{
+				"reported": d.Hostname,
+				"recorded": recorded,
+			})
+			return
+		}
+
+		if recorded != "" {
+			fmt.Println(recorded)
+			if d.Hostname != "" && d.Hostname != recorded {
+				fmt.Printf("(the device last reported %s)\n", d.Hostname)
+			}
+			return
+		}
+		fmt.Println(d.Hostname)
+	}
+}
+
+func setHostname(app *cli.Cmd) {
+	var (
+		hostnameArg = app.StringArg("HOSTNAME", "", "The device's host name")
+		clearOpt    = app.BoolOpt("clear", false, "Forget the recorded host name")
+	)
+	app.Spec = "HOSTNAME | --clear"
+
+	app.LongDesc = `
+Records the device's host name in its 'hostname' setting. The host name the
+device reports for itself is left alone.`
+
+	app.Action = func() {
+		if *clearOpt {
+			err := util.API.DeleteDeviceSetting(DeviceSerial, conch.SettingHostname)
+			if err != nil && err != conch.ErrDataNotFound {
+				util.Bail(err)
+			}
+			return
+		}
+
+		if err := conch.ValidHostname(*hostnameArg); err != nil {
+			util.Bail(err)
+		}
+		if err := util.API.SetDeviceSetting(DeviceSerial, conch.SettingHostname, *hostnameArg); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+// bmcInfo is what 'bmc get' prints with --json
+type bmcInfo struct {
+	conch.DeviceBMC
+	AddressSource string `json:"address_source,omitempty"`
+}
+
+func getBMC(app *cli.Cmd) {
+	app.LongDesc = `
+Shows the BMC metadata recorded for the device with 'bmc set'. When no address
+has been recorded, the address of the device's ipmi1 interface is shown
+instead, if it has one.`
+
+	app.Action = func() {
+		b, err := util.API.GetDeviceBMC(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		source := ""
+		if b.Address != "" {
+			source = "settings"
+		} else {
+			ipmi, err := util.API.GetDeviceIPMI(DeviceSerial)
+			if err != nil && err != conch.ErrDataNotFound {
+				util.Bail(err)
+			}
+			if ipmi != "" {
+				b.Address = ipmi
+				source = "ipmi1"
+			}
+		}
+
+		if util.JSON {
+			util.JSONOut(bmcInfo{b, source})
+			return
+		}
+
+		if b.IsEmpty() {
+			util.Bail(errors.New("nothing is known about this device's BMC. Use 'bmc set' to record it"))
+		}
+
+		if b.Address != "" {
+			if source == "settings" {
+				fmt.Printf("Address: %s\n", b.Address)
+			} else {
+				fmt.Printf("Address: %s (from the %s interface)\n", b.Address, source)
+			}
+		}
+		if b.User != "" {
+			fmt.Printf("User: %s\n", b.User)
+		}
+		if b.Vendor != "" {
+			fmt.Printf("Vendor: %s\n", b.Vendor)
+		}
+		if b.MAC != "" {
+			fmt.Printf("MAC: %s\n", b.MAC)
+		}
+	}
+}
+
+func setBMC(app *cli.Cmd) {
+	var (
+		addressOpt = app.StringOpt("address a", "", "The BMC's host name, IP address, or URL")
+		userOpt    = app.StringOpt("user u", "", "The user to log in to the BMC as")
+		vendorOpt  = app.StringOpt("vendor", "", "The BMC's vendor, eg 'supermicro' or 'dell'")
+		macOpt     = app.StringOpt("mac", "", "The MAC address of the BMC's network interface")
+		clearOpt   = app.BoolOpt("clear", false, "Forget everything recorded about the BMC")
+	)
+	app.Spec = "[--address] [--user] [--vendor] [--mac] | --clear"
+
+	app.LongDesc = `
+Records how to reach the device's BMC in its device settings:
+
+    bmc.address, bmc.user, bmc.vendor, bmc.mac
+
+Only the fields given are changed. Passwords are deliberately not stored, as
+device settings can be read by anyone with access to the device. Commands that
+talk to the BMC, like 'device verify', use the recorded address and user.`
+
+	app.Action = func() {
+		if *clearOpt {
+			if err := util.API.ClearDeviceBMC(DeviceSerial); err != nil {
+				util.Bail(err)
+			}
+			return
+		}
+
+		b := conch.DeviceBMC{
+			Address: *addressOpt,
+			User:    *userOpt,
+			Vendor:  *vendorOpt,
+			MAC:     *macOpt,
+		}
+		if b.IsEmpty() {
+			util.Bail(errors.New("nothing to set. Use --address, --user, --vendor, or --mac"))
+		}
+
+		if err := util.API.SetDeviceBMC(DeviceSerial, b); err != nil {
+			util.Bail(err)
+		}
+	}
+}
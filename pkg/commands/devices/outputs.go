@@ -0,0 +1,49 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("devices search setting", []util.BriefDevice{})
+	util.RegisterOutput("devices search tag", []util.BriefDevice{})
+	util.RegisterOutput("devices search hostname", []util.BriefDevice{})
+	util.RegisterOutput("devices report send", conch.ValidationState{})
+	util.RegisterOutput("devices report import-archive", importSummary{})
+
+	util.RegisterOutput("device get", conch.Device{})
+	util.RegisterOutput("device location", conch.DeviceLocation{})
+	util.RegisterOutput("device ipmi", map[string]string{})
+	util.RegisterOutput("device hostname get", map[string]string{})
+	util.RegisterOutput("device bmc get", bmcInfo{})
+	util.RegisterOutput("device settings", map[string]string{})
+	util.RegisterOutput("device settings apply-template", templateApplied{})
+	util.RegisterOutput("device settings diff", []settingDiff{})
+	util.RegisterOutput("device fields get", []deviceField{})
+	util.RegisterOutput("device ticket list", conch.TicketLinks{})
+	util.RegisterOutput("device ticket link", conch.TicketLinks{})
+	util.RegisterOutput("device setting get", map[string]string{})
+	util.RegisterOutput("device validations", []conch.ValidationState{})
+	util.RegisterOutput("device validations history", []historyEntry{})
+	util.RegisterOutput("device validation-plan get", util.ValidationPlanAssignment{})
+	util.RegisterOutput("device validation-plan set", util.ValidationPlanAssignment{})
+	util.RegisterOutput("device replace", []*util.PlanChange{})
+	util.RegisterOutput("device components", []conch.Component{})
+	util.RegisterOutput("device preflight", []preflightCheck{})
+	util.RegisterOutput("device verify", []verifyRow{})
+	util.RegisterOutput("device decommission", decommissionCertificate{})
+	util.RegisterOutput("device maintenance start", maintenanceStatus{})
+	util.RegisterOutput("device maintenance status", maintenanceStatus{})
+	util.RegisterOutput("device reports list", []conch.StoredDeviceReport{})
+	util.RegisterOutput("device reports get", conch.ReportSummary{})
+	util.RegisterOutput("device tags", map[string]string{})
+	util.RegisterOutput("device tag get", map[string]string{})
+}
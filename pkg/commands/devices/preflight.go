@@ -0,0 +1,305 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// preflightCheck is a single item on the intake checklist
+type preflightCheck struct {
+	Check  string `json:"check"`
+	Pass   bool   `json:"pass"`
+	Detail string `json:"detail"`
+}
+
+func preflight(app *cli.Cmd) {
+	var (
+		maxAgeOpt      = app.StringOpt("max-age", "24h", "How recently the device must have sent a report, as a Go duration")
+		validationsOpt = app.StringsOpt("validation v", nil, "Name of a validation that must pass. Can be given more than once. Defaults to every validation in the latest validation states")
+		settingsOpt    = app.StringsOpt("setting s", nil, "Name of a setting the device must have. Can be given more than once")
+	)
+
+	app.LongDesc = `
+Walks through the intake checklist for a newly racked device:
+
+    location          The device is assigned to a rack unit
+    hardware product  The device is the hardware product its rack slot expects
+    report            The device has reported within --max-age
+    validations       The device's latest validation results pass
+    settings          The device has every --setting, and matches its
+                      hardware product's settings template, if there is one
+
+Each item is marked pass or fail. The command exits non-zero if any item
+fails.`
+
+	app.Action = func() {
+		maxAge, err := time.ParseDuration(*maxAgeOpt)
+		if err != nil {
+			util.Bail(fmt.Errorf("--max-age: %s", err))
+		}
+
+		d, err := util.API.GetDevice(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		loc, err := util.API.GetDeviceLocation(DeviceSerial)
+		if err == conch.ErrDataNotFound {
+			err = nil
+		}
+
+		checks := []preflightCheck{
+			checkLocation(loc, err),
+			checkHardwareProduct(d, loc, err),
+			checkReport(d, maxAge),
+			checkValidations(*validationsOpt),
+			checkSettings(d, *settingsOpt),
+		}
+
+		failed := 0
+		for _, c := range checks {
+			if !c.Pass {
+				failed++
+			}
+		}
+
+		if util.JSON {
+			util.JSONOut(checks)
+		} else {
+			header := []string{"Check", "Result", "Detail"}
+			row := func(i int) []string {
+				result := "PASS"
+				if !checks[i].Pass {
+					result = "FAIL"
+				}
+				return []string{checks[i].Check, result, checks[i].Detail}
+			}
+			if err := util.RenderTable(util.GetMarkdownTable(), header, len(checks), row, "Result"); err != nil {
+				util.Bail(err)
+			}
+
+			if failed == 0 {
+				fmt.Printf("\n%s is ready\n", DeviceSerial)
+			} else {
+				fmt.Printf("\n%s failed %d of %d checks\n", DeviceSerial, failed, len(checks))
+			}
+		}
+
+		if failed > 0 {
+			util.Exit(1)
+		}
+	}
+}
+
+func checkLocation(loc conch.DeviceLocation, err error) preflightCheck {
+	c := preflightCheck{Check: "location"}
+
+	if err != nil {
+		c.Detail = err.Error()
+		return c
+	}
+	if uuid.Equal(loc.Rack.ID, uuid.UUID{}) {
+		c.Detail = "not assigned to a rack"
+		return c
+	}
+
+	c.Pass = true
+	c.Detail = fmt.Sprintf(
+		"%s / %s / %s, RU %d",
+		loc.Datacenter.Region,
+		loc.Room.Alias,
+		loc.Rack.Name,
+		loc.RackUnitStart,
+	)
+	return c
+}
+
+func checkHardwareProduct(d conch.Device, loc conch.DeviceLocation, err error) preflightCheck {
+	c := preflightCheck{Check: "hardware product"}
+
+	if err != nil {
+		c.Detail = err.Error()
+		return c
+	}
+	target := loc.TargetHardwareProduct
+	if uuid.Equal(target.ID, uuid.UUID{}) {
+		c.Detail = "no rack slot to compare against"
+		return c
+	}
+
+	if uuid.Equal(d.HardwareProduct, uuid.UUID{}) {
+		c.Detail = fmt.Sprintf("the device has not reported a hardware product. The slot expects %s", target.Name)
+		return c
+	}
+	if !uuid.Equal(d.HardwareProduct, target.ID) {
+		actual := d.HardwareProduct.String()
+		if h, err := util.API.GetHardwareProduct(d.HardwareProduct); err == nil {
+			actual = h.Name
+		}
+		c.Detail = fmt.Sprintf("the device is %s but the slot expects %s", actual, target.Name)
+		return c
+	}
+
+	c.Pass = true
+	c.Detail = target.Name
+	return c
+}
+
+func checkReport(d conch.Device, maxAge time.Duration) preflightCheck {
+	c := preflightCheck{Check: "report"}
+
+	if d.LastSeen.IsZero() {
+		c.Detail = "the device has never reported"
+		return c
+	}
+
+	age := time.Since(d.LastSeen)
+	c.Detail = fmt.Sprintf("last seen %s", util.TimeStr(d.LastSeen))
+	if age > maxAge {
+		c.Detail += fmt.Sprintf(", more than %s ago", maxAge)
+		return c
+	}
+	if d.LatestReportIsInvalid {
+		c.Detail += ", but the latest report was invalid"
+		return c
+	}
+
+	c.Pass = true
+	return c
+}
+
+func checkValidations(required []string) preflightCheck {
+	c := preflightCheck{Check: "validations"}
+
+	states, err := util.API.DeviceValidationStates(DeviceSerial)
+	if err != nil && err != conch.ErrDataNotFound {
+		c.Detail = err.Error()
+		return c
+	}
+	if len(states) == 0 {
+		c.Detail = "the device has never been validated"
+		return c
+	}
+
+	validations, err := util.API.GetValidations()
+	if err != nil {
+		c.Detail = err.Error()
+		return c
+	}
+	names := make(map[uuid.UUID]string)
+	for _, v := range validations {
+		names[v.ID] = v.Name
+	}
+
+	// A validation fails if any of its results in any of the latest states
+	// does not pass
+	passed := make(map[string]bool)
+	for _, state := range states {
+		for _, r := range state.Results {
+			name := names[r.ValidationID]
+			if name == "" {
+				name = r.ValidationID.String()
+			}
+			ok, seen := passed[name]
+			passed[name] = (ok || !seen) && r.Status == "pass"
+		}
+	}
+
+	failing := make([]string, 0)
+	if len(required) == 0 {
+		for name, ok := range passed {
+			if !ok {
+				failing = append(failing, name)
+			}
+		}
+	} else {
+		for _, name := range required {
+			ok, seen := passed[name]
+			if !seen {
+				failing = append(failing, name+" (not run)")
+			} else if !ok {
+				failing = append(failing, name)
+			}
+		}
+	}
+	sort.Strings(failing)
+
+	if len(failing) > 0 {
+		c.Detail = "failing: " + strings.Join(failing, ", ")
+		return c
+	}
+
+	c.Pass = true
+	if len(required) == 0 {
+		c.Detail = fmt.Sprintf("%d validations pass", len(passed))
+	} else {
+		c.Detail = fmt.Sprintf("%d required validations pass", len(required))
+	}
+	return c
+}
+
+func checkSettings(d conch.Device, required []string) preflightCheck {
+	c := preflightCheck{Check: "settings"}
+
+	current, err := util.API.GetDeviceSettings(DeviceSerial)
+	if err != nil && err != conch.ErrDataNotFound {
+		c.Detail = err.Error()
+		return c
+	}
+
+	problems := make([]string, 0)
+	for _, name := range required {
+		if _, ok := current[name]; !ok {
+			problems = append(problems, "missing "+name)
+		}
+	}
+
+	checked := len(required)
+	if !uuid.Equal(d.HardwareProduct, uuid.UUID{}) {
+		tmpl, err := util.API.GetSettingsTemplate(d.HardwareProduct)
+		if err != nil && err != conch.ErrDataNotFound {
+			c.Detail = err.Error()
+			return c
+		}
+		checked += len(tmpl)
+		for _, change := range tmpl.Diff(current) {
+			if change.Action == "add" {
+				problems = append(problems, "missing "+change.Name)
+			} else {
+				problems = append(problems, fmt.Sprintf(
+					"%s is '%s', template says '%s'",
+					change.Name,
+					change.Current,
+					change.Template,
+				))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		c.Detail = strings.Join(problems, "; ")
+		return c
+	}
+	if checked == 0 {
+		c.Pass = true
+		c.Detail = "no settings are required"
+		return c
+	}
+
+	c.Pass = true
+	c.Detail = fmt.Sprintf("%d settings checked", checked)
+	return c
+}
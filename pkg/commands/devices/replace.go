@@ -0,0 +1,158 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func replaceDevice(app *cli.Cmd) {
+	var (
+		newArg          = app.StringArg("NEW", "", "The serial, hostname, asset tag, or alias of the replacement device")
+		decommissionOpt = app.BoolOpt("decommission", false, "Move the old device to the 'decommissioned' phase afterwards")
+		noSettingsOpt   = app.BoolOpt("no-settings", false, "Don't copy the old device's settings")
+		forceOpt        = app.BoolOpt("force", false, "Replace even if the new device is already assigned to a rack")
+		planOpts        = util.NewPlanOpts(app)
+	)
+
+	app.Spec = "NEW [OPTIONS]"
+
+	app.LongDesc = `
+Swaps a failed device for its replacement. The old device is removed from its
+rack unit and the new one is assigned to the same rack unit with the old
+device's asset tag. The old device's settings and phase are then copied to the
+new device. Tags are not copied, and neither are the settings that hold the
+old device's own records, like its RMA history and linked tickets.
+
+The changes are listed, and confirmed, before they are made. If one fails, the
+rest are not attempted and the error says how many were made, so the rest can
+be finished by hand.`
+
+	app.Action = func() {
+		oldSerial := DeviceSerial
+		newSerial, err := util.MagicDeviceID(*newArg)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if oldSerial == newSerial {
+			util.Bail(errors.New("a device can't replace itself"))
+		}
+
+		old, err := util.API.GetDevice(oldSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		loc, err := util.API.GetDeviceLocation(oldSerial)
+		if err != nil && err != conch.ErrDataNotFound {
+			util.Bail(err)
+		}
+		if uuid.Equal(loc.Rack.ID, uuid.UUID{}) {
+			util.Bail(fmt.Errorf("device %s is not assigned to a rack, so there's nothing to replace", oldSerial))
+		}
+
+		// The replacement may never have reported in, in which case the
+		// assignment creates it
+		if !*forceOpt {
+			newLoc, err := util.API.GetDeviceLocation(newSerial)
+			if err != nil && err != conch.ErrDataNotFound {
+				util.Bail(err)
+			}
+			if !uuid.Equal(newLoc.Rack.ID, uuid.UUID{}) {
+				util.Bail(fmt.Errorf(
+					"device %s is already assigned to rack %s, RU %d. Use --force to move it anyway",
+					newSerial,
+					newLoc.Rack.Name,
+					newLoc.RackUnitStart,
+				))
+			}
+		}
+
+		settings := make(map[string]string)
+		existing := make(map[string]string)
+		if !*noSettingsOpt {
+			settings, err = util.API.GetDeviceSettings(oldSerial)
+			if err != nil {
+				util.Bail(err)
+			}
+			existing, err = util.API.GetDeviceSettings(newSerial)
+			if err != nil && err != conch.ErrDataNotFound {
+				util.Bail(err)
+			}
+		}
+		keys := make([]string, 0, len(settings))
+		for k := range settings {
+			// The old device's own records, like its RMA history, stay
+			// with it
+			if conch.IsReservedSetting(k) {
+				continue
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		rackID := loc.Rack.ID
+		ru := loc.RackUnitStart
+		where := fmt.Sprintf("rack %s, RU %d", loc.Rack.Name, ru)
+
+		plan := util.NewPlan()
+		plan.Add(util.PlanDelete, "assignment", oldSerial, where, func() error {
+			return util.API.DeleteDevicesFromRackSlots(
+				rackID,
+				conch.RequestRackAssignmentDeletes{{DeviceID: oldSerial, RackUnitStart: ru}},
+			)
+		})
+		plan.Add(util.PlanCreate, "assignment", newSerial, fmt.Sprintf("%s, asset tag '%s'", where, old.AssetTag), func() error {
+			return util.API.AssignDevicesToRackSlots(
+				rackID,
+				conch.RequestRackAssignmentUpdates{{
+					DeviceID:       newSerial,
+					RackUnitStart:  ru,
+					DeviceAssetTag: old.AssetTag,
+				}},
+			)
+		})
+
+		for _, k := range keys {
+			k := k
+			v := settings[k]
+
+			action := util.PlanCreate
+			if was, ok := existing[k]; ok {
+				if was == v {
+					continue
+				}
+				action = util.PlanUpdate
+			}
+			plan.Add(action, "setting", k, fmt.Sprintf("'%s' on %s", v, newSerial), func() error {
+				return util.API.SetDeviceSetting(newSerial, k, v)
+			})
+		}
+
+		if old.Phase != "" {
+			plan.Add(util.PlanUpdate, "phase", newSerial, old.Phase, func() error {
+				return util.API.SetDevicePhase(newSerial, old.Phase)
+			})
+		}
+
+		if *decommissionOpt {
+			plan.Add(util.PlanUpdate, "phase", oldSerial, conch.DecommissionedPhase, func() error {
+				return util.API.SetDevicePhase(oldSerial, conch.DecommissionedPhase)
+			})
+		}
+
+		plan.Run(planOpts)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func sendReport(cmd *cli.Cmd) {
+	var (
+		filePathArg   = cmd.StringArg("FILE", "-", "Path to a JSON device report. '-' indicates STDIN")
+		serialOpt     = cmd.StringOpt("serial", "", "Serial of the device. Defaults to the serial_number in the report")
+		relayOpt      = cmd.StringOpt("as-relay", "", "Serial of a relay to submit the report through, as that relay would")
+		sshPortOpt    = cmd.IntOpt("relay-ssh-port", 22, "With --as-relay, the SSH port the relay registers with")
+		ipaddrOpt     = cmd.StringOpt("relay-ipaddr", "", "With --as-relay, the IP address the relay registers with")
+		versionOpt    = cmd.StringOpt("relay-version", "conch-shell-"+util.Version, "With --as-relay, the version the relay registers with")
+		noRegisterOpt = cmd.BoolOpt("no-register", false, "With --as-relay, don't register the relay first")
+	)
+
+	cmd.Spec = "[OPTIONS] [FILE]"
+
+	cmd.LongDesc = `
+Submits a device report to the API and shows how it validated. The device
+serial is taken from the serial_number field of the report, just as a relay
+does, unless --serial is given.
+
+With --as-relay, the report is submitted the way a relay would submit it. The
+relay first registers itself, which also refreshes its last seen time, and
+then the relay's serial is recorded in the report so that the API associates
+the device with that relay.`
+
+	cmd.Action = func() {
+		var b []byte
+		var err error
+		if *filePathArg == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(*filePathArg)
+		}
+		if err != nil {
+			util.Bail(err)
+		}
+
+		report := make(map[string]interface{})
+		if err := json.Unmarshal(b, &report); err != nil {
+			util.Bail(fmt.Errorf("the report is not a JSON object: %s", err))
+		}
+
+		serial := *serialOpt
+		if serial == "" {
+			serial, _ = report["serial_number"].(string)
+		}
+		if serial == "" {
+			util.Bail(errors.New("the report has no serial_number. Please provide --serial"))
+		}
+
+		if *relayOpt != "" {
+			if !*noRegisterOpt {
+				err := util.API.RegisterRelay(conch.WorkspaceRelay{
+					ID:      *relayOpt,
+					IPAddr:  *ipaddrOpt,
+					SSHPort: *sshPortOpt,
+					Version: *versionOpt,
+				})
+				if err != nil {
+					util.Bail(fmt.Errorf("could not register relay %s: %s", *relayOpt, err))
+				}
+			}
+
+			report["relay"] = map[string]string{"serial": *relayOpt}
+
+			b, err = json.Marshal(report)
+			if err != nil {
+				util.Bail(err)
+			}
+		}
+
+		state, err := util.API.SubmitDeviceReport(serial, string(b))
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(state)
+			return
+		}
+
+		fmt.Printf(
+			"Device: %s\nStatus: %s\nValidation Plan: %s\nValidation State: %s\n",
+			serial,
+			state.Status,
+			state.ValidationPlanID,
+			state.ID,
+		)
+
+		problems := make([]conch.ValidationResult, 0)
+		for _, r := range state.Results {
+			if r.Status != "pass" {
+				problems = append(problems, r)
+			}
+		}
+		if len(problems) == 0 {
+			return
+		}
+
+		fmt.Println()
+		table := util.GetMarkdownTable()
+		table.SetHeader([]string{"Category", "Component", "Status", "Message", "Hint"})
+		for _, r := range problems {
+			table.Append([]string{
+				r.Category,
+				r.ComponentID,
+				r.Status,
+				r.Message,
+				r.Hint,
+			})
+		}
+		table.Render()
+	}
+}
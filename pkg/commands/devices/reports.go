@@ -0,0 +1,228 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func listReports(cmd *cli.Cmd) {
+	var limitOpt = cmd.IntOpt("limit n", 20, "Number of validation states to look back through. 0 means all of them")
+
+	cmd.LongDesc = `
+Lists the reports the API has stored for the device, newest first, along with
+the worst status of the validation plans that were run against each one. Use
+'conch device ID reports get REPORT_ID' to see what the device said about
+itself in a report.
+
+The API finds reports through the device's validation history, so reports
+that were never validated are not listed.`
+
+	cmd.Action = func() {
+		if *limitOpt < 0 {
+			util.Bail(errors.New("--limit cannot be negative"))
+		}
+
+		reports, err := util.API.GetDeviceReports(DeviceSerial, *limitOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(reports)
+			return
+		}
+
+		header := []string{"ID", "Created", "Status", "Validation Plans"}
+		row := func(i int) []string {
+			r := reports[i]
+			return []string{
+				r.ID.String(),
+				util.TimeStr(r.Created),
+				r.Status,
+				strconv.Itoa(r.Plans),
+			}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(reports), row, "Status"); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func getStoredReport(cmd *cli.Cmd) {
+	var (
+		reportIDArg = cmd.StringArg("REPORT_ID", "", "The ID of the report, as shown by 'reports list', or 'latest' for the device's latest report")
+		rawOpt      = cmd.BoolOpt("raw", false, "Print the report exactly as the device sent it")
+		summaryOpt  = cmd.BoolOpt("summary", false, "Print a readable summary of the report. This is the default")
+	)
+	cmd.Spec = "[OPTIONS] REPORT_ID"
+
+	cmd.LongDesc = `
+Shows a report the API has stored for the device: the system, its CPUs and
+memory, and each DIMM, disk, NIC, and power supply the device listed. With
+--json, the summary is printed as JSON. With --raw, the report is printed as
+the device sent it.`
+
+	cmd.Action = func() {
+		if *rawOpt && *summaryOpt {
+			util.Bail(errors.New("--raw and --summary cannot be used together"))
+		}
+
+		var (
+			raw     []byte
+			id      string
+			created time.Time
+		)
+
+		if strings.EqualFold(*reportIDArg, "latest") {
+			d, err := util.API.GetDevice(DeviceSerial)
+			if err != nil {
+				util.Bail(err)
+			}
+			if d.LatestReport == nil {
+				util.Bail(fmt.Errorf("device %s has not sent a report", DeviceSerial))
+			}
+			raw, err = json.Marshal(d.LatestReport)
+			if err != nil {
+				util.Bail(err)
+			}
+			id = "latest"
+			created = d.LastSeen
+		} else {
+			reportID, err := uuid.FromString(*reportIDArg)
+			if err != nil {
+				util.Bail(fmt.Errorf("'%s' is not a report ID", *reportIDArg))
+			}
+			r, err := util.API.GetDeviceReport(reportID)
+			if err != nil {
+				util.Bail(err)
+			}
+			if r.DeviceID != "" && r.DeviceID != DeviceSerial {
+				util.Bail(fmt.Errorf("report %s belongs to device %s, not %s", r.ID, r.DeviceID, DeviceSerial))
+			}
+			raw = r.Report
+			if len(raw) == 0 && r.InvalidReport != "" {
+				raw = []byte(r.InvalidReport)
+			}
+			id = r.ID.String()
+			created = r.Created
+		}
+
+		if *rawOpt {
+			var out bytes.Buffer
+			if err := json.Indent(&out, raw, "", "  "); err != nil {
+				// Invalid reports are kept as they were sent
+				fmt.Println(string(raw))
+				return
+			}
+			fmt.Println(out.String())
+			return
+		}
+
+		s, err := conch.SummarizeReport(raw)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(s)
+			return
+		}
+
+		printReportSummary(id, created, s)
+	}
+}
+
+// printReportSummary prints a report as a tree of the system and its parts
+func printReportSummary(id string, created time.Time, s conch.ReportSummary) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	line := func(indent int, fields ...string) {
+		fmt.Fprintf(w, "%s%s\n", strings.Repeat("  ", indent), strings.Join(fields, "\t"))
+	}
+	section := func(title string) {
+		w.Flush()
+		fmt.Println()
+		fmt.Println(title)
+	}
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+
+	fmt.Printf("Report %s for %s", id, DeviceSerial)
+	if !created.IsZero() {
+		fmt.Printf(", %s", util.TimeStr(created))
+	}
+	fmt.Println()
+	fmt.Println()
+
+	fmt.Println("System")
+	line(1, "Serial:", orDash(s.SerialNumber))
+	line(1, "Product:", orDash(s.ProductName))
+	line(1, "Hostname:", orDash(s.Hostname))
+	line(1, "System UUID:", orDash(s.SystemUUID))
+	line(1, "BIOS:", orDash(s.BiosVersion))
+	line(1, "Relay:", orDash(s.Relay))
+
+	section("CPU")
+	line(1, fmt.Sprintf("%d x %s", s.CPUCount, orDash(s.CPUType)))
+
+	section(fmt.Sprintf("Memory: %s in %d DIMMs", util.FormatSize(s.MemoryTotal, util.Gibibyte), s.DIMMCount))
+	for _, d := range s.DIMMs {
+		line(1, orDash(d.Slot), d.Serial, orDash(d.Vendor), orDash(d.Model), orDash(util.FormatSizeString(d.Size, util.Gibibyte)))
+	}
+
+	section(fmt.Sprintf("Disks: %d", len(s.Disks)))
+	for _, d := range s.Disks {
+		slot := d.Slot
+		if d.Enclosure != "" {
+			slot = d.Enclosure + ":" + slot
+		}
+		line(1,
+			orDash(slot),
+			d.Serial,
+			orDash(d.DriveType),
+			orDash(strings.TrimSpace(d.Vendor+" "+d.Model)),
+			orDash(util.FormatSizeString(d.Size, util.Megabyte)),
+			orDash(d.Firmware),
+			orDash(d.Health),
+		)
+	}
+
+	section(fmt.Sprintf("NICs: %d", len(s.NICs)))
+	for _, n := range s.NICs {
+		peer := "-"
+		if n.PeerSwitch != "" {
+			peer = "-> " + n.PeerSwitch + " port " + orDash(n.PeerPort)
+		}
+		line(1, n.Name, orDash(n.MAC), orDash(n.IPAddr), orDash(n.State), peer)
+	}
+
+	if len(s.PSUs) > 0 {
+		section(fmt.Sprintf("PSUs: %d", len(s.PSUs)))
+		for _, p := range s.PSUs {
+			line(1, orDash(p.Slot), p.Serial, orDash(p.Model), orDash(p.Firmware), orDash(p.Health))
+		}
+	}
+
+	w.Flush()
+}
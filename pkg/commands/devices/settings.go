@@ -0,0 +1,217 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// templateApplied is what 'settings apply-template' prints with --json
+type templateApplied struct {
+	Device  string                `json:"device"`
+	Product uuid.UUID             `json:"hardware_product"`
+	DryRun  bool                  `json:"dry_run"`
+	Changes []conch.SettingChange `json:"changes"`
+}
+
+func applySettingsTemplate(app *cli.Cmd) {
+	var (
+		productOpt = app.StringOpt("product p", "", "The UUID, name, or SKU of the hardware product whose template to use. Defaults to the device's own hardware product")
+		dryRunOpt  = app.BoolOpt("dry-run", false, "Show what would change without changing anything")
+	)
+
+	app.LongDesc = `
+Sets every setting in the hardware product's settings template that the device
+is missing or has a different value for, and prints what changed. Settings the
+template doesn't mention are left alone.`
+
+	app.Action = func() {
+		var productID uuid.UUID
+		if *productOpt != "" {
+			id, err := util.MagicProductID(*productOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+			productID = id
+		} else {
+			d, err := util.API.GetDevice(DeviceSerial)
+			if err != nil {
+				util.Bail(err)
+			}
+			productID = d.HardwareProduct
+		}
+		if uuid.Equal(productID, uuid.UUID{}) {
+			util.Bail(errors.New("could not determine the device's hardware product. Please provide --product"))
+		}
+
+		tmpl, err := util.API.GetSettingsTemplate(productID)
+		if err != nil {
+			util.Bail(err)
+		}
+		if len(tmpl) == 0 {
+			util.Bail(fmt.Errorf("hardware product %s has no settings template", productID))
+		}
+
+		current, err := util.API.GetDeviceSettings(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		changes := tmpl.Diff(current)
+
+		if !*dryRunOpt {
+			for _, c := range changes {
+				if err := util.API.SetDeviceSetting(DeviceSerial, c.Name, c.Template); err != nil {
+					util.Bail(err)
+				}
+			}
+		}
+
+		if util.JSON {
+			util.JSONOut(templateApplied{DeviceSerial, productID, *dryRunOpt, changes})
+			return
+		}
+
+		if len(changes) == 0 {
+			fmt.Println("The device's settings already match the template")
+			return
+		}
+
+		for _, c := range changes {
+			if c.Action == "add" {
+				fmt.Printf("+ %s : %s\n", c.Name, c.Template)
+			} else {
+				fmt.Printf("~ %s : %s -> %s\n", c.Name, c.Current, c.Template)
+			}
+		}
+		if *dryRunOpt {
+			fmt.Println("\nDry run. No settings were changed")
+		}
+	}
+}
+
+// settingDiff is a setting across several devices. A device that lacks the
+// setting has no entry in Values.
+type settingDiff struct {
+	Key    string            `json:"key"`
+	Same   bool              `json:"same"`
+	Values map[string]string `json:"values"`
+}
+
+func diffSettings(app *cli.Cmd) {
+	var (
+		othersArg = app.StringsArg("OTHER", nil, "The serials, hostnames, or asset tags of the devices to compare with")
+		allOpt    = app.BoolOpt("all a", false, "Also list the settings that are the same on every device")
+		prefixOpt = app.StringOpt("prefix p", "", "Only compare settings whose names start with this")
+	)
+	app.Spec = "[OPTIONS] OTHER... [OPTIONS]"
+
+	app.LongDesc = `
+Lines up the settings of this device and the others, one row per setting, and
+lists the settings whose values differ, eg:
+
+    conch device node-a settings diff node-b node-c
+
+A setting a device doesn't have counts as a difference. With --all, every
+setting is listed and the ones that differ are marked with a *.`
+
+	app.Action = func() {
+		ids := []string{DeviceSerial}
+		seen := map[string]bool{DeviceSerial: true}
+		for _, o := range *othersArg {
+			id, err := util.MagicDeviceID(o)
+			if err != nil {
+				util.Bail(err)
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) < 2 {
+			util.Bail(errors.New("there is nothing to compare the device with"))
+		}
+
+		util.CheckRequestBudget(len(ids), fmt.Sprintf("Comparing the settings of %d devices", len(ids)))
+
+		all := make([]map[string]string, len(ids))
+		for i, id := range ids {
+			s, err := util.API.GetDeviceSettings(id)
+			if err != nil {
+				util.Bail(fmt.Errorf("device %s: %s", id, err))
+			}
+			all[i] = s
+		}
+
+		keys := make(map[string]bool)
+		for _, s := range all {
+			for k := range s {
+				if strings.HasPrefix(k, *prefixOpt) {
+					keys[k] = true
+				}
+			}
+		}
+
+		diffs := make([]settingDiff, 0, len(keys))
+		for k := range keys {
+			d := settingDiff{Key: k, Same: true, Values: make(map[string]string)}
+			for i, s := range all {
+				v, ok := s[k]
+				if ok {
+					d.Values[ids[i]] = v
+				}
+				if !ok || v != all[0][k] {
+					d.Same = false
+				}
+			}
+			if d.Same && !*allOpt {
+				continue
+			}
+			diffs = append(diffs, d)
+		}
+		sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+
+		if util.JSON {
+			util.JSONOut(diffs)
+			return
+		}
+
+		if len(diffs) == 0 {
+			fmt.Println("The devices' settings are the same")
+			return
+		}
+
+		header := append([]string{"Setting"}, ids...)
+		row := func(i int) []string {
+			d := diffs[i]
+			key := d.Key
+			if *allOpt && !d.Same {
+				key = "* " + key
+			}
+			r := []string{key}
+			for _, id := range ids {
+				v, ok := d.Values[id]
+				if !ok {
+					v = "(unset)"
+				}
+				r = append(r, v)
+			}
+			return r
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(diffs), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
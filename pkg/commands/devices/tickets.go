@@ -0,0 +1,107 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func listTickets(app *cli.Cmd) {
+	app.LongDesc = `
+Lists the tickets linked to the device with 'conch device ID ticket link',
+oldest first. Links are kept in the device's ticket.* settings.`
+
+	app.Action = func() {
+		settings, err := util.API.GetDeviceSettings(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+		links, bad := conch.TicketLinksFromSettings(DeviceSerial, settings)
+		for _, k := range bad {
+			fmt.Fprintf(os.Stderr, "Warning: setting %s doesn't hold a ticket link\n", k)
+		}
+
+		if util.JSON {
+			util.JSONOut(links)
+			return
+		}
+
+		if len(links) == 0 {
+			fmt.Println("No tickets are linked to this device")
+			return
+		}
+
+		header := []string{"Ticket", "Linked", "User", "Note"}
+		row := func(i int) []string {
+			l := links[i]
+			return []string{l.Ticket, util.TimeStr(l.Linked), l.User, l.Note}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(links), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func linkTicket(app *cli.Cmd) {
+	var (
+		ticketArg = app.StringArg("TICKET", "", "The ticket, eg JIRA-1234")
+		noteOpt   = app.StringOpt("note n", "", "Why the ticket was linked")
+	)
+	app.Spec = "[OPTIONS] TICKET [OPTIONS]"
+
+	app.LongDesc = `
+Links a ticket in an outside tracker, like JIRA, to the device, eg:
+
+    conch device S1 ticket link JIRA-1234 --note "fails memory tests"
+
+Linking a ticket again replaces its note. Failing devices that have no ticket
+linked are listed by 'conch tickets open'.`
+
+	app.Action = func() {
+		l := conch.TicketLink{
+			DeviceID: DeviceSerial,
+			Ticket:   strings.TrimSpace(*ticketArg),
+			Note:     strings.TrimSpace(*noteOpt),
+		}
+		if util.ActiveProfile != nil {
+			l.User = util.ActiveProfile.User
+		}
+
+		if err := util.API.LinkTicket(l); err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			links, err := util.API.GetDeviceTickets(DeviceSerial)
+			if err != nil {
+				util.Bail(err)
+			}
+			util.JSONOut(links)
+		}
+	}
+}
+
+func unlinkTicket(app *cli.Cmd) {
+	var ticketArg = app.StringArg("TICKET", "", "The ticket, eg JIRA-1234")
+	app.Spec = "TICKET"
+
+	app.Action = func() {
+		err := util.API.UnlinkTicket(DeviceSerial, strings.TrimSpace(*ticketArg))
+		if err == conch.ErrDataNotFound {
+			util.Bail(fmt.Errorf("ticket %s is not linked to device %s", *ticketArg, DeviceSerial))
+		}
+		if err != nil {
+			util.Bail(err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"fmt"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func getValidationPlan(cmd *cli.Cmd) {
+	cmd.LongDesc = `
+Shows the validation plan that applies to the device: the one assigned to the
+device itself, or else the one assigned to its hardware product.`
+
+	cmd.Action = func() {
+		a, err := util.API.GetDeviceValidationPlan(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+		util.DisplayValidationPlanAssignment(a)
+	}
+}
+
+func setValidationPlan(cmd *cli.Cmd) {
+	var (
+		planArg  = cmd.StringArg("PLAN", "", "The UUID or short UUID of the validation plan")
+		clearOpt = cmd.BoolOpt("clear", false, "Remove the device's own plan, so that it goes by its hardware product's")
+	)
+	cmd.Spec = "(PLAN | --clear)"
+
+	cmd.LongDesc = `
+Assigns a validation plan to the device, over the one of its hardware product.
+The plan is kept in the device's validation.plan setting.`
+
+	cmd.Action = func() {
+		vp, err := util.ValidationPlanToAssign(*planArg, *clearOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if err := util.API.SetDeviceValidationPlan(DeviceSerial, vp.ID); err != nil {
+			util.Bail(err)
+		}
+
+		a, err := util.API.GetDeviceValidationPlan(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+		util.DisplayValidationPlanAssignment(a)
+
+		if !util.JSON && !*clearOpt {
+			fmt.Printf(
+				"\nTo check the device's latest report against it:\n  conch device %s report | conch validation-plan %s test %s\n",
+				DeviceSerial,
+				vp.ID,
+				DeviceSerial,
+			)
+		}
+	}
+}
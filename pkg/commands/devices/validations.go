@@ -0,0 +1,134 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// historyEntry is a single validation result, along with when and as part of
+// which validation state it was recorded
+type historyEntry struct {
+	Time       time.Time `json:"time"`
+	StateID    uuid.UUID `json:"validation_state_id"`
+	State      string    `json:"state_status"`
+	Validation string    `json:"validation"`
+	Status     string    `json:"status"`
+	Component  string    `json:"component_id"`
+	Message    string    `json:"message"`
+	Hint       string    `json:"hint"`
+}
+
+func getValidationHistory(cmd *cli.Cmd) {
+	var (
+		validationOpt = cmd.StringOpt("validation v", "", "Only show results for the validation with this name")
+		limitOpt      = cmd.IntOpt("limit n", 20, "Number of validation states to look back through. 0 means all of them")
+		failuresOpt   = cmd.BoolOpt("failures-only f", false, "Only show results that did not pass")
+	)
+
+	cmd.Action = func() {
+		if *limitOpt < 0 {
+			util.Bail(errors.New("--limit cannot be negative"))
+		}
+
+		states, err := util.API.DeviceValidationHistory(DeviceSerial, *limitOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		validations, err := util.API.GetValidations()
+		if err != nil {
+			util.Bail(err)
+		}
+		names := make(map[uuid.UUID]string)
+		for _, v := range validations {
+			names[v.ID] = v.Name
+		}
+
+		if *validationOpt != "" {
+			found := false
+			for _, v := range validations {
+				if v.Name == *validationOpt {
+					found = true
+					break
+				}
+			}
+			if !found {
+				util.Bail(errors.New("Could not find validation " + *validationOpt))
+			}
+		}
+
+		sort.SliceStable(states, func(i, j int) bool {
+			return states[i].Created.After(states[j].Created)
+		})
+		if (*limitOpt > 0) && (len(states) > *limitOpt) {
+			states = states[:*limitOpt]
+		}
+
+		entries := make([]historyEntry, 0)
+		for _, state := range states {
+			when := state.Completed
+			if when.IsZero() {
+				when = state.Created
+			}
+
+			for _, r := range state.Results {
+				name := names[r.ValidationID]
+				if (*validationOpt != "") && (name != *validationOpt) {
+					continue
+				}
+				if *failuresOpt && (r.Status == "pass") {
+					continue
+				}
+
+				entries = append(entries, historyEntry{
+					Time:       when,
+					StateID:    state.ID,
+					State:      state.Status,
+					Validation: name,
+					Status:     r.Status,
+					Component:  r.ComponentID,
+					Message:    r.Message,
+					Hint:       r.Hint,
+				})
+			}
+		}
+
+		if util.JSON {
+			util.JSONOut(entries)
+			return
+		}
+
+		table := util.GetMarkdownTable()
+		table.SetHeader([]string{
+			"Time",
+			"Validation State",
+			"Validation",
+			"Status",
+			"Component",
+			"Message",
+		})
+
+		for _, e := range entries {
+			table.Append([]string{
+				util.TimeStr(e.Time),
+				e.StateID.String(),
+				e.Validation,
+				e.Status,
+				e.Component,
+				e.Message,
+			})
+		}
+		table.Render()
+	}
+}
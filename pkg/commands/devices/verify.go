@@ -0,0 +1,363 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package devices
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// redfishClient fetches resources from a BMC's Redfish service
+type redfishClient struct {
+	base     string
+	user     string
+	password string
+	http     *http.Client
+}
+
+func (r *redfishClient) get(path string) (map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", r.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(r.user, r.password)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := r.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", path, res.Status)
+	}
+
+	var out map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("GET %s: %s", path, err)
+	}
+	return out, nil
+}
+
+// odataID returns the link held in a Redfish reference, eg {"@odata.id": "..."}
+func odataID(v interface{}) string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	id, _ := m["@odata.id"].(string)
+	return id
+}
+
+// expand replaces each reference in a list with the resource it points at
+func (r *redfishClient) expand(list interface{}) ([]interface{}, error) {
+	refs, _ := list.([]interface{})
+	out := make([]interface{}, 0, len(refs))
+	for _, ref := range refs {
+		id := odataID(ref)
+		if id == "" {
+			out = append(out, ref)
+			continue
+		}
+		m, err := r.get(id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// system fetches the first ComputerSystem the BMC knows about, with the
+// collections that a SpecTranslator needs expanded in place
+func (r *redfishClient) system() ([]byte, error) {
+	systems, err := r.get("/redfish/v1/Systems")
+	if err != nil {
+		return nil, err
+	}
+	members, _ := systems["Members"].([]interface{})
+	if len(members) == 0 {
+		return nil, errors.New("the BMC does not list any systems")
+	}
+
+	sys, err := r.get(odataID(members[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{"Memory", "EthernetInterfaces", "Storage", "SimpleStorage"} {
+		id := odataID(sys[name])
+		if id == "" {
+			continue
+		}
+		collection, err := r.get(id)
+		if err != nil {
+			return nil, err
+		}
+		expanded, err := r.expand(collection["Members"])
+		if err != nil {
+			return nil, err
+		}
+
+		if name == "Storage" {
+			for _, s := range expanded {
+				storage, ok := s.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				drives, err := r.expand(storage["Drives"])
+				if err != nil {
+					return nil, err
+				}
+				storage["Drives"] = drives
+			}
+		}
+		sys[name] = expanded
+	}
+
+	// Power supplies hang off the chassis rather than the system
+	if links, ok := sys["Links"].(map[string]interface{}); ok {
+		if chassis, ok := links["Chassis"].([]interface{}); ok && len(chassis) > 0 {
+			c, err := r.get(odataID(chassis[0]))
+			if err != nil {
+				return nil, err
+			}
+			if id := odataID(c["Power"]); id != "" {
+				power, err := r.get(id)
+				if err != nil {
+					return nil, err
+				}
+				sys["PowerSupplies"] = power["PowerSupplies"]
+			}
+		}
+	}
+
+	return json.Marshal(sys)
+}
+
+// verifyRow compares one hardware profile field across the live hardware,
+// the latest report, and the hardware product
+type verifyRow struct {
+	Field    string      `json:"field"`
+	Live     interface{} `json:"live"`
+	Report   interface{} `json:"report"`
+	Expected interface{} `json:"expected"`
+	Match    bool        `json:"match"`
+}
+
+// profileFields returns the JSON form of a profile as a map
+func profileFields(p conch.HardwareProfile) map[string]interface{} {
+	m := make(map[string]interface{})
+	j, _ := json.Marshal(p)
+	_ = json.Unmarshal(j, &m)
+	return m
+}
+
+// verifySizeField is true for disk sizes, which reports don't carry and which
+// vendors round differently, so they are only shown against the product
+func verifySizeField(field string) bool {
+	return strings.HasSuffix(field, "_size")
+}
+
+func verify(app *cli.Cmd) {
+	var (
+		bmcOpt  = app.StringOpt("bmc", "", "Address of the device's BMC. Defaults to the address recorded with 'bmc set', or the device's IPMI address")
+		userOpt = app.StringOpt("redfish-user", "", "Redfish user name. Defaults to the user recorded with 'bmc set', or root")
+		passOpt = app.String(cli.StringOpt{
+			Name:   "redfish-password",
+			Value:  "",
+			Desc:   "Redfish password",
+			EnvVar: "CONCH_REDFISH_PASSWORD",
+		})
+		vendorOpt   = app.StringOpt("vendor", "redfish", "How to read the BMC's inventory. One of: "+strings.Join(conch.SpecTranslators(), ", "))
+		insecureOpt = app.BoolOpt("insecure k", false, "Don't verify the BMC's TLS certificate")
+		timeoutOpt  = app.StringOpt("timeout", "30s", "Timeout for each Redfish request, as a Go duration")
+	)
+
+	app.LongDesc = `
+Reads the device's hardware from its BMC over Redfish and compares it, field by
+field, with the device's latest report and with the hardware profile of its
+hardware product. Any field that doesn't match is flagged, and the command
+exits non-zero.
+
+Disk sizes are only compared against the hardware product.`
+
+	app.Action = func() {
+		timeout, err := time.ParseDuration(*timeoutOpt)
+		if err != nil {
+			util.Bail(fmt.Errorf("--timeout: %s", err))
+		}
+
+		d, err := util.API.GetDevice(DeviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		bmc := *bmcOpt
+		if bmc == "" {
+			bmc, err = util.API.GetDeviceBMCAddress(DeviceSerial)
+			if err != nil {
+				util.Bail(err)
+			}
+			if bmc == "" {
+				util.Bail(errors.New("the device has no BMC or IPMI address on record. Use --bmc"))
+			}
+		}
+
+		user := *userOpt
+		if user == "" {
+			user, err = util.API.GetDeviceSetting(DeviceSerial, conch.SettingBMCUser)
+			if err != nil && err != conch.ErrDataNotFound {
+				util.Bail(err)
+			}
+			if user == "" {
+				user = "root"
+			}
+		}
+		if !strings.Contains(bmc, "://") {
+			bmc = "https://" + bmc
+		}
+
+		rf := &redfishClient{
+			base:     strings.TrimSuffix(bmc, "/"),
+			user:     user,
+			password: *passOpt,
+			http: &http.Client{
+				Timeout: timeout,
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{InsecureSkipVerify: *insecureOpt},
+				},
+			},
+		}
+
+		raw, err := rf.system()
+		if err != nil {
+			util.Bail(err)
+		}
+		live, err := conch.TranslateSpec(*vendorOpt, raw)
+		if err != nil {
+			util.Bail(err)
+		}
+		for _, w := range live.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+
+		var report conch.SpecImport
+		if d.LatestReport != nil {
+			j, err := json.Marshal(d.LatestReport)
+			if err != nil {
+				util.Bail(err)
+			}
+			report, err = conch.TranslateSpec("device-report", j)
+			if err != nil {
+				util.Bail(err)
+			}
+		}
+		inReport := make(map[string]bool)
+		for _, f := range report.Fields {
+			inReport[f] = true
+		}
+
+		var expected map[string]interface{}
+		if !d.HardwareProduct.IsZero() {
+			hp, err := util.API.GetHardwareProduct(d.HardwareProduct)
+			if err != nil {
+				util.Bail(err)
+			}
+			expected = profileFields(hp.Profile)
+		}
+
+		liveFields := profileFields(live.Profile)
+		reportFields := profileFields(report.Profile)
+
+		rows := make([]verifyRow, 0)
+		mismatches := 0
+		for _, f := range live.Fields {
+			row := verifyRow{Field: f, Live: profileValue(liveFields, f), Match: true}
+			value := fmt.Sprintf("%v", row.Live)
+
+			if inReport[f] && !verifySizeField(f) {
+				row.Report = profileValue(reportFields, f)
+				if fmt.Sprintf("%v", row.Report) != value {
+					row.Match = false
+				}
+			}
+
+			if expected != nil {
+				row.Expected = profileValue(expected, f)
+				if fmt.Sprintf("%v", row.Expected) != value {
+					row.Match = false
+				}
+			}
+
+			if !row.Match {
+				mismatches++
+			}
+			rows = append(rows, row)
+		}
+
+		if util.JSON {
+			util.JSONOut(rows)
+		} else {
+			cell := func(v interface{}) string {
+				if v == nil {
+					return ""
+				}
+				return fmt.Sprintf("%v", v)
+			}
+
+			table := util.GetMarkdownTable()
+			table.SetHeader([]string{"Field", "Live", "Report", "Product", "Status"})
+			for _, r := range rows {
+				status := "ok"
+				if !r.Match {
+					status = "MISMATCH"
+				}
+				table.Append([]string{
+					r.Field,
+					cell(r.Live),
+					cell(r.Report),
+					cell(r.Expected),
+					status,
+				})
+			}
+			table.Render()
+
+			if d.LatestReport == nil {
+				fmt.Println("\nThe device has no report on record")
+			}
+			if expected == nil {
+				fmt.Println("\nThe device has no hardware product on record")
+			}
+			fmt.Printf("\n%d of %d fields match\n", len(rows)-mismatches, len(rows))
+		}
+
+		if mismatches > 0 {
+			util.Exit(1)
+		}
+	}
+}
+
+// profileValue returns a field from profileFields. The counts that the API
+// leaves out when they are zero come back as zero.
+func profileValue(fields map[string]interface{}, field string) interface{} {
+	if v, ok := fields[field]; ok {
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,592 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package doctor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// The results a check can have
+const (
+	resultOK   = "OK"
+	resultWarn = "WARN"
+	resultFail = "FAIL"
+	resultSkip = "SKIP"
+)
+
+// credentialWarning is how long before credentials expire that they are
+// called out
+const credentialWarning = 7 * 24 * time.Hour
+
+// Clocks further apart than these from the API's are called out. The API's
+// Date header only has whole seconds.
+const (
+	clockSkewWarning = 30 * time.Second
+	clockSkewFailure = 5 * time.Minute
+)
+
+// doctorCheck is the result of one of the checks 'conch doctor' runs, with
+// what to do about it if it isn't OK
+type doctorCheck struct {
+	Check  string `json:"check"`
+	Result string `json:"result"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// dateRecorder remembers the Date header of the last response that went
+// through it, and when the request went out and the response came back, so
+// that the local clock can be compared to the API's
+type dateRecorder struct {
+	next     http.RoundTripper
+	date     string
+	sent     time.Time
+	received time.Time
+}
+
+func (d *dateRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	sent := time.Now()
+	resp, err := d.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	d.sent = sent
+	d.received = time.Now()
+	d.date = resp.Header.Get("Date")
+	return resp, nil
+}
+
+func doctor(cmd *cli.Cmd) {
+	cmd.LongDesc = `
+Checks the things that most often stop the shell from working, and suggests a
+fix for each problem found:
+
+  - that the config file can only be read by its owner, since it holds
+    credentials, and that it parses and has an active profile
+  - when the profile's login or API token expires
+  - which proxy, if any, requests to the API go through
+  - that the API can be reached, that its version is one this shell works
+    with, and that it accepts the profile's credentials
+  - how far the local clock is from the API's. Login tokens are rejected
+    when the two are too far apart
+  - whether a newer release of the shell is available
+
+Exits 1 if any check fails.`
+
+	cmd.Action = func() {
+		checks := []doctorCheck{
+			checkConfigPermissions(),
+			checkConfig(),
+			checkCredentials(),
+		}
+
+		baseURL := util.BaseURL
+		if !util.IgnoreConfig && util.ActiveProfile != nil {
+			baseURL = util.ActiveProfile.BaseURL
+		}
+
+		proxy, proxyCheck := checkProxy(baseURL)
+		checks = append(checks, proxyCheck)
+		checks = append(checks, checkAPI(baseURL, proxy)...)
+		checks = append(checks, checkRelease())
+
+		failed := 0
+		warned := 0
+		for _, c := range checks {
+			switch c.Result {
+			case resultFail:
+				failed++
+			case resultWarn:
+				warned++
+			}
+		}
+
+		if util.JSON {
+			util.JSONOut(checks)
+		} else {
+			header := []string{"Check", "Result", "Detail"}
+			row := func(i int) []string {
+				return []string{checks[i].Check, checks[i].Result, checks[i].Detail}
+			}
+			if err := util.RenderTable(util.GetMarkdownTable(), header, len(checks), row, "Result"); err != nil {
+				util.Bail(err)
+			}
+
+			if failed+warned == 0 {
+				fmt.Println("\nNo problems found")
+			} else {
+				fmt.Printf("\n%d failed, %d warnings. To fix:\n", failed, warned)
+				for _, c := range checks {
+					if c.Fix != "" {
+						fmt.Printf("  - %s: %s\n", c.Check, c.Fix)
+					}
+				}
+			}
+		}
+
+		if failed > 0 {
+			util.Exit(1)
+		}
+	}
+}
+
+func checkConfigPermissions() doctorCheck {
+	c := doctorCheck{Check: "config permissions"}
+
+	if util.IgnoreConfig {
+		c.Result = resultSkip
+		c.Detail = "the config file isn't used when --profile and --token are both given"
+		return c
+	}
+
+	path := util.Config.Path
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		c.Result = resultFail
+		c.Detail = fmt.Sprintf("there is no config file at %s", path)
+		c.Fix = "Create a profile with 'conch profile create'"
+		return c
+	}
+	if err != nil {
+		c.Result = resultFail
+		c.Detail = err.Error()
+		c.Fix = fmt.Sprintf("Make sure %s can be read", path)
+		return c
+	}
+
+	// Windows doesn't have the same notion of file modes
+	if runtime.GOOS == "windows" {
+		c.Result = resultSkip
+		c.Detail = "file modes aren't checked on Windows"
+		return c
+	}
+
+	mode := fi.Mode().Perm()
+	if mode&0077 != 0 {
+		c.Result = resultWarn
+		c.Detail = fmt.Sprintf("%s holds credentials but can be read by other users (mode %#o)", path, mode)
+		c.Fix = fmt.Sprintf("Run 'chmod 600 %s'", path)
+		return c
+	}
+
+	c.Result = resultOK
+	c.Detail = fmt.Sprintf("%s is only readable by its owner (mode %#o)", path, mode)
+	return c
+}
+
+func checkConfig() doctorCheck {
+	c := doctorCheck{Check: "config"}
+
+	if util.IgnoreConfig {
+		c.Result = resultSkip
+		c.Detail = "the config file isn't used when --profile and --token are both given"
+		return c
+	}
+
+	// The config was read when the shell started, but any error was set
+	// aside so that commands like 'profile create' can start from scratch
+	path := util.Config.Path
+	cfg, err := config.NewFromJSONFile(path)
+	if os.IsNotExist(err) {
+		c.Result = resultSkip
+		c.Detail = "there is no config file"
+		return c
+	}
+	if err != nil {
+		c.Result = resultFail
+		c.Detail = fmt.Sprintf("%s can't be read: %s", path, err)
+		c.Fix = fmt.Sprintf("Fix %s by hand, or move it aside and run 'conch profile create'", path)
+		return c
+	}
+
+	if len(cfg.Profiles) == 0 {
+		c.Result = resultFail
+		c.Detail = "the config has no profiles"
+		c.Fix = "Create a profile with 'conch profile create'"
+		return c
+	}
+
+	active := make([]string, 0)
+	for _, p := range cfg.Profiles {
+		if p.Active {
+			active = append(active, p.Name)
+		}
+	}
+
+	if util.ActiveProfile == nil {
+		c.Result = resultFail
+		c.Detail = fmt.Sprintf("none of the %d profiles is active", len(cfg.Profiles))
+		c.Fix = "Choose a profile with 'conch profile set active NAME'"
+		return c
+	}
+
+	if util.ActiveProfile.BaseURL == "" {
+		c.Result = resultFail
+		c.Detail = fmt.Sprintf("profile '%s' has no API URL", util.ActiveProfile.Name)
+		c.Fix = "Recreate the profile with 'conch profile create'"
+		return c
+	}
+
+	if len(active) > 1 {
+		c.Result = resultWarn
+		c.Detail = fmt.Sprintf("profiles %s are all marked active, and '%s' is being used", strings.Join(active, ", "), util.ActiveProfile.Name)
+		c.Fix = fmt.Sprintf("Run 'conch profile set active %s' to settle on one", util.ActiveProfile.Name)
+		return c
+	}
+
+	c.Result = resultOK
+	c.Detail = fmt.Sprintf(
+		"%d profiles, using '%s' for %s",
+		len(cfg.Profiles),
+		util.ActiveProfile.Name,
+		util.ActiveProfile.BaseURL,
+	)
+	return c
+}
+
+// tokenExpiry reads when an API token expires out of its claims. API tokens
+// are JWTs, but the shell never needs to look inside them otherwise.
+func tokenExpiry(token string) (time.Time, bool) {
+	bits := strings.Split(token, ".")
+	if len(bits) != 3 {
+		return time.Time{}, false
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(bits[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(b, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(claims.Exp), 0), true
+}
+
+func checkCredentials() doctorCheck {
+	c := doctorCheck{Check: "credentials"}
+
+	if util.Token != "" {
+		expires, ok := tokenExpiry(util.Token)
+		if !ok {
+			c.Result = resultOK
+			c.Detail = "using an API token, which doesn't say when it expires"
+			return c
+		}
+
+		left := time.Until(expires)
+		switch {
+		case left <= 0:
+			c.Result = resultFail
+			c.Detail = fmt.Sprintf("the API token expired %s", util.TimeStr(expires))
+			c.Fix = "Create a new token with 'conch user token create' and use it with 'conch profile set token'"
+		case left < credentialWarning:
+			c.Result = resultWarn
+			c.Detail = fmt.Sprintf("the API token expires %s", util.TimeStr(expires))
+			c.Fix = "Create a new token with 'conch user token create' and use it with 'conch profile set token'"
+		default:
+			c.Result = resultOK
+			c.Detail = fmt.Sprintf("using an API token that expires %s", util.TimeStr(expires))
+		}
+		return c
+	}
+
+	if util.ActiveProfile == nil {
+		c.Result = resultSkip
+		c.Detail = "there is no active profile"
+		return c
+	}
+
+	jwt := util.ActiveProfile.JWT
+	if jwt.Token == "" {
+		c.Result = resultFail
+		c.Detail = fmt.Sprintf("profile '%s' has neither a login nor an API token", util.ActiveProfile.Name)
+		c.Fix = "Log in with 'conch profile relogin'"
+		return c
+	}
+
+	if jwt.Expires.IsZero() {
+		c.Result = resultOK
+		c.Detail = "logged in, with no expiry given"
+		return c
+	}
+
+	left := time.Until(jwt.Expires)
+	switch {
+	case left <= 0:
+		c.Result = resultFail
+		c.Detail = fmt.Sprintf("the login expired %s", util.TimeStr(jwt.Expires))
+		c.Fix = "Log in again with 'conch profile relogin'"
+	case left < time.Duration(util.RefreshTokenTime)*time.Second:
+		c.Result = resultOK
+		c.Detail = fmt.Sprintf("the login expires %s, and will be renewed the next time it is used", util.TimeStr(jwt.Expires))
+	default:
+		c.Result = resultOK
+		c.Detail = fmt.Sprintf("the login expires %s", util.TimeStr(jwt.Expires))
+	}
+	return c
+}
+
+// proxyEnvironment lists the proxy variables that are set, as Go's HTTP client
+// sees them
+func proxyEnvironment() []string {
+	set := make([]string, 0)
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy"} {
+		if v := os.Getenv(name); v != "" {
+			set = append(set, fmt.Sprintf("%s=%s", name, v))
+		}
+	}
+	return set
+}
+
+// proxyName is a proxy's URL without any password in it
+func proxyName(proxy *url.URL) string {
+	return proxy.Scheme + "://" + proxy.Host
+}
+
+// checkProxy works out which proxy, if any, requests to the API go through
+func checkProxy(baseURL string) (*url.URL, doctorCheck) {
+	c := doctorCheck{Check: "proxy"}
+
+	if baseURL == "" {
+		c.Result = resultSkip
+		c.Detail = "there is no API URL to check against"
+		return nil, c
+	}
+
+	req, err := http.NewRequest("GET", baseURL, nil)
+	if err != nil {
+		c.Result = resultFail
+		c.Detail = fmt.Sprintf("the API URL '%s' is no good: %s", baseURL, err)
+		c.Fix = "Recreate the profile with 'conch profile create'"
+		return nil, c
+	}
+
+	env := proxyEnvironment()
+	proxy, err := http.ProxyFromEnvironment(req)
+	if err != nil {
+		c.Result = resultFail
+		c.Detail = fmt.Sprintf("the proxy settings can't be used: %s", err)
+		c.Fix = "Fix or unset " + strings.Join(env, ", ")
+		return nil, c
+	}
+
+	c.Result = resultOK
+	switch {
+	case proxy != nil:
+		c.Detail = fmt.Sprintf("requests to the API go through %s", proxyName(proxy))
+	case len(env) > 0:
+		c.Detail = fmt.Sprintf("requests to the API don't go through a proxy, given %s", strings.Join(env, ", "))
+	default:
+		c.Detail = "no proxy is set"
+	}
+	return proxy, c
+}
+
+// checkAPI checks that the API can be reached, is a version the shell works
+// with, and takes the profile's credentials. The clock is compared to the
+// API's along the way.
+func checkAPI(baseURL string, proxy *url.URL) []doctorCheck {
+	reach := doctorCheck{Check: "API reachable"}
+	version := doctorCheck{Check: "API version"}
+	login := doctorCheck{Check: "API login"}
+	clock := doctorCheck{Check: "clock"}
+	checks := func() []doctorCheck {
+		return []doctorCheck{reach, version, login, clock}
+	}
+
+	skipAll := func(why string) []doctorCheck {
+		for _, c := range []*doctorCheck{&reach, &version, &login, &clock} {
+			c.Result = resultSkip
+			c.Detail = why
+		}
+		return checks()
+	}
+
+	if baseURL == "" {
+		return skipAll("there is no API URL to check against")
+	}
+
+	recorder := &dateRecorder{next: conch.DefaultTransport()}
+	api := &conch.Conch{
+		BaseURL:       baseURL,
+		Token:         util.Token,
+		Debug:         util.Debug,
+		Trace:         util.Trace,
+		NoCompression: util.NoCompression,
+		Transport:     recorder,
+	}
+	if !util.IgnoreConfig && util.ActiveProfile != nil {
+		api.JWT = util.ActiveProfile.JWT
+	}
+	if util.UserAgent != "" {
+		api.UA = util.UserAgent
+	}
+
+	start := time.Now()
+	v, err := api.GetVersion()
+	took := time.Since(start)
+	if err != nil {
+		skipAll("the API can't be reached")
+		reach.Result = resultFail
+		reach.Detail = fmt.Sprintf("%s can't be reached: %s", baseURL, err)
+		if proxy != nil {
+			reach.Fix = fmt.Sprintf("Make sure the proxy at %s is up and lets requests to %s through", proxyName(proxy), baseURL)
+		} else {
+			reach.Fix = fmt.Sprintf("Check the network or VPN, and that %s is the right API URL with 'conch profile list'", baseURL)
+		}
+		return checks()
+	}
+	util.APIServerVersion = v
+
+	reach.Result = resultOK
+	reach.Detail = fmt.Sprintf("%s answered in %s", baseURL, took.Round(time.Millisecond))
+
+	clock = checkClock(recorder)
+
+	// CheckAPIVersion names the API it is complaining about
+	util.API = api
+	switch {
+	case util.DisableApiVersionCheck():
+		version.Result = resultWarn
+		version.Detail = fmt.Sprintf("the API is version %s, but this build of the shell doesn't check API versions", v)
+		version.Fix = "Use a release build of the shell, from 'conch update self'"
+	case util.SkipVersionCheck:
+		version.Result = resultWarn
+		version.Detail = fmt.Sprintf("the API is version %s, but version checking is disabled", v)
+		version.Fix = "Drop --skip-version-check, or unset CONCH_SKIP_VERSION_CHECK, unless the API is known to work with this shell"
+	default:
+		if err := util.CheckAPIVersion(v); err != nil {
+			version.Result = resultFail
+			version.Detail = err.Error()
+			version.Fix = "Run 'conch update self' for a shell that works with this API, or change the profile's range with 'conch profile set api-version'"
+		} else {
+			version.Result = resultOK
+			version.Detail = fmt.Sprintf("the API is version %s, within %s", v, util.APIVersionConstraint())
+		}
+	}
+
+	if api.Token == "" && api.JWT.Token == "" {
+		login.Result = resultSkip
+		login.Detail = "there are no credentials to try"
+		return checks()
+	}
+
+	if _, err := api.GetUserSettings(); err != nil {
+		login.Result = resultFail
+		login.Detail = err.Error()
+		if err == conch.ErrNotAuthorized {
+			if api.Token != "" {
+				login.Detail = "the API token was turned down. It might be incorrect or revoked"
+				login.Fix = "Create a new token with 'conch user token create' and use it with 'conch profile set token'"
+			} else {
+				login.Detail = "the login was turned down"
+				login.Fix = "Log in again with 'conch profile relogin'"
+			}
+		}
+		return checks()
+	}
+
+	login.Result = resultOK
+	if util.ActiveProfile != nil && util.ActiveProfile.User != "" {
+		login.Detail = fmt.Sprintf("logged in as %s", util.ActiveProfile.User)
+	} else {
+		login.Detail = "the credentials were accepted"
+	}
+	return checks()
+}
+
+// checkClock compares the local clock to the time in the Date header of a
+// response from the API, taking the middle of the request as the local time
+func checkClock(recorder *dateRecorder) doctorCheck {
+	c := doctorCheck{Check: "clock"}
+
+	if recorder.date == "" {
+		c.Result = resultSkip
+		c.Detail = "the API didn't send the time"
+		return c
+	}
+
+	server, err := http.ParseTime(recorder.date)
+	if err != nil {
+		c.Result = resultSkip
+		c.Detail = fmt.Sprintf("the API sent a time of '%s', which isn't understood", recorder.date)
+		return c
+	}
+
+	local := recorder.sent.Add(recorder.received.Sub(recorder.sent) / 2)
+	skew := local.Sub(server)
+	size := skew
+	if size < 0 {
+		size = -size
+	}
+
+	direction := "ahead of"
+	if skew < 0 {
+		direction = "behind"
+	}
+	detail := fmt.Sprintf("the local clock is %s %s the API's", size.Round(time.Second), direction)
+
+	switch {
+	case size > clockSkewFailure:
+		c.Result = resultFail
+		c.Detail = detail + ". Logins can be turned down as expired or not yet valid"
+		c.Fix = "Set the clock, eg by turning on NTP"
+	case size > clockSkewWarning:
+		c.Result = resultWarn
+		c.Detail = detail
+		c.Fix = "Set the clock, eg by turning on NTP"
+	default:
+		c.Result = resultOK
+		c.Detail = fmt.Sprintf("the local clock is within %s of the API's", clockSkewWarning)
+	}
+	return c
+}
+
+func checkRelease() doctorCheck {
+	c := doctorCheck{Check: "shell version"}
+
+	gh, err := util.LatestGithubRelease()
+	if err == util.ErrNoGithubRelease {
+		c.Result = resultOK
+		c.Detail = fmt.Sprintf("this is v%s, and no releases were found on the %s channel", util.Version, util.UpdateChannel())
+		return c
+	}
+	if err != nil {
+		c.Result = resultWarn
+		c.Detail = fmt.Sprintf("the latest release couldn't be looked up: %s", err)
+		c.Fix = "Make sure api.github.com can be reached"
+		return c
+	}
+
+	if gh.Upgrade {
+		c.Result = resultWarn
+		c.Detail = fmt.Sprintf("this is v%s, and %s is available", util.Version, gh.TagName)
+		c.Fix = "Upgrade with 'conch update self'. 'conch update changelog' shows what changed"
+		return c
+	}
+
+	c.Result = resultOK
+	c.Detail = fmt.Sprintf("v%s is the latest release", util.Version)
+	return c
+}
@@ -0,0 +1,26 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package doctor contains a command that looks for the problems with the
+// local setup that most often stop the shell from working
+package doctor
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the doctor command
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"doctor",
+		"Check the config, credentials, network, clock, and shell version for common problems, and suggest fixes",
+		doctor,
+	)
+}
@@ -0,0 +1,42 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package events contains commands that report changes in the fleet as they
+// happen
+package events
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the event commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"events",
+		"Commands for watching changes to devices",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+
+			util.Command(
+				cmd,
+				"watch",
+				"Emit a JSON line for every change to the devices in a workspace",
+				watch,
+			)
+
+			util.Command(
+				cmd,
+				"types",
+				"List the event types that can be watched",
+				types,
+			)
+		},
+	)
+}
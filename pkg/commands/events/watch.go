@@ -0,0 +1,218 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Event types. The API does not provide an event feed, so these are derived
+// by comparing successive device listings.
+const (
+	DeviceAdded         = "device.added"
+	DeviceRemoved       = "device.removed"
+	DeviceHealthChanged = "device.health_changed"
+	DevicePhaseChanged  = "device.phase_changed"
+	DeviceRackChanged   = "device.rack_changed"
+	DeviceGraduated     = "device.graduated"
+	DeviceValidated     = "device.validated"
+	DeviceAssetTagSet   = "device.asset_tag_changed"
+	DeviceHostnameSet   = "device.hostname_changed"
+)
+
+var eventTypes = []string{
+	DeviceAdded,
+	DeviceRemoved,
+	DeviceHealthChanged,
+	DevicePhaseChanged,
+	DeviceRackChanged,
+	DeviceGraduated,
+	DeviceValidated,
+	DeviceAssetTagSet,
+	DeviceHostnameSet,
+}
+
+// Event is a single change to a device, as written to STDOUT
+type Event struct {
+	Type        string      `json:"type"`
+	Time        time.Time   `json:"time"`
+	WorkspaceID uuid.UUID   `json:"workspace_id"`
+	DeviceID    string      `json:"device_id"`
+	Old         interface{} `json:"old,omitempty"`
+	New         interface{} `json:"new,omitempty"`
+}
+
+func types(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		if util.JSON {
+			util.JSONOut(eventTypes)
+			return
+		}
+		fmt.Println(strings.Join(eventTypes, "\n"))
+	}
+}
+
+func watch(cmd *cli.Cmd) {
+	var (
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+		typeOpt      = cmd.StringsOpt("type t", nil, "Only emit events of this type. Can be repeated. See 'conch events types'")
+		intervalOpt  = cmd.StringOpt("interval", "1m", "How often to poll the API. Uses Go duration syntax")
+		initialOpt   = cmd.BoolOpt("initial", false, "Emit a device.added event for every device on the first poll")
+	)
+
+	cmd.LongDesc = `
+Polls the device list for a workspace and writes one JSON object per line to
+STDOUT for each change between polls. Errors are written to STDERR and polling
+continues.`
+
+	cmd.Action = func() {
+		interval, err := time.ParseDuration(*intervalOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		wanted := make(map[string]bool)
+		for _, t := range *typeOpt {
+			known := false
+			for _, e := range eventTypes {
+				if t == e {
+					known = true
+					break
+				}
+			}
+			if !known {
+				util.Bail(fmt.Errorf("unknown event type '%s'", t))
+			}
+			wanted[t] = true
+		}
+
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		emit := func(e Event) {
+			if len(wanted) > 0 && !wanted[e.Type] {
+				return
+			}
+			e.WorkspaceID = workspaceID
+			if err := enc.Encode(e); err != nil {
+				util.Bail(err)
+			}
+		}
+
+		var previous map[string]conch.Device
+		if !*initialOpt {
+			previous, err = snapshot(workspaceID)
+			if err != nil {
+				util.Bail(err)
+			}
+			time.Sleep(interval)
+		}
+
+		for {
+			current, err := snapshot(workspaceID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error polling workspace %s: %s\n", workspaceID, err)
+			} else {
+				for _, e := range diff(previous, current, time.Now()) {
+					emit(e)
+				}
+				previous = current
+			}
+
+			time.Sleep(interval)
+		}
+	}
+}
+
+func snapshot(workspaceID uuid.UUID) (map[string]conch.Device, error) {
+	devices, err := util.API.GetWorkspaceDevices(workspaceID, false, "", "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	s := make(map[string]conch.Device)
+	for _, d := range devices {
+		s[d.ID] = d
+	}
+	return s, nil
+}
+
+// diff compares two snapshots and returns the resulting events, ordered by
+// device ID
+func diff(previous, current map[string]conch.Device, now time.Time) []Event {
+	events := make([]Event, 0)
+
+	ids := make([]string, 0, len(current)+len(previous))
+	for id := range current {
+		ids = append(ids, id)
+	}
+	for id := range previous {
+		if _, ok := current[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		old, hadOld := previous[id]
+		d, hasNew := current[id]
+
+		switch {
+		case !hadOld:
+			events = append(events, Event{Type: DeviceAdded, Time: now, DeviceID: id, New: d})
+			continue
+		case !hasNew:
+			events = append(events, Event{Type: DeviceRemoved, Time: now, DeviceID: id, Old: old})
+			continue
+		}
+
+		changed := func(t string, o, n interface{}) {
+			events = append(events, Event{Type: t, Time: now, DeviceID: id, Old: o, New: n})
+		}
+
+		if old.Health != d.Health {
+			changed(DeviceHealthChanged, old.Health, d.Health)
+		}
+		if old.Phase != d.Phase {
+			changed(DevicePhaseChanged, old.Phase, d.Phase)
+		}
+		if !uuid.Equal(old.RackID, d.RackID) || old.RackUnitStart != d.RackUnitStart {
+			changed(
+				DeviceRackChanged,
+				map[string]interface{}{"rack_id": old.RackID, "rack_unit_start": old.RackUnitStart},
+				map[string]interface{}{"rack_id": d.RackID, "rack_unit_start": d.RackUnitStart},
+			)
+		}
+		if old.Graduated.IsZero() && !d.Graduated.IsZero() {
+			changed(DeviceGraduated, nil, d.Graduated)
+		}
+		if !d.Validated.Equal(old.Validated) && !d.Validated.IsZero() {
+			changed(DeviceValidated, old.Validated, d.Validated)
+		}
+		if old.AssetTag != d.AssetTag {
+			changed(DeviceAssetTagSet, old.AssetTag, d.AssetTag)
+		}
+		if old.Hostname != d.Hostname {
+			changed(DeviceHostnameSet, old.Hostname, d.Hostname)
+		}
+	}
+
+	return events
+}
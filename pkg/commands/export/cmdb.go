@@ -0,0 +1,222 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/dghubble/sling"
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+	homedir "github.com/mitchellh/go-homedir"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// cmdbMapping describes how device records become CMDB records. Fields maps
+// CMDB field names to device record fields. Static fields are copied into
+// every record as-is.
+type cmdbMapping struct {
+	Table  string            `yaml:"table"`
+	Fields map[string]string `yaml:"fields"`
+	Static map[string]string `yaml:"static"`
+}
+
+var defaultCMDBMapping = cmdbMapping{
+	Table: "cmdb_ci_server",
+	Fields: map[string]string{
+		"serial_number":  "serial",
+		"asset_tag":      "asset_tag",
+		"name":           "hostname",
+		"model_id":       "model",
+		"manufacturer":   "vendor",
+		"location":       "location",
+		"install_status": "phase",
+	},
+}
+
+func loadCMDBMapping(path string) (cmdbMapping, error) {
+	if path == "" {
+		return defaultCMDBMapping, nil
+	}
+
+	m := cmdbMapping{}
+
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return m, err
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+
+	if err := yaml.UnmarshalStrict(raw, &m); err != nil {
+		return m, err
+	}
+
+	if len(m.Fields) == 0 {
+		return m, errors.New("the mapping does not contain any fields")
+	}
+
+	for dest, src := range m.Fields {
+		if !isRecordField(src) {
+			return m, fmt.Errorf(
+				"field '%s' maps to unknown device field '%s'. Known fields: %s",
+				dest,
+				src,
+				strings.Join(recordFields, ", "),
+			)
+		}
+	}
+
+	return m, nil
+}
+
+func (m cmdbMapping) apply(r deviceRecord) map[string]interface{} {
+	out := make(map[string]interface{})
+	for k, v := range m.Static {
+		out[k] = v
+	}
+	for dest, src := range m.Fields {
+		out[dest] = r[src]
+	}
+	return out
+}
+
+func cmdb(cmd *cli.Cmd) {
+	var (
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+		formatOpt    = cmd.StringOpt("format f", "json", "Output format: 'servicenow' or 'json'")
+		mappingOpt   = cmd.StringOpt("mapping m", "", "Path to a YAML field mapping. Defaults to a mapping suitable for the ServiceNow cmdb_ci_server table")
+		outputOpt    = cmd.StringOpt("output o", "", "Write to this file rather than STDOUT")
+		postOpt      = cmd.StringOpt("post", "", "POST the records to this URL rather than writing them out")
+		postUserOpt  = cmd.StringOpt("post-user", "", "User name for basic auth when using --post")
+		postPassOpt  = cmd.String(cli.StringOpt{
+			Name:   "post-password",
+			Value:  "",
+			Desc:   "Password for basic auth when using --post",
+			EnvVar: "CONCH_CMDB_PASSWORD",
+		})
+	)
+
+	cmd.LongDesc = `
+Maps the devices in a workspace into CMDB records.
+
+The mapping is a YAML file like:
+
+    table: cmdb_ci_server
+    fields:
+      serial_number: serial
+      asset_tag: asset_tag
+      model_id: model
+      location: location
+      install_status: phase
+    static:
+      company: Joyent
+
+Each entry in 'fields' names a CMDB field and the device field it comes from.
+Each entry in 'static' is copied into every record. 'table' is only used by
+the servicenow format.
+
+Available device fields: ` + strings.Join(recordFields, ", ") + `
+
+The 'json' format is a plain array of records. The 'servicenow' format wraps
+the records for the Import Set API's insertMultiple endpoint, eg:
+
+    --post https://example.service-now.com/api/now/import/u_conch_import/insertMultiple`
+
+	util.NotifyOpt(cmd, "export cmdb")
+
+	cmd.Action = func() {
+		if *formatOpt != "json" && *formatOpt != "servicenow" {
+			util.Bail(fmt.Errorf("unknown format '%s'", *formatOpt))
+		}
+
+		if *postOpt != "" && *outputOpt != "" {
+			util.Bail(errors.New("--post and --output cannot be used together"))
+		}
+
+		mapping, err := loadCMDBMapping(*mappingOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		records, err := deviceRecords(workspaceID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		mapped := make([]map[string]interface{}, 0, len(records))
+		for _, r := range records {
+			mapped = append(mapped, mapping.apply(r))
+		}
+
+		var payload interface{} = mapped
+		if *formatOpt == "servicenow" {
+			payload = struct {
+				Table   string                   `json:"table,omitempty"`
+				Records []map[string]interface{} `json:"records"`
+			}{mapping.Table, mapped}
+		}
+
+		if *postOpt != "" {
+			s := sling.New().Set("User-Agent", util.UserAgent).
+				Set("Accept", "application/json").
+				Post(*postOpt).
+				BodyJSON(payload)
+
+			if *postUserOpt != "" {
+				s = s.SetBasicAuth(*postUserOpt, *postPassOpt)
+			}
+
+			res, err := s.ReceiveSuccess(nil)
+			if err != nil {
+				util.Bail(err)
+			}
+			if res.StatusCode < 200 || res.StatusCode > 299 {
+				util.Bail(fmt.Errorf("POST to %s failed: %s", *postOpt, res.Status))
+			}
+
+			if !util.JSON {
+				fmt.Printf("Sent %d records to %s\n", len(mapped), *postOpt)
+			}
+			return
+		}
+
+		j, err := json.MarshalIndent(payload, "", "  ")
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if *outputOpt == "" {
+			fmt.Println(string(j))
+			return
+		}
+
+		path, err := homedir.Expand(*outputOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+		if err := util.WriteFileAtomic(path, append(j, '\n'), 0644); err != nil {
+			util.Bail(err)
+		}
+		if !util.JSON {
+			fmt.Fprintf(os.Stderr, "Wrote %d records to %s\n", len(mapped), path)
+		}
+	}
+}
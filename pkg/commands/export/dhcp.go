@@ -0,0 +1,237 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// dhcpHost is a single reservation, as handed to the templates
+type dhcpHost struct {
+	Name     string
+	Serial   string
+	Hostname string
+	Iface    string
+	MAC      string
+	IP       string
+}
+
+// dhcpData is the top level data handed to the templates
+type dhcpData struct {
+	Workspace string
+	Generated time.Time
+	Hosts     []dhcpHost
+}
+
+const iscTemplate = `# Generated by conch export dhcp for workspace {{ .Workspace }}
+# {{ .Generated }}
+{{ range .Hosts }}
+host {{ .Name }} {
+  hardware ethernet {{ .MAC }};
+{{- if .IP }}
+  fixed-address {{ .IP }};
+{{- end }}
+{{- if .Hostname }}
+  option host-name {{ iscquote .Hostname }};
+{{- end }}
+}
+{{ end -}}
+`
+
+const keaTemplate = `{
+  "reservations": [
+{{- range $i, $h := .Hosts }}{{ if $i }},{{ end }}
+    {
+      "hw-address": {{ json $h.MAC }}
+{{- if $h.IP }},
+      "ip-address": {{ json $h.IP }}
+{{- end }}
+{{- if $h.Hostname }},
+      "hostname": {{ json $h.Hostname }}
+{{- end }}
+    }
+{{- end }}
+  ]
+}
+`
+
+var dhcpTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		j, err := json.Marshal(v)
+		return string(j), err
+	},
+	"iscquote": iscQuote,
+}
+
+// iscQuote quotes a string for dhcpd.conf, which takes C style backslash
+// escapes. Control characters are written as octal escapes.
+func iscQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c == 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+var dhcpNameCleaner = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+func dhcp(cmd *cli.Cmd) {
+	var (
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+		formatOpt    = cmd.StringOpt("format f", "isc", "Output format: 'isc' or 'kea'. Ignored if --template is provided")
+		templateOpt  = cmd.StringOpt("template t", "", "Path to a Go text/template to render instead of the built in formats")
+		ifaceOpt     = cmd.StringOpt("iface i", "", "Only include interfaces whose name matches this regular expression, eg '^eth0$' or '^ipmi'")
+		skipNoIPOpt  = cmd.BoolOpt("require-ip", false, "Skip interfaces that do not have an IP address in the device's latest report")
+	)
+
+	cmd.LongDesc = `
+Renders DHCP host reservations for the devices in a workspace, using the NIC
+MAC addresses and IP addresses from each device's latest report.
+
+Custom templates receive .Workspace, .Generated, and .Hosts. Each host has
+.Name, .Serial, .Hostname, .Iface, .MAC, and .IP, which may be empty. The
+'json' and 'iscquote' functions quote values for JSON and dhcpd.conf.`
+
+	util.NotifyOpt(cmd, "export dhcp")
+
+	cmd.Action = func() {
+		var tmpl *template.Template
+		var err error
+
+		if *templateOpt != "" {
+			path, err := homedir.Expand(*templateOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+			raw, err := ioutil.ReadFile(path)
+			if err != nil {
+				util.Bail(err)
+			}
+			tmpl, err = template.New("dhcp").Funcs(dhcpTemplateFuncs).Parse(string(raw))
+			if err != nil {
+				util.Bail(err)
+			}
+		} else {
+			switch *formatOpt {
+			case "isc":
+				tmpl = template.Must(template.New("isc").Funcs(dhcpTemplateFuncs).Parse(iscTemplate))
+			case "kea":
+				tmpl = template.Must(template.New("kea").Funcs(dhcpTemplateFuncs).Parse(keaTemplate))
+			default:
+				util.Bail(fmt.Errorf("unknown format '%s'", *formatOpt))
+			}
+		}
+
+		var ifaceRE *regexp.Regexp
+		if *ifaceOpt != "" {
+			ifaceRE, err = regexp.Compile(*ifaceOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+		}
+
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		workspace, err := util.API.GetWorkspace(workspaceID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		devices, err := util.API.GetWorkspaceDevices(workspaceID, true, "", "", "")
+		if err != nil {
+			util.Bail(err)
+		}
+		sort.Sort(devices)
+
+		data := dhcpData{
+			Workspace: workspace.Name,
+			Generated: time.Now().UTC(),
+			Hosts:     make([]dhcpHost, 0),
+		}
+
+		for _, d := range devices {
+			// The workspace device list does not include NICs
+			full, err := util.API.GetDevice(d.ID)
+			if err != nil {
+				util.Bail(err)
+			}
+
+			nics := make([]conch.Nic, 0)
+			for _, nic := range full.Nics {
+				if nic.MAC == "" {
+					continue
+				}
+				if ifaceRE != nil && !ifaceRE.MatchString(nic.IfaceName) {
+					continue
+				}
+				nics = append(nics, nic)
+			}
+			sort.Slice(nics, func(i, j int) bool {
+				return nics[i].IfaceName < nics[j].IfaceName
+			})
+
+			for _, nic := range nics {
+				ip, err := util.API.GetDeviceInterfaceIP(full.ID, nic.IfaceName)
+				if err != nil && err != conch.ErrDataNotFound {
+					util.Bail(err)
+				}
+
+				if ip == "" && *skipNoIPOpt {
+					continue
+				}
+
+				name := full.ID
+				if full.Hostname != "" {
+					name = full.Hostname
+				}
+				if len(nics) > 1 {
+					name = name + "-" + nic.IfaceName
+				}
+
+				data.Hosts = append(data.Hosts, dhcpHost{
+					Name:     dhcpNameCleaner.ReplaceAllString(name, "-"),
+					Serial:   full.ID,
+					Hostname: full.Hostname,
+					Iface:    nic.IfaceName,
+					MAC:      strings.ToLower(nic.MAC),
+					IP:       ip,
+				})
+			}
+		}
+
+		if err := tmpl.Execute(os.Stdout, data); err != nil {
+			util.Bail(err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+// dotGraph collects nodes and edges, deduplicating both, and writes them out
+// in a stable order
+type dotGraph struct {
+	nodes map[string]string
+	edges map[string]string
+}
+
+func newDotGraph() *dotGraph {
+	return &dotGraph{
+		nodes: make(map[string]string),
+		edges: make(map[string]string),
+	}
+}
+
+func (g *dotGraph) node(id string, label string, attrs string) {
+	a := "label=" + dotQuote(label)
+	if attrs != "" {
+		a = a + ", " + attrs
+	}
+	g.nodes[id] = a
+}
+
+func (g *dotGraph) edge(from string, to string, attrs string) {
+	e := dotQuote(from) + " -> " + dotQuote(to)
+	if attrs != "" {
+		e = e + " [" + attrs + "]"
+	}
+	g.edges[e] = e
+}
+
+func (g *dotGraph) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "digraph %s {\n", dotQuote(name))
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [fontname=\"Helvetica\"];")
+
+	ids := make([]string, 0, len(g.nodes))
+	for id := range g.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Fprintf(w, "  %s [%s];\n", dotQuote(id), g.nodes[id])
+	}
+
+	edges := make([]string, 0, len(g.edges))
+	for e := range g.edges {
+		edges = append(edges, e)
+	}
+	sort.Strings(edges)
+	for _, e := range edges {
+		fmt.Fprintf(w, "  %s;\n", e)
+	}
+
+	fmt.Fprintln(w, "}")
+}
+
+func dot(cmd *cli.Cmd) {
+	var (
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+		linksOpt     = cmd.BoolOpt("links", false, "Add switch to server links, based on the LLDP data in device reports. This requires fetching every device individually")
+		noDevicesOpt = cmd.BoolOpt("no-devices", false, "Stop at racks, leaving out the devices")
+	)
+
+	cmd.LongDesc = `
+Writes a Graphviz DOT graph of datacenter -> room -> rack -> device for a
+workspace to STDOUT. For instance:
+
+    conch export dot --ws MyWorkspace | dot -Tsvg > topology.svg`
+
+	cmd.Action = func() {
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		workspace, err := util.API.GetWorkspace(workspaceID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		racks, err := util.API.GetWorkspaceRacks(workspaceID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		g := newDotGraph()
+		devices := make([]string, 0)
+
+		for _, r := range racks {
+			rack, err := util.API.GetWorkspaceRack(workspaceID, r.ID)
+			if err != nil {
+				util.Bail(err)
+			}
+
+			rackID := "rack:" + rack.ID.String()
+			g.node(rackID, rack.Name, "shape=box3d")
+
+			occupants := make([]conch.WorkspaceRackSlot, 0)
+			for _, slot := range rack.Slots {
+				if slot.Occupant.ID != "" {
+					occupants = append(occupants, slot)
+				}
+			}
+
+			// The workspace rack only knows the room's name, so that names
+			// the room's node. If there's a device in the rack, its location
+			// gets us the full picture for the label.
+			roomID := "room:" + rack.Datacenter
+			roomLabel := rack.Datacenter
+			dcID := ""
+			dcLabel := ""
+			if len(occupants) > 0 {
+				loc, err := util.API.GetDeviceLocation(occupants[0].Occupant.ID)
+				if err == nil {
+					roomLabel = loc.Room.AZ
+					if loc.Room.Alias != "" {
+						roomLabel = loc.Room.Alias + "\n" + loc.Room.AZ
+					}
+					dcID = "datacenter:" + loc.Datacenter.ID.String()
+					dcLabel = loc.Datacenter.Region + "\n" + loc.Datacenter.VendorName
+				}
+			}
+
+			// Don't let a rack without a location undo the label of a room
+			// that another rack found
+			if _, ok := g.nodes[roomID]; !ok || dcID != "" {
+				g.node(roomID, roomLabel, "shape=folder")
+			}
+			g.edge(roomID, rackID, "")
+			if dcID != "" {
+				g.node(dcID, dcLabel, "shape=house")
+				g.edge(dcID, roomID, "")
+			}
+
+			if *noDevicesOpt {
+				continue
+			}
+
+			for _, slot := range occupants {
+				d := slot.Occupant
+				deviceID := "device:" + d.ID
+
+				label := d.ID
+				if d.Hostname != "" {
+					label = d.Hostname + "\n" + d.ID
+				}
+				label = fmt.Sprintf("U%d: %s", slot.RackUnitStart, label)
+
+				attrs := "shape=box"
+				switch d.Health {
+				case "fail", "error":
+					attrs = attrs + ", color=red"
+				case "pass":
+					attrs = attrs + ", color=darkgreen"
+				}
+
+				g.node(deviceID, label, attrs)
+				g.edge(rackID, deviceID, "")
+				devices = append(devices, d.ID)
+			}
+		}
+
+		if *linksOpt && !*noDevicesOpt {
+			for _, serial := range devices {
+				d, err := util.API.GetDevice(serial)
+				if err != nil {
+					util.Bail(err)
+				}
+
+				for _, nic := range d.Nics {
+					if nic.PeerSwitch == "" {
+						continue
+					}
+
+					peerID := "device:" + nic.PeerSwitch
+					if _, ok := g.nodes[peerID]; !ok {
+						g.node(peerID, nic.PeerSwitch, "shape=box, style=dashed")
+					}
+
+					g.edge(
+						peerID,
+						"device:"+d.ID,
+						"dir=none, style=dotted, label="+dotQuote(nic.PeerPort+" - "+nic.IfaceName),
+					)
+				}
+			}
+		}
+
+		var buf bytes.Buffer
+		g.write(&buf, workspace.Name)
+		os.Stdout.Write(buf.Bytes())
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dghubble/sling"
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// esBulkResponse is the subset of the bulk API response that we care about
+type esBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string `json:"_id"`
+		Status int    `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// recordTime pulls a time back out of a record field written by timeOrEmpty
+func recordTime(r deviceRecord, field string) time.Time {
+	str, _ := r[field].(string)
+	t, _ := time.Parse(time.RFC3339, str)
+	return t
+}
+
+// parseSince accepts either an RFC3339 time or a duration, meaning "this long
+// ago"
+func parseSince(since string) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is neither an RFC3339 time nor a duration", since)
+	}
+	return time.Now().Add(-d), nil
+}
+
+func elasticsearch(cmd *cli.Cmd) {
+	var (
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+		urlOpt       = cmd.StringOpt("url", "", "Base URL of the Elasticsearch or OpenSearch cluster")
+		indexOpt     = cmd.StringOpt("index", "conch-devices", "Name of the index")
+		reportsOpt   = cmd.BoolOpt("reports", false, "Include each device's latest report. This requires fetching every device individually")
+		sinceOpt     = cmd.StringOpt("since", "", "Only send devices updated or seen since this time. Accepts RFC3339 or a duration, eg '24h'")
+		stateOpt     = cmd.StringOpt("state", "", "Path to a file that records the time of the last successful export. When present, it is used as --since")
+		batchOpt     = cmd.IntOpt("batch", 500, "Number of documents per bulk request")
+		userOpt      = cmd.StringOpt("user", "", "User name for basic auth")
+		passwordOpt  = cmd.String(cli.StringOpt{
+			Name:   "password",
+			Value:  "",
+			Desc:   "Password for basic auth",
+			EnvVar: "CONCH_ES_PASSWORD",
+		})
+		dryRunOpt = cmd.BoolOpt("dry-run", false, "Write the bulk request bodies to STDOUT instead of sending them")
+	)
+
+	cmd.Spec = "--url [OPTIONS] | --dry-run [OPTIONS]"
+
+	cmd.LongDesc = `
+Sends a document per device to the bulk API of Elasticsearch or OpenSearch.
+Documents use the device serial as their ID, so repeated exports update
+documents in place.
+
+For incremental exports, use --state. The first run sends every device and
+records the time. Later runs only send devices that were updated or seen
+since the previous successful run.`
+
+	util.NotifyOpt(cmd, "export elasticsearch")
+
+	cmd.Action = func() {
+		if *batchOpt < 1 {
+			util.Bail(errors.New("--batch must be at least 1"))
+		}
+
+		started := time.Now().UTC()
+
+		since, err := parseSince(*sinceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		statePath := ""
+		if *stateOpt != "" {
+			statePath, err = homedir.Expand(*stateOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+
+			if raw, err := ioutil.ReadFile(statePath); err == nil {
+				last, err := time.Parse(time.RFC3339, strings.TrimSpace(string(raw)))
+				if err != nil {
+					util.Bail(fmt.Errorf("could not parse state file %s: %s", statePath, err))
+				}
+				if last.After(since) {
+					since = last
+				}
+			} else if !os.IsNotExist(err) {
+				util.Bail(err)
+			}
+		}
+
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		records, err := deviceRecords(workspaceID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		// ^C stops fetching reports and sending batches. What was fetched
+		// is still sent, but the state file is left alone so that the next
+		// run sends the rest.
+		done := util.Stoppable()
+		defer done()
+
+		docs := make([]deviceRecord, 0, len(records))
+		for _, r := range records {
+			if util.Interrupted() {
+				break
+			}
+			if !since.IsZero() &&
+				!recordTime(r, "updated").After(since) &&
+				!recordTime(r, "last_seen").After(since) {
+				continue
+			}
+
+			r["@timestamp"] = started.Format(time.RFC3339)
+
+			if *reportsOpt {
+				d, err := util.API.GetDevice(r["serial"].(string))
+				if err != nil {
+					util.Bail(err)
+				}
+				r["latest_report"] = d.LatestReport
+			}
+
+			docs = append(docs, r)
+		}
+
+		var progress *util.Progress
+		if !*dryRunOpt {
+			batches := (len(docs) + *batchOpt - 1) / *batchOpt
+			progress = util.StartProgress("export elasticsearch batches", batches)
+			defer progress.Finish()
+		}
+
+		sent := 0
+		for start := 0; start < len(docs); start += *batchOpt {
+			if util.Interrupted() {
+				break
+			}
+			end := start + *batchOpt
+			if end > len(docs) {
+				end = len(docs)
+			}
+
+			var body bytes.Buffer
+			enc := json.NewEncoder(&body)
+			for _, doc := range docs[start:end] {
+				action := map[string]map[string]string{
+					"index": {
+						"_index": *indexOpt,
+						"_id":    doc["serial"].(string),
+					},
+				}
+				if err := enc.Encode(action); err != nil {
+					util.Bail(err)
+				}
+				if err := enc.Encode(doc); err != nil {
+					util.Bail(err)
+				}
+			}
+
+			if *dryRunOpt {
+				os.Stdout.Write(body.Bytes())
+				continue
+			}
+
+			item := fmt.Sprintf("devices %d-%d", start+1, end)
+			if err := esBulk(*urlOpt, *userOpt, *passwordOpt, &body); err != nil {
+				progress.Fail(item, err)
+				util.Bail(err)
+			}
+			progress.Step(item)
+			sent += end - start
+		}
+
+		if *dryRunOpt {
+			return
+		}
+
+		if util.Interrupted() {
+			fmt.Fprintf(
+				os.Stderr,
+				"Interrupted. Sent %d of %d devices to %s. The state file was not updated\n",
+				sent,
+				len(records),
+				*indexOpt,
+			)
+			util.Exit(util.InterruptExitCode)
+		}
+
+		if statePath != "" {
+			if err := util.WriteFileAtomic(
+				statePath,
+				[]byte(started.Format(time.RFC3339)+"\n"),
+				0644,
+			); err != nil {
+				util.Bail(err)
+			}
+		}
+
+		if !util.JSON {
+			fmt.Fprintf(os.Stderr, "Sent %d of %d devices to %s\n", sent, len(records), *indexOpt)
+		}
+	}
+}
+
+func esBulk(url string, user string, password string, body *bytes.Buffer) error {
+	s := sling.New().Set("User-Agent", util.UserAgent).
+		Set("Content-Type", "application/x-ndjson").
+		Post(strings.TrimRight(url, "/") + "/_bulk").
+		Body(body)
+
+	if user != "" {
+		s = s.SetBasicAuth(user, password)
+	}
+
+	resp := esBulkResponse{}
+	res, err := s.ReceiveSuccess(&resp)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("bulk request to %s failed: %s", url, res.Status)
+	}
+
+	if !resp.Errors {
+		return nil
+	}
+
+	failures := make([]string, 0)
+	for _, item := range resp.Items {
+		for _, result := range item {
+			if result.Status > 299 {
+				failures = append(failures, fmt.Sprintf(
+					"%s: %s: %s",
+					result.ID,
+					result.Error.Type,
+					result.Error.Reason,
+				))
+			}
+		}
+	}
+	return fmt.Errorf(
+		"%d documents failed to index:\n%s",
+		len(failures),
+		strings.Join(failures, "\n"),
+	)
+}
@@ -0,0 +1,77 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package export contains commands that feed API data into other systems
+package export
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the export and sync commands
+func Init(app *cli.Cli) {
+	util.Command(
+		app.Cmd,
+		"export",
+		"Export data from the API into other systems",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+
+			util.Command(
+				cmd,
+				"prometheus prom",
+				"Serve fleet metrics in the Prometheus text format",
+				prometheus,
+			)
+
+			util.Command(
+				cmd,
+				"cmdb",
+				"Export devices as CMDB records, using a configurable field mapping",
+				cmdb,
+			)
+
+			util.Command(
+				cmd,
+				"dot",
+				"Export the topology of a workspace as a Graphviz DOT graph",
+				dot,
+			)
+
+			util.Command(
+				cmd,
+				"inventory",
+				"Export the full inventory of a workspace as a spreadsheet",
+				inventory,
+			)
+
+			util.Command(
+				cmd,
+				"dhcp",
+				"Render DHCP host reservations from device NIC data",
+				dhcp,
+			)
+
+			util.Command(
+				cmd,
+				"elasticsearch es opensearch",
+				"Send device documents to Elasticsearch or OpenSearch via the bulk API",
+				elasticsearch,
+			)
+		},
+	)
+
+	util.Command(
+		app.Cmd,
+		"sync",
+		"Emit only the devices that changed since the last sync, for downstream systems",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+			syncCmd(cmd)
+		},
+	)
+}
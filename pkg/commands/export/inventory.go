@@ -0,0 +1,277 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+func inventory(cmd *cli.Cmd) {
+	var (
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+		xlsxOpt      = cmd.StringOpt("xlsx", "", "Write an Excel workbook to this path")
+		csvOpt       = cmd.StringOpt("csv", "", "Write one CSV file per sheet into this directory")
+	)
+
+	cmd.Spec = "[OPTIONS] (--xlsx | --csv)"
+
+	cmd.LongDesc = `
+Exports the inventory of a workspace with names, rather than UUIDs. The
+export has four sheets: devices, racks, rack layouts, and hardware products.
+
+With --csv, the sheets are written to devices.csv, racks.csv, layouts.csv,
+and hardware_products.csv in the given directory.`
+
+	util.NotifyOpt(cmd, "export inventory")
+
+	cmd.Action = func() {
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		sheets, skipped, err := inventorySheets(workspaceID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if *xlsxOpt != "" {
+			path, err := homedir.Expand(*xlsxOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+			if err := writeXLSX(path, sheets); err != nil {
+				util.Bail(err)
+			}
+			fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+		}
+
+		if *csvOpt != "" {
+			dir, err := homedir.Expand(*csvOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+
+			if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+				util.Bail(fmt.Errorf("%s is not a directory", dir))
+			}
+
+			for _, s := range sheets {
+				path := filepath.Join(
+					dir,
+					strings.Replace(strings.ToLower(s.Name), " ", "_", -1)+".csv",
+				)
+				if err := writeCSV(path, s.Rows); err != nil {
+					util.Bail(err)
+				}
+				fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+			}
+		}
+
+		if skipped > 0 {
+			fmt.Fprintf(
+				os.Stderr,
+				"Interrupted. The export is missing %d racks and their layouts\n",
+				skipped,
+			)
+			util.Exit(util.InterruptExitCode)
+		}
+	}
+}
+
+func writeCSV(path string, rows [][]string) error {
+	f, err := util.CreateAtomic(path, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Abandon()
+
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// inventorySheets fetches everything in the export. Fetching racks one at a
+// time is the slow part, so ^C stops it there; the racks that were skipped
+// are counted, and the sheets hold everything else.
+func inventorySheets(workspaceID uuid.UUID) (sheets []sheet, skipped int, err error) {
+	records, err := deviceRecords(workspaceID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	devices := sheet{Name: "Devices", Rows: [][]string{recordFields}}
+	for _, r := range records {
+		row := make([]string, len(recordFields))
+		for i, f := range recordFields {
+			row[i] = fmt.Sprintf("%v", r[f])
+		}
+		devices.Rows = append(devices.Rows, row)
+	}
+
+	products, err := util.API.GetHardwareProducts()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	vendors, err := util.API.GetHardwareVendors()
+	if err != nil {
+		return nil, 0, err
+	}
+	vendorNames := make(map[uuid.UUID]string)
+	for _, v := range vendors {
+		vendorNames[v.ID] = v.Name
+	}
+
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Name < products[j].Name
+	})
+
+	hardware := sheet{
+		Name: "Hardware Products",
+		Rows: [][]string{{
+			"name",
+			"alias",
+			"sku",
+			"vendor",
+			"generation",
+			"prefix",
+			"legacy_name",
+			"rack_units",
+			"purpose",
+			"cpu_type",
+			"cpu_num",
+			"ram_total",
+		}},
+	}
+	for _, p := range products {
+		hardware.Rows = append(hardware.Rows, []string{
+			p.Name,
+			p.Alias,
+			p.SKU,
+			vendorNames[p.HardwareVendorID],
+			p.GenerationName,
+			p.Prefix,
+			p.LegacyProductName,
+			strconv.Itoa(p.Profile.RackUnit),
+			p.Profile.Purpose,
+			p.Profile.CPUType,
+			strconv.Itoa(p.Profile.NumCPU),
+			strconv.Itoa(p.Profile.TotalRAM),
+		})
+	}
+
+	wsRacks, err := util.API.GetWorkspaceRacks(workspaceID)
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(wsRacks, func(i, j int) bool {
+		return wsRacks[i].Name < wsRacks[j].Name
+	})
+
+	racks := sheet{
+		Name: "Racks",
+		Rows: [][]string{{
+			"name",
+			"room",
+			"role",
+			"size",
+			"phase",
+			"serial_number",
+			"asset_tag",
+			"slots",
+			"occupied",
+		}},
+	}
+	layouts := sheet{
+		Name: "Layouts",
+		Rows: [][]string{{
+			"rack",
+			"room",
+			"rack_unit_start",
+			"rack_units",
+			"product",
+			"alias",
+			"vendor",
+			"occupant",
+		}},
+	}
+
+	done := util.Stoppable()
+	defer done()
+
+	progress := util.StartProgress("export inventory racks", len(wsRacks))
+	defer progress.Finish()
+
+	for i, r := range wsRacks {
+		if util.Interrupted() {
+			skipped = len(wsRacks) - i
+			break
+		}
+
+		rack, err := util.API.GetWorkspaceRack(workspaceID, r.ID)
+		if err != nil {
+			progress.Fail(r.Name, err)
+			return nil, 0, err
+		}
+		progress.Step(rack.Name)
+
+		occupied := 0
+		slots := make(conch.WorkspaceRackSlots, len(rack.Slots))
+		copy(slots, rack.Slots)
+		sort.Sort(sort.Reverse(slots))
+
+		for _, slot := range slots {
+			if slot.Occupant.ID != "" {
+				occupied++
+			}
+			layouts.Rows = append(layouts.Rows, []string{
+				rack.Name,
+				rack.Datacenter,
+				strconv.Itoa(slot.RackUnitStart),
+				strconv.Itoa(slot.Size),
+				slot.Name,
+				slot.Alias,
+				slot.Vendor,
+				slot.Occupant.ID,
+			})
+		}
+
+		racks.Rows = append(racks.Rows, []string{
+			rack.Name,
+			rack.Datacenter,
+			rack.Role,
+			strconv.Itoa(rack.Size),
+			rack.Phase,
+			rack.SerialNumber,
+			rack.AssetTag,
+			strconv.Itoa(len(slots)),
+			strconv.Itoa(occupied),
+		})
+	}
+
+	if len(devices.Rows) == 1 && len(racks.Rows) == 1 {
+		return nil, 0, errors.New("the workspace does not contain any racks or devices")
+	}
+
+	return []sheet{devices, racks, layouts, hardware}, skipped, nil
+}
@@ -0,0 +1,356 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// promSample is a single value in a metric. Labels are name/value pairs, in
+// order.
+type promSample struct {
+	labels []string
+	value  float64
+}
+
+// promGauge is a named gauge and all of its samples
+type promGauge struct {
+	name    string
+	help    string
+	samples []promSample
+}
+
+func (g *promGauge) add(value float64, labels ...string) {
+	g.samples = append(g.samples, promSample{labels: labels, value: value})
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (g promGauge) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", g.name, g.help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+	for _, s := range g.samples {
+		labels := make([]string, 0, len(s.labels)/2)
+		for i := 0; i+1 < len(s.labels); i += 2 {
+			labels = append(
+				labels,
+				fmt.Sprintf(`%s="%s"`, s.labels[i], promEscaper.Replace(s.labels[i+1])),
+			)
+		}
+
+		if len(labels) == 0 {
+			fmt.Fprintf(w, "%s %g\n", g.name, s.value)
+		} else {
+			fmt.Fprintf(w, "%s{%s} %g\n", g.name, strings.Join(labels, ","), s.value)
+		}
+	}
+}
+
+// promExporter keeps the most recent successful collection around so that
+// scrapes do not hit the API directly
+type promExporter struct {
+	sync.Mutex
+
+	workspaces  []string
+	body        []byte
+	lastSuccess time.Time
+	lastError   error
+}
+
+func prometheus(cmd *cli.Cmd) {
+	var (
+		listenOpt    = cmd.StringOpt("listen l", ":9100", "Serve metrics at /metrics on this address")
+		intervalOpt  = cmd.StringOpt("interval", "1m", "How often to refresh the metrics from the API. Uses Go duration syntax")
+		workspaceOpt = cmd.StringsOpt("workspace ws", nil, "The UUID or name of a workspace to export. Can be repeated. Defaults to every workspace the user can see")
+		onceOpt      = cmd.BoolOpt("once", false, "Collect the metrics once, write them to STDOUT, and exit. Useful for the node_exporter textfile collector")
+	)
+
+	cmd.LongDesc = `
+Collects gauges from the API and serves them in the Prometheus text format:
+
+* conch_devices: device counts by workspace, rack, health, and phase
+* conch_racks: rack counts by workspace and phase
+* conch_validation_failures: the number of devices failing each validation, by workspace
+* conch_relay_last_seen_seconds: seconds since each relay in a workspace last checked in
+* conch_relay_devices: the number of devices behind each relay
+
+The API is queried every --interval rather than on every scrape. If a refresh
+fails, the previous data continues to be served and conch_export_up drops to 0.`
+
+	cmd.Action = func() {
+		interval, err := time.ParseDuration(*intervalOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		e := &promExporter{workspaces: *workspaceOpt}
+
+		if *onceOpt {
+			body, err := e.collect()
+			if err != nil {
+				util.Bail(err)
+			}
+			os.Stdout.Write(body)
+			return
+		}
+
+		e.refresh()
+		go func() {
+			for range time.Tick(interval) {
+				e.refresh()
+			}
+		}()
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", e)
+
+		fmt.Fprintf(os.Stderr, "Serving metrics on %s/metrics\n", *listenOpt)
+		util.Bail(http.ListenAndServe(*listenOpt, mux))
+	}
+}
+
+func (e *promExporter) refresh() {
+	body, err := e.collect()
+
+	e.Lock()
+	defer e.Unlock()
+
+	e.lastError = err
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error collecting metrics: %s\n", err)
+		return
+	}
+	e.body = body
+	e.lastSuccess = time.Now()
+}
+
+func (e *promExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.Lock()
+	defer e.Unlock()
+
+	up := promGauge{
+		name: "conch_export_up",
+		help: "Whether the last refresh from the Conch API succeeded",
+	}
+	if e.lastError == nil {
+		up.add(1)
+	} else {
+		up.add(0)
+	}
+
+	last := promGauge{
+		name: "conch_export_last_success_timestamp_seconds",
+		help: "Unix time of the last successful refresh from the Conch API",
+	}
+	// Until a refresh succeeds there is no time to report. The zero time
+	// would be a large negative timestamp.
+	if !e.lastSuccess.IsZero() {
+		last.add(float64(e.lastSuccess.Unix()))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(e.body)
+	up.write(w)
+	last.write(w)
+}
+
+func (e *promExporter) resolveWorkspaces() (conch.Workspaces, error) {
+	if len(e.workspaces) == 0 {
+		return util.API.GetWorkspaces()
+	}
+
+	workspaces := make(conch.Workspaces, 0)
+	for _, wat := range e.workspaces {
+		id, err := util.MagicWorkspaceID(wat)
+		if err != nil {
+			return workspaces, err
+		}
+
+		ws, err := util.API.GetWorkspace(id)
+		if err != nil {
+			return workspaces, err
+		}
+		workspaces = append(workspaces, ws)
+	}
+	return workspaces, nil
+}
+
+// collect queries the API and renders every gauge
+func (e *promExporter) collect() ([]byte, error) {
+	workspaces, err := e.resolveWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(workspaces)
+
+	validations, err := util.API.GetValidations()
+	if err != nil {
+		return nil, err
+	}
+	validationNames := make(map[uuid.UUID]string)
+	for _, v := range validations {
+		validationNames[v.ID] = v.Name
+	}
+
+	devicesGauge := &promGauge{
+		name: "conch_devices",
+		help: "Number of devices by workspace, rack, health, and phase",
+	}
+	racksGauge := &promGauge{
+		name: "conch_racks",
+		help: "Number of racks by workspace and phase",
+	}
+	failuresGauge := &promGauge{
+		name: "conch_validation_failures",
+		help: "Number of devices failing a validation, by workspace",
+	}
+	relaySeenGauge := &promGauge{
+		name: "conch_relay_last_seen_seconds",
+		help: "Seconds since the relay last checked in",
+	}
+	relayDevicesGauge := &promGauge{
+		name: "conch_relay_devices",
+		help: "Number of devices behind the relay",
+	}
+
+	now := time.Now()
+
+	for _, ws := range workspaces {
+		racks, err := util.API.GetWorkspaceRacks(ws.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		rackNames := make(map[uuid.UUID]string)
+		rackPhases := make(map[string]int)
+		for _, r := range racks {
+			rackNames[r.ID] = r.Name
+			rackPhases[r.Phase]++
+		}
+		for _, phase := range sortedKeys(rackPhases) {
+			racksGauge.add(
+				float64(rackPhases[phase]),
+				"workspace", ws.Name,
+				"phase", phase,
+			)
+		}
+
+		devices, err := util.API.GetWorkspaceDevices(ws.ID, false, "", "", "")
+		if err != nil {
+			return nil, err
+		}
+
+		counts := make(map[string]int)
+		for _, d := range devices {
+			rack := rackNames[d.RackID]
+			if rack == "" && !uuid.Equal(d.RackID, uuid.UUID{}) {
+				rack = d.RackID.String()
+			}
+			counts[strings.Join([]string{rack, d.Health, d.Phase}, "\x00")]++
+		}
+		for _, key := range sortedKeys(counts) {
+			bits := strings.Split(key, "\x00")
+			devicesGauge.add(
+				float64(counts[key]),
+				"workspace", ws.Name,
+				"rack", bits[0],
+				"health", bits[1],
+				"phase", bits[2],
+			)
+		}
+
+		states, err := util.API.WorkspaceValidationStates(ws.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		failing := make(map[string]map[string]bool)
+		for _, state := range states {
+			for _, r := range state.Results {
+				if r.Status == "pass" {
+					continue
+				}
+				name, ok := validationNames[r.ValidationID]
+				if !ok {
+					name = r.ValidationID.String()
+				}
+				if _, ok := failing[name]; !ok {
+					failing[name] = make(map[string]bool)
+				}
+				failing[name][state.DeviceID] = true
+			}
+		}
+		names := make([]string, 0, len(failing))
+		for name := range failing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			failuresGauge.add(
+				float64(len(failing[name])),
+				"workspace", ws.Name,
+				"validation", name,
+			)
+		}
+
+		relays, err := util.API.GetWorkspaceRelays(ws.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range relays {
+			if !r.LastSeen.IsZero() {
+				relaySeenGauge.add(
+					now.Sub(r.LastSeen).Seconds(),
+					"workspace", ws.Name,
+					"relay", r.ID,
+					"alias", r.Alias,
+				)
+			}
+			relayDevicesGauge.add(
+				float64(r.NumDevices),
+				"workspace", ws.Name,
+				"relay", r.ID,
+				"alias", r.Alias,
+			)
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, g := range []*promGauge{
+		devicesGauge,
+		racksGauge,
+		failuresGauge,
+		relaySeenGauge,
+		relayDevicesGauge,
+	} {
+		g.write(&buf)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
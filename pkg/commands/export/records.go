@@ -0,0 +1,170 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package export
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// deviceRecord is a flattened view of a device, suitable for feeding into
+// systems that know nothing about workspaces or racks. The keys are listed in
+// recordFields.
+type deviceRecord map[string]interface{}
+
+// recordFields are the fields available in every deviceRecord
+var recordFields = []string{
+	"serial",
+	"asset_tag",
+	"hostname",
+	"system_uuid",
+	"model",
+	"sku",
+	"vendor",
+	"health",
+	"state",
+	"phase",
+	"workspace",
+	"room",
+	"rack",
+	"rack_role",
+	"rack_unit",
+	"location",
+	"last_seen",
+	"created",
+	"updated",
+}
+
+// deviceRecordSources are the API device fields that deviceRecords reads
+var deviceRecordSources = []string{
+	"id",
+	"asset_tag",
+	"hostname",
+	"system_uuid",
+	"hardware_product",
+	"health",
+	"state",
+	"phase",
+	"rack_id",
+	"rack_unit_start",
+	"last_seen",
+	"created",
+	"updated",
+}
+
+func isRecordField(name string) bool {
+	for _, f := range recordFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// deviceRecords builds a record for every device in a workspace. Rack,
+// product, and vendor details are fetched in bulk rather than per device.
+func deviceRecords(workspaceID uuid.UUID) ([]deviceRecord, error) {
+	records := make([]deviceRecord, 0)
+
+	workspace, err := util.API.GetWorkspace(workspaceID)
+	if err != nil {
+		return records, err
+	}
+
+	devices, err := util.API.GetWorkspaceDevicesFields(
+		workspaceID,
+		deviceRecordSources,
+		"",
+		"",
+		"",
+	)
+	if err != nil {
+		return records, err
+	}
+	sort.Sort(devices)
+
+	racks, err := util.API.GetWorkspaceRacks(workspaceID)
+	if err != nil {
+		return records, err
+	}
+	rackMap := make(map[uuid.UUID]conch.WorkspaceRack)
+	for _, r := range racks {
+		rackMap[r.ID] = r
+	}
+
+	products, err := util.API.GetHardwareProducts()
+	if err != nil {
+		return records, err
+	}
+	productMap := make(map[uuid.UUID]conch.HardwareProduct)
+	for _, p := range products {
+		productMap[p.ID] = p
+	}
+
+	vendors, err := util.API.GetHardwareVendors()
+	if err != nil {
+		return records, err
+	}
+	vendorMap := make(map[uuid.UUID]string)
+	for _, v := range vendors {
+		vendorMap[v.ID] = v.Name
+	}
+
+	for _, d := range devices {
+		rack := rackMap[d.RackID]
+		product := productMap[d.HardwareProduct]
+
+		location := ""
+		if rack.Name != "" {
+			location = fmt.Sprintf("%s:%s:%d", rack.Datacenter, rack.Name, d.RackUnitStart)
+		}
+
+		record := deviceRecord{
+			"serial":      d.ID,
+			"asset_tag":   d.AssetTag,
+			"hostname":    d.Hostname,
+			"system_uuid": "",
+			"model":       product.Name,
+			"sku":         product.SKU,
+			"vendor":      vendorMap[product.HardwareVendorID],
+			"health":      d.Health,
+			"state":       d.State,
+			"phase":       d.Phase,
+			"workspace":   workspace.Name,
+			"room":        rack.Datacenter,
+			"rack":        rack.Name,
+			"rack_role":   rack.Role,
+			"rack_unit":   d.RackUnitStart,
+			"location":    location,
+			"last_seen":   timeOrEmpty(d.LastSeen),
+			"created":     timeOrEmpty(d.Created),
+			"updated":     timeOrEmpty(d.Updated),
+		}
+
+		if !uuid.Equal(d.SystemUUID, uuid.UUID{}) {
+			record["system_uuid"] = d.SystemUUID.String()
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// timeOrEmpty formats a time as RFC3339 in UTC, or returns an empty string if
+// the time was never set
+func timeOrEmpty(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,250 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// syncState is what 'conch sync' remembers between runs: a fingerprint of
+// every device record it has already emitted
+type syncState struct {
+	WorkspaceID string            `json:"workspace_id"`
+	LastRun     time.Time         `json:"last_run"`
+	Ignored     []string          `json:"ignored"`
+	Devices     map[string]string `json:"devices"`
+}
+
+// syncChange is a single line of 'conch sync' output
+type syncChange struct {
+	Action string       `json:"action"`
+	Serial string       `json:"serial"`
+	Device deviceRecord `json:"device,omitempty"`
+}
+
+// fingerprint hashes a record, leaving out the ignored fields.
+// encoding/json sorts map keys, so the same record always hashes the same.
+func fingerprint(r deviceRecord, ignored []string) (string, error) {
+	trimmed := make(deviceRecord, len(r))
+	for k, v := range r {
+		trimmed[k] = v
+	}
+	for _, f := range ignored {
+		delete(trimmed, f)
+	}
+
+	j, err := json.Marshal(trimmed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(j)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sameFields(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func readSyncState(path string) (state syncState, found bool, err error) {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, false, nil
+	}
+	if err != nil {
+		return state, false, err
+	}
+
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return state, false, fmt.Errorf("could not parse state file %s: %s", path, err)
+	}
+	return state, true, nil
+}
+
+// writeSyncState replaces the state file in one step, so an interrupted run
+// never leaves a half written file behind
+func writeSyncState(path string, state syncState) error {
+	j, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(path, j, 0600)
+}
+
+func syncCmd(cmd *cli.Cmd) {
+	var (
+		stateOpt     = cmd.StringOpt("state", "", "Path to the state file. It is created on the first run")
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+		ignoreOpt    = cmd.StringOpt("ignore", "last_seen,updated", "Comma separated list of fields whose changes alone don't count as a change")
+		resetOpt     = cmd.BoolOpt("reset", false, "Ignore the existing state and emit every device")
+		dryRunOpt    = cmd.BoolOpt("dry-run", false, "Emit the changes but don't update the state file")
+	)
+
+	cmd.Spec = "--state [OPTIONS]"
+
+	cmd.LongDesc = `
+Emits only the devices that changed since the previous run, as one JSON object
+per line:
+
+    {"action":"new","serial":"...","device":{...}}
+    {"action":"changed","serial":"...","device":{...}}
+    {"action":"removed","serial":"..."}
+
+The device objects carry the same fields as 'conch export cmdb'. The first run,
+or any run with --reset, emits every device as new.
+
+Changes are found by comparing each device against a fingerprint kept in the
+state file, so a run costs a handful of list requests no matter how large the
+workspace is. By default, a device that has only reported in again (a new
+last_seen, and so a new updated) is not considered changed. Use --ignore ""
+to count those too.
+
+The state file is only updated after all changes have been written, so a
+failed run is simply repeated the next time.`
+
+	cmd.Action = func() {
+		statePath, err := homedir.Expand(*stateOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		ignored := make([]string, 0)
+		for _, f := range strings.Split(*ignoreOpt, ",") {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			if !isRecordField(f) {
+				util.Bail(fmt.Errorf(
+					"unknown field '%s'. Available fields: %s",
+					f,
+					strings.Join(recordFields, ", "),
+				))
+			}
+			ignored = append(ignored, f)
+		}
+		sort.Strings(ignored)
+
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		previous, found, err := readSyncState(statePath)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if found && !*resetOpt {
+			if previous.WorkspaceID != workspaceID.String() {
+				util.Bail(fmt.Errorf(
+					"state file %s belongs to workspace %s. Use --reset to start over with this workspace",
+					statePath,
+					previous.WorkspaceID,
+				))
+			}
+
+			// Different ignored fields produce different fingerprints, which
+			// would make every device look changed
+			if !sameFields(previous.Ignored, ignored) {
+				util.Bail(fmt.Errorf(
+					"state file %s was built with --ignore '%s'. Use --reset to start over with new fields",
+					statePath,
+					strings.Join(previous.Ignored, ","),
+				))
+			}
+		} else {
+			previous = syncState{Devices: make(map[string]string)}
+		}
+
+		started := time.Now().UTC()
+
+		records, err := deviceRecords(workspaceID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		state := syncState{
+			WorkspaceID: workspaceID.String(),
+			LastRun:     started,
+			Ignored:     ignored,
+			Devices:     make(map[string]string),
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		counts := make(map[string]int)
+		emit := func(c syncChange) {
+			if err := enc.Encode(c); err != nil {
+				util.Bail(err)
+			}
+			counts[c.Action]++
+		}
+
+		for _, r := range records {
+			serial := r["serial"].(string)
+
+			fp, err := fingerprint(r, ignored)
+			if err != nil {
+				util.Bail(err)
+			}
+			state.Devices[serial] = fp
+
+			old, ok := previous.Devices[serial]
+			switch {
+			case !ok:
+				emit(syncChange{Action: "new", Serial: serial, Device: r})
+			case old != fp:
+				emit(syncChange{Action: "changed", Serial: serial, Device: r})
+			}
+		}
+
+		removed := make([]string, 0)
+		for serial := range previous.Devices {
+			if _, ok := state.Devices[serial]; !ok {
+				removed = append(removed, serial)
+			}
+		}
+		sort.Strings(removed)
+		for _, serial := range removed {
+			emit(syncChange{Action: "removed", Serial: serial})
+		}
+
+		if !*dryRunOpt {
+			if err := writeSyncState(statePath, state); err != nil {
+				util.Bail(err)
+			}
+		}
+
+		fmt.Fprintf(
+			os.Stderr,
+			"%d new, %d changed, %d removed, %d unchanged\n",
+			counts["new"],
+			counts["changed"],
+			counts["removed"],
+			len(records)-counts["new"]-counts["changed"],
+		)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// sheet is a named table of strings. The first row is the header.
+type sheet struct {
+	Name string
+	Rows [][]string
+}
+
+// This is the bare minimum of SpreadsheetML that Excel, LibreOffice, and
+// Google Sheets will open. Every cell is an inline string so that serial
+// numbers and the like keep their leading zeroes.
+const (
+	xlsxContentTypesHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>
+`
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+</styleSheet>
+`
+)
+
+// xlsxColumn converts a zero-based column index into a spreadsheet column
+// name, eg 0 -> A, 27 -> AB
+func xlsxColumn(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+func xlsxEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func writeXLSXSheet(w io.Writer, s sheet) {
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n")
+	fmt.Fprint(w, `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	fmt.Fprint(w, `<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	fmt.Fprint(w, "<sheetData>")
+	for r, row := range s.Rows {
+		fmt.Fprintf(w, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			style := ""
+			if r == 0 {
+				style = ` s="1"`
+			}
+			fmt.Fprintf(
+				w,
+				`<c r="%s%d" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`,
+				xlsxColumn(c),
+				r+1,
+				style,
+				xlsxEscape(cell),
+			)
+		}
+		fmt.Fprint(w, "</row>")
+	}
+	fmt.Fprint(w, "</sheetData></worksheet>\n")
+}
+
+// writeXLSX writes the sheets out as an Office Open XML workbook
+func writeXLSX(path string, sheets []sheet) error {
+	f, err := util.CreateAtomic(path, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Abandon()
+
+	z := zip.NewWriter(f)
+
+	add := func(name string, content string) error {
+		w, err := z.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, content)
+		return err
+	}
+
+	var types, workbook, rels bytes.Buffer
+
+	types.WriteString(xlsxContentTypesHead)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+`)
+
+	for i, s := range sheets {
+		n := i + 1
+		fmt.Fprintf(
+			&types,
+			`<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`+"\n",
+			n,
+		)
+		fmt.Fprintf(
+			&workbook,
+			`<sheet name="%s" sheetId="%d" r:id="rId%d"/>`,
+			xlsxEscape(s.Name),
+			n,
+			n,
+		)
+		fmt.Fprintf(
+			&rels,
+			`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`+"\n",
+			n,
+			n,
+		)
+
+		w, err := z.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", n))
+		if err != nil {
+			return err
+		}
+		writeXLSXSheet(w, s)
+	}
+
+	fmt.Fprintf(
+		&rels,
+		`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`+"\n",
+		len(sheets)+1,
+	)
+
+	types.WriteString("</Types>\n")
+	workbook.WriteString("</sheets></workbook>\n")
+	rels.WriteString("</Relationships>\n")
+
+	for _, part := range []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", types.String()},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		if err := add(part.name, part.content); err != nil {
+			return err
+		}
+	}
+
+	if err := z.Close(); err != nil {
+		return err
+	}
+	return f.Commit()
+}
@@ -32,24 +32,31 @@ var GLayoutUUID uuid.UUID
 
 // Init loads up the commands
 func Init(app *cli.Cli) {
+	registerOutputs()
 
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"global system",
 		"Execute commands against objects without concern for workspaces. System admin access is required.",
 		func(cmd *cli.Cmd) {
-			cmd.Before = util.BuildAPIAndVerifyLogin
+			cmd.Before = util.BuildAPIRequiringAdmin("The global commands")
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"datacenters dcs",
 				"Operate on all datacenters",
 				func(dcs *cli.Cmd) {
-					dcs.Command(
+					dcs.Before = func() { util.RequireFeature("datacenters") }
+
+					util.Command(
+						dcs,
 						"get",
 						"Get all datacenters",
 						dcGetAll,
 					)
 
-					dcs.Command(
+					util.Command(
+						dcs,
 						"create",
 						"Create a datacenter",
 						dcCreate,
@@ -57,7 +64,8 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"datacenter dc",
 				"Operate on individual datacenters",
 				func(dc *cli.Cmd) {
@@ -65,6 +73,7 @@ func Init(app *cli.Cli) {
 
 					dc.Spec = "ID"
 					dc.Before = func() {
+						util.RequireFeature("datacenters")
 						id, err := util.MagicDatacenterID(*gdcIDStr)
 						if err != nil {
 							util.Bail(err)
@@ -72,31 +81,36 @@ func Init(app *cli.Cli) {
 						GdcUUID = id
 					}
 
-					dc.Command(
+					util.Command(
+						dc,
 						"get",
 						"Get a datacenter",
 						dcGet,
 					)
 
-					dc.Command(
+					util.Command(
+						dc,
 						"delete rm",
 						"Delete a datacenter",
 						dcDelete,
 					)
 
-					dc.Command(
+					util.Command(
+						dc,
 						"update",
 						"Update a datacenter",
 						dcUpdate,
 					)
 
-					dc.Command(
+					util.Command(
+						dc,
 						"rooms",
 						"Get all rooms assigned to a datacenter",
 						dcGetAllRooms,
 					)
 
-					dc.Command(
+					util.Command(
+						dc,
 						"layout-tree",
 						"Get a tree of the datacenter, its rooms, racks, and layouts",
 						dcAllTheThingsTree,
@@ -104,17 +118,20 @@ func Init(app *cli.Cli) {
 				},
 			)
 			/////////////////////////////////
-			cmd.Command(
+			util.Command(
+				cmd,
 				"rooms rs",
 				"Operate on all rooms",
 				func(rs *cli.Cmd) {
-					rs.Command(
+					util.Command(
+						rs,
 						"get",
 						"Get all rooms",
 						roomGetAll,
 					)
 
-					rs.Command(
+					util.Command(
+						rs,
 						"create",
 						"Create a room",
 						roomCreate,
@@ -122,7 +139,8 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"room r",
 				"Operate on individual rooms",
 				func(r *cli.Cmd) {
@@ -137,25 +155,29 @@ func Init(app *cli.Cli) {
 						GRoomUUID = id
 					}
 
-					r.Command(
+					util.Command(
+						r,
 						"get",
 						"Get a room",
 						roomGet,
 					)
 
-					r.Command(
+					util.Command(
+						r,
 						"delete rm",
 						"Delete a room",
 						roomDelete,
 					)
 
-					r.Command(
+					util.Command(
+						r,
 						"update",
 						"Update a room",
 						roomUpdate,
 					)
 
-					r.Command(
+					util.Command(
+						r,
 						"racks",
 						"Get all racks assigned to the room",
 						roomGetAllRacks,
@@ -165,17 +187,20 @@ func Init(app *cli.Cli) {
 			)
 
 			/////////////////////////////////
-			cmd.Command(
+			util.Command(
+				cmd,
 				"racks rks",
 				"Operate on all racks",
 				func(rs *cli.Cmd) {
-					rs.Command(
+					util.Command(
+						rs,
 						"get",
 						"Get all racks",
 						rackGetAll,
 					)
 
-					rs.Command(
+					util.Command(
+						rs,
 						"create",
 						"Create a rack",
 						rackCreate,
@@ -183,7 +208,8 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"rack rk",
 				"Operate on individual racks",
 				func(r *cli.Cmd) {
@@ -191,6 +217,9 @@ func Init(app *cli.Cli) {
 
 					r.Spec = "ID"
 					r.Before = func() {
+						// Layouts look up the same hardware products over and over
+						util.API.EnableMemoization()
+
 						id, err := util.MagicRackID(*rackIDStr)
 						if err != nil {
 							util.Bail(err)
@@ -198,41 +227,48 @@ func Init(app *cli.Cli) {
 						GRackUUID = id
 					}
 
-					r.Command(
+					util.Command(
+						r,
 						"get",
 						"Get a rack",
 						rackGet,
 					)
 
-					r.Command(
+					util.Command(
+						r,
 						"delete rm",
 						"Delete a rack",
 						rackDelete,
 					)
 
-					r.Command(
+					util.Command(
+						r,
 						"update",
 						"Update a rack",
 						rackUpdate,
 					)
 
-					r.Command(
+					util.Command(
+						r,
 						"layout",
 						"Commands for dealing with the rack's layout",
 						func(l *cli.Cmd) {
-							l.Command(
+							util.Command(
+								l,
 								"get",
 								"Get the rack's layout",
 								rackLayout,
 							)
 
-							l.Command(
+							util.Command(
+								l,
 								"import",
 								"Import a layout for this rack",
 								rackImportLayout,
 							)
 
-							l.Command(
+							util.Command(
+								l,
 								"export",
 								"Export the layout for this rack",
 								rackExportLayout,
@@ -243,17 +279,22 @@ func Init(app *cli.Cli) {
 			)
 
 			/////////////////////////////////
-			cmd.Command(
+			util.Command(
+				cmd,
 				"roles ros",
 				"Operate on all roles",
 				func(rs *cli.Cmd) {
-					rs.Command(
+					rs.Before = func() { util.RequireFeature("rack-roles") }
+
+					util.Command(
+						rs,
 						"get",
 						"Get all roles",
 						roleGetAll,
 					)
 
-					rs.Command(
+					util.Command(
+						rs,
 						"create",
 						"Create a role",
 						roleCreate,
@@ -261,7 +302,8 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"role ro",
 				"Operate on individual roles",
 				func(r *cli.Cmd) {
@@ -269,6 +311,7 @@ func Init(app *cli.Cli) {
 
 					r.Spec = "ID"
 					r.Before = func() {
+						util.RequireFeature("rack-roles")
 						id, err := util.MagicRackRoleID(*roleIDStr)
 						if err != nil {
 							util.Bail(err)
@@ -276,19 +319,22 @@ func Init(app *cli.Cli) {
 						GRoleUUID = id
 					}
 
-					r.Command(
+					util.Command(
+						r,
 						"get",
 						"Get a role",
 						roleGet,
 					)
 
-					r.Command(
+					util.Command(
+						r,
 						"delete rm",
 						"Delete a role",
 						roleDelete,
 					)
 
-					r.Command(
+					util.Command(
+						r,
 						"update",
 						"Update a role",
 						roleUpdate,
@@ -297,12 +343,15 @@ func Init(app *cli.Cli) {
 			)
 
 			/////////////////////////////////
-			cmd.Command(
+			util.Command(
+				cmd,
 				"layouts ls",
 				"Operate on all rack layouts",
 				func(rs *cli.Cmd) {
+					rs.Before = func() { util.RequireFeature("rack-layouts") }
 
-					rs.Command(
+					util.Command(
+						rs,
 						"create",
 						"Create a layout",
 						layoutCreate,
@@ -310,7 +359,8 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"layout l",
 				"Operate on individual layout entries",
 				func(r *cli.Cmd) {
@@ -318,6 +368,7 @@ func Init(app *cli.Cli) {
 
 					r.Spec = "ID"
 					r.Before = func() {
+						util.RequireFeature("rack-layouts")
 						id, err := util.MagicRackLayoutSlotID(*layoutIDStr)
 						if err != nil {
 							util.Bail(err)
@@ -325,19 +376,22 @@ func Init(app *cli.Cli) {
 						GLayoutUUID = id
 					}
 
-					r.Command(
+					util.Command(
+						r,
 						"get",
 						"Get a layout",
 						layoutGet,
 					)
 
-					r.Command(
+					util.Command(
+						r,
 						"delete rm",
 						"Delete a layout",
 						layoutDelete,
 					)
 
-					r.Command(
+					util.Command(
+						r,
 						"update",
 						"Update a layout",
 						layoutUpdate,
@@ -73,7 +73,7 @@ func layoutCreate(app *cli.Cmd) {
 			RUStart:   *ruStartOpt,
 		}
 
-		if err := util.API.SaveRackLayoutSlot(&r); err != nil {
+		if err := util.API.SaveRackLayoutSlotChecked(&r); err != nil {
 			util.Bail(err)
 		}
 
@@ -135,7 +135,7 @@ func layoutUpdate(app *cli.Cmd) {
 			r.RUStart = *ruStartOpt
 		}
 
-		if err := util.API.SaveRackLayoutSlot(r); err != nil {
+		if err := util.API.SaveRackLayoutSlotChecked(r); err != nil {
 			util.Bail(err)
 		}
 
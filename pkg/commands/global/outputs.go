@@ -0,0 +1,41 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package global
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("global datacenters get", []conch.Datacenter{})
+	util.RegisterOutput("global datacenters create", conch.Datacenter{})
+	util.RegisterOutput("global datacenter get", conch.Datacenter{})
+	util.RegisterOutput("global datacenter update", conch.Datacenter{})
+	util.RegisterOutput("global datacenter rooms", []conch.Room{})
+
+	util.RegisterOutput("global rooms get", []conch.Room{})
+	util.RegisterOutput("global rooms create", conch.Room{})
+	util.RegisterOutput("global room get", conch.Room{})
+	util.RegisterOutput("global room update", conch.Room{})
+	util.RegisterOutput("global room racks", []conch.Rack{})
+
+	util.RegisterOutput("global racks get", []conch.Rack{})
+	util.RegisterOutput("global racks create", conch.Rack{})
+	util.RegisterOutput("global rack get", conch.Rack{})
+	util.RegisterOutput("global rack layout get", conch.RackLayoutSlots{})
+	util.RegisterOutput("global rack layout export", importLayout{})
+
+	util.RegisterOutput("global roles get", []conch.RackRole{})
+	util.RegisterOutput("global roles create", conch.RackRole{})
+	util.RegisterOutput("global role get", conch.RackRole{})
+
+	util.RegisterOutput("global layouts create", conch.RackLayoutSlot{})
+	util.RegisterOutput("global layout get", conch.RackLayoutSlot{})
+	util.RegisterOutput("global layout update", conch.RackLayoutSlot{})
+}
@@ -159,6 +159,8 @@ func rackUpdate(app *cli.Cmd) {
 		if err != nil {
 			util.Bail(err)
 		}
+		before := r
+
 		if *dcIDOpt != "" {
 			dcID, err := uuid.FromString(*dcIDOpt)
 			if err != nil {
@@ -191,15 +193,19 @@ func rackUpdate(app *cli.Cmd) {
 			util.Bail(err)
 		}
 
-		if util.JSON {
-			util.JSONOut(r)
-			return
+		after, err := util.API.GetRack(r.ID)
+		if err != nil {
+			util.Bail(err)
 		}
-		displayOneRack(r)
+		util.DisplayFieldDiff("rack "+after.Name, util.FieldDiff(before, after))
 	}
 }
 func rackDelete(app *cli.Cmd) {
+	var breakGlassOpts = util.NewBreakGlassOpts(app)
+
 	app.Action = func() {
+		util.BreakGlassRackDelete(breakGlassOpts, GRackUUID)
+
 		if err := util.API.DeleteRack(GRackUUID); err != nil {
 			util.Bail(err)
 		}
@@ -302,11 +308,14 @@ func rackImportLayout(cmd *cli.Cmd) {
 	var (
 		filePathArg  = cmd.StringArg("FILE", "-", "Path to a JSON file that defines the layout. '-' indicates STDIN")
 		overwriteOpt = cmd.BoolOpt("overwrite", false, "If the rack has an existing layout, *overwrite* it. This is a destructive action")
+		planOpts     = util.NewPlanOpts(cmd)
 	)
 
 	cmd.Spec = "[OPTIONS] [FILE]"
+
+	util.NotifyOpt(cmd, "global rack layout import")
+
 	cmd.Action = func() {
-		util.JSON = true
 		var b []byte
 		var err error
 		if *filePathArg == "-" {
@@ -344,8 +353,9 @@ func rackImportLayout(cmd *cli.Cmd) {
 		// We need to support the use of product names and aliases in the
 		// import so they're readable by humans. We lack a way of doing API
 		// lookups on these properties so we pull them all down and create maps
-		// on our own.
-		productsL, err := util.API.GetHardwareProducts()
+		// on our own. Deactivated products are included so that layouts
+		// that use them can be warned about, rather than failing outright.
+		productsL, err := util.API.GetAllHardwareProducts()
 		if err != nil {
 			util.Bail(err)
 		}
@@ -402,27 +412,79 @@ func rackImportLayout(cmd *cli.Cmd) {
 			finalLayout = append(finalLayout, s)
 		}
 
-		// If the rack has a layout, and the user asked us to, nuke the
-		// existing layout
-		if *overwriteOpt {
-			for _, s := range existingLayout {
-				err := util.API.DeleteRackLayoutSlot(s.ID)
-				if err != nil {
-					util.Bail(err)
-				}
+		// The server only notices overlaps once it gets to them, by which time
+		// part of the layout has been changed
+		if err := conch.CheckRackLayout(finalLayout, conch.RackUnitSizes(productsL)); err != nil {
+			util.Bail(fmt.Errorf("%s. Nothing was changed", err))
+		}
+
+		util.WarnRackBudget(rack, finalLayout, productsL)
+
+		// Work out the difference between the existing layout and the import
+		// before touching anything. That way, if the import has problems, we
+		// haven't changed any data yet and the user gets to see what's about
+		// to happen.
+		plan := util.NewPlan()
+
+		wanted := make(map[int]bool)
+		for _, s := range finalLayout {
+			wanted[s.RUStart] = true
+		}
+
+		existingByRU := make(map[int]conch.RackLayoutSlot)
+		sort.Sort(existingLayout)
+		for _, s := range existingLayout {
+			s := s
+			existingByRU[s.RUStart] = s
+			if wanted[s.RUStart] {
+				continue
 			}
+			plan.Add(
+				util.PlanDelete,
+				"layout slot",
+				fmt.Sprintf("%s ru %d", rack.Name, s.RUStart),
+				productsID[s.ProductID.String()].Name,
+				func() error { return util.API.DeleteRackLayoutSlot(s.ID) },
+			)
 		}
 
-		// Yes, technically, doing this in two loops is unnecessary. It gets us
-		// a bit of sanity though. First loop verifies the data and the second
-		// loop acts on it. That way, if the first loop runs into problems, we
-		// haven't changed any data yet.
 		for _, s := range finalLayout {
-			err := util.API.SaveRackLayoutSlot(&s)
-			if err != nil {
-				util.Bail(err)
+			s := s
+			name := fmt.Sprintf("%s ru %d", rack.Name, s.RUStart)
+			product := productsID[s.ProductID.String()].Name
+
+			old, ok := existingByRU[s.RUStart]
+			if ok && uuid.Equal(old.ProductID, s.ProductID) {
+				continue
+			}
+
+			if d := productsID[s.ProductID.String()].Deactivated; !d.IsZero() {
+				fmt.Fprintf(
+					os.Stderr,
+					"Warning: %s uses hardware product %s, which was deactivated on %s\n",
+					name,
+					product,
+					util.TimeStr(d),
+				)
 			}
+
+			if !ok {
+				plan.Add(util.PlanCreate, "layout slot", name, product, func() error {
+					return util.API.SaveRackLayoutSlot(&s)
+				})
+				continue
+			}
+
+			s.ID = old.ID
+			plan.Add(
+				util.PlanUpdate,
+				"layout slot",
+				name,
+				productsID[old.ProductID.String()].Name+" -> "+product,
+				func() error { return util.API.SaveRackLayoutSlot(&s) },
+			)
 		}
 
+		plan.Run(planOpts)
 	}
 }
@@ -130,6 +130,7 @@ func roleUpdate(app *cli.Cmd) {
 		if err != nil {
 			util.Bail(err)
 		}
+		before := r
 
 		if *nameOpt != "" {
 			r.Name = *nameOpt
@@ -143,26 +144,11 @@ func roleUpdate(app *cli.Cmd) {
 			util.Bail(err)
 		}
 
-		if util.JSON {
-			util.JSONOut(r)
-			return
+		after, err := util.API.GetRackRole(r.ID)
+		if err != nil {
+			util.Bail(err)
 		}
-
-		fmt.Printf(`
-ID: %s
-Name: %s
-Rack Size: %d
-
-Created: %s
-Updated: %s
-
-`,
-			r.ID.String(),
-			r.Name,
-			r.RackSize,
-			util.TimeStr(r.Created),
-			util.TimeStr(r.Updated),
-		)
+		util.DisplayFieldDiff("rack role "+after.Name, util.FieldDiff(before, after))
 	}
 }
 
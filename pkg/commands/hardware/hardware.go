@@ -30,7 +30,8 @@ ID: {{ .ID }}
   SKU:  {{ .SKU }}
   Generation Name: {{ .GenerationName }}
   Vendor: {{ .Vendor }}
-  Prefix: {{ .Prefix }}
+  Prefix: {{ .Prefix }}{{ if not .Deactivated.IsZero }}
+  Deactivated: {{ .Deactivated }}{{ end }}
 
   Profile: {{ .Profile.ID }}
     Purpose: {{ .Profile.Purpose }}
@@ -46,28 +47,28 @@ ID: {{ .ID }}
       Raid LUN Count {{ .Profile.RaidLunNum }}
 
     DIMM Count: {{ .Profile.NumDimms }}
-    RAM Total:  {{ .Profile.TotalRAM }} GB
+    RAM Total:  {{ gibibytes .Profile.TotalRAM }}
 
     Drives:
     {{ if .Profile.SasHddNum }}
       SAS HDD:
         Count: {{ .Profile.SasHddNum }}
-        Size:  {{ .Profile.SasHddSize }}
+        Size:  {{ gigabytes .Profile.SasHddSize }}
         Slots: {{ .Profile.SasHddSlots }}
     {{ end }}{{ if .Profile.SataHddNum }}
       SATA HDD:
         Count: {{ .Profile.SataHddNum }}
-        Size:  {{ .Profile.SataHddSize }}
+        Size:  {{ gigabytes .Profile.SataHddSize }}
         Slots: {{ .Profile.SataHddSlots }}
     {{ end }}{{ if .Profile.SataSsdNum }}
       SATA SSD:
         Count: {{ .Profile.SataSsdNum }}
-        Size:  {{ .Profile.SataSsdSize }}
+        Size:  {{ gigabytes .Profile.SataSsdSize }}
         Slots: {{ .Profile.SataSsdSlots }}
     {{ end }}{{ if .Profile.NvmeSsdNum }}
       NVME SSD:
         Count: {{ .Profile.NvmeSsdNum }}
-        Size:  {{ .Profile.NvmeSsdSize }}
+        Size:  {{ gigabytes .Profile.NvmeSsdSize }}
         Slots: {{ .Profile.NvmeSsdSlots }}
     {{ end }}
 `
@@ -159,7 +160,7 @@ func getOne(app *cli.Cmd) {
 
 		extRet := extendedProduct{&ret, vendor_name}
 
-		t, err := template.New("hw").Parse(singleHWPTemplate)
+		t, err := template.New("hw").Funcs(util.SizeFuncs).Parse(singleHWPTemplate)
 		if err != nil {
 			util.Bail(err)
 		}
@@ -196,18 +197,53 @@ func getOneSpecification(app *cli.Cmd) {
 	}
 }
 
+// productRow is a hardware product as 'hardware products get' lists it
+type productRow struct {
+	ID      string `json:"id"`
+	SKU     string `json:"sku"`
+	Name    string `json:"name"`
+	Alias   string `json:"alias"`
+	Prefix  string `json:"prefix"`
+	Vendor  string `json:"vendor"`
+	Purpose string `json:"purpose"`
+
+	Deactivated string `json:"deactivated,omitempty"`
+}
+
 func getAll(app *cli.Cmd) {
 	var (
 		fullOutput = app.BoolOpt("full", false, "When --ids-only is *not* used, provide additional data about the devices rather than normal truncated data.")
 		idsOnly    = app.BoolOpt("ids-only", false, "Only retrieve hardware product IDs")
+		allOpt     = app.BoolOpt("all a", false, "Include deactivated hardware products")
+		deadOpt    = app.BoolOpt("deactivated", false, "Only list deactivated hardware products")
 	)
 
 	app.Action = func() {
-		ret, err := util.API.GetHardwareProducts()
+		if *allOpt && *deadOpt {
+			util.Bail(errors.New("--all and --deactivated can't be used together"))
+		}
+
+		var ret []conch.HardwareProduct
+		var err error
+		if *allOpt || *deadOpt {
+			ret, err = util.API.GetAllHardwareProducts()
+		} else {
+			ret, err = util.API.GetHardwareProducts()
+		}
 		if err != nil {
 			util.Bail(err)
 		}
 
+		if *deadOpt {
+			dead := make([]conch.HardwareProduct, 0)
+			for _, r := range ret {
+				if !r.Deactivated.IsZero() {
+					dead = append(dead, r)
+				}
+			}
+			ret = dead
+		}
+
 		if *idsOnly {
 			ids := make([]string, 0)
 			for _, r := range ret {
@@ -228,7 +264,7 @@ func getAll(app *cli.Cmd) {
 				util.JSONOut(ret)
 				return
 			}
-			t, err := template.New("hw").Parse(singleHWPTemplate)
+			t, err := template.New("hw").Funcs(util.SizeFuncs).Parse(singleHWPTemplate)
 			if err != nil {
 				util.Bail(err)
 			}
@@ -239,16 +275,7 @@ func getAll(app *cli.Cmd) {
 			}
 			return
 		}
-		type retRow struct {
-			ID      string `json:"id"`
-			SKU     string `json:"sku"`
-			Name    string `json:"name"`
-			Alias   string `json:"alias"`
-			Prefix  string `json:"prefix"`
-			Vendor  string `json:"vendor"`
-			Purpose string `json:"purpose"`
-		}
-		rows := make([]retRow, 0)
+		rows := make([]productRow, 0)
 		for _, r := range ret {
 			var vendor_name string
 
@@ -260,7 +287,12 @@ func getAll(app *cli.Cmd) {
 				vendor_name = vendor.Name
 			}
 
-			rows = append(rows, retRow{
+			var deactivated string
+			if !r.Deactivated.IsZero() {
+				deactivated = util.TimeStr(r.Deactivated)
+			}
+
+			rows = append(rows, productRow{
 				r.ID.String(),
 				r.SKU,
 				r.Name,
@@ -268,6 +300,7 @@ func getAll(app *cli.Cmd) {
 				r.Prefix,
 				vendor_name,
 				r.Profile.Purpose,
+				deactivated,
 			})
 		}
 
@@ -276,11 +309,23 @@ func getAll(app *cli.Cmd) {
 			return
 		}
 
+		// Only the listings that can hold deactivated products need to
+		// say which ones they are
+		showDeactivated := *allOpt || *deadOpt
+
 		table := util.GetMarkdownTable()
-		table.SetHeader([]string{"ID", "SKU", "Name", "Alias", "Prefix", "Vendor", "Purpose"})
+		header := []string{"ID", "SKU", "Name", "Alias", "Prefix", "Vendor", "Purpose"}
+		if showDeactivated {
+			header = append(header, "Deactivated")
+		}
+		table.SetHeader(header)
 
 		for _, r := range rows {
-			table.Append([]string{r.ID, r.SKU, r.Name, r.Alias, r.Prefix, r.Vendor, r.Purpose})
+			row := []string{r.ID, r.SKU, r.Name, r.Alias, r.Prefix, r.Vendor, r.Purpose}
+			if showDeactivated {
+				row = append(row, r.Deactivated)
+			}
+			table.Append(row)
 		}
 
 		table.Render()
@@ -379,6 +424,7 @@ func updateOne(app *cli.Cmd) {
 		if err != nil {
 			util.Bail(err)
 		}
+		before := h
 
 		if *nameOpt != "" {
 			h.Name = *nameOpt
@@ -439,10 +485,7 @@ func updateOne(app *cli.Cmd) {
 			util.Bail(err)
 		}
 
-		if util.JSON {
-			util.JSONOut(ret)
-			return
-		}
+		util.DisplayFieldDiff("hardware product "+ret.Name, util.FieldDiff(before, ret))
 	}
 }
 
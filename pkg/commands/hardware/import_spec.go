@@ -0,0 +1,111 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// importSpec fills in a hardware product's profile from a vendor's
+// description of the system, such as a Redfish inventory dump
+func importSpec(app *cli.Cmd) {
+	var (
+		vendorOpt = app.StringOpt(
+			"vendor",
+			"",
+			"The format of the spec file. One of: "+strings.Join(conch.SpecTranslators(), ", "),
+		)
+		fileOpt   = app.StringOpt("file f", "-", "Path to the vendor's spec file. '-' indicates STDIN")
+		dryRunOpt = app.BoolOpt("dry-run", false, "Show what would change without changing anything")
+	)
+	app.Spec = "--vendor [OPTIONS]"
+
+	app.Action = func() {
+		var (
+			b   []byte
+			err error
+		)
+		if *fileOpt == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(*fileOpt)
+		}
+		if err != nil {
+			util.Bail(err)
+		}
+		if len(string(b)) <= 1 {
+			util.Bail(errors.New("no spec provided"))
+		}
+
+		spec, err := conch.TranslateSpec(*vendorOpt, b)
+		if err != nil {
+			util.Bail(err)
+		}
+		for _, w := range spec.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+		if len(spec.Fields) == 0 {
+			util.Bail(errors.New("nothing in the spec maps onto a hardware profile"))
+		}
+
+		h, err := util.API.GetHardwareProduct(ProductUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+		before := h
+
+		h.Profile, err = conch.ApplySpecImport(h.Profile, spec)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if *dryRunOpt {
+			changes := util.FieldDiff(before, h)
+			if util.JSON {
+				util.JSONOut(changes)
+				return
+			}
+			if len(changes) == 0 {
+				fmt.Printf("The spec matches hardware product %s\n", h.Name)
+				return
+			}
+			fmt.Printf("Would update hardware product %s:\n\n", h.Name)
+			table := util.GetMarkdownTable()
+			table.SetHeader([]string{"Field", "Before", "After"})
+			for _, c := range changes {
+				table.Append([]string{
+					c.Field,
+					fmt.Sprintf("%v", c.Before),
+					fmt.Sprintf("%v", c.After),
+				})
+			}
+			table.Render()
+			return
+		}
+
+		verifyHardwareProduct(h)
+
+		if err := util.API.SaveHardwareProduct(&h); err != nil {
+			util.Bail(err)
+		}
+
+		ret, err := util.API.GetHardwareProduct(h.ID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		util.DisplayFieldDiff("hardware product "+ret.Name, util.FieldDiff(before, ret))
+	}
+}
@@ -25,7 +25,10 @@ var HardwareVendorName string
 
 // Init loads up the hardware commands
 func Init(app *cli.Cli) {
-	app.Command(
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
 		"hardware h",
 		"Commands for dealing with hardware products",
 		func(cmd *cli.Cmd) {
@@ -33,30 +36,35 @@ func Init(app *cli.Cli) {
 				util.BuildAPIAndVerifyLogin()
 			}
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"products ps",
 				"Deal with hardware products",
 				func(cmd *cli.Cmd) {
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get",
 						"Get a list of all hardware products",
 						getAll,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"create",
 						"Create a hardware product",
 						createOne,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"template",
 						"Dumping a JSON template for a hardware product. Used in creating a new product and profile",
 						dumpTemplate,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"import",
 						"Import a JSON file that defines a new hardware product",
 						importNewProductJson,
@@ -64,7 +72,8 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"product p",
 				"Deal with a single hardware product",
 				func(cmd *cli.Cmd) {
@@ -78,52 +87,123 @@ func Init(app *cli.Cli) {
 						}
 					}
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get",
 						"Get a single hardware product",
 						getOne,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get_specification",
 						"Get the hardware specification json blob",
 						getOneSpecification,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"delete rm",
 						"Delete a hardware product",
 						removeOne,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
+						"deactivate",
+						"Retire a hardware product so that it is no longer used in new rack layouts",
+						deactivateOne,
+					)
+
+					util.Command(
+						cmd,
+						"reactivate",
+						"Bring back a deactivated hardware product",
+						reactivateOne,
+					)
+
+					util.Command(
+						cmd,
 						"update up",
 						"Update a hardware product",
 						updateOne,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"export",
 						"Dump the JSON representation of a hardware product and profile. Intended for use with 'import'",
 						exportProductJson,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"import",
 						"Update an existing hardware product and profile using a JSON file",
 						importChangedProductJson,
 					)
 
+					util.Command(
+						cmd,
+						"import-spec",
+						"Fill in the hardware profile from a vendor's system spec, like a Redfish inventory dump",
+						importSpec,
+					)
+
+					util.Command(
+						cmd,
+						"validation-plan",
+						"Get/set the validation plan for devices of this hardware product",
+						func(cmd *cli.Cmd) {
+							util.Command(
+								cmd,
+								"get",
+								"Get the hardware product's validation plan",
+								getValidationPlan,
+							)
+
+							util.Command(
+								cmd,
+								"set",
+								"Set the hardware product's validation plan",
+								setValidationPlan,
+							)
+						},
+					)
+
+					util.Command(
+						cmd,
+						"settings-template",
+						"Deal with the canonical device settings for this hardware product",
+						func(cmd *cli.Cmd) {
+							util.Command(
+								cmd,
+								"get",
+								"Get the settings template",
+								getSettingsTemplate,
+							)
+
+							util.Command(
+								cmd,
+								"set",
+								"Replace the settings template using a JSON file",
+								setSettingsTemplate,
+							)
+						},
+					)
+
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"vendors vs",
 				"Get a list of all hardware vendors",
 				getAllVendors,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"vendor v",
 				"Deal with a hardware vendor",
 				func(cmd *cli.Cmd) {
@@ -131,22 +211,26 @@ func Init(app *cli.Cli) {
 					cmd.Spec = "NAME"
 
 					cmd.Before = func() {
+						util.RequireFeature("hardware-vendors")
 						HardwareVendorName = *vendorNameStr
 					}
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get",
 						"Get a single vendor",
 						getOneVendor,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"create make mk",
 						"Create a single vendor",
 						createOneVendor,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"delete rm ",
 						"Delete a single vendor",
 						deleteOneVendor,
@@ -0,0 +1,64 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hardware
+
+import (
+	"fmt"
+
+	cli "github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func deactivateOne(app *cli.Cmd) {
+	app.LongDesc = `
+Retires a hardware product. It stays in the API, and existing devices and rack
+layouts keep pointing at it, but it drops out of the product listings. Rack
+layout imports warn when they use a deactivated product.`
+
+	app.Action = func() {
+		p, err := util.API.GetHardwareProduct(ProductUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+		if !p.Deactivated.IsZero() {
+			util.Bail(fmt.Errorf(
+				"hardware product %s was already deactivated on %s",
+				p.Name,
+				util.TimeStr(p.Deactivated),
+			))
+		}
+
+		if err := util.API.DeactivateHardwareProduct(ProductUUID); err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(map[string]string{"id": p.ID.String(), "name": p.Name})
+			return
+		}
+		fmt.Printf("Deactivated hardware product %s (%s)\n", p.Name, p.ID)
+	}
+}
+
+func reactivateOne(app *cli.Cmd) {
+	app.Action = func() {
+		if err := util.API.ReactivateHardwareProduct(ProductUUID); err != nil {
+			util.Bail(err)
+		}
+
+		p, err := util.API.GetHardwareProduct(ProductUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(p)
+			return
+		}
+		fmt.Printf("Reactivated hardware product %s (%s)\n", p.Name, p.ID)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hardware
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("hardware products get", []productRow{})
+	util.RegisterOutput("hardware products create", conch.HardwareProduct{})
+	util.RegisterOutput("hardware products template", generateDumpableProduct(conch.HardwareProduct{}))
+	util.RegisterOutput("hardware products import", conch.HardwareProduct{})
+
+	util.RegisterOutput("hardware product get", conch.HardwareProduct{})
+	util.RegisterOutput("hardware product deactivate", map[string]string{})
+	util.RegisterOutput("hardware product reactivate", conch.HardwareProduct{})
+	util.RegisterOutput("hardware product export", generateDumpableProduct(conch.HardwareProduct{}))
+	util.RegisterOutput("hardware product import", generateDumpableProduct(conch.HardwareProduct{}))
+	util.RegisterOutput("hardware product import-spec", []util.FieldChange{})
+	util.RegisterOutput("hardware product settings-template get", conch.SettingsTemplate{})
+	util.RegisterOutput("hardware product settings-template set", conch.SettingsTemplate{})
+
+	util.RegisterOutput("hardware product validation-plan get", util.ValidationPlanAssignment{})
+	util.RegisterOutput("hardware product validation-plan set", util.ValidationPlanAssignment{})
+
+	util.RegisterOutput("hardware vendors", []conch.HardwareVendor{})
+	util.RegisterOutput("hardware vendor get", conch.HardwareVendor{})
+	util.RegisterOutput("hardware vendor create", conch.HardwareVendor{})
+}
@@ -0,0 +1,106 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func displaySettingsTemplate(t conch.SettingsTemplate) {
+	if util.JSON {
+		util.JSONOut(t)
+		return
+	}
+
+	if len(t) == 0 {
+		fmt.Println("This hardware product has no settings template")
+		return
+	}
+
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Printf("%s : %s\n", k, t[k])
+	}
+}
+
+func getSettingsTemplate(app *cli.Cmd) {
+	app.Action = func() {
+		t, err := util.API.GetSettingsTemplate(ProductUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+		displaySettingsTemplate(t)
+	}
+}
+
+func setSettingsTemplate(app *cli.Cmd) {
+	var (
+		filePathArg = app.StringArg("FILE", "-", "Path to a JSON file containing the template. '-' indicates STDIN")
+	)
+	app.Spec = "FILE"
+
+	app.LongDesc = `
+The template is a JSON object of setting names to string values, the same
+shape 'conch device ID settings --json' produces:
+
+    {
+        "build.os": "smartos",
+        "ipmi.user": "root"
+    }
+
+Tags can't be part of a template. The template replaces any existing one, and
+an empty object removes it. It is stored in the hardware product's
+specification, under "settings_template".
+
+Use 'conch device ID settings apply-template' to bring devices in line with it.`
+
+	app.Action = func() {
+		var (
+			b   []byte
+			err error
+		)
+		if *filePathArg == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(*filePathArg)
+		}
+		if err != nil {
+			util.Bail(err)
+		}
+		if len(string(b)) <= 1 {
+			util.Bail(errors.New("no data provided"))
+		}
+
+		t, err := conch.ParseSettingsTemplate(b)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if err := util.API.SaveSettingsTemplate(ProductUUID, t); err != nil {
+			util.Bail(err)
+		}
+
+		saved, err := util.API.GetSettingsTemplate(ProductUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+		displaySettingsTemplate(saved)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hardware
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func productValidationPlan() conch.ValidationPlanAssignment {
+	a := conch.ValidationPlanAssignment{Source: conch.ValidationPlanUnassigned}
+
+	id, err := util.API.GetProductValidationPlan(ProductUUID)
+	if err != nil {
+		util.Bail(err)
+	}
+	if !uuid.Equal(id, uuid.UUID{}) {
+		a.PlanID = id
+		a.Source = conch.ValidationPlanFromProduct
+	}
+	return a
+}
+
+func getValidationPlan(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		util.DisplayValidationPlanAssignment(productValidationPlan())
+	}
+}
+
+func setValidationPlan(cmd *cli.Cmd) {
+	var (
+		planArg  = cmd.StringArg("PLAN", "", "The UUID or short UUID of the validation plan")
+		clearOpt = cmd.BoolOpt("clear", false, "Remove the product's plan")
+	)
+	cmd.Spec = "(PLAN | --clear)"
+
+	cmd.LongDesc = `
+Assigns a validation plan to every device of the hardware product, except for
+those that have one of their own. The plan is stored in the hardware product's
+specification, under "validation_plan".`
+
+	cmd.Action = func() {
+		vp, err := util.ValidationPlanToAssign(*planArg, *clearOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if err := util.API.SaveProductValidationPlan(ProductUUID, vp.ID); err != nil {
+			util.Bail(err)
+		}
+		util.DisplayValidationPlanAssignment(productValidationPlan())
+	}
+}
@@ -47,6 +47,7 @@ func getOneVendor(app *cli.Cmd) {
 
 func getAllVendors(app *cli.Cmd) {
 	app.Action = func() {
+		util.RequireFeature("hardware-vendors")
 		ret, err := util.API.GetHardwareVendors()
 		if err != nil {
 			util.Bail(err)
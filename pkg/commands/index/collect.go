@@ -0,0 +1,119 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package index
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// collect pulls the devices and racks of a workspace, plus every hardware
+// product, down from the API as searchable entries
+func collect(workspaceID uuid.UUID) (meta, []entry, error) {
+	entries := make([]entry, 0)
+	m := meta{
+		API:         util.API.BaseURL,
+		WorkspaceID: workspaceID.String(),
+		Counts:      make(map[string]int),
+	}
+
+	workspace, err := util.API.GetWorkspace(workspaceID)
+	if err != nil {
+		return m, entries, err
+	}
+	m.WorkspaceName = workspace.Name
+
+	vendors, err := util.API.GetHardwareVendors()
+	if err != nil {
+		return m, entries, err
+	}
+	vendorNames := make(map[uuid.UUID]string)
+	for _, v := range vendors {
+		vendorNames[v.ID] = v.Name
+	}
+
+	products, err := util.API.GetHardwareProducts()
+	if err != nil {
+		return m, entries, err
+	}
+	productMap := make(map[uuid.UUID]conch.HardwareProduct)
+	for _, p := range products {
+		productMap[p.ID] = p
+		entries = append(entries, entry{
+			Type: "product",
+			ID:   p.ID.String(),
+			Name: p.Name,
+			Fields: map[string]string{
+				"alias":      p.Alias,
+				"sku":        p.SKU,
+				"vendor":     vendorNames[p.HardwareVendorID],
+				"generation": p.GenerationName,
+				"legacy":     p.LegacyProductName,
+			},
+		})
+	}
+
+	racks, err := util.API.GetWorkspaceRacks(workspaceID)
+	if err != nil {
+		return m, entries, err
+	}
+	rackMap := make(map[uuid.UUID]conch.WorkspaceRack)
+	for _, r := range racks {
+		rackMap[r.ID] = r
+		entries = append(entries, entry{
+			Type: "rack",
+			ID:   r.ID.String(),
+			Name: r.Name,
+			Fields: map[string]string{
+				"room":          r.Datacenter,
+				"role":          r.Role,
+				"phase":         r.Phase,
+				"serial_number": r.SerialNumber,
+				"asset_tag":     r.AssetTag,
+			},
+		})
+	}
+
+	devices, err := util.API.GetWorkspaceDevices(workspaceID, false, "", "", "")
+	if err != nil {
+		return m, entries, err
+	}
+	for _, d := range devices {
+		rack := rackMap[d.RackID]
+
+		unit := ""
+		if d.RackUnitStart > 0 {
+			unit = strconv.Itoa(d.RackUnitStart)
+		}
+
+		entries = append(entries, entry{
+			Type: "device",
+			ID:   d.ID,
+			Name: d.Hostname,
+			Fields: map[string]string{
+				"asset_tag": d.AssetTag,
+				"health":    d.Health,
+				"phase":     d.Phase,
+				"product":   productMap[d.HardwareProduct].Name,
+				"room":      rack.Datacenter,
+				"rack":      rack.Name,
+				"rack_unit": unit,
+			},
+		})
+	}
+
+	for _, e := range entries {
+		m.Counts[e.Type]++
+	}
+	m.Built = time.Now().UTC()
+
+	return m, entries, nil
+}
@@ -0,0 +1,242 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package index
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func buildAndWrite(workspaceID uuid.UUID) {
+	m, entries, err := collect(workspaceID)
+	if err != nil {
+		util.Bail(err)
+	}
+
+	if err := writeIndex(indexPath, m, entries); err != nil {
+		util.Bail(err)
+	}
+
+	if util.JSON {
+		util.JSONOut(m)
+		return
+	}
+	fmt.Printf(
+		"Indexed %d devices, %d racks, and %d hardware products from workspace %s\n",
+		m.Counts["device"],
+		m.Counts["rack"],
+		m.Counts["product"],
+		m.WorkspaceName,
+	)
+}
+
+func build(cmd *cli.Cmd) {
+	workspaceOpt := cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+
+	cmd.Before = util.BuildAPIAndVerifyLogin
+
+	cmd.Action = func() {
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+		buildAndWrite(workspaceID)
+	}
+}
+
+func refresh(cmd *cli.Cmd) {
+	cmd.Before = util.BuildAPIAndVerifyLogin
+
+	cmd.Action = func() {
+		m, _, err := readIndex(indexPath, false)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		workspaceID, err := uuid.FromString(m.WorkspaceID)
+		if err != nil {
+			util.Bail(err)
+		}
+		buildAndWrite(workspaceID)
+	}
+}
+
+func status(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		m, _, err := readIndex(indexPath, false)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(m)
+			return
+		}
+
+		types := make([]string, 0, len(m.Counts))
+		for t := range m.Counts {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+
+		counts := make([]string, 0, len(types))
+		for _, t := range types {
+			counts = append(counts, fmt.Sprintf("%s: %d", t, m.Counts[t]))
+		}
+
+		fmt.Printf(`Built: %s (%s ago)
+API: %s
+Workspace: %s (%s)
+Entries: %s
+`,
+			util.TimeStr(m.Built),
+			time.Since(m.Built).Round(time.Second),
+			m.API,
+			m.WorkspaceName,
+			m.WorkspaceID,
+			strings.Join(counts, ", "),
+		)
+	}
+}
+
+func clearIndexCmd(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		if err := clearIndex(indexPath); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func find(cmd *cli.Cmd) {
+	var (
+		termArg      = cmd.StringArg("TERM", "", "What to search for. Matches serials, hostnames, asset tags, names, aliases, SKUs, and more")
+		offlineOpt   = cmd.BoolOpt("offline o", false, "Search the local index instead of the API")
+		typeOpt      = cmd.StringOpt("type t", "", "Only return one type of result: device, rack, or product")
+		limitOpt     = cmd.IntOpt("limit", 20, "Maximum number of results. 0 means no limit")
+		maxAgeOpt    = cmd.StringOpt("max-age", "24h", "With --offline, warn when the index is older than this")
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "Without --offline, the UUID or name of the workspace. Defaults to the workspace in the active profile")
+		pathOpt      = cmd.StringOpt("index-file", defaultIndexPath, "Path to the index database")
+	)
+
+	cmd.Spec = "[OPTIONS] TERM"
+
+	cmd.Before = func() {
+		indexPath = *pathOpt
+
+		// The whole point of --offline is that the API might not be there
+		if !*offlineOpt {
+			util.BuildAPIAndVerifyLogin()
+		}
+	}
+
+	cmd.Action = func() {
+		switch *typeOpt {
+		case "", "device", "rack", "product":
+		default:
+			util.Bail(fmt.Errorf("unknown type '%s'", *typeOpt))
+		}
+
+		if strings.TrimSpace(*termArg) == "" {
+			util.Bail(errors.New("please provide a search term"))
+		}
+
+		var entries []entry
+
+		if *offlineOpt {
+			maxAge, err := time.ParseDuration(*maxAgeOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+
+			var m meta
+			m, entries, err = readIndex(indexPath, true)
+			if err != nil {
+				util.Bail(err)
+			}
+
+			if age := time.Since(m.Built); age > maxAge {
+				fmt.Fprintf(
+					os.Stderr,
+					"Warning: the index is %s old. Run 'conch index refresh' to update it\n",
+					age.Round(time.Minute),
+				)
+			}
+		} else {
+			workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+
+			_, entries, err = collect(workspaceID)
+			if err != nil {
+				util.Bail(fmt.Errorf("%s. If the API is unreachable, try --offline", err))
+			}
+		}
+
+		matches := search(entries, *termArg, *typeOpt, *limitOpt)
+
+		if util.JSON {
+			util.JSONOut(matches)
+			return
+		}
+
+		if len(matches) == 0 {
+			fmt.Println("No matches")
+			return
+		}
+
+		table := util.GetMarkdownTable()
+		table.SetHeader([]string{"Type", "ID", "Name", "Matched", "Details"})
+		for _, m := range matches {
+			keys := make([]string, 0, len(m.Fields))
+			for k, v := range m.Fields {
+				if v != "" {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			details := make([]string, 0, len(keys))
+			for _, k := range keys {
+				details = append(details, k+"="+m.Fields[k])
+			}
+
+			table.Append([]string{
+				m.Type,
+				m.ID,
+				m.Name,
+				m.Matched,
+				strings.Join(details, " "),
+			})
+		}
+		table.Render()
+	}
+}
+
+func prefetch(cmd *cli.Cmd) {
+	cmd.LongDesc = `
+Lists the hardware product catalogue, the rack roles, and the workspaces in
+parallel and stores their names and IDs in the local name cache. Commands
+that accept names for those things then resolve them without asking the API,
+until the cache expires.`
+
+	cmd.Before = util.BuildAPIAndVerifyLogin
+
+	cmd.Action = func() {
+		if err := util.PrefetchIDs(); err != nil {
+			util.Bail(err)
+		}
+	}
+}
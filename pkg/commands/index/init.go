@@ -0,0 +1,79 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package index contains commands for keeping local, searchable snapshots of
+// the inventory
+package index
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// defaultIndexPath is ~/.conch-index.db, or under LOCALAPPDATA on Windows
+var defaultIndexPath = config.DataPath("index.db")
+
+var indexPath string
+
+// Init loads up the index, find, and prefetch commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"index",
+		"Commands for managing the local search index",
+		func(cmd *cli.Cmd) {
+			pathOpt := cmd.StringOpt("index-file", defaultIndexPath, "Path to the index database")
+			cmd.Before = func() {
+				indexPath = *pathOpt
+			}
+
+			util.Command(
+				cmd,
+				"build",
+				"Snapshot the devices and racks of a workspace, and all hardware products, into the local index",
+				build,
+			)
+
+			util.Command(
+				cmd,
+				"refresh",
+				"Rebuild the local index from the same workspace it was built from",
+				refresh,
+			)
+
+			util.Command(
+				cmd,
+				"status",
+				"Show when the local index was built and what it contains",
+				status,
+			)
+
+			util.Command(
+				cmd,
+				"clear",
+				"Remove the local index for this profile",
+				clearIndexCmd,
+			)
+		},
+	)
+
+	util.Command(
+		app.Cmd,
+		"find",
+		"Fuzzy search for devices, racks, and hardware products",
+		find,
+	)
+
+	util.Command(
+		app.Cmd,
+		"prefetch",
+		"Fetch hardware products, rack roles, and workspaces into the local name cache",
+		prefetch,
+	)
+}
@@ -0,0 +1,97 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package index
+
+import (
+	"sort"
+	"strings"
+)
+
+// match is an entry that matched a search, and how well
+type match struct {
+	entry
+	Score   int    `json:"score"`
+	Matched string `json:"matched"`
+}
+
+// score rates how well the term matches a single value. Exact matches beat
+// prefixes, which beat substrings, which beat the term's letters merely
+// appearing in order.
+func score(term string, value string) int {
+	if value == "" {
+		return 0
+	}
+	value = strings.ToLower(value)
+
+	switch {
+	case value == term:
+		return 100
+	case strings.HasPrefix(value, term):
+		return 60
+	case strings.Contains(value, term):
+		return 40
+	}
+
+	// Subsequence match, eg 'hlsnc' matches 'hallasan c'
+	runes := []rune(term)
+	i := 0
+	for _, r := range value {
+		if i < len(runes) && runes[i] == r {
+			i++
+		}
+	}
+	if i == len(runes) && len(runes) > 2 {
+		return 10
+	}
+	return 0
+}
+
+// search returns the entries that match the term, best first. An empty kind
+// matches every type of entry.
+func search(entries []entry, term string, kind string, limit int) []match {
+	term = strings.ToLower(strings.TrimSpace(term))
+	matches := make([]match, 0)
+
+	for _, e := range entries {
+		if kind != "" && e.Type != kind {
+			continue
+		}
+
+		best := match{entry: e}
+		consider := func(field string, value string) {
+			if s := score(term, value); s > best.Score {
+				best.Score = s
+				best.Matched = field
+			}
+		}
+
+		consider("id", e.ID)
+		consider("name", e.Name)
+		for k, v := range e.Fields {
+			consider(k, v)
+		}
+
+		if best.Score > 0 {
+			matches = append(matches, best)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].Type != matches[j].Type {
+			return matches[i].Type < matches[j].Type
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
@@ -0,0 +1,186 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/util"
+	homedir "github.com/mitchellh/go-homedir"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNoIndex is returned when the index has never been built for a profile
+var ErrNoIndex = errors.New("no index found. Run 'conch index build' first")
+
+var (
+	metaKey       = []byte("meta")
+	entriesBucket = []byte("entries")
+)
+
+// entry is a single searchable object
+type entry struct {
+	Type   string            `json:"type"`
+	ID     string            `json:"id"`
+	Name   string            `json:"name"`
+	Fields map[string]string `json:"fields"`
+}
+
+// meta describes when and from where the index was built
+type meta struct {
+	Built         time.Time      `json:"built"`
+	API           string         `json:"api"`
+	WorkspaceID   string         `json:"workspace_id"`
+	WorkspaceName string         `json:"workspace_name"`
+	Counts        map[string]int `json:"counts"`
+}
+
+// profileBucket keeps each profile's index separate, since profiles can point
+// at different API servers
+func profileBucket() []byte {
+	if util.ActiveProfile != nil {
+		return []byte("profile:" + util.ActiveProfile.Name)
+	}
+	return []byte("url:" + util.BaseURL)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func openStore(path string, readOnly bool) (*bolt.DB, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+	if !readOnly {
+		// On Windows the index lives in a directory of its own that may
+		// not exist yet
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	return bolt.Open(path, 0600, &bolt.Options{
+		Timeout:  2 * time.Second,
+		ReadOnly: readOnly,
+	})
+}
+
+// writeIndex replaces the profile's index with the given entries
+func writeIndex(path string, m meta, entries []entry) error {
+	db, err := openStore(path, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		name := profileBucket()
+		if tx.Bucket(name) != nil {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+
+		b, err := tx.CreateBucket(name)
+		if err != nil {
+			return err
+		}
+
+		j, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(metaKey, j); err != nil {
+			return err
+		}
+
+		eb, err := b.CreateBucket(entriesBucket)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			j, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if err := eb.Put([]byte(e.Type+":"+e.ID), j); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readIndex loads the profile's index. If entries is false, only the metadata
+// is read.
+func readIndex(path string, withEntries bool) (meta, []entry, error) {
+	var m meta
+	entries := make([]entry, 0)
+
+	expanded, err := homedir.Expand(path)
+	if err != nil {
+		return m, entries, err
+	}
+	if !fileExists(expanded) {
+		return m, entries, ErrNoIndex
+	}
+
+	db, err := openStore(path, true)
+	if err != nil {
+		return m, entries, err
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(profileBucket())
+		if b == nil {
+			return ErrNoIndex
+		}
+
+		if err := json.Unmarshal(b.Get(metaKey), &m); err != nil {
+			return err
+		}
+
+		if !withEntries {
+			return nil
+		}
+
+		return b.Bucket(entriesBucket).ForEach(func(k, v []byte) error {
+			var e entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			entries = append(entries, e)
+			return nil
+		})
+	})
+
+	return m, entries, err
+}
+
+// clearIndex removes the profile's index
+func clearIndex(path string) error {
+	db, err := openStore(path, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(profileBucket()) == nil {
+			return ErrNoIndex
+		}
+		return tx.DeleteBucket(profileBucket())
+	})
+}
@@ -0,0 +1,29 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package onboard contains the command that takes a device from unpacked to
+// racked, configured and validated in one go
+package onboard
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the onboard command
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"onboard",
+		"Register a device, assign it to a rack unit, apply its settings template and validate it, undoing it all if a step fails",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+			onboard(cmd)
+		},
+	)
+}
@@ -0,0 +1,398 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package onboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// onboardStep is one API change made while onboarding a device, along with
+// the change that takes it back
+type onboardStep struct {
+	Description string `json:"description"`
+	Done        bool   `json:"done"`
+	RolledBack  bool   `json:"rolled_back,omitempty"`
+	run         func() error
+	undo        func() error
+}
+
+// validationSummary is how the device did against its validation plan
+type validationSummary struct {
+	PlanID  uuid.UUID      `json:"validation_plan_id,omitempty"`
+	Source  string         `json:"source"`
+	Ran     bool           `json:"ran"`
+	Note    string         `json:"note,omitempty"`
+	Results map[string]int `json:"results,omitempty"`
+}
+
+// onboarding is what 'onboard' prints with --json
+type onboarding struct {
+	Device     string             `json:"device"`
+	Registered bool               `json:"registered"`
+	Rack       uuid.UUID          `json:"rack_id"`
+	RackName   string             `json:"rack_name"`
+	RU         int                `json:"rack_unit_start"`
+	Product    uuid.UUID          `json:"hardware_product"`
+	AssetTag   string             `json:"asset_tag,omitempty"`
+	DryRun     bool               `json:"dry_run"`
+	Steps      []*onboardStep     `json:"steps"`
+	Validation *validationSummary `json:"validation,omitempty"`
+}
+
+// rollback takes back the steps that were done, newest first, and lists on
+// STDERR what could not be taken back
+func rollback(steps []*onboardStep) {
+	failed := make([]string, 0)
+	for i := len(steps) - 1; i >= 0; i-- {
+		s := steps[i]
+		if !s.Done || s.undo == nil {
+			continue
+		}
+		if err := s.undo(); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", s.Description, err))
+			continue
+		}
+		s.RolledBack = true
+	}
+
+	if util.JSON {
+		return
+	}
+	if len(failed) == 0 {
+		fmt.Fprintln(os.Stderr, "Every step already taken was rolled back")
+		return
+	}
+	fmt.Fprintln(os.Stderr, "These steps could not be rolled back, and need to be undone by hand:")
+	for _, f := range failed {
+		fmt.Fprintf(os.Stderr, "  - %s\n", f)
+	}
+}
+
+// validate runs the device's validation plan against its latest report. A
+// device that has never reported gets validated when it first does.
+func validate(serial string) (*validationSummary, error) {
+	a, err := util.API.GetDeviceValidationPlan(serial)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &validationSummary{PlanID: a.PlanID, Source: a.Source}
+	if uuid.Equal(a.PlanID, uuid.UUID{}) {
+		v.Note = "no validation plan is assigned to the device or its hardware product"
+		return v, nil
+	}
+
+	d, err := util.API.GetDevice(serial)
+	if err != nil {
+		return nil, err
+	}
+	if d.LatestReport == nil {
+		v.Note = "the device has not reported yet, and will be validated when it does"
+		return v, nil
+	}
+
+	report, err := json.Marshal(d.LatestReport)
+	if err != nil {
+		return nil, err
+	}
+	results, err := util.API.RunDeviceValidationPlan(serial, a.PlanID, string(report))
+	if err != nil {
+		return nil, err
+	}
+
+	v.Ran = true
+	v.Results = make(map[string]int)
+	for _, r := range results {
+		v.Results[r.Status]++
+	}
+	return v, nil
+}
+
+func onboard(app *cli.Cmd) {
+	var (
+		serialArg   = app.StringArg("SERIAL", "", "The serial of the device. It doesn't need to have reported in yet")
+		rackOpt     = app.StringOpt("rack r", "", "The UUID or name of the rack the device goes in")
+		ruOpt       = app.IntOpt("ru", 0, "The rack unit the device starts at")
+		productOpt  = app.StringOpt("product p", "", "The UUID, name, or SKU of the device's hardware product, which must be what the rack layout expects at that rack unit")
+		assetTagOpt = app.StringOpt("asset-tag a", "", "The asset tag of the device")
+		dryRunOpt   = app.BoolOpt("dry-run", false, "Show what would be done without changing anything")
+	)
+
+	app.Spec = "SERIAL --rack --ru --product [OPTIONS]"
+
+	app.LongDesc = `
+Does what otherwise takes six commands, for a device fresh out of the box:
+
+    conch onboard SERIAL --rack RACK --ru 12 --product PRODUCT --asset-tag TAG
+
+Everything is checked before anything changes: that the rack's layout has a
+slot for the product at that rack unit, that nothing else is in it, and that
+the device isn't racked somewhere else. Then the device is assigned to the rack
+unit with its asset tag, which registers it if Conch has never heard of it,
+its hardware product's settings template is applied, and its validation plan
+is run against its latest report.
+
+If any step fails, the steps already taken are rolled back, newest first. The
+one thing that can't be rolled back is the registration itself: a device the
+assignment created stays in Conch, unassigned. Failing validations don't roll
+anything back; they are reported, like 'conch device SERIAL validations' would.`
+
+	app.Action = func() {
+		serial := strings.TrimSpace(*serialArg)
+		if serial == "" {
+			util.Bail(errors.New("a device serial is needed"))
+		}
+		if *ruOpt < 1 {
+			util.Bail(errors.New("--ru must be a rack unit, starting at 1"))
+		}
+
+		rackID, err := util.MagicRackID(*rackOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+		rack, err := util.API.GetRack(rackID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		productID, err := util.MagicProductID(*productOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+		product, err := util.API.GetHardwareProduct(productID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		ru := *ruOpt
+
+		layout, err := util.API.GetRackLayout(rack)
+		if err != nil {
+			util.Bail(err)
+		}
+		var slot *conch.RackLayoutSlot
+		for i, s := range layout {
+			if s.RUStart == ru {
+				slot = &layout[i]
+				break
+			}
+		}
+		if slot == nil {
+			util.Bail(fmt.Errorf("the layout of rack %s has no slot starting at RU %d", rack.Name, ru))
+		}
+		if !uuid.Equal(slot.ProductID, product.ID) {
+			expected := slot.ProductID.String()
+			if h, err := util.API.GetHardwareProduct(slot.ProductID); err == nil {
+				expected = h.Name
+			}
+			util.Bail(fmt.Errorf(
+				"rack %s expects %s at RU %d, not %s",
+				rack.Name,
+				expected,
+				ru,
+				product.Name,
+			))
+		}
+
+		assignments, err := util.API.GetRackAssignments(rackID)
+		if err != nil {
+			util.Bail(err)
+		}
+		for _, a := range assignments {
+			if a.RackUnitStart == ru && a.DeviceID != "" && a.DeviceID != serial {
+				util.Bail(fmt.Errorf("RU %d of rack %s is taken by device %s", ru, rack.Name, a.DeviceID))
+			}
+		}
+
+		registered := false
+		placed := false
+		oldAssetTag := ""
+		current := make(map[string]string)
+
+		d, err := util.API.GetDevice(serial)
+		switch {
+		case err == conch.ErrDataNotFound:
+			registered = true
+		case err != nil:
+			util.Bail(err)
+		default:
+			oldAssetTag = d.AssetTag
+			if !uuid.Equal(d.HardwareProduct, uuid.UUID{}) && !uuid.Equal(d.HardwareProduct, product.ID) {
+				util.Bail(fmt.Errorf("device %s has reported itself as a different hardware product than %s", serial, product.Name))
+			}
+
+			loc, err := util.API.GetDeviceLocation(serial)
+			if err != nil && err != conch.ErrDataNotFound {
+				util.Bail(err)
+			}
+			if !uuid.Equal(loc.Rack.ID, uuid.UUID{}) {
+				if !uuid.Equal(loc.Rack.ID, rackID) || loc.RackUnitStart != ru {
+					util.Bail(fmt.Errorf(
+						"device %s is already in rack %s, RU %d. Use 'conch device %s replace' or remove it from there first",
+						serial,
+						loc.Rack.Name,
+						loc.RackUnitStart,
+						serial,
+					))
+				}
+				placed = true
+			}
+
+			current, err = util.API.GetDeviceSettings(serial)
+			if err != nil {
+				util.Bail(err)
+			}
+		}
+
+		tmpl, err := util.API.GetSettingsTemplate(product.ID)
+		if err != nil {
+			util.Bail(err)
+		}
+		changes := tmpl.Diff(current)
+
+		assetTag := *assetTagOpt
+		if assetTag == "" {
+			assetTag = oldAssetTag
+		}
+
+		steps := make([]*onboardStep, 0)
+
+		if !placed {
+			verb := "Assign"
+			if registered {
+				verb = "Register and assign"
+			}
+			desc := fmt.Sprintf("%s %s to rack %s, RU %d", verb, serial, rack.Name, ru)
+			if assetTag != "" {
+				desc += fmt.Sprintf(" with asset tag '%s'", assetTag)
+			}
+			steps = append(steps, &onboardStep{
+				Description: desc,
+				run: func() error {
+					return util.API.AssignDevicesToRackSlots(
+						rackID,
+						conch.RequestRackAssignmentUpdates{{
+							DeviceID:       serial,
+							RackUnitStart:  ru,
+							DeviceAssetTag: assetTag,
+						}},
+					)
+				},
+				undo: func() error {
+					return util.API.DeleteDevicesFromRackSlots(
+						rackID,
+						conch.RequestRackAssignmentDeletes{{DeviceID: serial, RackUnitStart: ru}},
+					)
+				},
+			})
+		} else if assetTag != oldAssetTag {
+			steps = append(steps, &onboardStep{
+				Description: fmt.Sprintf("Set the asset tag of %s to '%s'", serial, assetTag),
+				run: func() error {
+					return util.API.SetDeviceAssetTag(serial, assetTag)
+				},
+				undo: func() error {
+					return util.API.SetDeviceAssetTag(serial, oldAssetTag)
+				},
+			})
+		}
+
+		for _, c := range changes {
+			c := c
+			s := &onboardStep{
+				Description: fmt.Sprintf("Set setting %s to '%s' on %s", c.Name, c.Template, serial),
+				run: func() error {
+					return util.API.SetDeviceSetting(serial, c.Name, c.Template)
+				},
+				undo: func() error {
+					return util.API.SetDeviceSetting(serial, c.Name, c.Current)
+				},
+			}
+			if c.Action == "add" {
+				s.undo = func() error {
+					return util.API.DeleteDeviceSetting(serial, c.Name)
+				}
+			}
+			steps = append(steps, s)
+		}
+
+		result := onboarding{
+			Device:     serial,
+			Registered: registered,
+			Rack:       rackID,
+			RackName:   rack.Name,
+			RU:         ru,
+			Product:    product.ID,
+			AssetTag:   assetTag,
+			DryRun:     *dryRunOpt,
+			Steps:      steps,
+		}
+
+		if !*dryRunOpt {
+			util.CheckRequestBudget(len(steps)+1, fmt.Sprintf("Onboarding %s in %d steps", serial, len(steps)+1))
+
+			for _, s := range steps {
+				if err := s.run(); err != nil {
+					rollback(steps)
+					util.Bail(fmt.Errorf("%s: %s", s.Description, err))
+				}
+				s.Done = true
+			}
+
+			v, err := validate(serial)
+			if err != nil {
+				rollback(steps)
+				util.Bail(fmt.Errorf("validating %s: %s", serial, err))
+			}
+			result.Validation = v
+		}
+
+		if util.JSON {
+			util.JSONOut(result)
+			return
+		}
+
+		if *dryRunOpt {
+			fmt.Println("Dry run. These steps would be taken:")
+		}
+		for _, s := range steps {
+			fmt.Printf("  - %s\n", s.Description)
+		}
+		if *dryRunOpt {
+			fmt.Printf("  - Run the validation plan of %s against its latest report\n", serial)
+			return
+		}
+
+		v := result.Validation
+		switch {
+		case !v.Ran:
+			fmt.Printf("  - Not validated: %s\n", v.Note)
+		case v.Results["fail"]+v.Results["error"] > 0:
+			fmt.Printf(
+				"  - Validated with the %s plan: %d passed, %d failed, %d errors. See 'conch device %s validations'\n",
+				v.Source,
+				v.Results["pass"],
+				v.Results["fail"],
+				v.Results["error"],
+				serial,
+			)
+		default:
+			fmt.Printf("  - Validated with the %s plan: all %d passed\n", v.Source, v.Results["pass"])
+		}
+
+		fmt.Printf("\n%s is onboarded in rack %s, RU %d\n", serial, rack.Name, ru)
+	}
+}
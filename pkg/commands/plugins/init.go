@@ -0,0 +1,47 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package plugins contains commands for managing the external programs the
+// shell can hand its output to
+package plugins
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"output-plugins",
+		"Manage the external programs that can format the shell's output, via --output-plugin",
+		func(cmd *cli.Cmd) {
+			util.Command(
+				cmd,
+				"add",
+				"Register a program as an output plugin",
+				add,
+			)
+
+			util.Command(
+				cmd,
+				"list ls",
+				"List the output plugins",
+				list,
+			)
+
+			util.Command(
+				cmd,
+				"remove rm",
+				"Remove an output plugin",
+				remove,
+			)
+		},
+	)
+}
@@ -0,0 +1,117 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func add(cmd *cli.Cmd) {
+	var (
+		nameArg    = cmd.StringArg("NAME", "", "The name to use with --output-plugin")
+		commandArg = cmd.StringsArg("COMMAND", nil, "The program to run, and its arguments")
+		descOpt    = cmd.StringOpt("description d", "", "What the plugin produces")
+		forceOpt   = cmd.BoolOpt("force", false, "Replace an existing plugin with the same name")
+	)
+	cmd.Spec = "[OPTIONS] NAME [OPTIONS] -- COMMAND..."
+
+	cmd.LongDesc = `
+Registers a program as an output plugin, in the config file. Running any
+command with --output-plugin NAME, or with CONCH_OUTPUT_PLUGIN=NAME in the
+environment, switches the command to JSON output and pipes that output to the
+program instead of printing it. For instance:
+
+    conch output-plugins add wiki -d "Confluence tables" -- ~/bin/conch2wiki --compact
+    conch --output-plugin wiki workspace devices
+
+The program reads a single JSON object on STDIN:
+
+    {
+      "plugin":    "wiki",
+      "command":   ["conch", "--output-plugin", "wiki", "workspace", "devices"],
+      "profile":   "production",
+      "api":       "https://conch.joyent.us",
+      "workspace": "GLOBAL",
+      "version":   "1.0.0",
+      "generated": "2019-06-01T12:00:00Z",
+      "result":    <what --json would have printed>
+    }
+
+Credentials in the command line are redacted. Whatever the program writes to
+STDOUT and STDERR is passed through, and if it exits non-zero, so does conch.`
+
+	cmd.Action = func() {
+		name := *nameArg
+		if strings.ContainsAny(name, " \t/") {
+			util.Bail(errors.New("plugin names may not contain whitespace or slashes"))
+		}
+
+		if util.Config.OutputPlugins == nil {
+			util.Config.OutputPlugins = make(map[string]*config.OutputPlugin)
+		}
+		if _, ok := util.Config.OutputPlugins[name]; ok && !*forceOpt {
+			util.Bail(fmt.Errorf("an output plugin named '%s' already exists. Use --force to replace it", name))
+		}
+
+		util.Config.OutputPlugins[name] = &config.OutputPlugin{
+			Command:     *commandArg,
+			Description: *descOpt,
+		}
+		util.WriteConfigForce()
+
+		if !util.JSON {
+			fmt.Printf("Saved output plugin '%s'. Use it with 'conch --output-plugin %s ...'\n", name, name)
+		}
+	}
+}
+
+func list(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		plugins := util.Config.OutputPlugins
+		if plugins == nil {
+			plugins = make(map[string]*config.OutputPlugin)
+		}
+
+		if util.JSON {
+			util.JSONOut(plugins)
+			return
+		}
+
+		names := util.OutputPluginNames()
+		header := []string{"Name", "Description", "Command"}
+		row := func(i int) []string {
+			p := plugins[names[i]]
+			return []string{names[i], p.Description, strings.Join(p.Command, " ")}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(names), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func remove(cmd *cli.Cmd) {
+	var nameArg = cmd.StringArg("NAME", "", "The name of the plugin")
+	cmd.Spec = "NAME"
+
+	cmd.Action = func() {
+		if _, ok := util.Config.OutputPlugins[*nameArg]; !ok {
+			util.Bail(fmt.Errorf("no output plugin named '%s'", *nameArg))
+		}
+		delete(util.Config.OutputPlugins, *nameArg)
+		util.WriteConfigForce()
+
+		if !util.JSON {
+			fmt.Printf("Removed output plugin '%s'\n", *nameArg)
+		}
+	}
+}
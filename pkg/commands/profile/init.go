@@ -14,74 +14,128 @@ import (
 
 // Init loads up the profile commands
 func Init(app *cli.Cli) {
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"profile prof",
 		"Commands for creating and adjusting login profiles",
 		func(cmd *cli.Cmd) {
 			// Because login happens in here, we can't VerifyLogin blindly.
 			// Everyone has to do that on their own if they want.
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"new create add",
 				"Create a new login profile",
 				newProfile,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"delete del rm",
 				"Delete a profile",
 				deleteProfile,
 			)
-			cmd.Command(
+			util.Command(
+				cmd,
 				"list ls",
 				"List all known profiles",
 				listProfiles,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"change-password",
 				"Change the password associated with this profile",
 				changePassword,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"set",
 				"Change profile settings",
 				func(cmd *cli.Cmd) {
-					cmd.Command(
+					util.Command(
+						cmd,
 						"workspace ws",
 						"Set the workspace (by name or ID) for the active profile",
 						setWorkspace,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"active",
 						"Change which profile is active",
 						setActive,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"token",
 						"Change the API token for the active profile. This will convert the profile to token auth if it was previously using login auth",
 						setToken,
 					)
+
+					util.Command(
+						cmd,
+						"notify",
+						"Set the webhook that commands run with --notify will post to when they finish",
+						setNotify,
+					)
+
+					util.Command(
+						cmd,
+						"journal",
+						"Set the directory where a local journal of changes made with this profile is kept",
+						setJournal,
+					)
+
+					util.Command(
+						cmd,
+						"api-version",
+						"Set the API server versions this profile will work with, in place of the ones the shell was built for",
+						setAPIVersion,
+					)
+
+					util.Command(
+						cmd,
+						"max-requests",
+						"Set how many API requests a single command may make before it is stopped",
+						setMaxRequests,
+					)
+
+					util.Command(
+						cmd,
+						"cache-ttl",
+						"Set how long the JSON results of commands are reused for when the same command is run again",
+						setCacheTTL,
+					)
+
+					util.Command(
+						cmd,
+						"runbook",
+						"Set the runbook URL shown next to failures of a validation",
+						setRunbook,
+					)
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"upgrade",
 				"Upgrade this profile to use API tokens. This will generate a specific API token for this instance which will *not* be displayed or otherwise accessible",
 				upgradeToToken,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"relogin",
 				"Log in again, preserving all other profile data",
 				relogin,
 			)
 
 			if !util.DisableApiTokenCRUD() {
-				cmd.Command(
+				util.Command(
+					cmd,
 					"revoke-tokens",
 					"Revoke all auth tokens. User must log in again after this.",
 					revokeJWT,
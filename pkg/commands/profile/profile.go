@@ -9,7 +9,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/Bowery/prompt"
@@ -20,37 +23,86 @@ import (
 	"github.com/joyent/conch-shell/pkg/util"
 )
 
+// readBootstrapToken finds the token for 'profile create --from-token'. It
+// comes from --token, then the CONCH_TOKEN environment variable, then STDIN.
+func readBootstrapToken(flag string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+
+	if t := strings.TrimSpace(os.Getenv("CONCH_TOKEN")); t != "" {
+		return t, nil
+	}
+
+	if util.IsTerminal(os.Stdin) {
+		return prompt.Password("Token:")
+	}
+
+	b, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	t := strings.TrimSpace(string(b))
+	if t == "" {
+		return "", errors.New("no token was found on STDIN or in CONCH_TOKEN")
+	}
+	return t, nil
+}
+
 func newProfile(app *cli.Cmd) {
 	var (
-		nameOpt      = app.StringOpt("name", "", "Profile name. Must be unique")
+		nameOpt      = app.StringOpt("name", "", "Profile name. Must be unique. With --from-token, defaults to the user's email address")
 		overwriteOpt = app.BoolOpt("overwrite force", false, "Overwrite any profile with a matching name")
-		workspaceOpt = app.StringOpt("workspace ws", "", "Default workspace")
+		workspaceOpt = app.StringOpt("workspace ws", "", "Default workspace. With --from-token, defaults to the user's workspace if they only have one")
+		activeOpt    = app.BoolOpt("active", false, "Make this the active profile")
 
-		tokenOpt    = app.StringOpt("token", "", "Use an API token instead of a password")
-		userOpt     = app.StringOpt("user", "", "API User name")
-		passwordOpt = app.StringOpt("password pass", "", "API Password")
+		tokenOpt     = app.StringOpt("token", "", "Use an API token instead of a password")
+		fromTokenOpt = app.BoolOpt("from-token", false, "Set up the whole profile from an API token, without prompting. The token is read from --token, CONCH_TOKEN, or STDIN")
+		userOpt      = app.StringOpt("user", "", "API User name")
+		passwordOpt  = app.StringOpt("password pass", "", "API Password")
 
 		envOpt = app.StringOpt("environment env", "production", "Specify the environment: production, staging, development (provide URL in the --url parameter)")
 		urlOpt = app.StringOpt("url", "", "If the environment is 'development', this defines the API URL. Ignored otherwise")
 	)
 
-	app.Spec = "--name [OPTIONS]"
+	app.Spec = "[OPTIONS]"
+
+	app.LongDesc = `
+Creates a login profile, using either a user name and password or an API
+token.
+
+With --from-token, the profile is built from nothing but a token, which suits
+configuration management tools. The token is checked against the API, the
+user's email address is looked up and recorded, and the profile is written
+in one go. For instance:
+
+    echo "$TOKEN" | conch profile create --from-token --active`
 
 	app.Action = func() {
 		var err error
-		var p *config.ConchProfile
-		if _, ok := util.Config.Profiles[*nameOpt]; ok {
-			if !*overwriteOpt {
+
+		if *nameOpt == "" && !*fromTokenOpt {
+			util.Bail(errors.New("please provide --name"))
+		}
+
+		existing := func(name string) *config.ConchProfile {
+			prof, ok := util.Config.Profiles[name]
+			if ok && !*overwriteOpt {
 				util.Bail(
 					fmt.Errorf(
 						"a profile already exists with name '%s'",
-						*nameOpt,
+						name,
 					),
 				)
 			}
+			return prof
+		}
 
-			p = util.Config.Profiles[*nameOpt]
-		} else {
+		var p *config.ConchProfile
+		if *nameOpt != "" {
+			p = existing(*nameOpt)
+		}
+		if p == nil {
 			p = &config.ConchProfile{}
 			p.Name = *nameOpt
 		}
@@ -72,7 +124,8 @@ func newProfile(app *cli.Cmd) {
 		/***/
 
 		util.API = &conch.Conch{
-			BaseURL: p.BaseURL,
+			BaseURL:       p.BaseURL,
+			NoCompression: util.NoCompression,
 		}
 
 		if util.UserAgent != "" {
@@ -81,14 +134,41 @@ func newProfile(app *cli.Cmd) {
 
 		/***/
 
-		if *tokenOpt != "" {
-			p.Token = config.Token(*tokenOpt)
-			util.API.Token = *tokenOpt
+		token := *tokenOpt
+		if *fromTokenOpt {
+			token, err = readBootstrapToken(token)
+			if err != nil {
+				util.Bail(err)
+			}
+		}
+
+		var me conch.UserProfile
+		if token != "" {
+			p.Token = config.Token(token)
+			util.API.Token = token
 
 			if ok, err := util.API.VerifyToken(); !ok {
 				util.Bail(err)
 			}
 
+			if *fromTokenOpt {
+				me, err = util.API.GetUserProfile()
+				if err != nil {
+					util.Bail(err)
+				}
+				p.User = me.Email
+
+				if p.Name == "" {
+					if prof := existing(me.Email); prof != nil {
+						prof.Token = p.Token
+						prof.BaseURL = p.BaseURL
+						prof.User = p.User
+						p = prof
+					}
+					p.Name = me.Email
+				}
+			}
+
 		} else {
 			if *userOpt == "" {
 				util.Bail(errors.New("please provide a user name"))
@@ -119,6 +199,11 @@ func newProfile(app *cli.Cmd) {
 
 		if *workspaceOpt == "" {
 			p.WorkspaceUUID = uuid.UUID{}
+			p.WorkspaceName = ""
+			if *fromTokenOpt && len(me.Workspaces) == 1 {
+				p.WorkspaceUUID = me.Workspaces[0].ID
+				p.WorkspaceName = me.Workspaces[0].Name
+			}
 		} else {
 			p.WorkspaceUUID, err = util.MagicWorkspaceID(*workspaceOpt)
 			if err != nil {
@@ -133,17 +218,35 @@ func newProfile(app *cli.Cmd) {
 			p.WorkspaceName = ws.Name
 		}
 
-		if len(util.Config.Profiles) == 0 {
+		if len(util.Config.Profiles) == 0 || *activeOpt {
+			for _, prof := range util.Config.Profiles {
+				prof.Active = false
+			}
 			p.Active = true
 		}
 
 		util.Config.Profiles[p.Name] = p
 		util.WriteConfigForce()
 
-		if !util.JSON {
-			fmt.Printf("Done. Config written to %s\n", util.Config.Path)
+		if util.JSON {
+			if *fromTokenOpt {
+				util.JSONOut(map[string]interface{}{
+					"name":           p.Name,
+					"user":           p.User,
+					"url":            p.BaseURL,
+					"workspace_id":   p.WorkspaceUUID,
+					"workspace_name": p.WorkspaceName,
+					"active":         p.Active,
+					"config":         util.Config.Path,
+				})
+			}
+			return
 		}
 
+		if *fromTokenOpt {
+			fmt.Printf("Profile '%s' for %s\n", p.Name, p.User)
+		}
+		fmt.Printf("Done. Config written to %s\n", util.Config.Path)
 	}
 }
 
@@ -442,13 +545,228 @@ func setToken(cmd *cli.Cmd) {
 		util.ActiveProfile.Token = config.Token(*tokenArg)
 		util.Token = *tokenArg
 
+		// The token may well belong to someone else
 		util.ActiveProfile.JWT = conch.ConchJWT{}
+		util.ActiveProfile.Roles = nil
 
 		util.WriteConfigForce()
 	}
 
 }
 
+func setNotify(cmd *cli.Cmd) {
+	var (
+		webhookArg = cmd.StringArg("URL", "", "A Mattermost or Slack incoming webhook URL")
+		clearOpt   = cmd.BoolOpt("clear", false, "Remove the webhook from the profile")
+	)
+	cmd.Spec = "URL | --clear"
+
+	cmd.Action = func() {
+		if util.ActiveProfile == nil {
+			util.Bail(errors.New("there is no active profile. Please use 'profile set active' to mark a profile as active"))
+		}
+
+		if *clearOpt {
+			util.ActiveProfile.NotifyWebhook = ""
+		} else {
+			util.ActiveProfile.NotifyWebhook = *webhookArg
+		}
+
+		util.WriteConfigForce()
+		if !util.JSON {
+			fmt.Printf("Done. Config written to %s\n", util.Config.Path)
+		}
+	}
+}
+
+func setJournal(cmd *cli.Cmd) {
+	var (
+		dirArg   = cmd.StringArg("DIR", "", "Directory to write the journal into")
+		gitOpt   = cmd.BoolOpt("git", false, "Commit each journal entry to a git repository in DIR, creating it if needed")
+		clearOpt = cmd.BoolOpt("clear", false, "Stop journaling for this profile")
+	)
+	cmd.Spec = "DIR [--git] | --clear"
+
+	cmd.LongDesc = `
+When a profile has a journal directory, every command that changes data on the
+server appends a JSON line to DIR/YYYY-MM.jsonl, recording the command line and
+each request and response. Passwords and tokens are redacted.`
+
+	cmd.Action = func() {
+		if util.ActiveProfile == nil {
+			util.Bail(errors.New("there is no active profile. Please use 'profile set active' to mark a profile as active"))
+		}
+
+		if *clearOpt {
+			util.ActiveProfile.JournalDir = ""
+			util.ActiveProfile.JournalGit = false
+		} else {
+			util.ActiveProfile.JournalDir = *dirArg
+			util.ActiveProfile.JournalGit = *gitOpt
+		}
+
+		util.WriteConfigForce()
+		if !util.JSON {
+			fmt.Printf("Done. Config written to %s\n", util.Config.Path)
+		}
+	}
+}
+
+func setAPIVersion(cmd *cli.Cmd) {
+	var (
+		constraintArg = cmd.StringArg("CONSTRAINT", "", "The API versions to allow, eg '>=2.20.0 <3.0.0'")
+		clearOpt      = cmd.BoolOpt("clear", false, "Go back to the API versions the shell was built for")
+	)
+	cmd.Spec = "CONSTRAINT | --clear"
+
+	cmd.LongDesc = `
+Every command checks that the API server's version is one the shell was built
+to work with, as shown by 'conch version'. For a server that is older or newer
+than that, a profile can name the versions it will accept instead.
+
+Constraints are made of comparisons like '>=2.20.0' or '<3.0.0'. Comparisons
+separated by spaces must all be true, and groups of them can be joined with
+'||'. For example: '>=2.18.0 <2.20.0 || >=2.22.0 <3.0.0'
+
+The shell may not understand every server it is allowed to talk to this way.
+To skip the check entirely for a single command, use --skip-version-check.`
+
+	cmd.Action = func() {
+		if util.ActiveProfile == nil {
+			util.Bail(errors.New("there is no active profile. Please use 'profile set active' to mark a profile as active"))
+		}
+
+		if *clearOpt {
+			util.ActiveProfile.APIVersion = ""
+		} else {
+			constraint := strings.TrimSpace(*constraintArg)
+			if _, err := util.ParseAPIVersionRange(constraint); err != nil {
+				util.Bail(err)
+			}
+			util.ActiveProfile.APIVersion = constraint
+		}
+
+		util.WriteConfigForce()
+		if !util.JSON {
+			fmt.Printf("Done. Config written to %s\n", util.Config.Path)
+		}
+	}
+}
+
+func setMaxRequests(cmd *cli.Cmd) {
+	var (
+		countArg = cmd.IntArg("COUNT", 0, "The most API requests a single command may make")
+		clearOpt = cmd.BoolOpt("clear", false, "Let commands make as many requests as they like")
+	)
+	cmd.Spec = "COUNT | --clear"
+
+	cmd.LongDesc = `
+Sets the default for --max-requests for this profile. A command that can tell
+up front that it will go over, like listing thousands of devices with --full,
+asks before it starts, or stops if there is nobody to ask. Any other command
+stops when it reaches the limit.`
+
+	cmd.Action = func() {
+		if util.ActiveProfile == nil {
+			util.Bail(errors.New("there is no active profile. Please use 'profile set active' to mark a profile as active"))
+		}
+
+		if *clearOpt {
+			util.ActiveProfile.MaxRequests = 0
+		} else {
+			if *countArg < 1 {
+				util.Bail(errors.New("COUNT must be at least 1. Use --clear to remove the limit"))
+			}
+			util.ActiveProfile.MaxRequests = *countArg
+		}
+
+		util.WriteConfigForce()
+		if !util.JSON {
+			fmt.Printf("Done. Config written to %s\n", util.Config.Path)
+		}
+	}
+}
+
+func setCacheTTL(cmd *cli.Cmd) {
+	var (
+		ttlArg   = cmd.StringArg("TTL", "", "How long a result is reused for, eg '25s'")
+		clearOpt = cmd.BoolOpt("clear", false, "Stop caching results")
+	)
+	cmd.Spec = "TTL | --clear"
+
+	cmd.LongDesc = `
+Sets the default for --cache-ttl for this profile. A command run with --json
+within the TTL of the same command, with the same arguments, prints the result
+of the earlier run instead of asking the API again. This is meant for
+dashboards that run the same commands every few seconds. Only commands that
+just read from the API are cached, and never when they fail.`
+
+	cmd.Action = func() {
+		if util.ActiveProfile == nil {
+			util.Bail(errors.New("there is no active profile. Please use 'profile set active' to mark a profile as active"))
+		}
+
+		if *clearOpt {
+			util.ActiveProfile.CacheTTL = ""
+		} else {
+			ttl, err := time.ParseDuration(*ttlArg)
+			if err != nil {
+				util.Bail(err)
+			}
+			if ttl <= 0 {
+				util.Bail(errors.New("TTL must be more than zero. Use --clear to stop caching"))
+			}
+			util.ActiveProfile.CacheTTL = ttl.String()
+		}
+
+		util.WriteConfigForce()
+		if !util.JSON {
+			fmt.Printf("Done. Config written to %s\n", util.Config.Path)
+		}
+	}
+}
+
+func setRunbook(cmd *cli.Cmd) {
+	var (
+		nameArg  = cmd.StringArg("NAME", "", "The name of the validation, or '*' for every validation without a runbook of its own")
+		urlArg   = cmd.StringArg("URL", "", "Where the steps for fixing the validation's failures are")
+		clearOpt = cmd.BoolOpt("clear", false, "Remove the runbook for the validation")
+	)
+	cmd.Spec = "NAME (URL | --clear)"
+
+	cmd.LongDesc = `
+Validation failures, and 'conch validation explain', link to the runbook for
+the validation, so that whoever is looking at a failure can go straight to the
+steps for fixing it. {name} and {version} in the URL are replaced with the
+validation's name and version, so a single default can cover every validation:
+
+    conch profile set runbook '*' 'https://wiki.example.com/runbooks/{name}'`
+
+	cmd.Action = func() {
+		if util.ActiveProfile == nil {
+			util.Bail(errors.New("there is no active profile. Please use 'profile set active' to mark a profile as active"))
+		}
+
+		if *clearOpt {
+			util.SetRunbook(*nameArg, "")
+		} else {
+			u, err := url.Parse(strings.NewReplacer("{name}", "x", "{version}", "1").Replace(*urlArg))
+			if err != nil {
+				util.Bail(err)
+			}
+			if !u.IsAbs() {
+				util.Bail(errors.New("the runbook URL must be absolute, eg https://wiki.example.com/runbooks/{name}"))
+			}
+			util.SetRunbook(*nameArg, *urlArg)
+		}
+
+		util.WriteConfigForce()
+		if !util.JSON {
+			fmt.Printf("Done. Config written to %s\n", util.Config.Path)
+		}
+	}
+}
+
 func upgradeToToken(cmd *cli.Cmd) {
 	var forceOpt = cmd.BoolOpt("force", false, "Generate a new token, even if the current profile already uses one")
 	cmd.Action = func() {
@@ -0,0 +1,121 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rack
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// assignmentRow is one slot of the rack worksheet
+type assignmentRow struct {
+	ru       int
+	size     int
+	product  string
+	deviceID string
+	assetTag string
+}
+
+// writeAssignmentsCSV writes a worksheet of the rack, one row per slot in
+// its layout. Product names come from the layout, and device health from
+// each assigned device.
+func writeAssignmentsCSV(out io.Writer, assignments conch.ResponseRackAssignments) error {
+	rack, err := util.API.GetRack(GRackUUID)
+	if err != nil {
+		return err
+	}
+
+	layout, err := util.API.GetRackLayout(rack)
+	if err != nil {
+		return err
+	}
+
+	products, err := util.API.GetHardwareProducts()
+	if err != nil {
+		return err
+	}
+	names := make(map[uuid.UUID]string)
+	for _, p := range products {
+		names[p.ID] = p.Name
+	}
+
+	rows := make(map[int]*assignmentRow)
+	for _, slot := range layout {
+		rows[slot.RUStart] = &assignmentRow{
+			ru:      slot.RUStart,
+			product: names[slot.ProductID],
+		}
+	}
+
+	devices := make([]conch.Device, 0)
+	for _, a := range assignments {
+		row, ok := rows[a.RackUnitStart]
+		if !ok {
+			// The layout is the source of truth, but a device in a slot
+			// the layout has lost still belongs on the worksheet
+			row = &assignmentRow{ru: a.RackUnitStart}
+			rows[a.RackUnitStart] = row
+		}
+		if row.product == "" {
+			row.product = a.HardwareProduct
+		}
+		row.size = a.RackUnitSize
+		row.deviceID = a.DeviceID
+		row.assetTag = a.DeviceAssetTag
+
+		if a.DeviceID != "" {
+			devices = append(devices, conch.Device{ID: a.DeviceID})
+		}
+	}
+
+	devices, err = util.FillDeviceDetails(devices)
+	if err != nil {
+		return err
+	}
+	health := make(map[string]string)
+	for _, d := range devices {
+		health[d.ID] = d.Health
+	}
+
+	units := make([]int, 0, len(rows))
+	for ru := range rows {
+		units = append(units, ru)
+	}
+	sort.Ints(units)
+
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"Rack", "RU", "Size", "Product", "Serial", "Asset Tag", "Health"}); err != nil {
+		return err
+	}
+	for _, ru := range units {
+		row := rows[ru]
+		size := ""
+		if row.size > 0 {
+			size = strconv.Itoa(row.size)
+		}
+		err := w.Write([]string{
+			rack.Name,
+			strconv.Itoa(row.ru),
+			size,
+			row.product,
+			row.deviceID,
+			row.assetTag,
+			health[row.deviceID],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,134 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// rackBudget is what 'rack budget' prints with --json
+type rackBudget struct {
+	RackID   uuid.UUID `json:"rack_id"`
+	RackName string    `json:"rack_name"`
+	RoomID   uuid.UUID `json:"room_id"`
+	conch.RackBudget
+	Limits config.RoomLimits `json:"limits"`
+	Over   []string          `json:"over"`
+}
+
+func formatAmount(f *float64) string {
+	if f == nil {
+		return "?"
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// budgetLine is a total along with its limit, if the room has one
+func budgetLine(total float64, limit float64, unit string) string {
+	s := formatAmount(&total) + " " + unit
+	if limit == 0 {
+		return s + " (no limit set for the room)"
+	}
+	return fmt.Sprintf("%s of %s %s", s, formatAmount(&limit), unit)
+}
+
+func rackBudgetGet(app *cli.Cmd) {
+	app.LongDesc = `
+Adds up the weight and power draw of every slot in the rack's layout, as though
+all of them were filled, and compares the totals with the limits set for the
+rack's room with 'conch room ID limits set'.
+
+A hardware product's weight and power draw come from the weight_kg and
+power_watts keys of its specification. Products that lack them count as
+nothing and are listed, so that the totals aren't trusted more than they
+should be.`
+
+	app.Action = func() {
+		rack, err := util.API.GetRack(GRackUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		layout, err := util.API.GetRackLayout(rack)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		products, err := util.API.GetAllHardwareProducts()
+		if err != nil {
+			util.Bail(err)
+		}
+		byID := make(map[uuid.UUID]conch.HardwareProduct)
+		for _, p := range products {
+			byID[p.ID] = p
+		}
+
+		b, err := conch.NewRackBudget(layout, byID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		limits := util.RoomLimits(rack.DatacenterRoomID)
+		ret := rackBudget{
+			RackID:     rack.ID,
+			RackName:   rack.Name,
+			RoomID:     rack.DatacenterRoomID,
+			RackBudget: b,
+			Limits:     limits,
+			Over:       b.Exceeds(limits.MaxRackWeight, limits.MaxRackPower),
+		}
+
+		if util.JSON {
+			util.JSONOut(ret)
+			return
+		}
+
+		if len(b.Slots) == 0 {
+			fmt.Printf("Rack %s has no layout\n", rack.Name)
+			return
+		}
+
+		header := []string{"RU Start", "Product", "Weight (kg)", "Power (W)"}
+		row := func(i int) []string {
+			s := b.Slots[i]
+			return []string{
+				strconv.Itoa(s.RUStart),
+				s.ProductName,
+				formatAmount(s.Weight),
+				formatAmount(s.Power),
+			}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(b.Slots), row); err != nil {
+			util.Bail(err)
+		}
+
+		fmt.Println()
+		fmt.Printf("Weight: %s\n", budgetLine(b.Weight, limits.MaxRackWeight, "kg"))
+		fmt.Printf("Power:  %s\n", budgetLine(b.Power, limits.MaxRackPower, "W"))
+
+		if len(b.Unknown) > 0 {
+			fmt.Printf(
+				"\nThese products don't give their weight or power draw, and count as nothing: %s\n",
+				strings.Join(b.Unknown, ", "),
+			)
+		}
+		if len(ret.Over) > 0 {
+			fmt.Println()
+			for _, o := range ret.Over {
+				fmt.Printf("Over budget: the layout %s set for the room\n", o)
+			}
+		}
+	}
+}
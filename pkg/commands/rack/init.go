@@ -7,6 +7,8 @@
 package rack
 
 import (
+	"errors"
+
 	"github.com/jawher/mow.cli"
 	"github.com/joyent/conch-shell/pkg/conch/uuid"
 	"github.com/joyent/conch-shell/pkg/util"
@@ -17,34 +19,91 @@ var GRackUUID uuid.UUID
 
 // Init loads up the commands
 func Init(app *cli.Cli) {
-	app.Command(
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
 		"racks rks",
 		"Operate on all racks",
 		func(cmd *cli.Cmd) {
 			cmd.Before = util.BuildAPIAndVerifyLogin
-			cmd.Command(
+			util.Command(
+				cmd,
 				"get",
 				"Get all racks",
 				rackGetAll,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"create",
 				"Create a rack",
 				rackCreate,
 			)
+
+			util.Command(
+				cmd,
+				"layout-templates lts",
+				"Deal with the named rack layout templates",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"list ls",
+						"List the layout templates",
+						templateList,
+					)
+
+					util.Command(
+						cmd,
+						"show",
+						"Show the slots of a layout template",
+						templateShow,
+					)
+
+					util.Command(
+						cmd,
+						"delete rm",
+						"Delete a layout template",
+						templateDelete,
+					)
+
+					util.Command(
+						cmd,
+						"dir",
+						"Set the directory of shared layout templates",
+						templateSetDir,
+					)
+				},
+			)
 		},
 	)
 
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"rack rk",
 		"Operate on individual racks",
 		func(r *cli.Cmd) {
 			var rackIDStr = r.StringArg("ID", "", "The UUID of the rack")
 
-			r.Spec = "ID"
+			r.Spec = "[ID]"
 			r.Before = func() {
 				util.BuildAPIAndVerifyLogin()
+
+				// Layouts look up the same hardware products over and over
+				util.API.EnableMemoization()
+
+				if *rackIDStr == "" {
+					if !util.CanPick() {
+						util.Bail(errors.New("a rack ID or name is needed"))
+					}
+					id, err := util.PickRackID()
+					if err != nil {
+						util.Bail(err)
+					}
+					GRackUUID = id
+					return
+				}
+
 				id, err := util.MagicRackID(*rackIDStr)
 				if err != nil {
 					util.Bail(err)
@@ -52,23 +111,27 @@ func Init(app *cli.Cli) {
 				GRackUUID = id
 			}
 
-			r.Command(
+			util.Command(
+				r,
 				"get",
 				"Get a rack",
 				rackGet,
 			)
 
-			r.Command(
+			util.Command(
+				r,
 				"phase",
 				"Get the rack's phase",
 				rackPhaseGet,
 			)
 
-			r.Command(
+			util.Command(
+				r,
 				"set",
 				"Change various settings on a rack",
 				func(cmd *cli.Cmd) {
-					cmd.Command(
+					util.Command(
+						cmd,
 						"phase",
 						"Set a rack's phase",
 						rackPhaseSet,
@@ -76,55 +139,141 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			r.Command(
+			util.Command(
+				r,
 				"delete rm",
 				"Delete a rack",
 				rackDelete,
 			)
 
-			r.Command(
+			util.Command(
+				r,
 				"update",
 				"Update a rack",
 				rackUpdate,
 			)
 
-			r.Command(
+			util.Command(
+				r,
 				"layout",
 				"Commands for dealing with the rack's layout",
 				func(l *cli.Cmd) {
-					l.Command(
+					util.Command(
+						l,
 						"get",
 						"Get the rack's layout",
 						rackLayout,
 					)
 
-					l.Command(
+					util.Command(
+						l,
 						"import",
 						"Import a layout for this rack",
 						rackImportLayout,
 					)
 
-					l.Command(
+					util.Command(
+						l,
 						"export",
 						"Export the layout for this rack",
 						rackExportLayout,
 					)
+
+					util.Command(
+						l,
+						"template",
+						"Save this rack's layout as a named template, or apply one to it",
+						func(t *cli.Cmd) {
+							util.Command(
+								t,
+								"save",
+								"Save the rack's layout as a named template",
+								templateSave,
+							)
+
+							util.Command(
+								t,
+								"apply",
+								"Replace the rack's layout with a named template",
+								templateApply,
+							)
+
+							util.Command(
+								t,
+								"list ls",
+								"List the layout templates",
+								templateList,
+							)
+						},
+					)
+				},
+			)
+
+			util.Command(
+				r,
+				"budget",
+				"Total the weight and power draw of the rack's layout, against the limits of its room",
+				rackBudgetGet,
+			)
+
+			util.Command(
+				r,
+				"maintenance",
+				"Put the devices in this rack in maintenance, so that planned work doesn't page anyone",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"start",
+						"Put every device in the rack in maintenance for a number of hours",
+						startRackMaintenance,
+					)
+
+					util.Command(
+						cmd,
+						"end stop",
+						"Take the rack's devices out of maintenance before the window ends",
+						endRackMaintenance,
+					)
+
+					util.Command(
+						cmd,
+						"status get",
+						"Show which of the rack's devices are in maintenance",
+						getRackMaintenanceStatus,
+					)
 				},
 			)
 
-			r.Command(
+			util.Command(
+				r,
 				"assign",
 				"Assign devices to slots in this rack using JSON artifacts",
 				rackAssign,
 			)
 
-			r.Command(
+			util.Command(
+				r,
 				"assignments",
-				"Dump a JSON extract of the devices assigned to this rack's slots. Intended for use with 'assign'",
+				"Dump a JSON extract of the devices assigned to this rack's slots, intended for use with 'assign', or a CSV worksheet",
 				rackAssignments,
 			)
 
 		},
 	)
 
+	util.Command(
+		app.Cmd,
+		"rack-role",
+		"Operate on rack roles",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIRequiringFeature("rack-roles")
+
+			util.Command(
+				cmd,
+				"audit",
+				"List racks whose layouts don't fit their role's rack size",
+				roleAudit,
+			)
+		},
+	)
 }
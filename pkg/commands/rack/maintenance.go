@@ -0,0 +1,356 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rack
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// What became of a device in 'rack maintenance start' and 'end'
+const (
+	maintenanceStarted = "started"
+	maintenanceEnded   = "ended"
+	maintenanceKept    = "left alone"
+	maintenanceNone    = "not in maintenance"
+	maintenanceFailed  = "failed"
+)
+
+// rackDeviceMaintenance is the maintenance window of one device in a rack.
+// Maintenance is nil if the device has never had one.
+type rackDeviceMaintenance struct {
+	DeviceID      string             `json:"device_id"`
+	RackUnitStart int                `json:"rack_unit_start"`
+	Maintenance   *conch.Maintenance `json:"maintenance"`
+	Active        bool               `json:"active"`
+	Result        string             `json:"result,omitempty"`
+	Detail        string             `json:"detail,omitempty"`
+}
+
+// rackMaintenance is what the 'rack maintenance' commands print with --json
+type rackMaintenance struct {
+	RackID   uuid.UUID               `json:"rack_id"`
+	RackName string                  `json:"rack_name"`
+	State    string                  `json:"state"`
+	Devices  []rackDeviceMaintenance `json:"devices"`
+}
+
+// rackDevices lists the devices assigned to the rack, from the bottom up
+func rackDevices(rackID uuid.UUID) ([]rackDeviceMaintenance, error) {
+	assignments, err := util.API.GetRackAssignments(rackID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(assignments)
+
+	devices := make([]rackDeviceMaintenance, 0)
+	for _, a := range assignments {
+		if a.DeviceID == "" {
+			continue
+		}
+		devices = append(devices, rackDeviceMaintenance{
+			DeviceID:      a.DeviceID,
+			RackUnitStart: a.RackUnitStart,
+		})
+	}
+	return devices, nil
+}
+
+// fetchMaintenance fills in the maintenance window of each device
+func fetchMaintenance(devices []rackDeviceMaintenance) error {
+	now := time.Now()
+	return util.Each(
+		len(devices),
+		fmt.Sprintf("Fetching the maintenance windows of %d devices", len(devices)),
+		func(i int) error {
+			m, err := util.API.GetDeviceMaintenance(devices[i].DeviceID)
+			if err == conch.ErrDataNotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			devices[i].Maintenance = &m
+			devices[i].Active = m.Active(now)
+			return nil
+		},
+	)
+}
+
+// getRackMaintenance fetches the rack and the maintenance windows of the
+// devices in it
+func getRackMaintenance() rackMaintenance {
+	r, err := util.API.GetRack(GRackUUID)
+	if err != nil {
+		util.Bail(err)
+	}
+
+	devices, err := rackDevices(GRackUUID)
+	if err != nil {
+		util.Bail(err)
+	}
+	if err := fetchMaintenance(devices); err != nil {
+		util.Bail(err)
+	}
+
+	rm := rackMaintenance{RackID: r.ID, RackName: r.Name, Devices: devices}
+	rm.State = rm.state()
+	return rm
+}
+
+// state sums up how much of the rack is in maintenance. A rack whose devices
+// are all in maintenance is quiesced.
+func (rm rackMaintenance) state() string {
+	active := 0
+	for _, d := range rm.Devices {
+		if d.Active {
+			active++
+		}
+	}
+
+	switch {
+	case len(rm.Devices) == 0:
+		return "empty"
+	case active == 0:
+		return "in service"
+	case active == len(rm.Devices):
+		return "quiesced"
+	default:
+		return fmt.Sprintf("partly in maintenance (%d of %d devices)", active, len(rm.Devices))
+	}
+}
+
+// rackMaintenanceState sums up how much of a rack is in maintenance, looking
+// up only the devices in maintenance rather than every device in the rack
+func rackMaintenanceState(rackID uuid.UUID) (string, error) {
+	devices, err := rackDevices(rackID)
+	if err != nil {
+		return "", err
+	}
+	inMaintenance, err := util.API.GetDevicesInMaintenance(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	for i, d := range devices {
+		if m, ok := inMaintenance[d.DeviceID]; ok {
+			devices[i].Maintenance = &m
+			devices[i].Active = true
+		}
+	}
+	return rackMaintenance{Devices: devices}.state(), nil
+}
+
+func (rm rackMaintenance) render() {
+	fmt.Printf("Rack:  %s (%s)\n", rm.RackName, rm.RackID)
+	fmt.Printf("State: %s\n", rm.State)
+	if len(rm.Devices) == 0 {
+		return
+	}
+
+	results := false
+	for _, d := range rm.Devices {
+		if d.Result != "" {
+			results = true
+			break
+		}
+	}
+
+	header := []string{"RU", "Device", "In Maintenance", "Until", "Reason", "By"}
+	if results {
+		header = append(header, "Result")
+	}
+
+	fmt.Println()
+	table := util.GetMarkdownTable()
+	table.SetHeader(header)
+	for _, d := range rm.Devices {
+		row := []string{strconv.Itoa(d.RackUnitStart), d.DeviceID, "no", "", "", ""}
+		if d.Active {
+			row[2] = "yes"
+			if d.Maintenance.RackID != rm.RackID.String() {
+				row[2] = "yes, on its own"
+			}
+			row[3] = util.TimeStr(d.Maintenance.Until)
+			row[4] = d.Maintenance.Reason
+			row[5] = d.Maintenance.User
+		}
+		if results {
+			result := d.Result
+			if d.Detail != "" {
+				result += ": " + d.Detail
+			}
+			row = append(row, result)
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
+
+func startRackMaintenance(cmd *cli.Cmd) {
+	var (
+		hoursOpt  = cmd.IntOpt("hours", 4, "How long the rack will be in maintenance")
+		reasonOpt = cmd.StringOpt("reason", "", "What is being done to the rack, eg 'PDU swap'")
+	)
+	cmd.Spec = "[--hours] --reason"
+
+	cmd.LongDesc = `
+Marks every device in the rack as being worked on for the next few hours,
+recording who is doing the work and why, so that other teams can see the rack
+is quiesced. Devices in maintenance are left out of the failing and stale
+counts of 'conch status' and 'conch check'.
+
+Each device gets its own maintenance window, as 'device maintenance start'
+would give it, replacing any it already had. A device that fails to be marked
+doesn't stop the rest, but the command exits non-zero.`
+
+	cmd.Action = func() {
+		if *hoursOpt < 1 {
+			util.Bail(errors.New("--hours must be at least 1"))
+		}
+		if max := int(conch.MaxMaintenance / time.Hour); *hoursOpt > max {
+			util.Bail(fmt.Errorf("--hours can be at most %d", max))
+		}
+		if strings.TrimSpace(*reasonOpt) == "" {
+			util.Bail(errors.New("please give a --reason"))
+		}
+
+		r, err := util.API.GetRack(GRackUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+		devices, err := rackDevices(GRackUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		now := time.Now().UTC()
+		window := conch.Maintenance{
+			RackID:  r.ID.String(),
+			Started: now,
+			Until:   now.Add(time.Duration(*hoursOpt) * time.Hour),
+			Reason:  *reasonOpt,
+		}
+		if util.ActiveProfile != nil {
+			window.User = util.ActiveProfile.User
+		}
+
+		failed := 0
+		// Failures are recorded rather than returned, so that one device
+		// doesn't stop the rest
+		_ = util.Each(
+			len(devices),
+			fmt.Sprintf("Starting maintenance on %d devices", len(devices)),
+			func(i int) error {
+				m := window
+				m.DeviceID = devices[i].DeviceID
+				if err := util.API.StartDeviceMaintenance(m); err != nil {
+					devices[i].Result = maintenanceFailed
+					devices[i].Detail = err.Error()
+					return nil
+				}
+				devices[i].Maintenance = &m
+				devices[i].Active = true
+				devices[i].Result = maintenanceStarted
+				return nil
+			},
+		)
+		for _, d := range devices {
+			if d.Result == maintenanceFailed {
+				failed++
+			}
+		}
+
+		rm := rackMaintenance{RackID: r.ID, RackName: r.Name, Devices: devices}
+		rm.State = rm.state()
+		if util.JSON {
+			util.JSONOut(rm)
+		} else {
+			rm.render()
+		}
+
+		if failed > 0 {
+			util.Exit(1)
+		}
+	}
+}
+
+func endRackMaintenance(cmd *cli.Cmd) {
+	var allOpt = cmd.BoolOpt("all", false, "Also end the windows devices were put in on their own, with 'device maintenance start'")
+
+	cmd.LongDesc = `
+Takes the devices in the rack out of the maintenance started with
+'rack maintenance start', before the window ends by itself. Devices that were
+put in maintenance on their own are left in it, unless --all is given.`
+
+	cmd.Action = func() {
+		rm := getRackMaintenance()
+
+		failed := 0
+		_ = util.Each(
+			len(rm.Devices),
+			fmt.Sprintf("Ending maintenance on %d devices", len(rm.Devices)),
+			func(i int) error {
+				d := &rm.Devices[i]
+				if d.Maintenance == nil {
+					d.Result = maintenanceNone
+					return nil
+				}
+				if d.Maintenance.RackID != rm.RackID.String() && !*allOpt {
+					d.Result = maintenanceKept
+					return nil
+				}
+
+				if err := util.API.EndDeviceMaintenance(d.DeviceID); err != nil {
+					d.Result = maintenanceFailed
+					d.Detail = err.Error()
+					return nil
+				}
+				d.Maintenance = nil
+				d.Active = false
+				d.Result = maintenanceEnded
+				return nil
+			},
+		)
+		for _, d := range rm.Devices {
+			if d.Result == maintenanceFailed {
+				failed++
+			}
+		}
+
+		rm.State = rm.state()
+		if util.JSON {
+			util.JSONOut(rm)
+		} else {
+			rm.render()
+		}
+
+		if failed > 0 {
+			util.Exit(1)
+		}
+	}
+}
+
+func getRackMaintenanceStatus(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		rm := getRackMaintenance()
+		if util.JSON {
+			util.JSONOut(rm)
+			return
+		}
+		rm.render()
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rack
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("racks get", []conch.Rack{})
+	util.RegisterOutput("racks create", conch.Rack{})
+	util.RegisterOutput("racks layout-templates list", []layoutTemplate{})
+	util.RegisterOutput("racks layout-templates show", layoutTemplate{})
+
+	util.RegisterOutput("rack get", conch.Rack{})
+	util.RegisterOutput("rack layout get", conch.RackLayoutSlots{})
+	util.RegisterOutput("rack layout export", importLayout{})
+	util.RegisterOutput("rack layout template save", config.LayoutTemplate{})
+	util.RegisterOutput("rack layout template list", []layoutTemplate{})
+	util.RegisterOutput("rack assignments", conch.ResponseRackAssignments{})
+	util.RegisterOutput("rack budget", rackBudget{})
+	util.RegisterOutput("rack maintenance start", rackMaintenance{})
+	util.RegisterOutput("rack maintenance end", rackMaintenance{})
+	util.RegisterOutput("rack maintenance status", rackMaintenance{})
+
+	util.RegisterOutput("rack-role audit", []layoutProblem{})
+}
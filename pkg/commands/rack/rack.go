@@ -98,6 +98,12 @@ func rackGet(app *cli.Cmd) {
 		}
 
 		displayOneRack(r)
+
+		state, err := rackMaintenanceState(r.ID)
+		if err != nil {
+			util.Bail(err)
+		}
+		fmt.Printf("Maintenance: %s\n\n", state)
 	}
 }
 
@@ -156,6 +162,8 @@ func rackUpdate(app *cli.Cmd) {
 		if err != nil {
 			util.Bail(err)
 		}
+		before := r
+
 		if *dcIDOpt != "" {
 			dcID, err := uuid.FromString(*dcIDOpt)
 			if err != nil {
@@ -188,15 +196,19 @@ func rackUpdate(app *cli.Cmd) {
 			util.Bail(err)
 		}
 
-		if util.JSON {
-			util.JSONOut(r)
-			return
+		after, err := util.API.GetRack(r.ID)
+		if err != nil {
+			util.Bail(err)
 		}
-		displayOneRack(r)
+		util.DisplayFieldDiff("rack "+after.Name, util.FieldDiff(before, after))
 	}
 }
 func rackDelete(app *cli.Cmd) {
+	var breakGlassOpts = util.NewBreakGlassOpts(app)
+
 	app.Action = func() {
+		util.BreakGlassRackDelete(breakGlassOpts, GRackUUID)
+
 		if err := util.API.DeleteRack(GRackUUID); err != nil {
 			util.Bail(err)
 		}
@@ -256,54 +268,65 @@ type importLayout []importLayoutSlot
 
 func rackExportLayout(cmd *cli.Cmd) {
 	cmd.Action = func() {
-		rack, err := util.API.GetRack(GRackUUID)
-		if err != nil {
-			util.Bail(err)
-		}
-
-		// Get the current state of the world
-		existingLayout, err := util.API.GetRackLayout(rack)
+		output, err := exportLayout()
 		if err != nil {
 			util.Bail(err)
 		}
 
-		var output importLayout
-		if len(existingLayout) == 0 {
+		if len(output) == 0 {
 			output = append(output, importLayoutSlot{
 				RUStart:      0,
 				ProductID:    uuid.UUID{},
 				ProductName:  "Product Name",
 				ProductAlias: "Product Alias",
 			})
-		} else {
-
-			for _, l := range existingLayout {
-				hw, err := util.API.GetHardwareProduct(l.ProductID)
-				if err != nil {
-					util.Bail(err)
-				}
-				output = append(output, importLayoutSlot{
-					RUStart:      l.RUStart,
-					ProductID:    hw.ID,
-					ProductName:  hw.Name,
-					ProductAlias: hw.Alias,
-				})
-			}
 		}
 
 		util.JSONOutIndent(output)
 	}
 }
 
+// exportLayout gathers the current layout of the rack in the form that
+// 'layout import' takes
+func exportLayout() (importLayout, error) {
+	rack, err := util.API.GetRack(GRackUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	existingLayout, err := util.API.GetRackLayout(rack)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(importLayout, 0, len(existingLayout))
+	for _, l := range existingLayout {
+		hw, err := util.API.GetHardwareProduct(l.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, importLayoutSlot{
+			RUStart:      l.RUStart,
+			ProductID:    hw.ID,
+			ProductName:  hw.Name,
+			ProductAlias: hw.Alias,
+		})
+	}
+	return output, nil
+}
+
 func rackImportLayout(cmd *cli.Cmd) {
 	var (
 		filePathArg  = cmd.StringArg("FILE", "-", "Path to a JSON file that defines the layout. '-' indicates STDIN")
 		overwriteOpt = cmd.BoolOpt("overwrite", false, "If the rack has an existing layout, *overwrite* it. This is a destructive action")
+		planOpts     = util.NewPlanOpts(cmd)
 	)
 
 	cmd.Spec = "[OPTIONS] [FILE]"
+
+	util.NotifyOpt(cmd, "rack layout import")
+
 	cmd.Action = func() {
-		util.JSON = true
 		var b []byte
 		var err error
 		if *filePathArg == "-" {
@@ -321,107 +344,166 @@ func rackImportLayout(cmd *cli.Cmd) {
 			util.Bail(err)
 		}
 
-		rack, err := util.API.GetRack(GRackUUID)
-		if err != nil {
-			util.Bail(err)
-		}
+		applyLayout(importedLayout, *overwriteOpt, planOpts)
+	}
+}
 
-		// Get the current state of the world
-		existingLayout, err := util.API.GetRackLayout(rack)
-		if err != nil {
-			util.Bail(err)
-		}
+// applyLayout works out the changes needed to give the rack the imported
+// layout, and makes them once the user agrees
+func applyLayout(importedLayout importLayout, overwrite bool, planOpts util.PlanOpts) {
+	rack, err := util.API.GetRack(GRackUUID)
+	if err != nil {
+		util.Bail(err)
+	}
 
-		if len(existingLayout) > 0 {
-			if !*overwriteOpt {
-				util.Bail(errors.New("rack already has a layout. Use --overwrite to overwrite"))
-			}
-		}
+	// Get the current state of the world
+	existingLayout, err := util.API.GetRackLayout(rack)
+	if err != nil {
+		util.Bail(err)
+	}
 
-		// We need to support the use of product names and aliases in the
-		// import so they're readable by humans. We lack a way of doing API
-		// lookups on these properties so we pull them all down and create maps
-		// on our own.
-		productsL, err := util.API.GetHardwareProducts()
-		if err != nil {
-			util.Bail(err)
+	if len(existingLayout) > 0 {
+		if !overwrite {
+			util.Bail(errors.New("rack already has a layout. Use --overwrite to overwrite"))
 		}
+	}
 
-		productsAlias := make(map[string]conch.HardwareProduct)
-		productsName := make(map[string]conch.HardwareProduct)
-		productsID := make(map[string]conch.HardwareProduct)
+	// We need to support the use of product names and aliases in the
+	// import so they're readable by humans. We lack a way of doing API
+	// lookups on these properties so we pull them all down and create maps
+	// on our own. Deactivated products are included so that layouts
+	// that use them can be warned about, rather than failing outright.
+	productsL, err := util.API.GetAllHardwareProducts()
+	if err != nil {
+		util.Bail(err)
+	}
 
-		for _, p := range productsL {
-			productsAlias[p.Alias] = p
-			productsName[p.Name] = p
-			productsID[p.ID.String()] = p
-		}
+	productsAlias := make(map[string]conch.HardwareProduct)
+	productsName := make(map[string]conch.HardwareProduct)
+	productsID := make(map[string]conch.HardwareProduct)
+
+	for _, p := range productsL {
+		productsAlias[p.Alias] = p
+		productsName[p.Name] = p
+		productsID[p.ID.String()] = p
+	}
 
-		var finalLayout []conch.RackLayoutSlot
+	var finalLayout []conch.RackLayoutSlot
 
-		for _, l := range importedLayout {
-			if uuid.Equal(l.ProductID, uuid.UUID{}) {
-				if l.ProductName != "" {
-					p, ok := productsName[l.ProductName]
-					if ok {
-						l.ProductID = p.ID
-					}
-				} else if l.ProductAlias != "" {
-					p, ok := productsAlias[l.ProductAlias]
-					if ok {
-						l.ProductID = p.ID
-					}
-				} else {
-					util.Bail(fmt.Errorf(
-						"ru_start %d entry does not have a product id, name, or alias",
-						l.RUStart,
-					))
+	for _, l := range importedLayout {
+		if uuid.Equal(l.ProductID, uuid.UUID{}) {
+			if l.ProductName != "" {
+				p, ok := productsName[l.ProductName]
+				if ok {
+					l.ProductID = p.ID
 				}
-
-				if uuid.Equal(l.ProductID, uuid.UUID{}) {
-					util.Bail(fmt.Errorf(
-						"ru_start %d entry does not have a product id, name, or alias",
-						l.RUStart,
-					))
+			} else if l.ProductAlias != "" {
+				p, ok := productsAlias[l.ProductAlias]
+				if ok {
+					l.ProductID = p.ID
 				}
 			} else {
-				_, ok := productsID[l.ProductID.String()]
-				if !ok {
-					util.Bail(errors.New("Product ID " + l.ProductID.String() + " is unknown"))
-				}
+				util.Bail(fmt.Errorf(
+					"ru_start %d entry does not have a product id, name, or alias",
+					l.RUStart,
+				))
 			}
-			s := conch.RackLayoutSlot{
-				RackID:    GRackUUID,
-				ProductID: l.ProductID,
-				RUStart:   l.RUStart,
+
+			if uuid.Equal(l.ProductID, uuid.UUID{}) {
+				util.Bail(fmt.Errorf(
+					"ru_start %d entry does not have a product id, name, or alias",
+					l.RUStart,
+				))
 			}
+		} else {
+			_, ok := productsID[l.ProductID.String()]
+			if !ok {
+				util.Bail(errors.New("Product ID " + l.ProductID.String() + " is unknown"))
+			}
+		}
+		s := conch.RackLayoutSlot{
+			RackID:    GRackUUID,
+			ProductID: l.ProductID,
+			RUStart:   l.RUStart,
+		}
+
+		finalLayout = append(finalLayout, s)
+	}
 
-			finalLayout = append(finalLayout, s)
+	// The server only notices overlaps once it gets to them, by which time
+	// part of the layout has been changed
+	if err := conch.CheckRackLayout(finalLayout, conch.RackUnitSizes(productsL)); err != nil {
+		util.Bail(fmt.Errorf("%s. Nothing was changed", err))
+	}
+
+	util.WarnRackBudget(rack, finalLayout, productsL)
+
+	// Work out the difference between the existing layout and the import
+	// before touching anything. That way, if the import has problems, we
+	// haven't changed any data yet and the user gets to see what's about
+	// to happen.
+	plan := util.NewPlan()
+
+	wanted := make(map[int]bool)
+	for _, s := range finalLayout {
+		wanted[s.RUStart] = true
+	}
+
+	existingByRU := make(map[int]conch.RackLayoutSlot)
+	sort.Sort(existingLayout)
+	for _, s := range existingLayout {
+		s := s
+		existingByRU[s.RUStart] = s
+		if wanted[s.RUStart] {
+			continue
+		}
+		plan.Add(
+			util.PlanDelete,
+			"layout slot",
+			fmt.Sprintf("%s ru %d", rack.Name, s.RUStart),
+			productsID[s.ProductID.String()].Name,
+			func() error { return util.API.DeleteRackLayoutSlot(s.ID) },
+		)
+	}
+
+	for _, s := range finalLayout {
+		s := s
+		name := fmt.Sprintf("%s ru %d", rack.Name, s.RUStart)
+		product := productsID[s.ProductID.String()].Name
+
+		old, ok := existingByRU[s.RUStart]
+		if ok && uuid.Equal(old.ProductID, s.ProductID) {
+			continue
 		}
 
-		// If the rack has a layout, and the user asked us to, nuke the
-		// existing layout
-		if *overwriteOpt {
-			for _, s := range existingLayout {
-				err := util.API.DeleteRackLayoutSlot(s.ID)
-				if err != nil {
-					util.Bail(err)
-				}
-			}
+		if d := productsID[s.ProductID.String()].Deactivated; !d.IsZero() {
+			fmt.Fprintf(
+				os.Stderr,
+				"Warning: %s uses hardware product %s, which was deactivated on %s\n",
+				name,
+				product,
+				util.TimeStr(d),
+			)
 		}
 
-		// Yes, technically, doing this in two loops is unnecessary. It gets us
-		// a bit of sanity though. First loop verifies the data and the second
-		// loop acts on it. That way, if the first loop runs into problems, we
-		// haven't changed any data yet.
-		for _, s := range finalLayout {
-			err := util.API.SaveRackLayoutSlot(&s)
-			if err != nil {
-				util.Bail(err)
-			}
+		if !ok {
+			plan.Add(util.PlanCreate, "layout slot", name, product, func() error {
+				return util.API.SaveRackLayoutSlot(&s)
+			})
+			continue
 		}
 
+		s.ID = old.ID
+		plan.Add(
+			util.PlanUpdate,
+			"layout slot",
+			name,
+			productsID[old.ProductID.String()].Name+" -> "+product,
+			func() error { return util.API.SaveRackLayoutSlot(&s) },
+		)
 	}
+
+	plan.Run(planOpts)
 }
 
 func rackPhaseGet(cmd *cli.Cmd) {
@@ -495,6 +577,18 @@ func rackAssign(app *cli.Cmd) {
 }
 
 func rackAssignments(app *cli.Cmd) {
+	var csvOpt = app.BoolOpt("csv", false, "Print a CSV worksheet of the rack, with names, asset tags, and device health, instead of JSON")
+
+	app.LongDesc = `
+Prints the devices assigned to the rack's slots as JSON, in the form 'assign'
+accepts.
+
+With --csv, a worksheet suitable for printing is written instead, with a row
+for every slot in the rack's layout, occupied or not. Each row has the rack
+unit, the product the layout calls for, and the serial, asset tag, and health
+of the device in the slot. This requires fetching each assigned device
+individually.`
+
 	app.Action = func() {
 		a, err := util.API.GetRackAssignments(GRackUUID)
 		if err != nil {
@@ -502,6 +596,14 @@ func rackAssignments(app *cli.Cmd) {
 		}
 
 		sort.Sort(a)
+
+		if *csvOpt {
+			if err := writeAssignmentsCSV(os.Stdout, a); err != nil {
+				util.Bail(err)
+			}
+			return
+		}
+
 		util.JSONOutIndent(a)
 	}
 }
@@ -0,0 +1,253 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rack
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// layoutProblem is a single thing wrong with a rack's layout
+type layoutProblem struct {
+	RackID   uuid.UUID `json:"rack_id"`
+	RackName string    `json:"rack_name"`
+	Role     string    `json:"role"`
+	RackSize int       `json:"rack_size"`
+	SlotID   uuid.UUID `json:"slot_id,omitempty"`
+	RUStart  int       `json:"ru_start,omitempty"`
+	RUEnd    int       `json:"ru_end,omitempty"`
+	Problem  string    `json:"problem"`
+}
+
+// auditLayout checks a rack's layout against its role. sizes holds the
+// number of rack units each hardware product takes up.
+func auditLayout(
+	rack conch.Rack,
+	role conch.RackRole,
+	roleKnown bool,
+	slots conch.RackLayoutSlots,
+	sizes map[uuid.UUID]int,
+) []layoutProblem {
+	problems := make([]layoutProblem, 0)
+
+	add := func(s *conch.RackLayoutSlot, end int, problem string) {
+		p := layoutProblem{
+			RackID:   rack.ID,
+			RackName: rack.Name,
+			Role:     role.Name,
+			RackSize: role.RackSize,
+			Problem:  problem,
+		}
+		if s != nil {
+			p.SlotID = s.ID
+			p.RUStart = s.RUStart
+			p.RUEnd = end
+		}
+		problems = append(problems, p)
+	}
+
+	if !roleKnown {
+		add(nil, 0, fmt.Sprintf("rack role %s does not exist", rack.RoleID))
+	}
+
+	sorted := make(conch.RackLayoutSlots, len(slots))
+	copy(sorted, slots)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].RUStart < sorted[j].RUStart })
+
+	var (
+		prev    *conch.RackLayoutSlot
+		prevEnd int
+	)
+	for i := range sorted {
+		s := &sorted[i]
+
+		_, end := s.RackUnits(sizes)
+		if _, ok := sizes[s.ProductID]; !ok {
+			add(s, s.RUStart, fmt.Sprintf("hardware product %s does not exist", s.ProductID))
+		}
+
+		if s.RUStart < 1 {
+			add(s, end, "starts below RU 1")
+		}
+		if roleKnown && end > role.RackSize {
+			add(s, end, fmt.Sprintf(
+				"extends beyond the %d RUs of role %s",
+				role.RackSize,
+				role.Name,
+			))
+		}
+		if prev != nil && s.RUStart <= prevEnd {
+			add(s, end, fmt.Sprintf(
+				"overlaps slot %s at RU %d-%d",
+				prev.ID,
+				prev.RUStart,
+				prevEnd,
+			))
+		}
+
+		if prev == nil || end > prevEnd {
+			prev = s
+			prevEnd = end
+		}
+	}
+
+	return problems
+}
+
+// auditBudget checks that a rack's layout keeps it within the weight and
+// power limits of its room. Products missing from the catalogue are left to
+// auditLayout.
+func auditBudget(
+	rack conch.Rack,
+	role conch.RackRole,
+	slots conch.RackLayoutSlots,
+	products map[uuid.UUID]conch.HardwareProduct,
+) []layoutProblem {
+	problems := make([]layoutProblem, 0)
+
+	limits := util.RoomLimits(rack.DatacenterRoomID)
+	if limits.MaxRackWeight == 0 && limits.MaxRackPower == 0 {
+		return problems
+	}
+
+	b, err := conch.NewRackBudget(slots, products)
+	if err != nil {
+		return problems
+	}
+
+	for _, o := range b.Exceeds(limits.MaxRackWeight, limits.MaxRackPower) {
+		problems = append(problems, layoutProblem{
+			RackID:   rack.ID,
+			RackName: rack.Name,
+			Role:     role.Name,
+			RackSize: role.RackSize,
+			Problem:  fmt.Sprintf("the layout %s set for the room", o),
+		})
+	}
+	return problems
+}
+
+func roleAudit(app *cli.Cmd) {
+	var roleOpt = app.StringOpt("role", "", "Only audit racks with this role, by UUID or name")
+
+	app.LongDesc = `
+Checks every rack's layout against the size of its rack role, and reports
+slots that start below RU 1, run past the top of the rack, or overlap another
+slot. A slot's height comes from its hardware product's rack_unit, so products
+missing from the catalogue are reported as well.
+
+These are the layouts that make device assignment fail later on. Racks whose
+layouts would weigh or draw more than the limits set for their rooms, with
+'conch room ID limits set', are reported too. The command exits non-zero when
+it finds any problems.`
+
+	app.Action = func() {
+		var onlyRole uuid.UUID
+		if *roleOpt != "" {
+			id, err := util.MagicRackRoleID(*roleOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+			onlyRole = id
+		}
+
+		racks, err := util.API.GetRacks()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		roleList, err := util.API.GetRackRoles()
+		if err != nil {
+			util.Bail(err)
+		}
+		roles := make(map[uuid.UUID]conch.RackRole)
+		for _, r := range roleList {
+			roles[r.ID] = r
+		}
+
+		// One request for every slot is far cheaper than one per rack
+		allSlots, err := util.API.GetRackLayoutSlots()
+		if err != nil {
+			util.Bail(err)
+		}
+		layouts := make(map[uuid.UUID]conch.RackLayoutSlots)
+		for _, s := range allSlots {
+			layouts[s.RackID] = append(layouts[s.RackID], s)
+		}
+
+		products, err := util.API.GetHardwareProducts()
+		if err != nil {
+			util.Bail(err)
+		}
+		sizes := conch.RackUnitSizes(products)
+		byID := make(map[uuid.UUID]conch.HardwareProduct)
+		for _, p := range products {
+			byID[p.ID] = p
+		}
+
+		sort.Slice(racks, func(i, j int) bool { return racks[i].Name < racks[j].Name })
+
+		problems := make([]layoutProblem, 0)
+		for _, rack := range racks {
+			if !uuid.Equal(onlyRole, uuid.UUID{}) && !uuid.Equal(rack.RoleID, onlyRole) {
+				continue
+			}
+			role, ok := roles[rack.RoleID]
+			problems = append(
+				problems,
+				auditLayout(rack, role, ok, layouts[rack.ID], sizes)...,
+			)
+			problems = append(
+				problems,
+				auditBudget(rack, role, layouts[rack.ID], byID)...,
+			)
+		}
+
+		if util.JSON {
+			util.JSONOut(problems)
+		} else if len(problems) == 0 {
+			fmt.Println("No layout problems found")
+		} else {
+			header := []string{"Rack", "Role", "Rack Size", "Slot", "RUs", "Problem"}
+			row := func(i int) []string {
+				p := problems[i]
+
+				rus := ""
+				if p.RUStart != 0 || p.RUEnd != 0 {
+					rus = fmt.Sprintf("%d-%d", p.RUStart, p.RUEnd)
+				}
+				slot := ""
+				if !uuid.Equal(p.SlotID, uuid.UUID{}) {
+					slot = p.SlotID.String()
+				}
+
+				return []string{
+					fmt.Sprintf("%s (%s)", p.RackName, p.RackID),
+					p.Role,
+					strconv.Itoa(p.RackSize),
+					slot,
+					rus,
+					p.Problem,
+				}
+			}
+
+			if err := util.RenderTable(util.GetMarkdownTable(), header, len(problems), row, "Role"); err != nil {
+				util.Bail(err)
+			}
+		}
+
+		if len(problems) > 0 {
+			util.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// templateSourceConfig is the Source of templates kept in the config file
+const templateSourceConfig = "config"
+
+// layoutTemplate is a template along with where it was found
+type layoutTemplate struct {
+	*config.LayoutTemplate
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// templateDir is the directory of shared layout templates:
+// CONCH_LAYOUT_TEMPLATE_DIR, or the config's directory, or "" for none
+func templateDir() (string, error) {
+	dir := os.Getenv("CONCH_LAYOUT_TEMPLATE_DIR")
+	if dir == "" && util.Config != nil {
+		dir = util.Config.LayoutTemplateDir
+	}
+	if dir == "" {
+		return "", nil
+	}
+	return homedir.Expand(dir)
+}
+
+func validTemplateName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t/\\") {
+		return errors.New("template names may not be empty or contain whitespace or slashes")
+	}
+	return nil
+}
+
+// readTemplateFile reads a shared template. Besides the form that 'template
+// save --shared' writes, the file can be the plain output of 'layout export',
+// so that existing layout files can be dropped into the directory as they are.
+func readTemplateFile(path string) (*config.LayoutTemplate, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &config.LayoutTemplate{}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		err = json.Unmarshal(b, &t.Slots)
+		if info, statErr := os.Stat(path); statErr == nil {
+			t.Created = info.ModTime().UTC()
+		}
+	} else {
+		err = json.Unmarshal(b, t)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bad layout template %s: %s", path, err)
+	}
+	return t, nil
+}
+
+// loadTemplates gathers the templates in the config and the shared directory.
+// A template in the config hides a shared one of the same name.
+func loadTemplates() (map[string]*layoutTemplate, error) {
+	templates := make(map[string]*layoutTemplate)
+
+	dir, err := templateDir()
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			t, err := readTemplateFile(path)
+			if err != nil {
+				return nil, err
+			}
+			name := strings.TrimSuffix(filepath.Base(path), ".json")
+			templates[name] = &layoutTemplate{t, name, path}
+		}
+	}
+
+	if util.Config != nil {
+		for name, t := range util.Config.LayoutTemplates {
+			templates[name] = &layoutTemplate{t, name, templateSourceConfig}
+		}
+	}
+
+	return templates, nil
+}
+
+func lookupTemplate(name string) *layoutTemplate {
+	templates, err := loadTemplates()
+	if err != nil {
+		util.Bail(err)
+	}
+	t, ok := templates[name]
+	if !ok {
+		util.Bail(fmt.Errorf("there is no layout template named '%s'", name))
+	}
+	return t
+}
+
+func (t *layoutTemplate) layout() importLayout {
+	l := make(importLayout, 0, len(t.Slots))
+	for _, s := range t.Slots {
+		slot := importLayoutSlot{
+			RUStart:      s.RUStart,
+			ProductName:  s.ProductName,
+			ProductAlias: s.ProductAlias,
+		}
+		if s.ProductID != nil {
+			slot.ProductID = *s.ProductID
+		}
+		l = append(l, slot)
+	}
+	return l
+}
+
+func templateSave(cmd *cli.Cmd) {
+	var (
+		nameArg   = cmd.StringArg("NAME", "", "The name of the template")
+		descOpt   = cmd.StringOpt("description d", "", "What the template is for")
+		sharedOpt = cmd.BoolOpt("shared", false, "Save the template to the shared template directory, rather than the config file")
+		forceOpt  = cmd.BoolOpt("force", false, "Replace an existing template with the same name")
+	)
+	cmd.Spec = "[OPTIONS] NAME [OPTIONS]"
+
+	cmd.LongDesc = `
+Saves the rack's current layout as a named template, so that it can be applied
+to other racks with 'layout template apply'. Slots are saved by hardware
+product name and alias, not ID, so a template can be used with any API that
+has the same products.
+
+Templates are kept in the config file unless --shared is given, in which case
+they are written as NAME.json to the directory in CONCH_LAYOUT_TEMPLATE_DIR, or
+set with 'conch racks layout-templates dir'.`
+
+	cmd.Action = func() {
+		name := *nameArg
+		if err := validTemplateName(name); err != nil {
+			util.Bail(err)
+		}
+
+		layout, err := exportLayout()
+		if err != nil {
+			util.Bail(err)
+		}
+		if len(layout) == 0 {
+			util.Bail(errors.New("the rack has no layout to save"))
+		}
+
+		t := &config.LayoutTemplate{
+			Description: *descOpt,
+			Created:     time.Now().UTC(),
+			Slots:       make([]config.LayoutTemplateSlot, 0, len(layout)),
+		}
+		for _, l := range layout {
+			t.Slots = append(t.Slots, config.LayoutTemplateSlot{
+				RUStart:      l.RUStart,
+				ProductName:  l.ProductName,
+				ProductAlias: l.ProductAlias,
+			})
+		}
+
+		existing, err := loadTemplates()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		var where string
+		if *sharedOpt {
+			dir, err := templateDir()
+			if err != nil {
+				util.Bail(err)
+			}
+			if dir == "" {
+				util.Bail(errors.New("there is no shared template directory. Set CONCH_LAYOUT_TEMPLATE_DIR or use 'conch racks layout-templates dir'"))
+			}
+
+			where = filepath.Join(dir, name+".json")
+			if old, ok := existing[name]; ok && old.Source == where && !*forceOpt {
+				util.Bail(fmt.Errorf("a shared template named '%s' already exists. Use --force to replace it", name))
+			}
+
+			b, err := json.MarshalIndent(t, "", "  ")
+			if err != nil {
+				util.Bail(err)
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				util.Bail(err)
+			}
+			if err := util.WriteFileAtomic(where, append(b, '\n'), 0644); err != nil {
+				util.Bail(err)
+			}
+		} else {
+			if old, ok := existing[name]; ok && old.Source == templateSourceConfig && !*forceOpt {
+				util.Bail(fmt.Errorf("a template named '%s' already exists. Use --force to replace it", name))
+			}
+
+			if util.Config.LayoutTemplates == nil {
+				util.Config.LayoutTemplates = make(map[string]*config.LayoutTemplate)
+			}
+			util.Config.LayoutTemplates[name] = t
+			util.WriteConfig()
+			where = "the config file"
+		}
+
+		if util.JSON {
+			util.JSONOut(t)
+			return
+		}
+		fmt.Printf("Saved the %d slot layout as template '%s' in %s\n", len(t.Slots), name, where)
+	}
+}
+
+func templateApply(cmd *cli.Cmd) {
+	var (
+		nameArg      = cmd.StringArg("NAME", "", "The name of the template")
+		overwriteOpt = cmd.BoolOpt("overwrite", false, "If the rack has an existing layout, *overwrite* it. This is a destructive action")
+		planOpts     = util.NewPlanOpts(cmd)
+	)
+	cmd.Spec = "[OPTIONS] NAME [OPTIONS]"
+
+	util.NotifyOpt(cmd, "rack layout template apply")
+
+	cmd.Action = func() {
+		t := lookupTemplate(*nameArg)
+		if len(t.Slots) == 0 {
+			util.Bail(fmt.Errorf("layout template '%s' has no slots", t.Name))
+		}
+		applyLayout(t.layout(), *overwriteOpt, planOpts)
+	}
+}
+
+func templateList(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		templates, err := loadTemplates()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		list := make([]*layoutTemplate, 0, len(templates))
+		for _, t := range templates {
+			list = append(list, t)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+		if util.JSON {
+			util.JSONOut(list)
+			return
+		}
+
+		header := []string{"Name", "Slots", "Description", "Source"}
+		row := func(i int) []string {
+			t := list[i]
+			return []string{t.Name, strconv.Itoa(len(t.Slots)), t.Description, t.Source}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(list), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func templateShow(cmd *cli.Cmd) {
+	var nameArg = cmd.StringArg("NAME", "", "The name of the template")
+	cmd.Spec = "NAME"
+
+	cmd.Action = func() {
+		t := lookupTemplate(*nameArg)
+		if util.JSON {
+			util.JSONOut(t)
+			return
+		}
+
+		if t.Description != "" {
+			fmt.Println(t.Description)
+			fmt.Println()
+		}
+
+		slots := t.layout()
+		sort.Slice(slots, func(i, j int) bool { return slots[i].RUStart < slots[j].RUStart })
+
+		header := []string{"RU", "Product Name", "Product Alias"}
+		row := func(i int) []string {
+			s := slots[i]
+			return []string{strconv.Itoa(s.RUStart), s.ProductName, s.ProductAlias}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(slots), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func templateDelete(cmd *cli.Cmd) {
+	var nameArg = cmd.StringArg("NAME", "", "The name of the template")
+	cmd.Spec = "NAME"
+
+	cmd.Action = func() {
+		t := lookupTemplate(*nameArg)
+
+		if t.Source == templateSourceConfig {
+			delete(util.Config.LayoutTemplates, t.Name)
+			util.WriteConfig()
+		} else if err := os.Remove(t.Source); err != nil {
+			util.Bail(err)
+		}
+
+		if !util.JSON {
+			fmt.Printf("Deleted layout template '%s' from %s\n", t.Name, t.Source)
+		}
+	}
+}
+
+func templateSetDir(cmd *cli.Cmd) {
+	var (
+		dirArg   = cmd.StringArg("DIR", "", "The directory of shared layout templates")
+		clearOpt = cmd.BoolOpt("clear", false, "Stop using a shared template directory")
+	)
+	cmd.Spec = "DIR | --clear"
+
+	cmd.LongDesc = `
+Sets the directory of shared layout templates, one NAME.json file per
+template. A directory that everyone can see, like a checkout of a shared git
+repository, lets standard rack designs be applied by name everywhere.
+CONCH_LAYOUT_TEMPLATE_DIR, if set, is used instead.`
+
+	cmd.Action = func() {
+		if *clearOpt {
+			util.Config.LayoutTemplateDir = ""
+		} else {
+			util.Config.LayoutTemplateDir = *dirArg
+		}
+		util.WriteConfig()
+	}
+}
@@ -18,7 +18,10 @@ var RelaySerial string
 
 // Init loads up all the device related commands
 func Init(app *cli.Cli) {
-	app.Command(
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
 		"relay r",
 		"Commands for dealing with a single relay",
 		func(cmd *cli.Cmd) {
@@ -33,26 +36,30 @@ func Init(app *cli.Cli) {
 				RelaySerial = *relaySerialStr
 			}
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"register",
 				"Register the relay",
 				register,
 			)
 		},
 	)
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"relays rs",
 		"Commands for dealing with all relays",
 		func(cmd *cli.Cmd) {
-			cmd.Before = util.BuildAPIAndVerifyLogin
+			cmd.Before = util.BuildAPIRequiringFeature("relays")
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"get",
 				"Get a list of all relays",
 				getAllRelays,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"find",
 				"Find relays by name",
 				findRelaysByName,
@@ -0,0 +1,33 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package release contains commands used by the build process when making a
+// release of the shell
+package release
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the release commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"release",
+		"Commands used by the build process to make releases",
+		func(cmd *cli.Cmd) {
+			util.Command(
+				cmd,
+				"package-metadata",
+				"Generate Homebrew, Debian, and RPM packaging files for this version",
+				packageMetadata,
+			)
+		},
+	)
+}
@@ -0,0 +1,380 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package release
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// artifact is a single release binary, as handed to the templates
+type artifact struct {
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
+	DebArch string `json:"deb_arch,omitempty"`
+	RPMArch string `json:"rpm_arch,omitempty"`
+}
+
+// packageData is the top level data handed to the templates
+type packageData struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Homepage    string     `json:"homepage"`
+	License     string     `json:"license"`
+	Maintainer  string     `json:"maintainer"`
+	Version     string     `json:"version"`
+	DebVersion  string     `json:"deb_version"`
+	RPMVersion  string     `json:"rpm_version"`
+	GitRev      string     `json:"git_rev"`
+	Tag         string     `json:"tag"`
+	Artifacts   []artifact `json:"artifacts"`
+
+	// The architectures the RPM spec builds for
+	RPMArches string `json:"-"`
+
+	// The binaries Homebrew installs
+	MacIntel   *artifact `json:"-"`
+	LinuxIntel *artifact `json:"-"`
+	LinuxARM   *artifact `json:"-"`
+}
+
+// The architecture names that Debian and RPM use for each GOARCH
+var (
+	debArches = map[string]string{"amd64": "amd64", "386": "i386", "arm": "armhf", "arm64": "arm64"}
+	rpmArches = map[string]string{"amd64": "x86_64", "386": "i686", "arm": "armv7hl", "arm64": "aarch64"}
+)
+
+const brewTemplate = `# Generated by conch release package-metadata for v{{ .Version }} ({{ .GitRev }})
+class Conch < Formula
+  desc "{{ .Description }}"
+  homepage "{{ .Homepage }}"
+  version "{{ .Version }}"
+  license "{{ .License }}"
+{{- with .MacIntel }}
+
+  on_macos do
+    url "{{ .URL }}"
+    sha256 "{{ .SHA256 }}"
+  end
+{{- end }}
+{{- if or .LinuxIntel .LinuxARM }}
+
+  on_linux do
+{{- with .LinuxARM }}
+    if Hardware::CPU.arm?
+      url "{{ .URL }}"
+      sha256 "{{ .SHA256 }}"
+    end
+{{- end }}
+{{- with .LinuxIntel }}
+    if Hardware::CPU.intel?
+      url "{{ .URL }}"
+      sha256 "{{ .SHA256 }}"
+    end
+{{- end }}
+  end
+{{- end }}
+
+  def install
+    bin.install Dir["conch-*"].first => "conch"
+  end
+
+  test do
+    assert_match version.to_s, shell_output("#{bin}/conch --version 2>&1")
+  end
+end
+`
+
+const debTemplate = `Package: {{ .Data.Name }}
+Version: {{ .Data.DebVersion }}
+Section: utils
+Priority: optional
+Architecture: {{ .Artifact.DebArch }}
+Maintainer: {{ .Data.Maintainer }}
+Homepage: {{ .Data.Homepage }}
+Description: {{ .Data.Description }}
+ Built from {{ .Data.Tag }} ({{ .Data.GitRev }}). Install {{ .Artifact.Name }}
+ as /usr/bin/conch.
+`
+
+const rpmTemplate = `# Generated by conch release package-metadata for v{{ .Version }} ({{ .GitRev }})
+Name:           {{ .Name }}
+Version:        {{ .RPMVersion }}
+Release:        1%{?dist}
+Summary:        {{ .Description }}
+License:        {{ .License }}
+URL:            {{ .Homepage }}
+ExclusiveArch:  {{ .RPMArches }}
+{{ range .Artifacts }}{{ if .RPMArch }}
+%ifarch {{ .RPMArch }}
+Source0:        {{ .URL }}
+# sha256: {{ .SHA256 }}
+%endif
+{{- end }}{{ end }}
+
+%description
+{{ .Description }}.
+
+%install
+install -D -m 0755 %{SOURCE0} %{buildroot}%{_bindir}/conch
+
+%files
+%{_bindir}/conch
+`
+
+// readChecksums finds the release binaries in dir by their .sha256 files,
+// as written by 'make release'
+func readChecksums(dir string) ([]artifact, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "conch-*-*.sha256"))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no conch-OS-ARCH.sha256 files in %s. Run 'make release' first", dir)
+	}
+	sort.Strings(matches)
+
+	tag := "v" + strings.TrimLeft(util.Version, "v")
+	artifacts := make([]artifact, 0, len(matches))
+	for _, m := range matches {
+		name := strings.TrimSuffix(filepath.Base(m), ".sha256")
+		bits := strings.Split(strings.TrimPrefix(name, "conch-"), "-")
+		if len(bits) != 2 {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(m)
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%s is empty", m)
+		}
+
+		artifacts = append(artifacts, artifact{
+			OS:   bits[0],
+			Arch: bits[1],
+			Name: name,
+			URL: fmt.Sprintf(
+				"https://github.com/%s/%s/releases/download/%s/%s",
+				util.GhOrg,
+				util.GhRepo,
+				tag,
+				name,
+			),
+			SHA256:  strings.ToLower(fields[0]),
+			DebArch: debArches[bits[1]],
+			RPMArch: rpmArches[bits[1]],
+		})
+	}
+	return artifacts, nil
+}
+
+// newPackageData gathers everything the templates need. Only Linux binaries
+// get Debian and RPM architectures.
+func newPackageData(artifacts []artifact, maintainer string) packageData {
+	version := strings.TrimLeft(util.Version, "v")
+
+	// Neither Debian nor RPM want dashes in the upstream version, and both
+	// sort ~ before anything else, as a prerelease should be
+	pkgVersion := strings.Replace(version, "-", "~", -1)
+
+	d := packageData{
+		Name:        "conch",
+		Description: "Command line interface for Conch",
+		Homepage:    fmt.Sprintf("https://github.com/%s/%s", util.GhOrg, util.GhRepo),
+		License:     "MPL-2.0",
+		Maintainer:  maintainer,
+		Version:     version,
+		DebVersion:  pkgVersion + "-1",
+		RPMVersion:  pkgVersion,
+		GitRev:      util.GitRev,
+		Tag:         "v" + version,
+		Artifacts:   artifacts,
+	}
+
+	rpmArches := make([]string, 0)
+	for i := range d.Artifacts {
+		a := &d.Artifacts[i]
+		if a.OS != "linux" {
+			a.DebArch = ""
+			a.RPMArch = ""
+		}
+		if a.RPMArch != "" {
+			rpmArches = append(rpmArches, a.RPMArch)
+		}
+		switch {
+		case a.OS == "darwin" && a.Arch == "amd64":
+			d.MacIntel = a
+		case a.OS == "linux" && a.Arch == "amd64":
+			d.LinuxIntel = a
+		case a.OS == "linux" && (a.Arch == "arm64" || (a.Arch == "arm" && d.LinuxARM == nil)):
+			d.LinuxARM = a
+		}
+	}
+	d.RPMArches = strings.Join(rpmArches, " ")
+	return d
+}
+
+func render(text string, data interface{}) ([]byte, error) {
+	t, err := template.New("metadata").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// packagingFiles renders the files for a format, keyed by file name
+func packagingFiles(format string, d packageData) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	switch format {
+	case "brew":
+		out, err := render(brewTemplate, d)
+		if err != nil {
+			return nil, err
+		}
+		files["conch.rb"] = out
+
+	case "deb":
+		for _, a := range d.Artifacts {
+			if a.DebArch == "" {
+				continue
+			}
+			out, err := render(debTemplate, struct {
+				Data     packageData
+				Artifact artifact
+			}{d, a})
+			if err != nil {
+				return nil, err
+			}
+			files[fmt.Sprintf("conch_%s.control", a.DebArch)] = out
+		}
+
+	case "rpm":
+		out, err := render(rpmTemplate, d)
+		if err != nil {
+			return nil, err
+		}
+		files["conch.spec"] = out
+
+	default:
+		return nil, fmt.Errorf("unknown format '%s'. Choose from: brew, deb, rpm, all", format)
+	}
+	return files, nil
+}
+
+func packageMetadata(cmd *cli.Cmd) {
+	var (
+		dirOpt        = cmd.StringOpt("release-dir", "release", "The directory 'make release' wrote the binaries and their .sha256 files to")
+		formatOpt     = cmd.StringOpt("format", "all", "What to generate: brew, deb, rpm, or all")
+		outOpt        = cmd.StringOpt("out o", "", "Write the files to this directory, rather than to STDOUT")
+		maintainerOpt = cmd.String(cli.StringOpt{
+			Name:   "maintainer",
+			Value:  "",
+			Desc:   "The package maintainer, as 'Name <email>', for Debian packages",
+			EnvVar: "CONCH_PACKAGE_MAINTAINER",
+		})
+	)
+
+	cmd.LongDesc = `
+Generates a Homebrew formula, Debian control files, and an RPM spec from this
+binary's version and the checksums of the release binaries. Run it with the
+freshly built shell so that the version is the one being released:
+
+    make release && bin/conch release package-metadata -o release/packaging
+
+With --json, the version and binaries are written as JSON instead, for
+packagers who keep their own templates.`
+
+	cmd.Action = func() {
+		if util.Version == "" {
+			util.Bail(errors.New("this binary has no version. Build it with make"))
+		}
+
+		artifacts, err := readChecksums(*dirOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+		data := newPackageData(artifacts, *maintainerOpt)
+
+		if util.JSON {
+			util.JSONOutIndent(data)
+			return
+		}
+
+		formats := []string{*formatOpt}
+		if *formatOpt == "all" {
+			formats = []string{"brew", "deb", "rpm"}
+		}
+
+		for _, format := range formats {
+			if format == "deb" && *maintainerOpt == "" {
+				util.Bail(errors.New("Debian packages need a maintainer. Use --maintainer or CONCH_PACKAGE_MAINTAINER"))
+			}
+		}
+
+		files := make(map[string][]byte)
+		for _, format := range formats {
+			f, err := packagingFiles(format, data)
+			if err != nil {
+				util.Bail(err)
+			}
+			for name, out := range f {
+				files[name] = out
+			}
+		}
+
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		if *outOpt == "" {
+			for i, name := range names {
+				if len(names) > 1 {
+					if i > 0 {
+						fmt.Println()
+					}
+					fmt.Printf("==> %s <==\n", name)
+				}
+				fmt.Print(string(files[name]))
+			}
+			return
+		}
+
+		if err := os.MkdirAll(*outOpt, 0755); err != nil {
+			util.Bail(err)
+		}
+		for _, name := range names {
+			path := filepath.Join(*outOpt, name)
+			if err := ioutil.WriteFile(path, files[name], 0644); err != nil {
+				util.Bail(err)
+			}
+			fmt.Println(path)
+		}
+	}
+}
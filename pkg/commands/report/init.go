@@ -0,0 +1,63 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package report contains commands for saving conch command lines as named
+// reports and running them again
+package report
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"report",
+		"Save routine queries as named reports in the active profile and run them again",
+		func(cmd *cli.Cmd) {
+			cmd.Before = requireProfile
+
+			util.Command(
+				cmd,
+				"save",
+				"Save a conch command line as a named report",
+				save,
+			)
+
+			util.Command(
+				cmd,
+				"run",
+				"Run a saved report",
+				run,
+			)
+
+			util.Command(
+				cmd,
+				"list ls",
+				"List the saved reports",
+				list,
+			)
+
+			util.Command(
+				cmd,
+				"show",
+				"Show the command line of a saved report",
+				show,
+			)
+
+			util.Command(
+				cmd,
+				"delete rm",
+				"Delete a saved report",
+				remove,
+			)
+		},
+	)
+}
@@ -0,0 +1,209 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package report
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func requireProfile() {
+	if util.ActiveProfile == nil {
+		util.Bail(errors.New("there is no active profile. Reports are saved in the profile, so please use 'profile set active' to mark a profile as active"))
+	}
+}
+
+func lookup(name string) *config.SavedReport {
+	r, ok := util.ActiveProfile.Reports[name]
+	if !ok {
+		util.Bail(fmt.Errorf("there is no report named '%s' in profile %s", name, util.ActiveProfile.Name))
+	}
+	return r
+}
+
+// commandLine renders args the way they would be typed into a shell
+func commandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\n'\"\\$`|&;<>()*?!#~") {
+			a = "'" + strings.Replace(a, "'", `'\''`, -1) + "'"
+		}
+		quoted[i] = a
+	}
+	return "conch " + strings.Join(quoted, " ")
+}
+
+func save(cmd *cli.Cmd) {
+	var (
+		nameArg  = cmd.StringArg("NAME", "", "The name of the report")
+		argsArg  = cmd.StringsArg("ARGS", nil, "The conch command line to save, without the leading 'conch'")
+		descOpt  = cmd.StringOpt("description d", "", "What the report is for")
+		forceOpt = cmd.BoolOpt("force", false, "Replace an existing report with the same name")
+	)
+	cmd.Spec = "[OPTIONS] NAME [OPTIONS] -- ARGS..."
+
+	cmd.LongDesc = `
+Saves a conch command line under a name in the active profile. Global options
+like --json, --filter, --summary, and --count-only can be saved along with the
+command, as long as they come first:
+
+    conch report save failing-disks -d "weekly failing disks by rack" -- \
+        --filter 'Health = fail' --summary workspace devices --full
+
+Run it again with 'conch report run failing-disks'. Reports are stored in the
+config file with the rest of the profile.`
+
+	cmd.Action = func() {
+		name := *nameArg
+		if strings.ContainsAny(name, " \t/") {
+			util.Bail(errors.New("report names may not contain whitespace or slashes"))
+		}
+
+		args := *argsArg
+		for _, a := range args {
+			if strings.HasPrefix(a, "-") {
+				continue
+			}
+			if a == "report" {
+				util.Bail(errors.New("a report can't run another report"))
+			}
+			break
+		}
+
+		if _, ok := util.ActiveProfile.Reports[name]; ok && !*forceOpt {
+			util.Bail(fmt.Errorf("a report named '%s' already exists. Use --force to replace it", name))
+		}
+
+		if util.ActiveProfile.Reports == nil {
+			util.ActiveProfile.Reports = make(map[string]*config.SavedReport)
+		}
+		util.ActiveProfile.Reports[name] = &config.SavedReport{
+			Args:        args,
+			Description: *descOpt,
+			Created:     time.Now().UTC(),
+		}
+
+		util.WriteConfig()
+		if !util.JSON {
+			fmt.Printf("Saved report '%s': %s\n", name, commandLine(args))
+		}
+	}
+}
+
+func run(cmd *cli.Cmd) {
+	var (
+		nameArg = cmd.StringArg("NAME", "", "The name of the report")
+		argsArg = cmd.StringsArg("ARGS", nil, "Extra arguments appended to the saved command line")
+	)
+	cmd.Spec = "NAME [-- ARGS...]"
+
+	cmd.LongDesc = `
+Runs a saved report with the active profile. --json, --count-only, and
+--summary given to 'report run' are passed along to the report. Any ARGS are
+appended to the saved command line, eg to add --health fail to a device
+listing.`
+
+	cmd.Action = func() {
+		r := lookup(*nameArg)
+
+		args := []string{
+			"--config", util.Config.Path,
+			"--profile", util.ActiveProfile.Name,
+		}
+		if util.JSON {
+			args = append(args, "--json")
+		}
+		if util.CountOnly {
+			args = append(args, "--count-only")
+		}
+		if util.Summary {
+			args = append(args, "--summary")
+		}
+		args = append(args, r.Args...)
+		args = append(args, *argsArg...)
+
+		bin, err := os.Executable()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		c := exec.Command(bin, args...)
+		c.Stdin = os.Stdin
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+
+		if err := c.Run(); err != nil {
+			if exit, ok := err.(*exec.ExitError); ok {
+				util.Exit(exit.ExitCode())
+			}
+			util.Bail(err)
+		}
+	}
+}
+
+func list(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		reports := util.ActiveProfile.Reports
+		if reports == nil {
+			reports = make(map[string]*config.SavedReport)
+		}
+
+		if util.JSON {
+			util.JSONOut(reports)
+			return
+		}
+
+		names := make([]string, 0, len(reports))
+		for name := range reports {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		header := []string{"Name", "Description", "Command"}
+		row := func(i int) []string {
+			r := reports[names[i]]
+			return []string{names[i], r.Description, commandLine(r.Args)}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(names), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func show(cmd *cli.Cmd) {
+	var nameArg = cmd.StringArg("NAME", "", "The name of the report")
+	cmd.Spec = "NAME"
+
+	cmd.Action = func() {
+		r := lookup(*nameArg)
+		if util.JSON {
+			util.JSONOut(r)
+			return
+		}
+		fmt.Println(commandLine(r.Args))
+	}
+}
+
+func remove(cmd *cli.Cmd) {
+	var nameArg = cmd.StringArg("NAME", "", "The name of the report")
+	cmd.Spec = "NAME"
+
+	cmd.Action = func() {
+		lookup(*nameArg)
+		delete(util.ActiveProfile.Reports, *nameArg)
+		util.WriteConfig()
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package rma contains commands for recording failed components as they are
+// pulled from devices, and for looking at how often each model fails
+package rma
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the RMA commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"rma",
+		"Commands for tracking failed components and their replacements",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+
+			util.Command(
+				cmd,
+				"record",
+				"Record that a component was pulled from a device",
+				record,
+			)
+
+			util.Command(
+				cmd,
+				"list ls",
+				"List the components pulled from a device or from the devices of a workspace",
+				list,
+			)
+
+			util.Command(
+				cmd,
+				"report",
+				"Show how often each component model fails, over time",
+				report,
+			)
+		},
+	)
+}
@@ -0,0 +1,169 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rma
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// failureRate is how often one component model failed in one period
+type failureRate struct {
+	Period    string  `json:"period"`
+	Kind      string  `json:"kind"`
+	Model     string  `json:"model"`
+	Failures  int     `json:"failures"`
+	Installed int     `json:"installed"`
+	Rate      float64 `json:"rate_percent"`
+}
+
+// periodOf names the period a time falls in
+func periodOf(t time.Time, period string) string {
+	t = t.UTC()
+	switch period {
+	case "month":
+		return t.Format("2006-01")
+	case "quarter":
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+	case "year":
+		return strconv.Itoa(t.Year())
+	}
+	return "all"
+}
+
+func report(cmd *cli.Cmd) {
+	var (
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+		periodOpt    = cmd.StringOpt("period p", "quarter", "Group failures by 'month', 'quarter', 'year', or 'all' for no grouping")
+		sinceOpt     = cmd.StringOpt("since", "", "Only count components pulled since this time. Accepts RFC3339, a date, or a duration, eg '8760h'")
+		kindOpt      = cmd.StringOpt("kind k", "", "Only report on components of this kind")
+		sorting      = util.SortFlags(cmd, "rma_report")
+	)
+
+	cmd.LongDesc = `
+Counts the components recorded with 'conch rma record' in the workspace's
+devices by model and period, and compares each count to the number of
+components of that model currently installed in the workspace. The rate is
+the percentage of the installed population that failed in the period.
+
+This requires fetching the settings and the full details of every device in
+the workspace individually.`
+
+	cmd.Action = func() {
+		period := strings.ToLower(*periodOpt)
+		switch period {
+		case "month", "quarter", "year", "all":
+		default:
+			util.Bail(fmt.Errorf("unknown period '%s'. Use month, quarter, year, or all", *periodOpt))
+		}
+
+		since, err := parseTime(*sinceOpt, true)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		devices, records, err := workspaceRecords(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		devices, err = util.FillDeviceDetails(devices)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		type modelKey struct{ kind, model string }
+
+		installed := make(map[modelKey]int)
+		for _, d := range devices {
+			for _, c := range d.Components() {
+				installed[modelKey{c.Kind, c.Model}]++
+			}
+		}
+
+		type rateKey struct {
+			period string
+			modelKey
+		}
+		counts := make(map[rateKey]int)
+		for _, r := range records {
+			if !since.IsZero() && r.Time.Before(since) {
+				continue
+			}
+			if *kindOpt != "" && !strings.EqualFold(r.Kind, *kindOpt) {
+				continue
+			}
+			counts[rateKey{periodOf(r.Time, period), modelKey{r.Kind, r.Model}}]++
+		}
+
+		rates := make([]failureRate, 0, len(counts))
+		for k, n := range counts {
+			f := failureRate{
+				Period:    k.period,
+				Kind:      k.kind,
+				Model:     k.model,
+				Failures:  n,
+				Installed: installed[k.modelKey],
+			}
+			if f.Installed > 0 {
+				f.Rate = 100 * float64(n) / float64(f.Installed)
+			}
+			rates = append(rates, f)
+		}
+
+		// Oldest period first, then the models that fail the most
+		sort.Slice(rates, func(i, j int) bool {
+			a, b := rates[i], rates[j]
+			if a.Period != b.Period {
+				return a.Period < b.Period
+			}
+			if a.Failures != b.Failures {
+				return a.Failures > b.Failures
+			}
+			if a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			return a.Model < b.Model
+		})
+
+		header := []string{"Period", "Kind", "Model", "Failures", "Installed", "Rate"}
+		row := func(i int) []string {
+			f := rates[i]
+			rate := "-"
+			if f.Installed > 0 {
+				rate = strconv.FormatFloat(f.Rate, 'f', 2, 64) + "%"
+			}
+			return []string{
+				f.Period,
+				f.Kind,
+				f.Model,
+				strconv.Itoa(f.Failures),
+				strconv.Itoa(f.Installed),
+				rate,
+			}
+		}
+
+		if err := sorting.Sort(rates, header, row); err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(rates)
+			return
+		}
+
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(rates), row, "Period", "Kind"); err != nil {
+			util.Bail(err)
+		}
+	}
+}
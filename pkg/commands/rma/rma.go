@@ -0,0 +1,272 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rma
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// parseTime accepts an RFC3339 time or a plain date. When durations are
+// allowed, a duration like '720h' means that long ago.
+func parseTime(s string, durations bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	if durations {
+		if d, err := time.ParseDuration(s); err == nil {
+			return time.Now().Add(-d), nil
+		}
+		return time.Time{}, fmt.Errorf("'%s' is not an RFC3339 time, a date, or a duration", s)
+	}
+	return time.Time{}, fmt.Errorf("'%s' is neither an RFC3339 time nor a date", s)
+}
+
+// workspaceRecords fetches the RMA records of every device in a workspace,
+// oldest first. The workspace's devices are returned as well.
+func workspaceRecords(workspace string) (conch.Devices, conch.RMARecords, error) {
+	workspaceID, err := util.MagicWorkspaceOrActiveID(workspace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	devices, err := util.API.GetWorkspaceDevices(workspaceID, false, "", "", "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var mu sync.Mutex
+	records := make(conch.RMARecords, 0)
+	err = util.EachDevice(devices, func(i int, d conch.Device) error {
+		r, err := util.API.GetDeviceRMAs(d.ID)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, r...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sortRecords(records)
+	return devices, records, nil
+}
+
+func sortRecords(records conch.RMARecords) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		return a.DeviceID < b.DeviceID
+	})
+}
+
+func record(cmd *cli.Cmd) {
+	var (
+		deviceArg      = cmd.StringArg("DEVICE", "", "The serial, hostname, asset tag, or alias of the device the component was pulled from")
+		serialOpt      = cmd.StringOpt("serial s", "", "Serial number of the component that was pulled, or the MAC address of a NIC")
+		replacementOpt = cmd.StringOpt("replacement r", "", "Serial number of the component that went in its place")
+		reasonOpt      = cmd.StringOpt("reason", "", "Why the component was pulled")
+		ticketOpt      = cmd.StringOpt("ticket t", "", "The ticket or RMA number from the vendor")
+		kindOpt        = cmd.StringOpt("kind k", "", "Kind of component: disk, dimm, psu, nic, or anything else. Only needed if the device no longer lists the component")
+		modelOpt       = cmd.StringOpt("model m", "", "Model of the component. Only needed if the device no longer lists the component")
+		slotOpt        = cmd.StringOpt("slot", "", "Slot the component was in")
+		timeOpt        = cmd.StringOpt("time", "", "When the component was pulled, as an RFC3339 time or a date. Defaults to now, and is mostly useful for recording past replacements")
+	)
+	cmd.Spec = "[OPTIONS] DEVICE"
+
+	cmd.LongDesc = `
+Records that a component was pulled from a device, and optionally what it was
+replaced with, so that failures can be tracked by model with 'conch rma
+report'. Records are kept in the device's rma.* settings.
+
+The kind, model, vendor, and slot of the component are looked up by its serial
+among the device's components, as shown by 'conch device ID components'. Since
+that data comes from the latest device report, record the replacement before
+the device reports again, or give --kind and --model.`
+
+	cmd.Action = func() {
+		serial := strings.TrimSpace(*serialOpt)
+		if serial == "" {
+			util.Bail(errors.New("--serial is required"))
+		}
+		if strings.TrimSpace(*reasonOpt) == "" {
+			util.Bail(errors.New("--reason is required"))
+		}
+
+		when, err := parseTime(*timeOpt, false)
+		if err != nil {
+			util.Bail(err)
+		}
+		if when.IsZero() {
+			when = time.Now()
+		}
+
+		deviceID, err := util.MagicDeviceID(*deviceArg)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		d, err := util.API.GetDevice(deviceID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		r := conch.RMARecord{
+			DeviceID:          d.ID,
+			RemovedSerial:     serial,
+			ReplacementSerial: strings.TrimSpace(*replacementOpt),
+			Reason:            strings.TrimSpace(*reasonOpt),
+			Ticket:            strings.TrimSpace(*ticketOpt),
+			Time:              when.UTC(),
+		}
+		if util.ActiveProfile != nil {
+			r.User = util.ActiveProfile.User
+		}
+
+		for _, c := range d.Components() {
+			if strings.EqualFold(c.Serial, serial) {
+				r.Kind = c.Kind
+				r.Slot = c.Slot
+				r.Vendor = c.Vendor
+				r.Model = c.Model
+				break
+			}
+		}
+
+		if *kindOpt != "" {
+			r.Kind = strings.ToLower(strings.TrimSpace(*kindOpt))
+		}
+		if *modelOpt != "" {
+			r.Model = strings.TrimSpace(*modelOpt)
+		}
+		if *slotOpt != "" {
+			r.Slot = strings.TrimSpace(*slotOpt)
+		}
+
+		if r.Kind == "" || r.Model == "" {
+			util.Bail(fmt.Errorf(
+				"device %s has no component with serial '%s' that has a kind and model. Give --kind and --model",
+				d.ID,
+				serial,
+			))
+		}
+
+		if err := util.API.RecordRMA(r); err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(r)
+			return
+		}
+
+		fmt.Printf("Recorded %s %s (%s) pulled from %s", r.Kind, r.RemovedSerial, r.Model, r.DeviceID)
+		if r.ReplacementSerial != "" {
+			fmt.Printf(", replaced by %s", r.ReplacementSerial)
+		}
+		fmt.Println()
+	}
+}
+
+func list(cmd *cli.Cmd) {
+	var (
+		deviceOpt    = cmd.StringOpt("device d", "", "Only list the components pulled from this device")
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace whose devices are listed. Defaults to the workspace in the active profile")
+		sinceOpt     = cmd.StringOpt("since", "", "Only list components pulled since this time. Accepts RFC3339, a date, or a duration, eg '720h'")
+		kindOpt      = cmd.StringOpt("kind k", "", "Only list components of this kind")
+		sorting      = util.SortFlags(cmd, "rma")
+	)
+
+	cmd.LongDesc = `
+Lists the components recorded with 'conch rma record', oldest first. Without
+--device, every device in the workspace is checked, which requires fetching
+the settings of each device individually.`
+
+	cmd.Action = func() {
+		since, err := parseTime(*sinceOpt, true)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		var records conch.RMARecords
+		if *deviceOpt != "" {
+			var deviceID string
+			deviceID, err = util.MagicDeviceID(*deviceOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+			records, err = util.API.GetDeviceRMAs(deviceID)
+		} else {
+			_, records, err = workspaceRecords(*workspaceOpt)
+		}
+		if err != nil {
+			util.Bail(err)
+		}
+
+		filtered := make(conch.RMARecords, 0)
+		for _, r := range records {
+			if !since.IsZero() && r.Time.Before(since) {
+				continue
+			}
+			if *kindOpt != "" && !strings.EqualFold(r.Kind, *kindOpt) {
+				continue
+			}
+			filtered = append(filtered, r)
+		}
+		records = filtered
+
+		header := []string{"Time", "Device", "Kind", "Slot", "Model", "Removed", "Replacement", "Reason", "Ticket", "User"}
+		row := func(i int) []string {
+			r := records[i]
+			return []string{
+				util.TimeStr(r.Time),
+				r.DeviceID,
+				r.Kind,
+				r.Slot,
+				r.Model,
+				r.RemovedSerial,
+				r.ReplacementSerial,
+				r.Reason,
+				r.Ticket,
+				r.User,
+			}
+		}
+
+		if err := sorting.Sort(records, header, row); err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(records)
+			return
+		}
+
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(records), row, "Kind", "Model"); err != nil {
+			util.Bail(err)
+		}
+	}
+}
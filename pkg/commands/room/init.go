@@ -0,0 +1,130 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package room contains commands for managing datacenter rooms, each of which
+// is an availability zone
+package room
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// RoomUUID is the UUID of the room provided by the user
+var RoomUUID uuid.UUID
+
+// Init loads up the commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"rooms",
+		"Operate on all datacenter rooms",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+
+			util.Command(
+				cmd,
+				"get",
+				"Get all rooms",
+				getAll,
+			)
+
+			util.Command(
+				cmd,
+				"create",
+				"Create a room",
+				create,
+			)
+		},
+	)
+
+	util.Command(
+		app.Cmd,
+		"room",
+		"Operate on individual datacenter rooms",
+		func(cmd *cli.Cmd) {
+			var roomIDStr = cmd.StringArg("ID", "", "The UUID or alias of the room")
+
+			cmd.Spec = "ID"
+			cmd.Before = func() {
+				util.BuildAPIAndVerifyLogin()
+				id, err := util.MagicRoomID(*roomIDStr)
+				if err != nil {
+					util.Bail(err)
+				}
+				RoomUUID = id
+			}
+
+			util.Command(
+				cmd,
+				"get",
+				"Get a room",
+				get,
+			)
+
+			util.Command(
+				cmd,
+				"update",
+				"Update a room",
+				update,
+			)
+
+			util.Command(
+				cmd,
+				"delete rm",
+				"Delete a room",
+				remove,
+			)
+
+			util.Command(
+				cmd,
+				"racks",
+				"Get all racks assigned to the room",
+				getRacks,
+			)
+
+			util.Command(
+				cmd,
+				"limits",
+				"Get/set the most a single rack in the room may weigh and draw",
+				func(cmd *cli.Cmd) {
+					getLimits(cmd)
+
+					util.Command(
+						cmd,
+						"set",
+						"Set the weight and power limits for the room's racks",
+						setLimits,
+					)
+
+					util.Command(
+						cmd,
+						"clear",
+						"Remove the room's limits",
+						clearLimits,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
+				"phase",
+				"Change the phase of the room's racks",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"set",
+						"Set the phase of every rack in the room",
+						setPhase,
+					)
+				},
+			)
+		},
+	)
+}
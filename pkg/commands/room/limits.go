@@ -0,0 +1,88 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package room
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func displayLimits(l config.RoomLimits) {
+	if util.JSON {
+		util.JSONOut(l)
+		return
+	}
+
+	limit := func(f float64, unit string) string {
+		if f == 0 {
+			return "none"
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64) + " " + unit
+	}
+	fmt.Printf("Max rack weight: %s\n", limit(l.MaxRackWeight, "kg"))
+	fmt.Printf("Max rack power:  %s\n", limit(l.MaxRackPower, "W"))
+}
+
+func getLimits(app *cli.Cmd) {
+	app.Action = func() {
+		displayLimits(util.RoomLimits(RoomUUID))
+	}
+}
+
+// parseLimit reads a limit given on the command line. "" leaves the limit as
+// it was, and 0 removes it.
+func parseLimit(name string, s string, was float64) float64 {
+	if s == "" {
+		return was
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		util.Bail(fmt.Errorf("--%s must be a number, of zero or more", name))
+	}
+	return f
+}
+
+func setLimits(app *cli.Cmd) {
+	var (
+		weightOpt = app.StringOpt("max-weight", "", "The most a single rack in the room may weigh, in kilograms. 0 removes the limit")
+		powerOpt  = app.StringOpt("max-power", "", "The most power a single rack in the room may draw, in watts. 0 removes the limit")
+	)
+
+	app.LongDesc = `
+Sets the most that a single rack in the room may weigh and draw, for 'conch
+rack ID budget' to compare against. Importing a layout that goes over them
+gives a warning, and 'conch rack-role audit' reports the racks that do.
+
+The API has nowhere to keep these, so they are kept in the active profile.`
+
+	app.Action = func() {
+		if *weightOpt == "" && *powerOpt == "" {
+			util.Bail(errors.New("--max-weight, --max-power, or both are needed"))
+		}
+
+		l := util.RoomLimits(RoomUUID)
+		l.MaxRackWeight = parseLimit("max-weight", *weightOpt, l.MaxRackWeight)
+		l.MaxRackPower = parseLimit("max-power", *powerOpt, l.MaxRackPower)
+
+		util.SetRoomLimits(RoomUUID, l)
+		util.WriteConfig()
+
+		displayLimits(l)
+	}
+}
+
+func clearLimits(app *cli.Cmd) {
+	app.Action = func() {
+		util.SetRoomLimits(RoomUUID, config.RoomLimits{})
+		util.WriteConfig()
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package room
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("rooms get", []conch.Room{})
+	util.RegisterOutput("rooms create", conch.Room{})
+	util.RegisterOutput("room get", conch.Room{})
+	util.RegisterOutput("room update", conch.Room{})
+	util.RegisterOutput("room racks", []conch.Rack{})
+	util.RegisterOutput("room limits", config.RoomLimits{})
+	util.RegisterOutput("room limits set", config.RoomLimits{})
+}
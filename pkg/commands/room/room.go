@@ -0,0 +1,313 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package room
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func displayRoom(r conch.Room) {
+	if util.JSON {
+		util.JSONOut(r)
+		return
+	}
+
+	fmt.Printf(`
+ID: %s
+Datacenter ID: %s
+AZ: %s
+Alias: %s
+Vendor Name: %s
+
+Created: %s
+Updated: %s
+
+`,
+		r.ID.String(),
+		r.DatacenterID.String(),
+		r.AZ,
+		r.Alias,
+		r.VendorName,
+		util.TimeStr(r.Created),
+		util.TimeStr(r.Updated),
+	)
+}
+
+// existingDatacenter resolves a datacenter UUID or partial UUID and makes sure
+// the API actually knows about it, rather than letting the room be rejected
+// with a less helpful error
+func existingDatacenter(wat string) (uuid.UUID, error) {
+	id, err := util.MagicDatacenterID(wat)
+	if err != nil {
+		return id, err
+	}
+
+	if _, err := util.API.GetDatacenter(id); err != nil {
+		if err == conch.ErrDataNotFound {
+			return id, fmt.Errorf("datacenter %s does not exist", wat)
+		}
+		return id, err
+	}
+	return id, nil
+}
+
+// checkAlias makes sure no room other than the given one already uses the
+// alias. Aliases are how rooms are usually referred to, so they need to be
+// unique.
+func checkAlias(alias string, self uuid.UUID) error {
+	rooms, err := util.API.GetRooms()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rooms {
+		if uuid.Equal(r.ID, self) {
+			continue
+		}
+		if strings.EqualFold(r.Alias, alias) {
+			return fmt.Errorf("room %s already uses the alias '%s'", r.ID, r.Alias)
+		}
+	}
+	return nil
+}
+
+func getAll(app *cli.Cmd) {
+	app.Action = func() {
+		rs, err := util.API.GetRooms()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(rs)
+			return
+		}
+
+		header := []string{
+			"ID",
+			"Datacenter ID",
+			"AZ",
+			"Alias",
+			"Vendor Name",
+		}
+		row := func(i int) []string {
+			r := rs[i]
+			return []string{
+				r.ID.String(),
+				r.DatacenterID.String(),
+				r.AZ,
+				r.Alias,
+				r.VendorName,
+			}
+		}
+
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(rs), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func get(app *cli.Cmd) {
+	app.Action = func() {
+		r, err := util.API.GetRoom(RoomUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+		displayRoom(r)
+	}
+}
+
+func create(app *cli.Cmd) {
+	var (
+		dcOpt         = app.StringOpt("datacenter dc", "", "UUID or partial UUID of the datacenter the room is in")
+		azOpt         = app.StringOpt("az", "", "Name of the availability zone")
+		aliasOpt      = app.StringOpt("alias", "", "Short, unique name for the room")
+		vendorNameOpt = app.StringOpt("vendor-name vn", "", "The datacenter vendor's name for the room")
+	)
+	app.Spec = "--datacenter --az --alias [OPTIONS]"
+
+	app.Action = func() {
+		dcID, err := existingDatacenter(*dcOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if err := checkAlias(*aliasOpt, uuid.UUID{}); err != nil {
+			util.Bail(err)
+		}
+
+		r := conch.Room{
+			DatacenterID: dcID,
+			AZ:           *azOpt,
+			Alias:        *aliasOpt,
+			VendorName:   *vendorNameOpt,
+		}
+
+		if err := util.API.SaveRoom(&r); err != nil {
+			util.Bail(err)
+		}
+
+		displayRoom(r)
+	}
+}
+
+func update(app *cli.Cmd) {
+	var (
+		dcOpt         = app.StringOpt("datacenter dc", "", "Move the room to this datacenter")
+		azOpt         = app.StringOpt("az", "", "Name of the availability zone")
+		aliasOpt      = app.StringOpt("alias", "", "Short, unique name for the room")
+		vendorNameOpt = app.StringOpt("vendor-name vn", "", "The datacenter vendor's name for the room")
+	)
+
+	app.Action = func() {
+		r, err := util.API.GetRoom(RoomUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+		before := r
+
+		if *dcOpt != "" {
+			dcID, err := existingDatacenter(*dcOpt)
+			if err != nil {
+				util.Bail(err)
+			}
+			r.DatacenterID = dcID
+		}
+
+		if *azOpt != "" {
+			r.AZ = *azOpt
+		}
+
+		if *aliasOpt != "" && *aliasOpt != r.Alias {
+			if err := checkAlias(*aliasOpt, r.ID); err != nil {
+				util.Bail(err)
+			}
+			r.Alias = *aliasOpt
+		}
+
+		if *vendorNameOpt != "" {
+			r.VendorName = *vendorNameOpt
+		}
+
+		if err := util.API.SaveRoom(&r); err != nil {
+			util.Bail(err)
+		}
+
+		after, err := util.API.GetRoom(r.ID)
+		if err != nil {
+			util.Bail(err)
+		}
+		util.DisplayFieldDiff("room "+after.Alias, util.FieldDiff(before, after))
+	}
+}
+
+func remove(app *cli.Cmd) {
+	var forceOpt = app.BoolOpt("force", false, "Delete the room even if racks are still assigned to it")
+
+	app.Action = func() {
+		r, err := util.API.GetRoom(RoomUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if !*forceOpt {
+			racks, err := util.API.GetRoomRacks(r)
+			if err != nil {
+				util.Bail(err)
+			}
+			if len(racks) > 0 {
+				util.Bail(fmt.Errorf(
+					"room %s still has %d racks assigned to it. Use --force to delete it anyway",
+					r.Alias,
+					len(racks),
+				))
+			}
+		}
+
+		if err := util.API.DeleteRoom(RoomUUID); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func getRacks(app *cli.Cmd) {
+	app.Action = func() {
+		r, err := util.API.GetRoom(RoomUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		rs, err := util.API.GetRoomRacks(r)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(rs)
+			return
+		}
+
+		roles := make(map[uuid.UUID]string)
+		for _, rack := range rs {
+			if _, ok := roles[rack.RoleID]; ok {
+				continue
+			}
+			role, err := util.API.GetRackRole(rack.RoleID)
+			if err != nil {
+				util.Bail(err)
+			}
+			roles[rack.RoleID] = fmt.Sprintf("%s (%s)", role.Name, rack.RoleID.String())
+		}
+
+		header := []string{"ID", "Name", "Role"}
+		row := func(i int) []string {
+			return []string{
+				rs[i].ID.String(),
+				rs[i].Name,
+				roles[rs[i].RoleID],
+			}
+		}
+
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(rs), row, "Role"); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func setPhase(cmd *cli.Cmd) {
+	opts := util.NewRackPhaseOpts(cmd)
+
+	cmd.LongDesc = `
+Moves every rack in the room to a new phase at once, for bring-up milestones
+that apply to a whole hall. The racks that will change are listed, and the
+change is only made once confirmed. Racks already in the phase are skipped,
+unless --devices-also is given.`
+
+	cmd.Action = func() {
+		r, err := util.API.GetRoom(RoomUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		rs, err := util.API.GetRoomRacks(r)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		plan := util.NewPlan()
+		if err := util.PlanRackPhases(plan, rs, opts); err != nil {
+			util.Bail(err)
+		}
+		plan.Run(opts.PlanOpts)
+	}
+}
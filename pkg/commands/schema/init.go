@@ -0,0 +1,24 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package schema contains the command that describes the --json output of
+// the other commands
+package schema
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the commands
+func Init(app *cli.Cli) {
+	util.Command(
+		app.Cmd,
+		"schema",
+		"Print a JSON Schema for the --json output of a command",
+		schema,
+	)
+}
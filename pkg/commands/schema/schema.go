@@ -0,0 +1,83 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func schema(cmd *cli.Cmd) {
+	var (
+		commandArg = cmd.StringsArg("COMMAND", nil, "The command, without the leading 'conch'. IDs and other arguments may be left in")
+		listOpt    = cmd.BoolOpt("list l", false, "List the commands that have a schema")
+		allOpt     = cmd.BoolOpt("all a", false, "Print the schemas of every command, keyed by command")
+	)
+	cmd.Spec = "[--list | --all | COMMAND...]"
+
+	cmd.LongDesc = `
+Prints a JSON Schema (draft 2020-12) describing what a command prints with
+--json. The schema is generated from the same Go structures the output is
+encoded from, so it can't drift from what the shell really does:
+
+    conch schema device get
+    conch schema d 8a7f3b2c get
+
+Only top level commands may be given by an alias; below that, use the names
+'conch schema --list' shows.
+
+Keep the output of 'conch schema --all' next to a parser and diff it when the
+shell is upgraded, to catch changes to the output before they break anything.
+
+Options that change the shape of the output, like --ids or --count-only, and
+--filter, aren't taken into account; the schema is for the command's default
+--json output. With no COMMAND, the commands that have a schema are listed.`
+
+	cmd.Action = func() {
+		if *allOpt {
+			all := make(map[string]interface{})
+			for _, path := range util.OutputCommands() {
+				s, err := util.OutputSchema(path)
+				if err != nil {
+					util.Bail(err)
+				}
+				all[path] = s
+			}
+			util.JSONOutIndent(all)
+			return
+		}
+
+		if *listOpt || len(*commandArg) == 0 {
+			paths := util.OutputCommands()
+			if util.JSON {
+				util.JSONOut(paths)
+				return
+			}
+			for _, path := range paths {
+				fmt.Println(path)
+			}
+			return
+		}
+
+		path, ok := util.FindOutputCommand(*commandArg)
+		if !ok {
+			util.Bail(fmt.Errorf(
+				"no schema is known for 'conch %s'. 'conch schema --list' shows the commands that have one",
+				strings.Join(*commandArg, " "),
+			))
+		}
+
+		s, err := util.OutputSchema(path)
+		if err != nil {
+			util.Bail(err)
+		}
+		util.JSONOutIndent(s)
+	}
+}
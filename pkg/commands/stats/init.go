@@ -0,0 +1,63 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package stats contains commands for the local, opt-in record of which
+// commands are used and how long they take
+package stats
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the stats commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"stats",
+		"Show which commands are used, and how long they take. Nothing is ever sent anywhere",
+		func(cmd *cli.Cmd) {
+			cmd.LongDesc = `
+Usage statistics are kept in a local file, and only once 'conch stats enable'
+has been run. For each command, the file holds the number of runs and
+failures, and the runtimes. Arguments and option values are never recorded.
+
+With no subcommand, the statistics are shown.`
+
+			show(cmd)
+
+			util.Command(
+				cmd,
+				"show",
+				"Show the usage statistics",
+				show,
+			)
+
+			util.Command(
+				cmd,
+				"enable",
+				"Start keeping usage statistics",
+				enable,
+			)
+
+			util.Command(
+				cmd,
+				"disable",
+				"Stop keeping usage statistics. The file is left alone",
+				disable,
+			)
+
+			util.Command(
+				cmd,
+				"reset",
+				"Forget the usage statistics gathered so far",
+				reset,
+			)
+		},
+	)
+}
@@ -0,0 +1,154 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// commandStats is a single line of 'conch stats'
+type commandStats struct {
+	Command string `json:"command"`
+	util.CommandStats
+	Average time.Duration `json:"average_ns"`
+}
+
+func statsFile() string {
+	path, err := util.StatsFile()
+	if err != nil {
+		util.Bail(err)
+	}
+	if path == "" {
+		util.Bail(errors.New("usage statistics are not being kept. Run 'conch stats enable' to start"))
+	}
+	return path
+}
+
+func duration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond).String()
+	default:
+		return d.String()
+	}
+}
+
+func show(cmd *cli.Cmd) {
+	sorting := util.SortFlags(cmd, "stats")
+
+	cmd.Action = func() {
+		path := statsFile()
+		stats, err := util.LoadUsageStats(path)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		lines := make([]commandStats, 0, len(stats.Commands))
+		for name, s := range stats.Commands {
+			lines = append(lines, commandStats{name, *s, s.Average()})
+		}
+		// Most used first, unless --sort says otherwise
+		sort.Slice(lines, func(i, j int) bool {
+			if lines[i].Runs != lines[j].Runs {
+				return lines[i].Runs > lines[j].Runs
+			}
+			return lines[i].Command < lines[j].Command
+		})
+
+		header := []string{"Command", "Runs", "Failures", "Average", "Max", "Total", "Last Run"}
+		row := func(i int) []string {
+			l := lines[i]
+			return []string{
+				l.Command,
+				strconv.Itoa(l.Runs),
+				strconv.Itoa(l.Failures),
+				duration(l.Average),
+				duration(l.Max),
+				duration(l.Total),
+				util.TimeStr(l.LastRun),
+			}
+		}
+		if err := sorting.Sort(lines, header, row); err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(lines)
+			return
+		}
+
+		if len(lines) == 0 {
+			fmt.Printf("No commands have been recorded in %s yet\n", path)
+			return
+		}
+
+		fmt.Printf("Since %s, from %s\n\n", util.TimeStr(stats.Since), path)
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(lines), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func enable(cmd *cli.Cmd) {
+	var fileOpt = cmd.StringOpt("file", util.DefaultStatsFile, "Where to keep the statistics")
+
+	cmd.Action = func() {
+		if util.IgnoreConfig {
+			util.Bail(errors.New("the config file is being ignored, so statistics can't be enabled"))
+		}
+		if _, err := homedir.Expand(*fileOpt); err != nil {
+			util.Bail(err)
+		}
+
+		util.Config.StatsFile = *fileOpt
+		util.WriteConfig()
+
+		if !util.JSON {
+			fmt.Printf("Usage statistics will be kept in %s\n", *fileOpt)
+		}
+	}
+}
+
+func disable(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		path := statsFile()
+		util.CancelStats()
+
+		util.Config.StatsFile = ""
+		util.WriteConfig()
+
+		if !util.JSON {
+			fmt.Printf("Usage statistics are no longer being kept. %s was left alone\n", path)
+		}
+	}
+}
+
+func reset(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		path := statsFile()
+		util.CancelStats()
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			util.Bail(err)
+		}
+
+		if !util.JSON {
+			fmt.Printf("Removed %s\n", path)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package status
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// statusAcross collects the status of several workspaces at once. The JSON
+// output is a list of FleetStatus. The text output is an overview with a row
+// per workspace, followed by the failing validations and stale devices of all
+// of them merged, each with a workspace column.
+func statusAcross(targets *util.WorkspaceTargets, staleAfter time.Duration) {
+	workspaces, err := targets.Resolve()
+	if err != nil {
+		util.Bail(err)
+	}
+
+	statuses := make([]FleetStatus, len(workspaces))
+	err = util.FanOut(workspaces, func(i int, ws conch.Workspace) error {
+		s, err := collect(util.API, ws.ID, staleAfter)
+		if err != nil {
+			return err
+		}
+		statuses[i] = s
+		return nil
+	})
+	if err != nil {
+		util.Bail(err)
+	}
+
+	renderAcross(statuses, staleAfter)
+}
+
+// statusFederated collects the status of the workspace of every profile asked
+// for with --federate, each of which may be on a different Conch instance.
+// The output is that of statusAcross, with a source column naming the
+// profile.
+func statusFederated(federate *util.FederationTargets, staleAfter time.Duration) {
+	sources, err := federate.Resolve()
+	if err != nil {
+		util.Bail(err)
+	}
+	for _, s := range sources {
+		if uuid.Equal(s.Profile.WorkspaceUUID, uuid.UUID{}) {
+			util.Bail(fmt.Errorf(
+				"profile %s has no workspace. Use 'conch -p %s profile set workspace' to pick one",
+				s.Profile.Name,
+				s.Profile.Name,
+			))
+		}
+	}
+
+	statuses := make([]FleetStatus, len(sources))
+	err = util.Federate(sources, func(i int, s util.Source) error {
+		status, err := collect(s.API, s.Profile.WorkspaceUUID, staleAfter)
+		if err != nil {
+			return err
+		}
+		status.Source = s.Profile.Name
+		statuses[i] = status
+		return nil
+	})
+	if err != nil {
+		util.Bail(err)
+	}
+
+	renderAcross(statuses, staleAfter)
+}
+
+// renderAcross prints the status of several workspaces. If they came from
+// different profiles, each row also says which.
+func renderAcross(statuses []FleetStatus, staleAfter time.Duration) {
+	if util.JSON {
+		util.JSONOut(statuses)
+		return
+	}
+
+	federated := len(statuses) > 0 && statuses[0].Source != ""
+
+	// where labels the rows of a workspace
+	where := func(s FleetStatus, cells ...string) []string {
+		if federated {
+			return append([]string{s.Source, s.WorkspaceName}, cells...)
+		}
+		return append([]string{s.WorkspaceName}, cells...)
+	}
+	whereHeader := []string{"Workspace"}
+	if federated {
+		whereHeader = []string{"Source", "Workspace"}
+	}
+
+	fmt.Printf("Workspaces: %d\n\n", len(statuses))
+
+	table := util.GetMarkdownTable()
+	table.SetHeader(append(whereHeader, "Devices", "Racks", "Top Failing Validations", "Stale Devices"))
+	for _, s := range statuses {
+		table.Append(where(
+			s,
+			strconv.Itoa(s.DeviceCount),
+			strconv.Itoa(s.RackCount),
+			strconv.Itoa(len(s.FailingValidations)),
+			strconv.Itoa(len(s.StaleDevices)),
+		))
+	}
+	table.Render()
+	fmt.Println()
+
+	fmt.Printf("Failing validations:\n\n")
+	type failing struct {
+		status FleetStatus
+		FailingValidation
+	}
+	validations := make([]failing, 0)
+	for _, s := range statuses {
+		for _, v := range s.FailingValidations {
+			validations = append(validations, failing{s, v})
+		}
+	}
+	sort.SliceStable(validations, func(i, j int) bool {
+		return validations[i].Devices > validations[j].Devices
+	})
+	if len(validations) == 0 {
+		fmt.Printf("None\n\n")
+	} else {
+		table := util.GetMarkdownTable()
+		table.SetHeader(append(whereHeader, "Validation", "Devices"))
+		for _, v := range validations {
+			table.Append(where(v.status, v.Name, strconv.Itoa(v.Devices)))
+		}
+		table.Render()
+		fmt.Println()
+	}
+
+	type stale struct {
+		status FleetStatus
+		StaleDevice
+	}
+	devices := make([]stale, 0)
+	for _, s := range statuses {
+		for _, d := range s.StaleDevices {
+			devices = append(devices, stale{s, d})
+		}
+	}
+	sort.SliceStable(devices, func(i, j int) bool {
+		return devices[i].LastSeen.Before(devices[j].LastSeen)
+	})
+
+	fmt.Printf("Stale devices (not seen in %s): %d\n\n", staleAfter, len(devices))
+	if len(devices) > 0 {
+		table := util.GetMarkdownTable()
+		table.SetHeader(append(whereHeader, "ID", "Health", "Phase", "Last Seen"))
+		for i, d := range devices {
+			if i == maxStaleDevices {
+				more := make([]string, len(whereHeader))
+				table.Append(append(
+					more,
+					fmt.Sprintf("... and %d more", len(devices)-i),
+					"", "", "",
+				))
+				break
+			}
+
+			lastSeen := "never"
+			if !d.LastSeen.IsZero() {
+				lastSeen = util.TimeStr(d.LastSeen)
+			}
+			table.Append(where(d.status, d.ID, d.Health, d.Phase, lastSeen))
+		}
+		table.Render()
+	}
+
+	type maintained struct {
+		status FleetStatus
+		MaintenanceDevice
+	}
+	maintenance := make([]maintained, 0)
+	for _, s := range statuses {
+		for _, d := range s.InMaintenance {
+			maintenance = append(maintenance, maintained{s, d})
+		}
+	}
+	if len(maintenance) > 0 {
+		fmt.Printf("\nIn maintenance, and left out above: %d\n\n", len(maintenance))
+		table := util.GetMarkdownTable()
+		table.SetHeader(append(whereHeader, "ID", "Health", "Until", "Reason"))
+		for _, d := range maintenance {
+			table.Append(where(d.status, d.ID, d.Health, util.TimeStr(d.Until), d.Reason))
+		}
+		table.Render()
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package status
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Nagios plugin exit codes
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStates = map[int]string{
+	checkOK:       "OK",
+	checkWarning:  "WARNING",
+	checkCritical: "CRITICAL",
+	checkUnknown:  "UNKNOWN",
+}
+
+// checkUnknownExit reports an error in the format monitoring systems expect,
+// rather than through util.Bail whose exit code means WARNING to Nagios
+func checkUnknownExit(err error) {
+	fmt.Printf("CONCH UNKNOWN - %s\n", err)
+	util.Exit(checkUnknown)
+}
+
+// threshold compares a value to warning and critical limits. A negative limit
+// is disabled.
+func threshold(value int, warn int, crit int) int {
+	if crit >= 0 && value > crit {
+		return checkCritical
+	}
+	if warn >= 0 && value > warn {
+		return checkWarning
+	}
+	return checkOK
+}
+
+func check(cmd *cli.Cmd) {
+	var (
+		workspaceOpt   = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+		staleOpt       = cmd.StringOpt("stale", "24h", "Devices that have not reported in this long are considered stale. Uses Go duration syntax")
+		maxFailingOpt  = cmd.IntOpt("max-failing", 0, "CRITICAL if more than this many devices have a health of 'fail' or 'error'. -1 disables")
+		warnFailingOpt = cmd.IntOpt("warn-failing", -1, "WARNING if more than this many devices have a health of 'fail' or 'error'. -1 disables")
+		maxStaleOpt    = cmd.IntOpt("max-stale", -1, "CRITICAL if more than this many devices are stale. -1 disables")
+		warnStaleOpt   = cmd.IntOpt("warn-stale", -1, "WARNING if more than this many devices are stale. -1 disables")
+	)
+
+	cmd.LongDesc = `
+Checks the health of a workspace against thresholds and reports in the format
+used by Nagios and Icinga plugins. Exits 0 for OK, 1 for WARNING, 2 for
+CRITICAL, and 3 for UNKNOWN, which covers any error talking to the API while
+running the check. Problems with the profile or login are caught before the
+check runs and exit 1, like any other conch command.
+
+Devices in maintenance, see 'conch device maintenance', don't count as failing
+or stale.
+
+Performance data is included for the number of devices, failing devices,
+stale devices, and devices in maintenance.`
+
+	cmd.Before = util.BuildAPIAndVerifyLogin
+
+	cmd.Action = func() {
+		staleAfter, err := time.ParseDuration(*staleOpt)
+		if err != nil {
+			checkUnknownExit(err)
+		}
+
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			checkUnknownExit(err)
+		}
+
+		s, err := collect(util.API, workspaceID, staleAfter)
+		if err != nil {
+			checkUnknownExit(err)
+		}
+
+		// Devices in maintenance are expected to look broken
+		failing := s.DevicesByHealth["fail"] + s.DevicesByHealth["error"]
+		for _, d := range s.InMaintenance {
+			if d.Health == "fail" || d.Health == "error" {
+				failing--
+			}
+		}
+		stale := len(s.StaleDevices)
+
+		state := threshold(failing, *warnFailingOpt, *maxFailingOpt)
+		if st := threshold(stale, *warnStaleOpt, *maxStaleOpt); st > state {
+			state = st
+		}
+
+		perf := []string{
+			fmt.Sprintf("devices=%d", s.DeviceCount),
+			fmt.Sprintf("failing=%d;%s;%s", failing, perfLimit(*warnFailingOpt), perfLimit(*maxFailingOpt)),
+			fmt.Sprintf("stale=%d;%s;%s", stale, perfLimit(*warnStaleOpt), perfLimit(*maxStaleOpt)),
+			fmt.Sprintf("maintenance=%d", len(s.InMaintenance)),
+		}
+
+		fmt.Printf(
+			"CONCH %s - %s: %d devices, %d failing, %d stale (not seen in %s), %d in maintenance | %s\n",
+			checkStates[state],
+			s.WorkspaceName,
+			s.DeviceCount,
+			failing,
+			stale,
+			s.StaleThreshold,
+			len(s.InMaintenance),
+			strings.Join(perf, " "),
+		)
+
+		util.Exit(state)
+	}
+}
+
+func perfLimit(limit int) string {
+	if limit < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", limit)
+}
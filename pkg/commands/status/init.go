@@ -0,0 +1,32 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package status contains commands that summarize the state of the fleet
+package status
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the status commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"status",
+		"Get a one-screen overview of the devices and racks in a workspace",
+		fleetStatus,
+	)
+
+	util.Command(
+		app.Cmd,
+		"check",
+		"Check the health of a workspace, as a Nagios or Icinga plugin",
+		check,
+	)
+}
@@ -0,0 +1,342 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package status
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+const (
+	// The number of failing validations listed in the summary
+	topValidations = 10
+
+	// The number of stale devices listed in the text summary. The JSON output
+	// contains all of them.
+	maxStaleDevices = 10
+)
+
+// FleetStatus is a summary of the state of a workspace
+type FleetStatus struct {
+	Source             string              `json:"source,omitempty"`
+	WorkspaceID        uuid.UUID           `json:"workspace_id"`
+	WorkspaceName      string              `json:"workspace_name"`
+	Generated          time.Time           `json:"generated"`
+	DeviceCount        int                 `json:"device_count"`
+	DevicesByHealth    map[string]int      `json:"devices_by_health"`
+	DevicesByPhase     map[string]int      `json:"devices_by_phase"`
+	FailingValidations []FailingValidation `json:"failing_validations"`
+	StaleThreshold     string              `json:"stale_threshold"`
+	StaleDevices       []StaleDevice       `json:"stale_devices"`
+	RackCount          int                 `json:"rack_count"`
+	RacksByPhase       map[string]int      `json:"racks_by_phase"`
+
+	// InMaintenance are the devices being worked on. They are left out of
+	// FailingValidations and StaleDevices.
+	InMaintenance []MaintenanceDevice `json:"in_maintenance"`
+}
+
+// MaintenanceDevice is a device in a maintenance window
+type MaintenanceDevice struct {
+	ID     string    `json:"id"`
+	Health string    `json:"health"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// FailingValidation is a validation and the number of devices currently
+// failing it
+type FailingValidation struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Devices int       `json:"devices"`
+}
+
+// StaleDevice is a device that has not reported in recently
+type StaleDevice struct {
+	ID       string    `json:"id"`
+	Health   string    `json:"health"`
+	Phase    string    `json:"phase"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+func fleetStatus(cmd *cli.Cmd) {
+	var (
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace. Defaults to the workspace in the active profile")
+		staleOpt     = cmd.StringOpt("stale", "24h", "Devices that have not reported in this long are considered stale. Uses Go duration syntax (eg '6h', '90m')")
+	)
+
+	targets := util.WorkspaceFanOutFlags(cmd)
+	federate := util.FederateFlags(cmd)
+
+	cmd.Before = util.BuildAPIAndVerifyLogin
+
+	cmd.Action = func() {
+		staleAfter, err := time.ParseDuration(*staleOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if federate.Set() {
+			if *workspaceOpt != "" || targets.Set() {
+				util.Bail(errors.New("--federate can't be combined with --workspace, --all-workspaces, or --workspaces"))
+			}
+			statusFederated(federate, staleAfter)
+			return
+		}
+
+		if targets.Set() {
+			if *workspaceOpt != "" {
+				util.Bail(errors.New("--workspace can't be combined with --all-workspaces or --workspaces"))
+			}
+			statusAcross(targets, staleAfter)
+			return
+		}
+
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		s, err := collect(util.API, workspaceID, staleAfter)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(s)
+			return
+		}
+
+		s.render()
+	}
+}
+
+// collect gathers the status of a workspace from the API
+func collect(api *conch.Conch, workspaceID uuid.UUID, staleAfter time.Duration) (s FleetStatus, err error) {
+	workspace, err := api.GetWorkspace(workspaceID)
+	if err != nil {
+		return s, err
+	}
+
+	devices, err := api.GetWorkspaceDevices(workspaceID, false, "", "", "")
+	if err != nil {
+		return s, err
+	}
+
+	racks, err := api.GetWorkspaceRacks(workspaceID)
+	if err != nil {
+		return s, err
+	}
+
+	states, err := api.WorkspaceValidationStates(workspaceID)
+	if err != nil {
+		return s, err
+	}
+
+	validations, err := api.GetValidations()
+	if err != nil {
+		return s, err
+	}
+
+	now := time.Now()
+
+	maintenance, err := api.GetDevicesInMaintenance(now)
+	if err != nil {
+		return s, err
+	}
+
+	s = FleetStatus{
+		WorkspaceID:        workspace.ID,
+		WorkspaceName:      workspace.Name,
+		Generated:          now,
+		DeviceCount:        len(devices),
+		DevicesByHealth:    make(map[string]int),
+		DevicesByPhase:     make(map[string]int),
+		FailingValidations: make([]FailingValidation, 0),
+		StaleThreshold:     staleAfter.String(),
+		StaleDevices:       make([]StaleDevice, 0),
+		RackCount:          len(racks),
+		RacksByPhase:       make(map[string]int),
+		InMaintenance:      make([]MaintenanceDevice, 0),
+	}
+
+	for _, d := range devices {
+		s.DevicesByHealth[d.Health]++
+		s.DevicesByPhase[d.Phase]++
+
+		if m, ok := maintenance[d.ID]; ok {
+			s.InMaintenance = append(s.InMaintenance, MaintenanceDevice{
+				ID:     d.ID,
+				Health: d.Health,
+				Until:  m.Until,
+				Reason: m.Reason,
+			})
+			continue
+		}
+
+		if d.LastSeen.IsZero() || now.Sub(d.LastSeen) > staleAfter {
+			s.StaleDevices = append(s.StaleDevices, StaleDevice{
+				ID:       d.ID,
+				Health:   d.Health,
+				Phase:    d.Phase,
+				LastSeen: d.LastSeen,
+			})
+		}
+	}
+	sort.Slice(s.StaleDevices, func(i, j int) bool {
+		return s.StaleDevices[i].LastSeen.Before(s.StaleDevices[j].LastSeen)
+	})
+	sort.Slice(s.InMaintenance, func(i, j int) bool {
+		return s.InMaintenance[i].Until.Before(s.InMaintenance[j].Until)
+	})
+
+	for _, r := range racks {
+		s.RacksByPhase[r.Phase]++
+	}
+
+	validationNames := make(map[uuid.UUID]string)
+	for _, v := range validations {
+		validationNames[v.ID] = v.Name
+	}
+
+	// A device can fail a validation several times over, once per
+	// component. We only want to count the device once.
+	failing := make(map[uuid.UUID]map[string]bool)
+	for _, state := range states {
+		for _, r := range state.Results {
+			if r.Status == "pass" {
+				continue
+			}
+			if _, ok := maintenance[state.DeviceID]; ok {
+				continue
+			}
+			if _, ok := failing[r.ValidationID]; !ok {
+				failing[r.ValidationID] = make(map[string]bool)
+			}
+			failing[r.ValidationID][state.DeviceID] = true
+		}
+	}
+
+	for id, devs := range failing {
+		name, ok := validationNames[id]
+		if !ok {
+			name = id.String()
+		}
+		s.FailingValidations = append(s.FailingValidations, FailingValidation{
+			ID:      id,
+			Name:    name,
+			Devices: len(devs),
+		})
+	}
+	sort.Slice(s.FailingValidations, func(i, j int) bool {
+		a, b := s.FailingValidations[i], s.FailingValidations[j]
+		if a.Devices == b.Devices {
+			return a.Name < b.Name
+		}
+		return a.Devices > b.Devices
+	})
+	if len(s.FailingValidations) > topValidations {
+		s.FailingValidations = s.FailingValidations[:topValidations]
+	}
+
+	return s, nil
+}
+
+func (s FleetStatus) render() {
+	fmt.Printf(
+		"Workspace: %s (%s)\nGenerated: %s\n\n",
+		s.WorkspaceName,
+		s.WorkspaceID,
+		util.TimeStr(s.Generated),
+	)
+
+	fmt.Printf("Devices: %d\n\n", s.DeviceCount)
+	renderCounts("Health", s.DevicesByHealth)
+	renderCounts("Phase", s.DevicesByPhase)
+
+	fmt.Printf("Racks: %d\n\n", s.RackCount)
+	renderCounts("Phase", s.RacksByPhase)
+
+	fmt.Printf("Top failing validations:\n\n")
+	if len(s.FailingValidations) == 0 {
+		fmt.Printf("None\n\n")
+	} else {
+		table := util.GetMarkdownTable()
+		table.SetHeader([]string{"Validation", "Devices"})
+		for _, v := range s.FailingValidations {
+			table.Append([]string{v.Name, strconv.Itoa(v.Devices)})
+		}
+		table.Render()
+		fmt.Println()
+	}
+
+	fmt.Printf("Stale devices (not seen in %s): %d\n\n", s.StaleThreshold, len(s.StaleDevices))
+	if len(s.StaleDevices) > 0 {
+		table := util.GetMarkdownTable()
+		table.SetHeader([]string{"ID", "Health", "Phase", "Last Seen"})
+
+		for i, d := range s.StaleDevices {
+			if i == maxStaleDevices {
+				table.Append([]string{
+					fmt.Sprintf("... and %d more", len(s.StaleDevices)-i),
+					"", "", "",
+				})
+				break
+			}
+
+			lastSeen := "never"
+			if !d.LastSeen.IsZero() {
+				lastSeen = util.TimeStr(d.LastSeen)
+			}
+			table.Append([]string{d.ID, d.Health, d.Phase, lastSeen})
+		}
+		table.Render()
+	}
+
+	if len(s.InMaintenance) > 0 {
+		fmt.Printf("\nIn maintenance, and left out above: %d\n\n", len(s.InMaintenance))
+		table := util.GetMarkdownTable()
+		table.SetHeader([]string{"ID", "Health", "Until", "Reason"})
+		for _, d := range s.InMaintenance {
+			table.Append([]string{d.ID, d.Health, util.TimeStr(d.Until), d.Reason})
+		}
+		table.Render()
+	}
+}
+
+func renderCounts(title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	table := util.GetMarkdownTable()
+	table.SetHeader([]string{title, "Count"})
+	for _, k := range keys {
+		name := k
+		if name == "" {
+			name = "[none]"
+		}
+		table.Append([]string{name, strconv.Itoa(counts[k])})
+	}
+	table.Render()
+	fmt.Println()
+}
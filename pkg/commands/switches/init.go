@@ -0,0 +1,49 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package switches contains commands that look at the network from the
+// switch's side, using the LLDP data in device reports
+package switches
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// SwitchName is the name, hostname, or serial of the switch we're looking at,
+// as provided by the user
+var SwitchName string
+
+// Init loads up the switch commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"switch sw",
+		"Commands for dealing with a single switch",
+		func(cmd *cli.Cmd) {
+			var switchNameStr = cmd.StringArg(
+				"NAME",
+				"",
+				"The switch's name as it appears in LLDP data, or its hostname or serial if it is a device in Conch",
+			)
+			cmd.Spec = "NAME"
+
+			cmd.Before = func() {
+				util.BuildAPIAndVerifyLogin()
+				SwitchName = *switchNameStr
+			}
+
+			util.Command(
+				cmd,
+				"peers",
+				"Show which devices are cabled to each port of the switch",
+				peers,
+			)
+		},
+	)
+}
@@ -0,0 +1,240 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package switches
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+const (
+	peerOK        = "ok"
+	peerShared    = "shared port"
+	peerOtherRack = "other rack"
+	peerMissing   = "missing"
+)
+
+// switchPeer is a device interface whose LLDP neighbor is the switch, or a
+// device in the switch's rack that has no such interface
+type switchPeer struct {
+	Port      string `json:"port"`
+	DeviceID  string `json:"device_id"`
+	Hostname  string `json:"hostname"`
+	Interface string `json:"interface"`
+	MAC       string `json:"mac"`
+	Rack      string `json:"rack"`
+	Status    string `json:"status"`
+}
+
+// shortName drops the domain from a host name
+func shortName(name string) string {
+	if i := strings.Index(name, "."); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func peers(cmd *cli.Cmd) {
+	var (
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace whose devices are searched. Defaults to the workspace in the active profile")
+		problemsOpt  = cmd.BoolOpt("problems", false, "Only show ports and devices that need a look")
+		sorting      = util.SortFlags(cmd, "switch_peers")
+	)
+
+	cmd.LongDesc = `
+Inverts the LLDP neighbor data in the device reports of a workspace to show
+which device interface is cabled to each port of the switch. This requires
+fetching every device in the workspace individually.
+
+Each row is marked with one of:
+
+    ok           The port has a single neighbor, in the switch's rack
+    shared port  More than one interface claims the same port
+    other rack   The neighbor is not in the switch's rack
+    missing      A device in the switch's rack has no interface cabled to
+                 the switch
+
+The rack checks are only made when the switch is itself a device in the
+workspace. The command exits non-zero if any row is not ok.`
+
+	cmd.Action = func() {
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		devices, err := util.API.GetWorkspaceDevices(workspaceID, false, "", "", "")
+		if err != nil {
+			util.Bail(err)
+		}
+		devices, err = util.FillDeviceDetails(devices)
+		if err != nil {
+			util.Bail(err)
+		}
+		devices, err = util.FillDeviceLocations(devices)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		// Every name the switch might go by in LLDP data
+		names := map[string]bool{strings.ToLower(SwitchName): true}
+		var sw *conch.Device
+		for i, d := range devices {
+			if strings.EqualFold(d.ID, SwitchName) ||
+				strings.EqualFold(d.Hostname, SwitchName) ||
+				strings.EqualFold(shortName(d.Hostname), SwitchName) {
+				sw = &devices[i]
+				break
+			}
+		}
+		if sw != nil {
+			names[strings.ToLower(sw.ID)] = true
+			if sw.Hostname != "" {
+				names[strings.ToLower(sw.Hostname)] = true
+				names[strings.ToLower(shortName(sw.Hostname))] = true
+			}
+		}
+		isSwitch := func(peer string) bool {
+			peer = strings.ToLower(peer)
+			return names[peer] || names[shortName(peer)]
+		}
+
+		// Anything that shows up as a neighbor is a switch, and isn't
+		// expected to be cabled to this one
+		switches := make(map[string]bool)
+		for _, d := range devices {
+			for _, nic := range d.Nics {
+				if nic.PeerSwitch != "" {
+					switches[strings.ToLower(nic.PeerSwitch)] = true
+					switches[strings.ToLower(shortName(nic.PeerSwitch))] = true
+				}
+			}
+		}
+
+		var switchRack uuid.UUID
+		if sw != nil {
+			switchRack = sw.Location.Rack.ID
+		}
+
+		results := make([]switchPeer, 0)
+		cabled := make(map[string]bool)
+		for _, d := range devices {
+			if sw != nil && d.ID == sw.ID {
+				continue
+			}
+			for _, nic := range d.Nics {
+				if !isSwitch(nic.PeerSwitch) {
+					continue
+				}
+				cabled[d.ID] = true
+
+				status := peerOK
+				if !switchRack.IsZero() && !uuid.Equal(d.Location.Rack.ID, switchRack) {
+					status = peerOtherRack
+				}
+				results = append(results, switchPeer{
+					Port:      nic.PeerPort,
+					DeviceID:  d.ID,
+					Hostname:  d.Hostname,
+					Interface: nic.IfaceName,
+					MAC:       nic.MAC,
+					Rack:      d.Location.Rack.Name,
+					Status:    status,
+				})
+			}
+		}
+
+		if len(results) == 0 && sw == nil {
+			util.Bail(fmt.Errorf("no device in the workspace has an LLDP neighbor named '%s'", SwitchName))
+		}
+
+		byPort := make(map[string]int)
+		for _, p := range results {
+			byPort[p.Port]++
+		}
+		for i, p := range results {
+			if byPort[p.Port] > 1 {
+				results[i].Status = peerShared
+			}
+		}
+
+		if !switchRack.IsZero() {
+			for _, d := range devices {
+				if d.ID == sw.ID || cabled[d.ID] || !uuid.Equal(d.Location.Rack.ID, switchRack) {
+					continue
+				}
+				if switches[strings.ToLower(d.ID)] ||
+					(d.Hostname != "" && switches[strings.ToLower(shortName(d.Hostname))]) {
+					continue
+				}
+				results = append(results, switchPeer{
+					DeviceID: d.ID,
+					Hostname: d.Hostname,
+					Rack:     d.Location.Rack.Name,
+					Status:   peerMissing,
+				})
+			}
+		}
+
+		// Ports in port order, then the devices with no port
+		sort.SliceStable(results, func(i, j int) bool {
+			a, b := results[i], results[j]
+			if a.Port != b.Port {
+				return util.NaturalLess(a.Port, b.Port)
+			}
+			return a.DeviceID < b.DeviceID
+		})
+
+		problems := 0
+		for _, p := range results {
+			if p.Status != peerOK {
+				problems++
+			}
+		}
+
+		if *problemsOpt {
+			filtered := make([]switchPeer, 0)
+			for _, p := range results {
+				if p.Status != peerOK {
+					filtered = append(filtered, p)
+				}
+			}
+			results = filtered
+		}
+
+		header := []string{"Port", "Device", "Hostname", "Interface", "MAC", "Rack", "Status"}
+		row := func(i int) []string {
+			p := results[i]
+			return []string{p.Port, p.DeviceID, p.Hostname, p.Interface, p.MAC, p.Rack, p.Status}
+		}
+
+		if err := sorting.Sort(results, header, row); err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(results)
+		} else {
+			if sw == nil {
+				fmt.Printf("Switch %s is not a device in the workspace, so rack checks were skipped\n\n", SwitchName)
+			}
+			if err := util.RenderTable(util.GetMarkdownTable(), header, len(results), row, "Rack", "Status"); err != nil {
+				util.Bail(err)
+			}
+		}
+
+		if problems > 0 {
+			util.Exit(1)
+		}
+	}
+}
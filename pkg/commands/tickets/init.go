@@ -0,0 +1,35 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package tickets contains commands for finding devices that need a ticket in
+// an outside tracker, like JIRA
+package tickets
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the ticket commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"tickets",
+		"Commands for the tickets linked to devices with 'conch device ID ticket link'",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+
+			util.Command(
+				cmd,
+				"open",
+				"List the devices of a workspace that have a given health but no linked ticket",
+				open,
+			)
+		},
+	)
+}
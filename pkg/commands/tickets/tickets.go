@@ -0,0 +1,83 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package tickets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func open(cmd *cli.Cmd) {
+	var (
+		healthOpt    = cmd.StringOpt("health", "fail", "Only list devices with this health")
+		workspaceOpt = cmd.StringOpt("workspace ws", "", "The UUID or name of the workspace whose devices are listed. Defaults to the workspace in the active profile")
+		sorting      = util.SortFlags(cmd, "devices")
+	)
+
+	cmd.LongDesc = `
+Lists the devices of the workspace that have the given health, failing by
+default, but no ticket linked with 'conch device ID ticket link'. These are the
+failures nobody has picked up yet.
+
+The API can't send settings along with a list of devices, so the settings of
+each device with the health are fetched individually.`
+
+	cmd.Action = func() {
+		workspaceID, err := util.MagicWorkspaceOrActiveID(*workspaceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		health := strings.ToLower(strings.TrimSpace(*healthOpt))
+		devices, err := util.API.GetWorkspaceDevices(workspaceID, false, "", health, "")
+		if err != nil {
+			util.Bail(err)
+		}
+
+		util.CheckRequestBudget(
+			len(devices),
+			fmt.Sprintf("Checking %d devices for linked tickets", len(devices)),
+		)
+
+		var mu sync.Mutex
+		unticketed := make(conch.Devices, 0)
+		err = util.EachDevice(devices, func(i int, d conch.Device) error {
+			links, err := util.API.GetDeviceTickets(d.ID)
+			if err != nil {
+				return fmt.Errorf("device %s: %s", d.ID, err)
+			}
+			if len(links) > 0 {
+				return nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			unticketed = append(unticketed, d)
+			return nil
+		})
+		if err != nil {
+			util.Bail(err)
+		}
+
+		sort.Sort(unticketed)
+
+		if !util.JSON && len(unticketed) == 0 {
+			fmt.Printf("Every device with health '%s' has a linked ticket\n", health)
+			return
+		}
+
+		if err := util.DisplayDevices(unticketed, false, sorting); err != nil {
+			util.Bail(err)
+		}
+	}
+}
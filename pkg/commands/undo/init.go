@@ -0,0 +1,37 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package undo contains commands for reversing changes recorded in the change
+// journal
+package undo
+
+import (
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// Init loads up the commands
+func Init(app *cli.Cli) {
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
+		"undo",
+		"Reverse the most recent change recorded in the change journal that can be reversed",
+		func(cmd *cli.Cmd) {
+			cmd.Before = util.BuildAPIAndVerifyLogin
+
+			undo(cmd)
+
+			util.Command(
+				cmd,
+				"list ls",
+				"List the recorded changes that can be undone, newest first",
+				list,
+			)
+		},
+	)
+}
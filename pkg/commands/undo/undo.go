@@ -0,0 +1,135 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package undo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func commandLine(e util.JournalEntry) string {
+	if len(e.Command) <= 1 {
+		return "conch"
+	}
+	return "conch " + strings.Join(e.Command[1:], " ")
+}
+
+func undo(cmd *cli.Cmd) {
+	var idOpt = cmd.StringOpt("id", "", "Undo this journal entry, as shown by 'undo list', rather than the most recent one")
+	planOpts := util.NewPlanOpts(cmd)
+
+	cmd.LongDesc = `
+Reverses a command recorded in the change journal. Only some changes have an
+inverse, and only when the journal recorded what they replaced:
+
+    - deleted rack layout slots are recreated
+    - changed asset tags and device phases are restored
+    - changed or deleted device settings and tags are restored, and new
+      ones are deleted
+    - users removed from a workspace are added back with their old role
+
+Other changes made by the same command are left alone. Each command can be
+undone once, and undoing an undo is not supported.
+
+Changes are recorded only while the profile has a journal. See
+'conch profile set journal'.`
+
+	cmd.Action = func() {
+		entries, err := util.UndoableEntries()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		var target *util.UndoableEntry
+		for i := range entries {
+			if *idOpt == "" || entries[i].Entry.ID == *idOpt {
+				target = &entries[i]
+				break
+			}
+		}
+		if target == nil {
+			if *idOpt != "" {
+				util.Bail(fmt.Errorf("journal entry %s does not exist or can't be undone", *idOpt))
+			}
+			util.Bail(errors.New("there is nothing in the journal that can be undone"))
+		}
+
+		if !util.JSON {
+			fmt.Printf(
+				"Undoing '%s' from %s\n",
+				commandLine(target.Entry),
+				util.TimeStr(target.Entry.Time),
+			)
+			if target.Skipped > 0 {
+				fmt.Printf("%d of its changes can't be undone and will be left alone\n", target.Skipped)
+			}
+			fmt.Println()
+		}
+
+		if !*planOpts.DryRun {
+			util.MarkJournalUndo(target.Entry.ID)
+		}
+		target.Plan.Run(planOpts)
+	}
+}
+
+// listedEntry is a journal entry as 'undo list' shows it with --json
+type listedEntry struct {
+	ID      string             `json:"id"`
+	Time    string             `json:"time"`
+	Command string             `json:"command"`
+	Changes []*util.PlanChange `json:"changes"`
+	Skipped int                `json:"skipped"`
+}
+
+func list(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		entries, err := util.UndoableEntries()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			out := make([]listedEntry, 0, len(entries))
+			for _, e := range entries {
+				out = append(out, listedEntry{
+					e.Entry.ID,
+					util.TimeStr(e.Entry.Time),
+					commandLine(e.Entry),
+					e.Plan.Changes,
+					e.Skipped,
+				})
+			}
+			util.JSONOut(out)
+			return
+		}
+
+		header := []string{"ID", "Time", "Command", "Undo", "Skipped"}
+		row := func(i int) []string {
+			e := entries[i]
+			changes := make([]string, 0, len(e.Plan.Changes))
+			for _, c := range e.Plan.Changes {
+				changes = append(changes, fmt.Sprintf("%s %s %s", c.Action, c.Kind, c.Name))
+			}
+			return []string{
+				e.Entry.ID,
+				util.TimeStr(e.Entry.Time),
+				commandLine(e.Entry),
+				strings.Join(changes, "; "),
+				strconv.Itoa(e.Skipped),
+			}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(entries), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
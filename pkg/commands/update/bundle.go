@@ -0,0 +1,268 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package update
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// A signed bundle has a MANIFEST, signed as MANIFEST.sig in the same way as
+// release binaries are. Its first line is "version 1.2.3", and the rest are
+// the SHA256 sums of the binaries, as "sum  name". The signature covers the
+// version as well as the binaries, so a bundle can't be passed off as a
+// different release than it is.
+const (
+	manifestFile    = "MANIFEST"
+	manifestSigFile = "MANIFEST.sig"
+)
+
+// bundleManifest is what a bundle's MANIFEST vouches for
+type bundleManifest struct {
+	version string
+	sums    map[string]string
+}
+
+func parseManifest(data []byte) (bundleManifest, error) {
+	m := bundleManifest{sums: make(map[string]string)}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) == 2 && fields[0] == "version":
+			m.version = strings.TrimLeft(fields[1], "v")
+		case len(fields) == 2:
+			m.sums[path.Base(strings.TrimLeft(fields[1], "*"))] = strings.ToLower(fields[0])
+		default:
+			return m, fmt.Errorf("%s has a bad line '%s'", manifestFile, line)
+		}
+	}
+	if m.version == "" {
+		return m, fmt.Errorf("%s has no version", manifestFile)
+	}
+	return m, nil
+}
+
+// verifyManifest checks the bundle's signed MANIFEST, and that the binary
+// and the VERSION file, if there is one, match it. Like verifySignature, it
+// reports whether the signature was checked at all.
+func verifyManifest(files map[string][]byte, release string, name string, bin []byte, version string, allowUnsigned bool) (bool, error) {
+	if util.ReleaseSigningKey == "" {
+		if !util.JSON {
+			fmt.Fprintf(os.Stderr, "This shell was built without a release signing key, so signatures can't be checked\n")
+		}
+		return false, nil
+	}
+
+	data, found := files[manifestFile]
+	sig, signed := files[manifestSigFile]
+	if !found || !signed {
+		return false, refuseUnsigned(release, name, allowUnsigned)
+	}
+	if err := checkSignature(manifestFile, data, sig); err != nil {
+		return false, err
+	}
+
+	m, err := parseManifest(data)
+	if err != nil {
+		return false, fmt.Errorf("!!! %s: %s", release, err)
+	}
+	if version != "" && strings.TrimLeft(version, "v") != m.version {
+		return false, fmt.Errorf(
+			"!!! the VERSION of %s is %s, but its signed %s is for version %s",
+			release,
+			version,
+			manifestFile,
+			m.version,
+		)
+	}
+
+	sum, ok := m.sums[name]
+	if !ok {
+		return false, fmt.Errorf("!!! the signed %s of %s has no sum for %s", manifestFile, release, name)
+	}
+	h := sha256.Sum256(bin)
+	if hex.EncodeToString(h[:]) != sum {
+		return false, fmt.Errorf("!!! %s does not match the sum in the signed %s of %s", name, manifestFile, release)
+	}
+
+	if !util.JSON {
+		fmt.Fprintf(os.Stderr, "Signature verified\n")
+	}
+	return true, nil
+}
+
+// readBundle reads every regular file in a release bundle, which is a tar
+// archive, compressed with gzip or not, as made by 'make bundle'. Files are
+// keyed by their base name.
+func readBundle(bundlePath string) (map[string][]byte, error) {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(bundlePath, ".gz") || strings.HasSuffix(bundlePath, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a gzipped tar archive: %s", bundlePath, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle %s: %s", bundlePath, err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from bundle %s: %s", hdr.Name, bundlePath, err)
+		}
+		files[path.Base(hdr.Name)] = data
+	}
+	return files, nil
+}
+
+func fromFile(cmd *cli.Cmd) {
+	var bundleArg = cmd.StringArg("BUNDLE", "", "The release bundle, a .tar.gz made by 'make bundle'")
+	var force = cmd.BoolOpt(
+		"force",
+		false,
+		"Install the bundle even if it isn't newer than this version",
+	)
+	var yes = cmd.BoolOpt(
+		"yes y",
+		false,
+		"Don't ask before upgrading",
+	)
+	var allowUnsigned = cmd.BoolOpt(
+		"allow-unsigned",
+		false,
+		"Install a release that has no signature, even though this shell was built to expect one",
+	)
+	var requireSignature = cmd.BoolOpt(
+		"require-signature",
+		false,
+		"Refuse bundles whose signature can't be checked",
+	)
+	cmd.Spec = "[OPTIONS] BUNDLE [OPTIONS]"
+
+	cmd.LongDesc = `
+Updates from a release bundle on disk, for machines that can't reach Github.
+A bundle is a tar archive of the release binaries, each with its .sha256 file,
+plus a VERSION file and, if the release is signed, a MANIFEST of the version
+and the binaries' sums with its signature, MANIFEST.sig. 'make bundle' builds
+one.
+
+The binary for this platform is checked against its SHA256 sum before it is
+installed. If this shell was built with the release signing key, the
+MANIFEST's signature is checked too, and the binary and the VERSION file must
+match the MANIFEST. Such a shell refuses bundles that aren't signed unless
+--allow-unsigned is given.`
+
+	cmd.Action = func() {
+		files, err := readBundle(*bundleArg)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		name := fmt.Sprintf("conch-%s-%s", runtime.GOOS, runtime.GOARCH)
+		bin, ok := files[name]
+		if !ok {
+			util.Bail(fmt.Errorf(
+				"bundle %s has no binary for %s-%s",
+				*bundleArg,
+				runtime.GOOS,
+				runtime.GOARCH,
+			))
+		}
+
+		bundleVersion := strings.TrimSpace(string(files["VERSION"]))
+		version := bundleVersion
+		if version == "" {
+			if !*force {
+				util.Bail(fmt.Errorf("bundle %s has no VERSION file. Use --force to install it anyway", *bundleArg))
+			}
+			version = "unknown"
+		} else {
+			sem, err := semver.Parse(strings.TrimLeft(version, "v"))
+			if err != nil {
+				util.Bail(fmt.Errorf("bundle %s has a bad VERSION '%s': %s", *bundleArg, version, err))
+			}
+			if !*force {
+				if sem.Major != util.SemVersion.Major {
+					util.Bail(fmt.Errorf(
+						"bundle %s is version %s, a different major version than this v%s. Use --force to install it anyway",
+						*bundleArg,
+						sem,
+						util.SemVersion,
+					))
+				}
+				if !sem.GT(util.SemVersion) {
+					util.Bail(errors.New("no upgrade required"))
+				}
+			}
+			version = sem.String()
+		}
+
+		if !*force && util.UserIsRoot() {
+			util.Bail(errors.New("cannot continue. user is root. provide --force if you are ok writing a binary to disk as root"))
+		}
+
+		bundleFiles := func(name string) ([]byte, bool, error) {
+			data, ok := files[name]
+			return data, ok, nil
+		}
+		release := "bundle " + path.Base(*bundleArg)
+
+		if err := verifyChecksum(bundleFiles, release, name, bin); err != nil {
+			util.Bail(err)
+		}
+		signed, err := verifyManifest(files, release, name, bin, bundleVersion, *allowUnsigned)
+		if err != nil {
+			util.Bail(err)
+		}
+		if *requireSignature && !signed {
+			util.Bail(fmt.Errorf("the signature of %s in %s couldn't be checked, and --require-signature was given", name, release))
+		}
+
+		if !*yes && !util.JSON {
+			if !util.Confirm(fmt.Sprintf("Upgrade from %s to %s?", util.SemVersion, version)) {
+				util.Bail(errors.New("upgrade cancelled"))
+			}
+		}
+
+		installBinary(bin, version)
+	}
+}
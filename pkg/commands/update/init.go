@@ -9,31 +9,52 @@ package update
 
 import (
 	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
 )
 
 // Init loads up the commands dealing with updating
 func Init(app *cli.Cli) {
-	app.Command(
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
 		"update",
 		"Commands around self-updating",
 		func(cmd *cli.Cmd) {
-			cmd.Command(
+			util.Command(
+				cmd,
 				"status",
 				"Verify that we have the most recent revision",
 				status,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"changelog",
 				"Display the latest changelog",
 				changelog,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"self",
 				"Update the running application to the latest release",
 				selfUpdate,
 			)
+
+			util.Command(
+				cmd,
+				"from-file",
+				"Update the running application from a release bundle on disk",
+				fromFile,
+			)
+
+			util.Command(
+				cmd,
+				"channel",
+				"Show or set the release channel updates come from",
+				showChannel,
+			)
 		},
 	)
 
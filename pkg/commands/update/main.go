@@ -7,8 +7,6 @@
 package update
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -54,59 +52,175 @@ func status(cmd *cli.Cmd) {
 	}
 }
 
+// channelOpt adds --channel, which overrides the configured update channel
+func channelOpt(cmd *cli.Cmd) func() string {
+	var opt = cmd.StringOpt(
+		"channel",
+		"",
+		"The release channel to look at, overriding the configured one: "+strings.Join(util.UpdateChannels, ", "),
+	)
+	return func() string {
+		if *opt == "" {
+			return util.UpdateChannel()
+		}
+		if err := util.ValidUpdateChannel(*opt); err != nil {
+			util.Bail(err)
+		}
+		return *opt
+	}
+}
+
+// printChangelog prints each release's notes, oldest first
+func printChangelog(releases util.GithubReleases) {
+	sort.Sort(sort.Reverse(releases))
+
+	// I'm not going to try and fully sanitize the output for a shell
+	// environment but removing the markdown backticks seems like a
+	// no-brainer for safety.
+	re := regexp.MustCompile("`")
+
+	for _, gh := range releases {
+		body := re.ReplaceAllLiteralString(gh.Body, "'")
+		fmt.Printf("# Version %s Changelog:\n\n", gh.TagName)
+		fmt.Println(body)
+		fmt.Print("- - -\n\n")
+	}
+}
+
 func changelog(cmd *cli.Cmd) {
+	channel := channelOpt(cmd)
+
 	cmd.Action = func() {
-		releases := util.GithubReleasesSince(util.SemVersion)
+		releases := util.GithubReleasesSinceOn(util.SemVersion, channel())
 		if len(releases) == 0 {
 			fmt.Println("No changelog found")
 			return
 		}
 
-		sort.Sort(sort.Reverse(releases))
+		printChangelog(releases)
+	}
+}
+
+func showChannel(cmd *cli.Cmd) {
+	var channelArg = cmd.StringArg("CHANNEL", "", "The channel to switch to: "+strings.Join(util.UpdateChannels, ", "))
+	cmd.Spec = "[CHANNEL]"
+	cmd.LongDesc = `
+Shows or sets the release channel that 'conch update' looks at. The stable
+channel only has full releases. The prerelease channel has release candidates
+as well. CONCH_UPDATE_CHANNEL overrides the setting.`
+
+	cmd.Action = func() {
+		if *channelArg == "" {
+			if util.JSON {
+				util.JSONOut(map[string]string{"channel": util.UpdateChannel()})
+				return
+			}
+			fmt.Println(util.UpdateChannel())
+			return
+		}
 
-		for _, gh := range releases {
+		if err := util.ValidUpdateChannel(*channelArg); err != nil {
+			util.Bail(err)
+		}
+		if util.IgnoreConfig {
+			util.Bail(errors.New("the config file is being ignored, so the channel can't be saved"))
+		}
 
-			// I'm not going to try and fully sanitize the output
-			// for a shell environment but removing the markdown
-			// backticks seems like a no-brainer for safety.
-			re := regexp.MustCompile("`")
-			body := gh.Body
-			re.ReplaceAllLiteralString(body, "'")
-			fmt.Printf("# Version %s Changelog:\n\n", gh.TagName)
-			fmt.Println(body)
-			fmt.Println("- - -\n")
+		util.Config.UpdateChannel = *channelArg
+		if *channelArg == util.ChannelStable {
+			util.Config.UpdateChannel = ""
+		}
+		util.WriteConfig()
 
+		if !util.JSON {
+			fmt.Printf("Updates will come from the %s channel\n", *channelArg)
 		}
 	}
 }
 
+// checkResult is the JSON output of 'update self --check-only'
+type checkResult struct {
+	Current           string `json:"current"`
+	Latest            string `json:"latest"`
+	Channel           string `json:"channel"`
+	Upgrade           bool   `json:"upgrade"`
+	SignatureVerified bool   `json:"signature_verified"`
+}
+
 func selfUpdate(cmd *cli.Cmd) {
 	var force = cmd.BoolOpt(
 		"force",
 		false,
 		"Update the binary even if it appears we are on the current release",
 	)
+	var checkOnly = cmd.BoolOpt(
+		"check-only",
+		false,
+		"Download and verify the new release, but don't install it. Exits non-zero if an upgrade is available",
+	)
+	var yes = cmd.BoolOpt(
+		"yes y",
+		false,
+		"Don't show the changelog and ask before upgrading",
+	)
+	var allowUnsigned = cmd.BoolOpt(
+		"allow-unsigned",
+		false,
+		"Install a release that has no signature, even though this shell was built to expect one",
+	)
+	var requireSignature = cmd.BoolOpt(
+		"require-signature",
+		false,
+		"Refuse releases whose signature can't be checked",
+	)
+	channel := channelOpt(cmd)
+
+	cmd.LongDesc = `
+Updates to the latest release on the update channel (see 'conch update
+channel'). The download is checked against the SHA256 sum published with the
+release and, if this shell was built with the release signing key, against
+the release's signature. Such a shell refuses releases that aren't signed
+unless --allow-unsigned is given.
+
+The changelog since this version is shown before anything is changed. With
+--check-only, nothing is changed and the exit status says whether an upgrade
+is available, for use in CI.`
+
 	cmd.Action = func() {
-		gh, err := util.LatestGithubRelease()
+		ch := channel()
+		result := checkResult{Current: util.SemVersion.String(), Channel: ch}
+
+		gh, err := util.LatestGithubReleaseOn(ch)
 
 		if err != nil {
 			if err == util.ErrNoGithubRelease {
+				if *checkOnly && util.JSON {
+					util.JSONOut(result)
+					return
+				}
 				fmt.Fprintln(os.Stderr, "no upgrade available")
 				return
 			}
 
 			util.Bail(err)
 		}
-
-		if !*force {
-			if !gh.Upgrade {
-				util.Bail(errors.New("no upgrade required"))
+		result.Latest = gh.SemVer.String()
+		result.Upgrade = gh.Upgrade
+
+		if !*force && !gh.Upgrade {
+			if *checkOnly {
+				if util.JSON {
+					util.JSONOut(result)
+				} else {
+					fmt.Printf("This is v%s, the latest release on the %s channel\n", util.Version, ch)
+				}
+				return
 			}
+			util.Bail(errors.New("no upgrade required"))
+		}
 
-			if util.UserIsRoot() {
-				util.Bail(errors.New("cannot continue. user is root. provide --force if you are ok writing data from the internet to disk as root"))
-
-			}
+		if !*force && !*checkOnly && util.UserIsRoot() {
+			util.Bail(errors.New("cannot continue. user is root. provide --force if you are ok writing data from the internet to disk as root"))
 		}
 
 		if !util.JSON {
@@ -127,136 +241,126 @@ func selfUpdate(cmd *cli.Cmd) {
 
 		// What platform are we on?
 		lookingFor := fmt.Sprintf("conch-%s-%s", runtime.GOOS, runtime.GOARCH)
-		downloadURL := ""
 
 		// Is this a supported platform
-		for _, a := range gh.Assets {
-			if a.Name == lookingFor {
-				downloadURL = a.BrowserDownloadURL
-			}
-		}
-
-		if downloadURL == "" {
+		asset, ok := findAsset(gh, lookingFor)
+		if !ok {
 			util.Bail(fmt.Errorf(
 				"could not find an appropriate binary for %s-%s",
 				runtime.GOOS,
 				runtime.GOARCH,
 			))
 		}
+		downloadURL := asset.BrowserDownloadURL
+
 		/// Download the binary
 		conchBin, err := updaterDownloadFile(downloadURL)
 		if err != nil {
 			util.Bail(err)
 		}
 
-		/// Verify checksum
-
-		// This assumes our build system is being sensible about file names.
-		// At time of writing, it is.
-		shaURL := downloadURL + ".sha256"
-		shaBin, err := updaterDownloadFile(shaURL)
+		/// Verify it
+		files := githubFiles(gh, downloadURL)
+		if err := verifyChecksum(files, gh.TagName, lookingFor, conchBin); err != nil {
+			util.Bail(err)
+		}
+		signed, err := verifySignature(files, gh.TagName, lookingFor, conchBin, *allowUnsigned)
 		if err != nil {
 			util.Bail(err)
 		}
-
-		// The checksum file looks like "thisisahexstring ./conch-os-arch"
-		bits := strings.Split(string(shaBin[:]), " ")
-		remoteSum := bits[0]
-
-		if !util.JSON {
-			fmt.Fprintf(
-				os.Stderr,
-				"Server-side SHA256 sum: %s\n",
-				remoteSum,
-			)
+		if *requireSignature && !signed {
+			util.Bail(fmt.Errorf("the signature of %s in release %s couldn't be checked, and --require-signature was given", lookingFor, gh.TagName))
 		}
+		result.SignatureVerified = signed
 
-		h := sha256.New()
-		h.Write(conchBin)
-		sum := hex.EncodeToString(h.Sum(nil))
-
-		if !util.JSON {
-			fmt.Fprintf(
-				os.Stderr,
-				"SHA256 sum of downloaded binary: %s\n",
-				sum,
-			)
+		if *checkOnly {
+			if util.JSON {
+				util.JSONOut(result)
+			} else {
+				fmt.Printf("An upgrade from v%s to %s is available on the %s channel\n", util.Version, gh.TagName, ch)
+			}
+			util.Exit(1)
 		}
 
-		if sum == remoteSum {
-			if !util.JSON {
-				fmt.Fprintf(
-					os.Stderr,
-					"SHA256 checksums match\n",
-				)
+		/// Show what's changing
+		if !*yes && !util.JSON {
+			releases := util.GithubReleasesSinceOn(util.SemVersion, ch)
+			shown := make(util.GithubReleases, 0, len(releases))
+			for _, r := range releases {
+				if r.SemVer.LTE(gh.SemVer) {
+					shown = append(shown, r)
+				}
+			}
+			if len(shown) > 0 {
+				printChangelog(shown)
+			}
+			if !util.Confirm(fmt.Sprintf("Upgrade to %s?", gh.TagName)) {
+				util.Bail(errors.New("upgrade cancelled"))
 			}
-		} else {
-			util.Bail(fmt.Errorf(
-				"!!! SHA of downloaded file does not match the provided SHA sum: '%s' != '%s'",
-				sum,
-				remoteSum,
-			))
 		}
 
-		/// Write out the binary
-		binPath, err := os.Executable()
-		if err != nil {
-			util.Bail(err)
-		}
+		installBinary(conchBin, gh.SemVer.String())
+	}
+}
 
-		fullPath, err := filepath.EvalSymlinks(binPath)
-		if err != nil {
-			util.Bail(err)
-		}
-		if !util.JSON {
-			fmt.Fprintf(
-				os.Stderr,
-				"Detected local binary path: %s\n",
-				fullPath,
-			)
-		}
-		existingStat, err := os.Lstat(fullPath)
-		if err != nil {
-			util.Bail(err)
-		}
-		// On sensible operating systems, we can't open and write to our
-		// own binary, because it's in use. We can, however, move a file
-		// into that place.
+// installBinary replaces the running binary with bin, which is version
+func installBinary(bin []byte, version string) {
+	binPath, err := os.Executable()
+	if err != nil {
+		util.Bail(err)
+	}
 
-		newPath := fmt.Sprintf("%s-%s", fullPath, gh.SemVer)
-		if !util.JSON {
-			fmt.Fprintf(
-				os.Stderr,
-				"Writing to temp file '%s'\n",
-				newPath,
-			)
-		}
-		if err := ioutil.WriteFile(newPath, conchBin, existingStat.Mode()); err != nil {
-			util.Bail(err)
-		}
+	fullPath, err := filepath.EvalSymlinks(binPath)
+	if err != nil {
+		util.Bail(err)
+	}
+	if !util.JSON {
+		fmt.Fprintf(
+			os.Stderr,
+			"Detected local binary path: %s\n",
+			fullPath,
+		)
+	}
+	existingStat, err := os.Lstat(fullPath)
+	if err != nil {
+		util.Bail(err)
+	}
+	// On sensible operating systems, we can't open and write to our
+	// own binary, because it's in use. We can, however, move a file
+	// into that place.
 
-		if !util.JSON {
-			fmt.Fprintf(
-				os.Stderr,
-				"Renaming '%s' to '%s'\n",
-				newPath,
-				fullPath,
-			)
-		}
+	newPath := fmt.Sprintf("%s-%s", fullPath, version)
+	if !util.JSON {
+		fmt.Fprintf(
+			os.Stderr,
+			"Writing to temp file '%s'\n",
+			newPath,
+		)
+	}
+	if err := ioutil.WriteFile(newPath, bin, existingStat.Mode()); err != nil {
+		util.Bail(err)
+	}
 
-		if err := os.Rename(newPath, fullPath); err != nil {
-			util.Bail(err)
-		}
+	if !util.JSON {
+		fmt.Fprintf(
+			os.Stderr,
+			"Renaming '%s' to '%s'\n",
+			newPath,
+			fullPath,
+		)
+	}
 
-		if !util.JSON {
-			fmt.Fprintf(
-				os.Stderr,
-				"Successfully upgraded from %s to %s\n",
-				util.SemVersion,
-				gh.SemVer,
-			)
-		}
+	if err := os.Rename(newPath, fullPath); err != nil {
+		util.Bail(err)
+	}
 
+	if !util.JSON {
+		fmt.Fprintf(
+			os.Stderr,
+			"Successfully upgraded from %s to %s\n",
+			util.SemVersion,
+			version,
+		)
 	}
 }
 
@@ -0,0 +1,209 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package update
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// checksumFiles are the names of the files that may hold the SHA256 sums of
+// every binary in a release, one "sum  name" per line
+var checksumFiles = []string{"SHA256SUMS", "sha256sums.txt", "checksums.txt"}
+
+// releaseFiles fetches a file published with a release, by name. found is
+// false if the release has no such file.
+type releaseFiles func(name string) (data []byte, found bool, err error)
+
+func findAsset(gh util.GithubRelease, name string) (util.GithubAsset, bool) {
+	for _, a := range gh.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return util.GithubAsset{}, false
+}
+
+// githubFiles fetches the assets of a Github release. downloadURL is the
+// binary's own URL; its checksum file is looked for next to it even if it
+// isn't listed as an asset.
+func githubFiles(gh util.GithubRelease, downloadURL string) releaseFiles {
+	return func(name string) ([]byte, bool, error) {
+		url := ""
+		if a, ok := findAsset(gh, name); ok {
+			url = a.BrowserDownloadURL
+		} else if name == path.Base(downloadURL)+".sha256" {
+			url = downloadURL + ".sha256"
+		} else {
+			return nil, false, nil
+		}
+
+		data, err := updaterDownloadFile(url)
+		return data, err == nil, err
+	}
+}
+
+// publishedChecksum finds the SHA256 sum that the release publishes for the
+// named binary. Each binary normally has a .sha256 file of its own, but a
+// single file of sums for the whole release is understood too.
+func publishedChecksum(files releaseFiles, release string, name string) (string, error) {
+	data, found, err := files(name + ".sha256")
+	if err != nil {
+		return "", err
+	}
+	if found {
+		// The checksum file looks like "thisisahexstring ./conch-os-arch"
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return "", fmt.Errorf("the checksum file %s.sha256 is empty", name)
+		}
+		return strings.ToLower(fields[0]), nil
+	}
+
+	for _, file := range checksumFiles {
+		data, found, err := files(file)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && path.Base(strings.TrimLeft(fields[1], "*")) == name {
+				return strings.ToLower(fields[0]), nil
+			}
+		}
+		return "", fmt.Errorf("%s in release %s has no sum for %s", file, release, name)
+	}
+
+	return "", fmt.Errorf("release %s has no SHA256 sum for %s", release, name)
+}
+
+// verifyChecksum compares the binary against the sum the release publishes
+func verifyChecksum(files releaseFiles, release string, name string, bin []byte) error {
+	remoteSum, err := publishedChecksum(files, release, name)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.Sum256(bin)
+	sum := hex.EncodeToString(h[:])
+
+	if !util.JSON {
+		fmt.Fprintf(os.Stderr, "Published SHA256 sum: %s\n", remoteSum)
+		fmt.Fprintf(os.Stderr, "SHA256 sum of the new binary: %s\n", sum)
+	}
+
+	if sum != remoteSum {
+		return fmt.Errorf(
+			"!!! SHA of the new binary does not match the provided SHA sum: '%s' != '%s'",
+			sum,
+			remoteSum,
+		)
+	}
+
+	if !util.JSON {
+		fmt.Fprintf(os.Stderr, "SHA256 checksums match\n")
+	}
+	return nil
+}
+
+// verifySignature checks the binary's signature, which is the base64 ASN.1
+// ECDSA signature of its SHA256 sum, published as NAME.sig. It reports
+// whether the signature was checked at all: the shell may have been built
+// without the release key. With the key built in, a release that isn't signed
+// is refused unless allowUnsigned is set, so that publishing an unsigned
+// release isn't a way around the check.
+func verifySignature(files releaseFiles, release string, name string, bin []byte, allowUnsigned bool) (bool, error) {
+	if util.ReleaseSigningKey == "" {
+		if !util.JSON {
+			fmt.Fprintf(os.Stderr, "This shell was built without a release signing key, so signatures can't be checked\n")
+		}
+		return false, nil
+	}
+
+	data, found, err := files(name + ".sig")
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return false, refuseUnsigned(release, name, allowUnsigned)
+	}
+
+	if err := checkSignature(name, bin, data); err != nil {
+		return false, err
+	}
+
+	if !util.JSON {
+		fmt.Fprintf(os.Stderr, "Signature verified\n")
+	}
+	return true, nil
+}
+
+// refuseUnsigned is the error for a release that has no signature for name,
+// or nil if allowUnsigned is set
+func refuseUnsigned(release string, name string, allowUnsigned bool) error {
+	if !allowUnsigned {
+		return fmt.Errorf(
+			"!!! release %s has no signature for %s, but this shell expects signed releases. Use --allow-unsigned to install it anyway",
+			release,
+			name,
+		)
+	}
+	if !util.JSON {
+		fmt.Fprintf(os.Stderr, "Release %s has no signature for %s, installing anyway as --allow-unsigned was given\n", release, name)
+	}
+	return nil
+}
+
+// checkSignature checks that sigData, the base64 ASN.1 ECDSA signature of
+// data's SHA256 sum, was made with the release signing key. name is what the
+// signature is for, for errors.
+func checkSignature(name string, data []byte, sigData []byte) error {
+	keyDER, err := base64.StdEncoding.DecodeString(util.ReleaseSigningKey)
+	if err != nil {
+		return fmt.Errorf("the built in release signing key is broken: %s", err)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(keyDER)
+	if err != nil {
+		return fmt.Errorf("the built in release signing key is broken: %s", err)
+	}
+	key, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("the built in release signing key is not an ECDSA key")
+	}
+
+	sigDER, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(sigData)), ""))
+	if err != nil {
+		return fmt.Errorf("the signature for %s can't be read: %s", name, err)
+	}
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(sigDER, &sig); err != nil {
+		return fmt.Errorf("the signature for %s can't be read: %s", name, err)
+	}
+
+	h := sha256.Sum256(data)
+	if !ecdsa.Verify(key, h[:], sig.R, sig.S) {
+		return fmt.Errorf("!!! the signature for %s does not match the downloaded file", name)
+	}
+	return nil
+}
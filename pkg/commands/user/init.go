@@ -20,26 +20,39 @@ var (
 
 // Init loads up the user commands
 func Init(app *cli.Cli) {
-	app.Command(
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
 		"user u",
 		"Commands for dealing with the current user",
 		func(cmd *cli.Cmd) {
 			// Because login happens in here, we can't VerifyLogin blindly.
 			// Everyone has to do that on their own if they way.
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"profile",
 				"View your Conch profile",
 				getProfile,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
+				"roles",
+				"Show what the current user is allowed to do, as the shell understands it",
+				getRoles,
+			)
+
+			util.Command(
+				cmd,
 				"settings",
 				"Get the settings for the current user",
 				getSettings,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"setting",
 				"Commands for dealing with a single setting for the current user",
 				func(cmd *cli.Cmd) {
@@ -53,19 +66,22 @@ func Init(app *cli.Cli) {
 						SettingName = *settingNameArg
 					}
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get",
 						"Get a setting for the current user",
 						getSetting,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"set",
 						"Set a setting for the current user",
 						setSetting,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"delete",
 						"Delete a setting for the current user",
 						deleteSetting,
@@ -73,34 +89,56 @@ func Init(app *cli.Cli) {
 				},
 			)
 
+			util.Command(
+				cmd,
+				"sessions",
+				"List the current user's login sessions and API tokens",
+				func(cmd *cli.Cmd) {
+					cmd.Before = util.BuildAPIAndVerifyLogin
+					listSessions(cmd)
+
+					util.Command(
+						cmd,
+						"revoke",
+						"End a single login session or API token",
+						revokeSession,
+					)
+				},
+			)
+
 			// The biggest use case for disabling these functions is security,
 			// particularly when it comes to edge automation. It's probably a
 			// bad idea for some automation on a random server to be able to
 			// create and remove tokens.
 			if !util.DisableApiTokenCRUD() {
-				cmd.Command(
+				util.Command(
+					cmd,
 					"tokens",
 					"List API tokens",
 					listTokens,
 				)
 
-				cmd.Command(
+				util.Command(
+					cmd,
 					"token",
 					"Operate on a single token",
 					func(cmd *cli.Cmd) {
-						cmd.Command(
+						util.Command(
+							cmd,
 							"remove del rm",
 							"Remove an API token",
 							removeToken,
 						)
 
-						cmd.Command(
+						util.Command(
+							cmd,
 							"create",
 							"Create an API token",
 							createToken,
 						)
 
-						cmd.Command(
+						util.Command(
+							cmd,
 							"get",
 							"See information about a single API token",
 							getToken,
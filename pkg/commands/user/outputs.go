@@ -0,0 +1,24 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package user
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("user profile", conch.UserProfile{})
+	util.RegisterOutput("user roles", config.RoleCache{})
+	util.RegisterOutput("user settings", map[string]interface{}{})
+	util.RegisterOutput("user sessions", util.Sessions{})
+	util.RegisterOutput("user tokens", conch.UserTokens{})
+	util.RegisterOutput("user token create", conch.NewUserToken{})
+	util.RegisterOutput("user token get", conch.UserToken{})
+}
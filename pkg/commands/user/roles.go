@@ -0,0 +1,71 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package user
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func getRoles(app *cli.Cmd) {
+	var refreshOpt = app.BoolOpt("refresh", false, "Fetch the roles from the API, rather than using the cached ones")
+
+	app.LongDesc = `
+Shows whether the current user is a system admin and their role in each
+workspace they have been added to directly. The shell caches these in the
+profile for an hour, and uses them to stop or warn about commands the user
+isn't allowed to run before the API refuses them. Use --refresh after the
+user's roles change.`
+
+	app.Before = util.BuildAPIAndVerifyLogin
+	app.Action = func() {
+		var roles *config.RoleCache
+		var err error
+		if *refreshOpt {
+			roles, err = util.RefreshRoles()
+		} else {
+			roles, err = util.UserRoles()
+		}
+		if err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(roles)
+			return
+		}
+
+		names := make(map[string]string)
+		if workspaces, err := util.API.GetWorkspaces(); err == nil {
+			for _, w := range workspaces {
+				names[w.ID.String()] = w.Name
+			}
+		}
+
+		ids := make([]string, 0, len(roles.Workspaces))
+		for id := range roles.Workspaces {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return names[ids[i]] < names[ids[j]] })
+
+		fmt.Printf("User: %s\n", roles.Email)
+		fmt.Printf("System admin: %t\n", roles.IsAdmin)
+		fmt.Printf("As of: %s\n\n", util.TimeStr(roles.Fetched))
+
+		header := []string{"Workspace", "ID", "Role"}
+		row := func(i int) []string {
+			return []string{names[ids[i]], ids[i], roles.Workspaces[ids[i]]}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(ids), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
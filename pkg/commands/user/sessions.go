@@ -0,0 +1,53 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package user
+
+import (
+	"fmt"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func listSessions(app *cli.Cmd) {
+	app.LongDesc = `
+Lists the tokens the API knows for the current user, newest use first, along
+with when the user last logged in. Login sessions are those the API started
+for a login; the rest are API tokens. The address each token was last used
+from is shown if the server records it. The API doesn't record user agents.
+
+A single session can be ended with 'conch user sessions revoke NAME', rather
+than revoking every login at once.`
+	sorting := util.SortFlags(app, "sessions")
+
+	app.Action = func() {
+		profile, err := util.API.GetUserProfile()
+		if err != nil {
+			util.Bail(err)
+		}
+		tokens, err := util.API.GetMyTokens()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		util.RenderSessions(util.NewSessions(profile.LastLogin, tokens), sorting)
+	}
+}
+
+func revokeSession(app *cli.Cmd) {
+	var nameArg = app.StringArg("NAME", "", "The name of the session, as 'conch user sessions' lists it")
+	app.Spec = "NAME"
+
+	app.Action = func() {
+		if err := util.API.DeleteMyToken(*nameArg); err != nil {
+			util.Bail(err)
+		}
+		if !util.JSON {
+			fmt.Printf("Session '%s' revoked\n", *nameArg)
+		}
+	}
+}
@@ -149,36 +149,45 @@ func deleteSetting(app *cli.Cmd) {
 
 func listTokens(app *cli.Cmd) {
 	app.Before = util.BuildAPIAndVerifyLogin
+	sorting := util.SortFlags(app, "tokens")
 
 	app.Action = func() {
 		tokens, err := util.API.GetMyTokens()
 		if err != nil {
 			util.Bail(err)
 		}
-		if util.JSON {
-			util.JSONOut(tokens)
-			return
-		}
-
-		sort.Sort(tokens)
-
-		table := util.GetMarkdownTable()
-		table.SetHeader([]string{"Name", "Created", "Last Used"})
 
-		for _, t := range tokens {
+		header := []string{"Name", "Created", "Last Used"}
+		row := func(i int) []string {
+			t := tokens[i]
 			timeStr := ""
 			if !t.LastUsed.IsZero() {
 				timeStr = util.TimeStr(t.LastUsed)
 			}
 
-			table.Append([]string{
+			return []string{
 				t.Name,
 				util.TimeStr(t.Created),
 				timeStr,
-			})
+			}
+		}
+
+		if util.JSON {
+			if err := sorting.Sort(tokens, header, row); err != nil {
+				util.Bail(err)
+			}
+			util.JSONOut(tokens)
+			return
+		}
+
+		sort.Sort(tokens)
+		if err := sorting.Sort(tokens, header, row); err != nil {
+			util.Bail(err)
 		}
 
-		table.Render()
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(tokens), row); err != nil {
+			util.Bail(err)
+		}
 	}
 }
 
@@ -0,0 +1,181 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// explanation is what 'validation explain' prints with --json
+type explanation struct {
+	conch.Validation
+	Runbook  string            `json:"runbook,omitempty"`
+	Versions conch.Validations `json:"versions"`
+}
+
+// findValidation finds the versions of the validation called name, or with
+// the ID or short ID name, newest first. The current version is the newest
+// active one.
+func findValidation(vs conch.Validations, name string) (conch.Validation, conch.Validations, error) {
+	found := make(conch.Validations, 0)
+	for _, v := range vs {
+		if strings.EqualFold(v.Name, name) {
+			found = append(found, v)
+		}
+	}
+
+	if len(found) == 0 {
+		ids := make([]uuid.UUID, len(vs))
+		for i, v := range vs {
+			ids[i] = v.ID
+		}
+		id, err := uuid.FromString(name)
+		if err != nil {
+			id, err = util.FindShortUUID(name, ids)
+		}
+		if err == nil {
+			for _, v := range vs {
+				if uuid.Equal(v.ID, id) {
+					return findValidation(vs, v.Name)
+				}
+			}
+		}
+		return conch.Validation{}, nil, fmt.Errorf(
+			"could not find a validation named '%s'. 'conch validations --deactivated' lists them all",
+			name,
+		)
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Version > found[j].Version })
+
+	current := found[0]
+	for _, v := range found {
+		if v.Deactivated.IsZero() {
+			current = v
+			break
+		}
+	}
+	return current, found, nil
+}
+
+// failedValidations lists the validations that have a result in rs that
+// didn't pass, in name order. Results of validations that aren't in vs are
+// left out.
+func failedValidations(rs []conch.ValidationResult, vs conch.Validations) conch.Validations {
+	byID := make(map[uuid.UUID]conch.Validation)
+	for _, v := range vs {
+		byID[v.ID] = v
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	failed := make(conch.Validations, 0)
+	for _, r := range rs {
+		if r.Status == "pass" || seen[r.ValidationID] {
+			continue
+		}
+		seen[r.ValidationID] = true
+		if v, ok := byID[r.ValidationID]; ok {
+			failed = append(failed, v)
+		}
+	}
+	sort.Sort(failed)
+	return failed
+}
+
+// renderFailures prints what each of the failed validations checks, and its
+// runbook, so that the way to fix a failure is right next to it
+func renderFailures(failed conch.Validations) {
+	if len(failed) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Failed validations:")
+	for _, v := range failed {
+		fmt.Printf("  - %s (v%d): %s\n", v.Name, v.Version, v.Description)
+		if runbook := util.RunbookURL(v.Name, v.Version); runbook != "" {
+			fmt.Printf("    Runbook: %s\n", runbook)
+		}
+	}
+}
+
+func explainValidation(cmd *cli.Cmd) {
+	var nameArg = cmd.StringArg("NAME", "", "The name of the validation. May be left out if an ID was given to 'validation'")
+	cmd.Spec = "[NAME]"
+
+	cmd.LongDesc = `
+Shows what a validation checks, and the runbook the profile has for it, if
+any. Runbooks are set with 'conch profile set runbook'.`
+
+	cmd.Action = func() {
+		vs, err := util.API.GetValidations()
+		if err != nil {
+			util.Bail(err)
+		}
+
+		name := *nameArg
+		if name == "" {
+			if uuid.Equal(validationUUID, uuid.UUID{}) {
+				util.Bail(errors.New("please give the name of a validation"))
+			}
+			name = validationUUID.String()
+		}
+
+		current, versions, err := findValidation(vs, name)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		e := explanation{
+			Validation: current,
+			Runbook:    util.RunbookURL(current.Name, current.Version),
+			Versions:   versions,
+		}
+
+		if util.JSON {
+			util.JSONOut(e)
+			return
+		}
+
+		fmt.Printf("Name:        %s\n", e.Name)
+		fmt.Printf("Version:     %d\n", e.Version)
+		fmt.Printf("ID:          %s\n", e.ID)
+		if !e.Deactivated.IsZero() {
+			fmt.Printf("Deactivated: %s\n", util.TimeStr(e.Deactivated))
+		}
+		fmt.Printf("Description: %s\n", e.Description)
+
+		if e.Runbook != "" {
+			fmt.Printf("Runbook:     %s\n", e.Runbook)
+		} else {
+			fmt.Println("Runbook:     none. Use 'conch profile set runbook' to add one")
+		}
+
+		if len(versions) > 1 {
+			fmt.Println()
+			fmt.Println("Other versions:")
+			for _, v := range versions {
+				if uuid.Equal(v.ID, e.ID) {
+					continue
+				}
+				state := "active"
+				if !v.Deactivated.IsZero() {
+					state = "deactivated " + util.TimeStr(v.Deactivated)
+				}
+				fmt.Printf("  - v%d  %s  %s\n", v.Version, v.ID, state)
+			}
+		}
+	}
+}
@@ -18,22 +18,29 @@ var validationPlanUUID uuid.UUID
 
 // Init loads up the commands dealing with validations and validation plans
 func Init(app *cli.Cli) {
-	app.Command(
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
 		"validations vs",
 		"List available validations",
 		getValidations,
 	)
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"validation v",
 		"Commands for operating on a validation",
 		func(cmd *cli.Cmd) {
 
 			var validationID = cmd.StringArg("ID", "", "The UUID of the validation")
 
-			cmd.Spec = "ID"
+			cmd.Spec = "[ID]"
 
 			cmd.Before = func() {
 				util.BuildAPIAndVerifyLogin()
+				if *validationID == "" {
+					return
+				}
 				var err error
 				validationUUID, err = util.MagicValidationID(*validationID)
 				if err != nil {
@@ -41,28 +48,37 @@ func Init(app *cli.Cli) {
 				}
 			}
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"test",
 				"Test a validation against a given device with input data from STDIN",
 				testValidation,
 			)
+
+			util.Command(
+				cmd,
+				"explain",
+				"Show what a validation checks, and where its runbook is",
+				explainValidation,
+			)
 		},
 	)
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"validation-plans vps",
 		"Manage validation plans",
 		func(cmd *cli.Cmd) {
-			cmd.Before = func() {
-				util.BuildAPIAndVerifyLogin()
-			}
-			cmd.Command(
+			cmd.Before = util.BuildAPIRequiringFeature("validation-plans")
+			util.Command(
+				cmd,
 				"get",
 				"List all active validation plans",
 				getValidationPlans,
 			)
 		},
 	)
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"validation-plan vp",
 		"Commands for operating on a validation plan",
 		func(cmd *cli.Cmd) {
@@ -73,6 +89,7 @@ func Init(app *cli.Cli) {
 
 			cmd.Before = func() {
 				util.BuildAPIAndVerifyLogin()
+				util.RequireFeature("validation-plans")
 				var err error
 				validationPlanUUID, err = util.MagicValidationPlanID(*validationPlanID)
 				if err != nil {
@@ -81,26 +98,30 @@ func Init(app *cli.Cli) {
 				}
 			}
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"get",
 				"Get details of a validation plan",
 				getValidationPlan,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"validations",
 				"Show a validation plan's associated validations",
 				showValidationPlanValidations,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"test",
 				"Test a validation plan against a given device with input data from STDIN",
 				testValidationPlan,
 			)
 		},
 	)
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"validation-states vss",
 		"Commands for validation states",
 		func(cmd *cli.Cmd) {
@@ -109,7 +130,8 @@ func Init(app *cli.Cli) {
 				util.BuildAPIAndVerifyLogin()
 			}
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"device",
 				"Get validation states for a device",
 				getDeviceValidationStates,
@@ -0,0 +1,24 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package validation
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("validations", conch.Validations{})
+	util.RegisterOutput("validation test", validationResults{})
+	util.RegisterOutput("validation explain", explanation{})
+	util.RegisterOutput("validation-plans get", validationPlans{})
+	util.RegisterOutput("validation-plan get", conch.ValidationPlan{})
+	util.RegisterOutput("validation-plan validations", conch.Validations{})
+	util.RegisterOutput("validation-plan test", validationResults{})
+	util.RegisterOutput("validation-states device", validationStates{})
+}
@@ -108,9 +108,14 @@ func testValidationPlan(app *cli.Cmd) {
 			util.Bail(errors.New("no device report provided on stdin"))
 		}
 
+		serial, err := util.MagicDeviceID(*deviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
 		var validationResults validationResults
 		validationResults, err = util.API.RunDeviceValidationPlan(
-			*deviceSerial,
+			serial,
 			validationPlanUUID,
 			body,
 		)
@@ -122,6 +127,6 @@ func testValidationPlan(app *cli.Cmd) {
 			util.JSONOut(validationResults)
 			return
 		}
-		validationResults.renderTable()
+		validationResults.renderTable(resultValidations())
 	}
 }
@@ -18,7 +18,7 @@ import (
 
 type validationStates []conch.ValidationState
 
-func (vs validationStates) renderTable(validationPlans []conch.ValidationPlan, validations []conch.Validation) {
+func (vs validationStates) renderTable(validationPlans []conch.ValidationPlan, validations conch.Validations) {
 	table := util.GetMarkdownTable()
 
 	planNameMap := make(map[uuid.UUID]string)
@@ -71,6 +71,12 @@ func (vs validationStates) renderTable(validationPlans []conch.ValidationPlan, v
 	}
 
 	table.Render()
+
+	results := make([]conch.ValidationResult, 0)
+	for _, v := range vs {
+		results = append(results, v.Results...)
+	}
+	renderFailures(failedValidations(results, validations))
 }
 
 func getDeviceValidationStates(app *cli.Cmd) {
@@ -79,8 +85,13 @@ func getDeviceValidationStates(app *cli.Cmd) {
 	app.Spec = "DEVICE_ID"
 
 	app.Action = func() {
+		serial, err := util.MagicDeviceID(*deviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
 		var validationStates validationStates
-		validationStates, err := util.API.DeviceValidationStates(*deviceSerial)
+		validationStates, err = util.API.DeviceValidationStates(serial)
 		if err != nil {
 			util.Bail(err)
 		}
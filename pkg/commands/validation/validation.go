@@ -9,6 +9,7 @@ package validation
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"sort"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/jawher/mow.cli"
 	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
 	"github.com/joyent/conch-shell/pkg/util"
 )
 
@@ -57,16 +59,37 @@ func renderTableValidations(vs conch.Validations, showDeactivated bool) {
 
 type validationResults []conch.ValidationResult
 
-func (rs validationResults) renderTable() {
+// renderTable lists the results, followed by what each validation that
+// didn't pass checks, and its runbook
+func (rs validationResults) renderTable(validations conch.Validations) {
 	table := util.GetMarkdownTable()
 
-	table.SetHeader([]string{"Status", "Category", "Message", "Hint", "Component ID"})
+	names := make(map[uuid.UUID]string)
+	for _, v := range validations {
+		names[v.ID] = v.Name
+	}
+
+	table.SetHeader([]string{"Validation", "Status", "Category", "Message", "Hint", "Component ID"})
 
 	for _, r := range rs {
-		table.Append([]string{r.Status, r.Category, r.Message, r.Hint, r.ComponentID})
+		table.Append([]string{names[r.ValidationID], r.Status, r.Category, r.Message, r.Hint, r.ComponentID})
 	}
 
 	table.Render()
+
+	renderFailures(failedValidations(rs, validations))
+}
+
+// resultValidations fetches the validations, to explain the results with.
+// The results are worth showing even if that fails, so the error is only
+// mentioned.
+func resultValidations() conch.Validations {
+	vs, err := util.API.GetValidations()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not fetch the validations to explain the results with: %s\n", err)
+		return nil
+	}
+	return vs
 }
 
 func getValidations(app *cli.Cmd) {
@@ -104,6 +127,10 @@ func testValidation(app *cli.Cmd) {
 	app.Spec = "DEVICE_ID"
 
 	app.Action = func() {
+		if uuid.Equal(validationUUID, uuid.UUID{}) {
+			util.Bail(errors.New("please give the ID of the validation to test, eg 'conch validation ID test DEVICE_ID'"))
+		}
+
 		bodyBytes, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
 			util.Bail(err)
@@ -114,9 +141,14 @@ func testValidation(app *cli.Cmd) {
 			util.Bail(errors.New("no device report provided on stdin"))
 		}
 
+		serial, err := util.MagicDeviceID(*deviceSerial)
+		if err != nil {
+			util.Bail(err)
+		}
+
 		var validationResults validationResults
 		validationResults, err = util.API.RunDeviceValidation(
-			*deviceSerial,
+			serial,
 			validationUUID,
 			body,
 		)
@@ -128,6 +160,6 @@ func testValidation(app *cli.Cmd) {
 			util.JSONOut(validationResults)
 			return
 		}
-		validationResults.renderTable()
+		validationResults.renderTable(resultValidations())
 	}
 }
@@ -0,0 +1,271 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// assetTagRow is one serial to asset tag mapping from the CSV file
+type assetTagRow struct {
+	Line     int    `json:"line"`
+	Serial   string `json:"serial"`
+	AssetTag string `json:"asset_tag"`
+	Problem  string `json:"problem,omitempty"`
+}
+
+// assetTagColumns works out which columns hold the serial and the asset tag.
+// A header row is recognized by its column names; without one, the serial is
+// the first column and the asset tag the second.
+func assetTagColumns(first []string) (serial int, tag int, header bool) {
+	serial, tag = -1, -1
+	for i, name := range first {
+		switch strings.ToLower(strings.Replace(strings.TrimSpace(name), " ", "_", -1)) {
+		case "serial", "serial_number", "device", "device_id", "id":
+			serial = i
+		case "asset_tag", "asset", "tag":
+			tag = i
+		}
+	}
+	if serial >= 0 && tag >= 0 {
+		return serial, tag, true
+	}
+	return 0, 1, false
+}
+
+func readAssetTagCSV(r io.Reader) ([]assetTagRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("the CSV file is empty")
+	}
+
+	serialCol, tagCol, header := assetTagColumns(records[0])
+	if header {
+		records = records[1:]
+	}
+
+	// Line numbers match what a spreadsheet shows
+	offset := 1
+	if header {
+		offset = 2
+	}
+
+	rows := make([]assetTagRow, 0, len(records))
+	for i, rec := range records {
+		row := assetTagRow{Line: i + offset}
+		if serialCol < len(rec) {
+			row.Serial = strings.TrimSpace(rec[serialCol])
+		}
+		if tagCol < len(rec) {
+			row.AssetTag = strings.TrimSpace(rec[tagCol])
+		}
+		if row.Serial == "" && row.AssetTag == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func importAssetTags(app *cli.Cmd) {
+	var (
+		filePathArg    = app.StringArg("CSV", "-", "Path to a CSV file of serials and asset tags. '-' indicates STDIN")
+		skipInvalidOpt = app.BoolOpt("skip-invalid", false, "Apply the rows that pass validation and skip the rest, instead of making no changes at all")
+		planOpts       = util.NewPlanOpts(app)
+	)
+	app.Spec = "[OPTIONS] CSV"
+
+	app.LongDesc = `
+Sets the asset tags of many devices in the workspace at once, from a CSV file
+with a serial and an asset tag on each line. If the first line names the
+columns, for instance 'serial,asset_tag', the columns may be in any order and
+other columns are ignored. Otherwise the serial must be the first column and
+the asset tag the second.
+
+Before anything is changed, every row is checked for:
+
+    * a serial that isn't a device in the workspace
+    * a serial that is listed more than once with different asset tags
+    * an asset tag given to more than one device in the file
+    * an asset tag that another device in the workspace already has
+    * a missing serial or asset tag
+
+If any row has a problem, the problems are listed and nothing is changed,
+unless --skip-invalid is given. Use --dry-run to see the changes without
+making them.`
+
+	app.Action = func() {
+		util.WarnWorkspaceRole(WorkspaceUUID, "rw", "Setting asset tags")
+
+		var (
+			in  io.Reader = os.Stdin
+			err error
+		)
+		if *filePathArg != "-" {
+			f, err := os.Open(*filePathArg)
+			if err != nil {
+				util.Bail(err)
+			}
+			defer f.Close()
+			in = f
+		}
+
+		rows, err := readAssetTagCSV(in)
+		if err != nil {
+			util.Bail(err)
+		}
+		if len(rows) == 0 {
+			util.Bail(errors.New("no asset tags found in the CSV file"))
+		}
+
+		devices, err := util.API.GetWorkspaceDevices(WorkspaceUUID, false, "", "", "")
+		if err != nil {
+			util.Bail(err)
+		}
+
+		// Serials are matched without regard to case
+		current := make(map[string]string)
+		ids := make(map[string]string)
+		owners := make(map[string]string)
+		for _, d := range devices {
+			current[strings.ToLower(d.ID)] = d.AssetTag
+			ids[strings.ToLower(d.ID)] = d.ID
+			if d.AssetTag != "" {
+				owners[d.AssetTag] = d.ID
+			}
+		}
+
+		tagsBySerial := make(map[string]map[string]bool)
+		serialsByTag := make(map[string]map[string]bool)
+		for _, r := range rows {
+			serial := strings.ToLower(r.Serial)
+			if tagsBySerial[serial] == nil {
+				tagsBySerial[serial] = make(map[string]bool)
+			}
+			tagsBySerial[serial][r.AssetTag] = true
+			if serialsByTag[r.AssetTag] == nil {
+				serialsByTag[r.AssetTag] = make(map[string]bool)
+			}
+			serialsByTag[r.AssetTag][serial] = true
+		}
+
+		// The file moves a tag off a device if it gives that device a
+		// different one
+		movedOff := func(serial string) bool {
+			tags, ok := tagsBySerial[strings.ToLower(serial)]
+			return ok && !tags[current[strings.ToLower(serial)]]
+		}
+
+		givesUp := func(r assetTagRow) bool {
+			tag := current[strings.ToLower(r.Serial)]
+			return tag != "" && tag != r.AssetTag && len(serialsByTag[tag]) > 0
+		}
+
+		problems := make([]assetTagRow, 0)
+		valid := make([]assetTagRow, 0)
+		seen := make(map[string]bool)
+		for _, r := range rows {
+			serial := strings.ToLower(r.Serial)
+			_, known := current[serial]
+
+			switch {
+			case r.Serial == "":
+				r.Problem = "missing serial"
+			case r.AssetTag == "":
+				r.Problem = "missing asset tag"
+			case !known:
+				r.Problem = "no such device in the workspace"
+			case len(tagsBySerial[serial]) > 1:
+				r.Problem = "serial is listed more than once with different asset tags"
+			case len(serialsByTag[r.AssetTag]) > 1:
+				r.Problem = "asset tag is given to more than one device"
+			default:
+				owner, taken := owners[r.AssetTag]
+				if taken && !strings.EqualFold(owner, r.Serial) && !movedOff(owner) {
+					r.Problem = "asset tag already belongs to " + owner
+				}
+			}
+
+			if r.Problem != "" {
+				problems = append(problems, r)
+				continue
+			}
+			if seen[serial] {
+				continue
+			}
+			seen[serial] = true
+			valid = append(valid, r)
+		}
+
+		if len(problems) > 0 {
+			if util.JSON {
+				if !*skipInvalidOpt {
+					util.JSONOut(problems)
+					util.Exit(1)
+				}
+				fmt.Fprintf(os.Stderr, "Skipping %d of %d rows with problems\n", len(problems), len(rows))
+			} else {
+				fmt.Printf("%d of %d rows have problems:\n\n", len(problems), len(rows))
+				table := util.GetMarkdownTable()
+				table.SetHeader([]string{"Line", "Serial", "Asset Tag", "Problem"})
+				for _, p := range problems {
+					table.Append([]string{strconv.Itoa(p.Line), p.Serial, p.AssetTag, p.Problem})
+				}
+				table.Render()
+				fmt.Println()
+
+				if !*skipInvalidOpt {
+					util.Bail(errors.New("no changes were made. Fix the rows above or use --skip-invalid"))
+				}
+			}
+		}
+
+		// Devices giving up a tag that another device is taking go first
+		sort.SliceStable(valid, func(i, j int) bool {
+			return givesUp(valid[i]) && !givesUp(valid[j])
+		})
+
+		plan := util.NewPlan()
+		for _, r := range valid {
+			serial, tag := ids[strings.ToLower(r.Serial)], r.AssetTag
+			before := current[strings.ToLower(serial)]
+			if before == tag {
+				continue
+			}
+
+			action := util.PlanUpdate
+			detail := before + " -> " + tag
+			if before == "" {
+				action = util.PlanCreate
+				detail = tag
+			}
+
+			plan.Add(action, "asset tag", serial, detail, func() error {
+				return util.API.SetDeviceAssetTag(serial, tag)
+			})
+		}
+
+		plan.Run(planOpts)
+	}
+}
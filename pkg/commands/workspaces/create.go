@@ -23,6 +23,8 @@ func createSubWorkspace(app *cli.Cmd) {
 	app.Spec = "NAME [OPTIONS]"
 
 	app.Action = func() {
+		util.WarnWorkspaceRole(WorkspaceUUID, "admin", "Creating a subworkspace")
+
 		sub := conch.Workspace{
 			Name:        *nameArg,
 			Description: *descriptionOpt,
@@ -0,0 +1,98 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"sort"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// decommissionedDevice is a device decommissioned out of a workspace, along
+// with what 'conch device decommission' recorded about it
+type decommissionedDevice struct {
+	ID             string `json:"id"`
+	AssetTag       string `json:"asset_tag"`
+	Phase          string `json:"phase"`
+	Decommissioned string `json:"decommissioned"`
+	By             string `json:"decommissioned_by"`
+	LastLocation   string `json:"last_location"`
+	Note           string `json:"note"`
+}
+
+func getDecommissioned(app *cli.Cmd) {
+	sorting := util.SortFlags(app, "decommissioned")
+
+	app.LongDesc = `
+Lists the devices that 'conch device decommission' took out of this
+workspace. Decommissioned devices are no longer in a rack, so they are found
+by their decommission.workspace setting rather than the workspace's racks.`
+
+	app.Action = func() {
+		devices, err := util.API.GetDevicesBySetting(
+			conch.DecommissionWorkspaceSetting,
+			WorkspaceUUID.String(),
+		)
+		if err != nil && err != conch.ErrDataNotFound {
+			util.Bail(err)
+		}
+		sort.Sort(devices)
+
+		list := make([]decommissionedDevice, 0, len(devices))
+		for _, d := range devices {
+			settings, err := util.API.GetDeviceSettings(d.ID)
+			if err != nil {
+				util.Bail(err)
+			}
+			list = append(list, decommissionedDevice{
+				ID:             d.ID,
+				AssetTag:       d.AssetTag,
+				Phase:          d.Phase,
+				Decommissioned: settings[conch.DecommissionDateSetting],
+				By:             settings[conch.DecommissionUserSetting],
+				LastLocation:   settings[conch.DecommissionLocationSetting],
+				Note:           settings[conch.DecommissionNoteSetting],
+			})
+		}
+
+		if util.JSON {
+			util.JSONOut(list)
+			return
+		}
+
+		header := []string{
+			"ID",
+			"Asset Tag",
+			"Phase",
+			"Decommissioned",
+			"By",
+			"Last Location",
+			"Note",
+		}
+		row := func(i int) []string {
+			d := list[i]
+			return []string{
+				d.ID,
+				d.AssetTag,
+				d.Phase,
+				d.Decommissioned,
+				d.By,
+				d.LastLocation,
+				d.Note,
+			}
+		}
+
+		if err := sorting.Sort(list, header, row); err != nil {
+			util.Bail(err)
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(list), row, "Phase"); err != nil {
+			util.Bail(err)
+		}
+	}
+}
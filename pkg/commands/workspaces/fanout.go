@@ -0,0 +1,155 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// workspaceDevice is a device listed as part of a run across several
+// workspaces
+type workspaceDevice struct {
+	WorkspaceID   uuid.UUID `json:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name"`
+	conch.Device
+}
+
+// getDevicesAcross lists the devices of every workspace asked for with
+// --all-workspaces or --workspaces, and merges them into one listing with a
+// workspace column
+func getDevicesAcross(
+	fetch func(uuid.UUID) (conch.Devices, error),
+	idsOnly bool,
+	fullOutput bool,
+	sorting *util.Sorting,
+) {
+	workspaces, err := fanOut.Resolve()
+	if err != nil {
+		util.Bail(err)
+	}
+
+	results := make([]conch.Devices, len(workspaces))
+	err = util.FanOut(workspaces, func(i int, ws conch.Workspace) error {
+		devices, err := fetch(ws.ID)
+		if err != nil {
+			return err
+		}
+		results[i] = devices
+		return nil
+	})
+	if err != nil {
+		util.Bail(err)
+	}
+
+	merged := make([]workspaceDevice, 0)
+	for i, devices := range results {
+		for _, d := range devices {
+			merged = append(merged, workspaceDevice{
+				WorkspaceID:   workspaces[i].ID,
+				WorkspaceName: workspaces[i].Name,
+				Device:        d,
+			})
+		}
+	}
+
+	if idsOnly {
+		if util.JSON {
+			type idOnly struct {
+				WorkspaceID   uuid.UUID `json:"workspace_id"`
+				WorkspaceName string    `json:"workspace_name"`
+				ID            string    `json:"id"`
+			}
+			ids := make([]idOnly, 0, len(merged))
+			for _, d := range merged {
+				ids = append(ids, idOnly{d.WorkspaceID, d.WorkspaceName, d.ID})
+			}
+			util.JSONOut(ids)
+			return
+		}
+		for _, d := range merged {
+			fmt.Printf("%s\t%s\n", d.WorkspaceName, d.ID)
+		}
+		return
+	}
+
+	if fullOutput {
+		devices := make([]conch.Device, len(merged))
+		for i, d := range merged {
+			devices[i] = d.Device
+		}
+		devices, err = util.FillDeviceLocations(devices)
+		if err != nil {
+			util.Bail(err)
+		}
+		for i := range merged {
+			merged[i].Device = devices[i]
+		}
+	}
+
+	header := []string{
+		"Workspace",
+		"ID",
+		"Asset Tag",
+		"Last Seen",
+		"Health",
+		"Validated",
+		"Phase",
+	}
+	if fullOutput {
+		header = append(header, "AZ", "Rack", "RU")
+	}
+
+	row := func(i int) []string {
+		d := merged[i]
+
+		lastSeen := ""
+		if !d.LastSeen.IsZero() {
+			lastSeen = util.TimeStr(d.LastSeen.UTC())
+		}
+		validated := ""
+		if !d.Validated.IsZero() {
+			validated = util.TimeStr(d.Validated.UTC())
+		}
+
+		r := []string{
+			d.WorkspaceName,
+			d.ID,
+			d.AssetTag,
+			lastSeen,
+			d.Health,
+			validated,
+			d.Phase,
+		}
+		if !fullOutput {
+			return r
+		}
+
+		ru := ""
+		if d.Location.RackUnitStart != 0 {
+			ru = strconv.Itoa(d.Location.RackUnitStart)
+		}
+		return append(r, d.Location.Room.AZ, d.Location.Rack.Name, ru)
+	}
+
+	if err := sorting.Sort(merged, header, row); err != nil {
+		util.Bail(err)
+	}
+
+	if util.JSON {
+		util.JSONOut(merged)
+		return
+	}
+
+	if err := util.RenderTable(util.GetMarkdownTable(), header, len(merged), row, "Workspace", "Health", "Phase"); err != nil {
+		util.Bail(err)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"fmt"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// sourceDevice is a device listed as part of a run across several Conch
+// instances. Source is the profile it was found through.
+type sourceDevice struct {
+	Source        string    `json:"source"`
+	WorkspaceID   uuid.UUID `json:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name"`
+	conch.Device
+}
+
+// getDevicesFederated lists the devices of the workspace of every profile
+// asked for with --federate, and merges them into one listing with a source
+// column
+func getDevicesFederated(
+	fetch func(*conch.Conch, uuid.UUID) (conch.Devices, error),
+	idsOnly bool,
+	sorting *util.Sorting,
+) {
+	sources, err := federate.Resolve()
+	if err != nil {
+		util.Bail(err)
+	}
+	for _, s := range sources {
+		if uuid.Equal(s.Profile.WorkspaceUUID, uuid.UUID{}) {
+			util.Bail(fmt.Errorf(
+				"profile %s has no workspace. Use 'conch -p %s profile set workspace' to pick one",
+				s.Profile.Name,
+				s.Profile.Name,
+			))
+		}
+	}
+
+	results := make([]conch.Devices, len(sources))
+	err = util.Federate(sources, func(i int, s util.Source) error {
+		devices, err := fetch(s.API, s.Profile.WorkspaceUUID)
+		if err != nil {
+			return err
+		}
+		results[i] = devices
+		return nil
+	})
+	if err != nil {
+		util.Bail(err)
+	}
+
+	merged := make([]sourceDevice, 0)
+	for i, devices := range results {
+		for _, d := range devices {
+			merged = append(merged, sourceDevice{
+				Source:        sources[i].Profile.Name,
+				WorkspaceID:   sources[i].Profile.WorkspaceUUID,
+				WorkspaceName: sources[i].Profile.WorkspaceName,
+				Device:        d,
+			})
+		}
+	}
+
+	if idsOnly {
+		if util.JSON {
+			type idOnly struct {
+				Source string `json:"source"`
+				ID     string `json:"id"`
+			}
+			ids := make([]idOnly, 0, len(merged))
+			for _, d := range merged {
+				ids = append(ids, idOnly{d.Source, d.ID})
+			}
+			util.JSONOut(ids)
+			return
+		}
+		for _, d := range merged {
+			fmt.Printf("%s\t%s\n", d.Source, d.ID)
+		}
+		return
+	}
+
+	header := []string{
+		"Source",
+		"ID",
+		"Asset Tag",
+		"Last Seen",
+		"Health",
+		"Validated",
+		"Phase",
+	}
+
+	row := func(i int) []string {
+		d := merged[i]
+
+		lastSeen := ""
+		if !d.LastSeen.IsZero() {
+			lastSeen = util.TimeStr(d.LastSeen.UTC())
+		}
+		validated := ""
+		if !d.Validated.IsZero() {
+			validated = util.TimeStr(d.Validated.UTC())
+		}
+
+		return []string{
+			d.Source,
+			d.ID,
+			d.AssetTag,
+			lastSeen,
+			d.Health,
+			validated,
+			d.Phase,
+		}
+	}
+
+	if err := sorting.Sort(merged, header, row); err != nil {
+		util.Bail(err)
+	}
+
+	if util.JSON {
+		util.JSONOut(merged)
+		return
+	}
+
+	if err := util.RenderTable(util.GetMarkdownTable(), header, len(merged), row, "Source", "Health", "Phase"); err != nil {
+		util.Bail(err)
+	}
+}
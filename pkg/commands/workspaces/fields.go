@@ -0,0 +1,102 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+func listFields(cmd *cli.Cmd) {
+	cmd.LongDesc = `
+Lists the custom fields defined for the workspace's devices. Their values are
+set with 'conch device ID fields set', and shown as columns of device listings
+with --field.
+
+The API has nowhere to keep workspace settings, so the definitions are kept in
+the active profile.`
+
+	cmd.Action = func() {
+		fields := util.WorkspaceCustomFields(WorkspaceUUID)
+
+		if util.JSON {
+			util.JSONOut(fields)
+			return
+		}
+
+		if len(fields) == 0 {
+			fmt.Println("No custom fields are defined for this workspace")
+			return
+		}
+
+		header := []string{"Name", "Type", "Required"}
+		row := func(i int) []string {
+			f := fields[i]
+			return []string{f.Name, f.Type, strconv.FormatBool(f.Required)}
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(fields), row); err != nil {
+			util.Bail(err)
+		}
+	}
+}
+
+func defineField(cmd *cli.Cmd) {
+	var (
+		nameArg     = cmd.StringArg("NAME", "", "The name of the field")
+		typeOpt     = cmd.StringOpt("type t", conch.CustomFieldString, "The type of the field: "+strings.Join(conch.CustomFieldTypes, ", "))
+		requiredOpt = cmd.BoolOpt("required", false, "Every device must have a value for the field")
+	)
+	cmd.Spec = "NAME [OPTIONS]"
+
+	cmd.LongDesc = `
+Defines a custom field for the workspace's devices, or changes the definition
+of an existing one, eg:
+
+    conch workspace ws fields define cost_center --type number --required
+
+Values that have already been set are not checked against a changed
+definition.`
+
+	cmd.Action = func() {
+		f := conch.CustomField{
+			Name:     strings.TrimSpace(*nameArg),
+			Type:     strings.ToLower(*typeOpt),
+			Required: *requiredOpt,
+		}
+		if err := f.Validate(); err != nil {
+			util.Bail(err)
+		}
+
+		util.SetWorkspaceCustomField(WorkspaceUUID, f)
+		util.WriteConfig()
+
+		if util.JSON {
+			util.JSONOut(f)
+		}
+	}
+}
+
+func removeField(cmd *cli.Cmd) {
+	var nameArg = cmd.StringArg("NAME", "", "The name of the field")
+	cmd.Spec = "NAME"
+
+	cmd.LongDesc = `
+Removes the definition of a custom field. The values already set on devices
+are left in their settings.`
+
+	cmd.Action = func() {
+		if !util.RemoveWorkspaceCustomField(WorkspaceUUID, *nameArg) {
+			util.Bail(fmt.Errorf("no custom field named '%s' is defined for this workspace", *nameArg))
+		}
+		util.WriteConfig()
+	}
+}
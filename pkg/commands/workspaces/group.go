@@ -0,0 +1,102 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// deviceGroupings are what 'workspace devices --group-by' accepts, and the
+// device fields each needs on top of the ones that are displayed
+var deviceGroupings = map[string][]string{
+	"rack":    {"rack_id"},
+	"health":  nil,
+	"phase":   nil,
+	"product": {"hardware_product"},
+}
+
+// checkGrouping bails unless by is something devices can be grouped by, and
+// returns it normalized
+func checkGrouping(by string) string {
+	by = strings.ToLower(by)
+	if _, ok := deviceGroupings[by]; !ok {
+		util.Bail(fmt.Errorf("devices can't be grouped by '%s'. Use rack, health, phase, or product", by))
+	}
+	return by
+}
+
+// groupFields adds the fields grouping by by needs to fields. A nil fields
+// asks for the whole device, and is left alone.
+func groupFields(fields []string, by string) []string {
+	if fields == nil {
+		return nil
+	}
+	return append(fields, deviceGroupings[by]...)
+}
+
+// deviceGrouper returns the name of the group a device of workspaceID falls
+// in, grouping by by. Racks and products are looked up so that the groups
+// have names rather than IDs.
+func deviceGrouper(workspaceID uuid.UUID, by string) func(conch.Device) string {
+	switch by {
+	case "health":
+		return func(d conch.Device) string { return d.Health }
+
+	case "phase":
+		return func(d conch.Device) string { return d.Phase }
+
+	case "rack":
+		racks, err := util.API.GetWorkspaceRacks(workspaceID)
+		if err != nil {
+			util.Bail(err)
+		}
+		names := make(map[uuid.UUID]string)
+		for _, r := range racks {
+			names[r.ID] = r.Name
+			if r.Datacenter != "" {
+				names[r.ID] = r.Datacenter + " " + r.Name
+			}
+		}
+
+		return func(d conch.Device) string {
+			if uuid.Equal(d.RackID, uuid.UUID{}) {
+				return ""
+			}
+			if name, ok := names[d.RackID]; ok {
+				return name
+			}
+			return d.RackID.String()
+		}
+
+	case "product":
+		products, err := util.API.GetHardwareProducts()
+		if err != nil {
+			util.Bail(err)
+		}
+		names := make(map[uuid.UUID]string)
+		for _, p := range products {
+			names[p.ID] = p.Name
+		}
+
+		return func(d conch.Device) string {
+			if uuid.Equal(d.HardwareProduct, uuid.UUID{}) {
+				return ""
+			}
+			if name, ok := names[d.HardwareProduct]; ok {
+				return name
+			}
+			return d.HardwareProduct.String()
+		}
+	}
+
+	return func(conch.Device) string { return "" }
+}
@@ -0,0 +1,300 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/config"
+	"github.com/joyent/conch-shell/pkg/util"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// HealthSnapshotDir is where 'workspace health-diff' keeps the snapshots it
+// compares against, one file per workspace
+var HealthSnapshotDir = config.DataPath("health")
+
+// healthSnapshotKeep is how many snapshots of a workspace are kept. The
+// oldest are dropped first.
+const healthSnapshotKeep = 100
+
+// healthSnapshotMaxAge is how long a snapshot is kept
+const healthSnapshotMaxAge = 30 * 24 * time.Hour
+
+// deviceState is what a snapshot remembers about a device
+type deviceState struct {
+	Health    string    `json:"health"`
+	Validated time.Time `json:"validated"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// healthSnapshot is the state of a workspace's devices at one time
+type healthSnapshot struct {
+	Taken   time.Time              `json:"taken"`
+	Devices map[string]deviceState `json:"devices"`
+}
+
+// healthSnapshots are a workspace's snapshots, oldest first
+type healthSnapshots []healthSnapshot
+
+// healthChange is a device whose state changed between two snapshots
+type healthChange struct {
+	Change    string    `json:"change"`
+	ID        string    `json:"id"`
+	WasHealth string    `json:"was_health"`
+	Health    string    `json:"health"`
+	LastSeen  time.Time `json:"last_seen"`
+	Validated time.Time `json:"validated"`
+}
+
+// healthDiff is what 'workspace health-diff' prints with --json
+type healthDiff struct {
+	WorkspaceID uuid.UUID      `json:"workspace_id"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Changes     []healthChange `json:"changes"`
+}
+
+// healthSnapshotPath is the snapshot file of a workspace. The API URL is
+// part of the name, so that profiles for different instances don't mix.
+func healthSnapshotPath(workspaceID uuid.UUID) string {
+	dir, err := homedir.Expand(HealthSnapshotDir)
+	if err != nil {
+		dir = HealthSnapshotDir
+	}
+	sum := sha256.Sum256([]byte(util.API.BaseURL))
+	name := hex.EncodeToString(sum[:4]) + "-" + workspaceID.String() + ".json"
+	return filepath.Join(dir, name)
+}
+
+// loadHealthSnapshots reads the snapshots of a workspace. A missing or
+// damaged file just means there is nothing to compare with.
+func loadHealthSnapshots(path string) healthSnapshots {
+	snaps := make(healthSnapshots, 0)
+	j, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snaps
+	}
+	if err := json.Unmarshal(j, &snaps); err != nil {
+		return make(healthSnapshots, 0)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Taken.Before(snaps[j].Taken) })
+	return snaps
+}
+
+// save writes the snapshots, dropping the ones that are too old or too many
+func (snaps healthSnapshots) save(path string) error {
+	cutoff := time.Now().Add(-healthSnapshotMaxAge)
+	kept := make(healthSnapshots, 0, len(snaps))
+	for _, s := range snaps {
+		if s.Taken.After(cutoff) {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) > healthSnapshotKeep {
+		kept = kept[len(kept)-healthSnapshotKeep:]
+	}
+
+	j, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(path, j, 0600)
+}
+
+// baseline is the newest snapshot taken no later than before. If they are
+// all newer than that, the oldest is used, and the second value is false.
+func (snaps healthSnapshots) baseline(before time.Time) (healthSnapshot, bool) {
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if !snaps[i].Taken.After(before) {
+			return snaps[i], true
+		}
+	}
+	return snaps[0], false
+}
+
+// compareHealth lists the devices that started failing, recovered, or went
+// silent between from and to. A device is silent once it hasn't reported in
+// staleAfter.
+func compareHealth(from healthSnapshot, to healthSnapshot, staleAfter time.Duration) []healthChange {
+	changes := make([]healthChange, 0)
+
+	for id, now := range to.Devices {
+		was, known := from.Devices[id]
+		wasHealth := was.Health
+		if !known {
+			wasHealth = "(new)"
+		}
+
+		c := healthChange{
+			ID:        id,
+			WasHealth: wasHealth,
+			Health:    now.Health,
+			LastSeen:  now.LastSeen,
+			Validated: now.Validated,
+		}
+
+		wasSilent := known && (was.LastSeen.IsZero() || from.Taken.Sub(was.LastSeen) > staleAfter)
+		isSilent := now.LastSeen.IsZero() || to.Taken.Sub(now.LastSeen) > staleAfter
+
+		switch {
+		case now.Health == "fail" && wasHealth != "fail":
+			c.Change = "failing"
+		case known && was.Health == "fail" && now.Health == "pass":
+			c.Change = "recovered"
+		case known && !wasSilent && isSilent:
+			c.Change = "silent"
+		default:
+			continue
+		}
+		changes = append(changes, c)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Change != changes[j].Change {
+			return changes[i].Change < changes[j].Change
+		}
+		return changes[i].ID < changes[j].ID
+	})
+	return changes
+}
+
+func healthDiffCmd(cmd *cli.Cmd) {
+	var (
+		sinceOpt  = cmd.StringOpt("since", "24h", "Compare with the newest snapshot at least this old. Uses Go duration syntax (eg '6h', '90m')")
+		staleOpt  = cmd.StringOpt("stale", "24h", "Devices that have not reported in this long are considered silent. Uses Go duration syntax (eg '6h', '90m')")
+		noSaveOpt = cmd.BoolOpt("no-save", false, "Don't keep a snapshot of the current state for later runs")
+		sorting   = util.SortFlags(cmd, "health_diff")
+	)
+
+	cmd.LongDesc = `
+Compares the health of the workspace's devices with an earlier snapshot of it,
+and lists the devices that have started failing, recovered from failing, or
+stopped reporting since.
+
+Each run keeps a snapshot of the current state, locally, for later runs to
+compare with. The first run only takes a snapshot. Running this regularly, eg
+from cron, means there is always one of about the right age. Snapshots are
+kept for 30 days, in ` + HealthSnapshotDir + `.`
+
+	cmd.Action = func() {
+		since, err := time.ParseDuration(*sinceOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+		staleAfter, err := time.ParseDuration(*staleOpt)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		devices, err := util.API.GetWorkspaceDevicesFields(
+			WorkspaceUUID,
+			[]string{"id", "health", "validated", "last_seen"},
+			"",
+			"",
+			"",
+		)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		current := healthSnapshot{
+			Taken:   time.Now().UTC(),
+			Devices: make(map[string]deviceState),
+		}
+		for _, d := range devices {
+			current.Devices[d.ID] = deviceState{
+				Health:    d.Health,
+				Validated: d.Validated,
+				LastSeen:  d.LastSeen,
+			}
+		}
+
+		path := healthSnapshotPath(WorkspaceUUID)
+		snaps := loadHealthSnapshots(path)
+
+		// A replayed session is in the past, and must not be mixed in
+		if !*noSaveOpt && !util.Replaying() {
+			if err := append(snaps, current).save(path); err != nil {
+				util.Bail(err)
+			}
+		}
+
+		if len(snaps) == 0 {
+			if util.JSON {
+				util.JSONOut(healthDiff{
+					WorkspaceID: WorkspaceUUID,
+					To:          current.Taken,
+					Changes:     make([]healthChange, 0),
+				})
+				return
+			}
+			fmt.Println("There is no earlier snapshot of this workspace to compare with. Run this again later")
+			return
+		}
+
+		from, old := snaps.baseline(current.Taken.Add(-since))
+		if !old {
+			fmt.Fprintf(
+				os.Stderr,
+				"Warning: the oldest snapshot of this workspace is from %s, less than %s ago\n",
+				util.TimeStr(from.Taken),
+				since,
+			)
+		}
+
+		diff := healthDiff{
+			WorkspaceID: WorkspaceUUID,
+			From:        from.Taken,
+			To:          current.Taken,
+			Changes:     compareHealth(from, current, staleAfter),
+		}
+
+		header := []string{"Change", "ID", "Was", "Health", "Last Seen", "Validated"}
+		row := func(i int) []string {
+			c := diff.Changes[i]
+			lastSeen := ""
+			if !c.LastSeen.IsZero() {
+				lastSeen = util.TimeStr(c.LastSeen.UTC())
+			}
+			validated := ""
+			if !c.Validated.IsZero() {
+				validated = util.TimeStr(c.Validated.UTC())
+			}
+			return []string{c.Change, c.ID, c.WasHealth, c.Health, lastSeen, validated}
+		}
+
+		if err := sorting.Sort(diff.Changes, header, row); err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(diff)
+			return
+		}
+
+		if !util.CountOnly {
+			fmt.Printf("Changes since %s\n\n", util.TimeStr(from.Taken))
+		}
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(diff.Changes), row, "Change", "Health"); err != nil {
+			util.Bail(err)
+		}
+	}
+}
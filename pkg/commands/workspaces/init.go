@@ -29,14 +29,27 @@ var RelayID string
 // parent command
 var RackUUID uuid.UUID
 
+// fanOut holds the --all-workspaces and --workspaces options of the
+// subcommand being run, if it has them. Those commands don't need a single
+// workspace resolved up front.
+var fanOut *util.WorkspaceTargets
+
+// federate holds the --federate option of the subcommand being run, if it has
+// it. Each profile brings its own workspace, so none is resolved up front.
+var federate *util.FederationTargets
+
 // Init loads up the commands dealing with workspaces
 func Init(app *cli.Cli) {
-	app.Command(
+	registerOutputs()
+
+	util.Command(
+		app.Cmd,
 		"workspaces wss",
 		"Get a list of all workspaces",
 		getAll,
 	)
-	app.Command(
+	util.Command(
+		app.Cmd,
 		"workspace ws",
 		"Commands for dealing with a single workspace",
 		func(cmd *cli.Cmd) {
@@ -47,6 +60,18 @@ func Init(app *cli.Cli) {
 
 			cmd.Before = func() {
 				util.BuildAPIAndVerifyLogin()
+				if fanOut.Set() {
+					if len(*workspaceIDStr) > 0 {
+						util.Bail(errors.New("a workspace ID can't be combined with --all-workspaces or --workspaces"))
+					}
+					return
+				}
+				if federate.Set() {
+					if len(*workspaceIDStr) > 0 {
+						util.Bail(errors.New("a workspace ID can't be combined with --federate"))
+					}
+					return
+				}
 				var newUUID uuid.UUID
 				if len(*workspaceIDStr) > 0 {
 					newUUID, _ = util.MagicWorkspaceID(*workspaceIDStr)
@@ -56,37 +81,115 @@ func Init(app *cli.Cli) {
 					WorkspaceUUID = newUUID
 					return
 				}
-				if uuid.Equal(util.ActiveProfile.WorkspaceUUID, uuid.UUID{}) {
-					util.Bail(errors.New("no workspace was found in the active profile"))
+				newUUID, err := util.MagicWorkspaceOrActiveID("")
+				if err != nil {
+					util.Bail(err)
 				}
-				WorkspaceUUID = util.ActiveProfile.WorkspaceUUID
+				WorkspaceUUID = newUUID
 			}
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"get",
 				"Get details of a single workspace",
 				getOne,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"users",
 				"Get a list of users for a single workspace",
 				getUsers,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"devices",
 				"Get a list of devices for a single workspace",
 				getDevices,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
+				"decommissioned",
+				"Get a list of devices decommissioned out of a single workspace",
+				getDecommissioned,
+			)
+
+			util.Command(
+				cmd,
+				"health-diff",
+				"List the devices whose health changed since an earlier snapshot of the workspace",
+				healthDiffCmd,
+			)
+
+			util.Command(
+				cmd,
+				"intake-report",
+				"Show how many devices arrived each week, and when the racks will be full at that rate",
+				intakeReportCmd,
+			)
+
+			util.Command(
+				cmd,
+				"production-gate",
+				"Mark each integration-phase device go or no-go for handover to production",
+				productionGate,
+			)
+
+			util.Command(
+				cmd,
+				"fields",
+				"List, define, and remove the custom fields of the workspace's devices",
+				func(cmd *cli.Cmd) {
+					listFields(cmd)
+
+					util.Command(
+						cmd,
+						"define set",
+						"Define a custom field, or change its definition",
+						defineField,
+					)
+
+					util.Command(
+						cmd,
+						"remove rm",
+						"Remove the definition of a custom field",
+						removeField,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
+				"settings",
+				"Commands for the device settings of a whole workspace",
+				func(cmd *cli.Cmd) {
+					util.Command(
+						cmd,
+						"find",
+						"List the devices whose setting has a given value",
+						findSettings,
+					)
+				},
+			)
+
+			util.Command(
+				cmd,
+				"import-asset-tags",
+				"Set the asset tags of many devices at once from a CSV file of serials and asset tags",
+				importAssetTags,
+			)
+
+			util.Command(
+				cmd,
 				"racks",
 				"Get a list of racks for a single workspace",
 				getRacks,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"rack",
 				"Subcommands that deal with an individual rack",
 				func(cmd *cli.Cmd) {
@@ -102,31 +205,36 @@ func Init(app *cli.Cli) {
 
 					cmd.Spec = "ID"
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"assign",
 						"Assign devices to slots in this rack using JSON artifacts",
 						assignRack,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"assignments",
 						"Dump a JSON extract of the devices assigned to this rack's slots. Intended for use with 'assign'",
 						assignmentsRack,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"get",
 						"Get details about a single rack in a workspace",
 						getRack,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"add",
 						"Add a single rack to a workspace",
 						addRack,
 					)
 
-					cmd.Command(
+					util.Command(
+						cmd,
 						"remove delete rm",
 						"Remove a single rack from a workspace",
 						deleteRack,
@@ -135,35 +243,41 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"relays",
 				"Get a list of relays for a single workspace",
 				getRelays,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"subs subworkspaces ws",
 				"Get a list of subworkspaces for a single workspace",
 				getSubs,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"add-user add invite",
 				"Add an existing user to this workspace",
 				addUser,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"remove-user",
 				"Remove a user from this workspace",
 				removeUser,
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"create",
 				"Create various items inside the given workspace",
 				func(cmd *cli.Cmd) {
-					cmd.Command(
+					util.Command(
+						cmd,
 						"subworkspace sub",
 						"Create a subworkspace",
 						createSubWorkspace,
@@ -171,7 +285,8 @@ func Init(app *cli.Cli) {
 				},
 			)
 
-			cmd.Command(
+			util.Command(
+				cmd,
 				"relay",
 				"Commands for a single relay in a workspace",
 				func(cmd *cli.Cmd) {
@@ -181,7 +296,8 @@ func Init(app *cli.Cli) {
 					}
 
 					cmd.Spec = "ID"
-					cmd.Command(
+					util.Command(
+						cmd,
 						"devices",
 						"Get a list of devices for a given relay",
 						getRelayDevices,
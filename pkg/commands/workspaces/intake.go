@@ -0,0 +1,251 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// intakeRecentWeeks is how many of the latest weeks the recent intake rate
+// is worked out over
+const intakeRecentWeeks = 4
+
+// intakeBarWidth is the length of the bar of the busiest week
+const intakeBarWidth = 40
+
+// intakeWeekLength is how long each bucket of the intake report is
+const intakeWeekLength = 7 * 24 * time.Hour
+
+// intakeWeek is the number of devices created in one week
+type intakeWeek struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Devices int       `json:"devices"`
+}
+
+// intakeProjection is when the workspace's racks fill up at a rate of
+// devices a week. WeeksToFill and FullBy are nil if they never do.
+type intakeProjection struct {
+	Weeks       int        `json:"weeks"`
+	Rate        float64    `json:"weekly_rate"`
+	WeeksToFill *float64   `json:"weeks_to_fill"`
+	FullBy      *time.Time `json:"full_by"`
+}
+
+// intakeReport is what 'workspace intake-report' prints with --json
+type intakeReport struct {
+	WorkspaceID uuid.UUID        `json:"workspace_id"`
+	Generated   time.Time        `json:"generated"`
+	Weeks       []intakeWeek     `json:"weeks"`
+	Total       int              `json:"total"`
+	Racks       int              `json:"racks"`
+	Slots       int              `json:"slots"`
+	EmptySlots  int              `json:"empty_slots"`
+	Average     intakeProjection `json:"average"`
+	Recent      intakeProjection `json:"recent"`
+}
+
+// bucketIntake counts the devices created in each of the given number of
+// weeks before now, oldest first. The weeks are the seven days up to now,
+// the seven before that, and so on, so that every week is a whole one.
+func bucketIntake(created []time.Time, weeks int, now time.Time) []intakeWeek {
+	buckets := make([]intakeWeek, weeks)
+	for i := range buckets {
+		end := now.Add(-time.Duration(weeks-1-i) * intakeWeekLength)
+		buckets[i] = intakeWeek{Start: end.Add(-intakeWeekLength), End: end}
+	}
+
+	start := buckets[0].Start
+	for _, c := range created {
+		if c.IsZero() || c.Before(start) || !c.Before(now) {
+			continue
+		}
+		i := int(c.Sub(start) / intakeWeekLength)
+		buckets[i].Devices++
+	}
+	return buckets
+}
+
+// project works out when empty slots fill up going by the intake of the
+// given weeks
+func project(weeks []intakeWeek, empty int, now time.Time) intakeProjection {
+	p := intakeProjection{Weeks: len(weeks)}
+	if len(weeks) == 0 {
+		return p
+	}
+
+	total := 0
+	for _, w := range weeks {
+		total += w.Devices
+	}
+	p.Rate = float64(total) / float64(len(weeks))
+	if p.Rate == 0 {
+		return p
+	}
+
+	toFill := float64(empty) / p.Rate
+	fullBy := now.Add(time.Duration(toFill * float64(intakeWeekLength)))
+	p.WeeksToFill = &toFill
+	p.FullBy = &fullBy
+	return p
+}
+
+// countSlots counts the slots of the workspace's racks, and how many of them
+// have no device in them
+func countSlots(workspaceID uuid.UUID) (racks int, slots int, empty int, err error) {
+	wsRacks, err := util.API.GetWorkspaceRacks(workspaceID)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	var mu sync.Mutex
+	err = util.Each(
+		len(wsRacks),
+		fmt.Sprintf("Counting the slots of %d racks", len(wsRacks)),
+		func(i int) error {
+			assignments, err := util.API.GetRackAssignments(wsRacks[i].ID)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, a := range assignments {
+				slots++
+				if a.DeviceID == "" {
+					empty++
+				}
+			}
+			return nil
+		},
+	)
+	return len(wsRacks), slots, empty, err
+}
+
+func intakeReportCmd(cmd *cli.Cmd) {
+	var weeksOpt = cmd.IntOpt("weeks", 12, "How many weeks back to look")
+
+	cmd.LongDesc = `
+Counts the devices that showed up in the workspace in each of the last few
+weeks, going by when each device was created, and works out when the empty
+slots in the workspace's racks will be full if devices keep arriving at the
+same rate.
+
+Two projections are made: one at the average rate over all the weeks, and
+one at the rate of the last 4 weeks, which is quicker to show a change of
+pace. Devices that have left the workspace aren't counted.`
+
+	cmd.Action = func() {
+		if *weeksOpt < 1 {
+			util.Bail(errors.New("--weeks must be at least 1"))
+		}
+
+		devices, err := util.API.GetWorkspaceDevicesFields(
+			WorkspaceUUID,
+			[]string{"id", "created"},
+			"",
+			"",
+			"",
+		)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		created := make([]time.Time, 0, len(devices))
+		for _, d := range devices {
+			created = append(created, d.Created)
+		}
+
+		racks, slots, empty, err := countSlots(WorkspaceUUID)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		now := time.Now().UTC()
+		report := intakeReport{
+			WorkspaceID: WorkspaceUUID,
+			Generated:   now,
+			Weeks:       bucketIntake(created, *weeksOpt, now),
+			Racks:       racks,
+			Slots:       slots,
+			EmptySlots:  empty,
+		}
+		for _, w := range report.Weeks {
+			report.Total += w.Devices
+		}
+
+		recent := intakeRecentWeeks
+		if recent > len(report.Weeks) {
+			recent = len(report.Weeks)
+		}
+		report.Average = project(report.Weeks, empty, now)
+		report.Recent = project(report.Weeks[len(report.Weeks)-recent:], empty, now)
+
+		if util.JSON {
+			util.JSONOut(report)
+			return
+		}
+		report.render()
+	}
+}
+
+func (r intakeReport) render() {
+	most := 0
+	for _, w := range r.Weeks {
+		if w.Devices > most {
+			most = w.Devices
+		}
+	}
+
+	table := util.GetMarkdownTable()
+	table.SetHeader([]string{"Week Starting", "Devices", ""})
+	for _, w := range r.Weeks {
+		bar := ""
+		if most > 0 {
+			bar = strings.Repeat("#", w.Devices*intakeBarWidth/most)
+		}
+		table.Append([]string{
+			w.Start.Local().Format("2006-01-02"),
+			strconv.Itoa(w.Devices),
+			bar,
+		})
+	}
+	table.Render()
+
+	fmt.Printf("\nDevices in the last %d weeks: %d\n", len(r.Weeks), r.Total)
+	fmt.Printf("Racks: %d, with %d of %d slots empty\n\n", r.Racks, r.EmptySlots, r.Slots)
+
+	if r.EmptySlots == 0 {
+		fmt.Println("Every slot is already full")
+		return
+	}
+	r.Average.render("Average")
+	if r.Recent.Weeks != r.Average.Weeks {
+		r.Recent.render(fmt.Sprintf("Last %d weeks", r.Recent.Weeks))
+	}
+}
+
+func (p intakeProjection) render(label string) {
+	fill := "never, at this rate"
+	if p.FullBy != nil {
+		fill = fmt.Sprintf(
+			"in %.1f weeks, around %s",
+			*p.WeeksToFill,
+			p.FullBy.Local().Format("2006-01-02"),
+		)
+	}
+	fmt.Printf("%s: %.1f devices a week. Full %s\n", label, p.Rate, fill)
+}
@@ -0,0 +1,88 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// The ways 'workspace devices --maintenance' treats devices in maintenance
+const (
+	maintenanceInclude = "include"
+	maintenanceExclude = "exclude"
+	maintenanceOnly    = "only"
+)
+
+func checkMaintenanceMode(mode string) {
+	switch mode {
+	case maintenanceInclude, maintenanceExclude, maintenanceOnly:
+	default:
+		util.Bail(fmt.Errorf(
+			"--maintenance must be one of '%s', '%s', or '%s'",
+			maintenanceInclude,
+			maintenanceExclude,
+			maintenanceOnly,
+		))
+	}
+}
+
+// filterMaintenance leaves out, or keeps only, the devices that are in
+// maintenance
+func filterMaintenance(api *conch.Conch, devices conch.Devices, mode string) (conch.Devices, error) {
+	if mode == maintenanceInclude {
+		return devices, nil
+	}
+
+	inMaintenance, err := api.GetDevicesInMaintenance(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make(conch.Devices, 0, len(devices))
+	for _, d := range devices {
+		_, ok := inMaintenance[d.ID]
+		if ok == (mode == maintenanceOnly) {
+			kept = append(kept, d)
+		}
+	}
+	return kept, nil
+}
+
+// maintenanceColumns adds a column to a listing that notes which of its
+// devices are in maintenance, if any are
+func maintenanceColumns(devices conch.Devices) []util.DeviceColumn {
+	inMaintenance, err := util.API.GetDevicesInMaintenance(time.Now())
+	if err != nil {
+		util.Bail(err)
+	}
+
+	listed := false
+	for _, d := range devices {
+		if _, ok := inMaintenance[d.ID]; ok {
+			listed = true
+			break
+		}
+	}
+	if !listed {
+		return nil
+	}
+
+	return []util.DeviceColumn{{
+		Name: "Maintenance",
+		Value: func(d conch.Device) string {
+			m, ok := inMaintenance[d.ID]
+			if !ok {
+				return ""
+			}
+			return "until " + util.TimeStr(m.Until)
+		},
+	}}
+}
@@ -0,0 +1,36 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// registerOutputs tells 'conch schema' what each command prints with --json
+func registerOutputs() {
+	util.RegisterOutput("workspaces", conch.Workspaces{})
+
+	util.RegisterOutput("workspace get", conch.Workspace{})
+	util.RegisterOutput("workspace users", []conch.WorkspaceUser{})
+	util.RegisterOutput("workspace devices", []util.BriefDevice{})
+	util.RegisterOutput("workspace decommissioned", []decommissionedDevice{})
+	util.RegisterOutput("workspace health-diff", healthDiff{})
+	util.RegisterOutput("workspace intake-report", intakeReport{})
+	util.RegisterOutput("workspace production-gate", []gateResult{})
+	util.RegisterOutput("workspace settings find", []settingMatch{})
+	util.RegisterOutput("workspace fields", []conch.CustomField{})
+	util.RegisterOutput("workspace fields define", conch.CustomField{})
+	util.RegisterOutput("workspace import-asset-tags", []assetTagRow{})
+	util.RegisterOutput("workspace racks", []conch.WorkspaceRack{})
+	util.RegisterOutput("workspace rack get", conch.WorkspaceRack{})
+	util.RegisterOutput("workspace rack assignments", rackAssignments{})
+	util.RegisterOutput("workspace relays", []relayRow{})
+	util.RegisterOutput("workspace subs", conch.Workspaces{})
+	util.RegisterOutput("workspace create subworkspace", conch.Workspace{})
+	util.RegisterOutput("workspace relay devices", []util.BriefDevice{})
+}
@@ -0,0 +1,227 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// The items on the production gate checklist, in the order they are shown
+const (
+	gateValidated = "validated"
+	gateGraduated = "graduated"
+	gateAssetTag  = "asset-tag"
+	gateSettings  = "settings"
+)
+
+var gateChecks = []string{gateValidated, gateGraduated, gateAssetTag, gateSettings}
+
+// gateResult is the verdict of the production gate on a single device.
+// Failures maps each check that failed to why.
+type gateResult struct {
+	DeviceID string            `json:"device_id"`
+	Go       bool              `json:"go"`
+	Failures map[string]string `json:"failures"`
+}
+
+// gateSettingsChecker checks devices' settings against the --setting names
+// and their hardware products' settings templates, fetching each template
+// only once
+type gateSettingsChecker struct {
+	required  []string
+	mu        sync.Mutex
+	templates map[uuid.UUID]conch.SettingsTemplate
+}
+
+func (g *gateSettingsChecker) template(productID uuid.UUID) (conch.SettingsTemplate, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if t, ok := g.templates[productID]; ok {
+		return t, nil
+	}
+	t, err := util.API.GetSettingsTemplate(productID)
+	if err == conch.ErrDataNotFound {
+		t, err = make(conch.SettingsTemplate), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.templates[productID] = t
+	return t, nil
+}
+
+// check returns what is wrong with a device's settings, if anything
+func (g *gateSettingsChecker) check(d conch.Device) (string, error) {
+	current, err := util.API.GetDeviceSettings(d.ID)
+	if err != nil && err != conch.ErrDataNotFound {
+		return "", err
+	}
+
+	problems := make([]string, 0)
+	for _, name := range g.required {
+		if _, ok := current[name]; !ok {
+			problems = append(problems, "missing "+name)
+		}
+	}
+
+	if !uuid.Equal(d.HardwareProduct, uuid.UUID{}) {
+		tmpl, err := g.template(d.HardwareProduct)
+		if err != nil {
+			return "", err
+		}
+		for _, change := range tmpl.Diff(current) {
+			if change.Action == "add" {
+				problems = append(problems, "missing "+change.Name)
+			} else {
+				problems = append(problems, fmt.Sprintf("%s is '%s', template says '%s'", change.Name, change.Current, change.Template))
+			}
+		}
+	}
+	return strings.Join(problems, "; "), nil
+}
+
+func productionGate(cmd *cli.Cmd) {
+	var (
+		skipOpt    = cmd.StringsOpt("skip", nil, "A check to leave out: validated, graduated, asset-tag, or settings. Can be given more than once")
+		settingOpt = cmd.StringsOpt("setting s", nil, "Name of a setting every device must have. Can be given more than once")
+	)
+
+	cmd.LongDesc = `
+The last check before a workspace's devices are handed over. Every device in
+the integration phase is held against a checklist:
+
+    validated   The device has passed validation
+    graduated   The device has been graduated
+    asset-tag   The device has an asset tag
+    settings    The device has every --setting, and matches its hardware
+                product's settings template, if there is one
+
+Each device is marked go or no-go, with the reasons for a no-go. Checks that
+don't apply to the handover can be left out with --skip. The command exits
+non-zero if any device is no-go, so that it can gate a script.`
+
+	cmd.Action = func() {
+		skip := make(map[string]bool)
+		for _, s := range *skipOpt {
+			known := false
+			for _, c := range gateChecks {
+				if s == c {
+					known = true
+				}
+			}
+			if !known {
+				util.Bail(fmt.Errorf("unknown check '%s'. The checks are %s", s, strings.Join(gateChecks, ", ")))
+			}
+			skip[s] = true
+		}
+
+		devices, err := util.API.GetWorkspaceDevicesFields(
+			WorkspaceUUID,
+			[]string{"id", "phase", "validated", "graduated", "asset_tag", "hardware_product"},
+			"",
+			"",
+			"",
+		)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		candidates := make([]conch.Device, 0)
+		for _, d := range devices {
+			if d.Phase == conch.IntegrationPhase {
+				candidates = append(candidates, d)
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+
+		settings := &gateSettingsChecker{
+			required:  *settingOpt,
+			templates: make(map[uuid.UUID]conch.SettingsTemplate),
+		}
+
+		results := make([]gateResult, len(candidates))
+		err = util.Each(
+			len(candidates),
+			fmt.Sprintf("Checking %d devices", len(candidates)),
+			func(i int) error {
+				d := candidates[i]
+				r := gateResult{DeviceID: d.ID, Failures: make(map[string]string)}
+
+				if !skip[gateValidated] && d.Validated.IsZero() {
+					r.Failures[gateValidated] = "not validated"
+				}
+				if !skip[gateGraduated] && d.Graduated.IsZero() {
+					r.Failures[gateGraduated] = "not graduated"
+				}
+				if !skip[gateAssetTag] && strings.TrimSpace(d.AssetTag) == "" {
+					r.Failures[gateAssetTag] = "no asset tag"
+				}
+				if !skip[gateSettings] {
+					problem, err := settings.check(d)
+					if err != nil {
+						return err
+					}
+					if problem != "" {
+						r.Failures[gateSettings] = problem
+					}
+				}
+
+				r.Go = len(r.Failures) == 0
+				results[i] = r
+				return nil
+			},
+		)
+		if err != nil {
+			util.Bail(err)
+		}
+
+		noGo := 0
+		for _, r := range results {
+			if !r.Go {
+				noGo++
+			}
+		}
+
+		if util.JSON {
+			util.JSONOut(results)
+		} else if len(results) == 0 {
+			fmt.Println("No devices are in the integration phase")
+		} else {
+			header := []string{"Device", "Verdict", "Reasons"}
+			row := func(i int) []string {
+				r := results[i]
+				if r.Go {
+					return []string{r.DeviceID, "go", ""}
+				}
+				reasons := make([]string, 0, len(r.Failures))
+				for _, c := range gateChecks {
+					if f, ok := r.Failures[c]; ok {
+						reasons = append(reasons, f)
+					}
+				}
+				return []string{r.DeviceID, "no-go", strings.Join(reasons, "; ")}
+			}
+			if err := util.RenderTable(util.GetMarkdownTable(), header, len(results), row); err != nil {
+				util.Bail(err)
+			}
+			fmt.Printf("\n%d of %d devices go, %d no-go\n", len(results)-noGo, len(results), noGo)
+		}
+
+		if noGo > 0 {
+			util.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package workspaces
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/util"
+)
+
+// settingMatch is a device whose setting matched
+type settingMatch struct {
+	ID       string `json:"id"`
+	AssetTag string `json:"asset_tag"`
+	Hostname string `json:"hostname"`
+	Phase    string `json:"phase"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+}
+
+func findSettings(cmd *cli.Cmd) {
+	var (
+		keyOpt     = cmd.StringOpt("key k", "", "The device setting to look at")
+		valueOpt   = cmd.StringOpt("value v", "", "Only list devices whose setting has this value. Without it, every device with the setting is listed")
+		regexOpt   = cmd.BoolOpt("regex r", false, "Treat --value as a regular expression, rather than an exact value")
+		idsOnlyOpt = cmd.BoolOpt("ids-only", false, "Only print the IDs of the matching devices")
+	)
+	sorting := util.SortFlags(cmd, "setting_matches")
+
+	cmd.LongDesc = `
+Scans the settings of every device in the workspace and lists the devices whose
+setting has the given value, eg:
+
+    conch workspace ws settings find --key build_status --value failed
+
+The API has no way to search settings, so this asks for the setting of each
+device in turn, several at a time.`
+
+	cmd.Action = func() {
+		key := strings.TrimSpace(*keyOpt)
+		if key == "" {
+			util.Bail(errors.New("--key is required"))
+		}
+
+		match := func(v string) bool { return *valueOpt == "" || v == *valueOpt }
+		if *regexOpt {
+			if *valueOpt == "" {
+				util.Bail(errors.New("--regex needs a --value"))
+			}
+			re, err := regexp.Compile(*valueOpt)
+			if err != nil {
+				util.Bail(fmt.Errorf("bad --value: %s", err))
+			}
+			match = re.MatchString
+		}
+
+		devices, err := util.API.GetWorkspaceDevices(WorkspaceUUID, false, "", "", "")
+		if err != nil {
+			util.Bail(err)
+		}
+
+		progress := util.StartProgress("settings find", len(devices))
+		defer progress.Finish()
+
+		var mu sync.Mutex
+		matches := make([]settingMatch, 0)
+		err = util.EachDevice(devices, func(i int, d conch.Device) error {
+			value, err := util.API.GetDeviceSetting(d.ID, key)
+			if err == conch.ErrDataNotFound {
+				progress.Step(d.ID)
+				return nil
+			}
+			if err != nil {
+				progress.Fail(d.ID, err)
+				return fmt.Errorf("device %s: %s", d.ID, err)
+			}
+			progress.Step(d.ID)
+
+			if !match(value) {
+				return nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			matches = append(matches, settingMatch{
+				ID:       d.ID,
+				AssetTag: d.AssetTag,
+				Hostname: d.Hostname,
+				Phase:    d.Phase,
+				Key:      key,
+				Value:    value,
+			})
+			return nil
+		})
+		if err != nil {
+			util.Bail(err)
+		}
+
+		// The lookups finish in any order
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i].ID < matches[j].ID
+		})
+
+		header := []string{"ID", "Asset Tag", "Hostname", "Phase", "Value"}
+		row := func(i int) []string {
+			m := matches[i]
+			return []string{m.ID, m.AssetTag, m.Hostname, m.Phase, m.Value}
+		}
+
+		if err := sorting.Sort(matches, header, row); err != nil {
+			util.Bail(err)
+		}
+
+		if *idsOnlyOpt {
+			ids := make([]string, 0, len(matches))
+			for _, m := range matches {
+				ids = append(ids, m.ID)
+			}
+			if util.JSON {
+				util.JSONOut(ids)
+				return
+			}
+			for _, id := range ids {
+				fmt.Println(id)
+			}
+			return
+		}
+
+		if util.JSON {
+			util.JSONOut(matches)
+			return
+		}
+
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(matches), row, "Phase", "Value"); err != nil {
+			util.Bail(err)
+		}
+	}
+}
@@ -22,6 +22,8 @@ func addUser(app *cli.Cmd) {
 Those days are behind us. New users must now be created via the 'admin user' interface.`
 
 	app.Action = func() {
+		util.WarnWorkspaceRole(WorkspaceUUID, "admin", "Adding a user")
+
 		var role string
 
 		address, err := mail.ParseAddress(*emailArg)
@@ -86,6 +88,8 @@ func removeUser(app *cli.Cmd) {
 	app.Spec = "EMAIL [OPTIONS]"
 
 	app.Action = func() {
+		util.WarnWorkspaceRole(WorkspaceUUID, "admin", "Removing a user")
+
 		address, err := mail.ParseAddress(*emailArg)
 		if err != nil {
 			util.Bail(err)
@@ -141,33 +141,49 @@ func getOne(app *cli.Cmd) {
 }
 
 func getUsers(app *cli.Cmd) {
+	sorting := util.SortFlags(app, "users")
+
 	app.Action = func() {
 		users, err := util.API.GetWorkspaceUsers(WorkspaceUUID)
 		if err != nil {
 			util.Bail(err)
 		}
 
+		// Many users tend to inherit their role from the same workspace
+		wsNames := make(map[uuid.UUID]string)
+		roleVia := func(u conch.WorkspaceUser) string {
+			if uuid.Equal(u.RoleVia, WorkspaceUUID) || uuid.Equal(u.RoleVia, uuid.UUID{}) {
+				return ""
+			}
+			if name, ok := wsNames[u.RoleVia]; ok {
+				return name
+			}
+			ws, err := util.API.GetWorkspace(u.RoleVia)
+			if err != nil {
+				util.Bail(err)
+			}
+			wsNames[u.RoleVia] = ws.Name
+			return ws.Name
+		}
+
+		header := []string{"Name", "Email", "Role", "Role Via"}
+		row := func(i int) []string {
+			u := users[i]
+			return []string{u.Name, u.Email, u.Role, roleVia(u)}
+		}
+
+		if err := sorting.Sort(users, header, row); err != nil {
+			util.Bail(err)
+		}
+
 		if util.JSON {
 			util.JSONOut(users)
 			return
 		}
 
-		table := util.GetMarkdownTable()
-		table.SetHeader([]string{"Name", "Email", "Role", "Role Via"})
-
-		for _, u := range users {
-			roleVia := ""
-			if !uuid.Equal(u.RoleVia, WorkspaceUUID) && !uuid.Equal(u.RoleVia, uuid.UUID{}) {
-				ws, err := util.API.GetWorkspace(u.RoleVia)
-				if err != nil {
-					util.Bail(err)
-				}
-				roleVia = ws.Name
-			}
-			table.Append([]string{u.Name, u.Email, u.Role, roleVia})
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(users), row, "Role"); err != nil {
+			util.Bail(err)
 		}
-
-		table.Render()
 	}
 }
 
@@ -179,16 +195,83 @@ func getDevices(app *cli.Cmd) {
 		graduated  = app.StringOpt("graduated", "", "Filter by the 'graduated' field")
 		health     = app.StringOpt("health", "", "Filter by the 'health' field")
 		validated  = app.StringOpt("validated", "", "Filter by the 'validated' field")
+		groupBy    = app.StringOpt("group-by", "", "Group the devices by 'rack', 'health', 'phase', or 'product', each under a heading with its count. With --json, a list of groups is printed")
+		maintMode  = app.StringOpt("maintenance", maintenanceInclude, "What to do with devices in maintenance: 'include' them, with a column noting which they are, 'exclude' them, or list 'only' them")
+		rackUnit   = app.BoolOpt("rack-unit ru", false, "Add a column of the rack unit each device starts at, which can be sorted on as 'ru'")
 	)
+	sorting := util.SortFlags(app, "devices")
+	columns := util.FieldColumnFlags(app)
+	fanOut = util.WorkspaceFanOutFlags(app)
+	federate = util.FederateFlags(app)
 
 	app.Action = func() {
-		devices, err := util.API.GetWorkspaceDevices(
-			WorkspaceUUID,
-			*idsOnly,
-			*graduated,
-			*health,
-			*validated,
-		)
+		checkMaintenanceMode(*maintMode)
+
+		if columns.Set() && (*idsOnly || *groupBy != "" || fanOut.Set() || federate.Set()) {
+			util.Bail(errors.New("--field can't be used with --ids-only, --group-by, or across workspaces"))
+		}
+
+		by := ""
+		if *groupBy != "" {
+			by = checkGrouping(*groupBy)
+			if *idsOnly {
+				util.Bail(errors.New("--group-by can't be used with --ids-only"))
+			}
+			if fanOut.Set() || federate.Set() {
+				util.Bail(errors.New("--group-by only works on a single workspace"))
+			}
+		}
+
+		fetchAll := func(api *conch.Conch, workspaceID uuid.UUID) (conch.Devices, error) {
+			if *idsOnly {
+				return api.GetWorkspaceDevices(
+					workspaceID,
+					true,
+					*graduated,
+					*health,
+					*validated,
+				)
+			}
+			// Only ask for what is going to be displayed. Big workspaces
+			// otherwise send back megabytes of data that gets thrown away
+			return api.GetWorkspaceDevicesFields(
+				workspaceID,
+				groupFields(util.DisplayDeviceFields(*fullOutput), by),
+				*graduated,
+				*health,
+				*validated,
+			)
+		}
+		fetch := func(api *conch.Conch, workspaceID uuid.UUID) (conch.Devices, error) {
+			devices, err := fetchAll(api, workspaceID)
+			if err != nil {
+				return devices, err
+			}
+			return filterMaintenance(api, devices, *maintMode)
+		}
+
+		if federate.Set() {
+			if fanOut.Set() {
+				util.Bail(errors.New("--federate can't be combined with --all-workspaces or --workspaces"))
+			}
+			if *fullOutput {
+				util.Bail(errors.New("--full can't be used with --federate"))
+			}
+			getDevicesFederated(fetch, *idsOnly, sorting)
+			return
+		}
+
+		if fanOut.Set() {
+			getDevicesAcross(
+				func(id uuid.UUID) (conch.Devices, error) { return fetch(util.API, id) },
+				*idsOnly,
+				*fullOutput,
+				sorting,
+			)
+			return
+		}
+
+		devices, err := fetch(util.API, WorkspaceUUID)
 		if err != nil {
 			util.Bail(err)
 		}
@@ -211,6 +294,15 @@ func getDevices(app *cli.Cmd) {
 		}
 
 		if *fullOutput {
+			rackIDs := make(map[uuid.UUID]bool)
+			for _, d := range devices {
+				rackIDs[d.RackID] = true
+			}
+			util.CheckRequestBudget(
+				len(rackIDs),
+				fmt.Sprintf("Finding the locations of %d devices", len(devices)),
+			)
+
 			locs := make(map[uuid.UUID]conch.DeviceLocation)
 
 			dLocs := make([]conch.Device, 0)
@@ -234,44 +326,64 @@ func getDevices(app *cli.Cmd) {
 			devices = dLocs
 		}
 
-		if err := util.DisplayDevices(devices, *fullOutput); err != nil {
+		var extra []util.DeviceColumn
+		if *rackUnit {
+			extra = append(extra, util.RackUnitColumn)
+		}
+		if *maintMode == maintenanceInclude && !util.JSON && !util.CountOnly {
+			extra = append(extra, maintenanceColumns(devices)...)
+		}
+
+		if by != "" {
+			groupOf := deviceGrouper(WorkspaceUUID, by)
+			if err := util.DisplayDeviceGroups(devices, *fullOutput, sorting, groupOf, extra...); err != nil {
+				util.Bail(err)
+			}
+		} else if err := util.DisplayDevicesWithFields(devices, *fullOutput, sorting, columns, extra...); err != nil {
 			util.Bail(err)
 		}
 	}
 }
 
 func getRacks(app *cli.Cmd) {
+	sorting := util.SortFlags(app, "racks")
+
 	app.Action = func() {
 		racks, err := util.API.GetWorkspaceRacks(WorkspaceUUID)
 		if err != nil {
 			util.Bail(err)
 		}
 
-		if util.JSON {
-			util.JSONOut(racks)
-			return
-		}
-
-		table := util.GetMarkdownTable()
-		table.SetHeader([]string{
+		header := []string{
 			"ID",
 			"Datacenter",
 			"Name",
 			"Role",
 			"Size",
-		})
-
-		for _, r := range racks {
-			table.Append([]string{
+		}
+		row := func(i int) []string {
+			r := racks[i]
+			return []string{
 				r.ID.String(),
 				r.Datacenter,
 				r.Name,
 				r.Role,
 				strconv.Itoa(r.Size),
-			})
+			}
 		}
 
-		table.Render()
+		if err := sorting.Sort(racks, header, row); err != nil {
+			util.Bail(err)
+		}
+
+		if util.JSON {
+			util.JSONOut(racks)
+			return
+		}
+
+		if err := util.RenderTable(util.GetMarkdownTable(), header, len(racks), row, "Role"); err != nil {
+			util.Bail(err)
+		}
 	}
 }
 
@@ -372,6 +484,18 @@ Phase: %s
 	}
 }
 
+// relayRow is a relay as 'workspace relays' lists it, without --full
+type relayRow struct {
+	ID         string    `json:"id"`
+	Alias      string    `json:"asset_tag"`
+	Created    time.Time `json:"created"`
+	IPAddr     string    `json:"ipaddr"`
+	SSHPort    int       `json:"ssh_port"`
+	Updated    time.Time `json:"updated"`
+	Version    string    `json:"version"`
+	NumDevices int       `json:"num_devices"`
+}
+
 func getRelays(app *cli.Cmd) {
 	var (
 		activeOnly   = app.BoolOpt("active-only", false, "Only retrieve active relays")
@@ -401,21 +525,10 @@ func getRelays(app *cli.Cmd) {
 			return
 		}
 
-		type resultRow struct {
-			ID         string    `json:"id"`
-			Alias      string    `json:"asset_tag"`
-			Created    time.Time `json:"created"`
-			IPAddr     string    `json:"ipaddr"`
-			SSHPort    int       `json:"ssh_port"`
-			Updated    time.Time `json:"updated"`
-			Version    string    `json:"version"`
-			NumDevices int       `json:"num_devices"`
-		}
-
-		results := make([]resultRow, 0)
+		results := make([]relayRow, 0)
 
 		for _, r := range relays {
-			results = append(results, resultRow{
+			results = append(results, relayRow{
 				r.ID,
 				r.Alias,
 				r.Created,
@@ -493,6 +606,7 @@ func getRelayDevices(app *cli.Cmd) {
 	var (
 		fullOutput = app.BoolOpt("full", false, "When global --json is used, provide full data about the devices rather than normal truncated data")
 	)
+	sorting := util.SortFlags(app, "devices")
 
 	app.Action = func() {
 		devices, err := util.API.GetWorkspaceRelayDevices(
@@ -503,7 +617,7 @@ func getRelayDevices(app *cli.Cmd) {
 			util.Bail(err)
 		}
 
-		if err := util.DisplayDevices(devices, *fullOutput); err != nil {
+		if err := util.DisplayDevices(devices, *fullOutput, sorting); err != nil {
 			util.Bail(err)
 		}
 	}
@@ -511,6 +625,8 @@ func getRelayDevices(app *cli.Cmd) {
 
 func addRack(app *cli.Cmd) {
 	app.Action = func() {
+		util.WarnWorkspaceRole(WorkspaceUUID, "admin", "Adding a rack")
+
 		if err := util.API.AddRackToWorkspace(WorkspaceUUID, RackUUID); err != nil {
 			util.Bail(err)
 		}
@@ -519,6 +635,8 @@ func addRack(app *cli.Cmd) {
 
 func deleteRack(app *cli.Cmd) {
 	app.Action = func() {
+		util.WarnWorkspaceRole(WorkspaceUUID, "admin", "Removing a rack")
+
 		if err := util.API.DeleteRackFromWorkspace(WorkspaceUUID, RackUUID); err != nil {
 			util.Bail(err)
 		}
@@ -528,8 +646,9 @@ func deleteRack(app *cli.Cmd) {
 func assignRack(app *cli.Cmd) {
 	var (
 		filePathArg = app.StringArg("FILE", "-", "Path to a JSON file to use as the data source. '-' indicates STDIN")
+		planOpts    = util.NewPlanOpts(app)
 	)
-	app.Spec = "FILE"
+	app.Spec = "[OPTIONS] FILE"
 	app.Action = func() {
 		var b []byte
 		var err error
@@ -562,19 +681,47 @@ func assignRack(app *cli.Cmd) {
 			util.Bail(errors.New("no devices found. no changes to make"))
 		}
 
-		assignments := make(conch.WorkspaceRackLayoutAssignments)
+		rack, err := util.API.GetWorkspaceRack(WorkspaceUUID, RackUUID)
+		if err != nil {
+			util.Bail(err)
+		}
 
-		for _, keeper := range keepers {
-			assignments[keeper.DeviceID] = keeper.RackUnitStart
+		occupants := make(map[int]string)
+		for _, slot := range rack.Slots {
+			if slot.Occupant.ID != "" {
+				occupants[slot.RackUnitStart] = slot.Occupant.ID
+			}
 		}
 
-		if err := util.API.AssignWorkspaceDevicesToRackSlots(
-			WorkspaceUUID,
-			RackUUID,
-			assignments,
-		); err != nil {
-			util.Bail(err)
+		sort.Sort(keepers)
+
+		plan := util.NewPlan()
+		for _, keeper := range keepers {
+			keeper := keeper
+			name := fmt.Sprintf("%s ru %d", rack.Name, keeper.RackUnitStart)
+
+			action := util.PlanCreate
+			detail := keeper.DeviceID
+			if current, ok := occupants[keeper.RackUnitStart]; ok {
+				if current == keeper.DeviceID {
+					continue
+				}
+				action = util.PlanUpdate
+				detail = current + " -> " + keeper.DeviceID
+			}
+
+			plan.Add(action, "slot assignment", name, detail, func() error {
+				return util.API.AssignWorkspaceDevicesToRackSlots(
+					WorkspaceUUID,
+					RackUUID,
+					conch.WorkspaceRackLayoutAssignments{
+						keeper.DeviceID: keeper.RackUnitStart,
+					},
+				)
+			})
 		}
+
+		plan.Run(planOpts)
 	}
 }
 
@@ -0,0 +1,138 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"time"
+)
+
+// LibraryVersion is the semantic version of the stable subset of this
+// package, described in the package documentation. It is separate from the
+// shell's version.
+const LibraryVersion = "1.0.0"
+
+// Option configures a Conch built by New
+type Option func(*clientOptions) error
+
+type clientOptions struct {
+	conch   *Conch
+	timeout time.Duration
+}
+
+// New builds a Conch. Without options it talks to the default Conch
+// instance, without credentials. Nothing is sent to the API until a method
+// is called, so a bad token is only found out then.
+func New(opts ...Option) (*Conch, error) {
+	o := &clientOptions{conch: &Conch{}}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	c := o.conch
+
+	// sling() fills in the defaults, including the HTTP client
+	c.sling()
+
+	if o.timeout > 0 {
+		// A copy, so that a client given to WithHTTPClient is left alone
+		client := *c.HTTPClient
+		client.Timeout = o.timeout
+		c.HTTPClient = &client
+	}
+
+	return c, nil
+}
+
+// WithBaseURL sets the URL of the Conch API, eg "https://conch.example.com"
+func WithBaseURL(u string) Option {
+	return func(o *clientOptions) error {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return err
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return fmt.Errorf("'%s' is not an http or https URL", u)
+		}
+		if parsed.Host == "" {
+			return fmt.Errorf("'%s' has no host", u)
+		}
+		o.conch.BaseURL = u
+		return nil
+	}
+}
+
+// WithToken authenticates with an API token, as made by 'conch user token
+// create' or CreateMyToken
+func WithToken(token string) Option {
+	return func(o *clientOptions) error {
+		if token == "" {
+			return errors.New("the API token is empty")
+		}
+		o.conch.Token = token
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent sent with every request. Programs should
+// name themselves, so that they can be told apart in the API's logs.
+func WithUserAgent(ua string) Option {
+	return func(o *clientOptions) error {
+		o.conch.UA = ua
+		return nil
+	}
+}
+
+// WithHTTPClient sends requests with client. Login keeps the session in a
+// cookie, so for Login to work the client needs a cookie jar.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *clientOptions) error {
+		if client == nil {
+			return errors.New("the HTTP client is nil")
+		}
+		o.conch.HTTPClient = client
+		if jar, ok := client.Jar.(*cookiejar.Jar); ok {
+			o.conch.CookieJar = jar
+		}
+		return nil
+	}
+}
+
+// WithTransport sends requests through rt, in the client Conch builds for
+// itself. It has no effect alongside WithHTTPClient.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *clientOptions) error {
+		o.conch.Transport = rt
+		return nil
+	}
+}
+
+// WithTimeout limits how long a request, including reading its response,
+// may take
+func WithTimeout(d time.Duration) Option {
+	return func(o *clientOptions) error {
+		if d < 0 {
+			return fmt.Errorf("the timeout %s is negative", d)
+		}
+		o.timeout = d
+		return nil
+	}
+}
+
+// WithoutCompression asks the API not to compress its responses
+func WithoutCompression() Option {
+	return func(o *clientOptions) error {
+		o.conch.NoCompression = true
+		return nil
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestNew(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	t.Run("Options", func(t *testing.T) {
+		gock.New("http://conch.test").Get("/version").
+			MatchHeader("Authorization", "^Bearer sekrit$").
+			MatchHeader("User-Agent", "^tester$").
+			Reply(200).
+			JSON(map[string]string{"version": "99.99.99"})
+
+		api, err := conch.New(
+			conch.WithBaseURL("http://conch.test"),
+			conch.WithToken("sekrit"),
+			conch.WithUserAgent("tester"),
+			conch.WithHTTPClient(http.DefaultClient),
+			conch.WithTimeout(time.Minute),
+		)
+		st.Expect(t, err, nil)
+
+		// The caller's client is not changed
+		st.Expect(t, http.DefaultClient.Timeout, time.Duration(0))
+		st.Expect(t, api.HTTPClient.Timeout, time.Minute)
+
+		v, err := api.GetVersion()
+		st.Expect(t, err, nil)
+		st.Expect(t, v, "99.99.99")
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		api, err := conch.New()
+		st.Expect(t, err, nil)
+		st.Expect(t, api.BaseURL, "https://conch.joyent.us")
+		st.Expect(t, api.UA, "go-conch")
+		st.Reject(t, api.HTTPClient, nil)
+		st.Reject(t, api.HTTPClient.Jar, nil)
+	})
+
+	t.Run("BadOptions", func(t *testing.T) {
+		_, err := conch.New(conch.WithBaseURL("conch.test"))
+		st.Reject(t, err, nil)
+
+		_, err = conch.New(conch.WithBaseURL("ftp://conch.test"))
+		st.Reject(t, err, nil)
+
+		_, err = conch.New(conch.WithToken(""))
+		st.Reject(t, err, nil)
+
+		_, err = conch.New(conch.WithHTTPClient(nil))
+		st.Reject(t, err, nil)
+
+		_, err = conch.New(conch.WithTimeout(-time.Second))
+		st.Reject(t, err, nil)
+	})
+}
@@ -0,0 +1,176 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Component kinds
+const (
+	ComponentDisk = "disk"
+	ComponentDIMM = "dimm"
+	ComponentPSU  = "psu"
+	ComponentNIC  = "nic"
+)
+
+// Component is a discrete, replaceable part of a device
+type Component struct {
+	Kind     string `json:"kind"`
+	Slot     string `json:"slot"`
+	Serial   string `json:"serial"`
+	Vendor   string `json:"vendor"`
+	Model    string `json:"model"`
+	Firmware string `json:"firmware"`
+	Size     string `json:"size"`
+	Health   string `json:"health"`
+}
+
+// Components lists the parts of a device that can be told apart by serial
+// number: disks and NICs from the device itself, and DIMMs and power supplies
+// from its latest report. NICs are identified by MAC address.
+func (d Device) Components() []Component {
+	out := make([]Component, 0)
+
+	for _, disk := range d.Disks {
+		slot := strconv.Itoa(disk.Slot)
+		if disk.Enclosure != "" {
+			slot = disk.Enclosure + ":" + slot
+		}
+		size := ""
+		if disk.Size > 0 {
+			size = strconv.Itoa(disk.Size)
+		}
+		out = append(out, Component{
+			Kind:     ComponentDisk,
+			Slot:     slot,
+			Serial:   disk.SerialNumber,
+			Vendor:   disk.Vendor,
+			Model:    disk.Model,
+			Firmware: disk.Firmware,
+			Size:     size,
+			Health:   disk.Health,
+		})
+	}
+
+	for _, nic := range d.Nics {
+		out = append(out, Component{
+			Kind:   ComponentNIC,
+			Slot:   nic.IfaceName,
+			Serial: nic.MAC,
+			Vendor: nic.IfaceVendor,
+			Model:  nic.IfaceType,
+		})
+	}
+
+	out = append(out, reportComponents(d.LatestReport)...)
+	return out
+}
+
+// reportField returns the first of the given keys that the report entry has,
+// as a string. Reports have spelled keys with both dashes and underscores
+// over time.
+func reportField(entry map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		for _, variant := range []string{k, strings.Replace(k, "_", "-", -1)} {
+			switch v := entry[variant].(type) {
+			case nil:
+				continue
+			case string:
+				return v
+			case float64:
+				return strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				return fmt.Sprintf("%v", v)
+			}
+		}
+	}
+	return ""
+}
+
+// reportList returns a list of objects from a report, which may be sent as
+// a list or as a map keyed by slot or serial
+func reportList(v interface{}) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0)
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			if m, ok := e.(map[string]interface{}); ok {
+				out = append(out, m)
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if m, ok := t[k].(map[string]interface{}); ok {
+				// The key is kept, under _key, without touching the report
+				entry := map[string]interface{}{"_key": k}
+				for field, v := range m {
+					entry[field] = v
+				}
+				out = append(out, entry)
+			}
+		}
+	}
+	return out
+}
+
+func reportComponents(report interface{}) []Component {
+	out := make([]Component, 0)
+
+	r, ok := report.(map[string]interface{})
+	if !ok {
+		// Reports that came from somewhere other than JSON decoding, eg
+		// a struct, get a round trip to look the same
+		j, err := json.Marshal(report)
+		if err != nil || json.Unmarshal(j, &r) != nil {
+			return out
+		}
+	}
+
+	for _, dimm := range reportList(r["dimms"]) {
+		serial := reportField(dimm, "memory_serial_number", "serial_number", "serial")
+		if serial == "" || strings.EqualFold(serial, "NO DIMM") {
+			continue
+		}
+		out = append(out, Component{
+			Kind:   ComponentDIMM,
+			Slot:   reportField(dimm, "memory_locator", "locator", "slot", "_key"),
+			Serial: serial,
+			Vendor: reportField(dimm, "memory_manufacturer", "manufacturer", "vendor"),
+			Model:  reportField(dimm, "memory_part_number", "part_number", "model"),
+			Size:   reportField(dimm, "memory_size", "size"),
+		})
+	}
+
+	for _, psu := range reportList(r["psus"]) {
+		serial := reportField(psu, "serial_number", "serial")
+		if serial == "" {
+			continue
+		}
+		out = append(out, Component{
+			Kind:     ComponentPSU,
+			Slot:     reportField(psu, "slot", "name", "_key"),
+			Serial:   serial,
+			Vendor:   reportField(psu, "manufacturer", "vendor"),
+			Model:    reportField(psu, "model", "part_number"),
+			Firmware: reportField(psu, "firmware", "firmware_version"),
+			Size:     reportField(psu, "capacity", "watts"),
+			Health:   reportField(psu, "health", "status"),
+		})
+	}
+
+	return out
+}
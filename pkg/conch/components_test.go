@@ -0,0 +1,52 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+)
+
+func TestDeviceComponents(t *testing.T) {
+	var report interface{}
+	err := json.Unmarshal([]byte(`{
+		"dimms": [
+			{ "memory-locator": "P1-DIMMA1", "memory-serial-number": "D1", "memory-size": 32, "memory-manufacturer": "Samsung" },
+			{ "memory-locator": "P1-DIMMA2", "memory-serial-number": "NO DIMM" }
+		],
+		"psus": {
+			"PSU1": { "serial_number": "P1", "firmware": "1.0", "model": "PWR-1100" }
+		}
+	}`), &report)
+	st.Expect(t, err, nil)
+
+	d := conch.Device{
+		Disks: []conch.Disk{
+			{Enclosure: "0", Slot: 3, SerialNumber: "S3", Firmware: "GS0F", Size: 7630885, Health: "pass"},
+		},
+		Nics: []conch.Nic{
+			{IfaceName: "ixgbe0", MAC: "00:11:22:33:44:55", IfaceVendor: "Intel"},
+		},
+		LatestReport: report,
+	}
+
+	st.Expect(t, d.Components(), []conch.Component{
+		{Kind: conch.ComponentDisk, Slot: "0:3", Serial: "S3", Firmware: "GS0F", Size: "7630885", Health: "pass"},
+		{Kind: conch.ComponentNIC, Slot: "ixgbe0", Serial: "00:11:22:33:44:55", Vendor: "Intel"},
+		{Kind: conch.ComponentDIMM, Slot: "P1-DIMMA1", Serial: "D1", Vendor: "Samsung", Size: "32"},
+		{Kind: conch.ComponentPSU, Slot: "PSU1", Serial: "P1", Model: "PWR-1100", Firmware: "1.0"},
+	})
+
+	// The report is left alone
+	_, ok := report.(map[string]interface{})["psus"].(map[string]interface{})["PSU1"].(map[string]interface{})["_key"]
+	st.Expect(t, ok, false)
+
+	st.Expect(t, conch.Device{}.Components(), []conch.Component{})
+}
@@ -0,0 +1,100 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// compressRequest replaces the body of the request with a gzipped copy of
+// body
+func compressRequest(req *http.Request, body []byte) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	compressed := buf.Bytes()
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(compressed))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	req.ContentLength = int64(len(compressed))
+	req.Header.Set("Content-Encoding", "gzip")
+
+	return nil
+}
+
+// readBody reads the whole response body, undoing any gzip encoding
+func readBody(res *http.Response) ([]byte, error) {
+	if !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return ioutil.ReadAll(res.Body)
+	}
+
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	body, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = int64(len(body))
+	res.Uncompressed = true
+
+	return body, nil
+}
+
+// gzipReadCloser closes both the gzip reader and the body underneath it
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (g gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.body.Close()
+}
+
+// decompressed arranges for the body of an unprocessed response to be read
+// back uncompressed, for callers of the Raw methods
+func decompressed(res *http.Response, err error) (*http.Response, error) {
+	if (res == nil) || (err != nil) {
+		return res, err
+	}
+	if !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return res, nil
+	}
+
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	res.Body = gzipReadCloser{zr, res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	return res, nil
+}
@@ -1,7 +1,11 @@
 package conch_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"testing"
 
@@ -45,4 +49,197 @@ func TestConch(t *testing.T) {
 		st.Expect(t, err, ErrApiUnpacked)
 		st.Expect(t, ret, "")
 	})
+	t.Run("OnMutation", func(t *testing.T) {
+		mutations := make([]conch.Mutation, 0)
+		api := &conch.Conch{
+			BaseURL:    API.BaseURL,
+			HTTPClient: http.DefaultClient,
+			OnMutation: func(m conch.Mutation) {
+				mutations = append(mutations, m)
+			},
+		}
+
+		gock.New(API.BaseURL).Get("/version").Reply(200).
+			JSON(map[string]string{"version": "99.99.99"})
+		gock.New(API.BaseURL).Post("/user/me/settings/test").
+			Reply(400).JSON(ErrApi)
+
+		_, err := api.GetVersion()
+		st.Expect(t, err, nil)
+
+		err = api.SetUserSetting("test", "wat")
+		st.Expect(t, err, ErrApiUnpacked)
+
+		st.Expect(t, len(mutations), 1)
+		st.Expect(t, mutations[0].Method, "POST")
+		st.Expect(t, mutations[0].URL, API.BaseURL+"/user/me/settings/test")
+		st.Expect(t, mutations[0].Status, 400)
+		st.Expect(t, string(mutations[0].Request), `"wat"`)
+		st.Expect(t, string(mutations[0].Response), `{"error":"totally broken"}`)
+	})
+	t.Run("BeforeMutation", func(t *testing.T) {
+		mutations := make([]conch.Mutation, 0)
+		calls := make([]string, 0)
+		api := &conch.Conch{
+			BaseURL:    API.BaseURL,
+			HTTPClient: http.DefaultClient,
+			OnMutation: func(m conch.Mutation) {
+				mutations = append(mutations, m)
+			},
+			BeforeMutation: func(method string, path string) json.RawMessage {
+				calls = append(calls, method+" "+path)
+				return json.RawMessage(`{"asset_tag":"old"}`)
+			},
+		}
+
+		gock.New(API.BaseURL).Get("/version").Reply(200).
+			JSON(map[string]string{"version": "99.99.99"})
+		gock.New(API.BaseURL).Post("/device/a b/asset_tag").Reply(204)
+
+		_, err := api.GetVersion()
+		st.Expect(t, err, nil)
+
+		err = api.SetDeviceAssetTag("a b", "new")
+		st.Expect(t, err, nil)
+
+		st.Expect(t, calls, []string{"POST /device/a b/asset_tag"})
+		st.Expect(t, len(mutations), 1)
+		st.Expect(t, string(mutations[0].Before), `{"asset_tag":"old"}`)
+	})
+	t.Run("BeforeRequest", func(t *testing.T) {
+		budget := errors.New("over budget")
+		calls := make([]string, 0)
+		api := &conch.Conch{
+			BaseURL:    API.BaseURL,
+			HTTPClient: http.DefaultClient,
+			BeforeRequest: func(method string, path string) error {
+				calls = append(calls, method+" "+path)
+				if len(calls) > 1 {
+					return budget
+				}
+				return nil
+			},
+		}
+		api.EnableMemoization()
+		defer api.DisableMemoization()
+
+		gock.New(API.BaseURL).Get("/version").Times(1).Reply(200).
+			JSON(map[string]string{"version": "1.0.0"})
+
+		_, err := api.GetVersion()
+		st.Expect(t, err, nil)
+
+		// Replayed from the memo, so not a request at all
+		_, err = api.GetVersion()
+		st.Expect(t, err, nil)
+
+		err = api.SetUserSetting("test", "wat")
+		st.Expect(t, err, budget)
+
+		st.Expect(t, calls, []string{"GET /version", "POST /user/me/settings/test"})
+	})
+	t.Run("Memoization", func(t *testing.T) {
+		api := &conch.Conch{
+			BaseURL:    API.BaseURL,
+			HTTPClient: http.DefaultClient,
+		}
+		api.EnableMemoization()
+		defer api.DisableMemoization()
+
+		gock.New(API.BaseURL).Get("/version").Times(1).Reply(200).
+			JSON(map[string]string{"version": "1.0.0"})
+
+		ret, err := api.GetVersion()
+		st.Expect(t, err, nil)
+		st.Expect(t, ret, "1.0.0")
+
+		ret, err = api.GetVersion()
+		st.Expect(t, err, nil)
+		st.Expect(t, ret, "1.0.0")
+		st.Expect(t, gock.IsDone(), true)
+
+		// A write forgets what was remembered
+		gock.New(API.BaseURL).Post("/user/me/settings/test").Reply(204)
+		gock.New(API.BaseURL).Get("/version").Times(1).Reply(200).
+			JSON(map[string]string{"version": "2.0.0"})
+
+		st.Expect(t, api.SetUserSetting("test", "wat"), nil)
+
+		ret, err = api.GetVersion()
+		st.Expect(t, err, nil)
+		st.Expect(t, ret, "2.0.0")
+		st.Expect(t, gock.IsDone(), true)
+
+		// Failures are not remembered
+		gock.New(API.BaseURL).Get("/user/me/settings/nope").Times(2).Reply(404)
+
+		_, err = api.GetUserSetting("nope")
+		st.Expect(t, err, conch.ErrDataNotFound)
+		_, err = api.GetUserSetting("nope")
+		st.Expect(t, err, conch.ErrDataNotFound)
+		st.Expect(t, gock.IsDone(), true)
+	})
+	t.Run("Compression", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(`{"version":"1.2.3"}`))
+		zw.Close()
+
+		gock.New(API.BaseURL).Get("/version").
+			MatchHeader("Accept-Encoding", "^gzip$").
+			Reply(200).
+			SetHeader("Content-Encoding", "gzip").
+			Body(bytes.NewReader(buf.Bytes()))
+
+		ret, err := API.GetVersion()
+		st.Expect(t, err, nil)
+		st.Expect(t, ret, "1.2.3")
+
+		api := &conch.Conch{
+			BaseURL:       API.BaseURL,
+			HTTPClient:    http.DefaultClient,
+			NoCompression: true,
+		}
+		gock.New(API.BaseURL).Get("/version").
+			MatchHeader("Accept-Encoding", "^identity$").
+			Reply(200).JSON(map[string]string{"version": "1.2.3"})
+
+		ret, err = api.GetVersion()
+		st.Expect(t, err, nil)
+		st.Expect(t, ret, "1.2.3")
+
+		api = &conch.Conch{
+			BaseURL:              API.BaseURL,
+			HTTPClient:           http.DefaultClient,
+			CompressRequestsOver: 4,
+		}
+		gock.New(API.BaseURL).Post("/user/me/settings/test").
+			MatchHeader("Content-Encoding", "^gzip$").
+			Reply(204)
+
+		st.Expect(t, api.SetUserSetting("test", "a long enough value"), nil)
+		st.Expect(t, gock.IsDone(), true)
+
+		gock.New(API.BaseURL).Get("/version").
+			Reply(200).
+			SetHeader("Content-Encoding", "gzip").
+			Body(bytes.NewReader(buf.Bytes()))
+
+		res, err := API.RawGet("/version")
+		st.Expect(t, err, nil)
+		raw, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		st.Expect(t, err, nil)
+		st.Expect(t, string(raw), `{"version":"1.2.3"}`)
+	})
+	t.Run("TimedGet", func(t *testing.T) {
+		gock.New(API.BaseURL).Get("/version").Reply(200).
+			JSON(map[string]string{"version": "1.2.3"})
+
+		timing, err := API.TimedGet("/version")
+		st.Expect(t, err, nil)
+		st.Expect(t, timing.Status, 200)
+		st.Expect(t, timing.Bytes > 0, true)
+		st.Expect(t, timing.Total >= timing.Server, true)
+	})
 }
@@ -0,0 +1,126 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CustomFieldPrefix starts the names of the device settings that hold the
+// values of custom fields, eg "field.cost_center"
+const CustomFieldPrefix = "field."
+
+// The types of custom field
+const (
+	CustomFieldString  = "string"
+	CustomFieldNumber  = "number"
+	CustomFieldBoolean = "boolean"
+	CustomFieldDate    = "date"
+)
+
+// CustomFieldTypes lists every type a custom field can have
+var CustomFieldTypes = []string{
+	CustomFieldString,
+	CustomFieldNumber,
+	CustomFieldBoolean,
+	CustomFieldDate,
+}
+
+// customFieldDate is how dates are written in custom fields
+const customFieldDate = "2006-01-02"
+
+// CustomField is the definition of a piece of information a workspace keeps
+// about each of its devices, beyond what Conch itself knows. The values are
+// kept as device settings.
+type CustomField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+// CustomFieldSetting is the name of the device setting that holds the value
+// of a custom field
+func CustomFieldSetting(name string) string {
+	return CustomFieldPrefix + name
+}
+
+// Validate checks that the field can be used: that its name can be part of a
+// setting name and that its type is known
+func (f CustomField) Validate() error {
+	if f.Name == "" {
+		return errors.New("a custom field needs a name")
+	}
+	if strings.ContainsAny(f.Name, "/ \t") {
+		return fmt.Errorf("the name of custom field '%s' can't contain slashes or spaces", f.Name)
+	}
+	for _, t := range CustomFieldTypes {
+		if f.Type == t {
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"custom field %s can't be of type '%s'. Use one of: %s",
+		f.Name,
+		f.Type,
+		strings.Join(CustomFieldTypes, ", "),
+	)
+}
+
+// Check makes sure a value fits the field, and returns it in the form it is
+// stored in, eg "true" for a boolean given as "yes"
+func (f CustomField) Check(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if f.Required {
+			return "", fmt.Errorf("custom field %s is required, and can't be empty", f.Name)
+		}
+		return "", nil
+	}
+
+	switch f.Type {
+	case CustomFieldNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("custom field %s is a number, not '%s'", f.Name, value)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+
+	case CustomFieldBoolean:
+		switch strings.ToLower(value) {
+		case "true", "yes", "y", "1":
+			return "true", nil
+		case "false", "no", "n", "0":
+			return "false", nil
+		}
+		return "", fmt.Errorf("custom field %s is true or false, not '%s'", f.Name, value)
+
+	case CustomFieldDate:
+		d, err := time.Parse(customFieldDate, value)
+		if err != nil {
+			return "", fmt.Errorf("custom field %s is a date, like 2019-12-31, not '%s'", f.Name, value)
+		}
+		return d.Format(customFieldDate), nil
+	}
+
+	return value, nil
+}
+
+// DeviceCustomFields picks the values of custom fields out of a device's
+// settings, keyed by field name
+func DeviceCustomFields(settings map[string]string) map[string]string {
+	fields := make(map[string]string)
+	for k, v := range settings {
+		if strings.HasPrefix(k, CustomFieldPrefix) {
+			fields[strings.TrimPrefix(k, CustomFieldPrefix)] = v
+		}
+	}
+	return fields
+}
@@ -0,0 +1,58 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+)
+
+func TestCustomFields(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		st.Expect(t, conch.CustomField{Name: "cost_center", Type: conch.CustomFieldString}.Validate(), nil)
+		st.Reject(t, conch.CustomField{Name: "", Type: conch.CustomFieldString}.Validate(), nil)
+		st.Reject(t, conch.CustomField{Name: "a/b", Type: conch.CustomFieldString}.Validate(), nil)
+		st.Reject(t, conch.CustomField{Name: "ok", Type: "color"}.Validate(), nil)
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		checks := []struct {
+			field conch.CustomField
+			in    string
+			out   string
+			ok    bool
+		}{
+			{conch.CustomField{Name: "s", Type: conch.CustomFieldString}, " x ", "x", true},
+			{conch.CustomField{Name: "s", Type: conch.CustomFieldString}, "", "", true},
+			{conch.CustomField{Name: "s", Type: conch.CustomFieldString, Required: true}, "", "", false},
+			{conch.CustomField{Name: "n", Type: conch.CustomFieldNumber}, "1.50", "1.5", true},
+			{conch.CustomField{Name: "n", Type: conch.CustomFieldNumber}, "lots", "", false},
+			{conch.CustomField{Name: "b", Type: conch.CustomFieldBoolean}, "Yes", "true", true},
+			{conch.CustomField{Name: "b", Type: conch.CustomFieldBoolean}, "0", "false", true},
+			{conch.CustomField{Name: "b", Type: conch.CustomFieldBoolean}, "maybe", "", false},
+			{conch.CustomField{Name: "d", Type: conch.CustomFieldDate}, "2019-12-31", "2019-12-31", true},
+			{conch.CustomField{Name: "d", Type: conch.CustomFieldDate}, "31/12/2019", "", false},
+		}
+		for _, c := range checks {
+			out, err := c.field.Check(c.in)
+			st.Expect(t, out, c.out)
+			st.Expect(t, err == nil, c.ok)
+		}
+	})
+
+	t.Run("DeviceCustomFields", func(t *testing.T) {
+		fields := conch.DeviceCustomFields(map[string]string{
+			"field.owner":  "ops",
+			"field.racked": "true",
+			"build.status": "done",
+		})
+		st.Expect(t, fields, map[string]string{"owner": "ops", "racked": "true"})
+		st.Expect(t, conch.CustomFieldSetting("owner"), "field.owner")
+	})
+}
@@ -0,0 +1,187 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// The device settings that hold a device's management metadata. Reports only
+// tell us what the device says about itself, so these are where people record
+// what it should be.
+const (
+	SettingHostname   = "hostname"
+	SettingBMCAddress = "bmc.address"
+	SettingBMCUser    = "bmc.user"
+	SettingBMCVendor  = "bmc.vendor"
+	SettingBMCMAC     = "bmc.mac"
+)
+
+// DeviceBMC is what is known about how to reach a device's BMC. There is
+// deliberately no password: device settings can be read by anyone with
+// access to the device's workspace.
+type DeviceBMC struct {
+	Address string `json:"address,omitempty"`
+	User    string `json:"user,omitempty"`
+	Vendor  string `json:"vendor,omitempty"`
+	MAC     string `json:"mac,omitempty"`
+}
+
+var hostnameLabelRe = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// ValidHostname returns an error if h is not an RFC 1123 host name
+func ValidHostname(h string) error {
+	if h == "" || len(h) > 253 {
+		return fmt.Errorf("'%s' is not a valid host name", h)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(h, "."), ".") {
+		if !hostnameLabelRe.MatchString(label) {
+			return fmt.Errorf("'%s' is not a valid host name", h)
+		}
+	}
+	return nil
+}
+
+// validBMCAddress accepts a host name or IP address, with or without a port,
+// or an http(s) URL
+func validBMCAddress(a string) error {
+	if strings.Contains(a, "://") {
+		u, err := url.Parse(a)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("'%s' is not a valid BMC URL", a)
+		}
+		return nil
+	}
+
+	host := a
+	if h, port, err := net.SplitHostPort(a); err == nil {
+		if port == "" {
+			return fmt.Errorf("'%s' is not a valid BMC address", a)
+		}
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if ValidHostname(host) != nil {
+		return fmt.Errorf("'%s' is not a valid BMC address", a)
+	}
+	return nil
+}
+
+// Validate checks the fields that are set
+func (b DeviceBMC) Validate() error {
+	if b.Address != "" {
+		if err := validBMCAddress(b.Address); err != nil {
+			return err
+		}
+	}
+	if b.MAC != "" {
+		if _, err := net.ParseMAC(b.MAC); err != nil {
+			return fmt.Errorf("'%s' is not a valid MAC address", b.MAC)
+		}
+	}
+	if strings.ContainsAny(b.User, " \t\n") {
+		return fmt.Errorf("'%s' is not a valid BMC user name", b.User)
+	}
+	return nil
+}
+
+// Settings is the device settings the fields that are set are stored in
+func (b DeviceBMC) Settings() map[string]string {
+	s := make(map[string]string)
+	if b.Address != "" {
+		s[SettingBMCAddress] = b.Address
+	}
+	if b.User != "" {
+		s[SettingBMCUser] = b.User
+	}
+	if b.Vendor != "" {
+		s[SettingBMCVendor] = b.Vendor
+	}
+	if b.MAC != "" {
+		mac, err := net.ParseMAC(b.MAC)
+		if err == nil {
+			s[SettingBMCMAC] = mac.String()
+		} else {
+			s[SettingBMCMAC] = b.MAC
+		}
+	}
+	return s
+}
+
+// IsEmpty is true if nothing is known about the BMC
+func (b DeviceBMC) IsEmpty() bool {
+	return b == DeviceBMC{}
+}
+
+// GetDeviceBMC fetches the BMC metadata recorded in the device's settings
+func (c *Conch) GetDeviceBMC(serial string) (DeviceBMC, error) {
+	settings, err := c.GetDeviceSettings(serial)
+	if err != nil {
+		return DeviceBMC{}, err
+	}
+	return DeviceBMC{
+		Address: settings[SettingBMCAddress],
+		User:    settings[SettingBMCUser],
+		Vendor:  settings[SettingBMCVendor],
+		MAC:     settings[SettingBMCMAC],
+	}, nil
+}
+
+// SetDeviceBMC records the fields of b that are set in the device's settings,
+// leaving the others alone. Nothing is written if b doesn't validate.
+func (c *Conch) SetDeviceBMC(serial string, b DeviceBMC) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	for k, v := range b.Settings() {
+		if err := c.SetDeviceSetting(serial, k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClearDeviceBMC removes all of the device's BMC metadata
+func (c *Conch) ClearDeviceBMC(serial string) error {
+	b, err := c.GetDeviceBMC(serial)
+	if err != nil {
+		return err
+	}
+
+	// The keys of Settings() are just the ones that hold something
+	for k := range b.Settings() {
+		if err := c.DeleteDeviceSetting(serial, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDeviceBMCAddress is the best address for the device's BMC: the one
+// recorded in its settings, or else the address of its ipmi1 interface. It is
+// "" if neither is known.
+func (c *Conch) GetDeviceBMCAddress(serial string) (string, error) {
+	addr, err := c.GetDeviceSetting(serial, SettingBMCAddress)
+	if err != nil && err != ErrDataNotFound {
+		return "", err
+	}
+	if addr != "" {
+		return addr, nil
+	}
+
+	addr, err = c.GetDeviceIPMI(serial)
+	if err == ErrDataNotFound {
+		return "", nil
+	}
+	return addr, err
+}
@@ -0,0 +1,89 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestDeviceBMC(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	t.Run("ValidHostname", func(t *testing.T) {
+		st.Expect(t, conch.ValidHostname("compute-01.example.com"), nil)
+		st.Reject(t, conch.ValidHostname("-nope"), nil)
+		st.Reject(t, conch.ValidHostname("a..b"), nil)
+		st.Reject(t, conch.ValidHostname("has space"), nil)
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		good := []conch.DeviceBMC{
+			{Address: "10.1.2.3"},
+			{Address: "10.1.2.3:443"},
+			{Address: "bmc-01.example.com"},
+			{Address: "https://bmc-01.example.com/redfish"},
+			{MAC: "00:25:90:aa:bb:cc"},
+			{User: "root", Vendor: "supermicro"},
+		}
+		for _, b := range good {
+			st.Expect(t, b.Validate(), nil)
+		}
+
+		bad := []conch.DeviceBMC{
+			{Address: "not an address"},
+			{Address: "ftp://bmc"},
+			{MAC: "00:25:90"},
+			{User: "two words"},
+		}
+		for _, b := range bad {
+			st.Reject(t, b.Validate(), nil)
+		}
+	})
+
+	t.Run("GetDeviceBMC", func(t *testing.T) {
+		gock.New(API.BaseURL).Get("/device/test/settings").Reply(200).
+			JSON(map[string]string{
+				"bmc.address": "10.1.2.3",
+				"bmc.user":    "root",
+				"build":       "ok",
+			})
+
+		b, err := API.GetDeviceBMC("test")
+		st.Expect(t, err, nil)
+		st.Expect(t, b, conch.DeviceBMC{Address: "10.1.2.3", User: "root"})
+	})
+
+	t.Run("SetDeviceBMC", func(t *testing.T) {
+		gock.New(API.BaseURL).Post("/device/test/settings/bmc.mac").
+			JSON(map[string]string{"bmc.mac": "00:25:90:aa:bb:cc"}).
+			Reply(204)
+
+		err := API.SetDeviceBMC("test", conch.DeviceBMC{MAC: "00-25-90-AA-BB-CC"})
+		st.Expect(t, err, nil)
+		st.Expect(t, gock.IsDone(), true)
+
+		// Nothing is written when a field is bad
+		err = API.SetDeviceBMC("test", conch.DeviceBMC{User: "root", MAC: "nope"})
+		st.Reject(t, err, nil)
+	})
+
+	t.Run("GetDeviceBMCAddress", func(t *testing.T) {
+		gock.New(API.BaseURL).Get("/device/test/settings/bmc.address").
+			Reply(404).JSON(map[string]string{"error": "Not Found"})
+		gock.New(API.BaseURL).Get("/device/test/interface/ipmi1/ipaddr").
+			Reply(200).JSON(map[string]string{"ipaddr": "10.9.9.9"})
+
+		addr, err := API.GetDeviceBMCAddress("test")
+		st.Expect(t, err, nil)
+		st.Expect(t, addr, "10.9.9.9")
+	})
+}
@@ -0,0 +1,242 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+)
+
+// DeviceReport is a report as a device sent it, as stored by the API
+type DeviceReport struct {
+	ID            uuid.UUID       `json:"id"`
+	DeviceID      string          `json:"device_id"`
+	Created       time.Time       `json:"created"`
+	Report        json.RawMessage `json:"report"`
+	InvalidReport string          `json:"invalid_report,omitempty"`
+}
+
+// StoredDeviceReport is a report the API kept for a device, as found in the
+// device's validation history. Status is the worst status of the validation
+// plans that were run against the report.
+type StoredDeviceReport struct {
+	ID       uuid.UUID `json:"id"`
+	DeviceID string    `json:"device_id"`
+	Created  time.Time `json:"created"`
+	Status   string    `json:"status"`
+	Plans    int       `json:"validation_plans"`
+}
+
+// statusRank orders validation statuses from best to worst
+var statusRank = map[string]int{
+	"pass":  1,
+	"fail":  2,
+	"error": 3,
+}
+
+// GetDeviceReport fetches a stored device report via /device_report/:id
+func (c *Conch) GetDeviceReport(id fmt.Stringer) (DeviceReport, error) {
+	var r DeviceReport
+	return r, c.get("/device_report/"+url.PathEscape(id.String()), &r)
+}
+
+// GetDeviceReports lists the reports stored for a device, newest first. The
+// API has no listing of reports, so they are gathered from every validation
+// state in the device's history. A limit greater than zero asks for only
+// that many of the most recent validation states.
+func (c *Conch) GetDeviceReports(deviceSerial string, limit int) ([]StoredDeviceReport, error) {
+	reports := make([]StoredDeviceReport, 0)
+
+	states, err := c.DeviceValidationHistory(deviceSerial, limit)
+	if err != nil {
+		return reports, err
+	}
+
+	byID := make(map[uuid.UUID]int)
+	for _, s := range states {
+		if uuid.Equal(s.DeviceReportID, uuid.UUID{}) {
+			continue
+		}
+		i, ok := byID[s.DeviceReportID]
+		if !ok {
+			byID[s.DeviceReportID] = len(reports)
+			reports = append(reports, StoredDeviceReport{
+				ID:       s.DeviceReportID,
+				DeviceID: s.DeviceID,
+				Created:  s.Created,
+				Status:   s.Status,
+			})
+			i = len(reports) - 1
+		}
+
+		r := &reports[i]
+		r.Plans++
+		if s.Created.Before(r.Created) {
+			r.Created = s.Created
+		}
+		if statusRank[s.Status] > statusRank[r.Status] {
+			r.Status = s.Status
+		}
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Created.After(reports[j].Created)
+	})
+	return reports, nil
+}
+
+// ReportDisk is a disk as a device report describes it
+type ReportDisk struct {
+	Serial    string `json:"serial"`
+	Enclosure string `json:"enclosure,omitempty"`
+	Slot      string `json:"slot,omitempty"`
+	DriveType string `json:"drive_type,omitempty"`
+	Transport string `json:"transport,omitempty"`
+	Vendor    string `json:"vendor,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Size      string `json:"size,omitempty"`
+	Firmware  string `json:"firmware,omitempty"`
+	Health    string `json:"health,omitempty"`
+}
+
+// ReportNIC is a network interface as a device report describes it
+type ReportNIC struct {
+	Name       string `json:"name"`
+	MAC        string `json:"mac"`
+	IPAddr     string `json:"ipaddr,omitempty"`
+	Vendor     string `json:"vendor,omitempty"`
+	Product    string `json:"product,omitempty"`
+	State      string `json:"state,omitempty"`
+	MTU        string `json:"mtu,omitempty"`
+	PeerSwitch string `json:"peer_switch,omitempty"`
+	PeerPort   string `json:"peer_port,omitempty"`
+}
+
+// ReportSummary is the readable part of a device report: what the device
+// said it is and what it has in it
+type ReportSummary struct {
+	SerialNumber string       `json:"serial_number"`
+	SystemUUID   string       `json:"system_uuid,omitempty"`
+	ProductName  string       `json:"product_name,omitempty"`
+	Hostname     string       `json:"hostname,omitempty"`
+	BiosVersion  string       `json:"bios_version,omitempty"`
+	Relay        string       `json:"relay,omitempty"`
+	CPUCount     int          `json:"cpu_count"`
+	CPUType      string       `json:"cpu_type,omitempty"`
+	MemoryTotal  int          `json:"memory_total"`
+	DIMMCount    int          `json:"dimm_count"`
+	DIMMs        []Component  `json:"dimms"`
+	Disks        []ReportDisk `json:"disks"`
+	NICs         []ReportNIC  `json:"nics"`
+	PSUs         []Component  `json:"psus"`
+}
+
+// SummarizeReport pulls the readable parts out of a device report. Reports
+// have changed shape over time, so anything missing is left empty rather
+// than being an error.
+func SummarizeReport(raw []byte) (ReportSummary, error) {
+	s := ReportSummary{
+		DIMMs: make([]Component, 0),
+		Disks: make([]ReportDisk, 0),
+		NICs:  make([]ReportNIC, 0),
+		PSUs:  make([]Component, 0),
+	}
+
+	r := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return s, fmt.Errorf("not a device report: %s", err)
+	}
+
+	s.SerialNumber = reportField(r, "serial_number")
+	s.SystemUUID = reportField(r, "system_uuid")
+	s.ProductName = reportField(r, "product_name")
+	s.BiosVersion = reportField(r, "bios_version")
+
+	if host, ok := r["os"].(map[string]interface{}); ok {
+		s.Hostname = reportField(host, "hostname")
+	}
+	if relay, ok := r["relay"].(map[string]interface{}); ok {
+		s.Relay = reportField(relay, "serial")
+	}
+
+	if p, ok := r["processor"].(map[string]interface{}); ok {
+		s.CPUCount, _ = strconv.Atoi(reportField(p, "count"))
+		s.CPUType = reportField(p, "type")
+	}
+	if m, ok := r["memory"].(map[string]interface{}); ok {
+		s.DIMMCount, _ = strconv.Atoi(reportField(m, "count"))
+		total, _ := strconv.ParseFloat(reportField(m, "total"), 64)
+		s.MemoryTotal = int(total + 0.5)
+	}
+
+	for _, c := range reportComponents(r) {
+		switch c.Kind {
+		case ComponentDIMM:
+			s.DIMMs = append(s.DIMMs, c)
+		case ComponentPSU:
+			s.PSUs = append(s.PSUs, c)
+		}
+	}
+
+	// Disks are keyed by serial, and interfaces by name
+	for _, d := range reportList(r["disks"]) {
+		serial := reportField(d, "serial_number", "serial")
+		if serial == "" {
+			serial = reportField(d, "_key")
+		}
+		s.Disks = append(s.Disks, ReportDisk{
+			Serial:    serial,
+			Enclosure: reportField(d, "enclosure"),
+			Slot:      reportField(d, "slot"),
+			DriveType: reportField(d, "drive_type"),
+			Transport: reportField(d, "transport"),
+			Vendor:    reportField(d, "vendor"),
+			Model:     reportField(d, "model"),
+			Size:      reportField(d, "size"),
+			Firmware:  reportField(d, "firmware"),
+			Health:    reportField(d, "health"),
+		})
+	}
+	sort.SliceStable(s.Disks, func(i, j int) bool {
+		a, b := s.Disks[i], s.Disks[j]
+		if a.Enclosure != b.Enclosure {
+			return a.Enclosure < b.Enclosure
+		}
+		ai, aErr := strconv.Atoi(a.Slot)
+		bi, bErr := strconv.Atoi(b.Slot)
+		if aErr == nil && bErr == nil {
+			return ai < bi
+		}
+		return a.Slot < b.Slot
+	})
+
+	for _, n := range reportList(r["interfaces"]) {
+		name := reportField(n, "iface_name", "name")
+		if name == "" {
+			name = reportField(n, "_key")
+		}
+		s.NICs = append(s.NICs, ReportNIC{
+			Name:       name,
+			MAC:        reportField(n, "mac"),
+			IPAddr:     reportField(n, "ipaddr"),
+			Vendor:     reportField(n, "vendor"),
+			Product:    reportField(n, "product"),
+			State:      reportField(n, "state"),
+			MTU:        reportField(n, "mtu"),
+			PeerSwitch: reportField(n, "peer_switch"),
+			PeerPort:   reportField(n, "peer_port"),
+		})
+	}
+
+	return s, nil
+}
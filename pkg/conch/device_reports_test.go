@@ -0,0 +1,92 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestDeviceReports(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	t.Run("GetDeviceReport", func(t *testing.T) {
+		id := uuid.NewV4()
+		gock.New(API.BaseURL).Get("/device_report/" + id.String()).
+			Reply(400).JSON(ErrApi)
+
+		_, err := API.GetDeviceReport(id)
+		st.Expect(t, err, ErrApiUnpacked)
+	})
+
+	t.Run("GetDeviceReports", func(t *testing.T) {
+		older := uuid.NewV4()
+		newer := uuid.NewV4()
+
+		gock.New(API.BaseURL).Get("/device/test/validation_state").
+			MatchParam("history", "^1$").
+			Reply(200).JSON([]map[string]interface{}{
+			{"device_id": "test", "device_report_id": older.String(), "status": "pass", "created": "2019-01-01T00:00:00Z"},
+			{"device_id": "test", "device_report_id": newer.String(), "status": "pass", "created": "2019-02-01T00:00:01Z"},
+			{"device_id": "test", "device_report_id": newer.String(), "status": "fail", "created": "2019-02-01T00:00:00Z"},
+			{"device_id": "test", "status": "error", "created": "2019-03-01T00:00:00Z"},
+		})
+
+		ret, err := API.GetDeviceReports("test", 0)
+		st.Expect(t, err, nil)
+		st.Expect(t, len(ret), 2)
+		st.Expect(t, ret[0].ID, newer)
+		st.Expect(t, ret[0].Status, "fail")
+		st.Expect(t, ret[0].Plans, 2)
+		st.Expect(t, ret[0].Created.Format("15:04:05"), "00:00:00")
+		st.Expect(t, ret[1].ID, older)
+	})
+}
+
+func TestSummarizeReport(t *testing.T) {
+	s, err := conch.SummarizeReport([]byte(`{
+		"serial_number": "S1",
+		"product_name": "Joyent-Compute-Platform",
+		"bios_version": "2.1.7",
+		"os": { "hostname": "s1.example.com" },
+		"relay": { "serial": "R1" },
+		"processor": { "count": 2, "type": "Intel(R) Xeon(R)" },
+		"memory": { "count": 12, "total": 383.8 },
+		"dimms": [ { "memory-locator": "A1", "memory-serial-number": "D1" } ],
+		"disks": {
+			"Z2": { "slot": 10, "drive_type": "SAS_HDD", "enclosure": "0" },
+			"Z1": { "slot": 2, "drive_type": "SAS_HDD", "enclosure": "0" }
+		},
+		"interfaces": {
+			"eth0": { "mac": "00:00:00:00:00:01", "peer_switch": "sw1", "peer_port": "1" }
+		}
+	}`))
+	st.Expect(t, err, nil)
+
+	st.Expect(t, s.SerialNumber, "S1")
+	st.Expect(t, s.Hostname, "s1.example.com")
+	st.Expect(t, s.Relay, "R1")
+	st.Expect(t, s.CPUCount, 2)
+	st.Expect(t, s.MemoryTotal, 384)
+	st.Expect(t, s.DIMMCount, 12)
+	st.Expect(t, len(s.DIMMs), 1)
+	st.Expect(t, len(s.PSUs), 0)
+	st.Expect(t, len(s.Disks), 2)
+	st.Expect(t, s.Disks[0].Serial, "Z1")
+	st.Expect(t, s.Disks[1].Serial, "Z2")
+	st.Expect(t, s.NICs, []conch.ReportNIC{
+		{Name: "eth0", MAC: "00:00:00:00:00:01", PeerSwitch: "sw1", PeerPort: "1"},
+	})
+
+	_, err = conch.SummarizeReport([]byte(`[]`))
+	st.Reject(t, err, nil)
+}
@@ -216,10 +216,18 @@ func (c *Conch) SetDeviceAssetTag(serial string, tag string) error {
 
 // GetDeviceIPMI retrieves "/device/:serial/interface/impi1/ipaddr"
 func (c *Conch) GetDeviceIPMI(serial string) (string, error) {
+	return c.GetDeviceInterfaceIP(serial, "ipmi1")
+}
+
+// GetDeviceInterfaceIP retrieves "/device/:serial/interface/:iface/ipaddr"
+func (c *Conch) GetDeviceInterfaceIP(serial string, iface string) (string, error) {
 	j := make(map[string]string)
 
 	escaped := url.PathEscape(serial)
-	if err := c.get("/device/"+escaped+"/interface/ipmi1/ipaddr", &j); err != nil {
+	if err := c.get(
+		"/device/"+escaped+"/interface/"+url.PathEscape(iface)+"/ipaddr",
+		&j,
+	); err != nil {
 		return "", err
 	}
 
@@ -264,3 +272,30 @@ func (c *Conch) SetDevicePhase(serial string, phase string) error {
 
 	return c.post("/device/"+url.PathEscape(serial)+"/phase", data, nil)
 }
+
+// DecommissionedPhase is the phase of a device that has been taken out of
+// service for good
+const DecommissionedPhase = "decommissioned"
+
+// IntegrationPhase is the phase of a device that is still being built and
+// validated, before it is handed over to production
+const IntegrationPhase = "integration"
+
+// The settings 'conch device decommission' leaves on a device, so that the
+// device can still be accounted for once it no longer has a location
+const (
+	DecommissionSettingPrefix    = "decommission."
+	DecommissionDateSetting      = "decommission.date"
+	DecommissionUserSetting      = "decommission.user"
+	DecommissionNoteSetting      = "decommission.note"
+	DecommissionLocationSetting  = "decommission.location"
+	DecommissionWorkspaceSetting = "decommission.workspace"
+)
+
+// The settings 'conch admin device merge' leaves on the two records of a
+// device that was registered twice. MergedFromSetting, on the record that
+// was kept, lists the serials merged into it, separated by commas.
+const (
+	MergedIntoSetting = "merged_into"
+	MergedFromSetting = "merged_from"
+)
@@ -101,6 +101,26 @@ func TestDevices(t *testing.T) {
 		st.Expect(t, err, ErrApiUnpacked)
 	})
 
+	t.Run("GetDeviceInterfaceIP", func(t *testing.T) {
+		serial := "test"
+		gock.New(API.BaseURL).Get("/device/" + serial + "/interface/eth0/ipaddr").
+			Reply(200).JSON(map[string]string{"ipaddr": "192.168.1.10"})
+
+		ret, err := API.GetDeviceInterfaceIP(serial, "eth0")
+		st.Expect(t, err, nil)
+		st.Expect(t, ret, "192.168.1.10")
+	})
+
+	t.Run("GetDeviceInterfaceIPErrors", func(t *testing.T) {
+		serial := "test"
+		gock.New(API.BaseURL).Get("/device/" + serial + "/interface/eth0/ipaddr").
+			Reply(400).JSON(ErrApi)
+
+		ret, err := API.GetDeviceInterfaceIP(serial, "eth0")
+		st.Expect(t, err, ErrApiUnpacked)
+		st.Expect(t, ret, "")
+	})
+
 	t.Run("GetDevicesByField", func(t *testing.T) {
 		var d conch.Devices
 
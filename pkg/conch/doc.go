@@ -0,0 +1,52 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+/*
+Package conch provides access to the Conch API.
+
+The package grew up alongside the conch shell, and most of it changes
+whenever the shell needs it to. A subset of it is kept stable, for programs
+outside the shell that want to talk to Conch without following its
+internals. That subset follows semantic versioning, as LibraryVersion: it is
+only changed incompatibly when the major version goes up. Everything else
+may change in any release.
+
+The stable subset is:
+
+Building a client: New and its options, WithBaseURL, WithToken,
+WithUserAgent, WithHTTPClient, WithTransport, WithTimeout, and
+WithoutCompression.
+
+Authentication: Login, VerifyToken, GetVersion, and GetUserProfile.
+
+Devices: GetDevice, GetDeviceLocation, GetDevicePhase, SetDevicePhase,
+SetDeviceAssetTag, GetDeviceSettings, GetDeviceSetting, SetDeviceSetting,
+DeleteDeviceSetting, GetDeviceTags, and SetDeviceTag.
+
+Racks: GetRacks, GetRack, GetRackLayout, and GetRackAssignments.
+
+Workspaces: GetWorkspaces, GetWorkspace, GetWorkspaceByName,
+GetSubWorkspaces, GetWorkspaceDevices, and GetWorkspaceRacks.
+
+The types those use and return, and the errors in errors.go, are stable as
+well. Fields may be added to the types, but not taken away or changed.
+
+A client is built with New:
+
+	api, err := conch.New(
+		conch.WithBaseURL("https://conch.example.com"),
+		conch.WithToken(os.Getenv("CONCH_TOKEN")),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d, err := api.GetDevice("SERIAL")
+
+A Conch may also be built as a struct literal, as the shell does. That
+works, but the fields of Conch are not part of the stable subset.
+*/
+package conch
@@ -0,0 +1,118 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+)
+
+// These examples talk to a real Conch, so they are compiled but not run
+
+func ExampleNew() {
+	api, err := conch.New(
+		conch.WithBaseURL("https://conch.example.com"),
+		conch.WithToken(os.Getenv("CONCH_TOKEN")),
+		conch.WithUserAgent("rack-checker/1.0"),
+		conch.WithTimeout(30*time.Second),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	v, err := api.GetVersion()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Conch API", v)
+}
+
+func ExampleConch_Login() {
+	api, err := conch.New(conch.WithBaseURL("https://conch.example.com"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = api.Login("user@example.com", os.Getenv("CONCH_PASSWORD"))
+	if err == conch.ErrMustChangePassword {
+		log.Fatal("the password has to be changed, eg with 'conch user change-password'")
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	profile, err := api.GetUserProfile()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Logged in as", profile.Email)
+}
+
+func ExampleConch_GetDevice() {
+	api, err := conch.New(conch.WithToken(os.Getenv("CONCH_TOKEN")))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d, err := api.GetDevice("SERIAL")
+	if err == conch.ErrDataNotFound {
+		log.Fatal("no such device")
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(d.ID, d.Health, d.Phase)
+
+	loc, err := api.GetDeviceLocation(d.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s RU %d\n", loc.Rack.Name, loc.RackUnitStart)
+}
+
+func ExampleConch_GetWorkspaceDevices() {
+	api, err := conch.New(conch.WithToken(os.Getenv("CONCH_TOKEN")))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ws, err := api.GetWorkspaceByName("GLOBAL")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Failing devices, whether or not they have graduated or validated
+	devices, err := api.GetWorkspaceDevices(ws.ID, false, "", "fail", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, d := range devices {
+		fmt.Println(d.ID)
+	}
+}
+
+func ExampleConch_GetRackLayout() {
+	api, err := conch.New(conch.WithToken(os.Getenv("CONCH_TOKEN")))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	racks, err := api.GetRacks()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, r := range racks {
+		layout, err := api.GetRackLayout(r)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s has %d slots\n", r.Name, len(layout))
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"net/http"
+)
+
+// Feature is a part of the API that not every server has. A server has the
+// feature if Probe, a path normally read with GET, is routed.
+type Feature struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Probe       string `json:"probe"`
+}
+
+// Features are the parts of the API the shell checks for before using them
+var Features = []Feature{
+	{"api-tokens", "API tokens", "/user/me/token"},
+	{"datacenters", "datacenters", "/dc"},
+	{"hardware-vendors", "hardware vendors", "/hardware_vendor"},
+	{"rack-layouts", "rack layouts", "/layout"},
+	{"rack-roles", "rack roles", "/rack_role"},
+	{"relays", "the global relay list", "/relay?no_devices=1"},
+	{"user-admin", "user administration", "/user"},
+	{"validation-plans", "validation plans", "/validation_plan"},
+}
+
+// FeatureByName finds a feature in Features
+func FeatureByName(name string) (Feature, bool) {
+	for _, f := range Features {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Feature{}, false
+}
+
+// ProbeFeature asks the server whether it has a feature. The probe is a HEAD
+// request, so the data behind it isn't sent. Only a 404 means the server
+// lacks the feature: a 403, say, means the route exists even if this user
+// can't use it.
+func (c *Conch) ProbeFeature(f Feature) (bool, error) {
+	req, err := c.sling().New().Head(f.Probe).Request()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := c.httpDo(req, nil)
+	switch err {
+	case nil, ErrForbidden:
+		return true, nil
+	case ErrDataNotFound:
+		return false, nil
+	}
+	if res != nil && res.StatusCode != http.StatusUnauthorized {
+		// Any other answer came from a route that exists
+		return true, nil
+	}
+	return false, err
+}
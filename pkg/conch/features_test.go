@@ -0,0 +1,63 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestProbeFeature(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	feature := func(name string) conch.Feature {
+		f, ok := conch.FeatureByName(name)
+		st.Assert(t, ok, true)
+		return f
+	}
+
+	_, ok := conch.FeatureByName("time-travel")
+	st.Expect(t, ok, false)
+
+	t.Run("Supported", func(t *testing.T) {
+		gock.New(API.BaseURL).Head("/dc").Reply(200)
+
+		has, err := API.ProbeFeature(feature("datacenters"))
+		st.Expect(t, err, nil)
+		st.Expect(t, has, true)
+	})
+
+	t.Run("Forbidden", func(t *testing.T) {
+		gock.New(API.BaseURL).Head("/user").Reply(403)
+
+		has, err := API.ProbeFeature(feature("user-admin"))
+		st.Expect(t, err, nil)
+		st.Expect(t, has, true)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		gock.New(API.BaseURL).Head("/rack_role").Reply(404)
+
+		has, err := API.ProbeFeature(feature("rack-roles"))
+		st.Expect(t, err, nil)
+		st.Expect(t, has, false)
+	})
+
+	t.Run("NotAuthorized", func(t *testing.T) {
+		gock.New(API.BaseURL).Head("/relay").Reply(401)
+
+		has, err := API.ProbeFeature(feature("relays"))
+		st.Expect(t, err, conch.ErrNotAuthorized)
+		st.Expect(t, has, false)
+	})
+
+	st.Expect(t, gock.IsDone(), true)
+}
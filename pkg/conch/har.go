@@ -0,0 +1,383 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HAR is an HTTP Archive, as written by browsers' developer tools, holding the
+// API requests made during a session. Only the parts of the format the shell
+// needs are here.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the body of a HAR
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+
+	// Args is the command line that was recorded, without the leading
+	// 'conch'. The underscore marks it as an extension to the format.
+	Args []string `json:"_conch_args,omitempty"`
+}
+
+// HARCreator names the program that wrote a HAR
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single request and its response
+type HAREntry struct {
+	Started  time.Time   `json:"startedDateTime"`
+	Time     float64     `json:"time"`
+	Request  HARRequest  `json:"request"`
+	Response HARResponse `json:"response"`
+	Timings  HARTimings  `json:"timings"`
+}
+
+// HARRequest is the request half of a HAREntry
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse is the response half of a HAREntry
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a header or query parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is a request body
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent is a response body
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARTimings is how long a request took. The shell can only tell the total,
+// which is counted as waiting.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harRedacted replaces credentials, so that a HAR can be attached to a bug
+// report
+const harRedacted = "REDACTED"
+
+var harSecretHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+}
+
+var harSecretFields = []string{"jwt_token", "token", "password"}
+
+// NewHAR returns an empty HAR, written by creator
+func NewHAR(creator string, version string) *HAR {
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: creator, Version: version},
+		Entries: make([]*HAREntry, 0),
+	}}
+}
+
+// LoadHAR reads a HAR from a file
+func LoadHAR(path string) (*HAR, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	h := &HAR{}
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, fmt.Errorf("%s is not a HAR file: %s", path, err)
+	}
+	return h, nil
+}
+
+func harHeaders(h http.Header) []HARNameValue {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]HARNameValue, 0, len(h))
+	for _, name := range names {
+		for _, v := range h[name] {
+			if harSecretHeaders[http.CanonicalHeaderKey(name)] {
+				v = harRedacted
+			}
+			out = append(out, HARNameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+// redactBody blanks out credentials in a JSON object body
+func redactBody(body []byte) []byte {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return body
+	}
+
+	changed := false
+	for _, field := range harSecretFields {
+		if _, ok := obj[field].(string); ok {
+			obj[field] = harRedacted
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return body
+	}
+	return b
+}
+
+// HARRecorder is an http.RoundTripper that passes requests along to Next and
+// records them, and their responses, in a HAR. Bodies are stored
+// uncompressed, and credentials are redacted.
+type HARRecorder struct {
+	// Next sends the requests. If it is nil, http.DefaultTransport is used.
+	Next http.RoundTripper
+
+	// OnEntry, if set, is called after each entry is recorded, eg to save
+	// the HAR as the session goes along
+	OnEntry func(h *HAR)
+
+	sync.Mutex
+	har *HAR
+}
+
+// NewHARRecorder starts recording into h
+func NewHARRecorder(next http.RoundTripper, h *HAR) *HARRecorder {
+	return &HARRecorder{Next: next, har: h}
+}
+
+// HAR is what has been recorded so far
+func (r *HARRecorder) HAR() *HAR {
+	return r.har
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *HARRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	started := time.Now()
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	elapsed := float64(time.Since(started)) / float64(time.Millisecond)
+
+	// The HAR gets the plain body. The caller gets what it asked for.
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+	plain := resBody
+	headers := res.Header.Clone()
+	if strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		if zr, err := gzip.NewReader(bytes.NewReader(resBody)); err == nil {
+			if unzipped, err := ioutil.ReadAll(zr); err == nil {
+				plain = unzipped
+				headers.Del("Content-Encoding")
+				headers.Del("Content-Length")
+			}
+		}
+	}
+
+	entry := &HAREntry{
+		Started: started.UTC(),
+		Time:    elapsed,
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Headers:     harHeaders(req.Header),
+			QueryString: make([]HARNameValue, 0),
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Response: HARResponse{
+			Status:      res.StatusCode,
+			StatusText:  http.StatusText(res.StatusCode),
+			HTTPVersion: res.Proto,
+			Headers:     harHeaders(headers),
+			Content: HARContent{
+				Size:     len(plain),
+				MimeType: res.Header.Get("Content-Type"),
+				Text:     string(redactBody(plain)),
+			},
+			HeadersSize: -1,
+			BodySize:    len(resBody),
+		},
+		Timings: HARTimings{Send: 0, Wait: elapsed, Receive: 0},
+	}
+	if entry.Request.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+	for name, values := range req.URL.Query() {
+		for _, v := range values {
+			entry.Request.QueryString = append(
+				entry.Request.QueryString,
+				HARNameValue{Name: name, Value: v},
+			)
+		}
+	}
+	if len(reqBody) > 0 {
+		entry.Request.PostData = &HARPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     string(redactBody(reqBody)),
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.har.Log.Entries = append(r.har.Log.Entries, entry)
+	if r.OnEntry != nil {
+		r.OnEntry(r.har)
+	}
+
+	return res, nil
+}
+
+// HARReplayer is an http.RoundTripper that answers requests from a HAR,
+// without touching the network. A request is matched to a recorded one by
+// method, path, and query, ignoring the host, so that a session recorded
+// against one server can be replayed with a profile for another. Requests
+// that were made several times get their recorded responses in order, and
+// the last one again after that.
+type HARReplayer struct {
+	sync.Mutex
+	entries map[string][]*HAREntry
+	used    map[string]int
+}
+
+// NewHARReplayer prepares to replay h
+func NewHARReplayer(h *HAR) *HARReplayer {
+	r := &HARReplayer{
+		entries: make(map[string][]*HAREntry),
+		used:    make(map[string]int),
+	}
+	for _, e := range h.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			continue
+		}
+		key := harKey(e.Request.Method, u)
+		r.entries[key] = append(r.entries[key], e)
+	}
+	return r
+}
+
+func harKey(method string, u *url.URL) string {
+	// Encode() sorts the query, so that parameter order doesn't matter
+	return method + " " + u.EscapedPath() + "?" + u.Query().Encode()
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *HARReplayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	key := harKey(req.Method, req.URL)
+
+	r.Lock()
+	entries := r.entries[key]
+	if len(entries) == 0 {
+		r.Unlock()
+		return nil, fmt.Errorf("the recording has no response for %s %s", req.Method, req.URL.RequestURI())
+	}
+	i := r.used[key]
+	if i >= len(entries) {
+		i = len(entries) - 1
+	}
+	r.used[key] = i + 1
+	e := entries[i]
+	r.Unlock()
+
+	headers := make(http.Header)
+	for _, h := range e.Response.Headers {
+		headers.Add(h.Name, h.Value)
+	}
+	body := []byte(e.Response.Content.Text)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Response.Status, e.Response.StatusText),
+		StatusCode:    e.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,84 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestHAR(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	h := conch.NewHAR("test", "0.0.0")
+
+	t.Run("Record", func(t *testing.T) {
+		gock.New(API.BaseURL).Get("/version").Reply(200).
+			JSON(map[string]string{"version": "99.99.99"})
+		gock.New(API.BaseURL).Post("/user/me/password").Reply(200).
+			JSON(map[string]string{"jwt_token": "sekrit"})
+
+		saved := 0
+		rec := conch.NewHARRecorder(nil, h)
+		rec.OnEntry = func(*conch.HAR) { saved++ }
+
+		api := &conch.Conch{
+			BaseURL:    API.BaseURL,
+			Token:      "sekrit",
+			HTTPClient: &http.Client{Transport: rec},
+		}
+
+		v, err := api.GetVersion()
+		st.Expect(t, err, nil)
+		st.Expect(t, v, "99.99.99")
+
+		err = api.ChangeMyPassword("hunter2", false)
+		st.Expect(t, err, nil)
+
+		st.Expect(t, len(h.Log.Entries), 2)
+		st.Expect(t, saved, 2)
+
+		for _, e := range h.Log.Entries {
+			for _, hdr := range e.Request.Headers {
+				if hdr.Name == "Authorization" {
+					st.Expect(t, hdr.Value, "REDACTED")
+				}
+			}
+		}
+
+		pw := h.Log.Entries[1]
+		st.Expect(t, strings.Contains(pw.Request.PostData.Text, "hunter2"), false)
+		st.Expect(t, strings.Contains(pw.Response.Content.Text, "sekrit"), false)
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		gock.Flush()
+
+		api := &conch.Conch{
+			BaseURL:    "http://elsewhere",
+			HTTPClient: &http.Client{Transport: conch.NewHARReplayer(h)},
+		}
+
+		v, err := api.GetVersion()
+		st.Expect(t, err, nil)
+		st.Expect(t, v, "99.99.99")
+
+		// The last response is repeated
+		v, err = api.GetVersion()
+		st.Expect(t, err, nil)
+		st.Expect(t, v, "99.99.99")
+
+		_, err = api.GetUserSettings()
+		st.Reject(t, err, nil)
+	})
+}
@@ -34,6 +34,14 @@ func (c *Conch) GetHardwareProducts() ([]HardwareProduct, error) {
 	return prods, c.get("/hardware_product", &prods)
 }
 
+// GetAllHardwareProducts fetches every hardware product, deactivated ones
+// included, via /hardware_product?include_deactivated=1. Servers that don't
+// know the parameter only list the active products.
+func (c *Conch) GetAllHardwareProducts() ([]HardwareProduct, error) {
+	prods := make([]HardwareProduct, 0)
+	return prods, c.get("/hardware_product?include_deactivated=1", &prods)
+}
+
 // SaveHardwareProduct creates or saves s hardware product, based
 // on the presence of an ID
 func (c *Conch) SaveHardwareProduct(h *HardwareProduct) error {
@@ -103,6 +111,22 @@ func (c *Conch) DeleteHardwareProduct(hwUUID fmt.Stringer) error {
 	return c.httpDelete("/hardware_product/" + url.PathEscape(hwUUID.String()))
 }
 
+// DeactivateHardwareProduct retires a hardware product. Since the API never
+// really deletes hardware products, this is the same as deleting one.
+func (c *Conch) DeactivateHardwareProduct(hwUUID fmt.Stringer) error {
+	return c.DeleteHardwareProduct(hwUUID)
+}
+
+// ReactivateHardwareProduct brings a deactivated hardware product back, via
+// /hardware_product/:uuid/reactivate
+func (c *Conch) ReactivateHardwareProduct(hwUUID fmt.Stringer) error {
+	return c.post(
+		"/hardware_product/"+url.PathEscape(hwUUID.String())+"/reactivate",
+		nil,
+		nil,
+	)
+}
+
 // GetHardwareVendor ...
 func (c *Conch) GetHardwareVendor(name string) (v HardwareVendor, err error) {
 	return v, c.get("/hardware_vendor/"+url.PathEscape(name), &v)
@@ -0,0 +1,467 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SpecImport is what a SpecTranslator could work out about a hardware profile
+// from a vendor's description of a system. Only the profile fields named in
+// Fields were found; the rest are zero and should be left alone.
+type SpecImport struct {
+	Profile  HardwareProfile `json:"hardware_product_profile"`
+	Fields   []string        `json:"fields"`
+	Warnings []string        `json:"warnings"`
+}
+
+// SpecTranslator turns a vendor's system description into a SpecImport
+type SpecTranslator func(raw []byte) (SpecImport, error)
+
+var specTranslators = map[string]SpecTranslator{
+	"redfish":       translateRedfish,
+	"dell":          translateDell,
+	"device-report": translateDeviceReport,
+}
+
+// RegisterSpecTranslator makes a translator available to TranslateSpec under
+// the given vendor name, replacing any translator already registered for it
+func RegisterSpecTranslator(vendor string, t SpecTranslator) {
+	specTranslators[strings.ToLower(vendor)] = t
+}
+
+// SpecTranslators lists the vendor names that have a translator
+func SpecTranslators() []string {
+	names := make([]string, 0, len(specTranslators))
+	for name := range specTranslators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TranslateSpec runs the translator for the given vendor
+func TranslateSpec(vendor string, raw []byte) (SpecImport, error) {
+	t, ok := specTranslators[strings.ToLower(vendor)]
+	if !ok {
+		return SpecImport{}, fmt.Errorf(
+			"no spec translator for vendor '%s'. Known vendors: %s",
+			vendor,
+			strings.Join(SpecTranslators(), ", "),
+		)
+	}
+	return t(raw)
+}
+
+// ApplySpecImport copies the fields the import found onto a profile
+func ApplySpecImport(p HardwareProfile, s SpecImport) (HardwareProfile, error) {
+	var (
+		current  map[string]interface{}
+		imported map[string]interface{}
+	)
+
+	j, err := json.Marshal(p)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(j, &current); err != nil {
+		return p, err
+	}
+
+	j, err = json.Marshal(s.Profile)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(j, &imported); err != nil {
+		return p, err
+	}
+
+	for _, f := range s.Fields {
+		current[f] = imported[f]
+	}
+
+	j, err = json.Marshal(current)
+	if err != nil {
+		return p, err
+	}
+	var out HardwareProfile
+	return out, json.Unmarshal(j, &out)
+}
+
+// redfishCollection is a Redfish collection that may or may not have had its
+// members expanded. Unexpanded members only carry an @odata.id.
+type redfishCollection struct {
+	Members []json.RawMessage `json:"Members"`
+}
+
+// UnmarshalJSON accepts both a collection and a bare array of members, as
+// produced by most inventory dump tools
+func (c *redfishCollection) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '[' {
+		return json.Unmarshal(b, &c.Members)
+	}
+	type plain redfishCollection
+	return json.Unmarshal(b, (*plain)(c))
+}
+
+type redfishStatus struct {
+	State string `json:"State"`
+}
+
+type redfishDrive struct {
+	OdataID       string        `json:"@odata.id"`
+	MediaType     string        `json:"MediaType"`
+	Protocol      string        `json:"Protocol"`
+	CapacityBytes int64         `json:"CapacityBytes"`
+	Status        redfishStatus `json:"Status"`
+}
+
+// redfishSystem is the part of a Redfish ComputerSystem, with its Memory,
+// EthernetInterfaces, and Storage expanded, that maps onto a hardware
+// profile. PowerSupplies come from the chassis' Power resource and are looked
+// for at the top level too.
+type redfishSystem struct {
+	BiosVersion      string `json:"BiosVersion"`
+	ProcessorSummary struct {
+		Count int    `json:"Count"`
+		Model string `json:"Model"`
+	} `json:"ProcessorSummary"`
+	MemorySummary struct {
+		TotalSystemMemoryGiB float64 `json:"TotalSystemMemoryGiB"`
+	} `json:"MemorySummary"`
+	Memory             *redfishCollection `json:"Memory"`
+	EthernetInterfaces *redfishCollection `json:"EthernetInterfaces"`
+	Storage            *redfishCollection `json:"Storage"`
+	SimpleStorage      *redfishCollection `json:"SimpleStorage"`
+	PowerSupplies      []json.RawMessage  `json:"PowerSupplies"`
+	Power              struct {
+		PowerSupplies []json.RawMessage `json:"PowerSupplies"`
+	} `json:"Power"`
+}
+
+// specBuilder keeps track of which profile fields have been filled in
+type specBuilder struct {
+	SpecImport
+}
+
+func (b *specBuilder) set(field string) {
+	for _, f := range b.Fields {
+		if f == field {
+			return
+		}
+	}
+	b.Fields = append(b.Fields, field)
+}
+
+func (b *specBuilder) warn(format string, args ...interface{}) {
+	b.Warnings = append(b.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (b *specBuilder) finish() SpecImport {
+	sort.Strings(b.Fields)
+	if b.Fields == nil {
+		b.Fields = make([]string, 0)
+	}
+	if b.Warnings == nil {
+		b.Warnings = make([]string, 0)
+	}
+	return b.SpecImport
+}
+
+func translateRedfish(raw []byte) (SpecImport, error) {
+	b := &specBuilder{}
+	if err := b.redfish(raw); err != nil {
+		return SpecImport{}, err
+	}
+	return b.finish(), nil
+}
+
+func (b *specBuilder) redfish(raw []byte) error {
+	var sys redfishSystem
+	if err := json.Unmarshal(raw, &sys); err != nil {
+		return fmt.Errorf("not a Redfish ComputerSystem: %s", err)
+	}
+	p := &b.Profile
+
+	if sys.BiosVersion != "" {
+		p.BiosFirmware = sys.BiosVersion
+		b.set("bios_firmware")
+	}
+
+	if sys.ProcessorSummary.Count > 0 {
+		p.NumCPU = sys.ProcessorSummary.Count
+		b.set("cpu_num")
+	}
+	if sys.ProcessorSummary.Model != "" {
+		p.CPUType = strings.TrimSpace(sys.ProcessorSummary.Model)
+		b.set("cpu_type")
+	}
+
+	if sys.MemorySummary.TotalSystemMemoryGiB > 0 {
+		p.TotalRAM = int(sys.MemorySummary.TotalSystemMemoryGiB + 0.5)
+		b.set("ram_total")
+	}
+
+	if sys.Memory != nil {
+		dimms := 0
+		for _, m := range sys.Memory.Members {
+			var dimm struct {
+				OdataID     string        `json:"@odata.id"`
+				CapacityMiB int           `json:"CapacityMiB"`
+				Status      redfishStatus `json:"Status"`
+			}
+			if err := json.Unmarshal(m, &dimm); err != nil {
+				return err
+			}
+			// Empty slots are listed as absent, or with no capacity
+			if dimm.Status.State == "Absent" {
+				continue
+			}
+			if dimm.CapacityMiB == 0 && dimm.Status.State == "" && dimm.OdataID != "" {
+				b.warn("memory was not expanded, so empty DIMM slots could not be told apart from populated ones")
+				dimms = len(sys.Memory.Members)
+				break
+			}
+			if dimm.CapacityMiB == 0 {
+				continue
+			}
+			dimms++
+		}
+		p.NumDimms = dimms
+		b.set("dimms_num")
+	}
+
+	if sys.EthernetInterfaces != nil {
+		p.NumNics = len(sys.EthernetInterfaces.Members)
+		b.set("nics_num")
+	}
+
+	supplies := sys.PowerSupplies
+	if len(supplies) == 0 {
+		supplies = sys.Power.PowerSupplies
+	}
+	if len(supplies) > 0 {
+		p.TotalPSU = len(supplies)
+		b.set("psu_total")
+	}
+
+	drives := make([]redfishDrive, 0)
+	collect := func(c *redfishCollection) error {
+		if c == nil {
+			return nil
+		}
+		for _, m := range c.Members {
+			// Storage lists Drives, SimpleStorage lists Devices
+			var s struct {
+				Drives  []json.RawMessage `json:"Drives"`
+				Devices []json.RawMessage `json:"Devices"`
+			}
+			if err := json.Unmarshal(m, &s); err != nil {
+				return err
+			}
+			for _, d := range append(s.Drives, s.Devices...) {
+				var drive redfishDrive
+				if err := json.Unmarshal(d, &drive); err != nil {
+					return err
+				}
+				drives = append(drives, drive)
+			}
+		}
+		return nil
+	}
+	if err := collect(sys.Storage); err != nil {
+		return err
+	}
+	if err := collect(sys.SimpleStorage); err != nil {
+		return err
+	}
+
+	if sys.Storage != nil || sys.SimpleStorage != nil {
+		b.drives(drives)
+	}
+
+	return nil
+}
+
+// drives fills in the disk counts and sizes, in GB, by type
+func (b *specBuilder) drives(drives []redfishDrive) {
+	p := &b.Profile
+
+	type bucket struct {
+		num  *int
+		size *int
+		name string
+	}
+	buckets := map[string]bucket{
+		"sas_hdd":  {&p.SasHddNum, &p.SasHddSize, "sas_hdd"},
+		"sata_hdd": {&p.SataHddNum, &p.SataHddSize, "sata_hdd"},
+		"sata_ssd": {&p.SataSsdNum, &p.SataSsdSize, "sata_ssd"},
+		"nvme_ssd": {&p.NvmeSsdNum, &p.NvmeSsdSize, "nvme_ssd"},
+	}
+
+	for _, d := range drives {
+		if d.Status.State == "Absent" {
+			continue
+		}
+		if d.MediaType == "" && d.Protocol == "" {
+			if d.OdataID != "" {
+				b.warn("drive %s was not expanded and was skipped", d.OdataID)
+			}
+			continue
+		}
+
+		var key string
+		switch {
+		case strings.EqualFold(d.Protocol, "NVMe"):
+			key = "nvme_ssd"
+		case strings.EqualFold(d.Protocol, "SAS") && strings.EqualFold(d.MediaType, "HDD"):
+			key = "sas_hdd"
+		case strings.EqualFold(d.Protocol, "SATA") && strings.EqualFold(d.MediaType, "HDD"):
+			key = "sata_hdd"
+		case strings.EqualFold(d.Protocol, "SATA") && strings.EqualFold(d.MediaType, "SSD"):
+			key = "sata_ssd"
+		default:
+			b.warn("no profile field for %s %s drives, skipped one", d.Protocol, d.MediaType)
+			continue
+		}
+
+		bk := buckets[key]
+		*bk.num++
+		size := int(d.CapacityBytes / 1000000000)
+		if *bk.size != 0 && *bk.size != size {
+			b.warn("%s drives are not all the same size, using the largest", key)
+		}
+		if size > *bk.size {
+			*bk.size = size
+		}
+	}
+
+	// Finding no drives of a type is as much a result as finding some
+	for _, bk := range buckets {
+		b.set(bk.name + "_num")
+		if *bk.num > 0 {
+			b.set(bk.name + "_size")
+		}
+	}
+}
+
+// translateDell reads the Redfish ComputerSystem that an iDRAC serves, along
+// with what Dell adds under Oem.Dell
+func translateDell(raw []byte) (SpecImport, error) {
+	b := &specBuilder{}
+	if err := b.redfish(raw); err != nil {
+		return SpecImport{}, err
+	}
+
+	var oem struct {
+		Oem struct {
+			Dell struct {
+				DellSystem struct {
+					ChassisSystemHeightUnit int `json:"ChassisSystemHeightUnit"`
+				} `json:"DellSystem"`
+			} `json:"Dell"`
+		} `json:"Oem"`
+	}
+	if err := json.Unmarshal(raw, &oem); err != nil {
+		return SpecImport{}, err
+	}
+	dell := oem.Oem.Dell.DellSystem
+
+	if dell.ChassisSystemHeightUnit > 0 {
+		b.Profile.RackUnit = dell.ChassisSystemHeightUnit
+		b.set("rack_unit")
+	} else {
+		b.warn("Oem.Dell.DellSystem.ChassisSystemHeightUnit is missing, so rack_unit was not set")
+	}
+
+	return b.finish(), nil
+}
+
+// translateDeviceReport reads a device report, as sent by a relay and kept as
+// a device's latest_report. It lets a report be compared with a hardware
+// profile, or with what another translator found.
+func translateDeviceReport(raw []byte) (SpecImport, error) {
+	var r struct {
+		BiosVersion string `json:"bios_version"`
+		Processor   *struct {
+			Count int    `json:"count"`
+			Type  string `json:"type"`
+		} `json:"processor"`
+		Memory *struct {
+			Count int     `json:"count"`
+			Total float64 `json:"total"`
+		} `json:"memory"`
+		Interfaces map[string]json.RawMessage `json:"interfaces"`
+		Disks      map[string]struct {
+			DriveType string `json:"drive_type"`
+		} `json:"disks"`
+	}
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return SpecImport{}, fmt.Errorf("not a device report: %s", err)
+	}
+
+	b := &specBuilder{}
+	p := &b.Profile
+
+	if r.BiosVersion != "" {
+		p.BiosFirmware = r.BiosVersion
+		b.set("bios_firmware")
+	}
+
+	if r.Processor != nil {
+		p.NumCPU = r.Processor.Count
+		b.set("cpu_num")
+		if r.Processor.Type != "" {
+			p.CPUType = strings.TrimSpace(r.Processor.Type)
+			b.set("cpu_type")
+		}
+	}
+
+	if r.Memory != nil {
+		p.NumDimms = r.Memory.Count
+		p.TotalRAM = int(r.Memory.Total + 0.5)
+		b.set("dimms_num")
+		b.set("ram_total")
+	}
+
+	if r.Interfaces != nil {
+		for name := range r.Interfaces {
+			// The BMC is reported alongside the host's interfaces
+			if strings.HasPrefix(name, "ipmi") {
+				continue
+			}
+			p.NumNics++
+		}
+		b.set("nics_num")
+	}
+
+	if r.Disks != nil {
+		counts := map[string]*int{
+			"sas_hdd":  &p.SasHddNum,
+			"sata_hdd": &p.SataHddNum,
+			"sata_ssd": &p.SataSsdNum,
+			"nvme_ssd": &p.NvmeSsdNum,
+			"raid_lun": &p.RaidLunNum,
+		}
+		for serial, d := range r.Disks {
+			n, ok := counts[strings.ToLower(d.DriveType)]
+			if !ok {
+				b.warn("no profile field for disk %s, of type '%s'", serial, d.DriveType)
+				continue
+			}
+			*n++
+		}
+		for name := range counts {
+			b.set(name + "_num")
+		}
+	}
+
+	return b.finish(), nil
+}
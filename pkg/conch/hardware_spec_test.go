@@ -0,0 +1,144 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+)
+
+const redfishSystem = `{
+	"BiosVersion": "2.1.7",
+	"ProcessorSummary": { "Count": 2, "Model": "Intel(R) Xeon(R) Gold 6148 CPU @ 2.40GHz " },
+	"MemorySummary": { "TotalSystemMemoryGiB": 384 },
+	"Memory": { "Members": [
+		{ "CapacityMiB": 32768, "Status": { "State": "Enabled" } },
+		{ "CapacityMiB": 32768, "Status": { "State": "Enabled" } },
+		{ "Status": { "State": "Absent" } }
+	] },
+	"EthernetInterfaces": [ {}, {}, {}, {} ],
+	"Storage": { "Members": [ { "Drives": [
+		{ "Protocol": "SAS", "MediaType": "HDD", "CapacityBytes": 8001563222016 },
+		{ "Protocol": "SAS", "MediaType": "HDD", "CapacityBytes": 8001563222016 },
+		{ "Protocol": "SATA", "MediaType": "SSD", "CapacityBytes": 480103981056 },
+		{ "Protocol": "NVMe", "MediaType": "SSD", "CapacityBytes": 1600321314816 }
+	] } ] },
+	"Power": { "PowerSupplies": [ {}, {} ] },
+	"Oem": { "Dell": { "DellSystem": { "ChassisSystemHeightUnit": 2 } } }
+}`
+
+func TestTranslateSpec(t *testing.T) {
+	t.Run("Redfish", func(t *testing.T) {
+		s, err := conch.TranslateSpec("redfish", []byte(redfishSystem))
+		st.Expect(t, err, nil)
+
+		p := s.Profile
+		st.Expect(t, p.BiosFirmware, "2.1.7")
+		st.Expect(t, p.NumCPU, 2)
+		st.Expect(t, p.CPUType, "Intel(R) Xeon(R) Gold 6148 CPU @ 2.40GHz")
+		st.Expect(t, p.TotalRAM, 384)
+		st.Expect(t, p.NumDimms, 2)
+		st.Expect(t, p.NumNics, 4)
+		st.Expect(t, p.TotalPSU, 2)
+		st.Expect(t, p.SasHddNum, 2)
+		st.Expect(t, p.SasHddSize, 8001)
+		st.Expect(t, p.SataSsdNum, 1)
+		st.Expect(t, p.SataSsdSize, 480)
+		st.Expect(t, p.NvmeSsdNum, 1)
+		st.Expect(t, p.SataHddNum, 0)
+		st.Expect(t, p.RackUnit, 0)
+
+		st.Expect(t, s.Fields, []string{
+			"bios_firmware",
+			"cpu_num",
+			"cpu_type",
+			"dimms_num",
+			"nics_num",
+			"nvme_ssd_num",
+			"nvme_ssd_size",
+			"psu_total",
+			"ram_total",
+			"sas_hdd_num",
+			"sas_hdd_size",
+			"sata_hdd_num",
+			"sata_ssd_num",
+			"sata_ssd_size",
+		})
+		st.Expect(t, s.Warnings, []string{})
+	})
+
+	t.Run("Dell", func(t *testing.T) {
+		s, err := conch.TranslateSpec("Dell", []byte(redfishSystem))
+		st.Expect(t, err, nil)
+		st.Expect(t, s.Profile.RackUnit, 2)
+		st.Expect(t, s.Profile.NumCPU, 2)
+	})
+
+	t.Run("DeviceReport", func(t *testing.T) {
+		report := `{
+			"bios_version": "2.1.7",
+			"processor": { "count": 2, "type": "Intel(R) Xeon(R) Gold 6148 CPU @ 2.40GHz" },
+			"memory": { "count": 12, "total": 384 },
+			"interfaces": { "eth0": {}, "eth1": {}, "ipmi1": {} },
+			"disks": {
+				"A": { "drive_type": "SAS_HDD" },
+				"B": { "drive_type": "SAS_HDD" },
+				"C": { "drive_type": "NVME_SSD" },
+				"D": { "drive_type": "TAPE" }
+			}
+		}`
+		s, err := conch.TranslateSpec("device-report", []byte(report))
+		st.Expect(t, err, nil)
+
+		p := s.Profile
+		st.Expect(t, p.BiosFirmware, "2.1.7")
+		st.Expect(t, p.NumCPU, 2)
+		st.Expect(t, p.NumDimms, 12)
+		st.Expect(t, p.TotalRAM, 384)
+		st.Expect(t, p.NumNics, 2)
+		st.Expect(t, p.SasHddNum, 2)
+		st.Expect(t, p.NvmeSsdNum, 1)
+		st.Expect(t, len(s.Warnings), 1)
+	})
+
+	t.Run("UnknownVendor", func(t *testing.T) {
+		_, err := conch.TranslateSpec("acme", []byte(redfishSystem))
+		st.Reject(t, err, nil)
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		conch.RegisterSpecTranslator("Acme", func(raw []byte) (conch.SpecImport, error) {
+			return conch.SpecImport{
+				Profile: conch.HardwareProfile{NumUSB: 3},
+				Fields:  []string{"usb_num"},
+			}, nil
+		})
+		s, err := conch.TranslateSpec("acme", nil)
+		st.Expect(t, err, nil)
+		st.Expect(t, s.Profile.NumUSB, 3)
+	})
+}
+
+func TestApplySpecImport(t *testing.T) {
+	p := conch.HardwareProfile{
+		Purpose:  "compute",
+		NumCPU:   1,
+		TotalRAM: 128,
+	}
+	s := conch.SpecImport{
+		Profile: conch.HardwareProfile{NumCPU: 2, TotalRAM: 256},
+		Fields:  []string{"cpu_num"},
+	}
+
+	out, err := conch.ApplySpecImport(p, s)
+	st.Expect(t, err, nil)
+	st.Expect(t, out.NumCPU, 2)
+	st.Expect(t, out.TotalRAM, 128)
+	st.Expect(t, out.Purpose, "compute")
+}
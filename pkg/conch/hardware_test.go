@@ -37,6 +37,34 @@ func TestHardwareErrors(t *testing.T) {
 		st.Expect(t, ret, []conch.HardwareVendor{})
 	})
 
+	t.Run("GetAllHardwareProducts", func(t *testing.T) {
+		id := uuid.NewV4()
+		gock.New(API.BaseURL).Get("/hardware_product").
+			MatchParam("include_deactivated", "1").
+			Reply(200).
+			JSON([]map[string]interface{}{{
+				"id":          id.String(),
+				"name":        "retired",
+				"deactivated": "2019-05-01T00:00:00Z",
+			}})
+
+		ret, err := API.GetAllHardwareProducts()
+		st.Expect(t, err, nil)
+		st.Expect(t, len(ret), 1)
+		st.Expect(t, ret[0].ID, id)
+		st.Expect(t, ret[0].Deactivated.Format("2006-01-02"), "2019-05-01")
+	})
+
+	t.Run("ReactivateHardwareProduct", func(t *testing.T) {
+		id := uuid.NewV4()
+		gock.New(API.BaseURL).
+			Post("/hardware_product/" + id.String() + "/reactivate").
+			Reply(400).JSON(ErrApi)
+
+		err := API.ReactivateHardwareProduct(id)
+		st.Expect(t, err, ErrApiUnpacked)
+	})
+
 	t.Run("DeleteHardwareVendor", func(t *testing.T) {
 		gock.New(API.BaseURL).Delete("/hardware_vendor/" + name).
 			Reply(400).JSON(ErrApi)
@@ -0,0 +1,163 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Device settings that mark a device as being worked on. MaintenanceSetting
+// holds a Maintenance as JSON. MaintenanceEndsSetting holds the day the window
+// ends, in MaintenanceEndsFormat, so that the devices whose windows may still
+// be open can be found with a search for each day from today on. Windows that
+// ended before today are never looked at again.
+const (
+	MaintenanceSetting     = "maintenance"
+	MaintenanceEndsSetting = "maintenance.ends"
+	MaintenanceEndsFormat  = "2006-01-02"
+)
+
+// MaxMaintenance is the longest a maintenance window can be. It bounds the
+// number of days GetDevicesInMaintenance searches.
+const MaxMaintenance = 7 * 24 * time.Hour
+
+// Maintenance is a window during which a device is being worked on, and
+// shouldn't be alerted on. The API has no facility for these so they're kept
+// as device settings. RackID is set when the window was opened for the whole
+// of the device's rack.
+type Maintenance struct {
+	DeviceID string    `json:"device_id"`
+	RackID   string    `json:"rack_id,omitempty"`
+	Started  time.Time `json:"started"`
+	Until    time.Time `json:"until"`
+	Reason   string    `json:"reason"`
+	User     string    `json:"user,omitempty"`
+}
+
+// Active returns true if the window is open at the given time
+func (m Maintenance) Active(at time.Time) bool {
+	return !at.Before(m.Started) && at.Before(m.Until)
+}
+
+// StartDeviceMaintenance puts a device in maintenance, replacing any window
+// it already had
+func (c *Conch) StartDeviceMaintenance(m Maintenance) error {
+	if m.DeviceID == "" {
+		return errors.New("a maintenance window needs a device")
+	}
+	if m.Started.IsZero() {
+		m.Started = time.Now()
+	}
+	m.Started = m.Started.UTC()
+	m.Until = m.Until.UTC()
+	if !m.Until.After(m.Started) {
+		return errors.New("a maintenance window has to end after it starts")
+	}
+	if m.Until.Sub(m.Started) > MaxMaintenance {
+		return fmt.Errorf(
+			"a maintenance window can't be longer than %d hours",
+			int(MaxMaintenance/time.Hour),
+		)
+	}
+
+	j, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := c.SetDeviceSetting(m.DeviceID, MaintenanceSetting, string(j)); err != nil {
+		return err
+	}
+	return c.SetDeviceSetting(
+		m.DeviceID,
+		MaintenanceEndsSetting,
+		m.Until.Format(MaintenanceEndsFormat),
+	)
+}
+
+// EndDeviceMaintenance takes a device out of maintenance. A device that
+// wasn't in maintenance is left as it was.
+func (c *Conch) EndDeviceMaintenance(deviceID string) error {
+	for _, key := range []string{MaintenanceEndsSetting, MaintenanceSetting} {
+		err := c.DeleteDeviceSetting(deviceID, key)
+		if err != nil && err != ErrDataNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseMaintenance reads a device's MaintenanceSetting
+func parseMaintenance(deviceID string, v string) (m Maintenance, err error) {
+	if v == "" {
+		return m, ErrDataNotFound
+	}
+	if err := json.Unmarshal([]byte(v), &m); err != nil {
+		return m, err
+	}
+	if m.DeviceID == "" {
+		m.DeviceID = deviceID
+	}
+	return m, nil
+}
+
+// GetDeviceMaintenance fetches the maintenance window of a device, which may
+// have ended. ErrDataNotFound is returned if the device has never had one,
+// or it was ended with EndDeviceMaintenance.
+func (c *Conch) GetDeviceMaintenance(deviceID string) (Maintenance, error) {
+	v, err := c.GetDeviceSetting(deviceID, MaintenanceSetting)
+	if err != nil {
+		return Maintenance{}, err
+	}
+	return parseMaintenance(deviceID, v)
+}
+
+// GetDevicesInMaintenance finds the devices whose maintenance window is open
+// at the given time, keyed by device ID. Windows that have ended, or can't be
+// read, are left out. Only the devices whose windows end on or after the day
+// of the given time are fetched.
+func (c *Conch) GetDevicesInMaintenance(at time.Time) (map[string]Maintenance, error) {
+	found := make(map[string]Maintenance)
+	seen := make(map[string]bool)
+
+	at = at.UTC()
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	for last := at.Add(MaxMaintenance); !day.After(last); day = day.AddDate(0, 0, 1) {
+		devices, err := c.GetDevicesBySetting(
+			MaintenanceEndsSetting,
+			day.Format(MaintenanceEndsFormat),
+		)
+		if err == ErrDataNotFound {
+			continue
+		}
+		if err != nil {
+			return found, err
+		}
+
+		for _, d := range devices {
+			if seen[d.ID] {
+				continue
+			}
+			seen[d.ID] = true
+
+			v, err := c.GetDeviceSetting(d.ID, MaintenanceSetting)
+			if err == ErrDataNotFound {
+				continue
+			}
+			if err != nil {
+				return found, err
+			}
+			m, err := parseMaintenance(d.ID, v)
+			if err == nil && m.Active(at) {
+				found[d.ID] = m
+			}
+		}
+	}
+	return found, nil
+}
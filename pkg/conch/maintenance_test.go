@@ -0,0 +1,109 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestMaintenance(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	start := time.Date(2019, 3, 4, 5, 0, 0, 0, time.UTC)
+	m := conch.Maintenance{
+		DeviceID: "test",
+		Started:  start,
+		Until:    start.Add(4 * time.Hour),
+		Reason:   "PSU swap",
+	}
+
+	t.Run("Active", func(t *testing.T) {
+		st.Expect(t, m.Active(start.Add(-time.Minute)), false)
+		st.Expect(t, m.Active(start), true)
+		st.Expect(t, m.Active(start.Add(2*time.Hour)), true)
+		st.Expect(t, m.Active(m.Until), false)
+	})
+
+	t.Run("StartDeviceMaintenance", func(t *testing.T) {
+		gock.New(API.BaseURL).Post("/device/test/settings/maintenance").Reply(204)
+		gock.New(API.BaseURL).Post("/device/test/settings/maintenance.ends").
+			JSON(map[string]string{"maintenance.ends": "2019-03-04"}).
+			Reply(204)
+
+		st.Expect(t, API.StartDeviceMaintenance(m), nil)
+		st.Expect(t, gock.IsDone(), true)
+
+		backwards := m
+		backwards.Until = start.Add(-time.Hour)
+		st.Reject(t, API.StartDeviceMaintenance(backwards), nil)
+
+		forever := m
+		forever.Until = start.Add(conch.MaxMaintenance + time.Hour)
+		st.Reject(t, API.StartDeviceMaintenance(forever), nil)
+		st.Reject(t, API.StartDeviceMaintenance(conch.Maintenance{}), nil)
+	})
+
+	t.Run("EndDeviceMaintenance", func(t *testing.T) {
+		gock.New(API.BaseURL).Delete("/device/test/settings/maintenance.ends").Reply(204)
+		gock.New(API.BaseURL).Delete("/device/test/settings/maintenance").Reply(404).
+			JSON(map[string]string{"error": "Not Found"})
+
+		st.Expect(t, API.EndDeviceMaintenance("test"), nil)
+		st.Expect(t, gock.IsDone(), true)
+	})
+
+	t.Run("GetDeviceMaintenance", func(t *testing.T) {
+		gock.New(API.BaseURL).Get("/device/test/settings/maintenance").Reply(200).
+			JSON(map[string]string{"maintenance": `{"rack_id":"r1","started":"2019-03-04T05:00:00Z","until":"2019-03-04T09:00:00Z","reason":"PSU swap"}`})
+		gock.New(API.BaseURL).Get("/device/none/settings/maintenance").Reply(404).
+			JSON(map[string]string{"error": "Not Found"})
+
+		found, err := API.GetDeviceMaintenance("test")
+		st.Expect(t, err, nil)
+		st.Expect(t, found.DeviceID, "test")
+		st.Expect(t, found.RackID, "r1")
+		st.Expect(t, found.Until, start.Add(4*time.Hour))
+
+		_, err = API.GetDeviceMaintenance("none")
+		st.Expect(t, err, conch.ErrDataNotFound)
+	})
+
+	t.Run("GetDevicesInMaintenance", func(t *testing.T) {
+		// Windows that ended before the day asked about, like one that ended
+		// on 2019-03-01, are never searched for
+		gock.New(API.BaseURL).Get("/device").MatchParam("maintenance.ends", "2019-03-04").
+			Reply(200).JSON([]map[string]string{{"id": "test"}, {"id": "over"}, {"id": "broken"}})
+		gock.New(API.BaseURL).Get("/device").MatchParam("maintenance.ends", "2019-03-06").
+			Reply(200).JSON([]map[string]string{{"id": "long"}, {"id": "test"}})
+		for _, day := range []string{"05", "07", "08", "09", "10", "11"} {
+			gock.New(API.BaseURL).Get("/device").MatchParam("maintenance.ends", "2019-03-"+day).
+				Reply(404).JSON(map[string]string{"error": "Not Found"})
+		}
+		gock.New(API.BaseURL).Get("/device/test/settings/maintenance").Reply(200).
+			JSON(map[string]string{"maintenance": `{"started":"2019-03-04T05:00:00Z","until":"2019-03-04T09:00:00Z","reason":"PSU swap"}`})
+		gock.New(API.BaseURL).Get("/device/over/settings/maintenance").Reply(200).
+			JSON(map[string]string{"maintenance": `{"started":"2019-03-04T01:00:00Z","until":"2019-03-04T05:30:00Z","reason":"done"}`})
+		gock.New(API.BaseURL).Get("/device/long/settings/maintenance").Reply(200).
+			JSON(map[string]string{"maintenance": `{"started":"2019-03-04T05:00:00Z","until":"2019-03-06T05:00:00Z","reason":"rebuild"}`})
+		gock.New(API.BaseURL).Get("/device/broken/settings/maintenance").Reply(200).
+			JSON(map[string]string{"maintenance": `not json`})
+
+		found, err := API.GetDevicesInMaintenance(start.Add(time.Hour))
+		st.Expect(t, err, nil)
+		st.Expect(t, len(found), 2)
+		st.Expect(t, found["test"].Reason, "PSU swap")
+		st.Expect(t, found["test"].DeviceID, "test")
+		st.Expect(t, found["long"].Reason, "rebuild")
+		st.Expect(t, gock.IsDone(), true)
+	})
+}
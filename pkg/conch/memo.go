@@ -0,0 +1,130 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// memoEntry is a GET response that can be handed out again. ready is closed
+// once the first request for the URL has finished.
+type memoEntry struct {
+	ready  chan struct{}
+	failed bool
+
+	status     int
+	statusText string
+	header     http.Header
+	body       []byte
+}
+
+func (e *memoEntry) replay(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     e.statusText,
+		StatusCode: e.status,
+		Header:     e.header,
+		Body:       ioutil.NopCloser(bytes.NewReader(e.body)),
+		Request:    req,
+	}
+}
+
+type memoCache struct {
+	sync.Mutex
+	entries map[string]*memoEntry
+}
+
+// EnableMemoization makes the client remember every successful GET, so that
+// asking for the same URL again is answered without going back to the API.
+// Identical GETs that are in flight at the same time are only sent once.
+// Anything other than a GET forgets everything remembered so far, since it
+// may have changed what a GET would return.
+//
+// This is meant for a single short-lived command. Anything that polls the API
+// should leave it off.
+func (c *Conch) EnableMemoization() {
+	c.memo = &memoCache{entries: make(map[string]*memoEntry)}
+}
+
+// DisableMemoization forgets all remembered responses and turns memoization
+// back off
+func (c *Conch) DisableMemoization() {
+	c.memo = nil
+}
+
+// send performs the request and reads the whole response body, or, when
+// memoization is on, replays an identical earlier GET
+func (c *Conch) send(req *http.Request) (*http.Response, []byte, error) {
+	if c.memo == nil {
+		return c.sendNow(req)
+	}
+
+	if req.Method != "GET" {
+		c.memo.Lock()
+		c.memo.entries = make(map[string]*memoEntry)
+		c.memo.Unlock()
+		return c.sendNow(req)
+	}
+
+	key := req.URL.String()
+
+	c.memo.Lock()
+	if e, ok := c.memo.entries[key]; ok {
+		c.memo.Unlock()
+		<-e.ready
+		if !e.failed {
+			c.debugLog("Response: replayed from an earlier identical request")
+			return e.replay(req), e.body, nil
+		}
+		// The earlier request didn't work out, so try for ourselves
+		return c.sendNow(req)
+	}
+	e := &memoEntry{ready: make(chan struct{})}
+	c.memo.entries[key] = e
+	c.memo.Unlock()
+
+	res, body, err := c.sendNow(req)
+	if (err != nil) || (res.StatusCode < 200) || (res.StatusCode > 299) {
+		e.failed = true
+		c.memo.Lock()
+		if c.memo.entries[key] == e {
+			delete(c.memo.entries, key)
+		}
+		c.memo.Unlock()
+	} else {
+		e.status = res.StatusCode
+		e.statusText = res.Status
+		e.header = res.Header
+		e.body = body
+	}
+	close(e.ready)
+
+	return res, body, err
+}
+
+func (c *Conch) sendNow(req *http.Request) (*http.Response, []byte, error) {
+	res, err := c.do(req)
+	if (res == nil) || (err != nil) {
+		return res, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := readBody(res)
+	return res, body, err
+}
+
+// do sends req over the network, unless BeforeRequest objects
+func (c *Conch) do(req *http.Request) (*http.Response, error) {
+	if c.BeforeRequest != nil {
+		if err := c.BeforeRequest(req.Method, req.URL.Path); err != nil {
+			return nil, err
+		}
+	}
+	return c.HTTPClient.Do(req)
+}
@@ -0,0 +1,159 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+)
+
+// The keys in a hardware product's specification that hold how much a single
+// device of the product weighs, in kilograms, and how much power it draws, in
+// watts
+const (
+	ProductWeightKey = "weight_kg"
+	ProductPowerKey  = "power_watts"
+)
+
+// ProductDraw is what a single device of a hardware product adds to the rack
+// it is in. A nil Weight or Power means the product's specification doesn't
+// say.
+type ProductDraw struct {
+	Weight *float64 `json:"weight_kg"`
+	Power  *float64 `json:"power_watts"`
+}
+
+// specificationNumber reads a number from a hardware product's
+// specification, which may have been written as a string
+func specificationNumber(spec map[string]interface{}, key string) (*float64, error) {
+	switch v := spec[key].(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		return &v, nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("the %s of the hardware product is not a number: %s", key, v)
+		}
+		return &f, nil
+	default:
+		return nil, fmt.Errorf("the %s of the hardware product is %s, not a number", key, jsonType(v))
+	}
+}
+
+// HardwareProductDraw reads the weight and power draw out of a hardware
+// product's specification
+func HardwareProductDraw(h HardwareProduct) (ProductDraw, error) {
+	var d ProductDraw
+
+	spec, err := specificationMap(h)
+	if err != nil {
+		return d, err
+	}
+
+	if d.Weight, err = specificationNumber(spec, ProductWeightKey); err != nil {
+		return d, err
+	}
+	if d.Power, err = specificationNumber(spec, ProductPowerKey); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// RackBudgetSlot is a single slot of a rack layout, with what the product in
+// it adds to the rack
+type RackBudgetSlot struct {
+	RUStart     int       `json:"ru_start"`
+	ProductID   uuid.UUID `json:"product_id"`
+	ProductName string    `json:"product_name"`
+	ProductDraw
+}
+
+// RackBudget is the total weight and power draw of a rack, were every slot of
+// its layout filled. Products whose specifications don't give a weight or
+// power draw count as nothing, and are listed in Unknown.
+type RackBudget struct {
+	Weight  float64          `json:"weight_kg"`
+	Power   float64          `json:"power_watts"`
+	Slots   []RackBudgetSlot `json:"slots"`
+	Unknown []string         `json:"unknown_products"`
+}
+
+// NewRackBudget adds up the weight and power draw of the products in a rack
+// layout. products must hold every product the layout uses.
+func NewRackBudget(slots RackLayoutSlots, products map[uuid.UUID]HardwareProduct) (RackBudget, error) {
+	b := RackBudget{
+		Slots:   make([]RackBudgetSlot, 0, len(slots)),
+		Unknown: make([]string, 0),
+	}
+
+	sorted := make(RackLayoutSlots, len(slots))
+	copy(sorted, slots)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].RUStart < sorted[j].RUStart })
+
+	unknown := make(map[string]bool)
+	for _, s := range sorted {
+		p, ok := products[s.ProductID]
+		if !ok {
+			return b, fmt.Errorf("hardware product %s, at RU %d, is unknown", s.ProductID, s.RUStart)
+		}
+
+		d, err := HardwareProductDraw(p)
+		if err != nil {
+			return b, fmt.Errorf("%s: %s", p.Name, err)
+		}
+
+		if d.Weight != nil {
+			b.Weight += *d.Weight
+		}
+		if d.Power != nil {
+			b.Power += *d.Power
+		}
+		if (d.Weight == nil || d.Power == nil) && !unknown[p.Name] {
+			unknown[p.Name] = true
+			b.Unknown = append(b.Unknown, p.Name)
+		}
+
+		b.Slots = append(b.Slots, RackBudgetSlot{
+			RUStart:     s.RUStart,
+			ProductID:   p.ID,
+			ProductName: p.Name,
+			ProductDraw: d,
+		})
+	}
+	sort.Strings(b.Unknown)
+	return b, nil
+}
+
+// Exceeds lists how the budget goes over a rack's limits, a zero limit being
+// no limit at all
+func (b RackBudget) Exceeds(maxWeight float64, maxPower float64) []string {
+	over := make([]string, 0)
+	if maxWeight > 0 && b.Weight > maxWeight {
+		over = append(over, fmt.Sprintf(
+			"weighs %s kg, over the limit of %s kg",
+			formatFloat(b.Weight),
+			formatFloat(maxWeight),
+		))
+	}
+	if maxPower > 0 && b.Power > maxPower {
+		over = append(over, fmt.Sprintf(
+			"draws %s W, over the limit of %s W",
+			formatFloat(b.Power),
+			formatFloat(maxPower),
+		))
+	}
+	return over
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
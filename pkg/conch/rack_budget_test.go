@@ -0,0 +1,70 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/nbio/st"
+)
+
+func TestRackBudget(t *testing.T) {
+	server := conch.HardwareProduct{
+		ID:   uuid.NewV4(),
+		Name: "server",
+		Specification: map[string]interface{}{
+			conch.ProductWeightKey: 25.5,
+			conch.ProductPowerKey:  "450",
+		},
+	}
+	sw := conch.HardwareProduct{
+		ID:            uuid.NewV4(),
+		Name:          "switch",
+		Specification: `{"weight_kg": 8}`,
+	}
+	products := map[uuid.UUID]conch.HardwareProduct{
+		server.ID: server,
+		sw.ID:     sw,
+	}
+
+	t.Run("totals", func(t *testing.T) {
+		b, err := conch.NewRackBudget(conch.RackLayoutSlots{
+			{ProductID: server.ID, RUStart: 3},
+			{ProductID: sw.ID, RUStart: 40},
+			{ProductID: server.ID, RUStart: 1},
+		}, products)
+		st.Expect(t, err, nil)
+		st.Expect(t, b.Weight, 59.0)
+		st.Expect(t, b.Power, 900.0)
+		st.Expect(t, b.Unknown, []string{"switch"})
+		st.Expect(t, len(b.Slots), 3)
+		st.Expect(t, b.Slots[0].RUStart, 1)
+		st.Expect(t, b.Slots[2].Power, (*float64)(nil))
+
+		st.Expect(t, b.Exceeds(0, 0), []string{})
+		st.Expect(t, b.Exceeds(60, 800), []string{"draws 900 W, over the limit of 800 W"})
+	})
+
+	t.Run("unknown product", func(t *testing.T) {
+		_, err := conch.NewRackBudget(conch.RackLayoutSlots{
+			{ProductID: uuid.NewV4(), RUStart: 1},
+		}, products)
+		st.Reject(t, err, nil)
+	})
+
+	t.Run("bad specification", func(t *testing.T) {
+		bad := conch.HardwareProduct{
+			ID:            uuid.NewV4(),
+			Name:          "bad",
+			Specification: map[string]interface{}{conch.ProductPowerKey: "lots"},
+		}
+		_, err := conch.HardwareProductDraw(bad)
+		st.Reject(t, err, nil)
+	})
+}
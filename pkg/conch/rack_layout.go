@@ -7,11 +7,152 @@
 package conch
 
 import (
+	"fmt"
 	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/joyent/conch-shell/pkg/conch/uuid"
 )
 
+// LayoutConflict is a pair of slots in a rack whose rack units overlap
+type LayoutConflict struct {
+	Slot     RackLayoutSlot `json:"slot"`
+	SlotEnd  int            `json:"slot_ru_end"`
+	Other    RackLayoutSlot `json:"other"`
+	OtherEnd int            `json:"other_ru_end"`
+}
+
+func (c LayoutConflict) Error() string {
+	other := ""
+	if !uuid.Equal(c.Other.ID, uuid.UUID{}) {
+		other = " " + c.Other.ID.String()
+	}
+	return fmt.Sprintf(
+		"the slot at RU %d-%d overlaps the slot%s at RU %d-%d",
+		c.Slot.RUStart,
+		c.SlotEnd,
+		other,
+		c.Other.RUStart,
+		c.OtherEnd,
+	)
+}
+
+// LayoutConflicts is every overlap found in a layout. It is returned as an
+// error by CheckRackLayout and CheckRackLayoutSlot.
+type LayoutConflicts []LayoutConflict
+
+func (l LayoutConflicts) Error() string {
+	lines := make([]string, 0, len(l))
+	for _, c := range l {
+		lines = append(lines, c.Error())
+	}
+	return "the rack layout has overlapping slots: " + strings.Join(lines, "; ")
+}
+
+// RackUnitSizes maps hardware products to the number of rack units they take
+// up, for CheckRackLayout
+func RackUnitSizes(products []HardwareProduct) map[uuid.UUID]int {
+	sizes := make(map[uuid.UUID]int)
+	for _, p := range products {
+		sizes[p.ID] = p.Profile.RackUnit
+	}
+	return sizes
+}
+
+// RackUnits is the first and last rack unit the slot takes up, given the
+// sizes of hardware products. A product that is missing, or has no size,
+// takes up one rack unit.
+func (r RackLayoutSlot) RackUnits(sizes map[uuid.UUID]int) (int, int) {
+	size := sizes[r.ProductID]
+	if size < 1 {
+		size = 1
+	}
+	return r.RUStart, r.RUStart + size - 1
+}
+
+// CheckRackLayout checks that none of the slots of a rack overlap, without
+// asking the API. It returns LayoutConflicts if some do.
+func CheckRackLayout(slots RackLayoutSlots, sizes map[uuid.UUID]int) error {
+	sorted := make(RackLayoutSlots, len(slots))
+	copy(sorted, slots)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].RUStart < sorted[j].RUStart })
+
+	conflicts := make(LayoutConflicts, 0)
+	for i, a := range sorted {
+		_, aEnd := a.RackUnits(sizes)
+		for _, b := range sorted[i+1:] {
+			bStart, bEnd := b.RackUnits(sizes)
+			if bStart > aEnd {
+				break
+			}
+			conflicts = append(conflicts, LayoutConflict{
+				Slot:     b,
+				SlotEnd:  bEnd,
+				Other:    a,
+				OtherEnd: aEnd,
+			})
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return conflicts
+	}
+	return nil
+}
+
+// CheckRackLayoutSlot checks that saving r wouldn't make it overlap another
+// slot in its rack. The server only reports an overlap once it gets to it,
+// and not clearly. It returns LayoutConflicts if r would overlap anything.
+func (c *Conch) CheckRackLayoutSlot(r *RackLayoutSlot) error {
+	existing, err := c.GetRackLayout(Rack{ID: r.RackID})
+	if err != nil {
+		return err
+	}
+
+	products, err := c.GetAllHardwareProducts()
+	if err != nil {
+		return err
+	}
+	sizes := RackUnitSizes(products)
+
+	start, end := r.RackUnits(sizes)
+
+	conflicts := make(LayoutConflicts, 0)
+	for _, s := range existing {
+		if !uuid.Equal(r.ID, uuid.UUID{}) && uuid.Equal(s.ID, r.ID) {
+			continue
+		}
+		sStart, sEnd := s.RackUnits(sizes)
+		if start <= sEnd && sStart <= end {
+			conflicts = append(conflicts, LayoutConflict{
+				Slot:     *r,
+				SlotEnd:  end,
+				Other:    s,
+				OtherEnd: sEnd,
+			})
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return conflicts
+	}
+	return nil
+}
+
+// SaveRackLayoutSlotChecked is SaveRackLayoutSlot, after making sure with
+// CheckRackLayoutSlot that the slot doesn't overlap another
+func (c *Conch) SaveRackLayoutSlotChecked(r *RackLayoutSlot) error {
+	// SaveRackLayoutSlot says what is wrong with a slot without a rack
+	if uuid.Equal(r.RackID, uuid.UUID{}) {
+		return c.SaveRackLayoutSlot(r)
+	}
+	if err := c.CheckRackLayoutSlot(r); err != nil {
+		return err
+	}
+	return c.SaveRackLayoutSlot(r)
+}
+
 func (c *Conch) GetRackLayoutSlots() (RackLayoutSlots, error) {
 	r := make([]RackLayoutSlot, 0)
 	return r, c.get("/layout", &r)
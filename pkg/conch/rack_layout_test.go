@@ -75,3 +75,67 @@ func TestRackLayoutSlotErrors(t *testing.T) {
 	})
 
 }
+
+func TestRackLayoutConflicts(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	small := uuid.NewV4()
+	big := uuid.NewV4()
+	products := []conch.HardwareProduct{
+		{ID: small, Profile: conch.HardwareProfile{RackUnit: 1}},
+		{ID: big, Profile: conch.HardwareProfile{RackUnit: 4}},
+	}
+	sizes := conch.RackUnitSizes(products)
+
+	t.Run("CheckRackLayout", func(t *testing.T) {
+		ok := conch.RackLayoutSlots{
+			{ProductID: big, RUStart: 1},
+			{ProductID: small, RUStart: 5},
+			{ProductID: big, RUStart: 6},
+		}
+		st.Expect(t, conch.CheckRackLayout(ok, sizes), nil)
+
+		bad := conch.RackLayoutSlots{
+			{ProductID: small, RUStart: 3},
+			{ProductID: big, RUStart: 1},
+			{ProductID: small, RUStart: 5},
+		}
+		err := conch.CheckRackLayout(bad, sizes)
+		conflicts, isConflicts := err.(conch.LayoutConflicts)
+		st.Assert(t, isConflicts, true)
+		st.Expect(t, len(conflicts), 1)
+		st.Expect(t, conflicts[0].Slot.RUStart, 3)
+		st.Expect(t, conflicts[0].Other.RUStart, 1)
+		st.Expect(t, conflicts[0].OtherEnd, 4)
+	})
+
+	t.Run("SaveRackLayoutSlotChecked", func(t *testing.T) {
+		rackID := uuid.NewV4()
+		existing := conch.RackLayoutSlots{
+			{ID: uuid.NewV4(), RackID: rackID, ProductID: big, RUStart: 1},
+		}
+
+		gock.New(API.BaseURL).Get("/rack/" + rackID.String() + "/layouts").
+			Persist().Reply(200).JSON(existing)
+		gock.New(API.BaseURL).Get("/hardware_product").
+			MatchParam("include_deactivated", "1").
+			Persist().Reply(200).JSON(products)
+
+		r := conch.RackLayoutSlot{RackID: rackID, ProductID: small, RUStart: 2}
+		err := API.SaveRackLayoutSlotChecked(&r)
+		_, isConflicts := err.(conch.LayoutConflicts)
+		st.Expect(t, isConflicts, true)
+
+		// Moving the slot itself is not an overlap
+		moved := existing[0]
+		moved.RUStart = 2
+		gock.New(API.BaseURL).Post("/layout/" + moved.ID.String()).
+			Reply(200).JSON(moved)
+		st.Expect(t, API.SaveRackLayoutSlotChecked(&moved), nil)
+
+		r.RUStart = 5
+		gock.New(API.BaseURL).Post("/layout").Reply(200).JSON(r)
+		st.Expect(t, API.SaveRackLayoutSlotChecked(&r), nil)
+	})
+}
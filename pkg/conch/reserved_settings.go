@@ -0,0 +1,46 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"strings"
+)
+
+// reservedSettingPrefixes begin the names of the device settings that the
+// shell keeps its own records in, because the API has nowhere else for them
+var reservedSettingPrefixes = []string{
+	RMASettingPrefix,
+	TicketSettingPrefix,
+	CustomFieldPrefix,
+	DecommissionSettingPrefix,
+	MaintenanceSetting + ".",
+}
+
+// reservedSettings are single device settings that the shell keeps its own
+// records in
+var reservedSettings = map[string]bool{
+	MaintenanceSetting:    true,
+	MergedIntoSetting:     true,
+	MergedFromSetting:     true,
+	ValidationPlanSetting: true,
+}
+
+// IsReservedSetting is true for the device settings that hold the shell's
+// own records of a device, like its RMA history, linked tickets, or
+// maintenance. They belong to that one device, so they must not be pruned as
+// stray configuration or copied to another device.
+func IsReservedSetting(key string) bool {
+	if reservedSettings[key] {
+		return true
+	}
+	for _, p := range reservedSettingPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
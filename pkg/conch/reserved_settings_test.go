@@ -0,0 +1,34 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+)
+
+func TestIsReservedSetting(t *testing.T) {
+	for _, k := range []string{
+		"rma.20190304T050607.000008Z",
+		"ticket.OPS-1234",
+		"field.cost_center",
+		"decommission.date",
+		"maintenance",
+		"maintenance.ends",
+		"merged_into",
+		"merged_from",
+		"validation.plan",
+	} {
+		st.Expect(t, conch.IsReservedSetting(k), true)
+	}
+
+	for _, k := range []string{"build.phase", "ipmi.user", "maintenance_window", "tickets"} {
+		st.Expect(t, conch.IsReservedSetting(k), false)
+	}
+}
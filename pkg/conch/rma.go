@@ -0,0 +1,106 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RMASettingPrefix begins the name of every device setting that holds an
+// RMARecord. The rest of the name is the time of the replacement.
+const RMASettingPrefix = "rma."
+
+// rmaKeyFormat keeps setting names unique and in time order
+const rmaKeyFormat = "20060102T150405.000000Z"
+
+// RMARecord is the removal, and usually replacement, of a failed component.
+// The API has no facility for these so they're kept as device settings.
+type RMARecord struct {
+	DeviceID          string    `json:"device_id"`
+	Kind              string    `json:"kind"`
+	Slot              string    `json:"slot,omitempty"`
+	Vendor            string    `json:"vendor,omitempty"`
+	Model             string    `json:"model"`
+	RemovedSerial     string    `json:"removed_serial"`
+	ReplacementSerial string    `json:"replacement_serial,omitempty"`
+	Reason            string    `json:"reason"`
+	Ticket            string    `json:"ticket,omitempty"`
+	User              string    `json:"user,omitempty"`
+	Time              time.Time `json:"time"`
+}
+
+// RMARecords is a list of RMARecord
+type RMARecords []RMARecord
+
+// SettingKey is the name of the device setting the record is stored under
+func (r RMARecord) SettingKey() string {
+	return RMASettingPrefix + r.Time.UTC().Format(rmaKeyFormat)
+}
+
+// RMARecordsFromSettings pulls the RMA records out of a device's settings,
+// oldest first. The names of any rma.* settings that can't be understood are
+// returned as well.
+func RMARecordsFromSettings(deviceID string, settings map[string]string) (RMARecords, []string) {
+	records := make(RMARecords, 0)
+	bad := make([]string, 0)
+
+	for k, v := range settings {
+		if !strings.HasPrefix(k, RMASettingPrefix) {
+			continue
+		}
+		var r RMARecord
+		if err := json.Unmarshal([]byte(v), &r); err != nil || r.Time.IsZero() {
+			bad = append(bad, k)
+			continue
+		}
+		if r.DeviceID == "" {
+			r.DeviceID = deviceID
+		}
+		records = append(records, r)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	sort.Strings(bad)
+	return records, bad
+}
+
+// RecordRMA stores an RMA record as a setting on its device
+func (c *Conch) RecordRMA(r RMARecord) error {
+	if r.DeviceID == "" {
+		return errors.New("an RMA record needs a device")
+	}
+	if r.RemovedSerial == "" {
+		return errors.New("an RMA record needs the serial of the removed component")
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Time = r.Time.UTC()
+
+	j, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return c.SetDeviceSetting(r.DeviceID, r.SettingKey(), string(j))
+}
+
+// GetDeviceRMAs fetches the RMA records of a device, oldest first. Settings
+// that look like RMA records but can't be read are skipped.
+func (c *Conch) GetDeviceRMAs(deviceID string) (RMARecords, error) {
+	settings, err := c.GetDeviceSettings(deviceID)
+	if err != nil {
+		return make(RMARecords, 0), err
+	}
+	records, _ := RMARecordsFromSettings(deviceID, settings)
+	return records, nil
+}
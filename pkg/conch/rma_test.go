@@ -0,0 +1,59 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestRMARecords(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	when := time.Date(2019, 3, 4, 5, 6, 7, 8000, time.UTC)
+	r := conch.RMARecord{
+		DeviceID:      "test",
+		Kind:          conch.ComponentDisk,
+		Model:         "HUH721212AL",
+		RemovedSerial: "S1",
+		Reason:        "smart failure",
+		Time:          when,
+	}
+	st.Expect(t, r.SettingKey(), "rma.20190304T050607.000008Z")
+
+	t.Run("RecordRMA", func(t *testing.T) {
+		gock.New(API.BaseURL).Post("/device/test/settings/rma.20190304T050607.000008Z").
+			Reply(204)
+
+		st.Expect(t, API.RecordRMA(r), nil)
+		st.Expect(t, gock.IsDone(), true)
+
+		st.Reject(t, API.RecordRMA(conch.RMARecord{DeviceID: "test"}), nil)
+	})
+
+	t.Run("RMARecordsFromSettings", func(t *testing.T) {
+		settings := map[string]string{
+			"rma.20190305T000000.000000Z": `{"kind":"dimm","removed_serial":"D1","time":"2019-03-05T00:00:00Z"}`,
+			"rma.20190304T050607.000008Z": `{"device_id":"test","kind":"disk","removed_serial":"S1","time":"2019-03-04T05:06:07.000008Z"}`,
+			"rma.broken":                  `not json`,
+			"build.phase":                 `integration`,
+		}
+
+		records, bad := conch.RMARecordsFromSettings("test", settings)
+		st.Expect(t, len(records), 2)
+		st.Expect(t, records[0].RemovedSerial, "S1")
+		st.Expect(t, records[0].Time.Equal(when), true)
+		st.Expect(t, records[1].RemovedSerial, "D1")
+		st.Expect(t, records[1].DeviceID, "test")
+		st.Expect(t, bad, []string{"rma.broken"})
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SettingsTemplateKey is the key in a hardware product's specification that
+// holds the product's settings template
+const SettingsTemplateKey = "settings_template"
+
+// SettingsTemplate is the canonical set of device settings for devices of a
+// single hardware product, as setting names mapped to their values
+type SettingsTemplate map[string]string
+
+// SettingChange is a single difference between a device's settings and a
+// settings template. Action is either "add" or "change".
+type SettingChange struct {
+	Name     string `json:"name"`
+	Action   string `json:"action"`
+	Current  string `json:"current,omitempty"`
+	Template string `json:"template"`
+}
+
+// ParseSettingsTemplate decodes and validates a settings template. The
+// template must be a single JSON object whose values are all strings, since
+// that is how the API stores device settings.
+func ParseSettingsTemplate(raw []byte) (SettingsTemplate, error) {
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, fmt.Errorf("settings template is not valid JSON: %s", err)
+	}
+
+	obj, ok := generic.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("settings template must be a JSON object of setting names to values")
+	}
+
+	t := make(SettingsTemplate)
+	for k, v := range obj {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf(
+				"the value of setting '%s' must be a string, not %s",
+				k,
+				jsonType(v),
+			)
+		}
+		t[k] = s
+	}
+
+	return t, t.Validate()
+}
+
+// Validate checks that every setting in the template could be set on a device
+func (t SettingsTemplate) Validate() error {
+	for k := range t {
+		if k == "" {
+			return errors.New("settings template contains an empty setting name")
+		}
+		if strings.TrimSpace(k) != k || strings.ContainsAny(k, " \t\n/") {
+			return fmt.Errorf("setting name '%s' may not contain whitespace or slashes", k)
+		}
+		if isTag(k) {
+			return fmt.Errorf("setting '%s' is a tag. Tags can't be part of a settings template", k)
+		}
+	}
+	return nil
+}
+
+// Diff lists what would have to be set on a device with the given settings to
+// make it match the template, sorted by setting name. Settings that the
+// template doesn't mention are left alone and not listed.
+func (t SettingsTemplate) Diff(current map[string]string) []SettingChange {
+	names := make([]string, 0, len(t))
+	for k := range t {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	changes := make([]SettingChange, 0)
+	for _, k := range names {
+		have, ok := current[k]
+		switch {
+		case !ok:
+			changes = append(changes, SettingChange{
+				Name:     k,
+				Action:   "add",
+				Template: t[k],
+			})
+		case have != t[k]:
+			changes = append(changes, SettingChange{
+				Name:     k,
+				Action:   "change",
+				Current:  have,
+				Template: t[k],
+			})
+		}
+	}
+	return changes
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// specificationMap returns a hardware product's specification as an object,
+// creating an empty one if the product doesn't have a specification yet
+func specificationMap(h HardwareProduct) (map[string]interface{}, error) {
+	switch spec := h.Specification.(type) {
+	case nil:
+		return make(map[string]interface{}), nil
+	case map[string]interface{}:
+		return spec, nil
+	case string:
+		if spec == "" {
+			return make(map[string]interface{}), nil
+		}
+		m := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(spec), &m); err != nil {
+			return nil, errors.New("the hardware product specification is not a JSON object")
+		}
+		return m, nil
+	}
+	return nil, errors.New("the hardware product specification is not a JSON object")
+}
+
+// GetSettingsTemplate fetches the settings template stored in a hardware
+// product's specification. A product without a template has an empty one.
+func (c *Conch) GetSettingsTemplate(productID fmt.Stringer) (SettingsTemplate, error) {
+	h, err := c.GetHardwareProduct(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := specificationMap(h)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, ok := spec[SettingsTemplateKey]
+	if !ok || raw == nil {
+		return make(SettingsTemplate), nil
+	}
+
+	j, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSettingsTemplate(j)
+}
+
+// SaveSettingsTemplate stores a settings template in a hardware product's
+// specification, leaving the rest of the specification as it is. Saving an
+// empty template removes it from the product.
+func (c *Conch) SaveSettingsTemplate(productID fmt.Stringer, t SettingsTemplate) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	h, err := c.GetHardwareProduct(productID)
+	if err != nil {
+		return err
+	}
+
+	spec, err := specificationMap(h)
+	if err != nil {
+		return err
+	}
+
+	if len(t) == 0 {
+		delete(spec, SettingsTemplateKey)
+	} else {
+		spec[SettingsTemplateKey] = t
+	}
+	h.Specification = spec
+
+	return c.SaveHardwareProduct(&h)
+}
@@ -0,0 +1,111 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestSettingsTemplate(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	t.Run("ParseSettingsTemplate", func(t *testing.T) {
+		tmpl, err := conch.ParseSettingsTemplate([]byte(`{"build.os":"smartos","ipmi.user":"root"}`))
+		st.Expect(t, err, nil)
+		st.Expect(t, tmpl, conch.SettingsTemplate{
+			"build.os":  "smartos",
+			"ipmi.user": "root",
+		})
+
+		bad := []string{
+			`[]`,
+			`"build.os"`,
+			`{"build.os": 1}`,
+			`{"build.os": null}`,
+			`{"tag.owner": "ops"}`,
+			`{"": "empty"}`,
+			`{"has space": "x"}`,
+			`{`,
+		}
+		for _, b := range bad {
+			_, err := conch.ParseSettingsTemplate([]byte(b))
+			st.Reject(t, err, nil)
+		}
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		tmpl := conch.SettingsTemplate{
+			"a": "1",
+			"b": "2",
+			"c": "3",
+		}
+
+		changes := tmpl.Diff(map[string]string{
+			"a":     "1",
+			"c":     "4",
+			"extra": "left alone",
+		})
+		st.Expect(t, changes, []conch.SettingChange{
+			{Name: "b", Action: "add", Template: "2"},
+			{Name: "c", Action: "change", Current: "4", Template: "3"},
+		})
+
+		st.Expect(t, len(tmpl.Diff(map[string]string(tmpl))), 0)
+	})
+
+	t.Run("GetSettingsTemplate", func(t *testing.T) {
+		id := uuid.NewV4()
+
+		gock.New(API.BaseURL).Get("/hardware_product/" + id.String()).
+			Reply(200).JSON(map[string]interface{}{
+			"id":            id.String(),
+			"specification": `{"cpu":"fast","settings_template":{"build.os":"smartos"}}`,
+		})
+
+		tmpl, err := API.GetSettingsTemplate(id)
+		st.Expect(t, err, nil)
+		st.Expect(t, tmpl, conch.SettingsTemplate{"build.os": "smartos"})
+
+		gock.New(API.BaseURL).Get("/hardware_product/" + id.String()).
+			Reply(200).JSON(map[string]interface{}{"id": id.String()})
+
+		tmpl, err = API.GetSettingsTemplate(id)
+		st.Expect(t, err, nil)
+		st.Expect(t, tmpl, conch.SettingsTemplate{})
+	})
+
+	t.Run("SaveSettingsTemplate", func(t *testing.T) {
+		id := uuid.NewV4()
+
+		gock.New(API.BaseURL).Get("/hardware_product/" + id.String()).
+			Reply(200).JSON(map[string]interface{}{
+			"id":                 id.String(),
+			"name":               "test",
+			"alias":              "test",
+			"hardware_vendor_id": uuid.NewV4().String(),
+			"specification":      `{"cpu":"fast"}`,
+		})
+
+		// The rest of the specification has to survive the update
+		gock.New(API.BaseURL).Post("/hardware_product/" + id.String()).
+			BodyString(`cpu.*settings_template.*build\.os`).
+			Reply(200).JSON(map[string]interface{}{"id": id.String()})
+
+		err := API.SaveSettingsTemplate(id, conch.SettingsTemplate{"build.os": "smartos"})
+		st.Expect(t, err, nil)
+		st.Expect(t, gock.IsDone(), true)
+
+		err = API.SaveSettingsTemplate(id, conch.SettingsTemplate{"tag.owner": "ops"})
+		st.Reject(t, err, nil)
+	})
+}
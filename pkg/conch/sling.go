@@ -7,6 +7,7 @@
 package conch
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -35,6 +36,12 @@ var defaultTransport = &http.Transport{
 	TLSHandshakeTimeout: 5 * time.Second,
 }
 
+// DefaultTransport is the transport a Conch uses unless it is given one, eg
+// for wrapping in something that watches the requests go by
+func DefaultTransport() http.RoundTripper {
+	return defaultTransport
+}
+
 func (c *Conch) sling() *sling.Sling {
 	if c.UA == "" {
 		c.UA = defaultUA
@@ -49,8 +56,13 @@ func (c *Conch) sling() *sling.Sling {
 	}
 
 	if c.HTTPClient == nil {
+		transport := c.Transport
+		if transport == nil {
+			transport = defaultTransport
+		}
+
 		c.HTTPClient = &http.Client{
-			Transport: defaultTransport,
+			Transport: transport,
 			Jar:       c.CookieJar,
 
 			// Preserve auth header on redirect
@@ -86,6 +98,15 @@ func (c *Conch) sling() *sling.Sling {
 		Base(c.BaseURL).
 		Set("User-Agent", c.UA)
 
+	// Asking for gzip ourselves, rather than leaving it to net/http, means
+	// that it also works for clients with DisableCompression set. send()
+	// takes care of the decompression.
+	if c.NoCompression {
+		s = s.Set("Accept-Encoding", "identity")
+	} else {
+		s = s.Set("Accept-Encoding", "gzip")
+	}
+
 	if c.Token != "" {
 		s = s.Set("Authorization", "Bearer "+c.Token)
 	} else {
@@ -115,31 +136,68 @@ func (c *Conch) httpDo(req *http.Request, data interface{}) (*http.Response, err
 		req.URL,
 	))
 
-	if (req.Method == "POST") && (req.Body != nil) {
+	var reqBytes []byte
+	if (req.Body != nil) && (req.GetBody != nil) {
 		if read, err := req.GetBody(); err == nil {
-			if bodyBytes, err := ioutil.ReadAll(read); err == nil {
-				c.traceLog(
-					fmt.Sprintf(
-						"  Request Body: %s",
-						string(bodyBytes),
-					),
-				)
-			}
+			reqBytes, _ = ioutil.ReadAll(read)
 		}
 	}
 
-	res, err := c.HTTPClient.Do(req)
-	if (res == nil) || (err != nil) {
-		return res, err
+	if (req.Method == "POST") && (reqBytes != nil) {
+		c.traceLog(
+			fmt.Sprintf(
+				"  Request Body: %s",
+				string(reqBytes),
+			),
+		)
 	}
 
-	defer res.Body.Close()
+	if (c.CompressRequestsOver > 0) && (len(reqBytes) > c.CompressRequestsOver) {
+		if err := compressRequest(req, reqBytes); err != nil {
+			return nil, err
+		}
+		c.debugLog(fmt.Sprintf(
+			"  Request body gzipped from %d bytes to %d bytes",
+			len(reqBytes),
+			req.ContentLength,
+		))
+	}
 
-	bodyBytes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
+	mutation := (c.OnMutation != nil) && (req.Method != "GET") && (req.Method != "HEAD")
+
+	var before json.RawMessage
+	if mutation && (c.BeforeMutation != nil) {
+		before = c.BeforeMutation(req.Method, req.URL.Path)
+	}
+
+	res, bodyBytes, err := c.send(req)
+	if (res == nil) || (err != nil) {
+		if mutation {
+			m := Mutation{
+				Method:  req.Method,
+				URL:     req.URL.String(),
+				Request: rawJSON(reqBytes),
+				Before:  before,
+			}
+			if err != nil {
+				m.Error = err.Error()
+			}
+			c.OnMutation(m)
+		}
 		return res, err
 	}
 
+	if mutation {
+		c.OnMutation(Mutation{
+			Method:   req.Method,
+			URL:      req.URL.String(),
+			Status:   res.StatusCode,
+			Request:  rawJSON(reqBytes),
+			Response: rawJSON(bodyBytes),
+			Before:   before,
+		})
+	}
+
 	if c.Trace {
 		c.traceLogDDP(
 			fmt.Sprintf(
@@ -197,6 +255,20 @@ func (c *Conch) httpDo(req *http.Request, data interface{}) (*http.Response, err
 	return res, ErrHTTPNotOk
 }
 
+// rawJSON passes valid JSON through untouched and wraps anything else up as a
+// JSON string, so that a Mutation always marshals
+func rawJSON(b []byte) json.RawMessage {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil
+	}
+	if json.Valid(b) {
+		return json.RawMessage(b)
+	}
+	s, _ := json.Marshal(string(b))
+	return json.RawMessage(s)
+}
+
 func (c *Conch) getWithQuery(url string, query interface{}, data interface{}) error {
 	req, err := c.sling().New().Get(url).QueryStruct(query).Request()
 	if err != nil {
@@ -281,7 +353,7 @@ func (c *Conch) RawGet(url string) (*http.Response, error) {
 		return nil, err
 	}
 
-	return c.HTTPClient.Do(req)
+	return decompressed(c.do(req))
 }
 
 // RawDelete allows the user to perform an HTTP DELETE against the API, with the
@@ -292,7 +364,7 @@ func (c *Conch) RawDelete(url string, body io.Reader) (*http.Response, error) {
 		return nil, err
 	}
 
-	return c.HTTPClient.Do(req)
+	return decompressed(c.do(req))
 }
 
 // RawPost allows the user to perform an HTTP POST against the API, with the
@@ -305,5 +377,5 @@ func (c *Conch) RawPost(url string, body io.Reader) (*http.Response, error) {
 		return nil, err
 	}
 
-	return c.HTTPClient.Do(req)
+	return decompressed(c.do(req))
 }
@@ -34,6 +34,50 @@ type Conch struct {
 
 	HTTPClient *http.Client
 	CookieJar  *cookiejar.Jar
+
+	// Transport, if set, is used by the HTTP client the Conch builds for
+	// itself, in place of the default transport. It has no effect if
+	// HTTPClient is set.
+	Transport http.RoundTripper
+
+	// OnMutation, if set, is called after every request that is not a GET
+	OnMutation func(Mutation)
+
+	// BeforeMutation, if set, is called before every request that OnMutation
+	// would see, with the request method and the unescaped URL path. What it
+	// returns, typically the state about to be changed, is passed along as
+	// the Before of the Mutation.
+	BeforeMutation func(method string, path string) json.RawMessage
+
+	// BeforeRequest, if set, is called before every request that is about
+	// to go out over the network, with the request method and the unescaped
+	// URL path. If it returns an error, the request is not sent and the
+	// error is returned instead. Requests answered from the memoization
+	// cache don't count.
+	BeforeRequest func(method string, path string) error
+
+	// NoCompression asks the API to send responses uncompressed. Otherwise,
+	// responses are requested gzipped and decompressed transparently.
+	NoCompression bool
+
+	// CompressRequestsOver, if set, gzips request bodies larger than this
+	// many bytes, eg large device reports. The server must accept gzipped
+	// request bodies.
+	CompressRequestsOver int
+
+	memo *memoCache
+}
+
+// Mutation records a single request that may have changed data on the server,
+// along with what the server said about it
+type Mutation struct {
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Status   int             `json:"status"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Before   json.RawMessage `json:"before,omitempty"`
 }
 
 type ConchJWT struct {
@@ -100,7 +144,7 @@ type Device struct {
 	LatestReportIsInvalid bool               `json:"latest_report_is_invalid"`
 	InvalidReport         string             `json:"invalid_report"`
 	Disks                 []Disk             `json:"disks"`
-	RackUnitStart         int                `json:"rack_unit_start`
+	RackUnitStart         int                `json:"rack_unit_start"`
 	RackID                uuid.UUID          `json:"rack_id"`
 	Phase                 string             `json:"phase"`
 }
@@ -227,6 +271,7 @@ type HardwareProduct struct {
 	Profile           HardwareProfile `json:"hardware_product_profile"`
 	Created           time.Time       `json:"created"`
 	Updated           time.Time       `json:"updated"`
+	Deactivated       time.Time       `json:"deactivated"`
 }
 
 func (h *HardwareProduct) UnmarshalJSON(data []byte) error {
@@ -243,6 +288,7 @@ func (h *HardwareProduct) UnmarshalJSON(data []byte) error {
 		Profile           HardwareProfile `json:"hardware_product_profile"`
 		Created           time.Time       `json:"created"`
 		Updated           time.Time       `json:"updated"`
+		Deactivated       time.Time       `json:"deactivated"`
 	}{}
 
 	if err := json.Unmarshal(data, &r); err != nil {
@@ -260,6 +306,7 @@ func (h *HardwareProduct) UnmarshalJSON(data []byte) error {
 	h.Profile = r.Profile
 	h.Created = r.Created
 	h.Updated = r.Updated
+	h.Deactivated = r.Deactivated
 
 	if r.Specification == "" {
 		h.Specification = make(map[string]interface{})
@@ -429,6 +476,7 @@ type UserProfile struct {
 	Name                string             `json:"name"`
 	RefuseSessionAuth   bool               `json:"refuse_session_auth"`
 	Workspaces          WorkspacesAndRoles `json:"workspaces"`
+	IsAdmin             bool               `json:"is_admin"`
 }
 
 // Validation represents device validations loaded into Conch
@@ -521,6 +569,7 @@ type ValidationState struct {
 	Created          time.Time          `json:"created"`
 	Completed        time.Time          `json:"completed"`
 	DeviceID         string             `json:"device_id"`
+	DeviceReportID   uuid.UUID          `json:"device_report_id"`
 	Results          []ValidationResult `json:"results"`
 	Status           string             `json:"status"`
 	ValidationPlanID uuid.UUID          `json:"validation_plan_id"`
@@ -629,11 +678,23 @@ type CreateNewUserToken struct {
 }
 
 // corresponds to conch.git/json-schema/response.yaml;UserToken
+// LastIPAddr is only filled in by servers that record where each token was
+// last used from
 type UserToken struct {
-	Name     string    `json:"name"`
-	Created  time.Time `json:"created"`
-	LastUsed time.Time `json:"last_used,omitempty"`
-	Expires  time.Time `json:"expires"`
+	Name       string    `json:"name"`
+	Created    time.Time `json:"created"`
+	LastUsed   time.Time `json:"last_used,omitempty"`
+	LastIPAddr string    `json:"last_ipaddr,omitempty"`
+	Expires    time.Time `json:"expires"`
+}
+
+// LoginTokenPrefix starts the names the API gives the tokens of login
+// sessions, as opposed to the API tokens that users name themselves
+const LoginTokenPrefix = "login_jwt_"
+
+// IsLogin is true if the token is that of a login session
+func (u UserToken) IsLogin() bool {
+	return strings.HasPrefix(u.Name, LoginTokenPrefix)
 }
 
 type UserTokens []UserToken
@@ -0,0 +1,121 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TicketSettingPrefix begins the name of every device setting that holds a
+// TicketLink. The rest of the name is the ticket, eg "ticket.OPS-1234".
+const TicketSettingPrefix = "ticket."
+
+// TicketLink ties a device to a ticket in an outside tracker, like JIRA. The
+// API has no facility for these so they're kept as device settings.
+type TicketLink struct {
+	DeviceID string    `json:"device_id"`
+	Ticket   string    `json:"ticket"`
+	Note     string    `json:"note,omitempty"`
+	User     string    `json:"user,omitempty"`
+	Linked   time.Time `json:"linked"`
+}
+
+// TicketLinks is a list of TicketLink
+type TicketLinks []TicketLink
+
+// SettingKey is the name of the device setting the link is stored under
+func (l TicketLink) SettingKey() string {
+	return TicketSettingPrefix + l.Ticket
+}
+
+// ValidateTicket checks that a ticket can be part of a setting name
+func ValidateTicket(ticket string) error {
+	if ticket == "" {
+		return errors.New("a ticket link needs a ticket")
+	}
+	if strings.ContainsAny(ticket, "/ \t") {
+		return fmt.Errorf("ticket '%s' can't contain slashes or spaces", ticket)
+	}
+	return nil
+}
+
+// TicketLinksFromSettings pulls the ticket links out of a device's settings,
+// oldest first. The names of any ticket.* settings that can't be understood
+// are returned as well.
+func TicketLinksFromSettings(deviceID string, settings map[string]string) (TicketLinks, []string) {
+	links := make(TicketLinks, 0)
+	bad := make([]string, 0)
+
+	for k, v := range settings {
+		if !strings.HasPrefix(k, TicketSettingPrefix) {
+			continue
+		}
+		var l TicketLink
+		if err := json.Unmarshal([]byte(v), &l); err != nil || l.Ticket == "" {
+			bad = append(bad, k)
+			continue
+		}
+		if l.DeviceID == "" {
+			l.DeviceID = deviceID
+		}
+		links = append(links, l)
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Linked.Equal(links[j].Linked) {
+			return links[i].Ticket < links[j].Ticket
+		}
+		return links[i].Linked.Before(links[j].Linked)
+	})
+	sort.Strings(bad)
+	return links, bad
+}
+
+// LinkTicket stores a ticket link as a setting on its device, replacing any
+// earlier link to the same ticket
+func (c *Conch) LinkTicket(l TicketLink) error {
+	if l.DeviceID == "" {
+		return errors.New("a ticket link needs a device")
+	}
+	if err := ValidateTicket(l.Ticket); err != nil {
+		return err
+	}
+	if l.Linked.IsZero() {
+		l.Linked = time.Now()
+	}
+	l.Linked = l.Linked.UTC()
+
+	j, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return c.SetDeviceSetting(l.DeviceID, l.SettingKey(), string(j))
+}
+
+// UnlinkTicket removes the link between a device and a ticket
+func (c *Conch) UnlinkTicket(deviceID string, ticket string) error {
+	if err := ValidateTicket(ticket); err != nil {
+		return err
+	}
+	return c.DeleteDeviceSetting(deviceID, TicketSettingPrefix+ticket)
+}
+
+// GetDeviceTickets fetches the ticket links of a device, oldest first.
+// Settings that look like ticket links but can't be read are skipped.
+func (c *Conch) GetDeviceTickets(deviceID string) (TicketLinks, error) {
+	settings, err := c.GetDeviceSettings(deviceID)
+	if err != nil {
+		return make(TicketLinks, 0), err
+	}
+	links, _ := TicketLinksFromSettings(deviceID, settings)
+	return links, nil
+}
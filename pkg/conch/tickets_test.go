@@ -0,0 +1,66 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestTicketLinks(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	l := conch.TicketLink{
+		DeviceID: "test",
+		Ticket:   "OPS-1234",
+		Note:     "bad dimm",
+		Linked:   time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC),
+	}
+	st.Expect(t, l.SettingKey(), "ticket.OPS-1234")
+
+	t.Run("LinkTicket", func(t *testing.T) {
+		gock.New(API.BaseURL).Post("/device/test/settings/ticket.OPS-1234").
+			Reply(204)
+
+		st.Expect(t, API.LinkTicket(l), nil)
+		st.Expect(t, gock.IsDone(), true)
+
+		st.Reject(t, API.LinkTicket(conch.TicketLink{DeviceID: "test"}), nil)
+		st.Reject(t, API.LinkTicket(conch.TicketLink{DeviceID: "test", Ticket: "a b"}), nil)
+		st.Reject(t, API.LinkTicket(conch.TicketLink{Ticket: "OPS-1"}), nil)
+	})
+
+	t.Run("UnlinkTicket", func(t *testing.T) {
+		gock.New(API.BaseURL).Delete("/device/test/settings/ticket.OPS-1234").
+			Reply(204)
+
+		st.Expect(t, API.UnlinkTicket("test", "OPS-1234"), nil)
+		st.Expect(t, gock.IsDone(), true)
+	})
+
+	t.Run("TicketLinksFromSettings", func(t *testing.T) {
+		settings := map[string]string{
+			"ticket.OPS-2":    `{"ticket":"OPS-2","linked":"2019-03-05T00:00:00Z"}`,
+			"ticket.OPS-1234": `{"device_id":"test","ticket":"OPS-1234","note":"bad dimm","linked":"2019-03-04T05:06:07Z"}`,
+			"ticket.broken":   `not json`,
+			"build.phase":     `integration`,
+		}
+
+		links, bad := conch.TicketLinksFromSettings("test", settings)
+		st.Expect(t, len(links), 2)
+		st.Expect(t, links[0].Ticket, "OPS-1234")
+		st.Expect(t, links[0].Linked.Equal(l.Linked), true)
+		st.Expect(t, links[1].Ticket, "OPS-2")
+		st.Expect(t, links[1].DeviceID, "test")
+		st.Expect(t, bad, []string{"ticket.broken"})
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http/httptrace"
+	"time"
+)
+
+// Timing breaks down where the time went in a single request. Server is the
+// time between the request being written and the first byte of the response
+// arriving, which covers the API's own work plus one network round trip.
+// Decode is the time the client spent parsing the JSON response.
+type Timing struct {
+	Status   int           `json:"status"`
+	Bytes    int           `json:"bytes"`
+	Reused   bool          `json:"reused_connection"`
+	DNS      time.Duration `json:"dns"`
+	Connect  time.Duration `json:"connect"`
+	TLS      time.Duration `json:"tls"`
+	Server   time.Duration `json:"server"`
+	Transfer time.Duration `json:"transfer"`
+	Decode   time.Duration `json:"decode"`
+	Total    time.Duration `json:"total"`
+}
+
+// TimedGet performs a GET against the API, with the library handling all
+// auth, and reports how long each phase of the request took. The response
+// body is parsed as JSON, to account for decoding time, and then thrown away.
+// Memoization is bypassed.
+func (c *Conch) TimedGet(url string) (Timing, error) {
+	var (
+		t Timing
+
+		dnsStart     time.Time
+		connectStart time.Time
+		tlsStart     time.Time
+		wrote        time.Time
+		firstByte    time.Time
+	)
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.DNS = time.Since(dnsStart)
+		},
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(string, string, error) {
+			t.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.TLS = time.Since(tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.Reused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			wrote = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			firstByte = time.Now()
+		},
+	}
+
+	req, err := c.sling().New().Get(url).Request()
+	if err != nil {
+		return t, err
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start := time.Now()
+	res, body, err := c.sendNow(req)
+	done := time.Now()
+	if err != nil {
+		return t, err
+	}
+
+	t.Status = res.StatusCode
+	t.Bytes = len(body)
+	t.Total = done.Sub(start)
+
+	// Transports that don't support tracing get everything counted as server
+	// time
+	if wrote.IsZero() || firstByte.IsZero() {
+		t.Server = t.Total
+	} else {
+		t.Server = firstByte.Sub(wrote)
+		t.Transfer = done.Sub(firstByte)
+	}
+
+	var discard interface{}
+	decodeStart := time.Now()
+	json.Unmarshal(body, &discard)
+	t.Decode = time.Since(decodeStart)
+	t.Total += t.Decode
+
+	return t, nil
+}
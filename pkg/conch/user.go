@@ -107,6 +107,41 @@ func (c *Conch) DeleteUserSetting(name string) error {
 	return c.httpDelete("/user/me/settings/" + url.PathEscape(name))
 }
 
+// GetUserSettingsByEmail returns the settings of another user, via
+// /user/email=:email/settings. Only system admins can read settings that
+// aren't their own.
+func (c *Conch) GetUserSettingsByEmail(email string) (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+	return settings, c.get("/user/email="+url.PathEscape(email)+"/settings", &settings)
+}
+
+// GetUserSettingByEmail returns a single setting of another user, via
+// /user/email=:email/settings/:key
+func (c *Conch) GetUserSettingByEmail(email string, key string) (setting interface{}, err error) {
+	return setting, c.get(
+		"/user/email="+url.PathEscape(email)+"/settings/"+url.PathEscape(key),
+		&setting,
+	)
+}
+
+// SetUserSettingByEmail sets the value of another user's setting via
+// /user/email=:email/settings/:name
+func (c *Conch) SetUserSettingByEmail(email string, name string, value interface{}) error {
+	return c.post(
+		"/user/email="+url.PathEscape(email)+"/settings/"+url.PathEscape(name),
+		value,
+		nil,
+	)
+}
+
+// DeleteUserSettingByEmail deletes another user's setting via
+// /user/email=:email/settings/:name
+func (c *Conch) DeleteUserSettingByEmail(email string, name string) error {
+	return c.httpDelete(
+		"/user/email=" + url.PathEscape(email) + "/settings/" + url.PathEscape(name),
+	)
+}
+
 // DeleteUser deletes a user and, optionally, clears their JWT credentials
 func (c *Conch) DeleteUser(emailAddress string, clearTokens bool) error {
 	url := "/user/email=" + url.PathEscape(emailAddress)
@@ -60,6 +60,41 @@ func TestUserErrors(t *testing.T) {
 		st.Expect(t, err, ErrApiUnpacked)
 	})
 
+	t.Run("GetUserSettingsByEmail", func(t *testing.T) {
+		gock.New(API.BaseURL).Get("/user/email=foo@bar.bat/settings").
+			Reply(400).JSON(ErrApi)
+
+		ret, err := API.GetUserSettingsByEmail("foo@bar.bat")
+		st.Expect(t, err, ErrApiUnpacked)
+		st.Expect(t, ret, make(map[string]interface{}))
+	})
+
+	t.Run("GetUserSettingByEmail", func(t *testing.T) {
+		gock.New(API.BaseURL).Get("/user/email=foo@bar.bat/settings/test").
+			Reply(400).JSON(ErrApi)
+
+		ret, err := API.GetUserSettingByEmail("foo@bar.bat", "test")
+		st.Expect(t, err, ErrApiUnpacked)
+		var f interface{}
+		st.Expect(t, ret, f)
+	})
+
+	t.Run("SetUserSettingByEmail", func(t *testing.T) {
+		gock.New(API.BaseURL).Post("/user/email=foo@bar.bat/settings/test").
+			Reply(400).JSON(ErrApi)
+
+		err := API.SetUserSettingByEmail("foo@bar.bat", "test", "wat")
+		st.Expect(t, err, ErrApiUnpacked)
+	})
+
+	t.Run("DeleteUserSettingByEmail", func(t *testing.T) {
+		gock.New(API.BaseURL).Delete("/user/email=foo@bar.bat/settings/test").
+			Reply(400).JSON(ErrApi)
+
+		err := API.DeleteUserSettingByEmail("foo@bar.bat", "test")
+		st.Expect(t, err, ErrApiUnpacked)
+	})
+
 	t.Run("DeleteUser", func(t *testing.T) {
 		gock.New(API.BaseURL).Delete("/user/email=foo@bar.bat").
 			Reply(400).JSON(ErrApi)
@@ -177,3 +212,8 @@ func TestUserErrors(t *testing.T) {
 	})
 
 }
+
+func TestUserTokenIsLogin(t *testing.T) {
+	st.Expect(t, conch.UserToken{Name: "login_jwt_1234"}.IsLogin(), true)
+	st.Expect(t, conch.UserToken{Name: "automation"}.IsLogin(), false)
+}
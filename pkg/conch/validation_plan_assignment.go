@@ -0,0 +1,137 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch
+
+import (
+	"fmt"
+
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+)
+
+// ValidationPlanKey is the key in a hardware product's specification that
+// holds the ID of the validation plan for devices of that product
+const ValidationPlanKey = "validation_plan"
+
+// ValidationPlanSetting is the device setting that holds the ID of the
+// validation plan for a single device, over the one of its hardware product
+const ValidationPlanSetting = "validation.plan"
+
+// Where the validation plan of a device was assigned
+const (
+	ValidationPlanFromDevice  = "device"
+	ValidationPlanFromProduct = "hardware product"
+	ValidationPlanUnassigned  = "unassigned"
+)
+
+// ValidationPlanAssignment is the validation plan that applies to a device or
+// hardware product, and where it was assigned. A zero PlanID means that none
+// was, and the API picks the plan itself.
+type ValidationPlanAssignment struct {
+	PlanID uuid.UUID `json:"validation_plan_id"`
+	Source string    `json:"source"`
+}
+
+// GetProductValidationPlan fetches the ID of the validation plan stored in a
+// hardware product's specification. A product without one gets a zero UUID.
+func (c *Conch) GetProductValidationPlan(productID fmt.Stringer) (uuid.UUID, error) {
+	h, err := c.GetHardwareProduct(productID)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	spec, err := specificationMap(h)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	raw, ok := spec[ValidationPlanKey]
+	if !ok || raw == nil {
+		return uuid.UUID{}, nil
+	}
+	s, ok := raw.(string)
+	if !ok {
+		return uuid.UUID{}, fmt.Errorf("the %s of hardware product %s is not a string", ValidationPlanKey, h.ID)
+	}
+	return uuid.FromString(s)
+}
+
+// SaveProductValidationPlan stores the ID of a validation plan in a hardware
+// product's specification, leaving the rest of the specification as it is. A
+// zero UUID removes it.
+func (c *Conch) SaveProductValidationPlan(productID fmt.Stringer, planID uuid.UUID) error {
+	h, err := c.GetHardwareProduct(productID)
+	if err != nil {
+		return err
+	}
+
+	spec, err := specificationMap(h)
+	if err != nil {
+		return err
+	}
+
+	if uuid.Equal(planID, uuid.UUID{}) {
+		delete(spec, ValidationPlanKey)
+	} else {
+		spec[ValidationPlanKey] = planID.String()
+	}
+	h.Specification = spec
+
+	return c.SaveHardwareProduct(&h)
+}
+
+// GetDeviceValidationPlan works out which validation plan applies to a
+// device: the one in its own settings, or else the one of its hardware
+// product
+func (c *Conch) GetDeviceValidationPlan(serial string) (ValidationPlanAssignment, error) {
+	a := ValidationPlanAssignment{Source: ValidationPlanUnassigned}
+
+	s, err := c.GetDeviceSetting(serial, ValidationPlanSetting)
+	if err == nil && s != "" {
+		id, err := uuid.FromString(s)
+		if err != nil {
+			return a, fmt.Errorf("the %s setting of device %s is not a UUID: %s", ValidationPlanSetting, serial, s)
+		}
+		a.PlanID = id
+		a.Source = ValidationPlanFromDevice
+		return a, nil
+	}
+	if err != nil && err != ErrDataNotFound {
+		return a, err
+	}
+
+	d, err := c.GetDevice(serial)
+	if err != nil {
+		return a, err
+	}
+	if uuid.Equal(d.HardwareProduct, uuid.UUID{}) {
+		return a, nil
+	}
+
+	id, err := c.GetProductValidationPlan(d.HardwareProduct)
+	if err != nil {
+		return a, err
+	}
+	if !uuid.Equal(id, uuid.UUID{}) {
+		a.PlanID = id
+		a.Source = ValidationPlanFromProduct
+	}
+	return a, nil
+}
+
+// SetDeviceValidationPlan assigns a validation plan to a single device. A
+// zero UUID removes the assignment, so that the device goes by its hardware
+// product's plan again.
+func (c *Conch) SetDeviceValidationPlan(serial string, planID uuid.UUID) error {
+	if uuid.Equal(planID, uuid.UUID{}) {
+		err := c.DeleteDeviceSetting(serial, ValidationPlanSetting)
+		if err == ErrDataNotFound {
+			return nil
+		}
+		return err
+	}
+	return c.SetDeviceSetting(serial, ValidationPlanSetting, planID.String())
+}
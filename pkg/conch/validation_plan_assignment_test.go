@@ -0,0 +1,88 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package conch_test
+
+import (
+	"testing"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestValidationPlanAssignment(t *testing.T) {
+	gock.Flush()
+	defer gock.Flush()
+
+	t.Run("GetDeviceValidationPlan from the device", func(t *testing.T) {
+		plan := uuid.NewV4()
+
+		gock.New(API.BaseURL).Get("/device/test/settings/validation.plan").
+			Reply(200).JSON(map[string]string{"validation.plan": plan.String()})
+
+		a, err := API.GetDeviceValidationPlan("test")
+		st.Expect(t, err, nil)
+		st.Expect(t, a, conch.ValidationPlanAssignment{
+			PlanID: plan,
+			Source: conch.ValidationPlanFromDevice,
+		})
+	})
+
+	t.Run("GetDeviceValidationPlan from the hardware product", func(t *testing.T) {
+		plan := uuid.NewV4()
+		product := uuid.NewV4()
+
+		gock.New(API.BaseURL).Get("/device/test/settings/validation.plan").
+			Reply(404).JSON(map[string]string{"error": "Not Found"})
+		gock.New(API.BaseURL).Get("/device/test").
+			Reply(200).JSON(map[string]string{"id": "test", "hardware_product": product.String()})
+		gock.New(API.BaseURL).Get("/hardware_product/" + product.String()).
+			Reply(200).JSON(map[string]interface{}{
+			"id":            product.String(),
+			"specification": `{"validation_plan":"` + plan.String() + `"}`,
+		})
+
+		a, err := API.GetDeviceValidationPlan("test")
+		st.Expect(t, err, nil)
+		st.Expect(t, a, conch.ValidationPlanAssignment{
+			PlanID: plan,
+			Source: conch.ValidationPlanFromProduct,
+		})
+	})
+
+	t.Run("SaveProductValidationPlan", func(t *testing.T) {
+		id := uuid.NewV4()
+		plan := uuid.NewV4()
+
+		gock.New(API.BaseURL).Get("/hardware_product/" + id.String()).
+			Reply(200).JSON(map[string]interface{}{
+			"id":                 id.String(),
+			"name":               "test",
+			"alias":              "test",
+			"hardware_vendor_id": uuid.NewV4().String(),
+			"specification":      `{"cpu":"fast"}`,
+		})
+
+		// The rest of the specification has to survive the update
+		gock.New(API.BaseURL).Post("/hardware_product/" + id.String()).
+			BodyString(`cpu.*validation_plan.*` + plan.String()).
+			Reply(200).JSON(map[string]interface{}{"id": id.String()})
+
+		err := API.SaveProductValidationPlan(id, plan)
+		st.Expect(t, err, nil)
+		st.Expect(t, gock.IsDone(), true)
+	})
+
+	t.Run("SetDeviceValidationPlan clears", func(t *testing.T) {
+		gock.New(API.BaseURL).Delete("/device/test/settings/validation.plan").
+			Reply(404).JSON(map[string]string{"error": "Not Found"})
+
+		err := API.SetDeviceValidationPlan("test", uuid.UUID{})
+		st.Expect(t, err, nil)
+	})
+}
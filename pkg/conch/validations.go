@@ -53,6 +53,42 @@ func (c *Conch) GetValidationPlanValidations(
 	)
 }
 
+// CreateValidationPlan creates a validation plan via /validation_plan. The
+// plan starts out with no validations.
+func (c *Conch) CreateValidationPlan(vp *ValidationPlan) error {
+	if vp.Name == "" {
+		return ErrBadInput
+	}
+
+	j := struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}{
+		vp.Name,
+		vp.Description,
+	}
+
+	return c.post("/validation_plan", j, vp)
+}
+
+// AddValidationToPlan adds a validation to a validation plan via
+// /validation_plan/:uuid/validation
+func (c *Conch) AddValidationToPlan(
+	validationPlanUUID fmt.Stringer,
+	validationUUID fmt.Stringer,
+) error {
+
+	j := struct {
+		ID string `json:"id"`
+	}{validationUUID.String()}
+
+	return c.post(
+		"/validation_plan/"+url.PathEscape(validationPlanUUID.String())+"/validation",
+		j,
+		nil,
+	)
+}
+
 // RunDeviceValidation runs a validation against given a device and returns the results
 func (c *Conch) RunDeviceValidation(
 	deviceSerial string,
@@ -100,6 +136,28 @@ func (c *Conch) DeviceValidationStates(
 	return states, c.get("/device/"+url.PathEscape(deviceSerial)+"/validation_state", &states)
 }
 
+// DeviceValidationHistory returns every stored validation state for a
+// device, not just the latest one for each validation plan. A limit greater
+// than zero asks for only that many of the most recent states. Servers that
+// don't keep history return the latest states only.
+func (c *Conch) DeviceValidationHistory(
+	deviceSerial string,
+	limit int,
+) ([]ValidationState, error) {
+
+	opts := struct {
+		History int `url:"history"`
+		Limit   int `url:"limit,omitempty"`
+	}{1, limit}
+
+	states := make([]ValidationState, 0)
+	return states, c.getWithQuery(
+		"/device/"+url.PathEscape(deviceSerial)+"/validation_state",
+		opts,
+		&states,
+	)
+}
+
 // WorkspaceValidationStates returns the stored validation states for all devices in a workspace
 func (c *Conch) WorkspaceValidationStates(
 	workspaceUUID fmt.Stringer,
@@ -68,4 +68,34 @@ func TestValidationErrors(t *testing.T) {
 		st.Expect(t, ret, []conch.ValidationResult{})
 	})
 
+	t.Run("CreateValidationPlan", func(t *testing.T) {
+		gock.New(API.BaseURL).Post("/validation_plan").
+			Reply(400).JSON(ErrApi)
+		err := API.CreateValidationPlan(&conch.ValidationPlan{Name: "test"})
+		st.Expect(t, err, ErrApiUnpacked)
+
+		st.Expect(t, API.CreateValidationPlan(&conch.ValidationPlan{}), conch.ErrBadInput)
+	})
+
+	t.Run("AddValidationToPlan", func(t *testing.T) {
+		id := uuid.NewV4()
+		url := "/validation_plan/" + id.String() + "/validation"
+		gock.New(API.BaseURL).Post(url).Reply(400).JSON(ErrApi)
+		err := API.AddValidationToPlan(id, uuid.NewV4())
+		st.Expect(t, err, ErrApiUnpacked)
+	})
+
+	t.Run("DeviceValidationHistory", func(t *testing.T) {
+		dID := "test"
+		url := "/device/" + dID + "/validation_state"
+
+		gock.New(API.BaseURL).Get(url).
+			MatchParam("history", "^1$").
+			MatchParam("limit", "^5$").
+			Reply(400).JSON(ErrApi)
+		ret, err := API.DeviceValidationHistory(dID, 5)
+		st.Expect(t, err, ErrApiUnpacked)
+		st.Expect(t, ret, []conch.ValidationState{})
+	})
+
 }
@@ -63,16 +63,58 @@ func (c *Conch) GetWorkspaceDevices(
 	health string,
 	validated string,
 ) (Devices, error) {
+	return c.getWorkspaceDevices(
+		workspaceUUID,
+		idsOnly,
+		nil,
+		graduated,
+		health,
+		validated,
+	)
+}
+
+// GetWorkspaceDevicesFields behaves like GetWorkspaceDevices but asks the API
+// for a sparse fieldset, only the named fields of each device, eg "id" and
+// "health". Fields that aren't asked for come back as zero values. Passing no
+// fields asks for every field, and servers that don't support sparse
+// fieldsets send every field regardless.
+func (c *Conch) GetWorkspaceDevicesFields(
+	workspaceUUID fmt.Stringer,
+	fields []string,
+	graduated string,
+	health string,
+	validated string,
+) (Devices, error) {
+	return c.getWorkspaceDevices(
+		workspaceUUID,
+		false,
+		fields,
+		graduated,
+		health,
+		validated,
+	)
+}
+
+func (c *Conch) getWorkspaceDevices(
+	workspaceUUID fmt.Stringer,
+	idsOnly bool,
+	fields []string,
+	graduated string,
+	health string,
+	validated string,
+) (Devices, error) {
 
 	devices := make([]Device, 0)
 
 	opts := struct {
-		IDsOnly   bool   `url:"ids_only,omitempty"`
-		Graduated string `url:"graduated,omitempty"`
-		Health    string `url:"health,omitempty"`
-		Validated string `url:"validated,omitempty"`
+		IDsOnly   bool     `url:"ids_only,omitempty"`
+		Fields    []string `url:"fields,comma,omitempty"`
+		Graduated string   `url:"graduated,omitempty"`
+		Health    string   `url:"health,omitempty"`
+		Validated string   `url:"validated,omitempty"`
 	}{
 		idsOnly,
+		fields,
 		graduated,
 		health,
 		validated,
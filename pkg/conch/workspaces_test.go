@@ -143,6 +143,19 @@ func TestWorkspaceErrors(t *testing.T) {
 
 	})
 
+	t.Run("GetWorkspaceDevicesFields", func(t *testing.T) {
+		id := uuid.NewV4()
+		d := conch.Device{ID: "test", Health: "pass"}
+
+		gock.New(API.BaseURL).Get("/workspace/"+id.String()+"/device").
+			MatchParam("fields", "^id,health$").
+			Reply(200).JSON(conch.Devices{d})
+
+		ret, err := API.GetWorkspaceDevicesFields(id, []string{"id", "health"}, "", "", "")
+		st.Expect(t, err, nil)
+		st.Expect(t, ret, conch.Devices{d})
+	})
+
 	t.Run("GetWorkspaceRacks", func(t *testing.T) {
 		id := uuid.NewV4()
 
@@ -14,6 +14,8 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -41,6 +43,50 @@ var ErrConfigNoPath = errors.New("no path found in config data")
 type ConchConfig struct {
 	Path     string                   `json:"path"`
 	Profiles map[string]*ConchProfile `json:"profiles"`
+
+	OutputPlugins map[string]*OutputPlugin `json:"output_plugins,omitempty"`
+
+	// StatsFile is where local usage statistics are kept. They are only kept
+	// if this is set, by 'conch stats enable'.
+	StatsFile string `json:"stats_file,omitempty"`
+
+	// UpdateChannel is the kind of release that 'conch update' looks for:
+	// "stable", the default, or "prerelease"
+	UpdateChannel string `json:"update_channel,omitempty"`
+
+	// LayoutTemplates are rack layouts saved under a name by 'conch rack
+	// layout template save'
+	LayoutTemplates map[string]*LayoutTemplate `json:"layout_templates,omitempty"`
+
+	// LayoutTemplateDir is a directory of shared layout templates, one
+	// NAME.json file per template
+	LayoutTemplateDir string `json:"layout_template_dir,omitempty"`
+}
+
+// LayoutTemplate is a standard rack design that can be applied to racks by
+// name. Slots name their hardware products, rather than holding their IDs, so
+// that a template works against any API that has the same products.
+type LayoutTemplate struct {
+	Description string               `json:"description,omitempty"`
+	Created     time.Time            `json:"created"`
+	Slots       []LayoutTemplateSlot `json:"slots"`
+}
+
+// LayoutTemplateSlot is a single slot of a LayoutTemplate, in the same form as
+// the output of 'conch rack layout export'
+type LayoutTemplateSlot struct {
+	RUStart      int        `json:"ru_start"`
+	ProductID    *uuid.UUID `json:"product_id,omitempty"`
+	ProductName  string     `json:"product_name,omitempty"`
+	ProductAlias string     `json:"product_alias,omitempty"`
+}
+
+// OutputPlugin is an external program that formats the shell's output. It is
+// handed the JSON result of a command, along with some metadata, on STDIN and
+// writes whatever it likes to STDOUT.
+type OutputPlugin struct {
+	Command     []string `json:"command"`
+	Description string   `json:"description,omitempty"`
 }
 
 // We're going to obfuscate the token itself. I'm aware this is krypto and not
@@ -85,6 +131,65 @@ type ConchProfile struct {
 	JWT           conch.ConchJWT `json:"jwt"`               // TODO(sungo): DEPRECATED
 	Expires       time.Time      `json:"expires,omitempty"` // TODO(sungo): DEPRECATED
 	Token         Token          `json:"token"`
+	NotifyWebhook string         `json:"notify_webhook,omitempty"`
+	JournalDir    string         `json:"journal_dir,omitempty"`
+	JournalGit    bool           `json:"journal_git,omitempty"`
+	APIVersion    string         `json:"api_version,omitempty"`
+	MaxRequests   int            `json:"max_requests,omitempty"`
+	CacheTTL      string         `json:"cache_ttl,omitempty"`
+
+	Reports  map[string]*SavedReport `json:"reports,omitempty"`
+	Features *FeatureCache           `json:"features,omitempty"`
+	Roles    *RoleCache              `json:"roles,omitempty"`
+
+	// DeviceAliases maps workspace IDs to the device aliases set in them,
+	// which map to device serials
+	DeviceAliases map[string]map[string]string `json:"device_aliases,omitempty"`
+
+	// Runbooks maps validation names to the URL of the steps for fixing
+	// their failures. "*" is used for validations that aren't listed.
+	Runbooks map[string]string `json:"runbooks,omitempty"`
+
+	// RoomLimits maps room IDs to what a single rack in the room may weigh
+	// and draw
+	RoomLimits map[string]*RoomLimits `json:"room_limits,omitempty"`
+
+	// CustomFields maps workspace IDs to the custom fields defined for the
+	// workspace's devices
+	CustomFields map[string][]conch.CustomField `json:"custom_fields,omitempty"`
+}
+
+// RoomLimits is the most a single rack in a datacenter room may weigh, in
+// kilograms, and draw, in watts. Zero is no limit.
+type RoomLimits struct {
+	MaxRackWeight float64 `json:"max_rack_weight_kg,omitempty"`
+	MaxRackPower  float64 `json:"max_rack_power_watts,omitempty"`
+}
+
+// RoleCache remembers what the profile's user is allowed to do, so that
+// commands they can't run can say so before trying. Workspaces maps workspace
+// IDs to the user's role in them.
+type RoleCache struct {
+	Fetched    time.Time         `json:"fetched"`
+	Email      string            `json:"email"`
+	IsAdmin    bool              `json:"is_admin"`
+	Workspaces map[string]string `json:"workspaces"`
+}
+
+// FeatureCache remembers which optional API features the profile's server
+// has. It is only good for the API version it was gathered from.
+type FeatureCache struct {
+	APIVersion string          `json:"api_version"`
+	Features   map[string]bool `json:"features"`
+}
+
+// SavedReport is a conch command line saved under a name so that it can be
+// rerun with 'conch report run'. Args are everything after 'conch', global
+// options included.
+type SavedReport struct {
+	Args        []string  `json:"args"`
+	Description string    `json:"description,omitempty"`
+	Created     time.Time `json:"created"`
 }
 
 // New provides an initialized struct with default values geared towards a
@@ -92,7 +197,7 @@ type ConchProfile struct {
 // "http://localhost:5001".
 func New() (c *ConchConfig) {
 	c = &ConchConfig{
-		Path:     "~/.conch.json",
+		Path:     DefaultConfigPath(),
 		Profiles: make(map[string]*ConchProfile),
 	}
 
@@ -122,7 +227,7 @@ func NewFromJSON(j string) (c *ConchConfig, err error) {
 	}
 
 	ct := &conchConfigTransition{
-		Path:     "~/.conch.json",
+		Path:     DefaultConfigPath(),
 		Profiles: make(map[string]*conchProfileTransition),
 	}
 
@@ -225,7 +330,12 @@ func (c *ConchConfig) SerializeToFile(path string) (err error) {
 		return err
 	}
 
-	err = ioutil.WriteFile(path, j, 0644)
+	// The config holds API tokens, so only the user gets to read it. On
+	// Windows, the default path is in a directory that may not exist yet.
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, j, 0600)
 	return err
 }
 
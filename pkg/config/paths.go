@@ -0,0 +1,56 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// appDir is the directory, under APPDATA or LOCALAPPDATA, that the shell
+// keeps its files in on Windows
+const appDir = "conch"
+
+// DefaultConfigPath is where the config lives unless --config says
+// otherwise. Elsewhere that is ~/.conch.json. On Windows it is
+// %APPDATA%\conch\config.json, so that it roams with the user, unless a
+// ~/.conch.json from an older shell is already there.
+func DefaultConfigPath() string {
+	if runtime.GOOS != "windows" {
+		return "~/.conch.json"
+	}
+
+	if home, err := homedir.Dir(); err == nil {
+		legacy := filepath.Join(home, ".conch.json")
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy
+		}
+	}
+
+	if dir := os.Getenv("APPDATA"); dir != "" {
+		return filepath.Join(dir, appDir, "config.json")
+	}
+	return filepath.Join("~", ".conch.json")
+}
+
+// DataPath is where the shell keeps a file of its own that isn't part of
+// the config, like caches and indexes. Elsewhere that is ~/.conch-NAME. On
+// Windows it is %LOCALAPPDATA%\conch\NAME, since none of these files are
+// worth roaming.
+func DataPath(name string) string {
+	if runtime.GOOS != "windows" {
+		return "~/.conch-" + name
+	}
+
+	if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+		return filepath.Join(dir, appDir, name)
+	}
+	return filepath.Join("~", ".conch-"+name)
+}
@@ -0,0 +1,42 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+)
+
+// DeviceAliases returns the device aliases set in a workspace of the active
+// profile, mapped to device serials. The map may be nil.
+func DeviceAliases(workspace uuid.UUID) map[string]string {
+	if ActiveProfile == nil || ActiveProfile.DeviceAliases == nil {
+		return nil
+	}
+	return ActiveProfile.DeviceAliases[workspace.String()]
+}
+
+// SetDeviceAlias points an alias in a workspace of the active profile at a
+// device serial. An empty serial removes the alias. The config is not saved.
+func SetDeviceAlias(workspace uuid.UUID, alias string, serial string) {
+	if ActiveProfile.DeviceAliases == nil {
+		ActiveProfile.DeviceAliases = make(map[string]map[string]string)
+	}
+	key := workspace.String()
+
+	if serial == "" {
+		delete(ActiveProfile.DeviceAliases[key], alias)
+		if len(ActiveProfile.DeviceAliases[key]) == 0 {
+			delete(ActiveProfile.DeviceAliases, key)
+		}
+		return
+	}
+
+	if ActiveProfile.DeviceAliases[key] == nil {
+		ActiveProfile.DeviceAliases[key] = make(map[string]string)
+	}
+	ActiveProfile.DeviceAliases[key][alias] = serial
+}
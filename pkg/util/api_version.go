@@ -0,0 +1,114 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/config"
+)
+
+// SkipVersionCheck is set by the global --skip-version-check option. The API
+// version is not checked at all, at the user's own risk.
+var SkipVersionCheck bool
+
+var warnedSkipVersionCheck bool
+
+// WarnSkipVersionCheck prints, once, a warning that the API version is not
+// being checked
+func WarnSkipVersionCheck() {
+	if warnedSkipVersionCheck {
+		return
+	}
+	warnedSkipVersionCheck = true
+
+	fmt.Fprintln(os.Stderr, "**********************************************************************")
+	fmt.Fprintln(os.Stderr, "** WARNING: API version checking is disabled by --skip-version-check **")
+	fmt.Fprintln(os.Stderr, "** This shell may misread responses from, or send bad data to, an   **")
+	fmt.Fprintln(os.Stderr, "** API server it was not built for. Proceed at your own risk.        **")
+	fmt.Fprintln(os.Stderr, "**********************************************************************")
+}
+
+// ParseAPIVersionRange parses a profile's API version constraint, such as
+// '>=2.20.0 <3.0.0'. See https://github.com/blang/semver#ranges for the
+// syntax.
+func ParseAPIVersionRange(constraint string) (semver.Range, error) {
+	r, err := semver.ParseRange(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("bad API version constraint '%s': %s", constraint, err)
+	}
+	return r, nil
+}
+
+// APIVersionConstraint describes the API versions the shell will work with.
+// This is the active profile's constraint if it has one, or the range the
+// shell was built for.
+func APIVersionConstraint() string {
+	if ActiveProfile != nil && ActiveProfile.APIVersion != "" {
+		return ActiveProfile.APIVersion
+	}
+	return fmt.Sprintf(">=%s <%s", conch.MinimumAPIVersion, conch.BreakingAPIVersion)
+}
+
+// CheckAPIVersion returns an error if the API server's version is outside of
+// APIVersionConstraint
+func CheckAPIVersion(version string) error {
+	return checkAPIVersion(API, ActiveProfile, version)
+}
+
+// checkAPIVersion checks the version of the API server behind api against
+// what profile requires
+func checkAPIVersion(api *conch.Conch, profile *config.ConchProfile, version string) error {
+	sem, err := semver.Parse(strings.Split(strings.TrimLeft(version, "v"), "-")[0])
+	if err != nil {
+		return fmt.Errorf("cannot continue. the API server '%s' reports a version of '%s', which isn't understood: %s", api.BaseURL, version, err)
+	}
+
+	if profile != nil && profile.APIVersion != "" {
+		r, err := ParseAPIVersionRange(profile.APIVersion)
+		if err != nil {
+			return err
+		}
+		if !r(sem) {
+			return fmt.Errorf(
+				"cannot continue. the API server '%s' is version '%s' and profile '%s' requires %s. See 'conch profile set api-version'",
+				api.BaseURL,
+				sem,
+				profile.Name,
+				profile.APIVersion,
+			)
+		}
+		return nil
+	}
+
+	minSem := CleanVersion(conch.MinimumAPIVersion)
+	maxSem := CleanVersion(conch.BreakingAPIVersion)
+
+	if sem.Major != minSem.Major {
+		return fmt.Errorf(
+			"cannot continue. the major version of API server '%s' is '%d' and we require '%d'",
+			api.BaseURL,
+			sem.Major,
+			minSem.Major,
+		)
+	}
+
+	if sem.LT(minSem) || sem.GTE(maxSem) {
+		return fmt.Errorf(
+			"cannot continue. the API server version '%s' is '%s' and we require >= %s and < %s",
+			api.BaseURL,
+			sem,
+			minSem,
+			maxSem,
+		)
+	}
+	return nil
+}
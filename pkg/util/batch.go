@@ -0,0 +1,151 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"os"
+
+	cli "github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+)
+
+// BatchMode is set while 'conch batch' runs commands inside this process.
+// Commands end by panicking with a BatchExit rather than exiting, and API
+// clients are kept between commands so that each server is only asked for
+// its version, and each login verified, once.
+var BatchMode bool
+
+// Args is the command line of the command being run. It is os.Args, save for
+// the commands 'conch batch' runs, which each have their own.
+var Args = os.Args
+
+// BatchExit is what Bail and Exit panic with in batch mode
+type BatchExit struct {
+	Code    int
+	Message string
+}
+
+// batchClient is an API client built by an earlier command in the batch
+type batchClient struct {
+	api      *conch.Conch
+	version  string
+	loggedIn bool
+}
+
+var batchClients = make(map[string]*batchClient)
+
+// batchClientKey tells apart the clients a batch might need. Commands can
+// choose their own profile, so this is more than just the URL.
+func batchClientKey() string {
+	name := ""
+	if ActiveProfile != nil {
+		name = ActiveProfile.Name
+	}
+	return fmt.Sprintf("%s\n%s\n%s", API.BaseURL, API.Token, name)
+}
+
+// reuseBatchClient swaps API for the one an earlier command built, if there
+// is one
+func reuseBatchClient() bool {
+	if !BatchMode {
+		return false
+	}
+	c, ok := batchClients[batchClientKey()]
+	if !ok {
+		return false
+	}
+	API = c.api
+	APIServerVersion = c.version
+	return true
+}
+
+// rememberBatchClient keeps API, whose server version has been checked, for
+// later commands in the batch
+func rememberBatchClient() {
+	if BatchMode {
+		batchClients[batchClientKey()] = &batchClient{api: API, version: APIServerVersion}
+	}
+}
+
+// batchLoggedIn reports whether API's login was verified by an earlier
+// command in the batch
+func batchLoggedIn() bool {
+	if !BatchMode {
+		return false
+	}
+	c, ok := batchClients[batchClientKey()]
+	return ok && c.api == API && c.loggedIn
+}
+
+func markBatchLoggedIn() {
+	if !BatchMode {
+		return
+	}
+	if c, ok := batchClients[batchClientKey()]; ok && c.api == API {
+		c.loggedIn = true
+	}
+}
+
+// Exit ends the command with the given exit code, giving its After hooks a
+// chance to run. In batch mode, only the command ends.
+func Exit(code int) {
+	if code != 0 {
+		discardResult()
+	}
+	if BatchMode {
+		panic(BatchExit{Code: code})
+	}
+	cli.Exit(code)
+}
+
+// RunBatchCommand runs args, which are os.Args for a single command, with
+// app. The app must be freshly built, since mow.cli apps can only be run
+// once. The exit code is returned, along with the error message if the
+// command failed.
+func RunBatchCommand(app *cli.Cli, args []string) (code int, message string) {
+	// The global options of one command mustn't leak into the next
+	JSON = false
+	IgnoreConfig = false
+	Token = ""
+	BaseURL = ""
+	ActiveProfile = nil
+	OutputFilter = nil
+	OutputPlugin = nil
+	OutputPluginName = ""
+	journal = nil
+	activeNotifier = nil
+	MaxRequests = 0
+	resetRequestBudget()
+
+	// The batch's own After hooks still see the batch's command line, and
+	// record the batch's own statistics
+	batchArgs, batchCommand, batchStats := Args, runningCommand, activeStats
+	defer func() {
+		Args, runningCommand, activeStats = batchArgs, batchCommand, batchStats
+	}()
+	Args = args
+	runningCommand = ""
+	activeStats = nil
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if e, ok := r.(BatchExit); ok {
+			code, message = e.Code, e.Message
+			return
+		}
+		code, message = 1, fmt.Sprintf("%v", r)
+	}()
+
+	if err := app.Run(args); err != nil {
+		return 2, err.Error()
+	}
+	return 0, ""
+}
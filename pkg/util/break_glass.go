@@ -0,0 +1,173 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/config"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// ErrBreakGlassMismatch is returned when what the user typed to confirm a
+// break glass operation isn't the name of its target
+var ErrBreakGlassMismatch = errors.New("the name typed did not match. No changes were made")
+
+// BreakGlassLogPath is where break glass operations are recorded when the
+// active profile has no change journal. Each line is a JournalEntry with no
+// mutations.
+var BreakGlassLogPath = config.DataPath("break-glass.jsonl")
+
+// BreakGlassOpts holds the option of a command that does something that can't
+// be taken back, and which only goes ahead once the target has been named
+type BreakGlassOpts struct {
+	Confirm *string
+}
+
+// NewBreakGlassOpts adds --confirm to a command, for scripts to name the
+// target of a break glass operation instead of typing it
+func NewBreakGlassOpts(cmd *cli.Cmd) BreakGlassOpts {
+	return BreakGlassOpts{
+		Confirm: cmd.StringOpt("confirm", "", "The name of the target, to go ahead without being asked to type it"),
+	}
+}
+
+// BreakGlass stands between the user and an operation that can't be undone,
+// like deleting a user. The user has to type the name of the target, or give
+// it with --confirm, before it goes ahead. Anything else stops the shell with
+// nothing changed. The operation is recorded in the change journal along with
+// the requests it makes. Without a journal, it is recorded in
+// BreakGlassLogPath before it goes ahead, and doesn't go ahead if that fails.
+//
+// operation describes what is about to happen, eg "delete user foo@bar.com"
+func BreakGlass(opts BreakGlassOpts, operation string, target string) {
+	confirm := *opts.Confirm
+	if confirm == "" {
+		if !IsTerminal(os.Stdin) {
+			Bail(fmt.Errorf(
+				"'%s' can't be undone, and there is nobody to ask. Pass --confirm '%s' to go ahead",
+				operation,
+				target,
+			))
+		}
+
+		fmt.Fprintf(os.Stderr, "This will %s. It can't be undone.\n", operation)
+		fmt.Fprintf(os.Stderr, "Type '%s' to confirm: ", target)
+
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			Bail(err)
+		}
+		confirm = strings.TrimSpace(line)
+	}
+
+	if confirm != target {
+		Bail(ErrBreakGlassMismatch)
+	}
+
+	journalLock.Lock()
+	recorded := journal != nil
+	if recorded {
+		journal.BreakGlass = operation
+	}
+	journalLock.Unlock()
+
+	if recorded || Replaying() {
+		return
+	}
+
+	if err := logBreakGlass(operation); err != nil {
+		Bail(fmt.Errorf(
+			"'%s' could not be recorded in %s, so it was not done: %s",
+			operation,
+			BreakGlassLogPath,
+			err,
+		))
+	}
+}
+
+// logBreakGlass appends a record of the operation to BreakGlassLogPath
+func logBreakGlass(operation string) error {
+	path, err := homedir.Expand(BreakGlassLogPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	entry := JournalEntry{
+		ID:         now.Format(time.RFC3339Nano),
+		Time:       now,
+		Command:    redactArgs(Args),
+		Mutations:  make([]conch.Mutation, 0),
+		BreakGlass: operation,
+	}
+	if ActiveProfile != nil {
+		entry.Profile = ActiveProfile.Name
+	}
+	if API != nil {
+		entry.API = API.BaseURL
+	}
+
+	j, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(j, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// BreakGlassRackDelete asks for the rack's name before it is deleted, if any
+// devices are assigned to it. Empty racks go without asking.
+func BreakGlassRackDelete(opts BreakGlassOpts, rackID uuid.UUID) {
+	assignments, err := API.GetRackAssignments(rackID)
+	if err != nil {
+		Bail(err)
+	}
+
+	assigned := 0
+	for _, a := range assignments {
+		if a.DeviceID != "" {
+			assigned++
+		}
+	}
+	if assigned == 0 {
+		return
+	}
+
+	rack, err := API.GetRack(rackID)
+	if err != nil {
+		Bail(err)
+	}
+
+	BreakGlass(
+		opts,
+		fmt.Sprintf("delete rack %s, which has %d devices assigned to it", rack.Name, assigned),
+		rack.Name,
+	)
+}
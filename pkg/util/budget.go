@@ -0,0 +1,106 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// MaxRequests is the most API requests a single command may make, from
+// --max-requests or the profile's default. Zero is no limit.
+var MaxRequests int
+
+// requestBudget counts the requests the current command has made. allowed
+// starts out as MaxRequests and grows if the user agrees to go over it.
+var requestBudget struct {
+	sync.Mutex
+	made    int
+	allowed int
+	started bool
+}
+
+// StartRequestBudget hooks the API up to the request budget, if there is
+// one. Requests past the budget fail rather than being sent.
+func StartRequestBudget() {
+	if MaxRequests <= 0 {
+		return
+	}
+
+	requestBudget.Lock()
+	if !requestBudget.started {
+		requestBudget.started = true
+		requestBudget.made = 0
+		requestBudget.allowed = MaxRequests
+	}
+	requestBudget.Unlock()
+
+	API.BeforeRequest = func(method string, path string) error {
+		requestBudget.Lock()
+		defer requestBudget.Unlock()
+
+		if requestBudget.made >= requestBudget.allowed {
+			return fmt.Errorf(
+				"this command has used its budget of %d API requests, and stopped before %s %s. Use --max-requests to allow more",
+				requestBudget.allowed,
+				method,
+				path,
+			)
+		}
+		requestBudget.made++
+		return nil
+	}
+}
+
+// resetRequestBudget gets the budget ready for the next command in a batch
+func resetRequestBudget() {
+	requestBudget.Lock()
+	defer requestBudget.Unlock()
+	requestBudget.started = false
+	requestBudget.made = 0
+	requestBudget.allowed = 0
+}
+
+// CheckRequestBudget is called before work whose cost is known up front,
+// with an estimate of the requests it will make and what they are for. If
+// that would go over the budget, the user is asked whether to go ahead, and
+// the budget grows to fit if they do. Without a terminal to ask at, or with
+// --json, the command stops instead.
+func CheckRequestBudget(estimate int, what string) {
+	if MaxRequests <= 0 {
+		return
+	}
+
+	requestBudget.Lock()
+	left := requestBudget.allowed - requestBudget.made
+	requestBudget.Unlock()
+
+	if estimate <= left {
+		return
+	}
+
+	msg := fmt.Sprintf(
+		"%s needs about %d API requests, over this command's budget of %d (%d left)",
+		what,
+		estimate,
+		MaxRequests,
+		left,
+	)
+
+	if JSON || !IsTerminal(os.Stdin) {
+		Bail(fmt.Errorf("%s. Use --max-requests to allow more", msg))
+	}
+
+	if !Confirm(msg + ". Go ahead anyway?") {
+		Bail(fmt.Errorf("%s. Stopped before starting", msg))
+	}
+
+	requestBudget.Lock()
+	requestBudget.allowed = requestBudget.made + estimate + MaxRequests
+	requestBudget.Unlock()
+}
@@ -0,0 +1,133 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+)
+
+// WorkspaceCustomFields lists the custom fields the active profile defines
+// for a workspace's devices, by name
+func WorkspaceCustomFields(workspaceID uuid.UUID) []conch.CustomField {
+	fields := make([]conch.CustomField, 0)
+	if ActiveProfile == nil || ActiveProfile.CustomFields == nil {
+		return fields
+	}
+	fields = append(fields, ActiveProfile.CustomFields[workspaceID.String()]...)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
+
+// WorkspaceCustomField finds a single custom field of a workspace
+func WorkspaceCustomField(workspaceID uuid.UUID, name string) (conch.CustomField, bool) {
+	for _, f := range WorkspaceCustomFields(workspaceID) {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return conch.CustomField{}, false
+}
+
+// SetWorkspaceCustomField defines a custom field for a workspace's devices,
+// replacing any field of the same name. The config is not saved.
+func SetWorkspaceCustomField(workspaceID uuid.UUID, f conch.CustomField) {
+	RemoveWorkspaceCustomField(workspaceID, f.Name)
+	if ActiveProfile.CustomFields == nil {
+		ActiveProfile.CustomFields = make(map[string][]conch.CustomField)
+	}
+	id := workspaceID.String()
+	ActiveProfile.CustomFields[id] = append(ActiveProfile.CustomFields[id], f)
+}
+
+// RemoveWorkspaceCustomField removes the definition of a custom field,
+// returning false if there was none. The values already set on devices are
+// left alone. The config is not saved.
+func RemoveWorkspaceCustomField(workspaceID uuid.UUID, name string) bool {
+	id := workspaceID.String()
+	fields := ActiveProfile.CustomFields[id]
+	for i, f := range fields {
+		if f.Name != name {
+			continue
+		}
+		fields = append(fields[:i], fields[i+1:]...)
+		if len(fields) == 0 {
+			delete(ActiveProfile.CustomFields, id)
+		} else {
+			ActiveProfile.CustomFields[id] = fields
+		}
+		return true
+	}
+	return false
+}
+
+// FieldColumns holds the --field option of a device listing, which adds a
+// column for each custom field named
+type FieldColumns struct {
+	list *[]string
+}
+
+// FieldColumnFlags adds --field to a device listing
+func FieldColumnFlags(cmd *cli.Cmd) *FieldColumns {
+	return &FieldColumns{
+		list: cmd.StringsOpt(
+			"field",
+			nil,
+			"Add a column with the value of this custom field. Takes a comma separated list or can be given more than once",
+		),
+	}
+}
+
+// Names are the custom fields asked for, in order
+func (c *FieldColumns) Names() []string {
+	names := make([]string, 0)
+	if c == nil {
+		return names
+	}
+	for _, l := range *c.list {
+		for _, n := range strings.Split(l, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				names = append(names, n)
+			}
+		}
+	}
+	return names
+}
+
+// Set returns true if any custom fields were asked for
+func (c *FieldColumns) Set() bool {
+	return len(c.Names()) > 0
+}
+
+// DeviceFieldValues fetches the custom fields of each device, keyed by
+// device ID. The API can't send settings along with a list of devices, so
+// they are asked for one device at a time.
+func DeviceFieldValues(devices []conch.Device) (map[string]map[string]string, error) {
+	values := make(map[string]map[string]string)
+
+	CheckRequestBudget(len(devices), fmt.Sprintf("Fetching the custom fields of %d devices", len(devices)))
+
+	var mu sync.Mutex
+	err := EachDevice(devices, func(i int, d conch.Device) error {
+		settings, err := API.GetDeviceSettings(d.ID)
+		if err != nil {
+			return fmt.Errorf("device %s: %s", d.ID, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		values[d.ID] = conch.DeviceCustomFields(settings)
+		return nil
+	})
+	return values, err
+}
@@ -0,0 +1,113 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// FieldChange is a single field that differs between two versions of an
+// object. Nested fields are named with dots, eg "hardware_product_profile.cpu_num"
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// FieldDiff compares the JSON form of two objects and returns the fields that
+// differ, sorted by name, descending into nested objects. The id, created,
+// and updated fields are maintained by the API and are ignored.
+func FieldDiff(before interface{}, after interface{}) []FieldChange {
+	toMap := func(v interface{}) map[string]interface{} {
+		m := make(map[string]interface{})
+		j, _ := json.Marshal(v)
+		_ = json.Unmarshal(j, &m)
+		return m
+	}
+
+	var walk func(prefix string, a map[string]interface{}, b map[string]interface{}) []FieldChange
+	walk = func(prefix string, a map[string]interface{}, b map[string]interface{}) []FieldChange {
+		keys := make(map[string]bool)
+		for k := range a {
+			keys[k] = true
+		}
+		for k := range b {
+			keys[k] = true
+		}
+
+		changes := make([]FieldChange, 0)
+		for k := range keys {
+			switch k {
+			case "id", "created", "updated":
+				continue
+			}
+			av, bv := a[k], b[k]
+			am, aok := av.(map[string]interface{})
+			bm, bok := bv.(map[string]interface{})
+			if aok && bok {
+				changes = append(changes, walk(prefix+k+".", am, bm)...)
+			} else if !reflect.DeepEqual(av, bv) {
+				changes = append(changes, FieldChange{prefix + k, av, bv})
+			}
+		}
+		return changes
+	}
+
+	changes := walk("", toMap(before), toMap(after))
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// FieldNames returns the names of the changed fields
+func FieldNames(changes []FieldChange) []string {
+	names := make([]string, len(changes))
+	for i, c := range changes {
+		names[i] = c.Field
+	}
+	return names
+}
+
+func diffValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(j)
+}
+
+// DisplayFieldDiff prints what an update command actually changed, as a
+// table of before and after values, or as a JSON list of FieldChange. what
+// names the object, eg "rack r1".
+func DisplayFieldDiff(what string, changes []FieldChange) {
+	if JSON {
+		JSONOut(changes)
+		return
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("No changes made to %s\n", what)
+		return
+	}
+
+	fmt.Printf("Updated %s:\n\n", what)
+
+	table := GetMarkdownTable()
+	table.SetHeader([]string{"Field", "Before", "After"})
+	for _, c := range changes {
+		table.Append([]string{c.Field, diffValue(c.Before), diffValue(c.After)})
+	}
+	table.Render()
+}
@@ -0,0 +1,113 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+)
+
+// WorkspaceTargets holds the --all-workspaces and --workspaces options of a
+// read-only command that can be run across several workspaces at once
+type WorkspaceTargets struct {
+	all  *bool
+	list *[]string
+}
+
+// WorkspaceFanOutFlags adds --all-workspaces and --workspaces to a command
+func WorkspaceFanOutFlags(cmd *cli.Cmd) *WorkspaceTargets {
+	return &WorkspaceTargets{
+		all: cmd.BoolOpt(
+			"all-workspaces A",
+			false,
+			"Run against every workspace the user can see and merge the results",
+		),
+		list: cmd.StringsOpt(
+			"workspaces",
+			nil,
+			"Run against these workspaces, by UUID or name, and merge the results. Takes a comma separated list or can be given more than once",
+		),
+	}
+}
+
+// Set returns true if either option was used
+func (t *WorkspaceTargets) Set() bool {
+	return t != nil && (*t.all || len(*t.list) > 0)
+}
+
+// Resolve returns the workspaces that were asked for, sorted by name. Names in
+// --workspaces are resolved the same way as everywhere else.
+func (t *WorkspaceTargets) Resolve() (conch.Workspaces, error) {
+	if *t.all && len(*t.list) > 0 {
+		return nil, errors.New("--all-workspaces and --workspaces can't be used together")
+	}
+
+	all, err := API.GetWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(all)
+
+	if *t.all {
+		return all, nil
+	}
+
+	byID := make(map[uuid.UUID]conch.Workspace)
+	for _, w := range all {
+		byID[w.ID] = w
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	out := make(conch.Workspaces, 0)
+	names := make([]string, 0)
+	for _, l := range *t.list {
+		names = append(names, strings.Split(l, ",")...)
+	}
+
+	for _, wat := range names {
+		wat = strings.TrimSpace(wat)
+		if wat == "" {
+			continue
+		}
+		id, err := MagicWorkspaceID(wat)
+		if err != nil {
+			return nil, err
+		}
+		w, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("workspace %s does not exist or you do not have permission to access it", wat)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, w)
+	}
+	sort.Sort(out)
+
+	return out, nil
+}
+
+// FanOut calls fn once for every workspace, spread across LocationWorkers
+// concurrent goroutines. fn must be safe to call concurrently; the usual way
+// is to write into its own index of a results slice. If any call fails, the
+// error names the workspace it failed for.
+func FanOut(workspaces conch.Workspaces, fn func(i int, ws conch.Workspace) error) error {
+	CheckRequestBudget(len(workspaces), fmt.Sprintf("Running against %d workspaces", len(workspaces)))
+	return parallel(len(workspaces), func(i int) error {
+		if err := fn(i, workspaces[i]); err != nil {
+			return fmt.Errorf("workspace %s: %s", workspaces[i].Name, err)
+		}
+		return nil
+	})
+}
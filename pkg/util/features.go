@@ -0,0 +1,101 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/config"
+)
+
+// APIServerVersion is the version the API server reported when BuildAPI ran
+var APIServerVersion string
+
+// featureCache is used when there is no profile to keep the cache in
+var featureCache *config.FeatureCache
+
+// activeFeatureCache returns the cache of probed features for the API server,
+// emptying it if it was gathered from a different version of the server
+func activeFeatureCache() *config.FeatureCache {
+	cache := &featureCache
+	if !IgnoreConfig && ActiveProfile != nil {
+		cache = &ActiveProfile.Features
+	}
+
+	if *cache == nil || (*cache).APIVersion != APIServerVersion {
+		*cache = &config.FeatureCache{
+			APIVersion: APIServerVersion,
+			Features:   make(map[string]bool),
+		}
+	}
+	return *cache
+}
+
+// ForgetFeatures empties the cache of probed features, so that each is
+// probed again the next time it is needed
+func ForgetFeatures() {
+	featureCache = nil
+	if !IgnoreConfig && ActiveProfile != nil && ActiveProfile.Features != nil {
+		ActiveProfile.Features = nil
+		WriteConfig()
+	}
+}
+
+// HasFeature reports whether the API server has the named feature from
+// conch.Features. The answer is cached in the active profile until the
+// server's version changes. BuildAPI must have been run first.
+func HasFeature(name string) (bool, error) {
+	f, ok := conch.FeatureByName(name)
+	if !ok {
+		return false, fmt.Errorf("unknown API feature '%s'", name)
+	}
+
+	cache := activeFeatureCache()
+	if has, ok := cache.Features[f.Name]; ok {
+		return has, nil
+	}
+
+	has, err := API.ProbeFeature(f)
+	if err != nil {
+		return false, err
+	}
+	cache.Features[f.Name] = has
+	WriteConfig()
+
+	return has, nil
+}
+
+// RequireFeature bails, with an explanation, if the API server doesn't have
+// the named feature
+func RequireFeature(name string) {
+	has, err := HasFeature(name)
+	if err != nil {
+		Bail(err)
+	}
+	if has {
+		return
+	}
+
+	f, _ := conch.FeatureByName(name)
+	Bail(fmt.Errorf(
+		"the API server at %s (version %s) doesn't support %s. This command needs a newer server",
+		API.BaseURL,
+		APIServerVersion,
+		f.Description,
+	))
+}
+
+// BuildAPIRequiringFeature returns a function, suitable for a command's
+// Before, that builds the API, verifies the login, and then requires the
+// named feature
+func BuildAPIRequiringFeature(name string) func() {
+	return func() {
+		BuildAPIAndVerifyLogin()
+		RequireFeature(name)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/config"
+)
+
+// FederateAll, given to --federate, means every profile in the config
+const FederateAll = "all"
+
+// profileAPI builds an API client from a profile's URL and credentials
+func profileAPI(p *config.ConchProfile) *conch.Conch {
+	return &conch.Conch{
+		BaseURL:       p.BaseURL,
+		JWT:           p.JWT,
+		Token:         string(p.Token),
+		Debug:         Debug,
+		Trace:         Trace,
+		NoCompression: NoCompression,
+	}
+}
+
+// FederationTargets holds the --federate option of a read-only command that
+// can be run against several Conch instances at once
+type FederationTargets struct {
+	list *[]string
+}
+
+// FederateFlags adds --federate to a command
+func FederateFlags(cmd *cli.Cmd) *FederationTargets {
+	return &FederationTargets{
+		list: cmd.StringsOpt(
+			"federate",
+			nil,
+			"Run against each of these profiles, and so the Conch instances they point at, and merge the results with a source column. Each profile's own workspace is used. Takes a comma separated list or can be given more than once. 'all' means every profile",
+		),
+	}
+}
+
+// Set returns true if --federate was used
+func (t *FederationTargets) Set() bool {
+	return t != nil && len(*t.list) > 0
+}
+
+// Source is one of the Conch instances a federated command runs against
+type Source struct {
+	// Profile is the profile the instance was reached through. Its name is
+	// what results are labelled with.
+	Profile *config.ConchProfile
+
+	// API is a client for the instance, logged in as the profile's user
+	API *conch.Conch
+}
+
+// Resolve returns the profiles that were asked for, sorted by name, each with
+// an API client of its own
+func (t *FederationTargets) Resolve() ([]Source, error) {
+	if Config == nil || len(Config.Profiles) == 0 {
+		return nil, errors.New("there are no profiles to federate. 'conch profile create' makes one")
+	}
+
+	names := make([]string, 0)
+	for _, l := range *t.list {
+		for _, name := range strings.Split(l, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	// A profile that happens to be called 'all' is still found by name
+	if len(names) == 1 && names[0] == FederateAll && Config.Profiles[FederateAll] == nil {
+		names = names[:0]
+		for name := range Config.Profiles {
+			names = append(names, name)
+		}
+	}
+
+	seen := make(map[string]bool)
+	profiles := make([]*config.ConchProfile, 0)
+	for _, name := range names {
+		found, ok := Config.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("could not find a profile named '%s'", name)
+		}
+		if seen[found.Name] {
+			continue
+		}
+		seen[found.Name] = true
+		profiles = append(profiles, found)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+	sources := make([]Source, 0, len(profiles))
+	for _, p := range profiles {
+		api := profileAPI(p)
+		if UserAgent != "" {
+			api.UA = UserAgent
+		}
+		api.Transport = apiTransport()
+
+		// Every instance's requests count against the same budget
+		if API != nil {
+			api.BeforeRequest = API.BeforeRequest
+		}
+
+		sources = append(sources, Source{Profile: p, API: api})
+	}
+	return sources, nil
+}
+
+// Federate calls fn once for every source, spread across LocationWorkers
+// concurrent goroutines, after checking that the source's API server is a
+// version this shell works with. fn must be safe to call concurrently; the
+// usual way is to write into its own index of a results slice. If any call
+// fails, the error names the profile it failed for.
+func Federate(sources []Source, fn func(i int, s Source) error) error {
+	CheckRequestBudget(len(sources), fmt.Sprintf("Running against %d profiles", len(sources)))
+	checkVersions := !DisableApiVersionCheck()
+	if checkVersions && SkipVersionCheck {
+		WarnSkipVersionCheck()
+		checkVersions = false
+	}
+
+	return parallel(len(sources), func(i int) error {
+		s := sources[i]
+
+		if checkVersions {
+			version, err := s.API.GetVersion()
+			if err != nil {
+				return fmt.Errorf("profile %s: %s", s.Profile.Name, err)
+			}
+			if err := checkAPIVersion(s.API, s.Profile, version); err != nil {
+				return fmt.Errorf("profile %s: %s", s.Profile.Name, err)
+			}
+		}
+
+		if err := fn(i, s); err != nil {
+			return fmt.Errorf("profile %s: %s", s.Profile.Name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,460 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OutputFilter is set by the global --filter option. When set, only the list
+// entries that match it are output.
+var OutputFilter *Filter
+
+// FilterHelp describes the --filter expression language
+const FilterHelp = `Only output list entries matching this expression. Compare fields with = != < <= > >= ~ (regular expression) and !~, and combine comparisons with && || ! and parentheses, eg 'health=fail && last_seen<2024-01-01'. JSON output is filtered by JSON field, using dots for nested fields, and tables by column name`
+
+// Filter is a parsed --filter expression
+type Filter struct {
+	root   filterNode
+	fields []string
+}
+
+type filterLookup func(field string) (string, bool)
+
+type filterNode interface {
+	match(lookup filterLookup) bool
+}
+
+type filterAnd struct{ left, right filterNode }
+type filterOr struct{ left, right filterNode }
+type filterNot struct{ node filterNode }
+
+type filterCompare struct {
+	field string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (n filterAnd) match(l filterLookup) bool { return n.left.match(l) && n.right.match(l) }
+func (n filterOr) match(l filterLookup) bool  { return n.left.match(l) || n.right.match(l) }
+func (n filterNot) match(l filterLookup) bool { return !n.node.match(l) }
+
+func (n filterCompare) match(lookup filterLookup) bool {
+	v, ok := lookup(n.field)
+
+	switch n.op {
+	case "":
+		return ok && v != "" && v != "false" && v != "0"
+	case "=", "==":
+		return filterEqual(v, n.value)
+	case "!=":
+		return !filterEqual(v, n.value)
+	case "~":
+		return n.re.MatchString(v)
+	case "!~":
+		return !n.re.MatchString(v)
+	}
+
+	// Something that isn't there is neither before nor after anything
+	if v == "" {
+		return false
+	}
+
+	c := filterCompareValues(v, n.value)
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+var filterTimeFormats = []string{
+	time.RFC3339Nano,
+	DateFormat,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseFilterTime(s string) (time.Time, bool) {
+	for _, f := range filterTimeFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func filterEqual(a string, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	if a == "" || b == "" {
+		return false
+	}
+	return filterCompareValues(a, b) == 0
+}
+
+// filterCompareValues orders two values as times if both are times, as
+// numbers if both are numbers, and otherwise as text
+func filterCompareValues(a string, b string) int {
+	if ta, ok := parseFilterTime(a); ok {
+		if tb, ok := parseFilterTime(b); ok {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if fa, err := strconv.ParseFloat(a, 64); err == nil {
+		if fb, err := strconv.ParseFloat(b, 64); err == nil {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return naturalCompare(strings.ToLower(a), strings.ToLower(b))
+}
+
+/******************************************/
+
+type filterParser struct {
+	tokens []string
+	pos    int
+	fields []string
+}
+
+var filterOps = []string{"==", "!=", "<=", ">=", "!~", "&&", "||", "=", "<", ">", "~", "!", "(", ")"}
+
+func isFilterOp(s string) bool {
+	for _, op := range filterOps {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenizeFilter splits an expression into operators, quoted strings (kept
+// with their quotes), and bare words
+func tokenizeFilter(expr string) ([]string, error) {
+	tokens := make([]string, 0)
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+
+		if unicode.IsSpace(rune(c)) {
+			i++
+			continue
+		}
+
+		if c == '"' || c == '\'' {
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string starting at position %d", i+1)
+			}
+			tokens = append(tokens, expr[i:i+end+2])
+			i += end + 2
+			continue
+		}
+
+		matched := false
+		for _, op := range filterOps {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, op)
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		start := i
+		for i < len(expr) && !unicode.IsSpace(rune(expr[i])) && !strings.ContainsRune("()&|!=<>~\"'", rune(expr[i])) {
+			i++
+		}
+		if i == start {
+			return nil, fmt.Errorf("unexpected '%c' at position %d", c, i+1)
+		}
+		tokens = append(tokens, expr[start:i])
+	}
+
+	return tokens, nil
+}
+
+func (p *filterParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{n}, nil
+
+	case "(":
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		return n, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *filterParser) parseCompare() (filterNode, error) {
+	field := p.next()
+	if field == "" {
+		return nil, errors.New("unexpected end of expression")
+	}
+	if isFilterOp(field) || strings.ContainsAny(field[:1], "\"'") {
+		return nil, fmt.Errorf("expected a field name but found '%s'", field)
+	}
+	p.fields = append(p.fields, field)
+
+	n := filterCompare{field: field}
+
+	switch op := p.peek(); op {
+	case "=", "==", "!=", "<", "<=", ">", ">=", "~", "!~":
+		p.next()
+		n.op = op
+
+		value := p.next()
+		switch {
+		case value == "":
+			return nil, fmt.Errorf("missing a value after '%s %s'", field, op)
+		case isFilterOp(value):
+			return nil, fmt.Errorf("expected a value after '%s %s' but found '%s'", field, op, value)
+		case value[0] == '"' || value[0] == '\'':
+			value = value[1 : len(value)-1]
+		}
+		n.value = value
+
+		if op == "~" || op == "!~" {
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("bad regular expression '%s': %s", value, err)
+			}
+			n.re = re
+		}
+	}
+
+	return n, nil
+}
+
+// ParseFilter parses a --filter expression
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, fmt.Errorf("bad filter: %s", err)
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("bad filter: the expression is empty")
+	}
+
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("bad filter: %s", err)
+	}
+	if p.pos < len(tokens) {
+		return nil, fmt.Errorf("bad filter: unexpected '%s'", p.peek())
+	}
+
+	return &Filter{root: root, fields: p.fields}, nil
+}
+
+/******************************************/
+
+// jsonFilterValue turns a decoded JSON value into the text a filter compares
+// against. Zero times are treated as missing, the same as tables show them.
+func jsonFilterValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if t == "0001-01-01T00:00:00Z" {
+			return ""
+		}
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	j, _ := json.Marshal(v)
+	return string(j)
+}
+
+func jsonFilterLookup(entry interface{}) filterLookup {
+	return func(field string) (string, bool) {
+		cur := entry
+		for _, part := range strings.Split(field, ".") {
+			switch c := cur.(type) {
+			case map[string]interface{}:
+				v, ok := c[part]
+				if !ok {
+					return "", false
+				}
+				cur = v
+			case []interface{}:
+				i, err := strconv.Atoi(part)
+				if err != nil || i < 0 || i >= len(c) {
+					return "", false
+				}
+				cur = c[i]
+			default:
+				return "", false
+			}
+		}
+		return jsonFilterValue(cur), true
+	}
+}
+
+// FilterJSON removes the entries that don't match from a JSON array. Entries
+// that aren't objects never match. Anything other than an array is returned
+// as it is.
+func (f *Filter) FilterJSON(j []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(j)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return j, nil
+	}
+
+	raw := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(trimmed, &raw); err != nil {
+		return j, err
+	}
+
+	kept := make([]json.RawMessage, 0, len(raw))
+	for _, r := range raw {
+		var entry interface{}
+		if err := json.Unmarshal(r, &entry); err != nil {
+			return j, err
+		}
+		if _, ok := entry.(map[string]interface{}); !ok {
+			continue
+		}
+		if f.root.match(jsonFilterLookup(entry)) {
+			kept = append(kept, r)
+		}
+	}
+
+	return json.Marshal(kept)
+}
+
+// MatchRow reports if a table row matches. Fields are matched against the
+// column names in header, ignoring case, spaces, dashes, and underscores, so
+// that 'last_seen' finds the 'Last Seen' column.
+func (f *Filter) MatchRow(header []string, row []string) bool {
+	return f.root.match(func(field string) (string, bool) {
+		for i, h := range header {
+			if sortColumnName(h) == sortColumnName(field) && i < len(row) {
+				return row[i], true
+			}
+		}
+		return "", false
+	})
+}
+
+// checkColumns makes sure every field in the filter is a column of the table,
+// since a misspelled column would otherwise quietly match nothing
+func (f *Filter) checkColumns(header []string) error {
+	for _, field := range f.fields {
+		found := false
+		for _, h := range header {
+			if sortColumnName(h) == sortColumnName(field) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf(
+				"cannot filter this table on '%s'. Available columns: %s. Use --json to filter on any field",
+				field,
+				strings.Join(header, ", "),
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// RawNumbers is set by the global --raw-numbers option. Sizes and counts are
+// then printed as the API gives them, in the API's units, rather than made
+// human readable.
+var RawNumbers bool
+
+// The units the API uses for sizes. Disk sizes in reports are in megabytes,
+// and the disk sizes of hardware profiles in gigabytes, both in the decimal
+// units drive vendors use. Memory is in binary gigabytes.
+const (
+	Megabyte = 1000 * 1000
+	Gigabyte = 1000 * Megabyte
+	Gibibyte = 1024 * 1024 * 1024
+)
+
+// apiUnits names the API's units, for --raw-numbers
+var apiUnits = map[int64]string{
+	Megabyte: "MB",
+	Gigabyte: "GB",
+	Gibibyte: "GB",
+}
+
+var sizeUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// numberFormat is how numbers are written where the user is
+type numberFormat struct {
+	group   string
+	decimal string
+}
+
+// The languages that don't write numbers as 1,234.5. Everyone else gets that.
+var numberFormats = map[string]numberFormat{
+	"da": {".", ","},
+	"de": {".", ","},
+	"es": {".", ","},
+	"id": {".", ","},
+	"it": {".", ","},
+	"nl": {".", ","},
+	"pt": {".", ","},
+	"tr": {".", ","},
+	"cs": {" ", ","},
+	"fi": {" ", ","},
+	"fr": {" ", ","},
+	"nb": {" ", ","},
+	"pl": {" ", ","},
+	"ru": {" ", ","},
+	"sv": {" ", ","},
+	"uk": {" ", ","},
+}
+
+// localNumberFormat goes by the locale the environment asks for numbers in,
+// eg 'de_DE.UTF-8'
+func localNumberFormat() numberFormat {
+	for _, v := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		locale := os.Getenv(v)
+		if locale == "" {
+			continue
+		}
+		parts := strings.FieldsFunc(locale, func(r rune) bool {
+			return r == '_' || r == '.' || r == '@' || r == '-'
+		})
+		if len(parts) > 0 {
+			if f, ok := numberFormats[strings.ToLower(parts[0])]; ok {
+				return f
+			}
+		}
+		break
+	}
+	return numberFormat{",", "."}
+}
+
+// groupDigits puts the separator between every three digits of a whole number
+func (f numberFormat) groupDigits(digits string) string {
+	neg := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+
+	var b strings.Builder
+	if neg {
+		b.WriteString("-")
+	}
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(f.group)
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// FormatCount writes a count with the thousands separated, eg 1,234
+func FormatCount(n int) string {
+	if RawNumbers {
+		return strconv.Itoa(n)
+	}
+	return localNumberFormat().groupDigits(strconv.Itoa(n))
+}
+
+// FormatSize writes a size of n of the given unit in the largest binary unit
+// that keeps it at 1 or more, eg FormatSize(4000787, Megabyte) is "3.6 TiB"
+func FormatSize(n int, unit int64) string {
+	if RawNumbers {
+		return strings.TrimSpace(strconv.Itoa(n) + " " + apiUnits[unit])
+	}
+
+	size := float64(n) * float64(unit)
+	i := 0
+	for i < len(sizeUnits)-1 && (size >= 1024 || size <= -1024) {
+		size /= 1024
+		i++
+	}
+
+	f := localNumberFormat()
+	if i == 0 {
+		return f.groupDigits(strconv.Itoa(int(size))) + " " + sizeUnits[i]
+	}
+
+	s := strconv.FormatFloat(size, 'f', 1, 64)
+	s = strings.TrimSuffix(s, ".0")
+	whole, frac := s, ""
+	if dot := strings.Index(s, "."); dot >= 0 {
+		whole, frac = s[:dot], f.decimal+s[dot+1:]
+	}
+	return f.groupDigits(whole) + frac + " " + sizeUnits[i]
+}
+
+// FormatSizeString is FormatSize for sizes that come as strings, as they do
+// in reports. Anything that isn't a whole number is left as it is.
+func FormatSizeString(s string, unit int64) string {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return s
+	}
+	return FormatSize(n, unit)
+}
+
+// SizeFuncs lets text templates format sizes, eg {{ megabytes .Size }}
+var SizeFuncs = template.FuncMap{
+	"count":     FormatCount,
+	"megabytes": func(n int) string { return FormatSize(n, Megabyte) },
+	"gigabytes": func(n int) string { return FormatSize(n, Gigabyte) },
+	"gibibytes": func(n int) string { return FormatSize(n, Gibibyte) },
+}
@@ -10,6 +10,7 @@ package util
 import (
 	"errors"
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 
@@ -74,9 +75,49 @@ type GithubAsset struct {
 
 var ErrNoGithubRelease = errors.New("no appropriate github release found")
 
-// LatestGithubRelease returns some fields from the latest Github Release
-// that matches our major version
-func LatestGithubRelease() (gh GithubRelease, err error) {
+// Update channels choose which releases the shell offers to update to
+const (
+	ChannelStable     = "stable"
+	ChannelPrerelease = "prerelease"
+)
+
+// UpdateChannels are the channels that can be chosen
+var UpdateChannels = []string{ChannelStable, ChannelPrerelease}
+
+// UpdateChannel is the channel updates come from: CONCH_UPDATE_CHANNEL, or
+// the config's channel, or stable
+func UpdateChannel() string {
+	if c := os.Getenv("CONCH_UPDATE_CHANNEL"); c != "" {
+		return c
+	}
+	if Config != nil && Config.UpdateChannel != "" {
+		return Config.UpdateChannel
+	}
+	return ChannelStable
+}
+
+// ValidUpdateChannel returns an error if channel isn't one of UpdateChannels
+func ValidUpdateChannel(channel string) error {
+	for _, c := range UpdateChannels {
+		if c == channel {
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"unknown update channel '%s'. Choose from: %s",
+		channel,
+		strings.Join(UpdateChannels, ", "),
+	)
+}
+
+// githubReleases fetches every release of the shell, newest first, with
+// SemVer filled in. Prereleases are left out unless the channel is
+// prerelease.
+func githubReleases(channel string) (GithubReleases, error) {
+	if err := ValidUpdateChannel(channel); err != nil {
+		return nil, err
+	}
+
 	releases := make(GithubReleases, 0)
 
 	url := fmt.Sprintf(
@@ -85,74 +126,82 @@ func LatestGithubRelease() (gh GithubRelease, err error) {
 		GhRepo,
 	)
 
-	_, err = sling.New().
+	_, err := sling.New().
 		Set("User-Agent", UserAgent).
 		Get(url).Receive(&releases, nil)
 
 	if err != nil {
-		return gh, err
+		return nil, err
 	}
 
 	sort.Sort(releases)
 
+	wanted := make(GithubReleases, 0, len(releases))
 	for _, r := range releases {
-		if r.PreRelease {
+		if r.PreRelease && channel != ChannelPrerelease {
 			continue
 		}
 		if r.TagName == "" {
 			continue
 		}
-		r.SemVer = CleanVersion(
-			strings.TrimLeft(r.TagName, "v"),
-		)
 
-		if r.SemVer.Major == SemVersion.Major {
-			if r.SemVer.GT(SemVersion) {
-				r.Upgrade = true
+		// Prereleases keep their suffix, so that 3.1.0-rc.2 comes after
+		// 3.1.0-rc.1
+		r.SemVer = CleanVersion(r.TagName)
+		if r.PreRelease {
+			if sem, err := semver.Parse(strings.TrimLeft(r.TagName, "v")); err == nil {
+				r.SemVer = sem
 			}
-			return r, nil
 		}
+
+		if r.SemVer.Major == SemVersion.Major {
+			wanted = append(wanted, r)
+		}
+	}
+	return wanted, nil
+}
+
+// LatestGithubRelease returns some fields from the latest Github Release
+// on the update channel that matches our major version
+func LatestGithubRelease() (gh GithubRelease, err error) {
+	return LatestGithubReleaseOn(UpdateChannel())
+}
+
+// LatestGithubReleaseOn is LatestGithubRelease for a given channel
+func LatestGithubReleaseOn(channel string) (gh GithubRelease, err error) {
+	releases, err := githubReleases(channel)
+	if err != nil {
+		return gh, err
+	}
+
+	for _, r := range releases {
+		if r.SemVer.GT(SemVersion) {
+			r.Upgrade = true
+		}
+		return r, nil
 	}
 
 	return gh, ErrNoGithubRelease
 }
 
+// GithubReleasesSince returns the releases on the update channel that are
+// newer than start
 func GithubReleasesSince(start semver.Version) GithubReleases {
-	releases := make(GithubReleases, 0)
+	return GithubReleasesSinceOn(start, UpdateChannel())
+}
 
+// GithubReleasesSinceOn is GithubReleasesSince for a given channel
+func GithubReleasesSinceOn(start semver.Version, channel string) GithubReleases {
 	diff := make(GithubReleases, 0)
 
-	url := fmt.Sprintf(
-		"https://api.github.com/repos/%s/%s/releases",
-		GhOrg,
-		GhRepo,
-	)
-
-	_, err := sling.New().
-		Set("User-Agent", UserAgent).
-		Get(url).Receive(&releases, nil)
-
+	releases, err := githubReleases(channel)
 	if err != nil {
 		return diff
 	}
 
-	sort.Sort(releases)
-
 	for _, r := range releases {
-		if r.PreRelease {
-			continue
-		}
-		if r.TagName == "" {
-			continue
-		}
-		r.SemVer = CleanVersion(
-			strings.TrimLeft(r.TagName, "v"),
-		)
-
-		if r.SemVer.Major == SemVersion.Major {
-			if r.SemVer.GT(start) {
-				diff = append(diff, r)
-			}
+		if r.SemVer.GT(start) {
+			diff = append(diff, r)
 		}
 	}
 
@@ -0,0 +1,81 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+)
+
+// 'conch debug record' and 'conch debug replay' run a command with one of
+// these set to the HAR file to use
+const (
+	RecordHAREnv = "CONCH_RECORD_HAR"
+	ReplayHAREnv = "CONCH_REPLAY_HAR"
+)
+
+var har struct {
+	sync.Mutex
+	recorder *conch.HARRecorder
+	replayer *conch.HARReplayer
+}
+
+// Replaying reports whether API requests are being answered from a recording
+func Replaying() bool {
+	return os.Getenv(ReplayHAREnv) != ""
+}
+
+// harTransport is the transport for the API when a session is being recorded
+// or replayed, and nil otherwise. Every API built during the run shares it,
+// so that a batch ends up in a single recording.
+func harTransport() http.RoundTripper {
+	har.Lock()
+	defer har.Unlock()
+
+	if path := os.Getenv(ReplayHAREnv); path != "" {
+		if har.replayer == nil {
+			h, err := conch.LoadHAR(path)
+			if err != nil {
+				Bail(err)
+			}
+			har.replayer = conch.NewHARReplayer(h)
+		}
+		return har.replayer
+	}
+
+	if path := os.Getenv(RecordHAREnv); path != "" {
+		if har.recorder == nil {
+			har.recorder = conch.NewHARRecorder(
+				conch.DefaultTransport(),
+				conch.NewHAR("conch shell", Version),
+			)
+			// Saving after every request means the recording survives
+			// the command bailing out, which is when it is most wanted
+			har.recorder.OnEntry = func(h *conch.HAR) {
+				if err := saveHAR(path, h); err != nil {
+					fmt.Fprintf(os.Stderr, "Could not save the recording to %s: %s\n", path, err)
+				}
+			}
+		}
+		return har.recorder
+	}
+
+	return nil
+}
+
+func saveHAR(path string, h *conch.HAR) error {
+	b, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, append(b, '\n'), 0600)
+}
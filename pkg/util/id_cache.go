@@ -0,0 +1,242 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/config"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// IDCachePath is where human identifiers resolved to IDs are remembered
+// between invocations
+var IDCachePath = config.DataPath("ids.json")
+
+// IDCacheTTL is how long a resolved identifier is trusted before the
+// collection it came from is listed again
+var IDCacheTTL = 24 * time.Hour
+
+// idCacheKind holds the names of one kind of object, eg the racks of a single
+// workspace, mapped to their IDs. IDs are also mapped to themselves so that
+// partial UUIDs can be matched without listing the collection again.
+type idCacheKind struct {
+	Refreshed time.Time         `json:"refreshed"`
+	IDs       map[string]string `json:"ids"`
+}
+
+// idCacheFile is keyed by API URL, then by kind
+type idCacheFile map[string]map[string]*idCacheKind
+
+var (
+	idCache          idCacheFile
+	idCacheRefreshed = make(map[string]bool)
+)
+
+// idMap collects name to ID mappings. The first ID added for a name wins.
+// Names added with addUnique that point at more than one ID are ambiguous
+// and are dropped when the map is finished.
+type idMap struct {
+	ids       map[string]string
+	unique    map[string]bool
+	ambiguous map[string]bool
+}
+
+func newIDMap() *idMap {
+	return &idMap{
+		ids:       make(map[string]string),
+		unique:    make(map[string]bool),
+		ambiguous: make(map[string]bool),
+	}
+}
+
+func (m *idMap) add(name string, id string) {
+	if name == "" || id == "" {
+		return
+	}
+	if _, ok := m.ids[name]; ok {
+		return
+	}
+	m.ids[name] = id
+}
+
+func (m *idMap) addUnique(name string, id string) {
+	if name == "" || id == "" {
+		return
+	}
+	existing, ok := m.ids[name]
+	if !ok {
+		m.ids[name] = id
+		m.unique[name] = true
+		return
+	}
+	if m.unique[name] && existing != id {
+		m.ambiguous[name] = true
+	}
+}
+
+func (m *idMap) finish() map[string]string {
+	for name := range m.ambiguous {
+		delete(m.ids, name)
+	}
+	return m.ids
+}
+
+func idCachePath() string {
+	path, err := homedir.Expand(IDCachePath)
+	if err != nil {
+		return IDCachePath
+	}
+	return path
+}
+
+func idCacheKey() string {
+	if API != nil && API.BaseURL != "" {
+		return API.BaseURL
+	}
+	return BaseURL
+}
+
+func loadIDCache() {
+	if idCache != nil {
+		return
+	}
+	idCache = make(idCacheFile)
+
+	// A missing or damaged cache just means everything gets looked up again
+	j, err := ioutil.ReadFile(idCachePath())
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(j, &idCache); err != nil {
+		idCache = make(idCacheFile)
+	}
+}
+
+func saveIDCache() {
+	j, err := json.Marshal(idCache)
+	if err != nil {
+		return
+	}
+
+	// The cache is only an optimization, so failing to write it isn't worth
+	// failing the command over
+	path := idCachePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	_ = ioutil.WriteFile(path, j, 0600)
+}
+
+func idCacheFor(kind string) *idCacheKind {
+	loadIDCache()
+
+	key := idCacheKey()
+	if idCache[key] == nil {
+		idCache[key] = make(map[string]*idCacheKind)
+	}
+	if idCache[key][kind] == nil {
+		idCache[key][kind] = &idCacheKind{IDs: make(map[string]string)}
+	}
+	return idCache[key][kind]
+}
+
+func (k *idCacheKind) find(wat string) (string, bool) {
+	if id, ok := k.IDs[wat]; ok {
+		return id, true
+	}
+
+	prefix := strings.ToLower(wat) + "-"
+	for _, id := range k.IDs {
+		if _, err := uuid.FromString(id); err != nil {
+			continue
+		}
+		if strings.HasPrefix(id, prefix) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// storeIDs replaces one kind of cached IDs and marks it as fresh
+func storeIDs(kind string, ids map[string]string) {
+	k := idCacheFor(kind)
+	k.IDs = ids
+	k.Refreshed = time.Now().UTC()
+	idCacheRefreshed[kind] = true
+}
+
+// forgetIDs marks one kind of cached IDs as stale, so that the next lookup
+// lists the collection again
+func forgetIDs(kind string) {
+	k := idCacheFor(kind)
+	k.Refreshed = time.Time{}
+	saveIDCache()
+}
+
+// cachedID resolves a name or partial UUID to an ID using the local cache.
+// The refresh function lists the whole collection and is only called when
+// the cache is older than IDCacheTTL or, if refreshOnMiss is set, when the
+// name isn't in the cache. It is called at most once per kind per
+// invocation. An empty string means the name couldn't be resolved.
+func cachedID(
+	kind string,
+	wat string,
+	refreshOnMiss bool,
+	refresh func() (map[string]string, error),
+) (string, error) {
+	k := idCacheFor(kind)
+
+	stale := time.Since(k.Refreshed) > IDCacheTTL
+	if !stale {
+		if id, ok := k.find(wat); ok {
+			return id, nil
+		}
+		if !refreshOnMiss {
+			return "", nil
+		}
+	}
+
+	if idCacheRefreshed[kind] {
+		return "", nil
+	}
+
+	ids, err := refresh()
+	if err != nil {
+		return "", err
+	}
+	storeIDs(kind, ids)
+	saveIDCache()
+
+	id, _ := k.find(wat)
+	return id, nil
+}
+
+// cachedIDs returns every cached ID of one kind, refreshing them first if
+// they are older than IDCacheTTL or have never been fetched
+func cachedIDs(kind string, refresh func() (map[string]string, error)) (map[string]string, error) {
+	k := idCacheFor(kind)
+
+	if time.Since(k.Refreshed) <= IDCacheTTL || idCacheRefreshed[kind] {
+		return k.IDs, nil
+	}
+
+	ids, err := refresh()
+	if err != nil {
+		return nil, err
+	}
+	storeIDs(kind, ids)
+	saveIDCache()
+
+	return ids, nil
+}
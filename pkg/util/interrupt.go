@@ -0,0 +1,205 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// ErrInterrupted is returned by long running work that stopped early because
+// the user hit ^C
+var ErrInterrupted = errors.New("interrupted")
+
+// InterruptExitCode is what the shell exits with when it is interrupted, as
+// a shell would for SIGINT
+const InterruptExitCode = 130
+
+var interrupts = struct {
+	sync.Mutex
+	installed   bool
+	interrupted bool
+	stoppable   int
+	next        int
+	cleanups    map[int]func()
+}{cleanups: make(map[int]func())}
+
+// HandleInterrupts catches SIGINT and SIGTERM for the rest of the run.
+//
+// Inside a Stoppable section, the first interrupt only sets Interrupted, so
+// that the work can stop between two steps and report what it got done. A
+// second interrupt, or one outside a Stoppable section, runs the OnInterrupt
+// cleanups, writes the change journal, and exits.
+func HandleInterrupts() {
+	interrupts.Lock()
+	defer interrupts.Unlock()
+	if interrupts.installed {
+		return
+	}
+	interrupts.installed = true
+
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for range c {
+			interrupts.Lock()
+			if interrupts.stoppable > 0 && !interrupts.interrupted {
+				interrupts.interrupted = true
+				interrupts.Unlock()
+				fmt.Fprintln(os.Stderr, "\nInterrupted. Stopping after the current step. Interrupt again to quit now")
+				continue
+			}
+			interrupts.Unlock()
+			abort()
+		}
+	}()
+}
+
+// abort is the hard stop. The command is still running in another goroutine,
+// so this can only tidy up after it, not unwind it.
+func abort() {
+	interrupts.Lock()
+	cleanups := make([]func(), 0, len(interrupts.cleanups))
+	for i := interrupts.next - 1; i >= 0; i-- {
+		if fn, ok := interrupts.cleanups[i]; ok {
+			cleanups = append(cleanups, fn)
+		}
+	}
+	interrupts.Unlock()
+
+	for _, fn := range cleanups {
+		fn()
+	}
+	FlushJournal()
+
+	// Whatever was being printed stopped mid line, so start the message,
+	// and the user's prompt, on a fresh one
+	fmt.Fprintln(os.Stderr, "\nInterrupted")
+	os.Exit(InterruptExitCode)
+}
+
+// Interrupted reports whether the user has asked a Stoppable section to stop
+func Interrupted() bool {
+	interrupts.Lock()
+	defer interrupts.Unlock()
+	return interrupts.interrupted
+}
+
+// Stoppable marks the start of work that checks Interrupted between steps and
+// copes with stopping early. Call the returned func when the work is over.
+func Stoppable() (done func()) {
+	interrupts.Lock()
+	interrupts.stoppable++
+	interrupts.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			interrupts.Lock()
+			interrupts.stoppable--
+			interrupts.Unlock()
+		})
+	}
+}
+
+// OnInterrupt registers fn to run if the shell is interrupted before the
+// returned func is called. Cleanups run newest first.
+func OnInterrupt(fn func()) (remove func()) {
+	interrupts.Lock()
+	id := interrupts.next
+	interrupts.next++
+	interrupts.cleanups[id] = fn
+	interrupts.Unlock()
+
+	return func() {
+		interrupts.Lock()
+		delete(interrupts.cleanups, id)
+		interrupts.Unlock()
+	}
+}
+
+// AtomicFile is an output file that appears at its path in one step, when it
+// is committed, so that an interrupted or failed command never leaves half of
+// one behind. Until then it is written to a temporary file alongside.
+type AtomicFile struct {
+	*os.File
+	path      string
+	perm      os.FileMode
+	done      bool
+	forgetTmp func()
+}
+
+// CreateAtomic starts writing the file at path
+func CreateAtomic(path string, perm os.FileMode) (*AtomicFile, error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AtomicFile{File: tmp, path: path, perm: perm}
+	name := tmp.Name()
+	a.forgetTmp = OnInterrupt(func() { _ = os.Remove(name) })
+	return a, nil
+}
+
+// Commit moves the finished file into place
+func (a *AtomicFile) Commit() error {
+	if a.done {
+		return nil
+	}
+	a.done = true
+	defer a.forgetTmp()
+
+	if err := a.File.Chmod(a.perm); err != nil {
+		a.File.Close()
+		os.Remove(a.File.Name())
+		return err
+	}
+	if err := a.File.Close(); err != nil {
+		os.Remove(a.File.Name())
+		return err
+	}
+	if err := os.Rename(a.File.Name(), a.path); err != nil {
+		os.Remove(a.File.Name())
+		return err
+	}
+	return nil
+}
+
+// Abandon throws the file away, unless it was committed. It is meant to be
+// deferred.
+func (a *AtomicFile) Abandon() {
+	if a.done {
+		return
+	}
+	a.done = true
+	a.File.Close()
+	os.Remove(a.File.Name())
+	a.forgetTmp()
+}
+
+// WriteFileAtomic is ioutil.WriteFile, except that the file only appears once
+// all of data is written
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	a, err := CreateAtomic(path, perm)
+	if err != nil {
+		return err
+	}
+	defer a.Abandon()
+
+	if _, err := a.Write(data); err != nil {
+		return err
+	}
+	return a.Commit()
+}
@@ -0,0 +1,239 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// JournalEntry is a single line in the change journal. One entry is written
+// per command invocation that changed something.
+type JournalEntry struct {
+	ID        string           `json:"id"`
+	Time      time.Time        `json:"time"`
+	Command   []string         `json:"command"`
+	Profile   string           `json:"profile,omitempty"`
+	API       string           `json:"api"`
+	Mutations []conch.Mutation `json:"mutations"`
+
+	// UndoOf is the ID of the entry this one undid, if it was written by
+	// 'conch undo'
+	UndoOf string `json:"undo_of,omitempty"`
+
+	// BreakGlass describes the operation, if the command had to be confirmed
+	// by naming its target. See BreakGlass.
+	BreakGlass string `json:"break_glass,omitempty"`
+}
+
+var journal *JournalEntry
+
+// journalLock keeps an interrupt from flushing the journal while a mutation
+// is being added to it
+var journalLock sync.Mutex
+
+// Anything under these keys is replaced before it hits the disk
+var journalRedactedKeys = map[string]bool{
+	"password":     true,
+	"new_password": true,
+	"token":        true,
+	"jwt_token":    true,
+}
+
+// These requests are about authentication, not data, and are left out
+// entirely
+var journalSkippedPaths = []string{
+	"/login",
+	"/logout",
+	"/refresh_token",
+	"/user/me/password",
+}
+
+// redactArgs hides the values of command line options that carry credentials
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	hideNext := false
+	for i, a := range args {
+		switch {
+		case hideNext:
+			out[i] = "REDACTED"
+			hideNext = false
+		case a == "--token" || a == "--password":
+			out[i] = a
+			hideNext = true
+		case strings.HasPrefix(a, "--token=") || strings.HasPrefix(a, "--password="):
+			out[i] = a[:strings.Index(a, "=")+1] + "REDACTED"
+		default:
+			out[i] = a
+		}
+	}
+	return out
+}
+
+func redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if journalRedactedKeys[k] {
+				t[k] = "REDACTED"
+			} else {
+				t[k] = redact(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = redact(val)
+		}
+	}
+	return v
+}
+
+func redactRaw(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return raw
+	}
+
+	out, err := json.Marshal(redact(v))
+	if err != nil {
+		return raw
+	}
+	return json.RawMessage(out)
+}
+
+// StartJournal hooks the API up to the change journal, if the active profile
+// has a journal directory
+func StartJournal() {
+	// Replayed changes were never made
+	if ActiveProfile == nil || ActiveProfile.JournalDir == "" || Replaying() {
+		return
+	}
+
+	journal = &JournalEntry{
+		Command:   redactArgs(Args),
+		Profile:   ActiveProfile.Name,
+		API:       API.BaseURL,
+		Mutations: make([]conch.Mutation, 0),
+	}
+
+	API.OnMutation = func(m conch.Mutation) {
+		for _, p := range journalSkippedPaths {
+			if strings.Contains(m.URL, p) {
+				return
+			}
+		}
+
+		m.Request = redactRaw(m.Request)
+		m.Response = redactRaw(m.Response)
+
+		journalLock.Lock()
+		defer journalLock.Unlock()
+		if journal != nil {
+			journal.Mutations = append(journal.Mutations, m)
+		}
+	}
+
+	API.BeforeMutation = captureUndoState
+}
+
+// FlushJournal appends the current command's changes to the journal, and
+// commits them if the profile asked for that. Problems are reported but never
+// fatal; the changes have already happened by now.
+func FlushJournal() {
+	journalLock.Lock()
+	defer journalLock.Unlock()
+
+	if journal == nil || len(journal.Mutations) == 0 {
+		return
+	}
+	entry := journal
+	journal = nil
+
+	entry.Time = time.Now().UTC()
+	entry.ID = entry.Time.Format(time.RFC3339Nano)
+
+	if err := writeJournal(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write change journal: %s\n", err)
+	}
+}
+
+func writeJournal(entry *JournalEntry) error {
+	dir, err := homedir.Expand(ActiveProfile.JournalDir)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	name := entry.Time.Format("2006-01") + ".jsonl"
+
+	j, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(
+		filepath.Join(dir, name),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		0644,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(j, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if !ActiveProfile.JournalGit {
+		return nil
+	}
+
+	git := func(args ...string) error {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %s: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := git("init", "-q"); err != nil {
+			return err
+		}
+	}
+
+	if err := git("add", name); err != nil {
+		return err
+	}
+
+	msg := "conch"
+	if len(entry.Command) > 1 {
+		msg = msg + " " + strings.Join(entry.Command[1:], " ")
+	}
+
+	return git("commit", "-q", "-m", msg, "--", name)
+}
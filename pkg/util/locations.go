@@ -0,0 +1,300 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+)
+
+// LocationWorkers is the number of API requests FillDeviceLocations will have
+// in flight at once
+var LocationWorkers = 8
+
+// parallel calls fn for 0 through n-1 using at most LocationWorkers
+// goroutines. The first error stops any further calls and is returned.
+func parallel(n int, fn func(i int) error) error {
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		failed   = make(chan struct{})
+		work     = make(chan int)
+	)
+
+	workers := LocationWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if err := fn(i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						close(failed)
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case work <- i:
+		case <-failed:
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	return firstErr
+}
+
+// uniqueIDs returns each non-zero UUID once, in the order first seen
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool)
+	out := make([]uuid.UUID, 0)
+	for _, id := range ids {
+		if uuid.Equal(id, uuid.UUID{}) || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
+
+// FillDeviceLocations populates the Location of every device that doesn't
+// already have one.
+//
+// Devices that know their rack are resolved through that rack, so each rack,
+// its layout, its room, and its datacenter are fetched only once no matter
+// how many devices live there. Any device without a rack ID falls back to its
+// own /device/:serial/location request. All the lookups are spread across
+// LocationWorkers concurrent requests.
+func FillDeviceLocations(devices []conch.Device) ([]conch.Device, error) {
+	filledIn := make([]conch.Device, len(devices))
+	copy(filledIn, devices)
+
+	var (
+		rackIDs    = make([]uuid.UUID, 0)
+		stragglers = make([]int, 0)
+		inRacks    = make([]int, 0)
+	)
+
+	for i, d := range filledIn {
+		if d.Location.Rack.Name != "" {
+			continue
+		}
+		if uuid.Equal(d.RackID, uuid.UUID{}) {
+			stragglers = append(stragglers, i)
+			continue
+		}
+		rackIDs = append(rackIDs, d.RackID)
+		inRacks = append(inRacks, i)
+	}
+
+	// Rooms and datacenters come on top, but there are few of those
+	uniqueRacks := uniqueIDs(rackIDs)
+	CheckRequestBudget(
+		len(stragglers)+2*len(uniqueRacks)+2,
+		fmt.Sprintf("Finding the locations of %d devices", len(devices)),
+	)
+
+	// The table renderer only needs the location data so there's no
+	// need to go get a full DetailedDevice with its attendant database
+	// queries.
+	// In my experience, getting the full DetailedDevice doubles this
+	// query time [sungo]
+	err := parallel(len(stragglers), func(i int) error {
+		d := &filledIn[stragglers[i]]
+		loc, err := API.GetDeviceLocation(d.ID)
+		if err != nil {
+			return err
+		}
+		d.Location = loc
+		return nil
+	})
+	if err != nil {
+		return devices, err
+	}
+
+	if len(inRacks) == 0 {
+		return filledIn, nil
+	}
+
+	var mu sync.Mutex
+
+	racks := make(map[uuid.UUID]conch.Rack)
+	layouts := make(map[uuid.UUID]conch.RackLayoutSlots)
+	err = parallel(len(uniqueRacks), func(i int) error {
+		rack, err := API.GetRack(uniqueRacks[i])
+		if err != nil {
+			return err
+		}
+		layout, err := API.GetRackLayout(rack)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		racks[rack.ID] = rack
+		layouts[rack.ID] = layout
+		return nil
+	})
+	if err != nil {
+		return devices, err
+	}
+
+	roomIDs := make([]uuid.UUID, 0, len(racks))
+	for _, r := range racks {
+		roomIDs = append(roomIDs, r.DatacenterRoomID)
+	}
+	uniqueRooms := uniqueIDs(roomIDs)
+
+	rooms := make(map[uuid.UUID]conch.Room)
+	err = parallel(len(uniqueRooms), func(i int) error {
+		room, err := API.GetRoom(uniqueRooms[i])
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		rooms[room.ID] = room
+		return nil
+	})
+	if err != nil {
+		return devices, err
+	}
+
+	datacenterIDs := make([]uuid.UUID, 0, len(rooms))
+	for _, r := range rooms {
+		datacenterIDs = append(datacenterIDs, r.DatacenterID)
+	}
+	uniqueDatacenters := uniqueIDs(datacenterIDs)
+
+	datacenters := make(map[uuid.UUID]conch.Datacenter)
+	err = parallel(len(uniqueDatacenters), func(i int) error {
+		dc, err := API.GetDatacenter(uniqueDatacenters[i])
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		datacenters[dc.ID] = dc
+		return nil
+	})
+	if err != nil {
+		return devices, err
+	}
+
+	products, err := API.GetHardwareProducts()
+	if err != nil {
+		return devices, err
+	}
+	vendors, err := API.GetHardwareVendors()
+	if err != nil {
+		return devices, err
+	}
+	vendorNames := make(map[uuid.UUID]string)
+	for _, v := range vendors {
+		vendorNames[v.ID] = v.Name
+	}
+	targets := make(map[uuid.UUID]conch.HardwareProductTarget)
+	for _, p := range products {
+		targets[p.ID] = conch.HardwareProductTarget{
+			ID:     p.ID,
+			Name:   p.Name,
+			Alias:  p.Alias,
+			Vendor: vendorNames[p.HardwareVendorID],
+		}
+	}
+
+	for _, i := range inRacks {
+		d := &filledIn[i]
+
+		rack := racks[d.RackID]
+		room := rooms[rack.DatacenterRoomID]
+
+		d.Location.Rack = rack
+		d.Location.RackUnitStart = d.RackUnitStart
+		d.Location.Datacenter = datacenters[room.DatacenterID]
+		d.Location.Room = conch.DatacenterDetailedRoom{
+			ID:           room.ID,
+			AZ:           room.AZ,
+			Alias:        room.Alias,
+			VendorName:   room.VendorName,
+			DatacenterID: room.DatacenterID,
+			Created:      room.Created,
+			Updated:      room.Updated,
+		}
+
+		for _, slot := range layouts[d.RackID] {
+			if slot.RUStart == d.RackUnitStart {
+				d.Location.TargetHardwareProduct = targets[slot.ProductID]
+				break
+			}
+		}
+	}
+
+	return filledIn, nil
+}
+
+// FillDeviceDetails replaces every device with the full device from
+// /device/:serial, which carries the NICs, disks, and latest report that
+// listings leave out. The requests are spread across LocationWorkers
+// concurrent requests.
+func FillDeviceDetails(devices []conch.Device) ([]conch.Device, error) {
+	CheckRequestBudget(len(devices), fmt.Sprintf("Fetching the details of %d devices", len(devices)))
+
+	filledIn := make([]conch.Device, len(devices))
+	err := parallel(len(devices), func(i int) error {
+		d, err := API.GetDevice(devices[i].ID)
+		if err != nil {
+			return err
+		}
+		filledIn[i] = d
+		return nil
+	})
+	if err != nil {
+		return devices, err
+	}
+	return filledIn, nil
+}
+
+// Each calls fn for 0 through n-1, spread across LocationWorkers concurrent
+// calls, after checking the request budget. what says what the calls are
+// for, eg "Submitting 40 reports". The first error stops any further calls
+// and is returned.
+func Each(n int, what string, fn func(i int) error) error {
+	CheckRequestBudget(n, what)
+	return parallel(n, fn)
+}
+
+// EachDevice calls fn for every device, spread across LocationWorkers
+// concurrent calls. The first error stops any further calls and is returned.
+func EachDevice(devices []conch.Device, fn func(i int, d conch.Device) error) error {
+	CheckRequestBudget(len(devices), fmt.Sprintf("Visiting %d devices", len(devices)))
+	return parallel(len(devices), func(i int) error {
+		return fn(i, devices[i])
+	})
+}
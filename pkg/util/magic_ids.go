@@ -10,112 +10,152 @@ package util
 import (
 	"errors"
 	"fmt"
+	"os"
 	"regexp"
 
 	"github.com/joyent/conch-shell/pkg/conch/uuid"
 )
 
+// magicCachedUUID resolves a name or partial UUID through the local ID cache.
+// The fill function lists the collection into an idMap and is only called
+// when the cache can't answer.
+func magicCachedUUID(kind string, what string, wat string, fill func(m *idMap) error) (uuid.UUID, error) {
+	id, err := uuid.FromString(wat)
+	if err == nil {
+		return id, err
+	}
+
+	found, err := cachedID(kind, wat, true, idMapRefresh(fill))
+	if err != nil {
+		return id, err
+	}
+	if found == "" {
+		return id, errors.New("Could not find " + what + " " + wat)
+	}
+
+	return uuid.FromString(found)
+}
+
+// idMapRefresh turns a function that fills an idMap into one that refreshes
+// the ID cache
+func idMapRefresh(fill func(m *idMap) error) func() (map[string]string, error) {
+	return func() (map[string]string, error) {
+		m := newIDMap()
+		if err := fill(m); err != nil {
+			return nil, err
+		}
+		return m.finish(), nil
+	}
+}
+
 // MagicWorkspaceID takes a string and tries to find a valid UUID. If the
 // string is a UUID, it doesn't get checked further. If not, we dig through
 // GetWorkspaces() looking for UUIDs that match up to the first hyphen or where
-// the workspace name matches the string
+// the workspace name matches the string. The results of that dig are cached
+// locally for IDCacheTTL.
 func MagicWorkspaceID(wat string) (id uuid.UUID, err error) {
-	id, err = uuid.FromString(wat)
-	if err == nil {
-		return id, err
-	}
-	// So, it's not a UUID. Let's try for a string name or partial UUID
+	return magicCachedUUID("workspaces", "workspace", wat, workspaceIDs)
+}
+
+func workspaceIDs(m *idMap) error {
 	workspaces, err := API.GetWorkspaces()
 	if err != nil {
-		return id, err
+		return err
 	}
 
-	re := regexp.MustCompile(fmt.Sprintf("^%s-", wat))
 	for _, w := range workspaces {
-		if (w.Name == wat) || re.MatchString(w.ID.String()) {
-			return w.ID, nil
+		m.add(w.Name, w.ID.String())
+		m.add(w.ID.String(), w.ID.String())
+	}
+	return nil
+}
+
+// MagicWorkspaceOrActiveID behaves like MagicWorkspaceID, except that an empty
+// string resolves to the workspace in the active profile. If the profile
+// doesn't have one either, the user is asked to pick one, if they can be.
+func MagicWorkspaceOrActiveID(wat string) (uuid.UUID, error) {
+	if wat != "" {
+		return MagicWorkspaceID(wat)
+	}
+
+	if ActiveProfile == nil || uuid.Equal(ActiveProfile.WorkspaceUUID, uuid.UUID{}) {
+		if CanPick() {
+			return PickWorkspaceID()
 		}
+		return uuid.UUID{}, errors.New("no workspace was found in the active profile")
 	}
 
-	return id, errors.New("Could not find workspace " + wat)
+	return ActiveProfile.WorkspaceUUID, nil
 }
 
 // MagicWorkspaceRackID takes a workspace UUID and a string and tries to find a
 // valid rack UUID. If the string is a UUID, it doesn't get checked further. If
 // it's not a UUID, we dig through GetWorkspaceRacks() looking for UUIDs that
-// match up to the first hyphen or where the name matches the string.
+// match up to the first hyphen or where the name matches the string. The
+// results of that dig are cached locally for IDCacheTTL.
 func MagicWorkspaceRackID(workspace fmt.Stringer, wat string) (uuid.UUID, error) {
-	id, err := uuid.FromString(wat)
-	if err == nil {
-		return id, err
-	}
-
-	// So, it's not a UUID. Let's try for a string name or partial UUID
-	racks, err := API.GetWorkspaceRacks(workspace)
-	if err != nil {
-		return id, err
-	}
-
-	re := regexp.MustCompile(fmt.Sprintf("^%s-", wat))
-	for _, r := range racks {
-		if (r.Name == wat) || re.MatchString(r.ID.String()) {
-			return r.ID, nil
+	kind := "workspace_racks:" + workspace.String()
+	return magicCachedUUID(kind, "rack", wat, func(m *idMap) error {
+		racks, err := API.GetWorkspaceRacks(workspace)
+		if err != nil {
+			return err
 		}
-	}
 
-	return id, errors.New("Could not find rack " + wat)
+		for _, r := range racks {
+			m.add(r.Name, r.ID.String())
+			m.add(r.ID.String(), r.ID.String())
+		}
+		return nil
+	})
 }
 
 // MagicRackID takes a string and tries to find a valid global rack UUID.
 // If the string is a UUID, it doesn't get checked further. If it's not a UUID,
 // we dig through GetRacks() looking for UUIDs that match up to the first
-// hyphen.
+// hyphen, or for the one rack with that name. Names shared by several racks
+// are not matched. The results of that dig are cached locally for IDCacheTTL.
 func MagicRackID(wat string) (uuid.UUID, error) {
-	id, err := uuid.FromString(wat)
-	if err == nil {
-		return id, err
-	}
+	return magicCachedUUID("racks", "rack", wat, rackIDs)
+}
 
-	// So, it's not a UUID. Let's try for a string name or partial UUID
+func rackIDs(m *idMap) error {
 	racks, err := API.GetRacks()
 	if err != nil {
-		return id, err
+		return err
 	}
 
-	re := regexp.MustCompile(fmt.Sprintf("^%s-", wat))
 	for _, r := range racks {
-		if re.MatchString(r.ID.String()) {
-			return r.ID, nil
-		}
+		m.add(r.ID.String(), r.ID.String())
 	}
-
-	return id, errors.New("Could not find rack " + wat)
+	for _, r := range racks {
+		m.addUnique(r.Name, r.ID.String())
+	}
+	return nil
 }
 
 // MagicProductID takes a string and tries to find a valid UUID. If the
 // string is a UUID, it doesn't get checked further. If not, we dig through
 // GetHardwareProducts() looking for UUIDs that match up to the first hyphen or
-// where the product name or SKU matches the string
+// where the product name or SKU matches the string. The results of that dig
+// are cached locally for IDCacheTTL.
 func MagicProductID(wat string) (uuid.UUID, error) {
-	id, err := uuid.FromString(wat)
-	if err == nil {
-		return id, err
-	}
+	return magicCachedUUID("products", "product", wat, productIDs)
+}
 
-	// So, it's not a UUID. Let's try for a string name or partial UUID
-	d, err := API.GetHardwareProducts()
+// productIDs includes deactivated products, so that they can still be named
+// in order to look at them or bring them back
+func productIDs(m *idMap) error {
+	products, err := API.GetAllHardwareProducts()
 	if err != nil {
-		return id, err
+		return err
 	}
 
-	re := regexp.MustCompile(fmt.Sprintf("^%s-", wat))
-	for _, r := range d {
-		if (r.Name == wat) || (r.SKU == wat) || re.MatchString(r.ID.String()) {
-			return r.ID, nil
-		}
+	for _, p := range products {
+		m.add(p.Name, p.ID.String())
+		m.add(p.SKU, p.ID.String())
+		m.add(p.ID.String(), p.ID.String())
 	}
-
-	return id, errors.New("Could not find product " + wat)
+	return nil
 }
 
 // MagicValidationID takes a string and tries to find a valid UUID. If the
@@ -208,52 +248,45 @@ func MagicDatacenterID(wat string) (uuid.UUID, error) {
 // MagicRoomID takes a string and tries to find a valid global UUID.  If
 // the string is a UUID, it doesn't get checked further.  If it's not a UUID,
 // we dig through GetRooms() looking for UUIDs that match up to the first
-// hyphen.
+// hyphen or where the room alias matches the string. The results of that dig
+// are cached locally for IDCacheTTL.
 func MagicRoomID(wat string) (uuid.UUID, error) {
-	id, err := uuid.FromString(wat)
-	if err == nil {
-		return id, err
-	}
-
-	// So, it's not a UUID. Let's try for a partial UUID
-	ds, err := API.GetRooms()
-	if err != nil {
-		return id, err
-	}
-
-	re := regexp.MustCompile(fmt.Sprintf("^%s-", wat))
-	for _, d := range ds {
-		if re.MatchString(d.ID.String()) {
-			return d.ID, nil
+	return magicCachedUUID("rooms", "room", wat, func(m *idMap) error {
+		rooms, err := API.GetRooms()
+		if err != nil {
+			return err
 		}
-	}
 
-	return id, errors.New("Could not find room " + wat)
+		for _, r := range rooms {
+			m.add(r.ID.String(), r.ID.String())
+		}
+		for _, r := range rooms {
+			m.addUnique(r.Alias, r.ID.String())
+		}
+		return nil
+	})
 }
 
 // MagicRackRoleID takes a string and tries to find a valid UUID. If the
 // string is a UUID, it doesn't get checked further. If not, we dig through
 // GetRackRoles() looking for UUIDs that match up to the first hyphen or
-// where the role name matches the string
+// where the role name matches the string. The results of that dig are cached
+// locally for IDCacheTTL.
 func MagicRackRoleID(wat string) (id uuid.UUID, err error) {
-	id, err = uuid.FromString(wat)
-	if err == nil {
-		return id, err
-	}
-	// So, it's not a UUID. Let's try for a string name or partial UUID
-	ret, err := API.GetRackRoles()
+	return magicCachedUUID("rack_roles", "rack role", wat, rackRoleIDs)
+}
+
+func rackRoleIDs(m *idMap) error {
+	roles, err := API.GetRackRoles()
 	if err != nil {
-		return id, err
+		return err
 	}
 
-	re := regexp.MustCompile(fmt.Sprintf("^%s-", wat))
-	for _, r := range ret {
-		if (r.Name == wat) || re.MatchString(r.ID.String()) {
-			return r.ID, nil
-		}
+	for _, r := range roles {
+		m.add(r.Name, r.ID.String())
+		m.add(r.ID.String(), r.ID.String())
 	}
-
-	return id, errors.New("Could not find rack role " + wat)
+	return nil
 }
 
 // MagicRackLayoutSlotID takes a string and tries to find a valid UUID.
@@ -281,3 +314,87 @@ func MagicRackLayoutSlotID(wat string) (uuid.UUID, error) {
 
 	return id, errors.New("Could not find rack layout " + wat)
 }
+
+// MagicDeviceID takes a serial, hostname, asset tag, or alias and tries to
+// find the device's serial. Aliases are the ones set with 'conch alias set'
+// in the active profile's workspace, and win over everything else. Hostnames
+// and asset tags are resolved against the devices in that workspace, which
+// are cached locally for IDCacheTTL. Since hostnames and asset tags move
+// between devices, eg with 'conch device replace', a cached match is checked
+// against the device before it is trusted. Anything that can't be resolved,
+// including when the workspace's devices can't be listed, is assumed to
+// already be a serial.
+func MagicDeviceID(wat string) (string, error) {
+	if ActiveProfile == nil || uuid.Equal(ActiveProfile.WorkspaceUUID, uuid.UUID{}) {
+		return wat, nil
+	}
+	workspace := ActiveProfile.WorkspaceUUID
+
+	if serial, ok := DeviceAliases(workspace)[wat]; ok {
+		return serial, nil
+	}
+
+	kind := "devices:" + workspace.String()
+	refresh := idMapRefresh(deviceIDs(workspace))
+
+	serial, err := cachedID(kind, wat, false, refresh)
+	if err != nil {
+		return literalSerial(wat, err), nil
+	}
+	if serial == "" || serial == wat {
+		return wat, nil
+	}
+
+	// A match from this run's listing is current
+	if idCacheRefreshed[kind] {
+		return serial, nil
+	}
+
+	d, err := API.GetDevice(serial)
+	if err == nil && (d.Hostname == wat || d.AssetTag == wat) {
+		return serial, nil
+	}
+
+	forgetIDs(kind)
+	serial, err = cachedID(kind, wat, false, refresh)
+	if err != nil {
+		return literalSerial(wat, err), nil
+	}
+	if serial == "" {
+		return wat, nil
+	}
+	return serial, nil
+}
+
+// literalSerial is what MagicDeviceID falls back on when the workspace's
+// devices can't be listed: what it was given, as a serial. Commands given an
+// exact serial shouldn't stop working because the listing is slow or broken.
+func literalSerial(wat string, err error) string {
+	fmt.Fprintf(
+		os.Stderr,
+		"Warning: the workspace's devices couldn't be listed, so '%s' is taken to be a serial: %s\n",
+		wat,
+		err,
+	)
+	return wat
+}
+
+// deviceIDs maps the serials, hostnames, and asset tags of the devices in a
+// workspace to their serials
+func deviceIDs(workspace uuid.UUID) func(m *idMap) error {
+	return func(m *idMap) error {
+		devices, err := API.GetWorkspaceDevices(workspace, false, "", "", "")
+		if err != nil {
+			return err
+		}
+
+		for _, d := range devices {
+			m.add(d.ID, d.ID)
+		}
+		for _, d := range devices {
+			m.addUnique(d.Hostname, d.ID)
+			m.addUnique(d.AssetTag, d.ID)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dghubble/sling"
+	cli "github.com/jawher/mow.cli"
+)
+
+// NotifyField is a short key/value pair in a NotifyAttachment
+type NotifyField struct {
+	Short bool   `json:"short,omitempty"`
+	Title string `json:"title,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// NotifyAttachment is a message attachment, as understood by both Mattermost
+// and Slack incoming webhooks
+type NotifyAttachment struct {
+	Pretext  string        `json:"pretext,omitempty"`
+	Text     string        `json:"text,omitempty"`
+	Title    string        `json:"title,omitempty"`
+	Color    string        `json:"color,omitempty"`
+	Fallback string        `json:"fallback,omitempty"`
+	Fields   []NotifyField `json:"fields,omitempty"`
+}
+
+// NotifyPayload is the body of an incoming webhook request
+type NotifyPayload struct {
+	Text        string             `json:"text,omitempty"`
+	Attachments []NotifyAttachment `json:"attachments,omitempty"`
+}
+
+// SendNotification posts the payload to a Mattermost or Slack incoming webhook
+func SendNotification(webhook string, payload NotifyPayload) error {
+	res, err := sling.New().Set("User-Agent", UserAgent).
+		Post(webhook).
+		BodyJSON(payload).
+		ReceiveSuccess(nil)
+
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("notification webhook returned %s", res.Status)
+	}
+	return nil
+}
+
+// NotifyWebhook returns the webhook for the active profile, falling back to
+// the CONCH_NOTIFY_WEBHOOK environment variable
+func NotifyWebhook() string {
+	if ActiveProfile != nil && ActiveProfile.NotifyWebhook != "" {
+		return ActiveProfile.NotifyWebhook
+	}
+	return os.Getenv("CONCH_NOTIFY_WEBHOOK")
+}
+
+// notifier tracks a single command that asked for --notify
+type notifier struct {
+	what    string
+	webhook string
+	started time.Time
+}
+
+var activeNotifier *notifier
+
+// StartNotifier arranges for a notification to be sent when the current
+// command finishes via FinishNotifier or dies via Bail
+func StartNotifier(what string) {
+	webhook := NotifyWebhook()
+	if webhook == "" {
+		Bail(errors.New("--notify requires a webhook. Use 'profile set notify' or set CONCH_NOTIFY_WEBHOOK"))
+	}
+
+	activeNotifier = &notifier{
+		what:    what,
+		webhook: webhook,
+		started: time.Now(),
+	}
+}
+
+// FinishNotifier sends the pending notification, if there is one. A nil error
+// reports success.
+func FinishNotifier(err error) {
+	n := activeNotifier
+	if n == nil {
+		return
+	}
+	activeNotifier = nil
+
+	elapsed := time.Since(n.started).Round(time.Second)
+
+	attachment := NotifyAttachment{
+		Color: "#00AA00",
+		Title: fmt.Sprintf("conch %s finished", n.what),
+		Fields: []NotifyField{
+			{Short: true, Title: "Duration", Value: elapsed.String()},
+		},
+	}
+
+	if ActiveProfile != nil {
+		attachment.Fields = append(attachment.Fields, NotifyField{
+			Short: true,
+			Title: "Profile",
+			Value: ActiveProfile.Name,
+		})
+	}
+	if err != nil {
+		attachment.Color = "#FF0000"
+		attachment.Title = fmt.Sprintf("conch %s failed", n.what)
+		attachment.Text = err.Error()
+	}
+	attachment.Fallback = attachment.Title
+
+	if err := SendNotification(n.webhook, NotifyPayload{
+		Attachments: []NotifyAttachment{attachment},
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to send notification: %s\n", err)
+	}
+}
+
+// NotifyOpt adds a --notify option to a command. It must be called after the
+// command's own Before and After, if any, have been set.
+func NotifyOpt(cmd *cli.Cmd, what string) {
+	notify := cmd.BoolOpt("notify", false, "Send a message to the notification webhook when this command finishes or fails")
+
+	before := cmd.Before
+	cmd.Before = func() {
+		if before != nil {
+			before()
+		}
+		if *notify {
+			StartNotifier(what)
+		}
+	}
+
+	after := cmd.After
+	cmd.After = func() {
+		if after != nil {
+			after()
+		}
+		FinishNotifier(nil)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/config"
+)
+
+// OutputPlugin is set by the global --output-plugin option. When set, JSON
+// output is piped through the plugin instead of being printed.
+var OutputPlugin *config.OutputPlugin
+
+// OutputPluginName is the name OutputPlugin is registered under
+var OutputPluginName string
+
+// OutputPluginInput is what an output plugin reads on STDIN
+type OutputPluginInput struct {
+	Plugin    string          `json:"plugin"`
+	Command   []string        `json:"command"`
+	Profile   string          `json:"profile,omitempty"`
+	API       string          `json:"api,omitempty"`
+	Workspace string          `json:"workspace,omitempty"`
+	Version   string          `json:"version"`
+	Generated time.Time       `json:"generated"`
+	Result    json.RawMessage `json:"result"`
+}
+
+// OutputPluginNames lists the output plugins in the config, sorted
+func OutputPluginNames() []string {
+	names := make([]string, 0)
+	if Config == nil {
+		return names
+	}
+	for name := range Config.OutputPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseOutputPlugin looks up the named output plugin in the config and routes
+// all output through it. Commands are switched to JSON output, since that is
+// what plugins consume.
+func UseOutputPlugin(name string) error {
+	var p *config.OutputPlugin
+	if Config != nil {
+		p = Config.OutputPlugins[name]
+	}
+	if p == nil {
+		known := OutputPluginNames()
+		if len(known) == 0 {
+			return fmt.Errorf("no output plugin named '%s'. See 'conch output-plugins add'", name)
+		}
+		return fmt.Errorf(
+			"no output plugin named '%s'. Known plugins: %s",
+			name,
+			strings.Join(known, ", "),
+		)
+	}
+	if len(p.Command) == 0 {
+		return fmt.Errorf("output plugin '%s' has no command", name)
+	}
+
+	OutputPlugin = p
+	OutputPluginName = name
+	JSON = true
+	return nil
+}
+
+// runOutputPlugin hands a command's JSON result to the output plugin. The
+// plugin's STDOUT and STDERR are the shell's.
+func runOutputPlugin(j []byte) error {
+	in := OutputPluginInput{
+		Plugin:    OutputPluginName,
+		Command:   redactArgs(Args),
+		Version:   Version,
+		Generated: time.Now().UTC(),
+		Result:    json.RawMessage(j),
+	}
+	if ActiveProfile != nil {
+		in.Profile = ActiveProfile.Name
+		in.Workspace = ActiveProfile.WorkspaceName
+	}
+	if API != nil {
+		in.API = API.BaseURL
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(OutputPlugin.Command[0], OutputPlugin.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// A plugin that runs conch itself must not end up back here
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "CONCH_OUTPUT_PLUGIN=") {
+			cmd.Env = append(cmd.Env, e)
+		}
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("output plugin '%s': %s", OutputPluginName, err)
+	}
+	return nil
+}
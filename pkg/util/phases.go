@@ -0,0 +1,86 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+
+	cli "github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/conch"
+)
+
+// RackPhaseOpts are the options of the commands that move many racks to a
+// new phase at once
+type RackPhaseOpts struct {
+	Phase         *string
+	DevicesAlso   *bool
+	RacksMatching *string
+	PlanOpts
+}
+
+// NewRackPhaseOpts adds PHASE, --devices-also, --racks-matching, --dry-run,
+// and --yes to a command
+func NewRackPhaseOpts(cmd *cli.Cmd) RackPhaseOpts {
+	opts := RackPhaseOpts{
+		Phase:         cmd.StringArg("PHASE", "", "The desired phase"),
+		DevicesAlso:   cmd.BoolOpt("devices-also", false, "Also set every device in the racks to the same phase"),
+		RacksMatching: cmd.StringOpt("racks-matching", "", "Only change racks whose names match this glob, eg 'A1*'"),
+		PlanOpts:      NewPlanOpts(cmd),
+	}
+	cmd.Spec = "[OPTIONS] PHASE [OPTIONS]"
+	return opts
+}
+
+// PlanRackPhases adds a change to the plan for every rack that matches the
+// options. Racks already in the phase are left out, unless their devices are
+// being moved too, since the devices may not have followed.
+func PlanRackPhases(plan *Plan, racks []conch.Rack, opts RackPhaseOpts) error {
+	if *opts.Phase == "" {
+		return errors.New("a phase is required")
+	}
+	if *opts.RacksMatching != "" {
+		if _, err := path.Match(*opts.RacksMatching, ""); err != nil {
+			return fmt.Errorf("bad --racks-matching pattern '%s': %s", *opts.RacksMatching, err)
+		}
+	}
+
+	sorted := make([]conch.Rack, len(racks))
+	copy(sorted, racks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return NaturalLess(sorted[i].Name, sorted[j].Name)
+	})
+
+	phase := *opts.Phase
+	withDevices := *opts.DevicesAlso
+	for _, r := range sorted {
+		if *opts.RacksMatching != "" {
+			if ok, _ := path.Match(*opts.RacksMatching, r.Name); !ok {
+				continue
+			}
+		}
+		if r.Phase == phase && !withDevices {
+			continue
+		}
+
+		detail := r.Phase + " -> " + phase
+		if r.Phase == phase {
+			detail = phase
+		}
+		if withDevices {
+			detail += ", with its devices"
+		}
+
+		id := r.ID
+		plan.Add(PlanUpdate, "rack phase", r.Name, detail, func() error {
+			return API.SetRackPhase(id, phase, withDevices)
+		})
+	}
+	return nil
+}
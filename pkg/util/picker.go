@@ -0,0 +1,258 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+)
+
+// NoPickerEnv, when set to anything, stops the shell from offering a picker
+// when a command is missing an ID
+const NoPickerEnv = "CONCH_NO_PICKER"
+
+// pickerShown is how many matches the picker lists at once
+const pickerShown = 15
+
+// ErrNothingPicked is returned when the user leaves the picker without
+// choosing anything
+var ErrNothingPicked = errors.New("nothing was picked")
+
+// PickItem is one of the things a picker offers
+type PickItem struct {
+	// ID is what is returned when the item is picked
+	ID string
+
+	// Names are what the item is also known as, eg a workspace's name or a
+	// device's hostname and asset tag
+	Names []string
+}
+
+func (p PickItem) label() string {
+	if len(p.Names) == 0 {
+		return p.ID
+	}
+	return strings.Join(p.Names, ", ") + "  " + p.ID
+}
+
+// CanPick reports whether there is somebody at a terminal who could pick
+// something
+func CanPick() bool {
+	if os.Getenv(NoPickerEnv) != "" || Replaying() {
+		return false
+	}
+	return IsTerminal(os.Stdin) && IsTerminal(os.Stderr)
+}
+
+// fuzzyScore scores how well s matches query, fzf style: every word of the
+// query has to appear in s in order, though not necessarily together. Letters
+// that follow each other, or start a word, score higher. The second value is
+// false if s doesn't match at all.
+func fuzzyScore(query string, s string) (int, bool) {
+	s = strings.ToLower(s)
+	total := 0
+
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		score := 0
+		pos := 0
+		last := -2
+		for _, r := range word {
+			i := strings.IndexRune(s[pos:], r)
+			if i < 0 {
+				return 0, false
+			}
+			i += pos
+
+			score++
+			if i == last+1 {
+				score += 5
+			}
+			if i == 0 || strings.ContainsAny(s[i-1:i], " -_./,") {
+				score += 3
+			}
+
+			last = i
+			pos = i + len(string(r))
+		}
+		total += score
+	}
+
+	// Of two equally good matches, the shorter is the closer
+	return total*1000 - len(s), true
+}
+
+// fuzzyFilter lists the items that match query, best first
+func fuzzyFilter(query string, items []PickItem) []PickItem {
+	type scored struct {
+		item  PickItem
+		label string
+		score int
+	}
+
+	matches := make([]scored, 0, len(items))
+	for _, item := range items {
+		label := item.label()
+		if score, ok := fuzzyScore(query, label); ok {
+			matches = append(matches, scored{item, label, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].label < matches[j].label
+	})
+
+	out := make([]PickItem, 0, len(matches))
+	for _, m := range matches {
+		out = append(out, m.item)
+	}
+	return out
+}
+
+// Pick asks the user to choose one of items, on STDERR, and returns its ID.
+// The user narrows the list down by typing part of what they are after, and
+// chooses by number, or takes the best match with a blank line. what names
+// the items, eg "workspace".
+func Pick(what string, items []PickItem) (string, error) {
+	if len(items) == 0 {
+		return "", fmt.Errorf("there are no %ss to pick from", what)
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].label() < items[j].label() })
+
+	in := bufio.NewReader(os.Stdin)
+	query := ""
+	matches := items
+
+	fmt.Fprintf(
+		os.Stderr,
+		"No %s was given. Type to narrow the list down, then pick one by number or press enter for the first. ^D gives up.\n",
+		what,
+	)
+
+	for {
+		fmt.Fprintln(os.Stderr)
+		shown := matches
+		if len(shown) > pickerShown {
+			shown = shown[:pickerShown]
+		}
+		for i, m := range shown {
+			fmt.Fprintf(os.Stderr, "%3d) %s\n", i+1, m.label())
+		}
+		if len(matches) > len(shown) {
+			fmt.Fprintf(os.Stderr, "     ... and %d more\n", len(matches)-len(shown))
+		}
+
+		fmt.Fprintf(os.Stderr, "%s> %s", what, query)
+		line, err := in.ReadString('\n')
+		if err == io.EOF && line == "" {
+			fmt.Fprintln(os.Stderr)
+			return "", ErrNothingPicked
+		} else if err != nil && err != io.EOF {
+			return "", err
+		}
+		typed := strings.TrimSpace(line)
+
+		if typed == "" {
+			return shown[0].ID, nil
+		}
+		if n, err := strconv.Atoi(typed); err == nil && n >= 1 && n <= len(shown) {
+			return shown[n-1].ID, nil
+		}
+
+		// What is typed narrows down the list further
+		narrowed := fuzzyFilter(query+typed, items)
+		if len(narrowed) == 0 {
+			fmt.Fprintf(os.Stderr, "Nothing matches '%s'\n", typed)
+			continue
+		}
+		query = strings.TrimSpace(query+" "+typed) + " "
+		matches = narrowed
+	}
+}
+
+// pickItems turns cached IDs, which map names to IDs, into things to pick
+func pickItems(ids map[string]string) []PickItem {
+	names := make(map[string][]string)
+	for name, id := range ids {
+		if _, ok := names[id]; !ok {
+			names[id] = make([]string, 0)
+		}
+		if name != id {
+			names[id] = append(names[id], name)
+		}
+	}
+
+	items := make([]PickItem, 0, len(names))
+	for id, n := range names {
+		sort.Strings(n)
+		items = append(items, PickItem{ID: id, Names: n})
+	}
+	return items
+}
+
+// pickCached offers the cached IDs of one kind
+func pickCached(kind string, what string, fill func(m *idMap) error) (string, error) {
+	ids, err := cachedIDs(kind, idMapRefresh(fill))
+	if err != nil {
+		return "", err
+	}
+	return Pick(what, pickItems(ids))
+}
+
+// PickWorkspaceID asks the user to pick one of their workspaces
+func PickWorkspaceID() (uuid.UUID, error) {
+	id, err := pickCached("workspaces", "workspace", workspaceIDs)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return uuid.FromString(id)
+}
+
+// PickRackID asks the user to pick a rack
+func PickRackID() (uuid.UUID, error) {
+	id, err := pickCached("racks", "rack", rackIDs)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return uuid.FromString(id)
+}
+
+// PickDeviceID asks the user to pick a device in the active profile's
+// workspace, or one of its aliases, and returns its serial
+func PickDeviceID() (string, error) {
+	if ActiveProfile == nil || uuid.Equal(ActiveProfile.WorkspaceUUID, uuid.UUID{}) {
+		return "", errors.New("no workspace was found in the active profile to pick a device from")
+	}
+	workspace := ActiveProfile.WorkspaceUUID
+
+	cached, err := cachedIDs("devices:"+workspace.String(), idMapRefresh(deviceIDs(workspace)))
+	if err != nil {
+		return "", err
+	}
+
+	// Aliases are the names people know the devices by
+	ids := make(map[string]string, len(cached))
+	for name, id := range cached {
+		ids[name] = id
+	}
+	for alias, serial := range DeviceAliases(workspace) {
+		ids[alias] = serial
+	}
+
+	return Pick("device", pickItems(ids))
+}
@@ -0,0 +1,228 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Bowery/prompt"
+	cli "github.com/jawher/mow.cli"
+)
+
+// Plan actions
+const (
+	PlanCreate = "create"
+	PlanUpdate = "update"
+	PlanDelete = "delete"
+)
+
+// PlanChange is a single step in a Plan. Nothing happens until Do is called.
+type PlanChange struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Detail string `json:"detail,omitempty"`
+	Done   bool   `json:"done"`
+	Error  string `json:"error,omitempty"`
+
+	Do func() error `json:"-"`
+}
+
+// Plan is an ordered change set, computed up front by import style commands
+// so that the user can see what is about to happen before anything does
+type Plan struct {
+	Changes []*PlanChange
+}
+
+// PlanOpts are the options every command that executes a Plan should offer
+type PlanOpts struct {
+	DryRun *bool
+	Yes    *bool
+}
+
+// NewPlanOpts adds --dry-run and --yes to a command
+func NewPlanOpts(cmd *cli.Cmd) PlanOpts {
+	return PlanOpts{
+		DryRun: cmd.BoolOpt("dry-run", false, "Show the changes that would be made, without making them"),
+		Yes:    cmd.BoolOpt("yes y", false, "Make the changes without asking for confirmation"),
+	}
+}
+
+// NewPlan returns an empty Plan
+func NewPlan() *Plan {
+	return &Plan{Changes: make([]*PlanChange, 0)}
+}
+
+// Add appends a change to the plan
+func (p *Plan) Add(action string, kind string, name string, detail string, do func() error) {
+	p.Changes = append(p.Changes, &PlanChange{
+		Action: action,
+		Kind:   kind,
+		Name:   name,
+		Detail: detail,
+		Do:     do,
+	})
+}
+
+// Counts returns the number of changes for each action
+func (p *Plan) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, c := range p.Changes {
+		counts[c.Action]++
+	}
+	return counts
+}
+
+// Summary is a one line description of the plan
+func (p *Plan) Summary() string {
+	counts := p.Counts()
+	return fmt.Sprintf(
+		"%d to create, %d to update, %d to delete",
+		counts[PlanCreate],
+		counts[PlanUpdate],
+		counts[PlanDelete],
+	)
+}
+
+// Render prints the plan as a table, followed by the summary
+func (p *Plan) Render() {
+	table := GetMarkdownTable()
+	table.SetHeader([]string{"Action", "Kind", "Name", "Detail"})
+	for _, c := range p.Changes {
+		table.Append([]string{c.Action, c.Kind, c.Name, c.Detail})
+	}
+	table.Render()
+
+	fmt.Printf("\n%s\n", p.Summary())
+}
+
+// confirm asks the user whether to go ahead
+func (p *Plan) confirm() bool {
+	return Confirm("Make these changes?")
+}
+
+// Confirm asks the user a yes or no question. If STDIN is not a terminal,
+// there is nobody to ask and scripts get the behavior they have always had.
+func Confirm(question string) bool {
+	if !IsTerminal(os.Stdin) {
+		return true
+	}
+
+	ok, err := prompt.Ask(question)
+	if err != nil {
+		Bail(err)
+	}
+	return ok
+}
+
+// Execute runs every change in order, reporting progress on stderr, and stops
+// at the first failure. If the user hits ^C, it stops before the next change
+// and returns ErrInterrupted.
+func (p *Plan) Execute() error {
+	done := Stoppable()
+	defer done()
+
+	progress := StartProgress("plan", len(p.Changes))
+	defer progress.Finish()
+
+	for i, c := range p.Changes {
+		if Interrupted() {
+			return ErrInterrupted
+		}
+		if !JSON {
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s %s %s\n", i+1, len(p.Changes), c.Action, c.Kind, c.Name)
+		}
+
+		item := fmt.Sprintf("%s %s %s", c.Action, c.Kind, c.Name)
+		if err := c.Do(); err != nil {
+			c.Error = err.Error()
+			progress.Fail(item, err)
+			return fmt.Errorf(
+				"%s %s %s failed after %d of %d changes: %s",
+				c.Action,
+				c.Kind,
+				c.Name,
+				i,
+				len(p.Changes),
+				err,
+			)
+		}
+		c.Done = true
+		progress.Step(item)
+	}
+	return nil
+}
+
+// Run is the standard flow for a command with a Plan: show it, stop there for
+// --dry-run, ask for confirmation unless --yes, then execute it and summarize
+// the results
+func (p *Plan) Run(opts PlanOpts) {
+	if len(p.Changes) == 0 {
+		if JSON {
+			JSONOut(p.Changes)
+		} else {
+			fmt.Println("Nothing to do")
+		}
+		return
+	}
+
+	if JSON {
+		if *opts.DryRun {
+			JSONOut(p.Changes)
+			return
+		}
+	} else {
+		p.Render()
+		if *opts.DryRun {
+			return
+		}
+		fmt.Println()
+	}
+
+	if !*opts.Yes && !JSON && !p.confirm() {
+		Bail(errors.New("no changes were made"))
+	}
+
+	CheckRequestBudget(len(p.Changes), fmt.Sprintf("Making %d changes", len(p.Changes)))
+
+	if err := p.Execute(); err != nil {
+		if err == ErrInterrupted {
+			p.interrupted()
+		}
+		Bail(err)
+	}
+
+	if JSON {
+		JSONOut(p.Changes)
+		return
+	}
+	fmt.Printf("Done. %d changes made\n", len(p.Changes))
+}
+
+// interrupted reports how far an interrupted plan got, then exits
+func (p *Plan) interrupted() {
+	made := 0
+	for _, c := range p.Changes {
+		if c.Done {
+			made++
+		}
+	}
+
+	if JSON {
+		JSONOut(p.Changes)
+	} else {
+		fmt.Fprintf(
+			os.Stderr,
+			"Interrupted. %d of %d changes made; the rest were not attempted\n",
+			made,
+			len(p.Changes),
+		)
+	}
+	Exit(InterruptExitCode)
+}
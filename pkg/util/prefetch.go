@@ -0,0 +1,44 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+// prefetchKinds are the collections that almost every name lookup ends up
+// needing, along with how to list them
+var prefetchKinds = []struct {
+	kind string
+	fill func(m *idMap) error
+}{
+	{"products", productIDs},
+	{"rack_roles", rackRoleIDs},
+	{"workspaces", workspaceIDs},
+}
+
+// PrefetchIDs lists the hardware product catalogue, the rack roles, and the
+// workspaces all at once and stores them in the local ID cache, so that
+// later commands resolve those names without going to the API
+func PrefetchIDs() error {
+	results := make([]map[string]string, len(prefetchKinds))
+
+	err := parallel(len(prefetchKinds), func(i int) error {
+		m := newIDMap()
+		if err := prefetchKinds[i].fill(m); err != nil {
+			return err
+		}
+		results[i] = m.finish()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, p := range prefetchKinds {
+		storeIDs(p.kind, results[i])
+	}
+	saveIDCache()
+
+	return nil
+}
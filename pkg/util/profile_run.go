@@ -0,0 +1,212 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+)
+
+// ProfileRunEnv turns on --profile-run, for when the command line can't be
+// changed
+const ProfileRunEnv = "CONCH_PROFILE_RUN"
+
+// profileRunSlowest is how many of the slowest calls --profile-run lists
+const profileRunSlowest = 5
+
+// apiCall is a single request timed by --profile-run
+type apiCall struct {
+	method   string
+	path     string
+	endpoint string
+	status   int
+	took     time.Duration
+}
+
+// runProfile collects the API calls of the command being run. It is nil
+// unless --profile-run is on.
+var runProfile *profiledRun
+
+type profiledRun struct {
+	sync.Mutex
+	started time.Time
+	calls   []apiCall
+}
+
+// StartRunProfile starts timing the command and its API calls, for
+// --profile-run
+func StartRunProfile() {
+	runProfile = &profiledRun{started: time.Now(), calls: make([]apiCall, 0)}
+}
+
+// endpointIDs matches the parts of a URL path that name a single object, so
+// that calls to eg /rack/:id are counted together whatever the rack
+var endpointIDs = regexp.MustCompile(`^([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9]+)$`)
+
+// endpoint is the path of a call with the IDs taken out
+func endpoint(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if endpointIDs.MatchString(p) {
+			parts[i] = ":id"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// profileTransport times every request that goes out through next, for
+// --profile-run. Requests answered from the memoization cache never get this
+// far, so they aren't counted.
+type profileTransport struct {
+	next http.RoundTripper
+}
+
+func (t profileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = conch.DefaultTransport()
+	}
+
+	start := time.Now()
+	res, err := next.RoundTrip(req)
+	took := time.Since(start)
+
+	call := apiCall{
+		method:   req.Method,
+		path:     req.URL.Path,
+		endpoint: endpoint(req.URL.Path),
+		took:     took,
+	}
+	if res != nil {
+		call.status = res.StatusCode
+	}
+
+	if p := runProfile; p != nil {
+		p.Lock()
+		p.calls = append(p.calls, call)
+		p.Unlock()
+	}
+	return res, err
+}
+
+// apiTransport is the transport for every API built during the run: the one
+// that records or replays a session, if there is one, timed for
+// --profile-run
+func apiTransport() http.RoundTripper {
+	t := harTransport()
+	if runProfile == nil {
+		return t
+	}
+	return profileTransport{next: t}
+}
+
+// PrintRunProfile prints, on STDERR, how many API calls the command made,
+// the time spent on each endpoint, and the slowest calls. It is called once
+// the command is done, whether it worked or not.
+func PrintRunProfile() {
+	p := runProfile
+	if p == nil {
+		return
+	}
+	runProfile = nil
+
+	p.Lock()
+	defer p.Unlock()
+
+	elapsed := time.Since(p.started)
+
+	type endpointTotal struct {
+		name  string
+		calls int
+		total time.Duration
+		max   time.Duration
+	}
+	byEndpoint := make(map[string]*endpointTotal)
+	var inAPI time.Duration
+	for _, c := range p.calls {
+		name := c.method + " " + c.endpoint
+		e, ok := byEndpoint[name]
+		if !ok {
+			e = &endpointTotal{name: name}
+			byEndpoint[name] = e
+		}
+		e.calls++
+		e.total += c.took
+		if c.took > e.max {
+			e.max = c.took
+		}
+		inAPI += c.took
+	}
+
+	totals := make([]*endpointTotal, 0, len(byEndpoint))
+	for _, e := range byEndpoint {
+		totals = append(totals, e)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].total != totals[j].total {
+			return totals[i].total > totals[j].total
+		}
+		return totals[i].name < totals[j].name
+	})
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(
+		w,
+		"Run profile: %d API calls in %s, %s of it waiting on the API\n",
+		len(p.calls),
+		round(elapsed),
+		round(inAPI),
+	)
+	if len(p.calls) == 0 {
+		w.Flush()
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "ENDPOINT\tCALLS\tTOTAL\tAVERAGE\tSLOWEST")
+	for _, e := range totals {
+		fmt.Fprintf(
+			w,
+			"%s\t%d\t%s\t%s\t%s\n",
+			e.name,
+			e.calls,
+			round(e.total),
+			round(e.total/time.Duration(e.calls)),
+			round(e.max),
+		)
+	}
+
+	slowest := make([]apiCall, len(p.calls))
+	copy(slowest, p.calls)
+	sort.SliceStable(slowest, func(i, j int) bool { return slowest[i].took > slowest[j].took })
+	if len(slowest) > profileRunSlowest {
+		slowest = slowest[:profileRunSlowest]
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "SLOWEST CALLS\tSTATUS\tTOOK")
+	for _, c := range slowest {
+		status := "-"
+		if c.status != 0 {
+			status = fmt.Sprintf("%d", c.status)
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\n", c.method, c.path, status, round(c.took))
+	}
+	w.Flush()
+}
@@ -0,0 +1,111 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Progress event types
+const (
+	ProgressStarted   = "started"
+	ProgressStep      = "progress"
+	ProgressCompleted = "completed"
+)
+
+// ProgressEvent is a line of JSON written to STDERR during a long operation
+// in --json mode, so that whatever is wrapping the shell can show progress,
+// and notice when it stops coming
+type ProgressEvent struct {
+	Event     string    `json:"event"`
+	Operation string    `json:"operation"`
+	Done      int       `json:"done"`
+	Failed    int       `json:"failed"`
+	Total     int       `json:"total"`
+	Item      string    `json:"item,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+	Elapsed   float64   `json:"elapsed_seconds"`
+}
+
+// Progress tracks a long operation made of a known number of steps. It only
+// says anything in --json mode; commands print their own progress for
+// people. It is safe to use from several goroutines.
+type Progress struct {
+	sync.Mutex
+	operation string
+	total     int
+	done      int
+	failed    int
+	started   time.Time
+	finished  bool
+}
+
+// StartProgress begins an operation of total steps
+func StartProgress(operation string, total int) *Progress {
+	p := &Progress{
+		operation: operation,
+		total:     total,
+		started:   time.Now(),
+	}
+	p.emit(ProgressStarted, "", "")
+	return p
+}
+
+// Step records that one step, named by item, succeeded
+func (p *Progress) Step(item string) {
+	p.Lock()
+	defer p.Unlock()
+	p.done++
+	p.emit(ProgressStep, item, "")
+}
+
+// Fail records that one step, named by item, failed
+func (p *Progress) Fail(item string, err error) {
+	p.Lock()
+	defer p.Unlock()
+	p.failed++
+	p.emit(ProgressStep, item, err.Error())
+}
+
+// Finish ends the operation. It is safe to call more than once, so that it
+// can be deferred as well as called when the operation stops early.
+func (p *Progress) Finish() {
+	p.Lock()
+	defer p.Unlock()
+	if p.finished {
+		return
+	}
+	p.finished = true
+	p.emit(ProgressCompleted, "", "")
+}
+
+func (p *Progress) emit(event string, item string, errMsg string) {
+	if !JSON {
+		return
+	}
+
+	now := time.Now()
+	j, err := json.Marshal(ProgressEvent{
+		Event:     event,
+		Operation: p.operation,
+		Done:      p.done,
+		Failed:    p.failed,
+		Total:     p.total,
+		Item:      item,
+		Error:     errMsg,
+		Time:      now.UTC(),
+		Elapsed:   now.Sub(p.started).Seconds(),
+	})
+	if err != nil {
+		return
+	}
+	_, _ = os.Stderr.Write(append(j, '\n'))
+}
@@ -0,0 +1,67 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/config"
+)
+
+// RoomLimits is what the active profile allows a single rack in the room to
+// weigh and draw. A room without limits gets zeroes, which are no limit.
+func RoomLimits(roomID uuid.UUID) config.RoomLimits {
+	if ActiveProfile == nil || ActiveProfile.RoomLimits == nil {
+		return config.RoomLimits{}
+	}
+	l, ok := ActiveProfile.RoomLimits[roomID.String()]
+	if !ok || l == nil {
+		return config.RoomLimits{}
+	}
+	return *l
+}
+
+// SetRoomLimits stores the limits for racks in the room in the active
+// profile. Zero limits remove them. The config is not saved.
+func SetRoomLimits(roomID uuid.UUID, l config.RoomLimits) {
+	if l.MaxRackWeight == 0 && l.MaxRackPower == 0 {
+		delete(ActiveProfile.RoomLimits, roomID.String())
+		return
+	}
+	if ActiveProfile.RoomLimits == nil {
+		ActiveProfile.RoomLimits = make(map[string]*config.RoomLimits)
+	}
+	ActiveProfile.RoomLimits[roomID.String()] = &l
+}
+
+// WarnRackBudget warns on STDERR if a layout about to be given to the rack
+// would take it over the limits of its room. Layouts are still imported; the
+// limits are there for planning, and the API knows nothing of them.
+func WarnRackBudget(rack conch.Rack, layout conch.RackLayoutSlots, products []conch.HardwareProduct) {
+	limits := RoomLimits(rack.DatacenterRoomID)
+	if limits.MaxRackWeight == 0 && limits.MaxRackPower == 0 {
+		return
+	}
+
+	byID := make(map[uuid.UUID]conch.HardwareProduct)
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	b, err := conch.NewRackBudget(layout, byID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the rack's weight and power budget can't be worked out: %s\n", err)
+		return
+	}
+
+	for _, o := range b.Exceeds(limits.MaxRackWeight, limits.MaxRackPower) {
+		fmt.Fprintf(os.Stderr, "Warning: with this layout, rack %s %s set for its room\n", rack.Name, o)
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/config"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// ResultCacheDir is where --cache-ttl keeps the results of commands
+var ResultCacheDir = config.DataPath("results")
+
+// resultCacheMaxAge is how old a cached result can get before it is removed,
+// whatever TTL it was saved with
+const resultCacheMaxAge = 24 * time.Hour
+
+// cacheableCommands are the only commands whose results --cache-ttl reuses,
+// by CommandPath. A cached result means the command doesn't run at all, so
+// these must do nothing but read from the API and print. Commands that also
+// write local files, like 'index build' or 'workspace health-diff', or that
+// reach beyond the API, like 'update self', must never be listed.
+var cacheableCommands = map[string]bool{
+	"admin audit-duplicates":      true,
+	"admin audit-orphans":         true,
+	"admin user get":              true,
+	"admin user sessions":         true,
+	"admin user tokens":           true,
+	"admin users":                 true,
+	"component find":              true,
+	"datacenter get":              true,
+	"datacenter rooms":            true,
+	"datacenters get":             true,
+	"device bmc get":              true,
+	"device components":           true,
+	"device get":                  true,
+	"device hostname get":         true,
+	"device ipmi":                 true,
+	"device location":             true,
+	"device maintenance status":   true,
+	"device reports get":          true,
+	"device reports list":         true,
+	"device setting get":          true,
+	"device settings":             true,
+	"device tag get":              true,
+	"device tags":                 true,
+	"device ticket list":          true,
+	"device validation-plan get":  true,
+	"device validations":          true,
+	"device validations history":  true,
+	"devices search hostname":     true,
+	"devices search setting":      true,
+	"devices search tag":          true,
+	"global datacenter get":       true,
+	"global datacenter rooms":     true,
+	"global datacenters get":      true,
+	"global rack get":             true,
+	"global rack layout get":      true,
+	"global racks get":            true,
+	"global role get":             true,
+	"global roles get":            true,
+	"global room get":             true,
+	"global room racks":           true,
+	"global rooms get":            true,
+	"hardware product get":        true,
+	"hardware products get":       true,
+	"hardware vendor get":         true,
+	"hardware vendors":            true,
+	"rack assignments":            true,
+	"rack get":                    true,
+	"rack layout get":             true,
+	"rack maintenance status":     true,
+	"racks get":                   true,
+	"relays get":                  true,
+	"rma list":                    true,
+	"rma report":                  true,
+	"room get":                    true,
+	"room racks":                  true,
+	"rooms get":                   true,
+	"switch peers":                true,
+	"tickets open":                true,
+	"user profile":                true,
+	"user sessions":               true,
+	"user settings":               true,
+	"user tokens":                 true,
+	"validation-plan get":         true,
+	"validation-plan validations": true,
+	"validation-plans get":        true,
+	"validation-states device":    true,
+	"validations":                 true,
+	"workspace decommissioned":    true,
+	"workspace devices":           true,
+	"workspace get":               true,
+	"workspace intake-report":     true,
+	"workspace rack assignments":  true,
+	"workspace rack get":          true,
+	"workspace racks":             true,
+	"workspace relay devices":     true,
+	"workspace relays":            true,
+	"workspace settings find":     true,
+	"workspace subs":              true,
+	"workspace users":             true,
+	"workspaces":                  true,
+}
+
+// resultCache records the output of the current command, so that it can be
+// saved for the next run of the same command. It is nil unless --cache-ttl,
+// or the profile, asked for caching.
+var resultCache *cachedResult
+
+type cachedResult struct {
+	sync.Mutex
+	path      string
+	out       bytes.Buffer
+	mutated   bool
+	discarded bool
+}
+
+// resultCacheEnv are the environment variables, besides the CONCH_SORT_
+// ones, that change what a command prints
+var resultCacheEnv = []string{"CONCH_RAW_NUMBERS", "CONCH_SUMMARY", "LC_ALL", "LC_NUMERIC", "LANG"}
+
+// resultCacheKey names the cached result of a command. The same arguments
+// against the same profile, workspace, and API, with the same output
+// settings in the environment, give the same key. --cache-ttl itself is left
+// out, so that changing it doesn't throw the cache away.
+func resultCacheKey(args []string) string {
+	h := sha256.New()
+	if ActiveProfile != nil {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", ActiveProfile.Name, ActiveProfile.BaseURL, ActiveProfile.WorkspaceUUID)
+	} else {
+		fmt.Fprintf(h, "\x00%s\x00\x00", BaseURL)
+	}
+
+	env := make([]string, 0)
+	for _, name := range resultCacheEnv {
+		env = append(env, name+"="+os.Getenv(name))
+	}
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, "CONCH_SORT_") {
+			env = append(env, e)
+		}
+	}
+	sort.Strings(env)
+	for _, e := range env {
+		fmt.Fprintf(h, "%s\x00", e)
+	}
+
+	for i := 1; i < len(args); i++ {
+		switch {
+		case args[i] == "--cache-ttl":
+			i++
+			continue
+		case strings.HasPrefix(args[i], "--cache-ttl="):
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00", args[i])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// UseResultCache is called before a command runs, with the TTL from
+// --cache-ttl or the profile, and Args. If the same command saved a result
+// within the TTL, that result is printed and the shell exits without running
+// the command. Otherwise the command's result is recorded, to be saved by
+// SaveResult.
+//
+// Only JSON results of the commands in cacheableCommands are cached, and
+// never when they fail or, to be safe, when they turn out to change anything.
+// Batches and output plugins don't use the cache.
+func UseResultCache(ttl time.Duration, args []string) {
+	if ttl <= 0 || !JSON || BatchMode || OutputPlugin != nil {
+		return
+	}
+	if !cacheableCommands[CommandPath()] {
+		return
+	}
+
+	dir, err := homedir.Expand(ResultCacheDir)
+	if err != nil {
+		Bail(err)
+	}
+	path := filepath.Join(dir, resultCacheKey(args)+".json")
+
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < ttl {
+		if out, err := ioutil.ReadFile(path); err == nil {
+			if Debug {
+				fmt.Fprintf(os.Stderr, "Printing the result cached at %s\n", TimeStr(info.ModTime()))
+			}
+			os.Stdout.Write(out)
+			Exit(0)
+		}
+	}
+
+	resultCache = &cachedResult{path: path}
+}
+
+// startResultCache hooks the API up to the result cache, so that the results
+// of commands that change data aren't saved
+func startResultCache() {
+	if resultCache == nil {
+		return
+	}
+
+	next := API.BeforeRequest
+	API.BeforeRequest = func(method string, path string) error {
+		if method != "GET" && method != "HEAD" {
+			resultCache.Lock()
+			resultCache.mutated = true
+			resultCache.Unlock()
+		}
+		if next != nil {
+			return next(method, path)
+		}
+		return nil
+	}
+}
+
+// recordResult adds printed JSON to the result being cached
+func recordResult(out string) {
+	if resultCache == nil {
+		return
+	}
+	resultCache.Lock()
+	defer resultCache.Unlock()
+	resultCache.out.WriteString(out)
+	resultCache.out.WriteString("\n")
+}
+
+// discardResult stops the current command's result from being cached, as
+// when it fails
+func discardResult() {
+	if resultCache == nil {
+		return
+	}
+	resultCache.Lock()
+	defer resultCache.Unlock()
+	resultCache.discarded = true
+}
+
+// SaveResult saves the result of the command that just ran, if it is being
+// cached. Problems are reported but never fatal; the command has done its
+// work by now.
+func SaveResult() {
+	c := resultCache
+	if c == nil {
+		return
+	}
+	resultCache = nil
+
+	c.Lock()
+	defer c.Unlock()
+	if c.mutated || c.discarded || c.out.Len() == 0 {
+		return
+	}
+
+	if err := writeResult(c.path, c.out.Bytes()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to cache the result: %s\n", err)
+	}
+}
+
+// writeResult writes a result into the cache, in a way that a command reading
+// it at the same time never sees half of it, and clears out old results
+func writeResult(path string, out []byte) error {
+	dir := filepath.Dir(path)
+	// Results can hold anything the API returns, so only the user gets to
+	// read them
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".result-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if time.Since(e.ModTime()) > resultCacheMaxAge {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+	return nil
+}
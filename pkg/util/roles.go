@@ -0,0 +1,143 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	"github.com/joyent/conch-shell/pkg/config"
+)
+
+// RoleCacheTTL is how long the user's roles are trusted before they are
+// fetched again
+var RoleCacheTTL = time.Hour
+
+// The workspace roles, weakest first
+var workspaceRoles = []string{"ro", "rw", "admin"}
+
+// roleCache is used when there is no profile to keep the cache in
+var roleCache *config.RoleCache
+
+func activeRoleCache() **config.RoleCache {
+	if !IgnoreConfig && ActiveProfile != nil {
+		return &ActiveProfile.Roles
+	}
+	return &roleCache
+}
+
+// UserRoles returns the current user's system and workspace roles. They are
+// cached in the active profile for RoleCacheTTL. BuildAPI must have been run
+// first.
+func UserRoles() (*config.RoleCache, error) {
+	cache := activeRoleCache()
+	if *cache != nil && time.Since((*cache).Fetched) < RoleCacheTTL {
+		return *cache, nil
+	}
+	return RefreshRoles()
+}
+
+// RefreshRoles fetches the current user's roles, whether or not the cached
+// ones are still good
+func RefreshRoles() (*config.RoleCache, error) {
+	profile, err := API.GetUserProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	roles := &config.RoleCache{
+		Fetched:    time.Now().UTC(),
+		Email:      profile.Email,
+		IsAdmin:    profile.IsAdmin,
+		Workspaces: make(map[string]string),
+	}
+	for _, w := range profile.Workspaces {
+		roles.Workspaces[w.ID.String()] = w.Role
+	}
+
+	*activeRoleCache() = roles
+	WriteConfig()
+	return roles, nil
+}
+
+// primeRoles makes sure the roles are cached once the user is logged in.
+// Failing to get them isn't fatal; the API has the last word anyway.
+func primeRoles() {
+	if _, err := UserRoles(); err != nil && Debug {
+		fmt.Fprintf(os.Stderr, "Could not fetch the user's roles: %s\n", err)
+	}
+}
+
+// RoleAtLeast reports whether the workspace role have is at least as strong
+// as want
+func RoleAtLeast(have string, want string) bool {
+	rank := func(r string) int {
+		for i, known := range workspaceRoles {
+			if known == r {
+				return i
+			}
+		}
+		return -1
+	}
+	return rank(have) >= rank(want)
+}
+
+// RequireSystemAdmin bails if the user is known not to be a system admin.
+// what names the commands, eg "The admin commands". If the roles can't be
+// fetched, the command goes ahead and the API decides.
+func RequireSystemAdmin(what string) {
+	roles, err := UserRoles()
+	if err != nil || roles.IsAdmin {
+		return
+	}
+	Bail(fmt.Errorf(
+		"%s need a system admin, and %s is not one. If that has just changed, run 'conch user roles --refresh'",
+		what,
+		roles.Email,
+	))
+}
+
+// BuildAPIRequiringAdmin returns a function, suitable for a command's Before,
+// that builds the API, verifies the login, and then requires a system admin
+func BuildAPIRequiringAdmin(what string) func() {
+	return func() {
+		BuildAPIAndVerifyLogin()
+		RequireSystemAdmin(what)
+	}
+}
+
+// WarnWorkspaceRole warns, on STDERR, if the user's role in the workspace is
+// weaker than want. It only warns, since the role may come from a parent
+// workspace that the cache doesn't know about. System admins can do anything.
+func WarnWorkspaceRole(workspaceID uuid.UUID, want string, what string) {
+	if uuid.Equal(workspaceID, uuid.UUID{}) {
+		return
+	}
+
+	roles, err := UserRoles()
+	if err != nil || roles.IsAdmin {
+		return
+	}
+
+	have, ok := roles.Workspaces[workspaceID.String()]
+	if ok && RoleAtLeast(have, want) {
+		return
+	}
+	if !ok {
+		have = "no direct"
+	}
+
+	fmt.Fprintf(
+		os.Stderr,
+		"Warning: %s needs the '%s' role in the workspace, and you have %s access. The API will probably refuse it\n",
+		what,
+		want,
+		have,
+	)
+}
@@ -0,0 +1,52 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RunbookDefault is the runbook key used for validations that don't have a
+// runbook of their own
+const RunbookDefault = "*"
+
+// RunbookURL is the active profile's runbook for a validation, or "" if it
+// has none. {name} and {version} in the URL are filled in, so that a single
+// default runbook can point at a page per validation.
+func RunbookURL(name string, version int) string {
+	if ActiveProfile == nil || len(ActiveProfile.Runbooks) == 0 {
+		return ""
+	}
+
+	u, ok := ActiveProfile.Runbooks[name]
+	if !ok {
+		u = ActiveProfile.Runbooks[RunbookDefault]
+	}
+	if u == "" {
+		return ""
+	}
+
+	return strings.NewReplacer(
+		"{name}", url.PathEscape(name),
+		"{version}", strconv.Itoa(version),
+	).Replace(u)
+}
+
+// SetRunbook points the active profile's runbook for a validation at u. An
+// empty u removes it. The config is not saved.
+func SetRunbook(name string, u string) {
+	if u == "" {
+		delete(ActiveProfile.Runbooks, name)
+		return
+	}
+	if ActiveProfile.Runbooks == nil {
+		ActiveProfile.Runbooks = make(map[string]string)
+	}
+	ActiveProfile.Runbooks[name] = u
+}
@@ -0,0 +1,313 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+)
+
+// SchemaDialect is the JSON Schema version 'conch schema' writes
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// outputTypes maps a command, as named by CommandPath, to the type it prints
+// with --json
+var outputTypes = make(map[string]reflect.Type)
+
+// RegisterOutput records that the command at path, eg "device get", prints
+// something like v with --json. Only v's type is looked at, so a zero value
+// will do. Registering a command again replaces its type.
+func RegisterOutput(path string, v interface{}) {
+	outputTypes[path] = reflect.TypeOf(v)
+}
+
+// OutputCommands lists the commands whose output has been registered
+func OutputCommands() []string {
+	paths := make([]string, 0, len(outputTypes))
+	for p := range outputTypes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// FindOutputCommand finds the registered command that words, as typed after
+// 'conch', name. Aliases of top level commands are understood. Words that
+// aren't in any registered path, like IDs, are skipped, so "d ABC get" is
+// "device get".
+func FindOutputCommand(words []string) (string, bool) {
+	if len(words) == 0 {
+		return "", false
+	}
+
+	top := TopCommand(words[0])
+	if top == "" {
+		top = words[0]
+	}
+
+	known := make(map[string]bool)
+	for p := range outputTypes {
+		parts := strings.Fields(p)
+		if parts[0] != top {
+			continue
+		}
+		for _, w := range parts[1:] {
+			known[w] = true
+		}
+	}
+
+	path := []string{top}
+	for _, w := range words[1:] {
+		if known[w] {
+			path = append(path, w)
+		}
+	}
+
+	found := strings.Join(path, " ")
+	_, ok := outputTypes[found]
+	return found, ok
+}
+
+// OutputSchema is a JSON Schema for the --json output of the command at path
+func OutputSchema(path string) (map[string]interface{}, error) {
+	t, ok := outputTypes[path]
+	if !ok {
+		return nil, fmt.Errorf("no schema is known for '%s'. 'conch schema --list' shows the commands that have one", path)
+	}
+
+	s := JSONSchema(t)
+	s["title"] = "conch " + path
+	return s, nil
+}
+
+// JSONSchema describes how encoding/json renders values of type t. Named
+// structs are put in $defs and referred to, so recursive types work.
+func JSONSchema(t reflect.Type) map[string]interface{} {
+	g := &schemaGen{defs: make(map[string]interface{})}
+	s := g.schema(t)
+
+	out := map[string]interface{}{"$schema": SchemaDialect}
+	for k, v := range s {
+		out[k] = v
+	}
+	if len(g.defs) > 0 {
+		out["$defs"] = g.defs
+	}
+	return out
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	uuidType          = reflect.TypeOf(uuid.UUID{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+type schemaGen struct {
+	defs map[string]interface{}
+}
+
+// defName names a struct in $defs after its package and type, eg
+// "conch.Device"
+func defName(t reflect.Type) string {
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+func nullable(s map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"anyOf": []interface{}{s, map[string]interface{}{"type": "null"}},
+	}
+}
+
+func (g *schemaGen) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case uuidType:
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	// Types that encode themselves could be anything, except that text is
+	// always a string
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return map[string]interface{}{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(g.schema(t.Elem()))
+
+	case reflect.Interface:
+		return map[string]interface{}{}
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		// A nil slice is encoded as null
+		return map[string]interface{}{
+			"type":  []string{"array", "null"},
+			"items": g.schema(t.Elem()),
+		}
+
+	case reflect.Array:
+		return map[string]interface{}{
+			"type":     "array",
+			"items":    g.schema(t.Elem()),
+			"minItems": t.Len(),
+			"maxItems": t.Len(),
+		}
+
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 []string{"object", "null"},
+			"additionalProperties": g.schema(t.Elem()),
+		}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := defName(t)
+		if _, ok := g.defs[name]; !ok {
+			// Claimed before it is filled in, so that the struct can
+			// refer to itself
+			g.defs[name] = map[string]interface{}{}
+			g.defs[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + name}
+	}
+
+	// Channels, functions, and complex numbers can't be encoded at all
+	return map[string]interface{}{"not": map[string]interface{}{}}
+}
+
+// structSchema follows encoding/json's rules for struct fields: only
+// exported fields are encoded, under the name in their json tag, and the
+// fields of embedded structs without a tag are promoted
+func (g *schemaGen) structSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	required := make([]string, 0)
+	g.addFields(t, props, &required)
+
+	sort.Strings(required)
+	s := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *schemaGen) addFields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	// The struct's own fields go first, as they win over promoted ones
+	embedded := make([]reflect.Type, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		opts := parts[1:]
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		var s map[string]interface{}
+		if stringIn("string", opts) && isScalar(ft) {
+			s = map[string]interface{}{"type": "string"}
+		} else {
+			s = g.schema(ft)
+		}
+		props[name] = s
+
+		if !stringIn("omitempty", opts) {
+			*required = append(*required, name)
+		}
+	}
+
+	for _, et := range embedded {
+		promoted := make(map[string]interface{})
+		promotedRequired := make([]string, 0)
+		g.addFields(et, promoted, &promotedRequired)
+
+		for name, s := range promoted {
+			if _, ok := props[name]; !ok {
+				props[name] = s
+				if stringIn(name, promotedRequired) {
+					*required = append(*required, name)
+				}
+			}
+		}
+	}
+}
+
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func stringIn(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
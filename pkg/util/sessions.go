@@ -0,0 +1,96 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+)
+
+// Session is a token as 'user sessions' and 'admin user USER sessions' list
+// it
+type Session struct {
+	conch.UserToken
+	Kind string `json:"kind"`
+}
+
+// Sessions is what 'user sessions' prints with --json
+type Sessions struct {
+	LastLogin time.Time `json:"last_login"`
+	Sessions  []Session `json:"sessions"`
+}
+
+// NewSessions lists tokens as sessions, newest use first
+func NewSessions(lastLogin time.Time, tokens conch.UserTokens) Sessions {
+	s := Sessions{LastLogin: lastLogin, Sessions: make([]Session, 0, len(tokens))}
+	for _, t := range tokens {
+		kind := "api"
+		if t.IsLogin() {
+			kind = "login"
+		}
+		s.Sessions = append(s.Sessions, Session{UserToken: t, Kind: kind})
+	}
+
+	sort.SliceStable(s.Sessions, func(i, j int) bool {
+		a, b := s.Sessions[i], s.Sessions[j]
+		if !a.LastUsed.Equal(b.LastUsed) {
+			return a.LastUsed.After(b.LastUsed)
+		}
+		return a.Name < b.Name
+	})
+	return s
+}
+
+// RenderSessions prints sessions, as JSON or as a table
+func RenderSessions(s Sessions, sorting *Sorting) {
+	header := []string{"Name", "Kind", "Created", "Last Used", "Last IP", "Expires"}
+	row := func(i int) []string {
+		t := s.Sessions[i]
+		lastUsed := ""
+		if !t.LastUsed.IsZero() {
+			lastUsed = TimeStr(t.LastUsed)
+		}
+		expires := ""
+		if !t.Expires.IsZero() {
+			expires = TimeStr(t.Expires)
+		}
+		return []string{
+			t.Name,
+			t.Kind,
+			TimeStr(t.Created),
+			lastUsed,
+			t.LastIPAddr,
+			expires,
+		}
+	}
+
+	if err := sorting.Sort(s.Sessions, header, row); err != nil {
+		Bail(err)
+	}
+
+	if JSON {
+		JSONOut(s)
+		return
+	}
+
+	lastLogin := "never"
+	if !s.LastLogin.IsZero() {
+		lastLogin = TimeStr(s.LastLogin)
+	}
+	fmt.Printf("Last login: %s\n\n", lastLogin)
+
+	if len(s.Sessions) == 0 {
+		fmt.Println("No active sessions")
+		return
+	}
+	if err := RenderTable(GetMarkdownTable(), header, len(s.Sessions), row); err != nil {
+		Bail(err)
+	}
+}
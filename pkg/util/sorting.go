@@ -0,0 +1,225 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	cli "github.com/jawher/mow.cli"
+)
+
+// Sorting is the order a listing was asked to display in, via the flags that
+// SortFlags adds to its command
+type Sorting struct {
+	keys    *[]string
+	reverse *bool
+}
+
+// SortFlags adds --sort and --reverse to a listing command. The default sort
+// keys come from CONCH_SORT_<LISTING>, so CONCH_SORT_DEVICES=health,-created
+// makes every device listing sort by health and then newest first.
+func SortFlags(cmd *cli.Cmd, listing string) *Sorting {
+	return &Sorting{
+		keys: cmd.Strings(cli.StringsOpt{
+			Name:   "sort",
+			Value:  []string{},
+			Desc:   "Sort by these columns, in order. Prefix a column with '-' to sort it in descending order. May be repeated or comma separated",
+			EnvVar: "CONCH_SORT_" + strings.ToUpper(listing),
+		}),
+		reverse: cmd.BoolOpt("reverse", false, "Reverse the order of the listing"),
+	}
+}
+
+type sortKey struct {
+	column     int
+	descending bool
+}
+
+func sortColumnName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '_' || r == '-' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+func (s *Sorting) sortKeys(header []string) ([]sortKey, error) {
+	keys := make([]sortKey, 0)
+	if s.keys == nil {
+		return keys, nil
+	}
+
+	for _, opt := range *s.keys {
+		for _, name := range strings.Split(opt, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+
+			k := sortKey{column: -1}
+			if strings.HasPrefix(name, "-") {
+				k.descending = true
+				name = name[1:]
+			}
+
+			for i, h := range header {
+				if sortColumnName(h) == sortColumnName(name) {
+					k.column = i
+					break
+				}
+			}
+			if k.column < 0 {
+				return keys, fmt.Errorf(
+					"cannot sort by '%s'. Available columns: %s",
+					name,
+					strings.Join(header, ", "),
+				)
+			}
+
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// Sort reorders slice, which must be a slice, to follow the flags. header
+// names the columns of the listing and row renders item i the way the listing
+// displays it, so that sorting works on exactly what the user sees. With
+// neither flag given, the slice is left as it is.
+func (s *Sorting) Sort(slice interface{}, header []string, row func(i int) []string) error {
+	if s == nil {
+		return nil
+	}
+
+	keys, err := s.sortKeys(header)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(slice)
+	n := v.Len()
+
+	if len(keys) == 0 && !*s.reverse {
+		return nil
+	}
+
+	rows := make([][]string, n)
+	order := make([]int, n)
+	for i := 0; i < n; i++ {
+		rows[i] = row(i)
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		for _, k := range keys {
+			c := compareSortValues(rows[order[a]][k.column], rows[order[b]][k.column])
+			if c == 0 {
+				continue
+			}
+			if k.descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+
+	if *s.reverse {
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+
+	sorted := reflect.MakeSlice(v.Type(), n, n)
+	for i, o := range order {
+		sorted.Index(i).Set(v.Index(o))
+	}
+	reflect.Copy(v, sorted)
+
+	return nil
+}
+
+// compareSortValues orders two displayed values. Times are compared as times,
+// and runs of digits as numbers, so that "rack 9" comes before "rack 10".
+// Empty values come last.
+func compareSortValues(a string, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	if ta, err := time.Parse(DateFormat, a); err == nil {
+		if tb, err := time.Parse(DateFormat, b); err == nil {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if c := naturalCompare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// NaturalLess reports whether a sorts before b in the order --sort uses, so
+// that "Ethernet9" comes before "Ethernet10"
+func NaturalLess(a string, b string) bool {
+	return compareSortValues(a, b) < 0
+}
+
+func naturalCompare(a string, b string) int {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da != "" && db != "" {
+			na := strings.TrimLeft(da, "0")
+			nb := strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				if len(na) < len(nb) {
+					return -1
+				}
+				return 1
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+
+		if a[0] != b[0] {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
@@ -0,0 +1,218 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cli "github.com/jawher/mow.cli"
+	"github.com/joyent/conch-shell/pkg/config"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// DefaultStatsFile is where usage statistics are kept unless 'conch stats
+// enable' is told otherwise
+var DefaultStatsFile = config.DataPath("stats.json")
+
+// CommandStats is what is known about the use of a single command
+type CommandStats struct {
+	Runs     int           `json:"runs"`
+	Failures int           `json:"failures"`
+	Total    time.Duration `json:"total_ns"`
+	Max      time.Duration `json:"max_ns"`
+	LastRun  time.Time     `json:"last_run"`
+}
+
+// Average is the mean runtime of the command
+func (s CommandStats) Average() time.Duration {
+	if s.Runs == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Runs)
+}
+
+// UsageStats is the content of the stats file. Commands are keyed by their
+// words, with arguments and option values left out, so that 'device ABC get'
+// and 'device XYZ get' are both 'device get'.
+type UsageStats struct {
+	Since    time.Time                `json:"since"`
+	Commands map[string]*CommandStats `json:"commands"`
+}
+
+// StatsFile is the expanded path of the stats file, or "" if statistics are
+// not being kept
+func StatsFile() (string, error) {
+	if Config == nil || Config.StatsFile == "" {
+		return "", nil
+	}
+	return homedir.Expand(Config.StatsFile)
+}
+
+// LoadUsageStats reads the stats file. A missing file is empty stats.
+func LoadUsageStats(path string) (*UsageStats, error) {
+	stats := &UsageStats{Commands: make(map[string]*CommandStats)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, fmt.Errorf("failed to parse stats file %s: %s", path, err)
+	}
+	if stats.Commands == nil {
+		stats.Commands = make(map[string]*CommandStats)
+	}
+	return stats, nil
+}
+
+// SaveUsageStats writes the stats file
+func SaveUsageStats(path string, stats *UsageStats) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// statsRun is the command currently being timed
+type statsRun struct {
+	path    string
+	command string
+	started time.Time
+}
+
+var activeStats *statsRun
+
+// StartStats starts timing the command being run, if statistics are being
+// kept. It is called from the app's Before, once the config is loaded.
+func StartStats() {
+	path, err := StatsFile()
+	if err != nil || path == "" {
+		return
+	}
+
+	command := CommandPath()
+	if command == "" {
+		return
+	}
+
+	activeStats = &statsRun{
+		path:    path,
+		command: command,
+		started: time.Now(),
+	}
+}
+
+// CancelStats stops the current command from being recorded, for commands
+// that turn statistics off or throw them away
+func CancelStats() {
+	activeStats = nil
+}
+
+// FinishStats records the command in the stats file. A nil error records a
+// success. Nothing here is ever fatal; statistics aren't worth failing over.
+func FinishStats(err error) {
+	run := activeStats
+	if run == nil {
+		return
+	}
+	activeStats = nil
+
+	elapsed := time.Since(run.started)
+
+	stats, loadErr := LoadUsageStats(run.path)
+	if loadErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record usage statistics: %s\n", loadErr)
+		return
+	}
+	if stats.Since.IsZero() {
+		stats.Since = run.started.UTC()
+	}
+
+	s, ok := stats.Commands[run.command]
+	if !ok {
+		s = &CommandStats{}
+		stats.Commands[run.command] = s
+	}
+	s.Runs++
+	if err != nil {
+		s.Failures++
+	}
+	s.Total += elapsed
+	if elapsed > s.Max {
+		s.Max = elapsed
+	}
+	s.LastRun = run.started.UTC()
+
+	if err := SaveUsageStats(run.path, stats); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record usage statistics: %s\n", err)
+	}
+}
+
+// commandPaths holds the path of each command that mow.cli has set up
+var commandPaths = make(map[*cli.Cmd]string)
+
+// commandAliases maps each name a command can be run by, after its parent's
+// path, to the command's own path, eg "d" to "device" and "device r" to
+// "device reports"
+var commandAliases = make(map[string]string)
+
+// runningCommand is the path of the command being run
+var runningCommand string
+
+// Command adds a subcommand to parent, as parent.Command does, and keeps
+// track of its path. Every command is added this way, so that CommandPath
+// knows the command being run. mow.cli only sets up the commands that args
+// actually name, so the last of them to be set up is the one being run.
+func Command(parent *cli.Cmd, name, desc string, init cli.CmdInitializer) {
+	names := strings.Fields(name)
+	if len(names) == 0 {
+		parent.Command(name, desc, init)
+		return
+	}
+
+	prefix := commandPaths[parent]
+	if prefix != "" {
+		prefix += " "
+	}
+	path := prefix + names[0]
+	for _, n := range names {
+		commandAliases[prefix+n] = path
+	}
+
+	parent.Command(name, desc, func(cmd *cli.Cmd) {
+		commandPaths[cmd] = path
+		runningCommand = path
+		init(cmd)
+	})
+}
+
+// CommandPath names the command being run, by its words and with aliases
+// replaced by full names, so 'conch -j d ABC get' is 'device get'. Arguments
+// and option values are never part of it. Before the command's options have
+// been parsed, it is "".
+func CommandPath() string {
+	return runningCommand
+}
+
+// TopCommand names the top level command that name, which can be an alias,
+// runs, or "" if there is none
+func TopCommand(name string) string {
+	return commandAliases[name]
+}
@@ -0,0 +1,206 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+var (
+	// CountOnly is set by the global --count-only option. Lists print the
+	// number of entries they would have displayed, and nothing else.
+	CountOnly bool
+
+	// Summary is set by the global --summary option. Tables are followed by
+	// the number of rows and, where it makes sense, how many rows share each
+	// health, phase, and so on.
+	Summary bool
+)
+
+// RenderTable fills a table with rows 0 through n-1, leaving out any that
+// don't match --filter, and renders it. summaryColumns names the columns that
+// --summary breaks down by value.
+func RenderTable(
+	table *tablewriter.Table,
+	header []string,
+	n int,
+	row func(i int) []string,
+	summaryColumns ...string,
+) error {
+	if OutputFilter != nil {
+		if err := OutputFilter.checkColumns(header); err != nil {
+			return err
+		}
+	}
+
+	rows := make([][]string, 0, n)
+	for i := 0; i < n; i++ {
+		r := row(i)
+		if OutputFilter != nil && !OutputFilter.MatchRow(header, r) {
+			continue
+		}
+		rows = append(rows, r)
+	}
+
+	if CountOnly {
+		fmt.Println(len(rows))
+		return nil
+	}
+
+	table.SetHeader(header)
+	table.AppendBulk(rows)
+	table.Render()
+
+	if Summary {
+		fmt.Println()
+		fmt.Print(summarize(header, rows, summaryColumns))
+	}
+	return nil
+}
+
+// noGroup names the group of rows that groupOf put in no group
+const noGroup = "(none)"
+
+// RenderGroupedTable is RenderTable with the rows split up by groupOf, which
+// is called with each row's index. Each group gets its own table, from
+// newTable, under a heading with its number of rows, and the tables are
+// followed by the total. Groups are in order of name.
+func RenderGroupedTable(
+	newTable func() *tablewriter.Table,
+	header []string,
+	n int,
+	row func(i int) []string,
+	groupOf func(i int) string,
+	summaryColumns ...string,
+) error {
+	if OutputFilter != nil {
+		if err := OutputFilter.checkColumns(header); err != nil {
+			return err
+		}
+	}
+
+	rows := make(map[int][]string)
+	kept := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		r := row(i)
+		if OutputFilter != nil && !OutputFilter.MatchRow(header, r) {
+			continue
+		}
+		rows[i] = r
+		kept = append(kept, i)
+	}
+
+	if CountOnly {
+		fmt.Println(len(kept))
+		return nil
+	}
+
+	names, members := groupIndexes(len(kept), func(k int) string {
+		return groupOf(kept[k])
+	})
+
+	for g, name := range names {
+		if g > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%d)\n\n", name, len(members[name]))
+
+		groupRows := make([][]string, 0, len(members[name]))
+		for _, k := range members[name] {
+			groupRows = append(groupRows, rows[kept[k]])
+		}
+
+		table := newTable()
+		table.SetHeader(header)
+		table.AppendBulk(groupRows)
+		table.Render()
+
+		if Summary {
+			fmt.Println()
+			fmt.Print(summarize(header, groupRows, summaryColumns))
+		}
+	}
+
+	fmt.Printf("\nTotal: %d in %d groups\n", len(kept), len(names))
+	return nil
+}
+
+// groupIndexes puts 0 through n-1 into groups named by groupOf, keeping
+// their order. The names are sorted, with rows in no group last.
+func groupIndexes(n int, groupOf func(i int) string) ([]string, map[string][]int) {
+	members := make(map[string][]int)
+	names := make([]string, 0)
+	for i := 0; i < n; i++ {
+		name := groupOf(i)
+		if name == "" {
+			name = noGroup
+		}
+		if _, ok := members[name]; !ok {
+			names = append(names, name)
+		}
+		members[name] = append(members[name], i)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == noGroup) != (names[j] == noGroup) {
+			return names[j] == noGroup
+		}
+		return names[i] < names[j]
+	})
+
+	return names, members
+}
+
+// summarize counts the rows, and the rows sharing each value of the given
+// columns, eg:
+//
+//	Total: 42
+//	Health: fail 3, pass 39
+func summarize(header []string, rows [][]string, columns []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Total: %s\n", FormatCount(len(rows)))
+
+	for _, name := range columns {
+		col := -1
+		for i, h := range header {
+			if h == name {
+				col = i
+				break
+			}
+		}
+		if col < 0 || len(rows) == 0 {
+			continue
+		}
+
+		counts := make(map[string]int)
+		for _, r := range rows {
+			v := r[col]
+			if v == "" {
+				v = "(none)"
+			}
+			counts[v]++
+		}
+
+		values := make([]string, 0, len(counts))
+		for v := range counts {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+
+		parts := make([]string, 0, len(values))
+		for _, v := range values {
+			parts = append(parts, fmt.Sprintf("%s %s", v, FormatCount(counts[v])))
+		}
+		fmt.Fprintf(&b, "%s: %s\n", name, strings.Join(parts, ", "))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,23 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"os"
+)
+
+// IsTerminal reports whether f is a terminal that someone could answer a
+// prompt at. On Windows, a console counts, but the pipes that mintty and
+// other MSYS terminals hand their programs don't; run the shell through
+// winpty there to be asked.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,390 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// ErrNoJournal is returned when undo is attempted without a change journal
+var ErrNoJournal = errors.New("the active profile has no change journal, so there is nothing to undo. See 'conch profile set journal'")
+
+// undoRule covers one kind of mutation that can be reversed. capture records
+// the state the mutation is about to change, and inverse turns that record
+// back into a change that restores it. Both are handed the submatches of
+// pattern against the request path.
+type undoRule struct {
+	methods []string
+	pattern *regexp.Regexp
+	capture func(match []string) (interface{}, error)
+	inverse func(match []string, before json.RawMessage, p *Plan) error
+}
+
+// settingState is what a device setting was before it was set or deleted
+type settingState struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Present bool   `json:"present"`
+}
+
+var undoRules = []undoRule{
+	{
+		methods: []string{"DELETE"},
+		pattern: regexp.MustCompile(`/layout/([^/]+)$`),
+		capture: func(match []string) (interface{}, error) {
+			id, err := uuid.FromString(match[1])
+			if err != nil {
+				return nil, err
+			}
+			return API.GetRackLayoutSlot(id)
+		},
+		inverse: func(match []string, before json.RawMessage, p *Plan) error {
+			var slot conch.RackLayoutSlot
+			if err := json.Unmarshal(before, &slot); err != nil {
+				return err
+			}
+			p.Add(
+				PlanCreate,
+				"layout slot",
+				fmt.Sprintf("rack %s, RU %d", slot.RackID, slot.RUStart),
+				fmt.Sprintf("hardware product %s", slot.ProductID),
+				func() error {
+					return API.SaveRackLayoutSlot(&conch.RackLayoutSlot{
+						RackID:    slot.RackID,
+						ProductID: slot.ProductID,
+						RUStart:   slot.RUStart,
+					})
+				},
+			)
+			return nil
+		},
+	},
+
+	{
+		methods: []string{"POST"},
+		pattern: regexp.MustCompile(`/device/([^/]+)/asset_tag$`),
+		capture: func(match []string) (interface{}, error) {
+			d, err := API.GetDevice(match[1])
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"asset_tag": d.AssetTag}, nil
+		},
+		inverse: func(match []string, before json.RawMessage, p *Plan) error {
+			var prev map[string]string
+			if err := json.Unmarshal(before, &prev); err != nil {
+				return err
+			}
+			serial, tag := match[1], prev["asset_tag"]
+			p.Add(
+				PlanUpdate,
+				"asset tag",
+				serial,
+				fmt.Sprintf("restore '%s'", tag),
+				func() error { return API.SetDeviceAssetTag(serial, tag) },
+			)
+			return nil
+		},
+	},
+
+	{
+		methods: []string{"POST"},
+		pattern: regexp.MustCompile(`/device/([^/]+)/phase$`),
+		capture: func(match []string) (interface{}, error) {
+			phase, err := API.GetDevicePhase(match[1])
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"phase": phase}, nil
+		},
+		inverse: func(match []string, before json.RawMessage, p *Plan) error {
+			var prev map[string]string
+			if err := json.Unmarshal(before, &prev); err != nil {
+				return err
+			}
+			serial, phase := match[1], prev["phase"]
+			if phase == "" {
+				return errors.New("the previous phase is unknown")
+			}
+			p.Add(
+				PlanUpdate,
+				"phase",
+				serial,
+				fmt.Sprintf("restore '%s'", phase),
+				func() error { return API.SetDevicePhase(serial, phase) },
+			)
+			return nil
+		},
+	},
+
+	{
+		methods: []string{"POST", "DELETE"},
+		pattern: regexp.MustCompile(`/device/([^/]+)/settings/([^/]+)$`),
+		capture: func(match []string) (interface{}, error) {
+			key := match[2]
+			var (
+				settings map[string]string
+				err      error
+			)
+			if strings.HasPrefix(key, "tag.") {
+				settings, err = API.GetDeviceTags(match[1])
+			} else {
+				settings, err = API.GetDeviceSettings(match[1])
+			}
+			if err != nil && err != conch.ErrDataNotFound {
+				return nil, err
+			}
+			v, ok := settings[strings.TrimPrefix(key, "tag.")]
+			return settingState{key, v, ok}, nil
+		},
+		inverse: func(match []string, before json.RawMessage, p *Plan) error {
+			var prev settingState
+			if err := json.Unmarshal(before, &prev); err != nil {
+				return err
+			}
+
+			serial, key := match[1], prev.Key
+			kind := "setting"
+			set, del := API.SetDeviceSetting, API.DeleteDeviceSetting
+			if strings.HasPrefix(key, "tag.") {
+				kind = "tag"
+				set, del = API.SetDeviceTag, API.DeleteDeviceTag
+			}
+			name := fmt.Sprintf("%s on %s", key, serial)
+
+			if prev.Present {
+				p.Add(
+					PlanUpdate,
+					kind,
+					name,
+					fmt.Sprintf("restore '%s'", prev.Value),
+					func() error { return set(serial, key, prev.Value) },
+				)
+			} else {
+				p.Add(
+					PlanDelete,
+					kind,
+					name,
+					"it did not exist before",
+					func() error { return del(serial, key) },
+				)
+			}
+			return nil
+		},
+	},
+
+	{
+		methods: []string{"DELETE"},
+		pattern: regexp.MustCompile(`/workspace/([^/]+)/user/email=([^/]+)$`),
+		capture: func(match []string) (interface{}, error) {
+			users, err := API.GetWorkspaceUsers(stringer(match[1]))
+			if err != nil {
+				return nil, err
+			}
+			for _, u := range users {
+				if strings.EqualFold(u.Email, match[2]) {
+					return map[string]string{"email": u.Email, "role": u.Role}, nil
+				}
+			}
+			return nil, conch.ErrDataNotFound
+		},
+		inverse: func(match []string, before json.RawMessage, p *Plan) error {
+			var prev map[string]string
+			if err := json.Unmarshal(before, &prev); err != nil {
+				return err
+			}
+			ws, email, role := match[1], prev["email"], prev["role"]
+			p.Add(
+				PlanCreate,
+				"workspace member",
+				email,
+				fmt.Sprintf("re-add to workspace %s as %s", ws, role),
+				func() error { return API.AddUserToWorkspace(stringer(ws), email, role) },
+			)
+			return nil
+		},
+	},
+}
+
+// stringer lets a plain string be passed where the API wants a fmt.Stringer
+type stringer string
+
+func (s stringer) String() string { return string(s) }
+
+func matchUndoRule(method string, path string) (*undoRule, []string) {
+	for i := range undoRules {
+		r := &undoRules[i]
+		for _, m := range r.methods {
+			if m != method {
+				continue
+			}
+			if match := r.pattern.FindStringSubmatch(path); match != nil {
+				return r, match
+			}
+		}
+	}
+	return nil, nil
+}
+
+// captureUndoState is the API's BeforeMutation hook. It records the state an
+// undoable mutation is about to change. Anything that goes wrong just means
+// the mutation can't be undone later.
+func captureUndoState(method string, path string) json.RawMessage {
+	rule, match := matchUndoRule(method, path)
+	if rule == nil {
+		return nil
+	}
+
+	state, err := rule.capture(match)
+	if err != nil {
+		return nil
+	}
+
+	j, err := json.Marshal(state)
+	if err != nil {
+		return nil
+	}
+	return json.RawMessage(j)
+}
+
+// UndoableEntry is a journal entry along with the plan that reverses it
+type UndoableEntry struct {
+	Entry JournalEntry `json:"entry"`
+	Plan  *Plan        `json:"-"`
+
+	// Mutations in the entry that have no inverse, or whose earlier state
+	// wasn't recorded, and so are left alone
+	Skipped int `json:"skipped"`
+}
+
+// ReadJournal returns every entry in the active profile's change journal,
+// oldest first. Lines that don't parse are skipped.
+func ReadJournal() ([]JournalEntry, error) {
+	if ActiveProfile == nil || ActiveProfile.JournalDir == "" {
+		return nil, ErrNoJournal
+	}
+
+	dir, err := homedir.Expand(ActiveProfile.JournalDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	entries := make([]JournalEntry, 0)
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			var e JournalEntry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				continue
+			}
+			if e.ID == "" {
+				e.ID = e.Time.Format(time.RFC3339Nano)
+			}
+			entries = append(entries, e)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// UndoableEntries returns the journal entries that can still be undone,
+// newest first. Entries that are undos themselves, or that have already been
+// undone, are left out, as are entries recorded against a different API.
+func UndoableEntries() ([]UndoableEntry, error) {
+	entries, err := ReadJournal()
+	if err != nil {
+		return nil, err
+	}
+
+	undone := make(map[string]bool)
+	for _, e := range entries {
+		if e.UndoOf != "" {
+			undone[e.UndoOf] = true
+		}
+	}
+
+	out := make([]UndoableEntry, 0)
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.UndoOf != "" || undone[e.ID] || e.API != API.BaseURL {
+			continue
+		}
+
+		u := UndoableEntry{Entry: e, Plan: NewPlan()}
+
+		// Mutations are reversed newest first
+		for j := len(e.Mutations) - 1; j >= 0; j-- {
+			m := e.Mutations[j]
+			if m.Error != "" || m.Status < 200 || m.Status > 299 || len(m.Before) == 0 {
+				u.Skipped++
+				continue
+			}
+
+			path := m.URL
+			if u, err := url.Parse(m.URL); err == nil {
+				path = u.Path
+			}
+
+			rule, match := matchUndoRule(m.Method, path)
+			if rule == nil {
+				u.Skipped++
+				continue
+			}
+			if err := rule.inverse(match, m.Before, u.Plan); err != nil {
+				u.Skipped++
+			}
+		}
+
+		if len(u.Plan.Changes) > 0 {
+			out = append(out, u)
+		}
+	}
+
+	return out, nil
+}
+
+// MarkJournalUndo records, in the journal entry for the current command, that
+// it undoes the entry with the given ID
+func MarkJournalUndo(id string) {
+	if journal != nil {
+		journal.UndoOf = id
+	}
+}
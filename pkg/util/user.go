@@ -9,10 +9,17 @@ package util
 
 import (
 	"os/user"
+	"runtime"
 	"strconv"
 )
 
+// UserIsRoot reports whether the shell is running as root. Windows has no
+// uid 0, and its user IDs aren't numbers, so nobody is root there.
 func UserIsRoot() bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+
 	current, err := user.Current()
 	if err != nil {
 		Bail(err)
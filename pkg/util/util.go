@@ -8,10 +8,12 @@
 package util
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -54,6 +56,9 @@ var (
 	// Trace decides if we should trace the HTTP transactions
 	// Yes, this is a bit of a kludge
 	Trace bool
+
+	// NoCompression asks the API for uncompressed responses
+	NoCompression bool
 )
 
 // These variables are provided by the build environment
@@ -64,8 +69,11 @@ var (
 
 	FlagsDisableApiVersionCheck string // Used in shell development
 	FlagsDisableApiTokenCRUD    string // Useful for preventing automations from creating and deleting tokens
-	FlagsNoAdmin                string // Useful for preventing automations from accessing admin commands
 
+	// ReleaseSigningKey is the base64 DER public key that release binaries
+	// are signed with. Without it, 'conch update self' can only check
+	// checksums.
+	ReleaseSigningKey string
 )
 
 func DisableApiVersionCheck() bool {
@@ -76,10 +84,6 @@ func DisableApiTokenCRUD() bool {
 	return FlagsDisableApiTokenCRUD != "0"
 }
 
-func NoAdmin() bool {
-	return FlagsNoAdmin != "0"
-}
-
 func init() {
 	SemVersion = CleanVersion(Version)
 }
@@ -102,11 +106,23 @@ func TimeStr(t time.Time) string {
 func BuildAPIAndVerifyLogin() {
 	BuildAPI()
 
+	if batchLoggedIn() {
+		return
+	}
+
+	// The recording has whatever the login check asked for back then, which
+	// need not be what it would ask for now
+	if Replaying() {
+		return
+	}
+
 	if Token != "" {
 		ok, err := API.VerifyToken()
 		if !ok {
 			Bail(err)
 		}
+		primeRoles()
+		markBatchLoggedIn()
 		return
 	}
 
@@ -116,11 +132,54 @@ func BuildAPIAndVerifyLogin() {
 
 	ActiveProfile.JWT = API.JWT
 	WriteConfig()
+	primeRoles()
+	markBatchLoggedIn()
+}
+
+// APIForProfile builds a Conch object for a profile other than the active
+// one, for commands that work across environments, and verifies its login
+func APIForProfile(name string) (*conch.Conch, error) {
+	if IgnoreConfig || Config == nil {
+		return nil, errors.New("other profiles can't be used when the config file is ignored")
+	}
+
+	p, ok := Config.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("no profile named '%s'", name)
+	}
+
+	api := &conch.Conch{
+		BaseURL:       p.BaseURL,
+		JWT:           p.JWT,
+		Token:         string(p.Token),
+		Debug:         Debug,
+		Trace:         Trace,
+		NoCompression: NoCompression,
+	}
+	if UserAgent != "" {
+		api.UA = UserAgent
+	}
+
+	if p.Token != "" {
+		if ok, err := api.VerifyToken(); !ok {
+			return nil, fmt.Errorf("profile '%s': %s", name, err)
+		}
+		return api, nil
+	}
+
+	if err := api.VerifyJwtLogin(RefreshTokenTime, false); err != nil {
+		return nil, fmt.Errorf("profile '%s': %s", name, err)
+	}
+	p.JWT = api.JWT
+	WriteConfig()
+
+	return api, nil
 }
 
 // WriteConfig serializes the Config struct to disk
 func WriteConfig() {
-	if IgnoreConfig {
+	// A replayed session mustn't leave anything from the recording behind
+	if IgnoreConfig || Replaying() {
 		return
 	}
 
@@ -139,10 +198,11 @@ func WriteConfigForce() {
 func BuildAPI() {
 	if IgnoreConfig {
 		API = &conch.Conch{
-			BaseURL: BaseURL,
-			Debug:   Debug,
-			Trace:   Trace,
-			Token:   Token,
+			BaseURL:       BaseURL,
+			Debug:         Debug,
+			Trace:         Trace,
+			Token:         Token,
+			NoCompression: NoCompression,
 		}
 
 	} else {
@@ -150,51 +210,39 @@ func BuildAPI() {
 			Bail(errors.New("no active profile. Please use 'conch profile' to create or set an active profile"))
 		}
 
-		API = &conch.Conch{
-			BaseURL: ActiveProfile.BaseURL,
-			JWT:     ActiveProfile.JWT,
-			Token:   string(ActiveProfile.Token),
-			Debug:   Debug,
-			Trace:   Trace,
-		}
+		API = profileAPI(ActiveProfile)
 	}
 
 	if UserAgent != "" {
 		API.UA = UserAgent
 	}
+	API.Transport = apiTransport()
 
-	version, err := API.GetVersion()
-	if err != nil {
-		Bail(err)
-	}
-
-	if DisableApiVersionCheck() {
+	if reuseBatchClient() {
+		StartJournal()
+		StartRequestBudget()
 		return
 	}
 
-	sem := CleanVersion(version)
-	minSem := CleanVersion(conch.MinimumAPIVersion)
-	maxSem := CleanVersion(conch.BreakingAPIVersion)
+	StartJournal()
+	StartRequestBudget()
+	startResultCache()
 
-	if sem.Major != minSem.Major {
-		Bail(fmt.Errorf(
-			"cannot continue. the major version of API server '%s' is '%d' and we require '%d'",
-			API.BaseURL,
-			sem.Major,
-			minSem.Major,
-		))
+	version, err := API.GetVersion()
+	if err != nil {
+		Bail(err)
 	}
+	APIServerVersion = version
 
-	if sem.LT(minSem) || sem.GTE(maxSem) {
-		Bail(fmt.Errorf(
-			"cannot continue. the API server version '%s' is '%s' and we require >= %s and < %s",
-			API.BaseURL,
-			sem,
-			minSem,
-			maxSem,
-		))
-
+	if !DisableApiVersionCheck() {
+		if SkipVersionCheck {
+			WarnSkipVersionCheck()
+		} else if err := CheckAPIVersion(version); err != nil {
+			Bail(err)
+		}
 	}
+
+	rememberBatchClient()
 }
 
 // GetMarkdownTable returns a tablewriter configured to output markdown
@@ -246,30 +294,77 @@ func Bail(err error) {
 		fmt.Println(msg)
 	}
 
+	FinishNotifier(errors.New(msg))
+	FinishStats(errors.New(msg))
+	discardResult()
+	PrintRunProfile()
+
+	if BatchMode {
+		panic(BatchExit{Code: 1, Message: msg})
+	}
 	cli.Exit(1)
 }
 
+// DisplayDeviceFields names the device fields that DisplayDevices will
+// actually render, for use with the API's sparse fieldsets. nil means every
+// field is needed.
+func DisplayDeviceFields(fullOutput bool) []string {
+	fields := []string{
+		"id",
+		"asset_tag",
+		"created",
+		"last_seen",
+		"health",
+		"validated",
+		"graduated",
+		"phase",
+	}
+
+	if !fullOutput {
+		return fields
+	}
+
+	// Full JSON output hands back the whole device
+	if JSON {
+		return nil
+	}
+
+	// FillDeviceLocations works from the rack the device is in
+	return append(fields, "rack_id", "rack_unit_start")
+}
+
+// BriefDevice is what DisplayDevices prints with --json for each device,
+// unless the full output was asked for.
+//
+// BUG(sungo) for back compat
+// AZ and Rack were not ported over since they are always zero-value
+// without fullOutput
+type BriefDevice struct {
+	ID        string    `json:"id"`
+	AssetTag  string    `json:"asset_tag"`
+	Created   time.Time `json:"created"`
+	LastSeen  time.Time `json:"last_seen"`
+	Health    string    `json:"health"`
+	Graduated time.Time `json:"graduated"`
+	Validated time.Time `json:"validated"`
+	Phase     string    `json:"phase"`
+}
+
 // DisplayDevices is an abstraction to make sure that the output of
-// Devices is uniform, be it tables, json, or full json
-func DisplayDevices(devices []conch.Device, fullOutput bool) (err error) {
+// Devices is uniform, be it tables, json, or full json. The devices are put in
+// the order asked for by sorting, which may be nil.
+func DisplayDevices(devices []conch.Device, fullOutput bool, sorting *Sorting, extra ...DeviceColumn) (err error) {
 	if fullOutput {
-		filledIn := make([]conch.Device, 0)
-		for _, d := range devices {
-			if d.Location.Rack.Name == "" {
-				// The table renderer only needs the location data so there's no
-				// need to go get a full DetailedDevice with its attendant database
-				// queries.
-				// In my experience, getting the full DetailedDevice doubles this
-				// query time [sungo]
-				loc, err := API.GetDeviceLocation(d.ID)
-				if err != nil {
-					return err
-				}
-				d.Location = loc
-			}
-			filledIn = append(filledIn, d)
+		devices, err = FillDeviceLocations(devices)
+		if err != nil {
+			return err
 		}
-		devices = filledIn
+	}
+
+	header, row := deviceTable(devices, fullOutput, extra...)
+
+	if err := sorting.Sort(devices, header, row); err != nil {
+		return err
 	}
 
 	if JSON {
@@ -278,65 +373,214 @@ func DisplayDevices(devices []conch.Device, fullOutput bool) (err error) {
 			return nil
 		}
 
-		// BUG(sungo) for back compat
-		// AZ and Rack were not ported over since they are always zero-value
-		// without fullOutput
-		output := make([]interface{}, 0)
-		for _, d := range devices {
-			output = append(output, struct {
-				ID        string    `json:"id"`
-				AssetTag  string    `json:"asset_tag"`
-				Created   time.Time `json:"created"`
-				LastSeen  time.Time `json:"last_seen"`
-				Health    string    `json:"health"`
-				Graduated time.Time `json:"graduated"`
-				Validated time.Time `json:"validated"`
-				Phase     string    `json:"phase"`
-			}{
-				d.ID,
-				d.AssetTag,
-				d.Created,
-				d.LastSeen,
-				d.Health,
-				d.Graduated,
-				d.Validated,
-				d.Phase,
-			})
+		JSONOut(briefDevices(devices))
+		return nil
+	}
+
+	return RenderTable(GetMarkdownTable(), header, len(devices), row, "Health", "Phase")
+}
+
+// FieldedDevice is what DisplayDevicesWithFields prints with --json for each
+// device: the usual output with the custom fields asked for alongside
+type FieldedDevice struct {
+	BriefDevice
+	Fields map[string]string `json:"fields"`
+}
+
+// FullFieldedDevice is FieldedDevice for the full output
+type FullFieldedDevice struct {
+	conch.Device
+	Fields map[string]string `json:"fields"`
+}
+
+// DisplayDevicesWithFields is DisplayDevices with a column added for each of
+// the custom fields asked for with --field
+func DisplayDevicesWithFields(
+	devices []conch.Device,
+	fullOutput bool,
+	sorting *Sorting,
+	columns *FieldColumns,
+	extra ...DeviceColumn,
+) (err error) {
+	names := columns.Names()
+	if len(names) == 0 {
+		return DisplayDevices(devices, fullOutput, sorting, extra...)
+	}
+
+	if fullOutput {
+		devices, err = FillDeviceLocations(devices)
+		if err != nil {
+			return err
+		}
+	}
+
+	values, err := DeviceFieldValues(devices)
+	if err != nil {
+		return err
+	}
+	fieldsOf := func(d conch.Device) map[string]string {
+		fields := make(map[string]string)
+		for _, n := range names {
+			if v, ok := values[d.ID][n]; ok {
+				fields[n] = v
+			}
+		}
+		return fields
+	}
+
+	header, deviceRow := deviceTable(devices, fullOutput)
+	header = append(header, names...)
+	for _, c := range extra {
+		header = append(header, c.Name)
+	}
+	row := func(i int) []string {
+		r := deviceRow(i)
+		for _, n := range names {
+			r = append(r, values[devices[i].ID][n])
+		}
+		for _, c := range extra {
+			r = append(r, c.Value(devices[i]))
 		}
+		return r
+	}
+
+	if err := sorting.Sort(devices, header, row); err != nil {
+		return err
+	}
 
+	if JSON {
+		if fullOutput {
+			output := make([]FullFieldedDevice, 0, len(devices))
+			for _, d := range devices {
+				output = append(output, FullFieldedDevice{d, fieldsOf(d)})
+			}
+			JSONOut(output)
+			return nil
+		}
+
+		brief := briefDevices(devices)
+		output := make([]FieldedDevice, 0, len(devices))
+		for i, d := range devices {
+			output = append(output, FieldedDevice{brief[i], fieldsOf(d)})
+		}
 		JSONOut(output)
 		return nil
 	}
 
-	table := GetMarkdownTable()
+	return RenderTable(GetMarkdownTable(), header, len(devices), row, "Health", "Phase")
+}
 
+// DeviceGroup is what DisplayDeviceGroups prints with --json for each group.
+// Devices is a list of BriefDevice, or of conch.Device for the full output.
+type DeviceGroup struct {
+	Group   string      `json:"group"`
+	Count   int         `json:"count"`
+	Devices interface{} `json:"devices"`
+}
+
+// DisplayDeviceGroups is DisplayDevices with the devices split up by
+// groupOf, eg by health. Each group gets its own table, under a heading with
+// the number of devices in it. With --json, the groups are a list of
+// DeviceGroup.
+func DisplayDeviceGroups(
+	devices []conch.Device,
+	fullOutput bool,
+	sorting *Sorting,
+	groupOf func(d conch.Device) string,
+	extra ...DeviceColumn,
+) (err error) {
 	if fullOutput {
-		table.SetHeader([]string{
-			"AZ",
-			"Rack",
-			"ID",
-			"Asset Tag",
-			"Created",
-			"Last Seen",
-			"Health",
-			"Validated",
-			"Graduated",
-			"Phase",
-		})
-	} else {
-		table.SetHeader([]string{
-			"ID",
-			"Asset Tag",
-			"Created",
-			"Last Seen",
-			"Health",
-			"Validated",
-			"Graduated",
-			"Phase",
+		devices, err = FillDeviceLocations(devices)
+		if err != nil {
+			return err
+		}
+	}
+
+	header, row := deviceTable(devices, fullOutput, extra...)
+
+	if err := sorting.Sort(devices, header, row); err != nil {
+		return err
+	}
+
+	if JSON {
+		names, members := groupIndexes(len(devices), func(i int) string {
+			return groupOf(devices[i])
 		})
+
+		groups := make([]DeviceGroup, 0, len(names))
+		for _, name := range names {
+			grouped := make([]conch.Device, 0, len(members[name]))
+			for _, i := range members[name] {
+				grouped = append(grouped, devices[i])
+			}
+
+			g := DeviceGroup{Group: name, Count: len(grouped)}
+			if fullOutput {
+				g.Devices = grouped
+			} else {
+				g.Devices = briefDevices(grouped)
+			}
+			groups = append(groups, g)
+		}
+
+		JSONOut(groups)
+		return nil
 	}
 
-	for _, d := range devices {
+	return RenderGroupedTable(
+		GetMarkdownTable,
+		header,
+		len(devices),
+		row,
+		func(i int) string { return groupOf(devices[i]) },
+		"Health",
+		"Phase",
+	)
+}
+
+// DeviceColumn is a column that a command adds to the end of its device
+// table, eg to note which devices are in maintenance. It only appears in the
+// table; the --json output is left as it is.
+type DeviceColumn struct {
+	Name  string
+	Value func(d conch.Device) string
+}
+
+// RackUnitColumn is a DeviceColumn of the rack unit each device starts at
+var RackUnitColumn = DeviceColumn{
+	Name: "RU",
+	Value: func(d conch.Device) string {
+		ru := d.RackUnitStart
+		if ru == 0 {
+			ru = d.Location.RackUnitStart
+		}
+		if ru == 0 {
+			return ""
+		}
+		return strconv.Itoa(ru)
+	},
+}
+
+// deviceTable is the header and rows that DisplayDevices and
+// DisplayDeviceGroups render
+func deviceTable(devices []conch.Device, fullOutput bool, extra ...DeviceColumn) ([]string, func(int) []string) {
+	header := []string{
+		"ID",
+		"Asset Tag",
+		"Created",
+		"Last Seen",
+		"Health",
+		"Validated",
+		"Graduated",
+		"Phase",
+	}
+	if fullOutput {
+		header = append([]string{"AZ", "Rack"}, header...)
+	}
+
+	row := func(i int) []string {
+		d := devices[i]
+
 		validated := ""
 		if !d.Validated.IsZero() {
 			validated = TimeStr(d.Validated.UTC())
@@ -351,39 +595,61 @@ func DisplayDevices(devices []conch.Device, fullOutput bool) (err error) {
 			lastSeen = TimeStr(d.LastSeen.UTC())
 		}
 
-		if fullOutput {
-			table.Append([]string{
-				d.Location.Room.AZ,
-				d.Location.Rack.Name,
-				d.ID,
-				d.AssetTag,
-				TimeStr(d.Created.UTC()),
-				lastSeen,
-				d.Health,
-				validated,
-				graduated,
-				d.Phase,
-			})
-		} else {
-			table.Append([]string{
-				d.ID,
-				d.AssetTag,
-				TimeStr(d.Created.UTC()),
-				lastSeen,
-				d.Health,
-				validated,
-				graduated,
-				d.Phase,
-			})
+		r := []string{
+			d.ID,
+			d.AssetTag,
+			TimeStr(d.Created.UTC()),
+			lastSeen,
+			d.Health,
+			validated,
+			graduated,
+			d.Phase,
 		}
+
+		if !fullOutput {
+			return r
+		}
+
+		return append([]string{
+			d.Location.Room.AZ,
+			d.Location.Rack.Name,
+		}, r...)
 	}
 
-	table.Render()
+	if len(extra) == 0 {
+		return header, row
+	}
 
-	return nil
+	for _, c := range extra {
+		header = append(header, c.Name)
+	}
+	return header, func(i int) []string {
+		r := row(i)
+		for _, c := range extra {
+			r = append(r, c.Value(devices[i]))
+		}
+		return r
+	}
+}
+
+func briefDevices(devices []conch.Device) []BriefDevice {
+	output := make([]BriefDevice, 0)
+	for _, d := range devices {
+		output = append(output, BriefDevice{
+			d.ID,
+			d.AssetTag,
+			d.Created,
+			d.LastSeen,
+			d.Health,
+			d.Graduated,
+			d.Validated,
+			d.Phase,
+		})
+	}
+	return output
 }
 
-// JSONOut marshals an interface to JSON
+// JSONOut marshals an interface to JSON. Lists are filtered by --filter.
 func JSONOut(thingy interface{}) {
 	j, err := json.Marshal(thingy)
 
@@ -391,10 +657,42 @@ func JSONOut(thingy interface{}) {
 		Bail(err)
 	}
 
+	if OutputFilter != nil {
+		if j, err = OutputFilter.FilterJSON(j); err != nil {
+			Bail(err)
+		}
+	}
+
+	if CountOnly {
+		printJSONCount(j)
+		return
+	}
+
+	if OutputPlugin != nil {
+		if err := runOutputPlugin(j); err != nil {
+			Bail(err)
+		}
+		return
+	}
+
+	recordResult(string(j))
 	fmt.Println(string(j))
 }
 
-// JSONOutIndent marshals an interface to indented JSON
+// printJSONCount prints the number of entries in a JSON list, for
+// --count-only. Anything other than a list counts as a single entry.
+func printJSONCount(j []byte) {
+	count := 1
+	entries := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(j, &entries); err == nil {
+		count = len(entries)
+	}
+	recordResult(strconv.Itoa(count))
+	fmt.Println(count)
+}
+
+// JSONOutIndent marshals an interface to indented JSON. Lists are filtered by
+// --filter.
 func JSONOutIndent(thingy interface{}) {
 	j, err := json.MarshalIndent(thingy, "", "     ")
 
@@ -402,6 +700,32 @@ func JSONOutIndent(thingy interface{}) {
 		Bail(err)
 	}
 
+	if OutputFilter != nil {
+		filtered, err := OutputFilter.FilterJSON(j)
+		if err != nil {
+			Bail(err)
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, filtered, "", "     "); err != nil {
+			Bail(err)
+		}
+		j = out.Bytes()
+	}
+
+	if CountOnly {
+		printJSONCount(j)
+		return
+	}
+
+	if OutputPlugin != nil {
+		if err := runOutputPlugin(j); err != nil {
+			Bail(err)
+		}
+		return
+	}
+
+	recordResult(string(j))
 	fmt.Println(string(j))
 }
 
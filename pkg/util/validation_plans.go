@@ -0,0 +1,66 @@
+// Copyright Joyent, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"fmt"
+
+	"github.com/joyent/conch-shell/pkg/conch"
+	"github.com/joyent/conch-shell/pkg/conch/uuid"
+)
+
+// ValidationPlanAssignment is what 'validation-plan get' prints with --json,
+// for devices and hardware products alike
+type ValidationPlanAssignment struct {
+	conch.ValidationPlanAssignment
+	PlanName string `json:"validation_plan_name,omitempty"`
+}
+
+// DisplayValidationPlanAssignment prints the validation plan assigned to a
+// device or hardware product, along with the plan's name
+func DisplayValidationPlanAssignment(a conch.ValidationPlanAssignment) {
+	out := ValidationPlanAssignment{ValidationPlanAssignment: a}
+	if !uuid.Equal(a.PlanID, uuid.UUID{}) {
+		vp, err := API.GetValidationPlan(a.PlanID)
+		if err != nil && err != conch.ErrDataNotFound {
+			Bail(err)
+		}
+		out.PlanName = vp.Name
+	}
+
+	if JSON {
+		JSONOut(out)
+		return
+	}
+
+	switch {
+	case uuid.Equal(a.PlanID, uuid.UUID{}):
+		fmt.Println("No validation plan is assigned. The API picks the plan itself")
+	case out.PlanName == "":
+		fmt.Printf("%s, which no longer exists. Assigned to the %s\n", a.PlanID, a.Source)
+	default:
+		fmt.Printf("%s (%s). Assigned to the %s\n", out.PlanName, a.PlanID, a.Source)
+	}
+}
+
+// ValidationPlanToAssign looks up the plan given to a 'validation-plan set'
+// command, making sure it exists. A zero UUID is returned for --clear.
+func ValidationPlanToAssign(plan string, clear bool) (conch.ValidationPlan, error) {
+	if clear {
+		return conch.ValidationPlan{}, nil
+	}
+
+	id, err := MagicValidationPlanID(plan)
+	if err != nil {
+		return conch.ValidationPlan{}, err
+	}
+	vp, err := API.GetValidationPlan(id)
+	if err == conch.ErrDataNotFound {
+		return vp, fmt.Errorf("validation plan %s does not exist", plan)
+	}
+	return vp, err
+}